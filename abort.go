@@ -0,0 +1,72 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Reason of the aborts for which the operator gave none
+const defaultAbortReason = "No reason given"
+
+// Aborts the running game right away, unlike StopGame: Clients receive
+// GAME_ENDS with the last game state, no winner and the reason of the
+// operator, then they are kicked (ABORTED) and netorcai exits with a failure
+// code. The reason is also logged, so that game records tell operator aborts
+// from failures.
+// Returns an error if the game is not running or is already being aborted.
+func AbortGame(gs *GlobalState, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = defaultAbortReason
+	}
+
+	LockGlobalStateMutex(gs, "Abort game", "Admin")
+	defer UnlockGlobalStateMutex(gs, "Abort game", "Admin")
+
+	if gs.GameState != GAME_RUNNING || len(gs.GameLogic) == 0 {
+		return fmt.Errorf("Game is not running")
+	}
+
+	select {
+	case gs.GameLogic[0].abort <- reason:
+	default:
+		return fmt.Errorf("Game is already being aborted")
+	}
+	gs.AbortReason = reason
+	return nil
+}
+
+// The KICK reason of the clients of an aborted game.
+func abortKickReason(reason string) string {
+	return fmt.Sprintf("Game aborted by the operator: %v", reason)
+}
+
+// Aborts the running game on POST requests, with the body as reason
+// (see AbortGame).
+func handleAbort(gs *GlobalState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body,
+			adminMessageMaxBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := AbortGame(gs, string(body)); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"aborting": true})
+	}
+}
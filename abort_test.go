@@ -0,0 +1,25 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAbortGame(t *testing.T) {
+	glClient := &GameLogicClient{abort: make(chan string, 1)}
+	gs := &GlobalState{
+		GameState: GAME_NOT_RUNNING,
+		GameLogic: []*GameLogicClient{glClient},
+	}
+	assert.Error(t, AbortGame(gs, "Too late"), "Game aborted before it starts")
+
+	gs.GameState = GAME_RUNNING
+	assert.NoError(t, AbortGame(gs, " "))
+	if assert.Len(t, glClient.abort, 1, "Game logic not told to abort") {
+		assert.Equal(t, defaultAbortReason, <-glClient.abort)
+	}
+	assert.Equal(t, defaultAbortReason, gs.AbortReason)
+
+	glClient.abort <- "Power outage"
+	assert.Error(t, AbortGame(gs, "Again"), "Game aborted twice")
+}
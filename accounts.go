@@ -0,0 +1,85 @@
+package netorcai
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Persistent player accounts, stored in a JSON file that maps the SHA-256
+// hash of API keys to account names. API keys are not stored: They are
+// only printed when accounts are created.
+type Accounts struct {
+	filename string
+	mutex    sync.Mutex
+	names    map[string]string
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Loads an accounts file. A missing file is an empty store.
+func LoadAccounts(filename string) (*Accounts, error) {
+	accounts := &Accounts{
+		filename: filename,
+		names:    make(map[string]string),
+	}
+
+	err := readJSONFile(filename, &accounts.names)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// Creates an account and saves the store. Returns the API key of the account.
+func (a *Accounts) Add(name string) (string, error) {
+	if !regexp.MustCompile(`\A\S{1,64}\z`).MatchString(name) {
+		return "", fmt.Errorf("Invalid account name '%v' "+
+			"(1 to 64 non-space characters)", name)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, existingName := range a.names {
+		if existingName == name {
+			return "", fmt.Errorf("Account %v already exists", name)
+		}
+	}
+
+	keyBytes := make([]byte, 24)
+	_, err := rand.Read(keyBytes)
+	if err != nil {
+		return "", fmt.Errorf("Cannot generate API key: %v", err)
+	}
+	apiKey := hex.EncodeToString(keyBytes)
+
+	a.names[hashAPIKey(apiKey)] = name
+	content, err := json.MarshalIndent(a.names, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(a.filename, content, 0600)
+	}
+	if err != nil {
+		delete(a.names, hashAPIKey(apiKey))
+		return "", fmt.Errorf("Cannot save accounts: %v", err)
+	}
+	return apiKey, nil
+}
+
+// Returns the name of the account of an API key.
+func (a *Accounts) lookup(apiKey string) (string, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	name, exists := a.names[hashAPIKey(apiKey)]
+	return name, exists
+}
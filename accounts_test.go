@@ -0,0 +1,40 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-accounts")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "accounts.json")
+
+	accounts, err := LoadAccounts(filename)
+	assert.NoError(t, err, "A missing accounts file is an empty store")
+	apiKey, err := accounts.Add("alice")
+	assert.NoError(t, err, "Cannot add account")
+	_, err = accounts.Add("alice")
+	assert.Error(t, err, "Account names must be unique")
+	_, err = accounts.Add("alice bob")
+	assert.Error(t, err, "Account names cannot contain spaces")
+
+	// API keys are not stored, only their hash
+	content, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err, "Accounts file should be written")
+	assert.False(t, strings.Contains(string(content), apiKey),
+		"API key should not be stored")
+
+	accounts, err = LoadAccounts(filename)
+	assert.NoError(t, err, "Cannot reload accounts")
+	name, exists := accounts.lookup(apiKey)
+	assert.True(t, exists, "Account should be persistent")
+	assert.Equal(t, "alice", name)
+	_, exists = accounts.lookup("not a key")
+	assert.False(t, exists, "Unknown API key accepted")
+}
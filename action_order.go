@@ -0,0 +1,73 @@
+package netorcai
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// How the player actions are ordered in DO_TURN
+type ActionOrder int
+
+const (
+	// In the order in which the TURN_ACK have been received
+	ACTION_ORDER_ARRIVAL ActionOrder = iota
+	// By increasing player ID
+	ACTION_ORDER_PLAYER_ID
+	// In a random order drawn every turn (from --seed if it is set)
+	ACTION_ORDER_SHUFFLED
+)
+
+// The values of --action-order
+var ActionOrders = map[string]ActionOrder{
+	"arrival":   ACTION_ORDER_ARRIVAL,
+	"player-id": ACTION_ORDER_PLAYER_ID,
+	"shuffled":  ACTION_ORDER_SHUFFLED,
+}
+
+// Returns the random generator that shuffles the actions of a game:
+// The same seed gives the same orders, so that games can be reproduced.
+func newActionShuffler(seed *int64) *rand.Rand {
+	if seed != nil {
+		return rand.New(rand.NewSource(*seed))
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// Returns the actions of a turn in the order of the game logic, leaving
+// the received ones untouched.
+func (glClient *GameLogicClient) orderPlayerActions(
+	playerActions []MessageDoTurnPlayerAction) []MessageDoTurnPlayerAction {
+	ordered := make([]MessageDoTurnPlayerAction, len(playerActions))
+	copy(ordered, playerActions)
+	switch glClient.actionOrder {
+	case ACTION_ORDER_PLAYER_ID:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].PlayerID < ordered[j].PlayerID
+		})
+	case ACTION_ORDER_SHUFFLED:
+		// Sorted first, so that the order only depends on the generator
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].PlayerID < ordered[j].PlayerID
+		})
+		glClient.actionShuffler.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+	}
+	return ordered
+}
+
+// Appends the actions of a player to the actions received this turn.
+// Its previous actions, if any, are removed first: The new ones are placed
+// at the end without reordering the other ones, which stay in arrival order.
+func replacePlayerAction(playerActions []MessageDoTurnPlayerAction,
+	action MessageDoTurnPlayerAction) []MessageDoTurnPlayerAction {
+	for actionIndex, act := range playerActions {
+		if act.PlayerID == action.PlayerID {
+			playerActions = append(playerActions[:actionIndex],
+				playerActions[actionIndex+1:]...)
+			break
+		}
+	}
+	return append(playerActions, action)
+}
@@ -0,0 +1,80 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func actionPlayerIDs(actions []MessageDoTurnPlayerAction) []int {
+	playerIDs := []int{}
+	for _, action := range actions {
+		playerIDs = append(playerIDs, action.PlayerID)
+	}
+	return playerIDs
+}
+
+func TestOrderPlayerActions(t *testing.T) {
+	received := []MessageDoTurnPlayerAction{}
+	for _, playerID := range []int{3, 0, 2, 1, 5, 4} {
+		received = append(received, MessageDoTurnPlayerAction{PlayerID: playerID})
+	}
+
+	glClient := &GameLogicClient{actionOrder: ACTION_ORDER_ARRIVAL}
+	assert.Equal(t, []int{3, 0, 2, 1, 5, 4},
+		actionPlayerIDs(glClient.orderPlayerActions(received)),
+		"Actions should stay in arrival order")
+
+	glClient.actionOrder = ACTION_ORDER_PLAYER_ID
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5},
+		actionPlayerIDs(glClient.orderPlayerActions(received)),
+		"Actions should be sorted by player ID")
+	assert.Equal(t, []int{3, 0, 2, 1, 5, 4}, actionPlayerIDs(received),
+		"Received actions should be left untouched")
+
+	// Shuffled orders only depend on the seed
+	seed := int64(42)
+	shuffledOrders := func(received []MessageDoTurnPlayerAction) [][]int {
+		glClient := &GameLogicClient{
+			actionOrder:    ACTION_ORDER_SHUFFLED,
+			actionShuffler: newActionShuffler(&seed),
+		}
+		orders := [][]int{}
+		for turn := 0; turn < 5; turn++ {
+			orders = append(orders,
+				actionPlayerIDs(glClient.orderPlayerActions(received)))
+		}
+		return orders
+	}
+	orders := shuffledOrders(received)
+	reversed := []MessageDoTurnPlayerAction{}
+	for index := len(received) - 1; index >= 0; index-- {
+		reversed = append(reversed, received[index])
+	}
+	assert.Equal(t, orders, shuffledOrders(reversed),
+		"Shuffled orders should not depend on the arrival order")
+
+	allSame := true
+	for _, order := range orders {
+		assert.ElementsMatch(t, []int{0, 1, 2, 3, 4, 5}, order,
+			"Shuffled actions should be a permutation")
+		allSame = allSame && assert.ObjectsAreEqual(orders[0], order)
+	}
+	assert.False(t, allSame, "Actions should be shuffled every turn")
+}
+
+func TestReplacePlayerAction(t *testing.T) {
+	actions := []MessageDoTurnPlayerAction{}
+	for _, playerID := range []int{3, 0, 2, 1} {
+		actions = replacePlayerAction(actions,
+			MessageDoTurnPlayerAction{PlayerID: playerID})
+	}
+	assert.Equal(t, []int{3, 0, 2, 1}, actionPlayerIDs(actions),
+		"Actions should be in arrival order")
+
+	actions = replacePlayerAction(actions,
+		MessageDoTurnPlayerAction{PlayerID: 3, TurnNumber: 1})
+	assert.Equal(t, []int{0, 2, 1, 3}, actionPlayerIDs(actions),
+		"Replaced actions should be last, the other ones in arrival order")
+	assert.Equal(t, 1, actions[3].TurnNumber, "Actions not replaced")
+	assert.Len(t, actions, 4, "Actions of a player should be sent once")
+}
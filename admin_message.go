@@ -0,0 +1,105 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Number of ADMIN_MESSAGE buffered for a client.
+	// Messages are dropped for clients that are slower to read them.
+	adminMessageBufferSize = 16
+	// Maximum size of the text of an ADMIN_MESSAGE
+	adminMessageMaxBytes = 4096
+)
+
+// Sends an ADMIN_MESSAGE to all the players and visualizations logged in,
+// and to the spectators of the stream of turns.
+// Returns the number of clients it has been sent to.
+func BroadcastAdminMessage(gs *GlobalState, text string) int {
+	msg := MessageAdmin{
+		MessageType: "ADMIN_MESSAGE",
+		Text:        text,
+	}
+
+	LockGlobalStateMutex(gs, "Broadcast admin message", "Admin")
+	clients := append([]*PlayerOrVisuClient(nil), gs.Players...)
+	clients = append(clients, gs.SpecialPlayers...)
+	clients = append(clients, gs.Visus...)
+	UnlockGlobalStateMutex(gs, "Broadcast admin message", "Admin")
+
+	nbSent := 0
+	for _, pvClient := range clients {
+		select {
+		case pvClient.adminMessages <- msg:
+			nbSent++
+		default:
+			log.WithFields(log.Fields{
+				"nickname":       pvClient.client.nickname,
+				"remote address": pvClient.client.Conn.RemoteAddr(),
+			}).Warn("Dropping ADMIN_MESSAGE for a slow client")
+		}
+	}
+	globalTurnStream.announce("ADMIN_MESSAGE", msg)
+
+	log.WithFields(log.Fields{
+		"text":       text,
+		"nb sent":    nbSent,
+		"nb clients": len(clients),
+	}).Info("Admin message broadcast")
+	return nbSent
+}
+
+func sendAdminMessage(client *Client, msg MessageAdmin) error {
+	content, err := json.Marshal(msg)
+	if err == nil {
+		log.WithFields(log.Fields{
+			"nickname":       client.nickname,
+			"remote address": client.Conn.RemoteAddr(),
+			"content":        string(content),
+		}).Debug("Sending ADMIN_MESSAGE to client")
+		err = sendMessage(client, content)
+	}
+	return err
+}
+
+// Checks the text of an ADMIN_MESSAGE.
+func checkAdminMessageText(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("The text is empty")
+	}
+	if len(text) > adminMessageMaxBytes {
+		return fmt.Errorf("The text is too long (%v bytes, maximum is %v)",
+			len(text), adminMessageMaxBytes)
+	}
+	return nil
+}
+
+// Broadcasts the body of POST requests as an ADMIN_MESSAGE.
+func handleBroadcast(gs *GlobalState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body,
+			adminMessageMaxBytes+1))
+		if err == nil {
+			err = checkAdminMessageText(string(body))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		nbSent := BroadcastAdminMessage(gs, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"nb_clients": nbSent})
+	}
+}
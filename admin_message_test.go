@@ -0,0 +1,40 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCheckAdminMessageText(t *testing.T) {
+	assert.NoError(t, checkAdminMessageText("Match restarting in 2 minutes"))
+	assert.Error(t, checkAdminMessageText(""), "Empty text accepted")
+	assert.Error(t, checkAdminMessageText(" \t"), "Blank text accepted")
+	assert.NoError(t, checkAdminMessageText(
+		strings.Repeat("a", adminMessageMaxBytes)))
+	assert.Error(t, checkAdminMessageText(
+		strings.Repeat("a", adminMessageMaxBytes+1)), "Long text accepted")
+}
+
+func TestBroadcastAdminMessage(t *testing.T) {
+	newClient := func() *PlayerOrVisuClient {
+		return &PlayerOrVisuClient{
+			client:        &Client{},
+			adminMessages: make(chan MessageAdmin, adminMessageBufferSize),
+		}
+	}
+	player, visu := newClient(), newClient()
+	gs := &GlobalState{
+		Players: []*PlayerOrVisuClient{player},
+		Visus:   []*PlayerOrVisuClient{visu},
+	}
+
+	assert.Equal(t, 2, BroadcastAdminMessage(gs, "hello"))
+	for _, pvClient := range []*PlayerOrVisuClient{player, visu} {
+		if assert.Len(t, pvClient.adminMessages, 1, "ADMIN_MESSAGE not sent") {
+			msg := <-pvClient.adminMessages
+			assert.Equal(t, "ADMIN_MESSAGE", msg.MessageType)
+			assert.Equal(t, "hello", msg.Text)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	checkTimeout = 1000 * time.Millisecond
+)
+
+type CheckResult struct {
+	Role          string
+	Nickname      string
+	RemoteAddress string
+	Answered      bool
+	Latency       time.Duration
+}
+
+// Sends a PING to a client. Called by the coroutine that manages the client.
+// The client is sent on pongs when the matching PONG is received.
+func sendPing(client *Client, pongs chan *Client) error {
+	msg := MessagePing{
+		MessageType: "PING",
+	}
+
+	content, err := json.Marshal(msg)
+	if err == nil {
+		err = sendMessage(client, content)
+	}
+
+	if err == nil {
+		client.pendingPong = pongs
+	}
+	return err
+}
+
+// Called by the coroutine that manages the client when a PONG is received.
+func handlePong(client *Client) {
+	if client.pendingPong != nil {
+		client.pendingPong <- client
+		client.pendingPong = nil
+	}
+}
+
+// Answers the time synchronization PING of a client, sent at clientTime
+// and received at receivedAt, NTP-style: The client can estimate its clock
+// offset from the four times of the exchange.
+func sendTimeSyncPong(client *Client, clientTime int64,
+	receivedAt time.Time) error {
+	msg := MessagePong{
+		MessageType: "PONG",
+		ClientTime:  clientTime,
+		ReceiveTime: receivedAt.UnixNano() / int64(time.Millisecond),
+		SendTime:    time.Now().UnixNano() / int64(time.Millisecond),
+	}
+
+	content, err := json.Marshal(msg)
+	if err == nil {
+		err = sendMessage(client, content)
+	}
+	return err
+}
+
+// Sends a PING to all logged clients and waits for their PONG.
+// Clients that do not answer within checkTimeout are reported as such.
+func checkClients(gs *GlobalState) []CheckResult {
+	type checkedClient struct {
+		client *Client
+		role   string
+	}
+
+	LockGlobalStateMutex(gs, "Check clients", "Check")
+	clients := []checkedClient{}
+	for _, gl := range gs.GameLogic {
+		clients = append(clients, checkedClient{gl.client, "game logic"})
+	}
+	for _, player := range gs.Players {
+		clients = append(clients, checkedClient{player.client, "player"})
+	}
+	for _, splayer := range gs.SpecialPlayers {
+		clients = append(clients, checkedClient{splayer.client, "special player"})
+	}
+	for _, visu := range gs.Visus {
+		clients = append(clients, checkedClient{visu.client, "visualization"})
+	}
+	UnlockGlobalStateMutex(gs, "Check clients", "Check")
+
+	pongs := make(chan *Client, len(clients))
+	start := time.Now()
+	for _, c := range clients {
+		select {
+		case c.client.ping <- pongs:
+		default:
+		}
+	}
+
+	latencies := make(map[*Client]time.Duration)
+	timeout := time.After(checkTimeout)
+WaitPongs:
+	for len(latencies) < len(clients) {
+		select {
+		case client := <-pongs:
+			latencies[client] = time.Since(start)
+		case <-timeout:
+			break WaitPongs
+		}
+	}
+
+	results := []CheckResult{}
+	for _, c := range clients {
+		latency, answered := latencies[c.client]
+		results = append(results, CheckResult{
+			Role:          c.role,
+			Nickname:      c.client.nickname,
+			RemoteAddress: c.client.Conn.RemoteAddr().String(),
+			Answered:      answered,
+			Latency:       latency,
+		})
+	}
+	return results
+}
+
+// Prints the PING answers (or their absence) of the checked clients.
+func PrintCheckResults(results []CheckResult) {
+	nbAnswered := 0
+	for _, result := range results {
+		if result.Answered {
+			nbAnswered++
+		}
+	}
+
+	fmt.Printf("PING answered by %v/%v clients within %v ms\n",
+		nbAnswered, len(results), checkTimeout.Seconds()*1000)
+	for _, result := range results {
+		if result.Answered {
+			fmt.Printf("  %v %v (%v): %.3f ms\n", result.Role,
+				result.Nickname, result.RemoteAddress,
+				result.Latency.Seconds()*1000)
+		} else {
+			fmt.Printf("  %v %v (%v): no answer\n", result.Role,
+				result.Nickname, result.RemoteAddress)
+		}
+	}
+}
+
+// Starts the game if all clients answer a PING.
+// Only one check runs at a time (see autostart).
+func autostartAfterCheck(gs *GlobalState) {
+	results := checkClients(gs)
+
+	unanswered := []string{}
+	for _, result := range results {
+		if !result.Answered {
+			unanswered = append(unanswered, result.Nickname)
+		}
+	}
+
+	LockGlobalStateMutex(gs, "Autostart after check", "Check")
+	gs.autostartChecking = false
+	if len(unanswered) > 0 {
+		UnlockGlobalStateMutex(gs, "Autostart after check", "Check")
+		log.WithFields(log.Fields{
+			"clients": unanswered,
+		}).Warn("Automatic start cancelled: Some clients did not answer PING")
+		return
+	}
+
+	if gs.GameState == GAME_NOT_RUNNING && areAllExpectedClientsConnected(gs) {
+		log.Info("All clients answered PING")
+		gs.GameState = GAME_RUNNING
+		gs.GameLogic[0].start <- 1
+	}
+	UnlockGlobalStateMutex(gs, "Autostart after check", "Check")
+}
+
+// Waits (at most connectTimeout) for a single client to connect on port and
+// log in, then checks that it answers a PING within checkTimeout.
+// This allows to test a client (e.g. a player bot under development)
+// without running a game. Returns an error if the client does not connect
+// or does not log in properly.
+func CheckClient(port int, connectTimeout time.Duration) (CheckResult, error) {
+	var result CheckResult
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return result, err
+	}
+	defer listener.Close()
+
+	log.WithFields(log.Fields{
+		"port": port,
+	}).Info("Waiting for a client")
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(connectTimeout))
+	conn, err := listener.Accept()
+	if err != nil {
+		return result, fmt.Errorf("No client connected: %v", err.Error())
+	}
+	defer conn.Close()
+
+	client := newClient(conn)
+	go readClientMessages(client)
+	result.RemoteAddress = conn.RemoteAddr().String()
+
+	content, err := waitReplayMessage(client, "LOGIN")
+	if err != nil {
+		return result, err
+	}
+	login, err := readLoginMessage(content)
+	if err != nil {
+		Kick(client, KICK_INVALID_LOGIN, err.Error())
+		return result, fmt.Errorf("Invalid LOGIN. %v", err.Error())
+	}
+	client.nickname = login.nickname
+	result.Role = login.role
+	result.Nickname = login.nickname
+	if err = sendLoginACK(client, negotiateCapabilities(login)); err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	if err = sendPing(client, nil); err != nil {
+		return result, err
+	}
+	select {
+	case msg := <-client.incomingMessages:
+		if msg.err != nil {
+			return result, fmt.Errorf("Cannot read PONG. %v", msg.err.Error())
+		}
+		messageType, _ := ReadString(msg.content, "message_type")
+		if messageType != "PONG" {
+			Kick(client, KICK_PROTOCOL_ERROR, "Expected PONG")
+			return result, fmt.Errorf("Received %v instead of PONG",
+				messageType)
+		}
+		result.Answered = true
+		result.Latency = time.Since(start)
+	case <-time.After(checkTimeout):
+	}
+
+	Kick(client, KICK_GAME_FINISHED, "Client check is finished")
+	return result, nil
+}
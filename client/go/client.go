@@ -2,18 +2,31 @@ package client
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"strconv"
 )
 
+// Flags of v2 frames
+const (
+	FrameFlagCompressed byte = 0x01
+	FrameFlagChunked    byte = 0x08
+)
+
+var errNotConnected = fmt.Errorf("Not connected")
+
 type Client struct {
 	conn   net.Conn
 	reader *bufio.Reader
 	writer *bufio.Writer
+	// Frame format of the messages (1 unless set to 2 after LOGIN_ACK)
+	framing int
 }
 
 func (c *Client) Connect(hostname string, port int) error {
@@ -30,18 +43,66 @@ func (c *Client) Connect(hostname string, port int) error {
 	return nil
 }
 
+// Uses an already established connection (e.g. an in-process loopback
+// connection) instead of connecting via TCP.
+func (c *Client) ConnectConn(conn net.Conn) {
+	c.conn = conn
+	c.reader = bufio.NewReader(c.conn)
+	c.writer = bufio.NewWriter(c.conn)
+}
+
 func (c *Client) Disconnect() error {
 	c.reader = nil
 	c.writer = nil
+	if c.conn == nil {
+		return errNotConnected
+	}
 	return c.conn.Close()
 }
 
+// Sets the frame format of the next messages (1 or 2).
+// The frame format 2 must have been asked in LOGIN (framing field),
+// it is used once LOGIN_ACK is received.
+func (c *Client) SetFraming(framing int) {
+	c.framing = framing
+}
+
+// Sends a v2 frame with custom flags, e.g. a compressed payload or a chunk
+// of a message.
+func (c *Client) SendFrame(payload []byte, flags byte) error {
+	if c.writer == nil {
+		return errNotConnected
+	}
+
+	header := make([]byte, binary.MaxVarintLen64+1)
+	headerSize := binary.PutUvarint(header, uint64(len(payload)))
+	header[headerSize] = flags
+	_, err := c.writer.Write(header[:headerSize+1])
+	if err == nil {
+		_, err = c.writer.Write(payload)
+	}
+	if err != nil {
+		return fmt.Errorf("Remote endpoint closed? Write error: %v", err)
+	}
+
+	// Flush socket
+	c.writer.Flush()
+	return nil
+}
+
 func (c *Client) SendBytes(content []byte, checkSize bool) error {
 	contentSize := len(content)
 	if checkSize && contentSize >= 16777215 {
 		return fmt.Errorf("content too big: size does not fit in 24 bits")
 	}
 
+	if c.framing == 2 {
+		return c.SendFrame(content, 0)
+	}
+	if c.writer == nil {
+		return errNotConnected
+	}
+
 	// Write content size on socket
 	var contentSizeUint32 uint32 = uint32(contentSize) + 1 // +1 for \n
 	contentSizeBuf := make([]byte, 4)
@@ -94,10 +155,34 @@ func (c *Client) SendLogin(role, nickname, metaprotocolVersion string) error {
 
 func (c *Client) ReadMessage() (map[string]interface{}, error) {
 	var msg map[string]interface{}
+	var contentBuf []byte
+	var err error
+	if c.reader == nil {
+		return msg, errNotConnected
+	}
+	if c.framing == 2 {
+		contentBuf, err = c.readFramesV2()
+	} else {
+		contentBuf, err = c.readFrameV1()
+	}
+	if err != nil {
+		return msg, err
+	}
+
+	// Read message content
+	err = json.Unmarshal(contentBuf, &msg)
+	if err != nil {
+		return msg, fmt.Errorf("Non-JSON message received")
+	} else {
+		return msg, nil
+	}
+}
+
+func (c *Client) readFrameV1() ([]byte, error) {
 	contentSizeBuf := make([]byte, 4)
 	_, err := io.ReadFull(c.reader, contentSizeBuf)
 	if err != nil {
-		return msg, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+		return nil, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
 	}
 
 	// Read message content size
@@ -107,14 +192,42 @@ func (c *Client) ReadMessage() (map[string]interface{}, error) {
 	contentBuf := make([]byte, contentSize)
 	_, err = io.ReadFull(c.reader, contentBuf)
 	if err != nil {
-		return msg, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+		return nil, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
 	}
+	return contentBuf, nil
+}
 
-	// Read message content
-	err = json.Unmarshal(contentBuf, &msg)
-	if err != nil {
-		return msg, fmt.Errorf("Non-JSON message received")
-	} else {
-		return msg, nil
+// Reads the v2 frames of a message (several if it is chunked).
+func (c *Client) readFramesV2() ([]byte, error) {
+	var content []byte
+	for {
+		payloadSize, err := binary.ReadUvarint(c.reader)
+		if err != nil {
+			return nil, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+		}
+		flags, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+		}
+
+		payload := make([]byte, payloadSize)
+		_, err = io.ReadFull(c.reader, payload)
+		if err != nil {
+			return nil, fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+		}
+
+		if flags&FrameFlagCompressed != 0 {
+			reader := flate.NewReader(bytes.NewReader(payload))
+			payload, err = ioutil.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("Cannot decompress frame: %v", err)
+			}
+		}
+
+		content = append(content, payload...)
+		if flags&FrameFlagChunked == 0 {
+			return content, nil
+		}
 	}
 }
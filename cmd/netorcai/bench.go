@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	docopt "github.com/docopt/docopt-go"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the arguments of the game server run by bench, and its number of
+// loopback players. The game is run without timers (--fast) and starts as
+// soon as the game logic and the players are logged in.
+func benchServeArguments(arguments map[string]interface{}, port int) (
+	map[string]interface{}, int, error) {
+	nbPlayers, err := netorcai.ReadIntInString(arguments, "--nb-players",
+		64, 1, 1024)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	serveArgs := []string{
+		fmt.Sprintf("--port=%v", port),
+		fmt.Sprintf("--nb-players-max=%v", nbPlayers),
+		"--nb-splayers-max=0",
+		"--nb-visus-max=0",
+		fmt.Sprintf("--nb-turns-max=%v", arguments["--nb-turns-max"]),
+		"--delay-first-turn=50",
+		"--fast",
+		"--autostart",
+	}
+	for _, option := range []string{"--lua-gl", "--wasm-gl", "--connect-gl"} {
+		if arguments[option] != nil {
+			serveArgs = append(serveArgs,
+				fmt.Sprintf("%v=%v", option, arguments[option]))
+		}
+	}
+
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+	serveArguments, err := parser.ParseArgs(serveUsage, serveArgs, "")
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+	return serveArguments, nbPlayers, nil
+}
+
+// Runs an in-process player (see netorcai.ConnectLoopbackClient), that
+// answers each TURN right away without any action.
+func runLoopbackPlayer(gs *netorcai.GlobalState, gameLogicExit chan int,
+	nickname string) {
+	player := &client.Client{}
+	player.ConnectConn(netorcai.ConnectLoopbackClient(gs, gameLogicExit))
+	defer player.Disconnect()
+
+	err := player.SendLogin("player", nickname, netorcai.Version)
+	for err == nil {
+		var msg map[string]interface{}
+		msg, err = player.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		switch msg["message_type"] {
+		case "TURN":
+			var turnNumber int
+			turnNumber, err = netorcai.ReadInt(msg, "turn_number")
+			if err == nil {
+				err = player.SendJSON(map[string]interface{}{
+					"message_type": "TURN_ACK",
+					"turn_number":  turnNumber,
+					"actions":      []interface{}{},
+				})
+			}
+		case "PING":
+			err = player.SendJSON(map[string]interface{}{
+				"message_type": "PONG",
+			})
+		case "GAME_ENDS", "KICK":
+			return
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"nickname": nickname,
+		"err":      err,
+	}).Warn("Loopback player failed")
+}
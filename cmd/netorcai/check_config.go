@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netorcai/netorcai"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"time"
+)
+
+// The configuration of a game server, as printed by --check-config.
+type effectiveConfig struct {
+	netorcai.ServerConfig
+	Port               int      `json:"port"`
+	AdminPort          int      `json:"admin_port,omitempty"`
+	ControlPort        int      `json:"control_port,omitempty"`
+	ControlAddress     string   `json:"control_address,omitempty"`
+	GameLogic          string   `json:"game_logic"`
+	ConnectPlayers     []string `json:"connect_players,omitempty"`
+	Autostart          bool     `json:"autostart"`
+	AutostartCheck     bool     `json:"autostart_check"`
+	Countdown          int      `json:"countdown_seconds"`
+	StalePlayers       string   `json:"stale_players"`
+	TurnHistory        int      `json:"turn_history"`
+	TurnRatePlayers    float64  `json:"max_turn_rate_players"`
+	TurnRateSPlayers   float64  `json:"max_turn_rate_splayers"`
+	TurnRateVisus      float64  `json:"max_turn_rate_visus"`
+	ActionOrder        string   `json:"action_order"`
+	SkipIdleTurns      bool     `json:"skip_idle_turns"`
+	MaxStateBytes      int      `json:"max_state_bytes"`
+	StateSizePolicy    string   `json:"state_size_policy"`
+	MaxArrayLength     int      `json:"max_array_length"`
+	JSONBackend        string   `json:"json_backend"`
+	Watchdog           float64  `json:"milliseconds_watchdog"`
+	WatchdogAction     string   `json:"watchdog_action"`
+	DumpStates         string   `json:"dump_states,omitempty"`
+	Seed               *int64   `json:"seed,omitempty"`
+	Signed             bool     `json:"signed"`
+	Accounts           bool     `json:"accounts"`
+	DuplicateLogin     string   `json:"duplicate_login"`
+	HookCommand        string   `json:"hook_command,omitempty"`
+	GameLogicHotSwap   bool     `json:"gl_hot_swap"`
+	NbAcceptors        int      `json:"acceptors"`
+	ReusePort          bool     `json:"reuse_port"`
+	MaxPendingLogins   int      `json:"max_pending_logins"`
+	NbBroadcastWorkers int      `json:"broadcast_workers"`
+}
+
+// Returns the options that have no effect because of the other ones.
+// They are errors for --check-config, and warnings otherwise.
+func optionConflicts(arguments map[string]interface{}) []string {
+	conflicts := []string{}
+	if arguments["--autostart-check"] == true && arguments["--autostart"] != true {
+		conflicts = append(conflicts,
+			"--autostart-check has no effect without --autostart")
+	}
+	if arguments["--adaptive-delay"] == true && arguments["--fast"] == true {
+		conflicts = append(conflicts,
+			"--adaptive-delay has no effect with --fast")
+	}
+	if arguments["--watchdog-action"] == "advance" && arguments["--fast"] != true {
+		conflicts = append(conflicts,
+			"--watchdog-action=advance has no effect without --fast")
+	}
+	if arguments["--log-state-diffs"] == true && arguments["--debug"] != true {
+		conflicts = append(conflicts,
+			"--log-state-diffs has no effect without --debug")
+	}
+	if arguments["--signing-key"] != nil && arguments["--dump-states"] == nil {
+		conflicts = append(conflicts,
+			"--signing-key has no effect without --dump-states")
+	}
+	if arguments["--duplicate-login"] == "replace" && arguments["--accounts"] == nil {
+		conflicts = append(conflicts,
+			"--duplicate-login=replace has no effect without --accounts")
+	}
+	if arguments["--control-token-file"] != nil && arguments["--control-port"] == nil {
+		conflicts = append(conflicts,
+			"--control-token-file has no effect without --control-port")
+	}
+	return conflicts
+}
+
+// Checks the options that are only read when the server runs
+// (embedded game logic, remote clients and message tracing).
+func checkRuntimeOptions(arguments map[string]interface{}) error {
+	_, err := netorcai.ReadIntInString(arguments, "--trace-payload-max", 64,
+		0, 16777216)
+	if err != nil {
+		return err
+	}
+
+	for _, option := range []string{"--lua-gl", "--wasm-gl"} {
+		if arguments[option] != nil {
+			if _, err = os.Stat(arguments[option].(string)); err != nil {
+				return fmt.Errorf("Bad %v: %v", option, err.Error())
+			}
+		}
+	}
+	if arguments["--lua-gl"] != nil {
+		_, err = netorcai.ReadIntInString(arguments, "--lua-turn-timeout",
+			64, 1, 3600000)
+		if err != nil {
+			return err
+		}
+	}
+	if arguments["--wasm-gl"] != nil {
+		_, err = netorcai.ReadIntInString(arguments, "--wasm-memory-max", 64,
+			1, 4096)
+		if err != nil {
+			return err
+		}
+		_, err = netorcai.ReadIntInString(arguments, "--wasm-turn-timeout",
+			64, 1, 3600000)
+		if err != nil {
+			return err
+		}
+	}
+
+	addresses := arguments["--connect-player"].([]string)
+	if arguments["--connect-gl"] != nil {
+		addresses = append(addresses, arguments["--connect-gl"].(string))
+	}
+	for _, address := range addresses {
+		if _, _, err = net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("Bad address %v: %v", address, err.Error())
+		}
+	}
+	return nil
+}
+
+// Describes how the game logic is run.
+func gameLogicOf(arguments map[string]interface{}) string {
+	switch {
+	case arguments["--lua-gl"] != nil:
+		return "lua:" + arguments["--lua-gl"].(string)
+	case arguments["--wasm-gl"] != nil:
+		return "wasm:" + arguments["--wasm-gl"].(string)
+	case arguments["--connect-gl"] != nil:
+		return "connect:" + arguments["--connect-gl"].(string)
+	}
+	return "login"
+}
+
+// Returns the maximum number of TURN per second (0: unlimited).
+func turnRate(minTurnInterval time.Duration) float64 {
+	if minTurnInterval == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(minTurnInterval)
+}
+
+// Validates the options of the server without running it (--check-config),
+// and prints its effective configuration.
+func runCheckConfig(arguments map[string]interface{}, gs *netorcai.GlobalState,
+	port, adminPort, controlPort int) int {
+	ret := 0
+	if err := checkRuntimeOptions(arguments); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		ret = 1
+	}
+	for _, conflict := range optionConflicts(arguments) {
+		log.WithFields(log.Fields{
+			"err": conflict,
+		}).Error("Conflicting arguments")
+		ret = 1
+	}
+
+	config := effectiveConfig{
+		ServerConfig: netorcai.ServerConfig{
+			NbPlayersMax:         gs.NbPlayersMax,
+			NbSpecialPlayersMax:  gs.NbSpecialPlayersMax,
+			NbVisusMax:           gs.NbVisusMax,
+			NbTurnsMax:           gs.NbTurnsMax,
+			DelayFirstTurn:       gs.MillisecondsBeforeFirstTurn,
+			DelayTurns:           gs.MillisecondsBetweenTurns,
+			DelayTurnsMin:        gs.MillisecondsBetweenTurnsMin,
+			AdaptiveDelay:        gs.AdaptiveDelay,
+			GameStartsAckTimeout: gs.MillisecondsGameStartsAckTimeout,
+			Fast:                 gs.Fast,
+			PublicVisuDelay:      gs.PublicVisuDelay,
+			EchoActionsToVisus:   gs.EchoActionsToVisus,
+			AnonymizePlayers:     gs.AnonymizePlayers,
+		},
+		Port:               port,
+		AdminPort:          adminPort,
+		ControlPort:        controlPort,
+		GameLogic:          gameLogicOf(arguments),
+		ConnectPlayers:     arguments["--connect-player"].([]string),
+		Autostart:          gs.Autostart,
+		AutostartCheck:     gs.AutostartCheck,
+		Countdown:          gs.Countdown,
+		StalePlayers:       arguments["--stale-players"].(string),
+		TurnHistory:        gs.TurnHistory,
+		TurnRatePlayers:    turnRate(gs.MinTurnIntervalPlayers),
+		TurnRateSPlayers:   turnRate(gs.MinTurnIntervalSpecialPlayers),
+		TurnRateVisus:      turnRate(gs.MinTurnIntervalVisus),
+		ActionOrder:        arguments["--action-order"].(string),
+		SkipIdleTurns:      gs.SkipIdleTurns,
+		MaxStateBytes:      gs.MaxStateBytes,
+		StateSizePolicy:    arguments["--state-size-policy"].(string),
+		MaxArrayLength:     gs.MaxArrayLength,
+		JSONBackend:        arguments["--json-backend"].(string),
+		Watchdog:           gs.WatchdogTimeout.Seconds() * 1000,
+		WatchdogAction:     arguments["--watchdog-action"].(string),
+		DumpStates:         gs.DumpStatesDirectory,
+		Seed:               gs.Seed,
+		Signed:             gs.SigningKey != nil,
+		Accounts:           gs.Accounts != nil,
+		DuplicateLogin:     arguments["--duplicate-login"].(string),
+		HookCommand:        gs.HookCommand,
+		GameLogicHotSwap:   gs.GameLogicHotSwap,
+		NbAcceptors:        gs.NbAcceptors,
+		ReusePort:          gs.ReusePort,
+		MaxPendingLogins:   gs.MaxPendingLogins,
+		NbBroadcastWorkers: gs.NbBroadcastWorkers,
+	}
+	if controlPort != 0 {
+		config.ControlAddress = arguments["--control-address"].(string)
+	}
+	content, _ := json.MarshalIndent(config, "", "  ")
+	fmt.Println(string(content))
+	return ret
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh/terminal"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	doctorOK   = "OK"
+	doctorWarn = "WARN"
+	doctorFail = "FAIL"
+)
+
+var errNoFileDescriptorLimit = errors.New("no file descriptor limit")
+
+type doctorDiagnostic struct {
+	status  string
+	message string
+	hint    string
+}
+
+// Checks that the runtime environment is suitable for netorcai,
+// then prints the diagnostics. Returns 1 if a check failed, 0 otherwise.
+func runDoctor(port int) int {
+	diagnostics := []doctorDiagnostic{
+		doctorCheckPort(port),
+		doctorCheckFileDescriptors(),
+		doctorCheckTerminal(),
+		doctorCheckClockResolution(),
+	}
+
+	ret := 0
+	for _, d := range diagnostics {
+		fmt.Printf("[%v] %v\n", d.status, d.message)
+		if d.hint != "" {
+			fmt.Printf("       %v\n", d.hint)
+		}
+		if d.status == doctorFail {
+			ret = 1
+		}
+	}
+	return ret
+}
+
+func doctorCheckPort(port int) doctorDiagnostic {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return doctorDiagnostic{doctorFail,
+			fmt.Sprintf("TCP port %v is not available: %v", port, err),
+			"Stop the program that uses it or choose another one with --port."}
+	}
+	listener.Close()
+
+	return doctorDiagnostic{doctorOK,
+		fmt.Sprintf("TCP port %v is available", port), ""}
+}
+
+func doctorCheckFileDescriptors() doctorDiagnostic {
+	// One descriptor per client, with all client kinds at their maximum.
+	const wanted = 4096
+
+	limit, err := fileDescriptorLimit()
+	if err == errNoFileDescriptorLimit {
+		return doctorDiagnostic{doctorOK,
+			"No file descriptor limit on this platform", ""}
+	} else if err != nil {
+		return doctorDiagnostic{doctorWarn,
+			fmt.Sprintf("Cannot read file descriptor limit: %v", err), ""}
+	}
+
+	if limit < wanted {
+		return doctorDiagnostic{doctorWarn,
+			fmt.Sprintf("File descriptor limit is %v: "+
+				"large games may not be able to accept all clients", limit),
+			fmt.Sprintf("Increase it (e.g. ulimit -n %v).", wanted)}
+	}
+
+	return doctorDiagnostic{doctorOK,
+		fmt.Sprintf("File descriptor limit is %v", limit), ""}
+}
+
+func doctorCheckTerminal() doctorDiagnostic {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) ||
+		!terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return doctorDiagnostic{doctorWarn,
+			"Standard input/output is not a terminal: " +
+				"the simple prompt will be used", ""}
+	}
+
+	if os.Getenv("TERM") == "" || os.Getenv("TERM") == "dumb" {
+		return doctorDiagnostic{doctorWarn,
+			"TERM is not set to a capable terminal: " +
+				"the interactive prompt may not render correctly",
+			"Set TERM (e.g. xterm-256color) or use --simple-prompt."}
+	}
+
+	return doctorDiagnostic{doctorOK,
+		"Terminal supports the interactive prompt", ""}
+}
+
+func doctorCheckClockResolution() doctorDiagnostic {
+	// Measure how long a 1 ms sleep really lasts.
+	const nbSamples = 20
+	samples := make([]time.Duration, nbSamples)
+	for i := range samples {
+		start := time.Now()
+		time.Sleep(time.Millisecond)
+		samples[i] = time.Since(start)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+	median := samples[nbSamples/2]
+	medianMS := median.Seconds() * 1000
+
+	if median > 5*time.Millisecond {
+		return doctorDiagnostic{doctorWarn,
+			fmt.Sprintf("Timer resolution is coarse: "+
+				"a 1 ms sleep lasts %.3f ms", medianMS),
+			"Turn delays may be significantly longer than requested."}
+	}
+
+	return doctorDiagnostic{doctorOK,
+		fmt.Sprintf("Timer resolution is fine: "+
+			"a 1 ms sleep lasts %.3f ms", medianMS), ""}
+}
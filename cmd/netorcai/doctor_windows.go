@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package main
+
+// Windows has no per-process limit on the number of sockets.
+func fileDescriptorLimit() (uint64, error) {
+	return 0, errNoFileDescriptorLimit
+}
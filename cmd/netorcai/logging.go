@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prints each log entry on a single short line: time, level, message then
+// the sorted fields. Levels are colorized if colors is set.
+type compactFormatter struct {
+	colors bool
+}
+
+func (f *compactFormatter) Format(entry *log.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	level := strings.ToUpper(entry.Level.String())[:4]
+	if f.colors {
+		level = fmt.Sprintf("\x1b[%dm%v\x1b[0m", levelColor(entry.Level),
+			level)
+	}
+	fmt.Fprintf(&b, "%v %v %v", entry.Time.Format("15:04:05.000"), level,
+		entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fmt.Sprint(entry.Data[key])
+		if strings.ContainsAny(value, " \t\"=") || value == "" {
+			value = fmt.Sprintf("%q", value)
+		}
+		if strings.Contains(key, " ") {
+			key = fmt.Sprintf("%q", key)
+		}
+		fmt.Fprintf(&b, " %v=%v", key, value)
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// The ANSI color of a log level, as logrus uses them.
+func levelColor(level log.Level) int {
+	switch level {
+	case log.DebugLevel, log.TraceLevel:
+		return 37 // gray
+	case log.WarnLevel:
+		return 33 // yellow
+	case log.ErrorLevel, log.FatalLevel, log.PanicLevel:
+		return 31 // red
+	default:
+		return 36 // blue
+	}
+}
+
+const (
+	// The period during which identical warnings and errors are counted
+	logRepeatsPeriod = 10 * time.Second
+	// Logged at the end of a period if some messages have been dropped
+	logRepeatsMessage = "Message repeated"
+)
+
+// Identical warnings and errors (same level and message) logged during the
+// current period.
+type logRepeats struct {
+	level     log.Level
+	message   string
+	start     time.Time
+	count     int
+	nbDropped int
+}
+
+// Wraps a formatter to drop the warnings and errors that are repeated more
+// than repeatsMax times during a period. Once the period is over, how many
+// times they have been repeated is logged instead.
+type dedupFormatter struct {
+	formatter  log.Formatter
+	repeatsMax int
+	mutex      sync.Mutex
+	repeats    map[string]*logRepeats
+}
+
+func newDedupFormatter(formatter log.Formatter,
+	repeatsMax int) *dedupFormatter {
+	return &dedupFormatter{
+		formatter:  formatter,
+		repeatsMax: repeatsMax,
+		repeats:    make(map[string]*logRepeats),
+	}
+}
+
+func (f *dedupFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if entry.Level > log.WarnLevel || entry.Message == logRepeatsMessage {
+		return f.formatter.Format(entry)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := entry.Level.String() + "|" + entry.Message
+	r, exists := f.repeats[key]
+	var summary []byte
+	if exists && entry.Time.Sub(r.start) >= logRepeatsPeriod {
+		if r.nbDropped > 0 {
+			summary, _ = f.formatter.Format(repeatsEntry(entry.Logger, r,
+				entry.Time))
+		}
+		exists = false
+	}
+	if !exists {
+		r = &logRepeats{
+			level:   entry.Level,
+			message: entry.Message,
+			start:   entry.Time,
+		}
+		f.repeats[key] = r
+	}
+
+	r.count++
+	if r.count > f.repeatsMax {
+		r.nbDropped++
+		return summary, nil
+	}
+	formatted, err := f.formatter.Format(entry)
+	return append(summary, formatted...), err
+}
+
+// Returns the summaries of the periods that are over, and forgets them.
+func (f *dedupFormatter) endedPeriods(now time.Time,
+	all bool) []*logRepeats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	ended := []*logRepeats{}
+	for key, r := range f.repeats {
+		if all || now.Sub(r.start) >= logRepeatsPeriod {
+			if r.nbDropped > 0 {
+				ended = append(ended, r)
+			}
+			delete(f.repeats, key)
+		}
+	}
+	return ended
+}
+
+// Logs how many times messages have been repeated, for the periods that
+// are over (or for all of them).
+func (f *dedupFormatter) flush(all bool) {
+	for _, r := range f.endedPeriods(time.Now(), all) {
+		entry := repeatsEntry(log.StandardLogger(), r, time.Now())
+		if r.level <= log.ErrorLevel {
+			entry.Error(logRepeatsMessage)
+		} else {
+			entry.Warn(logRepeatsMessage)
+		}
+	}
+}
+
+// Regularly logs how many times messages have been repeated.
+func (f *dedupFormatter) run() {
+	for range time.Tick(logRepeatsPeriod) {
+		f.flush(false)
+	}
+}
+
+func repeatsEntry(logger *log.Logger, r *logRepeats,
+	now time.Time) *log.Entry {
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"message": r.message,
+		"times":   r.count,
+		"dropped": r.nbDropped,
+	})
+	entry.Time = now
+	entry.Level = r.level
+	entry.Message = logRepeatsMessage
+	return entry
+}
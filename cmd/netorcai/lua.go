@@ -0,0 +1,240 @@
+//go:build lua
+// +build lua
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/netorcai/netorcai/gamelogic"
+	lua "github.com/yuin/gopher-lua"
+	"time"
+)
+
+const luaSupported = true
+
+// Game logic implemented by a Lua script, that defines the
+// init(nb_players, nb_special_players, nb_turns_max) function (which returns
+// the initial game state) and the turn(actions) function (which returns the
+// new game state and the winner player ID, or nil if there is no winner).
+// Lua errors, and calls that do not return in time, make the game logic fail.
+// Game states are converted to JSON as described in luaToGo.
+type luaGame struct {
+	L           *lua.LState
+	turnTimeout time.Duration
+}
+
+// The metatable of the tables marked by json_array
+const luaArrayMarker = "__json_array"
+
+func newLuaGame(filename string, turnTimeout time.Duration) (gamelogic.Game,
+	error) {
+	L := lua.NewState()
+	L.SetGlobal("json_array", L.NewFunction(luaJSONArray))
+	err := L.DoFile(filename)
+	if err != nil {
+		L.Close()
+		return nil, fmt.Errorf("Cannot load Lua game logic: %v", err)
+	}
+
+	for _, function := range []string{"init", "turn"} {
+		if L.GetGlobal(function).Type() != lua.LTFunction {
+			L.Close()
+			return nil, fmt.Errorf("Lua game logic does not define the "+
+				"%v function", function)
+		}
+	}
+
+	return &luaGame{L: L, turnTimeout: turnTimeout}, nil
+}
+
+// json_array(t) marks the table t as a JSON array (even if it is empty),
+// and returns it.
+func luaJSONArray(L *lua.LState) int {
+	table := L.CheckTable(1)
+	metatable := L.NewTable()
+	metatable.RawSetString(luaArrayMarker, lua.LTrue)
+	L.SetMetatable(table, metatable)
+	L.Push(table)
+	return 1
+}
+
+func (g *luaGame) Init(nbPlayers, nbSpecialPlayers,
+	nbTurnsMax int) gamelogic.State {
+	values := g.call("init", 1, lua.LNumber(nbPlayers),
+		lua.LNumber(nbSpecialPlayers), lua.LNumber(nbTurnsMax))
+	return luaToState("init", values[0])
+}
+
+func (g *luaGame) Turn(actions []gamelogic.PlayerActions) (gamelogic.State,
+	gamelogic.Winner) {
+	luaActions := g.L.NewTable()
+	for _, action := range actions {
+		luaAction := g.L.NewTable()
+		luaAction.RawSetString("player_id", lua.LNumber(action.PlayerID))
+		luaAction.RawSetString("role", lua.LString(action.Role))
+		luaAction.RawSetString("turn_number", lua.LNumber(action.TurnNumber))
+		luaAction.RawSetString("actions", goToLua(g.L, action.Actions))
+		luaActions.Append(luaAction)
+	}
+
+	values := g.call("turn", 2, luaActions)
+	winner := gamelogic.NoWinner
+	if number, isNumber := values[1].(lua.LNumber); isNumber {
+		winner = gamelogic.Winner(number)
+	}
+	return luaToState("turn", values[0]), winner
+}
+
+// Calls a global Lua function and returns its nbRet first return values.
+// Panics on Lua errors, or if the function does not return within
+// turnTimeout (the game logic then fails).
+func (g *luaGame) call(function string, nbRet int,
+	args ...lua.LValue) []lua.LValue {
+	ctx, cancel := context.WithTimeout(context.Background(), g.turnTimeout)
+	defer cancel()
+	g.L.SetContext(ctx)
+	defer g.L.RemoveContext()
+
+	err := g.L.CallByParam(lua.P{
+		Fn:      g.L.GetGlobal(function),
+		NRet:    nbRet,
+		Protect: true,
+	}, args...)
+	if err != nil {
+		panic(fmt.Sprintf("Lua %v failed: %v", function, err))
+	}
+
+	values := make([]lua.LValue, nbRet)
+	for i := 0; i < nbRet; i++ {
+		values[i] = g.L.Get(i - nbRet)
+	}
+	g.L.Pop(nbRet)
+	return values
+}
+
+func luaToState(function string, value lua.LValue) gamelogic.State {
+	converted, err := luaToGo(value, make(map[*lua.LTable]bool))
+	if err != nil {
+		panic(fmt.Sprintf("Lua %v returned an invalid game state: %v",
+			function, err))
+	}
+	state, isObject := converted.(map[string]interface{})
+	if !isObject {
+		panic(fmt.Sprintf("Lua %v did not return a game state table "+
+			"(with string keys)", function))
+	}
+	return gamelogic.State(state)
+}
+
+// Converts a JSON-like Go value to Lua.
+func goToLua(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case int:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		table := L.NewTable()
+		for _, element := range v {
+			table.Append(goToLua(L, element))
+		}
+		return table
+	case map[string]interface{}:
+		table := L.NewTable()
+		for key, element := range v {
+			table.RawSetString(key, goToLua(L, element))
+		}
+		return table
+	default:
+		return lua.LNil
+	}
+}
+
+// Converts a Lua value to a JSON-like Go value:
+//   - Tables whose keys are exactly 1..n (n > 0) are arrays, as well as
+//     tables marked by json_array (which must not have other keys).
+//   - Other tables (including empty ones) are objects. Their keys must be
+//     strings or numbers, which are converted to strings as by tostring.
+//   - Functions and other values that JSON cannot represent are null.
+//
+// Tables that contain themselves cannot be converted. visited holds the
+// tables being converted.
+func luaToGo(value lua.LValue, visited map[*lua.LTable]bool) (interface{},
+	error) {
+	switch v := value.(type) {
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if visited[v] {
+			return nil, fmt.Errorf("Table contains itself")
+		}
+		visited[v] = true
+		defer delete(visited, v)
+
+		isArray, err := isLuaArray(v)
+		if err != nil {
+			return nil, err
+		}
+		if isArray {
+			array := make([]interface{}, 0, v.Len())
+			for i := 1; i <= v.Len(); i++ {
+				element, err := luaToGo(v.RawGetInt(i), visited)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, element)
+			}
+			return array, nil
+		}
+
+		object := make(map[string]interface{})
+		v.ForEach(func(key, element lua.LValue) {
+			if err != nil {
+				return
+			}
+			switch key.(type) {
+			case lua.LString, lua.LNumber:
+			default:
+				err = fmt.Errorf("Table key %v is neither a string nor "+
+					"a number", key)
+				return
+			}
+			object[key.String()], err = luaToGo(element, visited)
+		})
+		return object, err
+	default:
+		return nil, nil
+	}
+}
+
+// Returns whether a table is a JSON array (see luaToGo).
+func isLuaArray(table *lua.LTable) (bool, error) {
+	marked := false
+	if metatable, isTable := table.Metatable.(*lua.LTable); isTable {
+		marked = metatable.RawGetString(luaArrayMarker) == lua.LTrue
+	}
+
+	nbKeys := 0
+	table.ForEach(func(key, element lua.LValue) {
+		nbKeys++
+	})
+	isSequence := nbKeys == table.Len()
+	for i := 1; isSequence && i <= nbKeys; i++ {
+		isSequence = table.RawGetInt(i) != lua.LNil
+	}
+
+	if marked && !isSequence {
+		return false, fmt.Errorf("Table marked by json_array has other " +
+			"keys than 1..n")
+	}
+	return isSequence && (marked || nbKeys > 0), nil
+}
@@ -0,0 +1,18 @@
+//go:build !lua
+// +build !lua
+
+package main
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai/gamelogic"
+	"time"
+)
+
+const luaSupported = false
+
+func newLuaGame(filename string, turnTimeout time.Duration) (gamelogic.Game,
+	error) {
+	return nil, fmt.Errorf("netorcai has been built without Lua support " +
+		"(build it with -tags lua)")
+}
@@ -0,0 +1,82 @@
+//go:build lua
+// +build lua
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	lua "github.com/yuin/gopher-lua"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Evaluates a Lua expression and converts its value to Go.
+func luaExpressionToGo(t *testing.T, expression string) (interface{}, error) {
+	L := lua.NewState()
+	defer L.Close()
+	L.SetGlobal("json_array", L.NewFunction(luaJSONArray))
+	err := L.DoString("value = " + expression)
+	assert.NoError(t, err, "Invalid Lua expression %v", expression)
+	return luaToGo(L.GetGlobal("value"), make(map[*lua.LTable]bool))
+}
+
+func TestLuaToGo(t *testing.T) {
+	for expression, expected := range map[string]interface{}{
+		`{1, "a", true}`:          []interface{}{1.0, "a", true},
+		`{}`:                      map[string]interface{}{},
+		`json_array({})`:          []interface{}{},
+		`{x = 1, y = {}}`:         map[string]interface{}{"x": 1.0, "y": map[string]interface{}{}},
+		`{1, 2, x = 3}`:           map[string]interface{}{"1": 1.0, "2": 2.0, "x": 3.0},
+		`{[1] = "a", [3] = "c"}`:  map[string]interface{}{"1": "a", "3": "c"},
+		`{[2] = "b"}`:             map[string]interface{}{"2": "b"},
+		`{{}, json_array({1})}`:   []interface{}{map[string]interface{}{}, []interface{}{1.0}},
+		`{f = print, s = "text"}`: map[string]interface{}{"f": nil, "s": "text"},
+		`(function() local shared = {1}; return {shared, shared} end)()`: []interface{}{
+			[]interface{}{1.0}, []interface{}{1.0}},
+	} {
+		value, err := luaExpressionToGo(t, expression)
+		assert.NoError(t, err, "Cannot convert %v", expression)
+		assert.Equal(t, expected, value, "Unexpected conversion of %v",
+			expression)
+	}
+
+	for _, expression := range []string{
+		`(function() local t = {}; t.self = t; return t end)()`,
+		`(function() local t = {}; t[1] = {t}; return t end)()`,
+		`{[true] = 1}`,
+		`json_array({1, x = 2})`,
+	} {
+		_, err := luaExpressionToGo(t, expression)
+		assert.Error(t, err, "%v should not be converted", expression)
+	}
+}
+
+func TestLuaGameTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-lua")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "game.lua")
+	err = ioutil.WriteFile(filename, []byte(`
+function init(nb_players, nb_special_players, nb_turns_max)
+	return {units = json_array({})}
+end
+function turn(actions)
+	while true do end
+end
+`), 0600)
+	assert.NoError(t, err, "Cannot write Lua game logic")
+
+	game, err := newLuaGame(filename, 100*time.Millisecond)
+	if !assert.NoError(t, err, "Cannot load Lua game logic") {
+		return
+	}
+	state := game.Init(2, 0, 10)
+	assert.Equal(t, []interface{}{}, state["units"], "Unexpected game state")
+
+	start := time.Now()
+	assert.Panics(t, func() { game.Turn(nil) }, "Endless turn should fail")
+	assert.True(t, time.Since(start) < 5*time.Second, "Turn not interrupted")
+}
@@ -1,32 +1,78 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	docopt "github.com/docopt/docopt-go"
 	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/gamelogic"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh/terminal"
+	"math"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 var (
 	version string
+	// Set if repeated logs are dropped
+	logDedup *dedupFormatter
 )
 
-func setupLogging(arguments map[string]interface{}) {
+func setupLogging(arguments map[string]interface{}) error {
 	log.SetOutput(os.Stdout)
 
+	// Colors are only used on terminals by default
+	colors := terminal.IsTerminal(int(os.Stdout.Fd()))
+	if arguments["--no-color"] == true {
+		colors = false
+	} else if arguments["--color"] != nil {
+		switch arguments["--color"].(string) {
+		case "auto":
+		case "always":
+			colors = true
+		case "never":
+			colors = false
+		default:
+			return fmt.Errorf("Invalid arguments: Bad --color=%v. "+
+				"Accepted values: auto always never", arguments["--color"])
+		}
+	}
+
+	var formatter log.Formatter
 	if arguments["--json-logs"] == true {
-		log.SetFormatter(&log.JSONFormatter{})
+		formatter = &log.JSONFormatter{}
+	} else if arguments["--compact-logs"] == true {
+		formatter = &compactFormatter{colors: colors}
 	} else {
 		customFormatter := new(log.TextFormatter)
 		customFormatter.TimestampFormat = "2006-01-02 15:04:05.000"
 		customFormatter.FullTimestamp = true
 		customFormatter.QuoteEmptyFields = true
-		log.SetFormatter(customFormatter)
+		customFormatter.ForceColors = colors
+		customFormatter.DisableColors = !colors
+		formatter = customFormatter
+	}
+
+	logRepeatsMax := 0
+	if arguments["--log-repeats-max"] != nil {
+		var err error
+		logRepeatsMax, err = netorcai.ReadIntInString(arguments,
+			"--log-repeats-max", 64, 0, 65535)
+		if err != nil {
+			return fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+	}
+	if logRepeatsMax > 0 {
+		logDedup = newDedupFormatter(formatter, logRepeatsMax)
+		go logDedup.run()
+		formatter = logDedup
 	}
+	log.SetFormatter(formatter)
 
 	if arguments["--debug"] == true {
 		log.SetLevel(log.DebugLevel)
@@ -35,6 +81,18 @@ func setupLogging(arguments map[string]interface{}) {
 	} else {
 		log.SetLevel(log.InfoLevel)
 	}
+	return nil
+}
+
+func setupLoggingOrFail(arguments map[string]interface{}) bool {
+	err := setupLogging(arguments)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return false
+	}
+	return true
 }
 
 func initializeGlobalState(arguments map[string]interface{}) (
@@ -74,78 +132,258 @@ func initializeGlobalState(arguments map[string]interface{}) (
 		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
 	}
 
+	msBetweenTurnsMin, err := netorcai.ReadFloatInString(arguments,
+		"--delay-turns-min", 64, 50, 10000)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	if msBetweenTurnsMin > msBetweenTurns {
+		return nil, fmt.Errorf("Invalid arguments: " +
+			"--delay-turns-min is greater than --delay-turns")
+	}
+
+	nbAcceptors, err := netorcai.ReadIntInString(arguments,
+		"--acceptors", 64, 1, 1024)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	maxPendingLogins, err := netorcai.ReadIntInString(arguments,
+		"--max-pending-logins", 64, 0, 65535)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	nbBroadcastWorkers, err := netorcai.ReadIntInString(arguments,
+		"--broadcast-workers", 64, 0, 65535)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
 	autostart := arguments["--autostart"].(bool)
+	reusePort := arguments["--reuse-port"].(bool)
+	autostartCheck := arguments["--autostart-check"].(bool)
 	fast := arguments["--fast"].(bool)
+	echoActionsToVisus := arguments["--echo-actions-to-visus"].(bool)
+	anonymizePlayers := arguments["--anonymize-players"].(bool)
+
+	publicVisuDelay, err := netorcai.ReadIntInString(arguments,
+		"--public-visu-delay", 64, 0, 65535)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+	stalePlayers := arguments["--stale-players"].(string)
+	if stalePlayers != "catch-up" && stalePlayers != "send-all" {
+		return nil, fmt.Errorf("Invalid arguments: "+
+			"Bad --stale-players=%v. Accepted values: catch-up send-all",
+			stalePlayers)
+	}
+	turnHistory, err := netorcai.ReadIntInString(arguments,
+		"--turn-history", 64, 0, 65535)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	// Minimum time between two TURN messages sent to a client, per role
+	minTurnIntervals := make(map[string]time.Duration)
+	for _, role := range []string{"players", "splayers", "visus"} {
+		maxTurnRate, err := netorcai.ReadFloatInString(arguments,
+			"--max-turn-rate-"+role, 64, 0, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+		if maxTurnRate > 0 {
+			minTurnIntervals[role] = time.Duration(float64(time.Second) / maxTurnRate)
+		}
+	}
+	adaptiveDelay := arguments["--adaptive-delay"].(bool)
+	logStateDiffs := arguments["--log-state-diffs"].(bool)
+	logTurns := arguments["--log-turns"].(bool)
+	lintGameState := arguments["--lint-game-state"].(bool)
+	reportEncoding := arguments["--report-encoding"].(bool)
+	glHotSwap := arguments["--gl-hot-swap"].(bool)
+
+	hookCommand := ""
+	if arguments["--hook-command"] != nil {
+		hookCommand = arguments["--hook-command"].(string)
+	}
+
+	maxStateBytes, err := netorcai.ReadIntInString(arguments,
+		"--max-state-bytes", 64, 0, 16777215)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	err = netorcai.SetJSONBackend(arguments["--json-backend"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	maxArrayLength, err := netorcai.ReadIntInString(arguments,
+		"--max-array-length", 64, 0, 16777215)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	stateSizePolicy := arguments["--state-size-policy"].(string)
+	if stateSizePolicy != "warn" && stateSizePolicy != "abort" {
+		return nil, fmt.Errorf("Invalid arguments: "+
+			"Bad --state-size-policy=%v. Accepted values: warn abort",
+			stateSizePolicy)
+	}
+
+	countdown, err := netorcai.ReadIntInString(arguments, "--countdown", 64,
+		0, 60)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	msGameStartsAckTimeout, err := netorcai.ReadFloatInString(arguments,
+		"--game-starts-ack-timeout", 64, 0, 600000)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	watchdogTimeout, err := netorcai.ReadIntInString(arguments,
+		"--watchdog", 64, 0, 86400000)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	watchdogAction := arguments["--watchdog-action"].(string)
+	if watchdogAction != "abort" && watchdogAction != "advance" {
+		return nil, fmt.Errorf("Invalid arguments: "+
+			"Bad --watchdog-action=%v. Accepted values: abort advance",
+			watchdogAction)
+	}
+
+	actionOrder, validOrder := netorcai.ActionOrders[arguments["--action-order"].(string)]
+	if !validOrder {
+		return nil, fmt.Errorf("Invalid arguments: "+
+			"Bad --action-order=%v. Accepted values: arrival player-id shuffled",
+			arguments["--action-order"])
+	}
+
+	duplicateLogin := arguments["--duplicate-login"].(string)
+	if duplicateLogin != "reject" && duplicateLogin != "replace" {
+		return nil, fmt.Errorf("Invalid arguments: "+
+			"Bad --duplicate-login=%v. Accepted values: reject replace",
+			duplicateLogin)
+	}
+
+	var accounts *netorcai.Accounts
+	if arguments["--accounts"] != nil {
+		accounts, err = netorcai.LoadAccounts(arguments["--accounts"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid arguments: "+
+				"Cannot load --accounts: %v", err.Error())
+		}
+	}
+
+	dumpStatesDir := ""
+	if arguments["--dump-states"] != nil {
+		dumpStatesDir = arguments["--dump-states"].(string)
+		if arguments["--check-config"] != true {
+			err = os.MkdirAll(dumpStatesDir, 0755)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid arguments: "+
+				"Cannot create --dump-states directory: %v", err.Error())
+		}
+	}
+
+	var seed *int64
+	if arguments["--seed"] != nil {
+		seedValue, err := netorcai.ReadIntInString(arguments, "--seed",
+			64, 0, math.MaxInt32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+		seed = new(int64)
+		*seed = int64(seedValue)
+	}
+
+	signingKey, err := loadSigningKey(arguments)
+	if err != nil {
+		return nil, err
+	}
 
 	gs := &netorcai.GlobalState{
-		GameState:                   netorcai.GAME_NOT_RUNNING,
-		NbPlayersMax:                nbPlayersMax,
-		NbSpecialPlayersMax:         nbSpecialPlayersMax,
-		NbVisusMax:                  nbVisusMax,
-		NbTurnsMax:                  nbTurnsMax,
-		Autostart:                   autostart,
-		Fast:                        fast,
-		MillisecondsBeforeFirstTurn: msBeforeFirstTurn,
-		MillisecondsBetweenTurns:    msBetweenTurns,
+		GameState:                        netorcai.GAME_NOT_RUNNING,
+		NbPlayersMax:                     nbPlayersMax,
+		NbSpecialPlayersMax:              nbSpecialPlayersMax,
+		NbVisusMax:                       nbVisusMax,
+		NbTurnsMax:                       nbTurnsMax,
+		Autostart:                        autostart,
+		AutostartCheck:                   autostartCheck,
+		Fast:                             fast,
+		EchoActionsToVisus:               echoActionsToVisus,
+		AnonymizePlayers:                 anonymizePlayers,
+		PublicVisuDelay:                  publicVisuDelay,
+		MillisecondsBeforeFirstTurn:      msBeforeFirstTurn,
+		MillisecondsBetweenTurns:         msBetweenTurns,
+		MillisecondsBetweenTurnsMin:      msBetweenTurnsMin,
+		AdaptiveDelay:                    adaptiveDelay,
+		MillisecondsGameStartsAckTimeout: msGameStartsAckTimeout,
+		Countdown:                        countdown,
+		DumpStatesDirectory:              dumpStatesDir,
+		LogStateDiffs:                    logStateDiffs,
+		LogTurns:                         logTurns,
+		LintGameState:                    lintGameState,
+		ReportEncoding:                   reportEncoding,
+		MaxArrayLength:                   maxArrayLength,
+		MaxStateBytes:                    maxStateBytes,
+		AbortOnStateTooBig:               stateSizePolicy == "abort",
+		NbAcceptors:                      nbAcceptors,
+		ReusePort:                        reusePort,
+		MaxPendingLogins:                 maxPendingLogins,
+		NbBroadcastWorkers:               nbBroadcastWorkers,
+		HookCommand:                      hookCommand,
+		Accounts:                         accounts,
+		GameLogicHotSwap:                 glHotSwap,
+		DuplicateLoginReplace:            duplicateLogin == "replace",
+		WatchdogTimeout:                  time.Duration(watchdogTimeout) * time.Millisecond,
+		WatchdogAdvance:                  watchdogAction == "advance",
+		StalePlayersSendAll:              stalePlayers == "send-all",
+		TurnHistory:                      turnHistory,
+		MinTurnIntervalPlayers:           minTurnIntervals["players"],
+		MinTurnIntervalSpecialPlayers:    minTurnIntervals["splayers"],
+		MinTurnIntervalVisus:             minTurnIntervals["visus"],
+		ActionOrder:                      actionOrder,
+		SkipIdleTurns:                    arguments["--skip-idle-turns"].(bool),
+		Seed:                             seed,
+		SigningKey:                       signingKey,
 	}
 
 	return gs, nil
 }
 
-func setupGuards(gs *netorcai.GlobalState, onAbort chan int) {
+func setupGuards(gs *netorcai.GlobalState, cancel context.CancelFunc) {
 	// Guard against SIGINT (ctrl+C) and SIGTERM (kill)
 	sigterm := make(chan os.Signal, 2)
 	signal.Notify(sigterm, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigterm
-		onAbort <- 1
+		cancel()
 	}()
 }
 
 func main() {
+	// netorcai runs itself to set the sandbox of player commands up
+	if netorcai.IsSandboxHelper() {
+		os.Exit(netorcai.RunSandboxHelper())
+	}
 	os.Exit(mainReturnWithCode())
 }
 
 func mainReturnWithCode() int {
-	usage := `NETwork ORChestrator for Artificial Intelligence games.
-
-Usage:
-  netorcai [--port=<port-number>]
-           [--nb-turns-max=<nbt>]
-           [--nb-players-max=<nbp>]
-           [--nb-splayers-max=<nbsp>]
-           [--nb-visus-max=<nbv>]
-           [--delay-first-turn=<ms>]
-           [--delay-turns=<ms>]
-           [--autostart]
-           [--fast]
-           [--simple-prompt]
-           [(--verbose | --quiet | --debug)] [--json-logs]
-  netorcai -h | --help
-  netorcai --version
-
-Options:
-  --port=<port-number>      The TCP port to listen incoming connections.
-                            [default: 4242]
-  --nb-turns-max=<nbt>      The maximum number of turns. [default: 100]
-  --nb-players-max=<nbp>    The maximum number of players. [default: 4]
-  --nb-splayers-max=<nbsp>  The maximum number of special players. [default: 0]
-  --nb-visus-max=<nbv>      The maximum number of visualizations. [default: 1]
-  --delay-first-turn=<ms>   The amount of time (in milliseconds) between the
-                            GAME_STARTS message and the first TURN message.
-                            [default: 1000]
-  --delay-turns=<ms>        The amount of time (in milliseconds) between two
-                            consecutive TURNs. [default: 1000]
-  --autostart               Start game when all clients are connnected.
-                            Set --nb-{players,splayers,visus}-max accordingly.
-  --fast                    Do not rely on timers to manage turns.
-                            Send DO_TURN as soon as all players have played.
-                            This assumes players play/crash in finite time.
-  --simple-prompt           Always use a simple prompt.
-  --quiet                   Only print critical information.
-  --verbose                 Print information. Default verbosity mode.
-  --debug                   Print debug information.
-  --json-logs               Print log information in JSON.`
+	command, usage := commandOf(os.Args[1:])
+	defer func() {
+		if logDedup != nil {
+			logDedup.flush(true)
+		}
+	}()
 
 	netorcaiVersion := version
 	if netorcaiVersion == "" {
@@ -171,7 +409,40 @@ Options:
 		return ret
 	}
 
-	setupLogging(arguments)
+	if arguments["<command>"] != nil {
+		fmt.Printf("Unknown command '%v'. Run 'netorcai --help' for the "+
+			"list of commands.\n", arguments["<command>"])
+		return 1
+	}
+
+	switch command {
+	case "version":
+		return runVersion(netorcaiVersion, arguments["--json"] == true)
+	case "schema":
+		messageType, _ := arguments["<message-type>"].(string)
+		return runSchema(messageType)
+	case "add-account":
+		if !setupLoggingOrFail(arguments) {
+			return 1
+		}
+		return runAddAccount(arguments["<account-name>"].(string),
+			arguments["--accounts"].(string))
+	case "new-signing-key":
+		if !setupLoggingOrFail(arguments) {
+			return 1
+		}
+		return runNewSigningKey(arguments)
+	case "verify-signatures":
+		if !setupLoggingOrFail(arguments) {
+			return 1
+		}
+		return runVerifySignatures(arguments["<key-file>"].(string),
+			arguments["<file>"].([]string))
+	}
+
+	if !setupLoggingOrFail(arguments) {
+		return 1
+	}
 
 	port, err := netorcai.ReadIntInString(arguments, "--port", 64, 1, 65535)
 	if err != nil {
@@ -181,6 +452,49 @@ Options:
 		return 1
 	}
 
+	switch command {
+	case "doctor":
+		return runDoctor(port)
+	case "validate-replay", "verify-replay":
+		return runValidateReplay(arguments["<dump-dir>"].(string), port,
+			arguments["--gl-command"].(string))
+	case "tournament":
+		return runTournament(arguments, port)
+	case "lobby":
+		return runLobby(arguments, port)
+	case "scheduler":
+		return runScheduler(arguments, port)
+	case "check-client":
+		return runCheckClient(arguments, port)
+	}
+
+	var embeddedGL gamelogic.Game
+	embeddedGLName := ""
+	if command == "replay" {
+		arguments, embeddedGL, err = replayServeArguments(arguments, port)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot replay game")
+			return 1
+		}
+		embeddedGLName = "replay"
+	}
+
+	nbLoopbackPlayers := 0
+	if command == "bench" {
+		arguments, nbLoopbackPlayers, err = benchServeArguments(arguments,
+			port)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Invalid argument")
+			return 1
+		}
+		// Printed once the game is over and netorcai is stopped
+		defer netorcai.PrintTurnCosts()
+	}
+
 	globalState, err := initializeGlobalState(arguments)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -188,16 +502,156 @@ Options:
 		}).Error("Invalid argument")
 		return 1
 	}
+
+	adminPort := 0
+	if arguments["--admin-port"] != nil {
+		adminPort, err = netorcai.ReadIntInString(arguments, "--admin-port",
+			64, 1, 65535)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Invalid argument")
+			return 1
+		}
+	}
+
+	controlPort, controlToken, err := readControlOptions(arguments)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	configFilename := ""
+	if arguments["--config"] != nil {
+		configFilename = arguments["--config"].(string)
+		err = netorcai.LoadConfig(globalState, configFilename)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Invalid argument: --config")
+			return 1
+		}
+	}
+
+	if arguments["--check-config"] == true {
+		return runCheckConfig(arguments, globalState, port, adminPort,
+			controlPort)
+	}
+	for _, conflict := range optionConflicts(arguments) {
+		log.WithFields(log.Fields{
+			"err": conflict,
+		}).Warn("Conflicting arguments")
+	}
+
+	if arguments["--trace-messages"] != nil {
+		traceMaxPayload, err := netorcai.ReadIntInString(arguments,
+			"--trace-payload-max", 64, 0, 16777216)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Invalid argument")
+			return 1
+		}
+
+		err = netorcai.StartMessageTracing(
+			arguments["--trace-messages"].(string), traceMaxPayload)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot open message trace file")
+			return 1
+		}
+		defer netorcai.StopMessageTracing()
+	}
+
+	if arguments["--lua-gl"] != nil {
+		embeddedGL, err = initializeLuaGame(arguments)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot run Lua game logic")
+			return 1
+		}
+		embeddedGLName = "lua"
+	} else if arguments["--wasm-gl"] != nil {
+		embeddedGL, err = initializeWasmGame(arguments)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot run WASM game logic")
+			return 1
+		}
+		embeddedGLName = "wasm"
+	}
+
+	glAddress := ""
+	if arguments["--connect-gl"] != nil {
+		glAddress = arguments["--connect-gl"].(string)
+		_, _, err = net.SplitHostPort(glAddress)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Invalid argument: --connect-gl")
+			return 1
+		}
+	}
+
+	playerAddresses := arguments["--connect-player"].([]string)
+	for _, address := range playerAddresses {
+		_, _, err = net.SplitHostPort(address)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Invalid argument: --connect-player")
+			return 1
+		}
+	}
 	defer globalState.WaitGroup.Wait()
 
-	guardExit := make(chan int, 1)
+	// Cancelled on SIGINT/SIGTERM, which shuts netorcai down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	serverExit := make(chan int, 1)
 	gameLogicExit := make(chan int, 1)
 	shellExit := make(chan int, 1)
 
-	setupGuards(globalState, guardExit)
+	defer netorcai.SystemdNotify("STOPPING=1")
+
+	setupGuards(globalState, cancel)
+	setupSignals(globalState, configFilename)
 	globalState.WaitGroup.Add(1)
-	go netorcai.RunServer(int(port), globalState, serverExit, gameLogicExit)
+	go netorcai.RunServer(ctx, int(port), globalState, serverExit, gameLogicExit)
+	go netorcai.RunSystemdWatchdog(globalState)
+	if embeddedGL != nil {
+		go runEmbeddedGameLogic(globalState, gameLogicExit, embeddedGL,
+			embeddedGLName)
+	}
+	if glAddress != "" {
+		go netorcai.ConnectRemoteClient(ctx, glAddress, "game logic",
+			globalState, gameLogicExit)
+	}
+	for _, address := range playerAddresses {
+		go netorcai.ConnectRemoteClient(ctx, address, "player", globalState,
+			gameLogicExit)
+	}
+	for i := 0; i < nbLoopbackPlayers; i++ {
+		go runLoopbackPlayer(globalState, gameLogicExit,
+			fmt.Sprintf("bench-%v", i))
+	}
+	if adminPort != 0 {
+		go netorcai.RunAdminServer(adminPort, globalState, serverExit)
+	}
+	if controlPort != 0 {
+		go netorcai.RunControlServer(arguments["--control-address"].(string),
+			controlPort, controlToken, globalState, serverExit)
+	}
+	if arguments["--announce-mdns"] == true {
+		go netorcai.RunMdnsAnnouncer(port, arguments["--game-name"].(string),
+			globalState)
+	}
 
 	interactivePrompt := true
 	if arguments["--simple-prompt"] == true {
@@ -206,17 +660,17 @@ Options:
 		interactivePrompt = terminal.IsTerminal(int(os.Stdout.Fd()))
 	}
 
-	go netorcai.RunPrompt(globalState, shellExit, interactivePrompt)
+	go netorcai.RunPrompt(ctx, globalState, shellExit, interactivePrompt)
 
 	select {
 	case serverExitCode := <-serverExit:
 		return serverExitCode
-	case guardExitCode := <-guardExit:
+	case <-ctx.Done():
 		log.Warn("SIGTERM received. Aborting.")
 		netorcai.Cleanup()
-		return guardExitCode
+		return 1
 	case gameLogicExitCode := <-gameLogicExit:
-		if gameLogicExitCode != 0 {
+		if gameLogicExitCode != 0 && globalState.AbortReason == "" {
 			log.Warn("Game logic failed. Aborting.")
 		}
 		netorcai.Cleanup()
@@ -227,3 +681,573 @@ Options:
 		return shellExitCode
 	}
 }
+
+func initializeLuaGame(arguments map[string]interface{}) (
+	gamelogic.Game, error) {
+	turnTimeout, err := netorcai.ReadIntInString(arguments,
+		"--lua-turn-timeout", 64, 1, 3600000)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	return newLuaGame(arguments["--lua-gl"].(string),
+		time.Duration(turnTimeout)*time.Millisecond)
+}
+
+func initializeWasmGame(arguments map[string]interface{}) (
+	gamelogic.Game, error) {
+	memoryMax, err := netorcai.ReadIntInString(arguments,
+		"--wasm-memory-max", 64, 1, 4096)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	turnTimeout, err := netorcai.ReadIntInString(arguments,
+		"--wasm-turn-timeout", 64, 1, 3600000)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	return newWasmGame(arguments["--wasm-gl"].(string), memoryMax,
+		time.Duration(turnTimeout)*time.Millisecond)
+}
+
+// Runs an in-process game logic, connected to netorcai without any socket.
+func runEmbeddedGameLogic(gs *netorcai.GlobalState, gameLogicExit chan int,
+	game gamelogic.Game, nickname string) {
+	conn := netorcai.ConnectLoopbackClient(gs, gameLogicExit)
+	_, err := gamelogic.RunConn(game, conn, nickname)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"nickname": nickname,
+			"err":      err,
+		}).Error("Embedded game logic failed")
+	}
+}
+
+// Replays a recorded game through a fresh game logic.
+// Returns 0 if all the replayed game states match the recorded ones.
+func runValidateReplay(directory string, port int, glCommand string) int {
+	nbMismatches, err := netorcai.VerifyReplay(directory, port, glCommand)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot verify replay")
+		return 1
+	}
+
+	if nbMismatches > 0 {
+		fmt.Printf("Replay diverged: %v game states (or random draws) "+
+			"do not match\n", nbMismatches)
+		return 1
+	}
+	fmt.Println("Replay verified: All game states match")
+	return 0
+}
+
+// Checks that a client logs in and answers a PING.
+// Returns 0 if it does.
+func runCheckClient(arguments map[string]interface{}, port int) int {
+	connectTimeout, err := netorcai.ReadIntInString(arguments, "--timeout",
+		64, 1, 3600)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	result, err := netorcai.CheckClient(port,
+		time.Duration(connectTimeout)*time.Second)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Client check failed")
+		return 1
+	}
+
+	netorcai.PrintCheckResults([]netorcai.CheckResult{result})
+	if !result.Answered {
+		return 1
+	}
+	return 0
+}
+
+// Runs a tournament, each game being run by a netorcai subprocess.
+// Returns 0 if the tournament could be run.
+func runTournament(arguments map[string]interface{}, port int) int {
+	players, err := netorcai.ReadTournamentPlayers(arguments["--players"].(string))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot read tournament players")
+		return 1
+	}
+
+	settings, err := initializeTournamentSettings(arguments, port)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	signingKey, err := loadSigningKey(arguments)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	matches, err := netorcai.RunTournament(arguments["--bracket"].(string),
+		players, settings)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot run tournament")
+		return 1
+	}
+
+	report := netorcai.ReportTournament(players, matches)
+	report.Print()
+
+	if arguments["--results"] != nil {
+		filename := arguments["--results"].(string)
+		content, err := json.MarshalIndent(matches, "", "  ")
+		if err == nil {
+			err = netorcai.WriteSignedFile(filename, content, 0644,
+				signingKey)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":      err,
+				"filename": filename,
+			}).Error("Cannot write tournament results")
+			return 1
+		}
+	}
+
+	if arguments["--report"] != nil {
+		filename := arguments["--report"].(string)
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = netorcai.WriteSignedFile(filename, content, 0644,
+				signingKey)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":      err,
+				"filename": filename,
+			}).Error("Cannot write tournament report")
+			return 1
+		}
+	}
+	return 0
+}
+
+func initializeTournamentSettings(arguments map[string]interface{},
+	port int) (netorcai.TournamentSettings, error) {
+	var settings netorcai.TournamentSettings
+	nbRooms, err := netorcai.ReadIntInString(arguments, "--rooms", 64, 1, 256)
+	if err != nil {
+		return settings, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+	if port+nbRooms-1 > 65535 {
+		return settings, fmt.Errorf("Invalid arguments: "+
+			"The ports of the %v rooms exceed 65535", nbRooms)
+	}
+
+	matchTimeout, err := netorcai.ReadIntInString(arguments,
+		"--match-timeout", 64, 1, 86400)
+	if err != nil {
+		return settings, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return settings, fmt.Errorf("Cannot find netorcai executable: %v",
+			err.Error())
+	}
+
+	// These arguments are checked by the netorcai process of each game
+	gameArguments := []string{
+		"--nb-turns-max=" + arguments["--nb-turns-max"].(string),
+		"--delay-first-turn=" + arguments["--delay-first-turn"].(string),
+		"--delay-turns=" + arguments["--delay-turns"].(string),
+	}
+	if arguments["--fast"] == true {
+		gameArguments = append(gameArguments, "--fast")
+	}
+
+	matchLogsDirectory, err := initializeMatchLogsDirectory(arguments)
+	if err != nil {
+		return settings, err
+	}
+
+	limits, err := initializeResourceLimits(arguments)
+	if err != nil {
+		return settings, err
+	}
+
+	sandbox := arguments["--sandbox"] == true
+	err = netorcai.CheckSandbox(sandbox)
+	if err != nil {
+		return settings, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+
+	// The game logic executable is also checked before each game
+	glChecksum := ""
+	glExecutable := ""
+	if arguments["--gl-executable"] != nil {
+		glExecutable = arguments["--gl-executable"].(string)
+	}
+	if arguments["--gl-sha256"] != nil {
+		glChecksum = arguments["--gl-sha256"].(string)
+		err = netorcai.CheckSHA256(glChecksum)
+		if err == nil {
+			err = netorcai.CheckGLExecutable(
+				arguments["--gl-command"].(string), glExecutable, glChecksum)
+		}
+		if err != nil {
+			return settings, fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+	}
+
+	nbRounds := 0
+	if arguments["--rounds"] != nil {
+		nbRounds, err = netorcai.ReadIntInString(arguments, "--rounds",
+			64, 1, 1024)
+		if err != nil {
+			return settings, fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+	}
+
+	settings = netorcai.TournamentSettings{
+		Executable:    executable,
+		GameArguments: gameArguments,
+		GLCommand:     arguments["--gl-command"].(string),
+		GLExecutable:  glExecutable,
+		GLChecksum:    glChecksum,
+		Port:          port,
+		NbRooms:       nbRooms,
+		NbRounds:      nbRounds,
+		MatchTimeout:  time.Duration(matchTimeout) * time.Second,
+
+		MatchLogsDirectory: matchLogsDirectory,
+		Limits:             limits,
+		Sandbox:            sandbox,
+	}
+	return settings, nil
+}
+
+// Reads the resource limits of the commands run for each game.
+func initializeResourceLimits(arguments map[string]interface{}) (
+	netorcai.ResourceLimits, error) {
+	var limits netorcai.ResourceLimits
+	if arguments["--cpu-limit"] != nil {
+		cpuLimit, err := netorcai.ReadIntInString(arguments, "--cpu-limit",
+			64, 1, 86400)
+		if err != nil {
+			return limits, fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+		limits.CPUTime = time.Duration(cpuLimit) * time.Second
+	}
+
+	if arguments["--memory-limit"] != nil {
+		memoryLimit, err := netorcai.ReadIntInString(arguments,
+			"--memory-limit", 64, 1, 1048576)
+		if err != nil {
+			return limits, fmt.Errorf("Invalid arguments: %v", err.Error())
+		}
+		limits.Memory = int64(memoryLimit) * 1024 * 1024
+	}
+
+	err := netorcai.CheckResourceLimits(limits)
+	if err != nil {
+		return limits, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+	return limits, nil
+}
+
+// Creates the directory of the per-match log files, if any.
+func initializeMatchLogsDirectory(arguments map[string]interface{}) (
+	string, error) {
+	if arguments["--match-logs"] == nil {
+		return "", nil
+	}
+
+	directory := arguments["--match-logs"].(string)
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return "", fmt.Errorf("Cannot create match logs directory: %v",
+			err.Error())
+	}
+	return directory, nil
+}
+
+// Reads the port of the control endpoints (0 if they are disabled) and the
+// token that authenticates their requests.
+func readControlOptions(arguments map[string]interface{}) (int, string,
+	error) {
+	if arguments["--control-port"] == nil {
+		return 0, "", nil
+	}
+
+	port, err := netorcai.ReadIntInString(arguments, "--control-port",
+		64, 1, 65535)
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+	if arguments["--control-token-file"] == nil {
+		return 0, "", fmt.Errorf("Invalid arguments: " +
+			"--control-port requires --control-token-file")
+	}
+	token, err := netorcai.LoadControlToken(
+		arguments["--control-token-file"].(string))
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid arguments: "+
+			"Cannot load --control-token-file: %v", err.Error())
+	}
+	return port, token, nil
+}
+
+// Loads the key that signs the written files (nil if there is none).
+func loadSigningKey(arguments map[string]interface{}) (
+	*netorcai.SigningKey, error) {
+	if arguments["--signing-key"] == nil {
+		return nil, nil
+	}
+
+	key, err := netorcai.LoadSigningKey(arguments["--signing-key"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid arguments: "+
+			"Cannot load --signing-key: %v", err.Error())
+	}
+	if !key.CanSign() {
+		return nil, fmt.Errorf("Invalid arguments: " +
+			"--signing-key is a public key, which cannot sign files")
+	}
+	return key, nil
+}
+
+// Creates a signing key (and possibly writes its public part).
+func runNewSigningKey(arguments map[string]interface{}) int {
+	algorithm := netorcai.SignatureEd25519
+	if arguments["--hmac"] == true {
+		algorithm = netorcai.SignatureHMAC
+	}
+
+	key, err := netorcai.NewSigningKey(algorithm)
+	if err == nil {
+		err = key.Save(arguments["<key-file>"].(string))
+	}
+	if err == nil && arguments["--public-key"] != nil {
+		err = key.SavePublic(arguments["--public-key"].(string))
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot create signing key")
+		return 1
+	}
+	return 0
+}
+
+// Checks the signatures of files, and replay directories against their
+// manifest. Returns 0 if they all match.
+func runVerifySignatures(keyFilename string, filenames []string) int {
+	key, err := netorcai.LoadSigningKey(keyFilename)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot load key")
+		return 1
+	}
+
+	nbInvalid := 0
+	for _, filename := range filenames {
+		if info, statErr := os.Stat(filename); statErr == nil && info.IsDir() {
+			err = netorcai.VerifyDirectorySignature(filename, key)
+		} else {
+			err = netorcai.VerifyFileSignature(filename, key)
+		}
+		if err != nil {
+			fmt.Printf("[FAIL] %v: %v\n", filename, err.Error())
+			nbInvalid++
+		} else {
+			fmt.Printf("[OK]   %v\n", filename)
+		}
+	}
+
+	if nbInvalid > 0 {
+		fmt.Printf("%v files do not match their signature\n", nbInvalid)
+		return 1
+	}
+	return 0
+}
+
+// Creates a player account and prints its API key.
+// Prints the JSON Schema of a message type, or of all of them if
+// messageType is empty.
+func runSchema(messageType string) int {
+	schema := netorcai.MetaprotocolSchema()
+	if messageType != "" {
+		var err error
+		schema, err = netorcai.MessageSchema(messageType)
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+	}
+
+	content, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		fmt.Printf("Cannot serialize schema: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(content))
+	return 0
+}
+
+func runAddAccount(name, filename string) int {
+	accounts, err := netorcai.LoadAccounts(filename)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot load accounts")
+		return 1
+	}
+
+	apiKey, err := accounts.Add(name)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot add account")
+		return 1
+	}
+
+	fmt.Println(apiKey)
+	return 0
+}
+
+// Runs a lobby, that starts a game room whenever enough players wait.
+func runLobby(arguments map[string]interface{}, port int) int {
+	nbPlayers, err := netorcai.ReadIntInString(arguments,
+		"--nb-players-max", 64, 1, 1024)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	// Rooms listen right after the lobby port
+	settings, err := initializeTournamentSettings(arguments, port+1)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	err = netorcai.RunLobby(port, nbPlayers, settings)
+	log.WithFields(log.Fields{
+		"err": err,
+	}).Error("Lobby stopped")
+	return 1
+}
+
+// Serves the match scheduling API, and runs the enqueued matches.
+func runScheduler(arguments map[string]interface{}, port int) int {
+	adminPort, err := netorcai.ReadIntInString(arguments, "--admin-port",
+		64, 1, 65535)
+	if err == nil && adminPort == port {
+		err = fmt.Errorf("The admin port must differ from the game port")
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	token, err := netorcai.LoadControlToken(
+		arguments["--control-token-file"].(string))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot load --control-token-file")
+		return 1
+	}
+
+	var accounts *netorcai.Accounts
+	if arguments["--accounts"] != nil {
+		accounts, err = netorcai.LoadAccounts(arguments["--accounts"].(string))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot load --accounts")
+			return 1
+		}
+	}
+
+	matchTimeout, err := netorcai.ReadIntInString(arguments,
+		"--match-timeout", 64, 1, 86400)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	matchLogsDirectory, err := initializeMatchLogsDirectory(arguments)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	limits, err := initializeResourceLimits(arguments)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	sandbox := arguments["--sandbox"] == true
+	err = netorcai.CheckSandbox(sandbox)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid argument")
+		return 1
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot find netorcai executable")
+		return 1
+	}
+
+	settings := netorcai.TournamentSettings{
+		Executable:   executable,
+		Port:         port,
+		NbRooms:      1,
+		MatchTimeout: time.Duration(matchTimeout) * time.Second,
+
+		MatchLogsDirectory: matchLogsDirectory,
+		Limits:             limits,
+		Sandbox:            sandbox,
+	}
+	err = netorcai.RunScheduler(arguments["--admin-address"].(string),
+		adminPort, token, accounts, settings)
+	log.WithFields(log.Fields{
+		"err": err,
+	}).Error("Match scheduling API stopped")
+	return 1
+}
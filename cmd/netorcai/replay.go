@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	docopt "github.com/docopt/docopt-go"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/gamelogic"
+)
+
+// Game logic that plays back the game states of a recorded game
+// (netorcai replay), whatever the actions of the players.
+// The winner of the recorded game is not recorded, so there is none.
+type replayGame struct {
+	states []map[string]interface{}
+	turn   int
+}
+
+func (g *replayGame) Init(nbPlayers, nbSpecialPlayers,
+	nbTurnsMax int) gamelogic.State {
+	g.turn = 0
+	return g.states[0]
+}
+
+func (g *replayGame) Turn(actions []gamelogic.PlayerActions) (gamelogic.State,
+	gamelogic.Winner) {
+	if g.turn < len(g.states)-1 {
+		g.turn++
+	}
+	return g.states[g.turn], gamelogic.NoWinner
+}
+
+// Returns the arguments of the game server that plays back the game recorded
+// in the replay <dump-dir>, and the game logic that sends its game states.
+// The server has no player: It waits for the visualizations, then sends
+// them one recorded game state per turn.
+func replayServeArguments(arguments map[string]interface{}, port int) (
+	map[string]interface{}, gamelogic.Game, error) {
+	states, err := netorcai.ReadRecordedGameStates(
+		arguments["<dump-dir>"].(string))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(states) < 2 {
+		return nil, nil, fmt.Errorf("No turn has been recorded")
+	}
+
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+	serveArguments, err := parser.ParseArgs(serveUsage, []string{
+		fmt.Sprintf("--port=%v", port),
+		"--nb-players-max=0",
+		"--nb-splayers-max=0",
+		fmt.Sprintf("--nb-visus-max=%v", arguments["--nb-visus-max"]),
+		fmt.Sprintf("--nb-turns-max=%v", len(states)-1),
+		fmt.Sprintf("--delay-first-turn=%v", arguments["--delay-first-turn"]),
+		fmt.Sprintf("--delay-turns=%v", arguments["--delay-turns"]),
+		"--autostart",
+	}, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid arguments: %v", err.Error())
+	}
+	return serveArguments, &replayGame{states: states}, nil
+}
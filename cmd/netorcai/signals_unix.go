@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"github.com/netorcai/netorcai"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Dumps netorcai status (and goroutines) on SIGUSR1.
+// Reloads the --config file on SIGHUP, if any.
+func setupSignals(gs *netorcai.GlobalState, configFilename string) {
+	sigusr1 := make(chan os.Signal, 1)
+	signal.Notify(sigusr1, syscall.SIGUSR1)
+	sighup := make(chan os.Signal, 1)
+	if configFilename != "" {
+		signal.Notify(sighup, syscall.SIGHUP)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-sigusr1:
+				netorcai.DumpStatus(gs)
+			case <-sighup:
+				err := netorcai.LoadConfig(gs, configFilename)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"err": err,
+					}).Warn("Cannot reload configuration")
+				}
+			}
+		}
+	}()
+}
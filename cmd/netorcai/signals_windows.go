@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"github.com/netorcai/netorcai"
+)
+
+// Windows has no SIGUSR1 nor SIGHUP equivalent: Go delivers both
+// CTRL_C_EVENT and CTRL_BREAK_EVENT as os.Interrupt, which already aborts
+// netorcai. The status can be printed with the prompt 'status' command
+// instead, and the --config file is only loaded on startup.
+func setupSignals(gs *netorcai.GlobalState, configFilename string) {
+}
@@ -0,0 +1,541 @@
+package main
+
+// Each subcommand has its own usage (and options). netorcai runs the server
+// (serve) if no subcommand is given.
+
+const loggingUsage = `[(--verbose | --quiet | --debug)]
+           [--json-logs | --compact-logs] [--color=<when> | --no-color]
+           [--log-repeats-max=<n>]`
+
+const portOption = `
+  --port=<port-number>      The TCP port to listen incoming connections.
+                            [default: 4242]`
+
+const loggingOptions = `
+  --quiet                   Only print critical information.
+  --verbose                 Print information. Default verbosity mode.
+  --debug                   Print debug information.
+  --json-logs               Print log information in JSON.
+  --compact-logs            Print log information on short lines
+                            (time, level, message and fields).
+  --color=<when>            Colorize log levels: auto (only if the output is
+                            a terminal), always or never. [default: auto]
+  --no-color                Same as --color=never.
+  --log-repeats-max=<n>     The number of identical warnings or errors logged
+                            within 10 seconds. Further ones are dropped, and
+                            how many times they have been repeated is logged
+                            instead. 0 means unlimited. [default: 0]`
+
+const gameOptions = `
+  --nb-turns-max=<nbt>      The maximum number of turns of each game.
+                            [default: 100]
+  --delay-first-turn=<ms>   The amount of time (in milliseconds) between the
+                            GAME_STARTS message and the first TURN message.
+                            [default: 1000]
+  --delay-turns=<ms>        The amount of time (in milliseconds) between two
+                            consecutive TURNs. [default: 1000]
+  --fast                    Do not rely on timers to manage turns.
+                            Send DO_TURN as soon as all players have played.`
+
+const matchOptions = `
+  --match-timeout=<s>       The maximum duration (in seconds) of a game.
+                            [default: 600]
+  --match-logs=<dir>        Also write the logs of each game into its own
+                            file in <dir>, named by match ID.
+  --cpu-limit=<s>           Kill the game logic or player commands that use
+                            more than <s> seconds of CPU time (Linux only).
+                            Killed players are considered kicked.
+  --memory-limit=<mib>      Kill the game logic or player commands that use
+                            more than <mib> MiB of resident memory
+                            (Linux only).`
+
+const sandboxOption = `
+  --sandbox                 Run player commands in a sandbox (Linux only):
+                            read-only filesystem (but a private /tmp), no
+                            network but the game connection, and a seccomp
+                            profile that denies dangerous system calls.`
+
+const roomsOptions = `
+  --gl-command=<cmd>        The shell command that runs the game logic of
+                            each game.
+  --gl-sha256=<hash>        Check that the game logic executable (the first
+                            word of the game logic command by default) has
+                            this SHA-256 checksum before running each game.
+  --gl-executable=<path>    The game logic executable checked by --gl-sha256,
+                            mandatory if the game logic command runs an
+                            interpreter (e.g. gl.py for python3 gl.py).
+  --rooms=<n>               The number of games run in parallel. Each room
+                            listens on its own port, starting from the given
+                            port (tournament) or right after it (lobby).
+                            [default: 1]`
+
+const serveUsage = `NETwork ORChestrator for Artificial Intelligence games.
+
+Usage:
+  netorcai [serve] [--port=<port-number>] [--admin-port=<port-number>]
+           [--control-port=<port-number>] [--control-address=<address>]
+           [--control-token-file=<file>]
+           [--acceptors=<n>] [--reuse-port] [--max-pending-logins=<n>]
+           [--broadcast-workers=<n>]
+           [--announce-mdns] [--game-name=<name>]
+           [--nb-turns-max=<nbt>]
+           [--nb-players-max=<nbp>]
+           [--nb-splayers-max=<nbsp>]
+           [--nb-visus-max=<nbv>]
+           [--delay-first-turn=<ms>]
+           [--delay-turns=<ms>]
+           [--adaptive-delay] [--delay-turns-min=<ms>]
+           [--game-starts-ack-timeout=<ms>]
+           [--autostart] [--autostart-check] [--countdown=<s>]
+           [--fast] [--action-order=<order>] [--skip-idle-turns]
+           [--echo-actions-to-visus] [--anonymize-players]
+           [--public-visu-delay=<nbt>] [--stale-players=<policy>]
+           [--turn-history=<nbt>]
+           [--max-turn-rate-players=<hz>] [--max-turn-rate-splayers=<hz>]
+           [--max-turn-rate-visus=<hz>]
+           [--dump-states=<dir>] [--seed=<n>] [--signing-key=<file>]
+           [--log-state-diffs] [--log-turns] [--lint-game-state]
+           [--report-encoding]
+           [--max-state-bytes=<bytes>] [--state-size-policy=<policy>]
+           [--max-array-length=<n>] [--json-backend=<name>]
+           [--watchdog=<ms>] [--watchdog-action=<action>]
+           [--trace-messages=<file>] [--trace-payload-max=<bytes>]
+           [--hook-command=<cmd>] [--gl-hot-swap]
+           [--accounts=<file>] [--duplicate-login=<policy>]
+           [--lua-gl=<file> | --wasm-gl=<file> | --connect-gl=<address>]
+           [--connect-player=<address>]...
+           [--wasm-memory-max=<MiB>] [--wasm-turn-timeout=<ms>]
+           [--lua-turn-timeout=<ms>]
+           [--simple-prompt] [--config=<file>] [--check-config]
+           ` + loggingUsage + `
+  netorcai <command> [<args>...]
+  netorcai -h | --help
+  netorcai --version
+
+Commands:
+  serve                     Run a game server. Default command.
+  replay                    Play a recorded game back to visualizations.
+  validate-replay           Check that a game logic replays a recorded game.
+  check-client              Check that a client logs in and answers PING.
+  bench                     Measure the cost of the turns of a game logic.
+  tournament                Run a tournament between player bots.
+  lobby                     Start games whenever enough players wait.
+  scheduler                 Run the matches enqueued over HTTP.
+  add-account               Create a player account.
+  new-signing-key           Create a key that signs replays and results.
+  verify-signatures         Check that signed files have not been modified.
+  doctor                    Check the runtime environment.
+  version                   Print version and build information.
+  schema                    Print the JSON Schema of the metaprotocol.
+Run 'netorcai <command> --help' for the options of a command.
+
+Options:` + portOption + `
+  --admin-port=<port-number>  The TCP port to serve HTTP health probes
+                            (/healthz and /readyz), metrics (/metrics),
+                            the stream of public TURN messages (/turns) and
+                            a minimal web visualization (/) on.
+                            Disabled by default.
+  --control-port=<port-number>  The TCP port to serve the HTTP endpoints
+                            that act on the game on: admin messages
+                            (POST /broadcast, with the text as body),
+                            game stop (POST /stop) and game abort
+                            (POST /abort, with the reason as body).
+                            Requests must carry the token of the token
+                            file (Authorization: Bearer <token>).
+                            Disabled by default.
+  --control-address=<address>  The address the control endpoints are
+                            served on. [default: 127.0.0.1]
+  --control-token-file=<file>  The file that contains the secret token of
+                            the control endpoints (required with a control
+                            port).
+  --acceptors=<n>           The number of goroutines that accept incoming
+                            connections. [default: 1]
+  --reuse-port              Give each acceptor its own listening socket
+                            (with SO_REUSEPORT).
+  --max-pending-logins=<n>  The maximum number of connected clients that have
+                            not sent their LOGIN yet. Further connections wait
+                            to be accepted. Such clients are disconnected
+                            if they do not log in within 10 seconds.
+                            0 means unbounded. [default: 0]
+  --broadcast-workers=<n>   The number of workers that write the TURN
+                            messages on client sockets, which bounds the
+                            number of concurrent writes.
+                            0 means unbounded. [default: 0]
+  --announce-mdns           Advertise netorcai on the local network (mDNS)
+                            as a _netorcai._tcp service.
+  --game-name=<name>        The game name advertised via mDNS.
+                            [default: netorcai]
+  --nb-turns-max=<nbt>      The maximum number of turns. [default: 100]
+  --nb-players-max=<nbp>    The maximum number of players. [default: 4]
+  --nb-splayers-max=<nbsp>  The maximum number of special players. [default: 0]
+  --nb-visus-max=<nbv>      The maximum number of visualizations. [default: 1]
+  --delay-first-turn=<ms>   The amount of time (in milliseconds) between the
+                            GAME_STARTS message and the first TURN message.
+                            [default: 1000]
+  --delay-turns=<ms>        The amount of time (in milliseconds) between two
+                            consecutive TURNs. [default: 1000]
+  --adaptive-delay          Adjust the delay between turns to the TURN_ACK
+                            latency of the slowest healthy player.
+                            The delay stays between the min and max delays.
+  --delay-turns-min=<ms>    The minimum amount of time (in milliseconds)
+                            between two consecutive TURNs, when the delay is
+                            adaptive. [default: 50]
+  --game-starts-ack-timeout=<ms>  Wait for a GAME_STARTS_ACK from every player
+                            before the first turn, for at most <ms>
+                            milliseconds after GAME_STARTS. 0 does not wait.
+                            [default: 0]
+  --autostart               Start game when all clients are connnected.
+                            Set --nb-{players,splayers,visus}-max accordingly.
+  --autostart-check         Only autostart if all clients answer a PING.
+  --countdown=<s>           Send a COUNTDOWN message to players and
+                            visualizations every second during the <s>
+                            seconds before the game starts. [default: 0]
+  --fast                    Do not rely on timers to manage turns.
+                            Send DO_TURN as soon as all players have played.
+                            This assumes players play/crash in finite time.
+  --action-order=<order>    The order of the player actions in DO_TURN:
+                            arrival (of their TURN_ACK), player-id or
+                            shuffled (every turn, reproducibly with --seed).
+                            [default: arrival]
+  --skip-idle-turns         Do not invoke the game logic on turns in which no
+                            player acted: The game state stays the same.
+  --echo-actions-to-visus   Send to visualizations the player actions that
+                            led to each turn, along with its game state.
+  --anonymize-players       Replace player nicknames by "Player <id>" labels
+                            (and hide remote addresses) in messages sent to
+                            clients. Real nicknames are still logged.
+  --public-visu-delay=<nbt>  The number of turns public visualizations lag
+                            behind the game. Live visualizations are not
+                            delayed. [default: 0]
+  --stale-players=<policy>  What players that have not acknowledged a TURN
+                            receive: catch-up (only the latest turn, once
+                            they acknowledge) or send-all (every turn).
+                            [default: catch-up]
+  --turn-history=<nbt>      The number of past turns kept in memory, sent to
+                            the visualizations that log in while the game is
+                            running. 0 denies them the running game.
+                            [default: 0]
+  --max-turn-rate-players=<hz>  The maximum number of TURN messages sent per
+                            second to each player. Excess turns are coalesced:
+                            only the latest one is sent.
+                            0 means unlimited. [default: 0]
+  --max-turn-rate-splayers=<hz>  Same as --max-turn-rate-players, for special
+                            players. [default: 0]
+  --max-turn-rate-visus=<hz>  Same as --max-turn-rate-players, for
+                            visualizations. [default: 0]
+  --dump-states=<dir>       Write the game state of each turn (and the
+                            actions that led to it) in <dir>.
+  --seed=<n>                Hand the seed <n> to the game logic in DO_INIT.
+                            It is logged and written in the --dump-states
+                            game.json file, so that games can be audited.
+  --signing-key=<file>      Write a manifest.json file that lists the files
+                            of the --dump-states directory (with their
+                            SHA-256 checksum) at the end of the game, and
+                            sign it with the key in <file>
+                            (see netorcai new-signing-key).
+  --log-state-diffs         Log which game state keys changed between two
+                            consecutive turns. Requires --debug.
+  --log-turns               Log (as info) when each TURN is sent to players.
+  --lint-game-state         Warn about suspicious game states sent by the
+                            game logic: NaN or infinite numbers, extremely
+                            deep nesting, duplicate keys, or fields whose
+                            type changes between turns.
+  --report-encoding         Log the size and encode time of each game state
+                            per codec (JSON, DEFLATE at several levels), and
+                            a summary at the end of the game, to tell whether
+                            compressed frames are worth it for the game.
+  --max-state-bytes=<bytes>  The maximum size of a serialized game state.
+                            0 means unlimited. [default: 0]
+  --state-size-policy=<policy>  What to do when a game state is bigger than
+                            the maximum size: warn or abort. [default: warn]
+  --max-array-length=<n>    The maximum number of elements of the arrays
+                            (e.g., actions) in the messages of players and
+                            visualizations. Longer messages are rejected as
+                            soon as the limit is reached while decoding.
+                            0 means unlimited. [default: 65536]
+  --json-backend=<name>     The JSON library that serializes TURN messages:
+                            std (encoding/json), or a faster one this netorcai
+                            has been built with (e.g., jsoniter with
+                            go build -tags jsoniter). [default: std]
+  --watchdog=<ms>           Consider the game hung if it makes no progress
+                            (no DO_TURN_ACK, no TURN_ACK and no turn timer
+                            fired) for <ms> milliseconds: The stacks of all
+                            goroutines are then logged. 0 disables it.
+                            [default: 0]
+  --watchdog-action=<action>  What to do when the game is hung: abort, or
+                            advance (with --fast, stop waiting for the
+                            missing TURN_ACK of players; abort otherwise).
+                            [default: abort]
+  --trace-messages=<file>   Record every message received or sent by netorcai
+                            into <file> (one JSON object per line).
+  --trace-payload-max=<bytes>  Maximum number of payload bytes recorded per
+                            traced message. 0 omits payloads.
+                            [default: 16777216]
+  --hook-command=<cmd>      The shell command run on game lifecycle events
+                            (game_starts, game_ends), with the event as JSON
+                            on its standard input.
+  --gl-hot-swap             Let a new game logic replace the current one
+                            before the game starts. Players and visus stay
+                            connected if the game logic leaves.
+  --accounts=<file>         The player accounts file. If set, players must
+                            log in with the API key of an account.
+  --duplicate-login=<policy>  What to do when a player logs in with the
+                            account of a logged in player: reject (the new
+                            login) or replace (kick the previous player).
+                            [default: reject]
+  --lua-gl=<file>           Run the Lua script <file> inside netorcai as the
+                            game logic. The script defines the init and turn
+                            functions. Requires a build with Lua support.
+  --wasm-gl=<file>          Run the WebAssembly (WASI) module <file> inside
+                            netorcai as the game logic. The module exchanges
+                            JSON lines on its standard input and output.
+                            Requires a build with WebAssembly support.
+  --connect-gl=<address>    Connect to a game logic that listens on <address>
+                            (host:port) instead of waiting for it to connect.
+                            The game logic then logs in as usual.
+  --connect-player=<address>  Connect to a player bot that listens on
+                            <address> (host:port). Can be repeated.
+                            Each bot then logs in as usual.
+  --wasm-memory-max=<MiB>   The maximum memory of the WebAssembly game logic.
+                            [default: 64]
+  --wasm-turn-timeout=<ms>  The time given to the WebAssembly game logic to
+                            answer each message. [default: 1000]
+  --lua-turn-timeout=<ms>   The time given to each call of the init and turn
+                            functions of the Lua game logic. [default: 1000]
+  --simple-prompt           Always use a simple prompt.
+  --config=<file>           Load the variables of <file> (written by the
+                            save-config prompt command) on startup.
+                            On Unix, <file> is loaded again on SIGHUP.
+  --check-config            Only check the options (ranges, files, options
+                            that conflict with others) and print the
+                            effective configuration, without running the
+                            server. Exit code is 1 if they are invalid.` + loggingOptions
+
+const validateReplayUsage = `Replay a game recorded with --dump-states through a fresh game logic,
+and check that the game states match the recorded ones.
+
+Usage:
+  netorcai validate-replay <dump-dir> --gl-command=<cmd> [--port=<port-number>]
+           ` + loggingUsage + `
+  netorcai verify-replay <dump-dir> --gl-command=<cmd> [--port=<port-number>]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --gl-command=<cmd>        The shell command that runs the game logic.` +
+	loggingOptions
+
+const replayUsage = `Play a game recorded with --dump-states back to visualizations.
+The replay starts once --nb-visus-max visualizations are logged in. They
+receive the recorded game states, one per turn, without any player.
+
+Usage:
+  netorcai replay <dump-dir> [--port=<port-number>] [--nb-visus-max=<nbv>]
+           [--delay-first-turn=<ms>] [--delay-turns=<ms>]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --nb-visus-max=<nbv>      The number of visualizations. [default: 1]
+  --delay-first-turn=<ms>   The amount of time (in milliseconds) between the
+                            GAME_STARTS message and the first TURN message.
+                            [default: 1000]
+  --delay-turns=<ms>        The amount of time (in milliseconds) between two
+                            consecutive TURNs. [default: 1000]` +
+	loggingOptions
+
+const checkClientUsage = `Wait for a client to connect, and check that it logs in and answers a PING.
+No game is run: The client is kicked once checked.
+
+Usage:
+  netorcai check-client [--port=<port-number>] [--timeout=<s>]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --timeout=<s>             How long (in seconds) to wait for the client to
+                            connect. [default: 60]` + loggingOptions
+
+const benchUsage = `Run a game between in-process players, and print the cost of each turn.
+The players are connected without any network socket (loopback clients) and
+answer each TURN right away without any action, so that the costs of the game
+logic, of the serialization and of the game loop are measured independently
+of the network stack and of player bots. The game logic logs in as usual,
+unless it is run or connected by netorcai.
+
+Usage:
+  netorcai bench [--port=<port-number>] [--nb-players=<nbp>]
+           [--nb-turns-max=<nbt>]
+           [--lua-gl=<file> | --wasm-gl=<file> | --connect-gl=<address>]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --nb-players=<nbp>        The number of loopback players. [default: 4]
+  --nb-turns-max=<nbt>      The number of turns. [default: 100]
+  --lua-gl=<file>           Run the Lua script <file> inside netorcai as the
+                            game logic (see netorcai serve --help).
+  --wasm-gl=<file>          Run the WebAssembly (WASI) module <file> inside
+                            netorcai as the game logic
+                            (see netorcai serve --help).
+  --connect-gl=<address>    Connect to a game logic that listens on <address>
+                            (host:port) instead of waiting for it to connect.` +
+	loggingOptions
+
+const tournamentUsage = `Run a tournament, each game being run by a netorcai subprocess.
+
+Usage:
+  netorcai tournament --players=<file> --gl-command=<cmd> [--gl-sha256=<hash>]
+           [--gl-executable=<path>]
+           [--bracket=<format>] [--rounds=<n>] [--rooms=<n>]
+           [--match-timeout=<s>] [--results=<file>] [--report=<file>]
+           [--match-logs=<dir>] [--port=<port-number>] [--nb-turns-max=<nbt>]
+           [--cpu-limit=<s>] [--memory-limit=<mib>] [--sandbox]
+           [--signing-key=<file>]
+           [--delay-first-turn=<ms>] [--delay-turns=<ms>] [--fast]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --players=<file>          The tournament players file. Each line contains a
+                            player name followed by the shell command that
+                            runs the player. The player must log in with its
+                            name as nickname.
+  --bracket=<format>        The tournament format. Accepted values:
+                            single-elim swiss. [default: single-elim]
+  --rounds=<n>              The number of rounds of a Swiss tournament.
+                            By default, enough rounds to separate a single
+                            winner.
+  --results=<file>          Write the results of the tournament matches into
+                            <file> (JSON).
+  --report=<file>           Write the aggregate statistics of the tournament
+                            players (win rate, latency, crashes...) into
+                            <file> (JSON).
+  --signing-key=<file>      Sign the results and report files with the key
+                            in <file> (see netorcai new-signing-key).` +
+	roomsOptions + matchOptions + sandboxOption + gameOptions + loggingOptions
+
+const lobbyUsage = `Accept players in a lobby, and start a game room whenever enough
+players wait.
+
+Usage:
+  netorcai lobby --gl-command=<cmd> [--gl-sha256=<hash>] [--port=<port-number>]
+           [--gl-executable=<path>]
+           [--nb-players-max=<nbp>] [--rooms=<n>] [--match-timeout=<s>]
+           [--match-logs=<dir>] [--nb-turns-max=<nbt>]
+           [--cpu-limit=<s>] [--memory-limit=<mib>]
+           [--delay-first-turn=<ms>] [--delay-turns=<ms>] [--fast]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --nb-players-max=<nbp>    The number of players of each game. [default: 4]` +
+	roomsOptions + matchOptions + gameOptions + loggingOptions
+
+const schedulerUsage = `Serve a match scheduling API, and run the enqueued matches.
+
+Usage:
+  netorcai scheduler --admin-port=<port-number> --control-token-file=<file>
+           [--admin-address=<address>] [--accounts=<file>]
+           [--port=<port-number>]
+           [--match-timeout=<s>] [--match-logs=<dir>]
+           [--cpu-limit=<s>] [--memory-limit=<mib>] [--sandbox]
+           ` + loggingUsage + `
+
+Options:` + portOption + `
+  --admin-port=<port-number>  The TCP port to serve the match scheduling API
+                            (/matches and /bots) on.
+  --admin-address=<address>  The address the match scheduling API is served
+                            on. [default: 127.0.0.1]
+  --control-token-file=<file>  The file that contains the secret token of
+                            the match scheduling API. Requests must carry it
+                            (Authorization: Bearer <token>), as matches run
+                            commands.
+  --accounts=<file>         The player accounts file. If set, bots are
+                            registered with the API key of the account named
+                            as the bot, and can then change their address.
+                            Otherwise, a bot cannot be registered again.` +
+	matchOptions + sandboxOption + loggingOptions
+
+const addAccountUsage = `Create a player account and print its API key.
+
+Usage:
+  netorcai add-account <account-name> --accounts=<file>
+           ` + loggingUsage + `
+
+Options:
+  --accounts=<file>         The player accounts file.` + loggingOptions
+
+const newSigningKeyUsage = `Create a key that signs the replays (--dump-states) and the tournament
+results written by netorcai. The signature of each file is written next to
+it (suffixed by .sig).
+
+Usage:
+  netorcai new-signing-key <key-file> [--hmac] [--public-key=<file>]
+           ` + loggingUsage + `
+
+Options:
+  --hmac                    Create an HMAC (SHA-256) secret instead of an
+                            Ed25519 key. HMAC signatures can only be verified
+                            by those who know the secret.
+  --public-key=<file>       Also write the public part of the Ed25519 key
+                            into <file>, to be given to anyone who wants to
+                            verify signatures.` + loggingOptions
+
+const verifySignaturesUsage = `Check that signed files match their signatures.
+A --dump-states directory is checked against its signed manifest: It must
+contain exactly the files listed by the manifest, unmodified.
+
+Usage:
+  netorcai verify-signatures <key-file> <file>...
+           ` + loggingUsage + `
+
+Options:` + loggingOptions
+
+const doctorUsage = `Check that the runtime environment is suitable for netorcai.
+
+Usage:
+  netorcai doctor [--port=<port-number>]
+
+Options:` + portOption
+
+const versionUsage = `Print version and build information.
+
+Usage:
+  netorcai version [--json]
+
+Options:
+  --json                    Print the version and build information
+                            (metaprotocol version, git commit, capabilities)
+                            in JSON.`
+
+const schemaUsage = `Print the JSON Schema (draft-07) of the metaprotocol messages.
+
+Usage:
+  netorcai schema [<message-type>]
+
+Without <message-type>, the schema of all message types is printed
+(one definition per message type).`
+
+// The usage of each subcommand. Deprecated names are kept as aliases.
+var commandUsages = map[string]string{
+	"serve":             serveUsage,
+	"replay":            replayUsage,
+	"validate-replay":   validateReplayUsage,
+	"check-client":      checkClientUsage,
+	"bench":             benchUsage,
+	"verify-replay":     validateReplayUsage,
+	"tournament":        tournamentUsage,
+	"lobby":             lobbyUsage,
+	"scheduler":         schedulerUsage,
+	"add-account":       addAccountUsage,
+	"new-signing-key":   newSigningKeyUsage,
+	"verify-signatures": verifySignaturesUsage,
+	"doctor":            doctorUsage,
+	"version":           versionUsage,
+	"schema":            schemaUsage,
+}
+
+// Returns the subcommand of the command-line arguments, and its usage.
+func commandOf(args []string) (string, string) {
+	if len(args) > 0 {
+		if usage, exists := commandUsages[args[0]]; exists {
+			return args[0], usage
+		}
+	}
+	return "serve", serveUsage
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"strings"
+)
+
+var (
+	// Set at build time (see the Makefile)
+	commit string
+)
+
+type versionInfo struct {
+	Version             string   `json:"version"`
+	MetaprotocolVersion string   `json:"metaprotocol_version"`
+	GitCommit           string   `json:"git_commit"`
+	Capabilities        []string `json:"capabilities"`
+}
+
+// Returns the capabilities of this netorcai build: The metaprotocol ones,
+// and the embedded game logics and JSON backends it has been built with.
+func buildCapabilities() []string {
+	capabilities := append([]string(nil), netorcai.Capabilities...)
+	if luaSupported {
+		capabilities = append(capabilities, "lua-gl")
+	}
+	if wasmSupported {
+		capabilities = append(capabilities, "wasm-gl")
+	}
+	for _, backend := range netorcai.JSONBackends() {
+		if backend != "std" {
+			capabilities = append(capabilities, "json-"+backend)
+		}
+	}
+	return capabilities
+}
+
+// Prints the version and build information, as JSON if asJSON is set.
+func runVersion(netorcaiVersion string, asJSON bool) int {
+	info := versionInfo{
+		Version:             netorcaiVersion,
+		MetaprotocolVersion: netorcai.Version,
+		GitCommit:           commit,
+		Capabilities:        buildCapabilities(),
+	}
+
+	if asJSON {
+		content, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Printf("Cannot serialize version information: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(content))
+		return 0
+	}
+
+	gitCommit := info.GitCommit
+	if gitCommit == "" {
+		gitCommit = "unknown"
+	}
+	fmt.Printf("version: %v\n", info.Version)
+	fmt.Printf("metaprotocol version: %v\n", info.MetaprotocolVersion)
+	fmt.Printf("git commit: %v\n", gitCommit)
+	fmt.Printf("capabilities: %v\n", strings.Join(info.Capabilities, " "))
+	return 0
+}
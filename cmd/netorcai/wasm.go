@@ -0,0 +1,197 @@
+//go:build wasmgl
+// +build wasmgl
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/gamelogic"
+	log "github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const wasmSupported = true
+
+// Game logic implemented by a WebAssembly (WASI) module, run in-process.
+// The module reads DO_INIT and DO_TURN messages on its standard input
+// (one JSON object per line) and answers with DO_INIT_ACK and DO_TURN_ACK
+// messages on its standard output (one JSON object per line).
+// The module memory is bounded, and the module is stopped (which makes the
+// game logic fail) if it does not answer a message in time.
+type wasmGame struct {
+	stdin       io.WriteCloser
+	answers     chan string
+	exited      chan error
+	turnTimeout time.Duration
+	cancel      context.CancelFunc
+}
+
+// Size of a WebAssembly memory page
+const wasmPageBytes = 65536
+
+func newWasmGame(filename string, memoryMaxMiB int,
+	turnTimeout time.Duration) (gamelogic.Game, error) {
+	code, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read WASM game logic: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wasmRuntime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memoryMaxMiB*1024*1024/wasmPageBytes)).
+		WithCloseOnContextDone(true))
+	wasi_snapshot_preview1.MustInstantiate(ctx, wasmRuntime)
+
+	compiled, err := wasmRuntime.CompileModule(ctx, code)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("Cannot compile WASM game logic: %v", err)
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	game := &wasmGame{
+		stdin:       stdinWriter,
+		answers:     make(chan string),
+		exited:      make(chan error, 1),
+		turnTimeout: turnTimeout,
+		cancel:      cancel,
+	}
+
+	// The module runs its main function until its standard input is closed
+	go func() {
+		_, err := wasmRuntime.InstantiateModule(ctx, compiled,
+			wazero.NewModuleConfig().
+				WithName("game logic").
+				WithStdin(stdinReader).
+				WithStdout(stdoutWriter).
+				WithStderr(os.Stderr))
+		stdoutWriter.Close()
+		wasmRuntime.Close(context.Background())
+		game.exited <- err
+	}()
+
+	go func() {
+		reader := bufio.NewReader(stdoutReader)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(game.answers)
+				return
+			}
+			game.answers <- line
+		}
+	}()
+
+	return game, nil
+}
+
+func (g *wasmGame) Init(nbPlayers, nbSpecialPlayers,
+	nbTurnsMax int) gamelogic.State {
+	answer := g.request(map[string]interface{}{
+		"message_type":       "DO_INIT",
+		"nb_players":         nbPlayers,
+		"nb_special_players": nbSpecialPlayers,
+		"nb_turns_max":       nbTurnsMax,
+	})
+
+	return g.readState(answer, "initial_game_state")
+}
+
+func (g *wasmGame) Turn(actions []gamelogic.PlayerActions) (gamelogic.State,
+	gamelogic.Winner) {
+	playerActions := make([]map[string]interface{}, 0, len(actions))
+	for _, action := range actions {
+		playerActions = append(playerActions, map[string]interface{}{
+			"player_id":   action.PlayerID,
+			"role":        action.Role,
+			"turn_number": action.TurnNumber,
+			"actions":     action.Actions,
+		})
+	}
+
+	answer := g.request(map[string]interface{}{
+		"message_type":   "DO_TURN",
+		"player_actions": playerActions,
+	})
+
+	winner, err := netorcai.ReadInt(answer, "winner_player_id")
+	if err != nil {
+		g.fail(fmt.Sprintf("Invalid DO_TURN_ACK. %v", err))
+	}
+	return g.readState(answer, "game_state"), gamelogic.Winner(winner)
+}
+
+// Sends a message to the module and waits for its answer.
+// Panics (the game logic then fails) if the module does not answer in time.
+func (g *wasmGame) request(msg map[string]interface{}) map[string]interface{} {
+	content, err := json.Marshal(msg)
+	if err != nil {
+		g.fail(fmt.Sprintf("Cannot serialize message. %v", err))
+	}
+
+	// Writing blocks until the module reads its standard input
+	written := make(chan error, 1)
+	go func() {
+		_, err := g.stdin.Write(append(content, '\n'))
+		written <- err
+	}()
+
+	timeout := time.After(g.turnTimeout)
+	select {
+	case err = <-written:
+		if err != nil {
+			g.fail(fmt.Sprintf("Cannot write to the module. %v", err))
+		}
+	case <-timeout:
+		g.fail(fmt.Sprintf("Module did not read its input within %v", g.turnTimeout))
+	}
+
+	select {
+	case line, ok := <-g.answers:
+		if !ok {
+			g.fail(fmt.Sprintf("Module exited. %v", <-g.exited))
+		}
+
+		var answer map[string]interface{}
+		err = json.Unmarshal([]byte(line), &answer)
+		if err != nil {
+			g.fail("Module answer is not a JSON object")
+		}
+		return answer
+	case <-timeout:
+		g.fail(fmt.Sprintf("Module did not answer within %v", g.turnTimeout))
+	}
+	return nil
+}
+
+func (g *wasmGame) readState(answer map[string]interface{},
+	field string) gamelogic.State {
+	gameState, err := netorcai.ReadObject(answer, field)
+	if err == nil {
+		gameState, err = netorcai.ReadObject(gameState, "all_clients")
+	}
+	if err != nil {
+		g.fail(fmt.Sprintf("Invalid answer. %v", err))
+	}
+	return gamelogic.State(gameState)
+}
+
+// Stops the module and makes the game logic fail.
+func (g *wasmGame) fail(reason string) {
+	log.WithFields(log.Fields{
+		"reason": reason,
+	}).Warn("Stopping WASM game logic")
+	g.cancel()
+	g.stdin.Close()
+	panic("WASM game logic failed: " + reason)
+}
@@ -0,0 +1,18 @@
+//go:build !wasmgl
+// +build !wasmgl
+
+package main
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai/gamelogic"
+	"time"
+)
+
+const wasmSupported = false
+
+func newWasmGame(filename string, memoryMaxMiB int,
+	turnTimeout time.Duration) (gamelogic.Game, error) {
+	return nil, fmt.Errorf("netorcai has been built without WebAssembly " +
+		"support (build it with -tags wasmgl)")
+}
@@ -1,12 +1,14 @@
 package netorcai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/mpoquet/go-prompt"
 	log "github.com/sirupsen/logrus"
 	"net"
 	"sync"
+	"time"
 )
 
 // Game state
@@ -29,8 +31,13 @@ type GlobalState struct {
 	Mutex     sync.Mutex
 	WaitGroup sync.WaitGroup
 
-	Listener net.Listener
-	prompt   *prompt.Prompt
+	Listeners []net.Listener
+	Listening bool
+	prompt    *prompt.Prompt
+	// Set by RunServer, cancelled to shut netorcai down
+	ctx context.Context
+	// Closed once RunServer listens incoming connections
+	listeningDone chan int
 
 	GameState int
 
@@ -44,9 +51,93 @@ type GlobalState struct {
 	NbVisusMax                  int
 	NbTurnsMax                  int
 	Autostart                   bool
+	AutostartCheck              bool
 	Fast                        bool
+	EchoActionsToVisus          bool
+	AnonymizePlayers            bool
+	PublicVisuDelay             int
 	MillisecondsBeforeFirstTurn float64
 	MillisecondsBetweenTurns    float64
+	MillisecondsBetweenTurnsMin float64
+	AdaptiveDelay               bool
+	// Number of COUNTDOWN messages sent (one per second) before the game
+	// starts (0: the game starts right away)
+	Countdown int
+	// Maximum wait for the GAME_STARTS_ACK of players before the first
+	// turn (0: it is not waited for)
+	MillisecondsGameStartsAckTimeout float64
+	DumpStatesDirectory              string
+	LogStateDiffs                    bool
+	LogTurns                         bool
+	LintGameState                    bool
+	ReportEncoding                   bool
+	MaxArrayLength                   int
+	MaxStateBytes                    int
+	AbortOnStateTooBig               bool
+	NbAcceptors                      int
+	ReusePort                        bool
+	MaxPendingLogins                 int
+	NbBroadcastWorkers               int
+	HookCommand                      string
+	Accounts                         *Accounts
+	GameLogicHotSwap                 bool
+	// Maximum wait for the LOGIN of the clients counted by MaxPendingLogins
+	// (0: defaultPendingLoginTimeout)
+	PendingLoginTimeout time.Duration
+	// Whether a player that logs in with the account of a logged in player
+	// replaces it (instead of being denied)
+	DuplicateLoginReplace bool
+	// Aborts hung games (0 disables the watchdog), or forces them to advance
+	// if they wait for players and WatchdogAdvance is set
+	WatchdogTimeout time.Duration
+	WatchdogAdvance bool
+	// Whether players that have not acknowledged a TURN still receive the
+	// next ones, rather than the latest one once they acknowledge
+	StalePlayersSendAll bool
+	// Number of past turns kept for the visualizations that log in while
+	// the game is running (0: they are not sent the running game)
+	TurnHistory int
+	// Minimum time between two TURN messages sent to each player, special
+	// player or visualization (0: unlimited). Excess turns are coalesced.
+	MinTurnIntervalPlayers        time.Duration
+	MinTurnIntervalSpecialPlayers time.Duration
+	MinTurnIntervalVisus          time.Duration
+	// The order of the player actions in DO_TURN
+	ActionOrder ActionOrder
+	// Whether the game logic is not invoked on turns without actions
+	SkipIdleTurns bool
+	// Handed to the game logic in DO_INIT (nil if none)
+	Seed *int64
+	// Signs the --dump-states files (nil if they are not signed)
+	SigningKey *SigningKey
+
+	Breakpoints map[int]bool
+	Paused      bool
+	// The reason of the operator, once the game has been aborted
+	AbortReason string
+
+	// Milliseconds added once to the turn timer (extend-turn command)
+	turnExtension float64
+
+	// Number of logins of each player nickname
+	playerLogins map[string]int
+
+	// Bounds concurrent TURN writes (nil if unbounded)
+	turnWriters *turnWritePool
+
+	// Whether clients are being checked before the automatic start
+	// (--autostart-check), so that a single check runs at a time
+	autostartChecking bool
+}
+
+// Returns the context given to RunServer (or a never cancelled one if the
+// server has not been run, e.g. with loopback clients only).
+// The global state mutex must be held.
+func serverContext(gs *GlobalState) context.Context {
+	if gs.ctx == nil {
+		return context.Background()
+	}
+	return gs.ctx
 }
 
 // Debugging helpers
@@ -89,6 +180,21 @@ func areAllExpectedClientsConnected(gs *GlobalState) bool {
 
 func autostart(gs *GlobalState) {
 	if gs.Autostart && areAllExpectedClientsConnected(gs) {
+		if gs.AutostartCheck {
+			LockGlobalStateMutex(gs, "Autostart check", "Login manager")
+			checking := gs.autostartChecking
+			gs.autostartChecking = true
+			UnlockGlobalStateMutex(gs, "Autostart check", "Login manager")
+			if checking {
+				log.Debug("Clients are already being checked")
+				return
+			}
+
+			log.Info("Automatic starting conditions are met. Checking clients")
+			go autostartAfterCheck(gs)
+			return
+		}
+
 		log.Info("Automatic starting conditions are met")
 		gs.GameState = GAME_RUNNING
 		gs.GameLogic[0].start <- 1
@@ -103,20 +209,19 @@ func handleClient(client *Client, globalState *GlobalState,
 
 	defer globalState.WaitGroup.Done()
 	defer client.Conn.Close()
-	// This is to send a shutdown on the socket before closing it.
-	// Combined with a SO_LINGER<0 (default for go sockets),
-	// this should avoid loss of data sent by netorcai on client sockets.
-	defer client.Conn.(*net.TCPConn).CloseWrite()
+	defer shutdownConnection(client.Conn)
 
+	client.maxArrayLength = globalState.MaxArrayLength
 	go readClientMessages(client)
 
 	msg := <-client.incomingMessages
+	releasePendingLogin(client)
 	if msg.err != nil {
 		log.WithFields(log.Fields{
 			"err":            msg.err,
 			"remote address": client.Conn.RemoteAddr(),
 		}).Debug("Cannot receive client first message")
-		Kick(client, fmt.Sprintf("Invalid first message: %v", msg.err.Error()))
+		Kick(client, msg.errCode, fmt.Sprintf("Invalid first message: %v", msg.err.Error()))
 		return
 	}
 
@@ -126,7 +231,8 @@ func handleClient(client *Client, globalState *GlobalState,
 			"err":            err,
 			"remote address": client.Conn.RemoteAddr(),
 		}).Debug("Cannot read LOGIN message")
-		Kick(client, fmt.Sprintf("Invalid first message: %v", err.Error()))
+		sendError(client, err)
+		Kick(client, KICK_INVALID_LOGIN, fmt.Sprintf("Invalid first message: %v", err.Error()))
 		return
 	}
 	client.nickname = loginMessage.nickname
@@ -137,38 +243,71 @@ func handleClient(client *Client, globalState *GlobalState,
 		isSpecial := loginMessage.role == "special player"
 		if globalState.GameState != GAME_NOT_RUNNING {
 			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-			Kick(client, "LOGIN denied: Game has been started")
-		} else if !isSpecial && len(globalState.Players) >= globalState.NbPlayersMax {
+			Kick(client, KICK_GAME_STARTED, "LOGIN denied: Game has been started")
+		} else if globalState.Accounts != nil && loginMessage.apiKey == "" {
+			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
+			Kick(client, KICK_LOGIN_DENIED_AUTH, "LOGIN denied: An API key is required")
+		} else if previous, accepted := logInAccount(globalState, client,
+			loginMessage.apiKey); !accepted {
 			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-			Kick(client, "LOGIN denied: Maximum number of players reached")
-		} else if isSpecial && len(globalState.SpecialPlayers) >= globalState.NbSpecialPlayersMax {
+			Kick(client, KICK_LOGIN_DENIED_AUTH, "LOGIN denied: Unknown API key, or account already logged in")
+		} else if !isSpecial && len(globalState.Players)-
+			previous.seatIn(globalState.Players) >= globalState.NbPlayersMax {
 			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-			Kick(client, "LOGIN denied: Maximum number of special players reached")
+			Kick(client, KICK_LOGIN_DENIED_FULL, "LOGIN denied: Maximum number of players reached")
+		} else if isSpecial && len(globalState.SpecialPlayers)-
+			previous.seatIn(globalState.SpecialPlayers) >=
+			globalState.NbSpecialPlayersMax {
+			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
+			Kick(client, KICK_LOGIN_DENIED_FULL, "LOGIN denied: Maximum number of special players reached")
 		} else {
-			err = sendLoginACK(client)
+			err = sendLoginACK(client, negotiateCapabilities(loginMessage))
 			if err != nil {
 				UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-				Kick(client, "LOGIN denied: Could not send LOGIN_ACK")
+				Kick(client, KICK_NETWORK_ERROR, "LOGIN denied: Could not send LOGIN_ACK")
 			} else {
 				pvClient := &PlayerOrVisuClient{
 					client:          client,
 					playerID:        -1,
 					isPlayer:        true,
 					isSpecialPlayer: isSpecial,
+					lobby:           make(chan MessageLobby, 1),
+					adminMessages:   make(chan MessageAdmin, adminMessageBufferSize),
+					countdown:       make(chan MessageCountdown, 1),
 					gameStarts:      make(chan MessageGameStarts),
 					newTurn:         make(chan MessageTurn, 100),
 					gameEnds:        make(chan MessageGameEnds, 1),
 					playerInfo:      nil,
+					sendAllTurns:    globalState.StalePlayersSendAll,
+					unackedTurns:    make(map[int]time.Time),
+				}
+				pvClient.minTurnInterval = globalState.MinTurnIntervalPlayers
+				if isSpecial {
+					pvClient.minTurnInterval = globalState.MinTurnIntervalSpecialPlayers
 				}
 
+				if globalState.playerLogins == nil {
+					globalState.playerLogins = make(map[string]int)
+				}
+				nbLogins := globalState.playerLogins[client.nickname]
+				client.updateStats(func(stats *ClientProtocolStats) {
+					stats.Reconnects = nbLogins
+				})
+				globalState.playerLogins[client.nickname]++
+
+				// The new login is accepted: It takes the seat of the
+				// previous login of the account, if any
+				replacePlayer(globalState, previous)
 				if !isSpecial {
 					globalState.Players = append(globalState.Players, pvClient)
 				} else {
 					globalState.SpecialPlayers = append(globalState.SpecialPlayers, pvClient)
 				}
+				broadcastLobby(globalState)
 
 				log.WithFields(log.Fields{
 					"nickname":             client.nickname,
+					"account":              client.account,
 					"remote address":       client.Conn.RemoteAddr(),
 					"player count":         len(globalState.Players),
 					"special player count": len(globalState.SpecialPlayers),
@@ -188,28 +327,46 @@ func handleClient(client *Client, globalState *GlobalState,
 	case "visualization":
 		if len(globalState.Visus) >= globalState.NbVisusMax {
 			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-			Kick(client, "LOGIN denied: Maximum number of visus reached")
+			Kick(client, KICK_LOGIN_DENIED_FULL, "LOGIN denied: Maximum number of visus reached")
 		} else {
-			err = sendLoginACK(client)
+			err = sendLoginACK(client, negotiateCapabilities(loginMessage))
 			if err != nil {
 				UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-				Kick(client, "LOGIN denied: Could not send LOGIN_ACK")
+				Kick(client, KICK_NETWORK_ERROR, "LOGIN denied: Could not send LOGIN_ACK")
 			} else {
 				pvClient := &PlayerOrVisuClient{
 					client:     client,
 					playerID:   -1,
 					isPlayer:   false,
+					isLiveVisu: loginMessage.visuTier == "live",
+					lobby:      make(chan MessageLobby, 1),
+					adminMessages: make(chan MessageAdmin,
+						adminMessageBufferSize),
+					countdown:  make(chan MessageCountdown, 1),
 					gameStarts: make(chan MessageGameStarts),
 					newTurn:    make(chan MessageTurn, 100),
 					gameEnds:   make(chan MessageGameEnds, 1),
 				}
+				pvClient.minTurnInterval = globalState.MinTurnIntervalVisus
 
 				globalState.Visus = append(globalState.Visus, pvClient)
+				broadcastLobby(globalState)
+				if globalState.GameState == GAME_RUNNING &&
+					len(globalState.GameLogic) > 0 {
+					globalState.GameLogic[0].visuChanged(client.nickname, true)
+				}
+				if globalState.GameState == GAME_RUNNING &&
+					globalTurnHistory.acceptsVisus() {
+					// Late visualizations are sent the past turns kept
+					pvClient.lateGameStarts, pvClient.pastTurns =
+						globalTurnHistory.join(pvClient)
+				}
 
 				log.WithFields(log.Fields{
 					"nickname":       client.nickname,
 					"remote address": client.Conn.RemoteAddr(),
 					"visu count":     len(globalState.Visus),
+					"tier":           loginMessage.visuTier,
 				}).Info("New visualization accepted")
 				client.state = CLIENT_LOGGED
 
@@ -225,24 +382,33 @@ func handleClient(client *Client, globalState *GlobalState,
 	case "game logic":
 		if globalState.GameState != GAME_NOT_RUNNING {
 			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-			Kick(client, "LOGIN denied: Game has been started")
-		} else if len(globalState.GameLogic) >= 1 {
+			Kick(client, KICK_GAME_STARTED, "LOGIN denied: Game has been started")
+		} else if len(globalState.GameLogic) >= 1 && !globalState.GameLogicHotSwap {
 			UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-			Kick(client, "LOGIN denied: A game logic is already logged in")
+			Kick(client, KICK_LOGIN_DENIED_FULL, "LOGIN denied: A game logic is already logged in")
 		} else {
-			err = sendLoginACK(client)
+			err = sendLoginACK(client, negotiateCapabilities(loginMessage))
 			if err != nil {
 				UnlockGlobalStateMutex(globalState, "New client", "Login manager")
-				Kick(client, "LOGIN denied: Could not send LOGIN_ACK")
+				Kick(client, KICK_NETWORK_ERROR, "LOGIN denied: Could not send LOGIN_ACK")
 			} else {
+				// With hot swap, the new game logic replaces the current one
+				replaceGameLogic(globalState)
+
 				glClient := &GameLogicClient{
 					client:             client,
 					playerAction:       make(chan MessageDoTurnPlayerAction, 1),
 					playerDisconnected: make(chan int, 1),
 					start:              make(chan int, 1),
+					resume:             make(chan int, 1),
+					delayChanged:       make(chan int, 1),
+					stop:               make(chan int, 1),
+					abort:              make(chan string, 1),
+					forceAdvance:       make(chan int, 1),
 				}
 
 				globalState.GameLogic = append(globalState.GameLogic, glClient)
+				broadcastLobby(globalState)
 
 				log.WithFields(log.Fields{
 					"nickname":       client.nickname,
@@ -261,7 +427,114 @@ func handleClient(client *Client, globalState *GlobalState,
 	}
 }
 
-func Kick(client *Client, reason string) {
+// Sets the account of a player from its API key. Fails if the key is unknown.
+// If the account is already used by a logged in player, either the login
+// fails or (with --duplicate-login=replace) the previous player is returned:
+// Once the new login is accepted, the previous player must be kicked by
+// replacePlayer and leave its seat to the new one, so that two instances of
+// a bot never play for the same account.
+// Returns whether the login can go on.
+// The global state mutex must be held.
+func logInAccount(gs *GlobalState, client *Client,
+	apiKey string) (*PlayerOrVisuClient, bool) {
+	if gs.Accounts == nil {
+		return nil, true
+	}
+	account, exists := gs.Accounts.lookup(apiKey)
+	if !exists {
+		return nil, false
+	}
+
+	for _, players := range [][]*PlayerOrVisuClient{gs.Players, gs.SpecialPlayers} {
+		for _, player := range players {
+			if player.client.account != account {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"account":                 account,
+				"remote address":          client.Conn.RemoteAddr(),
+				"previous remote address": player.client.Conn.RemoteAddr(),
+				"replace":                 gs.DuplicateLoginReplace,
+			}).Warn("Account is already logged in")
+			if !gs.DuplicateLoginReplace {
+				return nil, false
+			}
+			client.account = account
+			return player, true
+		}
+	}
+
+	client.account = account
+	return nil, true
+}
+
+// Returns 1 if the player has a seat in players, 0 otherwise (or if nil).
+func (player *PlayerOrVisuClient) seatIn(players []*PlayerOrVisuClient) int {
+	if player == nil {
+		return 0
+	}
+	for _, p := range players {
+		if p == player {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Kicks the previous login of an account (nothing if nil), whose seat is
+// taken by a new login. The global state mutex must be held.
+func replacePlayer(gs *GlobalState, previous *PlayerOrVisuClient) {
+	if previous == nil {
+		return
+	}
+
+	for _, players := range []*[]*PlayerOrVisuClient{&gs.Players, &gs.SpecialPlayers} {
+		for index, player := range *players {
+			if player == previous {
+				*players = append((*players)[:index], (*players)[index+1:]...)
+				break
+			}
+		}
+	}
+
+	// The client goroutine may wait for the global state mutex, or already
+	// have a kick order pending: The order is sent without blocking the
+	// login, and is never dropped
+	order := kickOrder{KICK_REPLACED, "Replaced by a new login of the same account"}
+	ctx := serverContext(gs)
+	go func() {
+		select {
+		case previous.client.canTerminate <- order:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Kicks the current game logic (if any), whose place is taken by a newly
+// accepted one. The global state mutex must be held.
+func replaceGameLogic(gs *GlobalState) {
+	ctx := serverContext(gs)
+	for _, previous := range gs.GameLogic {
+		log.WithFields(log.Fields{
+			"nickname":       previous.client.nickname,
+			"remote address": previous.client.Conn.RemoteAddr(),
+		}).Info("Replacing game logic")
+
+		// As in replacePlayer, a pending order must not block the login
+		order := kickOrder{KICK_REPLACED, "Replaced by a new game logic"}
+		canTerminate := previous.client.canTerminate
+		go func() {
+			select {
+			case canTerminate <- order:
+			case <-ctx.Done():
+			}
+		}()
+	}
+	gs.GameLogic = gs.GameLogic[:0]
+}
+
+func Kick(client *Client, code KickCode, reason string) {
 	if client.state == CLIENT_KICKED {
 		return
 	}
@@ -271,11 +544,13 @@ func Kick(client *Client, reason string) {
 		"remote address": client.Conn.RemoteAddr(),
 		"nickname":       client.nickname,
 		"reason":         reason,
+		"kick code":      code,
 	}).Warn("Kicking client")
 
 	msg := MessageKick{
 		MessageType: "KICK",
 		KickReason:  reason,
+		KickCode:    code,
 	}
 
 	content, err := json.Marshal(msg)
@@ -284,23 +559,53 @@ func Kick(client *Client, reason string) {
 	}
 }
 
-func sendLoginACK(client *Client) error {
+// Tells a client why one of its messages is invalid, before kicking it.
+func sendError(client *Client, err error) {
+	if client.state == CLIENT_KICKED {
+		return
+	}
+
+	msg := MessageError{
+		MessageType:  "ERROR",
+		ErrorMessage: err.Error(),
+	}
+
+	if fieldErr, isFieldError := err.(*FieldError); isFieldError {
+		msg.FieldPath = fieldErr.Path
+		msg.Expected = fieldErr.Expected
+		msg.ReceivedValue = fieldErr.Received
+	}
+
+	content, err := json.Marshal(msg)
+	if err == nil {
+		_ = sendMessage(client, content)
+	}
+}
+
+// Sends LOGIN_ACK (always as a v1 frame). The next messages are sent with
+// the capabilities negotiated at LOGIN.
+func sendLoginACK(client *Client, capabilities clientCapabilities) error {
 	msg := MessageLoginAck{
 		MessageType:         "LOGIN_ACK",
 		MetaprotocolVersion: Version,
+		Framing:             capabilities.framing,
 	}
 
 	content, err := json.Marshal(msg)
 	if err == nil {
 		err = sendMessage(client, content)
 	}
+	if err == nil {
+		client.capabilities = capabilities
+	}
 	return err
 }
 
 func Cleanup() {
 	LockGlobalStateMutex(globalGS, "Cleanup", "Main")
 	log.Warn("Closing listening socket.")
-	globalGS.Listener.Close()
+	closeListeners(globalGS.Listeners)
+	globalGS.Listening = false
 
 	nonGlClients := append([]*PlayerOrVisuClient(nil), globalGS.Players...)
 	nonGlClients = append(nonGlClients, globalGS.SpecialPlayers...)
@@ -313,14 +618,14 @@ func Cleanup() {
 		kickChan := make(chan int)
 		for _, client := range nonGlClients {
 			go func(c *Client) {
-				c.canTerminate <- "netorcai abort"
+				c.canTerminate <- kickOrder{KICK_SHUTDOWN, "netorcai abort"}
 				kickChan <- 0
 			}(client.client)
 		}
 
 		for _, client := range globalGS.GameLogic {
 			go func(c *Client) {
-				c.canTerminate <- "netorcai abort"
+				c.canTerminate <- kickOrder{KICK_SHUTDOWN, "netorcai abort"}
 				kickChan <- 0
 			}(client.client)
 		}
@@ -330,6 +635,9 @@ func Cleanup() {
 		}
 	}
 
+	// Let HTTP spectators receive the end of the game
+	globalTurnStream.close(time.Second)
+
 	if globalGS.prompt != nil {
 		log.Warn("Cleaning prompt state.")
 		globalGS.prompt.TearDown()
@@ -1,14 +1,24 @@
 package netorcai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"math"
 	"math/rand"
 	"sort"
+	"sync"
 	"time"
 )
 
+const (
+	// Number of recent turns considered to adapt the delay between turns
+	adaptiveDelayNbTurns = 5
+	// Margin kept over the latency of the slowest player
+	adaptiveDelayMargin = 1.25
+)
+
 type GameLogicClient struct {
 	client *Client
 	// Messages to aggregate from player clients
@@ -16,6 +26,67 @@ type GameLogicClient struct {
 	// Control messages
 	start              chan int
 	playerDisconnected chan int
+	resume             chan int
+	delayChanged       chan int
+	// Makes the next DO_TURN the last one (see StopGame)
+	stop chan int
+	// Ends the game right away, with the reason of the operator (see AbortGame)
+	abort chan string
+	// Stops waiting for the TURN_ACK of players (see runGameWatchdog)
+	forceAdvance chan int
+	// Cancelled when netorcai shuts down
+	ctx context.Context
+	// Runs --hook-command on game lifecycle events
+	hooks hookRunner
+	// Game state size limit (0 if unlimited)
+	maxStateBytes      int
+	echoActionsToVisus bool
+	publicVisuDelay    int
+	logTurns           bool
+	publicVisuTurns    []MessageTurn
+	abortOnStateTooBig bool
+	// Warns about suspicious game states (see lint.go)
+	lintGameState bool
+	// Notifications sent right before the next DO_TURN: The players that
+	// left the running game, and the visualizations that joined or left
+	// (nil if none did) since the previous DO_TURN
+	notificationsMutex sync.Mutex
+	departures         []MessagePlayerLeft
+	visusChanged       *MessageVisusChanged
+	nbVisus            int
+	// Players whose GAME_STARTS_ACK is waited for before the first DO_TURN
+	// (nil if it is not waited for), and for how long
+	gameStartsAcks       *gameStartsBarrier
+	gameStartsAckTimeout time.Duration
+	// The number of turns, once changed during the game (0 otherwise)
+	nbTurnsMax int
+	// Whether the last DO_TURN sent has been flagged as the last one
+	lastTurn bool
+	// Debugging information
+	lastPlayerActions []MessageDoTurnPlayerAction
+	lastDepartures    []MessagePlayerLeft
+	lastGameState     map[string]interface{}
+	// The JSON type of every field of the last game state, by path
+	lastStateTypes map[string]string
+	// Declared by the game logic in DO_INIT_ACK (nil if none)
+	gameStateSchema map[string]interface{}
+	// The order of the player actions in DO_TURN (see action_order.go)
+	actionOrder    ActionOrder
+	actionShuffler *rand.Rand
+	// Whether turns without actions skip the game logic (see idle_turns.go),
+	// and whether the last DO_TURN has been skipped this way.
+	// As the turn state above, only used by the GL coroutine (see sendDoTurn)
+	skipIdleTurns bool
+	idleTurn      bool
+	// The winner of the last DO_TURN_ACK (nil before the first one)
+	lastWinnerPlayerID *int
+	// The action deadlines of the current turn (nil if none is set, see
+	// player_deadlines.go), and the players that missed theirs, sent in the
+	// next DO_TURN. Only set by the GL coroutine: Timers wait on their own
+	// reference to the deadlines of their turn
+	deadlines           *playerDeadlines
+	missedDeadlines     []int
+	lastMissedDeadlines []int
 }
 
 func waitGameLogicFinition(glClient *GameLogicClient) {
@@ -24,8 +95,8 @@ func waitGameLogicFinition(glClient *GameLogicClient) {
 	// (making sure that all other clients have been kicked first).
 	for {
 		select {
-		case kickReason := <-glClient.client.canTerminate:
-			Kick(glClient.client, kickReason)
+		case order := <-glClient.client.canTerminate:
+			Kick(glClient.client, order.code, order.reason)
 			return
 		case <-glClient.playerAction:
 		case <-glClient.playerDisconnected:
@@ -34,26 +105,68 @@ func waitGameLogicFinition(glClient *GameLogicClient) {
 	}
 }
 
+// Called when a game logic has been kicked before the game started.
+// netorcai aborts, unless game logic hot swap is enabled: In this case
+// players and visus stay connected while a new game logic is awaited.
+func gameLogicLeftBeforeStart(glClient *GameLogicClient, gs *GlobalState,
+	onexit chan int) {
+	LockGlobalStateMutex(gs, "GL left before start", "GL")
+	if gs.GameLogicHotSwap && gs.GameState == GAME_NOT_RUNNING {
+		for index, gl := range gs.GameLogic {
+			if gl == glClient {
+				gs.GameLogic = append(gs.GameLogic[:index], gs.GameLogic[index+1:]...)
+				break
+			}
+		}
+		broadcastLobby(gs)
+		UnlockGlobalStateMutex(gs, "GL left before start", "GL")
+		log.Warn("Game logic left before the game started. Waiting for a new one")
+		return
+	}
+	UnlockGlobalStateMutex(gs, "GL left before start", "GL")
+
+	onexit <- 1
+	waitGameLogicFinition(glClient)
+}
+
 func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 	onexit chan int) {
 	// Wait for the game to start
-	select {
-	case <-glClient.start:
-		log.Info("Starting game")
-	case kickReason := <-glClient.client.canTerminate:
-		Kick(glClient.client, kickReason)
-		return
-	case msg := <-glClient.client.incomingMessages:
-		LockGlobalStateMutex(globalState, "GL first message", "GL")
-		if msg.err == nil {
-			Kick(glClient.client, "Received a game logic message but the game has not started")
-		} else {
-			Kick(glClient.client, fmt.Sprintf("Game logic error. %v", msg.err.Error()))
+WaitStart:
+	for {
+		select {
+		case <-glClient.start:
+			log.Info("Starting game")
+			if !runCountdown(glClient, globalState) {
+				return
+			}
+			break WaitStart
+		case order := <-glClient.client.canTerminate:
+			Kick(glClient.client, order.code, order.reason)
+			return
+		case pongs := <-glClient.client.ping:
+			err := sendPing(glClient.client, pongs)
+			if err != nil {
+				Kick(glClient.client, KICK_NETWORK_ERROR, fmt.Sprintf("Cannot send PING. %v", err.Error()))
+				gameLogicLeftBeforeStart(glClient, globalState, onexit)
+				return
+			}
+		case msg := <-glClient.client.incomingMessages:
+			if msg.err == nil && isPongMessage(msg.content) {
+				handlePong(glClient.client)
+				continue
+			}
+
+			LockGlobalStateMutex(globalState, "GL first message", "GL")
+			if msg.err == nil {
+				Kick(glClient.client, KICK_PROTOCOL_ERROR, "Received a game logic message but the game has not started")
+			} else {
+				Kick(glClient.client, msg.errCode, fmt.Sprintf("Game logic error. %v", msg.err.Error()))
+			}
+			UnlockGlobalStateMutex(globalState, "GL first message", "GL")
+			gameLogicLeftBeforeStart(glClient, globalState, onexit)
+			return
 		}
-		UnlockGlobalStateMutex(globalState, "GL first message", "GL")
-		onexit <- 1
-		waitGameLogicFinition(glClient)
-		return
 	}
 
 	LockGlobalStateMutex(globalState, "Game init: copy players/visus and game parameters", "GL")
@@ -65,7 +178,44 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 	msBeforeFirstTurn := globalState.MillisecondsBeforeFirstTurn
 	msBetweenTurns := globalState.MillisecondsBetweenTurns
 	fast := globalState.Fast
+	watchdogTimeout := globalState.WatchdogTimeout
+	watchdogAdvance := globalState.WatchdogAdvance
+	serverConfig := currentServerConfig(globalState)
+	seed := globalState.Seed
+	debug := debugOptions{
+		dumpStatesDirectory: globalState.DumpStatesDirectory,
+		logStateDiffs:       globalState.LogStateDiffs,
+		reportEncoding:      globalState.ReportEncoding,
+		signingKey:          globalState.SigningKey,
+		manifest:            newReplayManifest(),
+	}
+	globalState.turnWriters = newTurnWritePool(serverContext(globalState),
+		globalState.NbBroadcastWorkers)
+	glClient.maxStateBytes = globalState.MaxStateBytes
+	glClient.echoActionsToVisus = globalState.EchoActionsToVisus
+	glClient.publicVisuDelay = globalState.PublicVisuDelay
+	globalTurnHistory.reset(globalState.TurnHistory, globalState.PublicVisuDelay)
+	glClient.logTurns = globalState.LogTurns
+	anonymizePlayers := globalState.AnonymizePlayers
+	glClient.abortOnStateTooBig = globalState.AbortOnStateTooBig
+	glClient.lintGameState = globalState.LintGameState
+	glClient.actionOrder = globalState.ActionOrder
+	glClient.actionShuffler = newActionShuffler(globalState.Seed)
+	glClient.skipIdleTurns = globalState.SkipIdleTurns
+	glClient.initVisus(visus)
+	if globalState.MillisecondsGameStartsAckTimeout > 0 {
+		glClient.gameStartsAcks = newGameStartsBarrier()
+		glClient.gameStartsAckTimeout = time.Duration(
+			globalState.MillisecondsGameStartsAckTimeout * float64(time.Millisecond))
+	}
+	glClient.ctx = serverContext(globalState)
+	glClient.hooks = hookRunner{
+		command:             globalState.HookCommand,
+		dumpStatesDirectory: globalState.DumpStatesDirectory,
+		waitGroup:           &globalState.WaitGroup,
+	}
 	UnlockGlobalStateMutex(globalState, "Game init: copy players/visus and game parameters", "GL")
+	defer globalTurnHistory.end()
 
 	// Generate randomized player identifiers
 	initialNbPlayers := len(players)
@@ -79,6 +229,14 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 		player.playerID = playerIDs[playerIndex] + initialNbSpecialPlayers
 	}
 
+	if glClient.gameStartsAcks != nil {
+		expectedPlayerIDs := []int{}
+		for _, player := range allPlayers {
+			expectedPlayerIDs = append(expectedPlayerIDs, player.playerID)
+		}
+		glClient.gameStartsAcks.expect(expectedPlayerIDs)
+	}
+
 	// Generate player information
 	playersInfo := []*PlayerInformation{}
 	for _, player := range allPlayers {
@@ -87,6 +245,8 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 			Nickname:      player.client.nickname,
 			RemoteAddress: player.client.Conn.RemoteAddr().String(),
 			IsConnected:   true,
+			Account:       player.client.account,
+			anonymous:     anonymizePlayers,
 		}
 		player.playerInfo = info
 		playersInfo = append(playersInfo, info)
@@ -98,10 +258,11 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 	})
 
 	// Send DO_INIT
-	err := sendDoInit(glClient, initialNbPlayers, initialNbSpecialPlayers, nbTurnsMax)
+	err := sendDoInit(glClient, initialNbPlayers, initialNbSpecialPlayers,
+		nbTurnsMax, seed)
 
 	if err != nil {
-		Kick(glClient.client, fmt.Sprintf("Cannot send DO_INIT. %v",
+		Kick(glClient.client, KICK_NETWORK_ERROR, fmt.Sprintf("Cannot send DO_INIT. %v",
 			err.Error()))
 		onexit <- 1
 		waitGameLogicFinition(glClient)
@@ -111,19 +272,20 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 	// Wait for first turn (DO_INIT_ACK)
 	var msg ClientMessage
 	select {
-	case kickReason := <-glClient.client.canTerminate:
-		Kick(glClient.client, kickReason)
+	case order := <-glClient.client.canTerminate:
+		Kick(glClient.client, order.code, order.reason)
 		return
 	case msg = <-glClient.client.incomingMessages:
+		lintGameLogicMessage(glClient, -1, msg)
 		if msg.err != nil {
-			Kick(glClient.client,
+			Kick(glClient.client, msg.errCode,
 				fmt.Sprintf("Cannot read DO_INIT_ACK. %v", msg.err.Error()))
 			onexit <- 1
 			waitGameLogicFinition(glClient)
 			return
 		}
 	case <-time.After(3 * time.Second):
-		Kick(glClient.client, "Did not receive DO_INIT_ACK after 3 seconds.")
+		Kick(glClient.client, KICK_TIMEOUT, "Did not receive DO_INIT_ACK after 3 seconds.")
 		onexit <- 1
 		waitGameLogicFinition(glClient)
 		return
@@ -131,13 +293,31 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 
 	doTurnAckMsg, err := readDoInitAckMessage(msg.content)
 	if err != nil {
-		Kick(glClient.client,
+		sendError(glClient.client, err)
+		Kick(glClient.client, KICK_PROTOCOL_ERROR,
 			fmt.Sprintf("Invalid DO_INIT_ACK message. %v", err.Error()))
 		onexit <- 1
 		waitGameLogicFinition(glClient)
 		return
 	}
 
+	err = checkGameStateSize(glClient, -1,
+		serializedSize(doTurnAckMsg.InitialGameState))
+	if err != nil {
+		Kick(glClient.client, KICK_GAME_STATE_TOO_BIG, err.Error())
+		onexit <- 1
+		waitGameLogicFinition(glClient)
+		return
+	}
+
+	logRandomDraws(-1, doTurnAckMsg.RandomDraws)
+	lintGameStateTypes(glClient, -1, doTurnAckMsg.InitialGameState)
+	glClient.gameStateSchema = doTurnAckMsg.GameStateSchema
+	glClient.lastGameState = doTurnAckMsg.InitialGameState
+	dumpGame(debug, initialNbPlayers,
+		initialNbSpecialPlayers, nbTurnsMax, serverConfig, seed,
+		doTurnAckMsg.InitialGameState, doTurnAckMsg.RandomDraws)
+
 	// Send GAME_STARTS to all clients
 	for _, player := range allPlayers {
 		player.gameStarts <- MessageGameStarts{
@@ -150,107 +330,197 @@ func handleGameLogic(glClient *GameLogicClient, globalState *GlobalState,
 			DelayFirstTurn:   msBeforeFirstTurn,
 			DelayTurns:       msBetweenTurns,
 			InitialGameState: doTurnAckMsg.InitialGameState,
+			ServerConfig:     serverConfig,
 		}
 	}
 
+	visuGameStarts := MessageGameStarts{
+		MessageType:      "GAME_STARTS",
+		PlayerID:         -1,
+		PlayersInfo:      playersInfo,
+		NbPlayers:        initialNbPlayers,
+		NbSpecialPlayers: initialNbSpecialPlayers,
+		NbTurnsMax:       nbTurnsMax,
+		DelayFirstTurn:   msBeforeFirstTurn,
+		DelayTurns:       msBetweenTurns,
+		InitialGameState: doTurnAckMsg.InitialGameState,
+		ServerConfig:     serverConfig,
+	}
 	for _, visu := range visus {
-		visu.gameStarts <- MessageGameStarts{
-			MessageType:      "GAME_STARTS",
-			PlayerID:         visu.playerID,
-			PlayersInfo:      playersInfo,
-			NbPlayers:        initialNbPlayers,
-			NbSpecialPlayers: initialNbSpecialPlayers,
-			NbTurnsMax:       nbTurnsMax,
-			DelayFirstTurn:   msBeforeFirstTurn,
-			DelayTurns:       msBetweenTurns,
-			InitialGameState: doTurnAckMsg.InitialGameState,
-		}
+		visu.gameStarts <- visuGameStarts
+	}
+	// Visualizations that logged in since the game init
+	for _, visu := range globalTurnHistory.started(visuGameStarts) {
+		visu.gameStarts <- visuGameStarts
+	}
+
+	glClient.hooks.run("game_starts", hookGameStarts{
+		Event:            "game_starts",
+		NbPlayers:        initialNbPlayers,
+		NbSpecialPlayers: initialNbSpecialPlayers,
+		NbTurnsMax:       nbTurnsMax,
+		PlayersInfo:      playersInfo,
+	})
+
+	if watchdogTimeout > 0 {
+		stopWatchdog := make(chan int)
+		defer close(stopWatchdog)
+		go runGameWatchdog(glClient, watchdogTimeout, watchdogAdvance, onexit,
+			stopWatchdog)
 	}
 
 	if fast {
-		gameLogicGameControlFast(glClient, onexit,
+		gameLogicGameControlFast(glClient, globalState, onexit,
 			initialTotalNbPlayers, nbTurnsMax,
-			allPlayers, visus, playersInfo)
+			allPlayers, visus, playersInfo, debug)
 	} else {
-		gameLogicGameControlTimers(glClient, onexit,
+		gameLogicGameControlTimers(glClient, globalState, onexit,
 			initialTotalNbPlayers, nbTurnsMax,
 			allPlayers, visus, playersInfo,
-			msBeforeFirstTurn, msBetweenTurns)
+			msBeforeFirstTurn, msBetweenTurns, debug)
 	}
 }
 
 func gameLogicGameControlTimers(glClient *GameLogicClient,
-	onexit chan int,
+	globalState *GlobalState, onexit chan int,
 	initialTotalNbPlayers, nbTurnsMax int,
 	allPlayers, visus []*PlayerOrVisuClient,
 	playersInfo []*PlayerInformation,
-	msBeforeFirstTurn, msBetweenTurns float64) {
+	msBeforeFirstTurn, msBetweenTurns float64, debug debugOptions) {
 	// Wait before really starting the game
 	log.WithFields(log.Fields{
 		"duration (ms)": msBeforeFirstTurn,
 	}).Debug("Sleeping before first turn")
-	time.Sleep(time.Duration(msBeforeFirstTurn) * time.Millisecond)
-
-	// Order the game logic to compute a TURN (without any action)
 	turnNumber := 0
 	playerActions := make([]MessageDoTurnPlayerAction, 0)
-	sendDoTurn(glClient, playerActions)
+	// Signaled by the timer of the current turn once DO_TURN should be sent.
+	// DO_TURN is sent from this goroutine, which owns the actions.
+	turnDue := make(chan int, 1)
+	gameStartsSentAt := time.Now()
+	if waitGameStartsAcks(glClient) &&
+		waitDelay(glClient, globalState, gameStartsSentAt,
+			func(gs *GlobalState) float64 {
+				return gs.MillisecondsBeforeFirstTurn
+			}) {
+		// Order the game logic to compute a TURN (without any action)
+		sendDoTurn(glClient, playerActions)
+	}
 
 	for {
+		var doTurnAckMsg MessageDoTurnAck
 		select {
-		case kickReason := <-glClient.client.canTerminate:
-			Kick(glClient.client, kickReason)
+		case order := <-glClient.client.canTerminate:
+			Kick(glClient.client, order.code, order.reason)
+			return
+		case reason := <-glClient.abort:
+			handleGlGameAborted(glClient, onexit, allPlayers, visus,
+				playersInfo, reason, debug)
 			return
 		case action := <-glClient.playerAction:
 			// A client sent its actions.
-			// Replace the current message from this player if it exists,
-			// and place it at the end of the array.
+			// They are dropped if they do not meet the player deadlines.
+			if !glClient.deadlines.accept(action, time.Now()) {
+				continue
+			}
+
+			// Replace the current message from this player if it exists.
 			// This may happen if the client was late in a previous turn but
 			// catched up in current turn by sending two TURN_ACK.
-			actionFound := false
-			for actionIndex, act := range playerActions {
-				if act.PlayerID == action.PlayerID {
-					playerActions[len(playerActions)-1], playerActions[actionIndex] = playerActions[actionIndex], playerActions[len(playerActions)-1]
-					playerActions[len(playerActions)-1] = action
-					actionFound = true
-					break
-				}
-			}
+			playerActions = replacePlayerAction(playerActions, action)
+			continue
 
-			if !actionFound {
-				// Append the action into the actions array
-				playerActions = append(playerActions, action)
+		case <-turnDue:
+			sendDoTurn(glClient, playerActions)
+			playerActions = playerActions[:0]
+			if !glClient.idleTurn {
+				continue
 			}
+			// The game logic has not been invoked: Nothing to wait for
+			doTurnAckMsg = handleGLIdleTurn(glClient, turnNumber)
 
 		case msg := <-glClient.client.incomingMessages:
 			// New message received from the game logic
-			doTurnAckMsg, err := handleGLDoTurnAckReception(glClient, msg, initialTotalNbPlayers)
+			var err error
+			doTurnAckMsg, err = handleGLDoTurnAckReception(glClient, msg, initialTotalNbPlayers, turnNumber)
 			if err != nil {
 				onexit <- 1
 				waitGameLogicFinition(glClient)
 				return
 			}
+			globalStats.checkGLComputeTime(turnNumber, msBetweenTurns)
+		}
+
+		turnNumber = turnNumber + 1
+		debugNewGameState(glClient, debug, turnNumber-1, doTurnAckMsg.GameState,
+			doTurnAckMsg.RandomDraws)
+		nbTurnsMax = updateNbTurnsMax(glClient, globalState, nbTurnsMax)
+		if turnNumber < nbTurnsMax && !glClient.lastTurn {
+			LockGlobalStateMutex(globalState, "Read turn delay", "GL")
+			msBetweenTurns = globalState.MillisecondsBetweenTurns
+			adaptive := globalState.AdaptiveDelay
+			msBetweenTurnsMin := globalState.MillisecondsBetweenTurnsMin
+			UnlockGlobalStateMutex(globalState, "Read turn delay", "GL")
+
+			if adaptive {
+				msBetweenTurns = adaptiveDelay(msBetweenTurnsMin,
+					msBetweenTurns)
+			}
+			msAdaptive := msBetweenTurns
 
-			turnNumber = turnNumber + 1
-			if turnNumber < nbTurnsMax {
-				handleGlForwardTurnToClients(doTurnAckMsg, turnNumber, allPlayers, visus, playersInfo)
+			// Player deadlines replace the delay between turns
+			deadlines := glClient.deadlines
+			msTurn := msBetweenTurns
+			if deadlines != nil {
+				msTurn = deadlines.longest()
+			}
 
-				// Trigger a new DO_TURN in some time
-				go func() {
+			handleGlForwardTurnToClients(glClient, doTurnAckMsg, turnNumber, allPlayers, visus, playersInfo, msTurn)
+
+			// Trigger a new DO_TURN in some time.
+			// The timer only waits: It must not touch the actions.
+			lastTurnNumber := turnNumber - 1
+			turnStart := time.Now()
+			go func() {
+				waited := false
+				if deadlines != nil {
+					log.Debug("Waiting for the players that can act")
+					waited = waitPlayerDeadlines(glClient, deadlines)
+				} else {
 					log.WithFields(log.Fields{
 						"duration (ms)": msBetweenTurns,
 					}).Debug("Sleeping before next turn")
-					time.Sleep(time.Duration(msBetweenTurns) * time.Millisecond)
+					waited = waitDelay(glClient, globalState, turnStart,
+						func(gs *GlobalState) float64 {
+							if adaptive {
+								return math.Min(msAdaptive,
+									gs.MillisecondsBetweenTurns)
+							}
+							return gs.MillisecondsBetweenTurns
+						})
+				}
+				if !waited {
+					return
+				}
 
-					sendDoTurn(glClient, playerActions)
-					playerActions = playerActions[:0]
-				}()
-			} else {
-				handleGlGameFinished(glClient, doTurnAckMsg, allPlayers, visus, playersInfo)
-				onexit <- 0
-				waitGameLogicFinition(glClient)
-				return
-			}
+				if isBreakpointReached(globalState, lastTurnNumber) {
+					select {
+					case <-glClient.resume:
+					case <-glClient.ctx.Done():
+						return
+					}
+				}
+
+				select {
+				case turnDue <- 1:
+				case <-glClient.ctx.Done():
+				}
+			}()
+		} else {
+			reportGame(debug)
+			handleGlGameFinished(glClient, doTurnAckMsg, allPlayers, visus, playersInfo, "")
+			onexit <- 0
+			waitGameLogicFinition(glClient)
+			return
 		}
 	}
 }
@@ -265,15 +535,18 @@ func areAllValuesTrue(playerIDToBoolMap map[int]bool) bool {
 }
 
 func gameLogicGameControlFast(glClient *GameLogicClient,
-	onexit chan int,
+	globalState *GlobalState, onexit chan int,
 	initialTotalNbPlayers, nbTurnsMax int,
 	allPlayers, visus []*PlayerOrVisuClient,
-	playersInfo []*PlayerInformation) {
+	playersInfo []*PlayerInformation, debug debugOptions) {
 
-	// Order the game logic to compute a TURN right away (without any action)
+	// Order the game logic to compute a TURN right away (without any action),
+	// once players have acknowledged GAME_STARTS
 	turnNumber := 0
 	playerActions := make([]MessageDoTurnPlayerAction, 0)
-	sendDoTurn(glClient, playerActions)
+	if waitGameStartsAcks(glClient) {
+		sendDoTurn(glClient, playerActions)
+	}
 
 	connectedPlayers := make(map[int]int) // keys are playerID. values are not used
 	for playerID := 0; playerID < initialTotalNbPlayers; playerID++ {
@@ -284,41 +557,74 @@ func gameLogicGameControlFast(glClient *GameLogicClient,
 		// Wait for GL's DO_TURN_ACK
 		var doTurnAckMsg MessageDoTurnAck
 		var err error
-		select {
-		case kickReason := <-glClient.client.canTerminate:
-			Kick(glClient.client, kickReason)
-			return
-		case msg := <-glClient.client.incomingMessages:
-			doTurnAckMsg, err = handleGLDoTurnAckReception(glClient, msg, initialTotalNbPlayers)
-			if err != nil {
-				onexit <- 1
-				waitGameLogicFinition(glClient)
+		if glClient.idleTurn {
+			// The game logic has not been invoked: Nothing to wait for
+			doTurnAckMsg = handleGLIdleTurn(glClient, turnNumber)
+		} else {
+			select {
+			case order := <-glClient.client.canTerminate:
+				Kick(glClient.client, order.code, order.reason)
 				return
+			case reason := <-glClient.abort:
+				handleGlGameAborted(glClient, onexit, allPlayers, visus,
+					playersInfo, reason, debug)
+				return
+			case msg := <-glClient.client.incomingMessages:
+				doTurnAckMsg, err = handleGLDoTurnAckReception(glClient, msg, initialTotalNbPlayers, turnNumber)
+				if err != nil {
+					onexit <- 1
+					waitGameLogicFinition(glClient)
+					return
+				}
 			}
 		}
 
 		turnNumber = turnNumber + 1
-		if turnNumber >= nbTurnsMax {
-			handleGlGameFinished(glClient, doTurnAckMsg, allPlayers, visus, playersInfo)
+		debugNewGameState(glClient, debug, turnNumber-1, doTurnAckMsg.GameState,
+			doTurnAckMsg.RandomDraws)
+		nbTurnsMax = updateNbTurnsMax(glClient, globalState, nbTurnsMax)
+		if turnNumber >= nbTurnsMax || glClient.lastTurn {
+			reportGame(debug)
+			handleGlGameFinished(glClient, doTurnAckMsg, allPlayers, visus, playersInfo, "")
 			onexit <- 0
 			waitGameLogicFinition(glClient)
 			return
 		}
 
 		// Forward the new turn to clients
-		handleGlForwardTurnToClients(doTurnAckMsg, turnNumber, allPlayers, visus, playersInfo)
+		handleGlForwardTurnToClients(glClient, doTurnAckMsg, turnNumber, allPlayers, visus, playersInfo, 0)
 
-		// Wait TURN_ACK (or socket failure) from all players.
+		// Wait TURN_ACK (or socket failure) from all players,
+		// or only from the ones that can act until their deadline.
 		actionReceived := make(map[int]bool)
 		for playerID, _ := range connectedPlayers {
-			actionReceived[playerID] = false
+			if glClient.deadlines.canAct(playerID) {
+				actionReceived[playerID] = false
+			}
 		}
+		globalWatchdog.setWaitingPlayers(true)
 		for !areAllValuesTrue(actionReceived) {
 			select {
-			case kickReason := <-glClient.client.canTerminate:
-				Kick(glClient.client, kickReason)
+			case order := <-glClient.client.canTerminate:
+				Kick(glClient.client, order.code, order.reason)
+				return
+			case reason := <-glClient.abort:
+				handleGlGameAborted(glClient, onexit, allPlayers, visus,
+					playersInfo, reason, debug)
 				return
+			case <-glClient.forceAdvance:
+				// Players that did not answer have no actions this turn
+				for playerID := range actionReceived {
+					actionReceived[playerID] = true
+				}
+			case <-glClient.deadlines.nextExpiry():
+				for _, playerID := range glClient.deadlines.expired(time.Now()) {
+					actionReceived[playerID] = true
+				}
 			case action := <-glClient.playerAction:
+				if !glClient.deadlines.accept(action, time.Now()) {
+					break
+				}
 				actionReceived[action.PlayerID] = true
 				if _, isConnected := connectedPlayers[action.PlayerID]; isConnected {
 					playerActions = append(playerActions, action)
@@ -326,6 +632,22 @@ func gameLogicGameControlFast(glClient *GameLogicClient,
 			case disconnectedPlayerID := <-glClient.playerDisconnected:
 				actionReceived[disconnectedPlayerID] = true
 				delete(connectedPlayers, disconnectedPlayerID)
+				glClient.deadlines.left(disconnectedPlayerID)
+			}
+		}
+		globalWatchdog.setWaitingPlayers(false)
+
+		// Wait for the game to be resumed if a breakpoint is set on this turn.
+		if isBreakpointReached(globalState, turnNumber-1) {
+			select {
+			case order := <-glClient.client.canTerminate:
+				Kick(glClient.client, order.code, order.reason)
+				return
+			case reason := <-glClient.abort:
+				handleGlGameAborted(glClient, onexit, allPlayers, visus,
+					playersInfo, reason, debug)
+				return
+			case <-glClient.resume:
 			}
 		}
 
@@ -335,52 +657,396 @@ func gameLogicGameControlFast(glClient *GameLogicClient,
 	}
 }
 
+// Computes the delay between turns (in milliseconds) from the recent TURN_ACK
+// latencies of the players, so that the slowest healthy player has enough
+// time to play. The delay is kept in [msMin, msMax].
+func adaptiveDelay(msMin, msMax float64) float64 {
+	latency, found := globalStats.slowestHealthyLatency(adaptiveDelayNbTurns,
+		time.Duration(msMax*float64(time.Millisecond)))
+	if !found {
+		return msMax
+	}
+
+	delay := latency.Seconds() * 1000 * adaptiveDelayMargin
+	delay = math.Max(msMin, math.Min(msMax, delay))
+	log.WithFields(log.Fields{
+		"slowest latency (ms)": latency.Seconds() * 1000,
+		"delay (ms)":           delay,
+	}).Debug("Adaptive delay computed")
+	return delay
+}
+
+// Waits until a delay (in milliseconds) has elapsed since start.
+// The delay is read again from the global state whenever it is changed,
+// so that changes made during the wait are taken into account.
+// Returns false if netorcai is shut down during the wait.
+func waitDelay(glClient *GameLogicClient, gs *GlobalState, start time.Time,
+	readDelay func(gs *GlobalState) float64) bool {
+	for {
+		LockGlobalStateMutex(gs, "Read delay", "GL")
+		extension := gs.turnExtension
+		delay := readDelay(gs) + extension
+		UnlockGlobalStateMutex(gs, "Read delay", "GL")
+
+		deadline := start.Add(time.Duration(delay * float64(time.Millisecond)))
+		globalWatchdog.timerArmed(deadline)
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			consumeTurnExtension(gs, extension)
+			globalWatchdog.progress()
+			return true
+		}
+
+		select {
+		case <-time.After(remaining):
+			consumeTurnExtension(gs, extension)
+			globalWatchdog.progress()
+			return true
+		case <-glClient.delayChanged:
+		case <-glClient.ctx.Done():
+			return false
+		}
+	}
+}
+
+// Sends a COUNTDOWN to players and visualizations every second before the
+// game starts (--countdown).
+// Returns false if the game logic is kicked meanwhile.
+func runCountdown(glClient *GameLogicClient, gs *GlobalState) bool {
+	LockGlobalStateMutex(gs, "Read countdown", "GL")
+	countdown := gs.Countdown
+	UnlockGlobalStateMutex(gs, "Read countdown", "GL")
+
+	for seconds := countdown; seconds > 0; seconds-- {
+		LockGlobalStateMutex(gs, "Countdown: copy players/visus", "GL")
+		clients := append([]*PlayerOrVisuClient(nil), gs.Players...)
+		clients = append(clients, gs.SpecialPlayers...)
+		clients = append(clients, gs.Visus...)
+		UnlockGlobalStateMutex(gs, "Countdown: copy players/visus", "GL")
+
+		log.WithFields(log.Fields{
+			"seconds": seconds,
+		}).Info("Game starts soon")
+		msg := MessageCountdown{
+			MessageType:             "COUNTDOWN",
+			SecondsBeforeGameStarts: seconds,
+		}
+		for _, pvClient := range clients {
+			// Clients that have not sent the previous one yet skip this one
+			select {
+			case pvClient.countdown <- msg:
+			default:
+			}
+		}
+
+		select {
+		case order := <-glClient.client.canTerminate:
+			Kick(glClient.client, order.code, order.reason)
+			return false
+		case <-time.After(time.Second):
+		}
+	}
+	return true
+}
+
+// Waits until all players have sent GAME_STARTS_ACK, or at most
+// --game-starts-ack-timeout. Missing players are then only logged.
+// Returns false if netorcai shuts down meanwhile.
+func waitGameStartsAcks(glClient *GameLogicClient) bool {
+	if glClient.gameStartsAcks == nil {
+		return true
+	}
+
+	deadline := time.Now().Add(glClient.gameStartsAckTimeout)
+	globalWatchdog.timerArmed(deadline)
+	select {
+	case <-glClient.gameStartsAcks.done:
+		log.Debug("All players acknowledged GAME_STARTS")
+	case <-time.After(time.Until(deadline)):
+		log.WithFields(log.Fields{
+			"player IDs":   glClient.gameStartsAcks.release(),
+			"timeout (ms)": glClient.gameStartsAckTimeout.Seconds() * 1000,
+		}).Warn("Players did not send GAME_STARTS_ACK in time. " +
+			"Starting the game anyway")
+	case <-glClient.ctx.Done():
+		return false
+	}
+	globalWatchdog.progress()
+	return true
+}
+
+// Called when a timer fires: The extension it used no longer applies.
+func consumeTurnExtension(gs *GlobalState, extension float64) {
+	if extension == 0 {
+		return
+	}
+
+	LockGlobalStateMutex(gs, "Consume turn extension", "GL")
+	gs.turnExtension -= extension
+	UnlockGlobalStateMutex(gs, "Consume turn extension", "GL")
+}
+
+// Returns the number of turns of the game, which may have been changed from
+// the prompt since the previous turn. Once changed, it is forwarded to the
+// game logic and the clients in the following DO_TURN and TURN messages.
+func updateNbTurnsMax(glClient *GameLogicClient, gs *GlobalState,
+	nbTurnsMax int) int {
+	LockGlobalStateMutex(gs, "Read nb turns max", "GL")
+	newNbTurnsMax := gs.NbTurnsMax
+	UnlockGlobalStateMutex(gs, "Read nb turns max", "GL")
+
+	if newNbTurnsMax != nbTurnsMax {
+		log.WithFields(log.Fields{
+			"previous nb turns max": nbTurnsMax,
+			"nb turns max":          newNbTurnsMax,
+		}).Info("Number of turns changed")
+		glClient.nbTurnsMax = newNbTurnsMax
+	}
+	return newNbTurnsMax
+}
+
+// Pauses the game if a breakpoint is set on the given turn.
+// The caller must then wait on the GL resume channel.
+func isBreakpointReached(gs *GlobalState, turnNumber int) bool {
+	LockGlobalStateMutex(gs, "Breakpoint check", "GL")
+	defer UnlockGlobalStateMutex(gs, "Breakpoint check", "GL")
+
+	if !gs.Breakpoints[turnNumber] {
+		return false
+	}
+
+	gs.Paused = true
+	globalWatchdog.setPaused(true)
+	log.WithFields(log.Fields{
+		"turn number": turnNumber,
+	}).Warn("Breakpoint reached. Game paused (type 'continue' to resume)")
+	return true
+}
+
 func handleGLDoTurnAckReception(glClient *GameLogicClient,
-	msg ClientMessage, initialTotalNbPlayers int) (MessageDoTurnAck, error) {
+	msg ClientMessage, initialTotalNbPlayers, turnNumber int) (
+	MessageDoTurnAck, error) {
 
+	lintGameLogicMessage(glClient, turnNumber, msg)
 	if msg.err != nil {
-		Kick(glClient.client, fmt.Sprintf("Cannot read DO_TURN_ACK. %v", msg.err.Error()))
+		Kick(glClient.client, msg.errCode, fmt.Sprintf("Cannot read DO_TURN_ACK. %v", msg.err.Error()))
 		return MessageDoTurnAck{}, msg.err
 	}
 
 	doTurnAckMsg, err := readDoTurnAckMessage(msg.content, initialTotalNbPlayers)
 	if err != nil {
-		Kick(glClient.client, fmt.Sprintf("Invalid DO_TURN_ACK message. %v", err.Error()))
+		sendError(glClient.client, err)
+		Kick(glClient.client, KICK_PROTOCOL_ERROR, fmt.Sprintf("Invalid DO_TURN_ACK message. %v", err.Error()))
 		return MessageDoTurnAck{}, err
 	}
 
+	if glClient.gameStateSchema != nil {
+		err = validateStateSchema(msg.content["game_state"],
+			glClient.gameStateSchema, "game_state")
+		if err != nil {
+			sendError(glClient.client, err)
+			Kick(glClient.client, KICK_PROTOCOL_ERROR, fmt.Sprintf(
+				"Game state of turn %v does not match game_state_schema. %v",
+				turnNumber, err.Error()))
+			return MessageDoTurnAck{}, err
+		}
+	}
+
 	log.Debug("GL received a new DO_TURN_ACK (from socket)")
+	globalStats.doTurnAckReceived()
+	globalWatchdog.progress()
+	logRandomDraws(turnNumber, doTurnAckMsg.RandomDraws)
+
+	stateBytes := serializedSize(doTurnAckMsg.GameState)
+	globalStats.stateReceived(turnNumber, stateBytes)
+	err = checkGameStateSize(glClient, turnNumber, stateBytes)
+	if err != nil {
+		Kick(glClient.client, KICK_GAME_STATE_TOO_BIG, err.Error())
+		return MessageDoTurnAck{}, err
+	}
+
+	lintGameStateTypes(glClient, turnNumber, doTurnAckMsg.GameState)
+	glClient.lastWinnerPlayerID = &doTurnAckMsg.WinnerPlayerID
+
+	// The deadlines apply to the TURN about to be sent
+	glClient.deadlines = newPlayerDeadlines(turnNumber,
+		doTurnAckMsg.PlayerDeadlines, time.Now())
 	return doTurnAckMsg, nil
 }
 
-func handleGlForwardTurnToClients(doTurnAckMsg MessageDoTurnAck, turnNumber int,
+func serializedSize(gameState map[string]interface{}) int {
+	content, err := jsonBackend.Marshal(gameState)
+	if err != nil {
+		return 0
+	}
+	return len(content)
+}
+
+// Checks that the serialized game state of a turn (-1 for the initial game
+// state) does not exceed the maximum size. A warning is logged otherwise,
+// and an error is returned if the game should be aborted.
+func checkGameStateSize(glClient *GameLogicClient, turnNumber,
+	stateBytes int) error {
+	if glClient.maxStateBytes <= 0 || stateBytes <= glClient.maxStateBytes {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"turn number":     turnNumber,
+		"state size":      stateBytes,
+		"max state bytes": glClient.maxStateBytes,
+		"abort game":      glClient.abortOnStateTooBig,
+	}).Warn("Game state is bigger than --max-state-bytes")
+
+	if glClient.abortOnStateTooBig {
+		return fmt.Errorf("Game state too big: %v bytes at turn %v "+
+			"(maximum is %v)", stateBytes, turnNumber, glClient.maxStateBytes)
+	}
+	return nil
+}
+
+// Must be called with the global state mutex held.
+func currentServerConfig(gs *GlobalState) ServerConfig {
+	return ServerConfig{
+		NbPlayersMax:         gs.NbPlayersMax,
+		NbSpecialPlayersMax:  gs.NbSpecialPlayersMax,
+		NbVisusMax:           gs.NbVisusMax,
+		NbTurnsMax:           gs.NbTurnsMax,
+		DelayFirstTurn:       gs.MillisecondsBeforeFirstTurn,
+		DelayTurns:           gs.MillisecondsBetweenTurns,
+		DelayTurnsMin:        gs.MillisecondsBetweenTurnsMin,
+		AdaptiveDelay:        gs.AdaptiveDelay,
+		GameStartsAckTimeout: gs.MillisecondsGameStartsAckTimeout,
+		Fast:                 gs.Fast,
+		PublicVisuDelay:      gs.PublicVisuDelay,
+		EchoActionsToVisus:   gs.EchoActionsToVisus,
+		AnonymizePlayers:     gs.AnonymizePlayers,
+	}
+}
+
+// msBetweenTurns is forwarded to visualizations (0 to omit it).
+func handleGlForwardTurnToClients(glClient *GameLogicClient,
+	doTurnAckMsg MessageDoTurnAck, turnNumber int,
 	allPlayers, visus []*PlayerOrVisuClient,
-	playersInfo []*PlayerInformation) {
+	playersInfo []*PlayerInformation, msBetweenTurns float64) {
 
+	if glClient.logTurns {
+		log.WithFields(log.Fields{
+			"turn number": turnNumber - 1,
+		}).Info("Sending TURN to players")
+	}
+	// The game state is only serialized once for all clients
+	encodedState := &encodedGameState{}
 	for _, player := range allPlayers {
 		player.newTurn <- MessageTurn{
-			MessageType: "TURN",
-			TurnNumber:  turnNumber - 1,
-			GameState:   doTurnAckMsg.GameState,
-			PlayersInfo: []*PlayerInformation{},
+			MessageType:   "TURN",
+			TurnNumber:    turnNumber - 1,
+			GameState:     doTurnAckMsg.GameState,
+			PlayersInfo:   []*PlayerInformation{},
+			PlayerMessage: doTurnAckMsg.PlayerMessages[player.playerID],
+			NbTurnsMax:    glClient.nbTurnsMax,
+
+			ActionDeadline: glClient.deadlines.of(player.playerID),
+			encodedState:   encodedState,
 		}
 	}
+	latencies := globalStats.recentLatencies(statsLatencyNbTurns)
+	var echoedActions []MessageDoTurnPlayerAction
+	if glClient.echoActionsToVisus {
+		// The actions that led to this game state
+		echoedActions = glClient.lastPlayerActions
+	}
+	visuTurn := MessageTurn{
+		MessageType:   "TURN",
+		TurnNumber:    turnNumber - 1,
+		GameState:     doTurnAckMsg.GameState,
+		PlayersInfo:   playersInfo,
+		DelayTurns:    msBetweenTurns,
+		Latencies:     latencies,
+		PlayerActions: echoedActions,
+		NbTurnsMax:    glClient.nbTurnsMax,
+		encodedState:  encodedState,
+	}
+
+	// Public visualizations receive turns publicVisuDelay turns late
+	glClient.publicVisuTurns = append(glClient.publicVisuTurns, visuTurn)
+	var publicTurn *MessageTurn
+	if len(glClient.publicVisuTurns) > glClient.publicVisuDelay {
+		publicTurn = &glClient.publicVisuTurns[0]
+		glClient.publicVisuTurns = glClient.publicVisuTurns[1:]
+	}
+
+	// Late or not, turns are displayed from now on until the next one
+	now := time.Now()
+	visuTurn.Timestamp, visuTurn.NextTurnETA = turnTimes(now, msBetweenTurns)
+	if publicTurn != nil {
+		publicTurn.Timestamp, publicTurn.NextTurnETA = turnTimes(now,
+			msBetweenTurns)
+		globalTurnStream.publish("TURN", *publicTurn)
+	}
+	globalTurnHistory.record(visuTurn, publicTurn)
+
 	for _, visu := range visus {
-		visu.newTurn <- MessageTurn{
-			MessageType: "TURN",
-			TurnNumber:  turnNumber - 1,
-			GameState:   doTurnAckMsg.GameState,
-			PlayersInfo: playersInfo,
+		if visu.isLiveVisu {
+			visu.newTurn <- visuTurn
+		} else if publicTurn != nil {
+			visu.newTurn <- *publicTurn
 		}
 	}
 }
 
+// Returns when a TURN is sent to visualizations and when the next one
+// should be, in milliseconds since the Unix epoch.
+// Without timer (msBetweenTurns is 0), the next turn time is unknown (0).
+func turnTimes(now time.Time, msBetweenTurns float64) (int64, int64) {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	if msBetweenTurns <= 0 {
+		return timestamp, 0
+	}
+	return timestamp, timestamp + int64(msBetweenTurns)
+}
+
+// Ends the game aborted by the operator: Clients receive the last game state
+// without winner, then netorcai exits with a failure code.
+func handleGlGameAborted(glClient *GameLogicClient, onexit chan int,
+	allPlayers, visus []*PlayerOrVisuClient,
+	playersInfo []*PlayerInformation, reason string, debug debugOptions) {
+	reportGame(debug)
+	handleGlGameFinished(glClient, MessageDoTurnAck{
+		WinnerPlayerID: -1,
+		GameState:      glClient.lastGameState,
+	}, allPlayers, visus, playersInfo, reason)
+	onexit <- 1
+	waitGameLogicFinition(glClient)
+}
+
+// abortReason is the reason of the operator if the game has been aborted
+// ("" otherwise).
 func handleGlGameFinished(glClient *GameLogicClient,
 	doTurnAckMsg MessageDoTurnAck,
 	allPlayers, visus []*PlayerOrVisuClient,
-	playersInfo []*PlayerInformation) {
+	playersInfo []*PlayerInformation, abortReason string) {
+
+	// Visualizations get the protocol statistics of the players
+	clientStats := make(map[int]ClientProtocolStats)
+	for _, player := range allPlayers {
+		stats := player.client.stats()
+		clientStats[player.playerID] = stats
+		log.WithFields(log.Fields{
+			"player ID":         player.playerID,
+			"nickname":          player.client.nickname,
+			"messages sent":     stats.MessagesSent,
+			"messages received": stats.MessagesReceived,
+			"invalid messages":  stats.InvalidMessages,
+			"reconnects":        stats.Reconnects,
+		}).Info("Player protocol report")
+	}
 
-	if doTurnAckMsg.WinnerPlayerID != -1 {
+	if abortReason != "" {
+		log.WithFields(log.Fields{
+			"abort reason": abortReason,
+		}).Warn("Game aborted by the operator")
+	} else if doTurnAckMsg.WinnerPlayerID != -1 {
 		log.WithFields(log.Fields{
 			"winner player ID":      doTurnAckMsg.WinnerPlayerID,
 			"winner nickname":       playersInfo[doTurnAckMsg.WinnerPlayerID].Nickname,
@@ -391,11 +1057,13 @@ func handleGlGameFinished(glClient *GameLogicClient,
 	}
 
 	// Send GAME_ENDS to all clients
+	visus = append(visus, globalTurnHistory.end()...)
 	for _, player := range allPlayers {
 		player.gameEnds <- MessageGameEnds{
 			MessageType:    "GAME_ENDS",
 			WinnerPlayerID: doTurnAckMsg.WinnerPlayerID,
 			GameState:      doTurnAckMsg.GameState,
+			AbortReason:    abortReason,
 		}
 	}
 	for _, visu := range visus {
@@ -403,19 +1071,45 @@ func handleGlGameFinished(glClient *GameLogicClient,
 			MessageType:    "GAME_ENDS",
 			WinnerPlayerID: doTurnAckMsg.WinnerPlayerID,
 			GameState:      doTurnAckMsg.GameState,
+			ClientStats:    clientStats,
+			AbortReason:    abortReason,
 		}
 	}
+	globalTurnStream.publish("GAME_ENDS", MessageGameEnds{
+		MessageType:    "GAME_ENDS",
+		WinnerPlayerID: doTurnAckMsg.WinnerPlayerID,
+		GameState:      doTurnAckMsg.GameState,
+		ClientStats:    clientStats,
+		AbortReason:    abortReason,
+	})
+
+	glClient.hooks.run("game_ends", hookGameEnds{
+		Event:               "game_ends",
+		WinnerPlayerID:      doTurnAckMsg.WinnerPlayerID,
+		PlayersInfo:         playersInfo,
+		GameState:           doTurnAckMsg.GameState,
+		DumpStatesDirectory: glClient.hooks.dumpStatesDirectory,
+		AbortReason:         abortReason,
+	})
 
 	// Leave the program
-	Kick(glClient.client, "Game is finished")
+	if abortReason != "" {
+		Kick(glClient.client, KICK_ABORTED, abortKickReason(abortReason))
+	} else {
+		Kick(glClient.client, KICK_GAME_FINISHED, "Game is finished")
+	}
 }
 
-func sendDoInit(client *GameLogicClient, nbPlayers, nbSpecialPlayers, nbTurnsMax int) error {
+// Sends DO_INIT to the game logic, with the seed of the game if any
+// (nil otherwise). Handed seeds are logged for auditing.
+func sendDoInit(client *GameLogicClient, nbPlayers, nbSpecialPlayers,
+	nbTurnsMax int, seed *int64) error {
 	msg := MessageDoInit{
 		MessageType:      "DO_INIT",
 		NbPlayers:        nbPlayers,
 		NbSpecialPlayers: nbSpecialPlayers,
 		NbTurnsMax:       nbTurnsMax,
+		Seed:             seed,
 	}
 
 	content, err := json.Marshal(msg)
@@ -427,15 +1121,191 @@ func sendDoInit(client *GameLogicClient, nbPlayers, nbSpecialPlayers, nbTurnsMax
 		}).Debug("Sending DO_INIT to game logic")
 		err = sendMessage(client.client, content)
 	}
+	if err == nil && seed != nil {
+		log.WithFields(log.Fields{
+			"nickname": client.client.nickname,
+			"seed":     *seed,
+		}).Info("Seed handed to game logic")
+	}
+	return err
+}
+
+// Logs the random numbers that the game logic reports to have drawn to
+// compute a turn (-1 for the initial game state), if any.
+func logRandomDraws(turnNumber int, randomDraws []interface{}) {
+	if randomDraws == nil {
+		return
+	}
+
+	content, _ := json.Marshal(randomDraws)
+	log.WithFields(log.Fields{
+		"turn number":  turnNumber,
+		"random draws": string(content),
+	}).Info("Random draws reported by game logic")
+}
+
+// Called when a player leaves the running game. The game logic is told
+// right before the next DO_TURN, so that it always learns about departures
+// between two turns.
+func (glClient *GameLogicClient) playerLeft(playerID int, code KickCode) {
+	reason := "kick"
+	switch code {
+	case KICK_NETWORK_ERROR:
+		reason = "disconnect"
+	case KICK_TIMEOUT:
+		reason = "timeout"
+	}
+
+	glClient.notificationsMutex.Lock()
+	defer glClient.notificationsMutex.Unlock()
+	glClient.departures = append(glClient.departures, MessagePlayerLeft{
+		MessageType: "PLAYER_LEFT",
+		PlayerID:    playerID,
+		TurnNumber:  globalStats.lastTurn(),
+		Reason:      reason,
+	})
+}
+
+// Sends the departures since the previous DO_TURN as PLAYER_LEFT messages,
+// if the game logic asked for them at LOGIN.
+func sendPlayersLeft(client *GameLogicClient) error {
+	client.notificationsMutex.Lock()
+	departures := client.departures
+	client.departures = nil
+	client.notificationsMutex.Unlock()
+	client.lastDepartures = departures
+
+	if !client.client.capabilities.playerLeft {
+		return nil
+	}
+	for _, departure := range departures {
+		content, err := json.Marshal(departure)
+		if err == nil {
+			log.WithFields(log.Fields{
+				"nickname":       client.client.nickname,
+				"remote address": client.client.Conn.RemoteAddr(),
+				"content":        string(content),
+			}).Debug("Sending PLAYER_LEFT to game logic")
+			err = sendMessage(client.client, content)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Called with the global state locked when the game starts. The
+// visualizations already logged in are notified as having joined.
+func (glClient *GameLogicClient) initVisus(visus []*PlayerOrVisuClient) {
+	glClient.notificationsMutex.Lock()
+	defer glClient.notificationsMutex.Unlock()
+
+	glClient.nbVisus = 0
+	glClient.visusChanged = nil
+	for _, visu := range visus {
+		glClient.visuChangedLocked(visu.client.nickname, true)
+	}
+}
+
+// Called with the global state locked when a visualization joins or leaves
+// the running game.
+func (glClient *GameLogicClient) visuChanged(nickname string, joined bool) {
+	glClient.notificationsMutex.Lock()
+	defer glClient.notificationsMutex.Unlock()
+	glClient.visuChangedLocked(nickname, joined)
+}
+
+func (glClient *GameLogicClient) visuChangedLocked(nickname string,
+	joined bool) {
+	if glClient.visusChanged == nil {
+		glClient.visusChanged = &MessageVisusChanged{
+			MessageType: "VISUS_CHANGED",
+			Joined:      []string{},
+			Left:        []string{},
+		}
+	}
+
+	if joined {
+		glClient.nbVisus++
+		glClient.visusChanged.Joined = append(glClient.visusChanged.Joined,
+			nickname)
+	} else {
+		glClient.nbVisus--
+		glClient.visusChanged.Left = append(glClient.visusChanged.Left,
+			nickname)
+	}
+	glClient.visusChanged.NbVisus = glClient.nbVisus
+}
+
+// Sends the visualizations that joined or left since the previous DO_TURN
+// as a VISUS_CHANGED message, if the game logic asked for it at LOGIN.
+func sendVisusChanged(client *GameLogicClient) error {
+	client.notificationsMutex.Lock()
+	msg := client.visusChanged
+	client.visusChanged = nil
+	client.notificationsMutex.Unlock()
+
+	if msg == nil || !client.client.capabilities.visuChanges {
+		return nil
+	}
+
+	content, err := json.Marshal(msg)
+	if err == nil {
+		log.WithFields(log.Fields{
+			"nickname":       client.client.nickname,
+			"remote address": client.client.Conn.RemoteAddr(),
+			"content":        string(content),
+		}).Debug("Sending VISUS_CHANGED to game logic")
+		err = sendMessage(client.client, content)
+	}
 	return err
 }
 
+// Must be called by the GL coroutine, which owns the turn state: Timers
+// signal it instead of sending DO_TURN themselves.
 func sendDoTurn(client *GameLogicClient,
 	playerActions []MessageDoTurnPlayerAction) error {
+	playerActions = client.orderPlayerActions(playerActions)
+	if client.deadlines != nil {
+		client.missedDeadlines = client.deadlines.close()
+	}
+	if client.skipIdleTurn(playerActions) {
+		return nil
+	}
+
+	if err := sendPlayersLeft(client); err != nil {
+		return err
+	}
+	if err := sendVisusChanged(client); err != nil {
+		return err
+	}
+
 	msg := MessageDoTurn{
 		MessageType:   "DO_TURN",
 		PlayerActions: playerActions,
+		Latencies:     globalStats.recentLatencies(statsLatencyNbTurns),
+		NbTurnsMax:    client.nbTurnsMax,
+
+		MissedDeadlines: client.missedDeadlines,
 	}
+	if len(client.missedDeadlines) > 0 {
+		log.WithFields(log.Fields{
+			"player IDs": client.missedDeadlines,
+		}).Info("Players missed their action deadline")
+	}
+	client.lastMissedDeadlines = client.missedDeadlines
+	client.missedDeadlines = nil
+	if !client.lastTurn {
+		select {
+		case <-client.stop:
+			client.lastTurn = true
+		default:
+		}
+	}
+	msg.LastTurn = client.lastTurn
+	client.lastPlayerActions = append([]MessageDoTurnPlayerAction(nil),
+		playerActions...)
 
 	content, err := json.Marshal(msg)
 	if err == nil {
@@ -446,5 +1316,9 @@ func sendDoTurn(client *GameLogicClient,
 		}).Debug("Sending DO_TURN to game logic")
 		err = sendMessage(client.client, content)
 	}
+
+	if err == nil {
+		globalStats.doTurnSent()
+	}
 	return err
 }
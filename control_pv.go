@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"time"
 )
 
 type PlayerOrVisuClient struct {
@@ -11,17 +12,36 @@ type PlayerOrVisuClient struct {
 	playerID        int
 	isPlayer        bool
 	isSpecialPlayer bool
+	isLiveVisu      bool
+	lobby           chan MessageLobby
+	adminMessages   chan MessageAdmin
+	countdown       chan MessageCountdown
 	gameStarts      chan MessageGameStarts
 	newTurn         chan MessageTurn
 	gameEnds        chan MessageGameEnds
 	playerInfo      *PlayerInformation
+	turnSentAt      time.Time
+	// Whether the player keeps receiving every TURN while it has not
+	// acknowledged the previous ones (--stale-players=send-all).
+	// Otherwise, only the latest TURN is sent once it acknowledges.
+	sendAllTurns bool
+	// Send times of the TURN not acknowledged yet (with sendAllTurns)
+	unackedTurns map[int]time.Time
+	// The GAME_STARTS and the past turns of the running game,
+	// for visualizations that logged in while it was running.
+	// Past turns are all sent (in order) before the buffered one.
+	lateGameStarts *MessageGameStarts
+	pastTurns      []MessageTurn
+	// Minimum time between two TURN messages (0: unlimited).
+	// The turns received in the meantime are coalesced (the latest is kept).
+	minTurnInterval time.Duration
 }
 
 func waitPlayerOrVisuFinition(pvClient *PlayerOrVisuClient) {
 	for {
 		select {
-		case kickReason := <-pvClient.client.canTerminate:
-			Kick(pvClient.client, kickReason)
+		case order := <-pvClient.client.canTerminate:
+			Kick(pvClient.client, order.code, order.reason)
 			return
 		case <-pvClient.client.incomingMessages:
 		}
@@ -33,37 +53,142 @@ func handlePlayerOrVisu(pvClient *PlayerOrVisuClient,
 	turnBuffer := make([]MessageTurn, 0)
 	lastTurnNumberSent := -1
 	var glClient *GameLogicClient
+	var turnWriters *turnWritePool
+	// Fires when the buffered TURN can be sent again (minTurnInterval)
+	var throttleTimer <-chan time.Time
+
+	startGame := func(gameStarts MessageGameStarts) bool {
+		// The lobby is over
+		select {
+		case <-pvClient.lobby:
+		default:
+		}
+
+		err := sendGameStarts(pvClient.client, gameStarts)
+		if err != nil {
+			KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+				fmt.Sprintf("Cannot send GAME_STARTS. %v", err.Error()))
+			return false
+		}
+		pvClient.client.state = CLIENT_READY
+
+		// Set glClient from the global state now
+		LockGlobalStateMutex(globalState, "Local copy of GL pointer", "client")
+		glClient = globalState.GameLogic[0]
+		turnWriters = globalState.turnWriters
+		UnlockGlobalStateMutex(globalState, "Local copy of GL pointer", "client")
+		return true
+	}
+
+	// Sends the next TURN that is kept for the client
+	sendKeptTurn := func(turn MessageTurn) bool {
+		pvClient.annotateSkippedTurns(&turn, lastTurnNumberSent)
+		lastTurnNumberSent = turn.TurnNumber
+		err := sendTurn(pvClient.client, turn, turnWriters)
+		if err != nil {
+			KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+				fmt.Sprintf("Cannot send TURN. %v", err.Error()))
+			return false
+		}
+		pvClient.turnSentAt = time.Now()
+		if pvClient.sendAllTurns {
+			pvClient.unackedTurns[turn.TurnNumber] = pvClient.turnSentAt
+		}
+		pvClient.client.state = CLIENT_THINKING
+		return true
+	}
+
+	// Keeps a TURN until the client can receive it.
+	// Only the latest one is kept.
+	bufferTurn := func(turn MessageTurn) {
+		if len(turnBuffer) > 0 {
+			// Update the turn buffer with the new message.
+			turnBuffer[0] = turn
+		} else {
+			// Put the new message into the turn buffer.
+			turnBuffer = append(turnBuffer, turn)
+		}
+	}
+
+	// Whether the client can be sent a TURN (regardless of minTurnInterval)
+	canReceiveTurn := func() bool {
+		return pvClient.client.state == CLIENT_READY ||
+			(pvClient.client.state == CLIENT_THINKING && pvClient.sendAllTurns)
+	}
+
+	armThrottleTimer := func() {
+		if throttleTimer == nil {
+			throttleTimer = time.After(pvClient.turnThrottleDelay())
+		}
+	}
+
+	// The game may already be running
+	if pvClient.lateGameStarts != nil {
+		if !startGame(*pvClient.lateGameStarts) {
+			return
+		}
+		if len(pvClient.pastTurns) > 0 {
+			if !sendKeptTurn(pvClient.pastTurns[0]) {
+				return
+			}
+			pvClient.pastTurns = pvClient.pastTurns[1:]
+		}
+	}
 
 	for {
 		select {
-		case kickReason := <-pvClient.client.canTerminate:
-			Kick(pvClient.client, kickReason)
+		case order := <-pvClient.client.canTerminate:
+			Kick(pvClient.client, order.code, order.reason)
 			return
+		case pongs := <-pvClient.client.ping:
+			err := sendPing(pvClient.client, pongs)
+			if err != nil {
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+					fmt.Sprintf("Cannot send PING. %v", err.Error()))
+				return
+			}
+		case lobby := <-pvClient.lobby:
+			err := sendLobby(pvClient.client, lobby)
+			if err != nil {
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+					fmt.Sprintf("Cannot send LOBBY. %v", err.Error()))
+				return
+			}
+		case adminMessage := <-pvClient.adminMessages:
+			err := sendAdminMessage(pvClient.client, adminMessage)
+			if err != nil {
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+					fmt.Sprintf("Cannot send ADMIN_MESSAGE. %v", err.Error()))
+				return
+			}
+		case countdown := <-pvClient.countdown:
+			err := sendCountdown(pvClient.client, countdown)
+			if err != nil {
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+					fmt.Sprintf("Cannot send COUNTDOWN. %v", err.Error()))
+				return
+			}
 		case gameStarts := <-pvClient.gameStarts:
 			// A game start has been received.
-			err := sendGameStarts(pvClient.client, gameStarts)
-			if err != nil {
-				KickLoggedPlayerOrVisu(pvClient, globalState,
-					fmt.Sprintf("Cannot send GAME_STARTS. %v", err.Error()))
+			if !startGame(gameStarts) {
 				return
 			}
-			pvClient.client.state = CLIENT_READY
-
-			// Set glClient from the global state now
-			LockGlobalStateMutex(globalState, "Local copy of GL pointer", "client")
-			glClient = globalState.GameLogic[0]
-			UnlockGlobalStateMutex(globalState, "Local copy of GL pointer", "client")
 		case gameEnds := <-pvClient.gameEnds:
 			// A game end has been received.
 			err := sendGameEnds(pvClient.client, gameEnds)
 			if err != nil {
-				KickLoggedPlayerOrVisu(pvClient, globalState,
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
 					fmt.Sprintf("Cannot send GAME_ENDS. %v", err.Error()))
 				return
 			}
 
 			// Leave the client
-			Kick(pvClient.client, "Game is finished")
+			if gameEnds.AbortReason != "" {
+				Kick(pvClient.client, KICK_ABORTED,
+					abortKickReason(gameEnds.AbortReason))
+			} else {
+				Kick(pvClient.client, KICK_GAME_FINISHED, "Game is finished")
+			}
 			waitPlayerOrVisuFinition(pvClient)
 			return
 		case turn := <-pvClient.newTurn:
@@ -72,39 +197,98 @@ func handlePlayerOrVisu(pvClient *PlayerOrVisuClient,
 				"playerID": pvClient.playerID,
 			}).Debug("Client received a new TURN (from GL goroutine)")
 
-			if pvClient.client.state == CLIENT_READY {
-				// The client is ready, the message can be sent right now.
+			if pvClient.client.capabilities.frameSkipping {
+				turn = latestTurn(pvClient.newTurn, turn)
+			}
+
+			if canReceiveTurn() && pvClient.turnThrottleDelay() > 0 {
+				// The previous TURN has been sent too recently.
+				// The turn is sent later, unless a newer one replaces it.
+				bufferTurn(turn)
+				armThrottleTimer()
+			} else if canReceiveTurn() {
+				// The client is ready (or gets all turns anyway),
+				// the message can be sent right now.
+				pvClient.annotateSkippedTurns(&turn, lastTurnNumberSent)
 				lastTurnNumberSent = turn.TurnNumber
-				err := sendTurn(pvClient.client, turn)
+				err := sendTurn(pvClient.client, turn, turnWriters)
 				if err != nil {
-					KickLoggedPlayerOrVisu(pvClient, globalState,
+					KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
 						fmt.Sprintf("Cannot send TURN. %v", err.Error()))
 					return
 				}
+				pvClient.turnSentAt = time.Now()
+				if pvClient.sendAllTurns {
+					pvClient.unackedTurns[turn.TurnNumber] = pvClient.turnSentAt
+				}
 				pvClient.client.state = CLIENT_THINKING
 			} else if pvClient.client.state == CLIENT_THINKING {
 				// The client is still computing something (its decisions for
 				// a player, or just updating its display for a visualization).
 				// The turn message is therefore buffered.
-				if len(turnBuffer) > 0 {
-					// Update the turn buffer with the new message.
-					turnBuffer[0] = turn
-				} else {
-					// Put the new message into the turn buffer.
-					turnBuffer = append(turnBuffer, turn)
+				bufferTurn(turn)
+			}
+		case <-throttleTimer:
+			// The buffered TURN can now be sent.
+			throttleTimer = nil
+			if len(turnBuffer) > 0 && canReceiveTurn() {
+				if !sendKeptTurn(turnBuffer[0]) {
+					return
 				}
+				turnBuffer = turnBuffer[:0]
 			}
 		case msg := <-pvClient.client.incomingMessages:
 			// A new message has been received from the player socket.
 			if msg.err != nil {
-				KickLoggedPlayerOrVisu(pvClient, globalState,
+				KickLoggedPlayerOrVisu(pvClient, globalState, msg.errCode,
 					fmt.Sprintf("Cannot read TURN_ACK. %v", msg.err.Error()))
 				return
 			}
+
+			// Messages other than TURN_ACK are handled by their handler
+			if handler, exists := lookupMessageHandler(msg.content); exists {
+				received := &ReceivedMessage{
+					Content:    msg.content,
+					ReceivedAt: msg.receivedAt,
+					Nickname:   pvClient.client.nickname,
+					PlayerID:   pvClient.playerID,
+					Role:       pvClient.role(),
+					pvClient:   pvClient,
+					glClient:   glClient,
+				}
+				if handler.Validate != nil {
+					if err := handler.Validate(received); err != nil {
+						pvClient.client.updateStats(func(stats *ClientProtocolStats) {
+							stats.InvalidMessages++
+						})
+						sendError(pvClient.client, err)
+						KickLoggedPlayerOrVisu(pvClient, globalState, KICK_PROTOCOL_ERROR,
+							fmt.Sprintf("Invalid %v received. %v",
+								msg.content["message_type"], err.Error()))
+						return
+					}
+				}
+				if err := handler.Handle(received); err != nil {
+					KickLoggedPlayerOrVisu(pvClient, globalState, KICK_NETWORK_ERROR,
+						err.Error())
+					return
+				}
+				continue
+			}
+
+			expectedTurnNumber := lastTurnNumberSent
+			if pvClient.sendAllTurns {
+				expectedTurnNumber = pvClient.expectedTurnAck(msg.content,
+					lastTurnNumberSent)
+			}
 			turnAckMsg, err := readTurnAckMessage(msg.content,
-				lastTurnNumberSent)
+				expectedTurnNumber)
 			if err != nil {
-				KickLoggedPlayerOrVisu(pvClient, globalState,
+				pvClient.client.updateStats(func(stats *ClientProtocolStats) {
+					stats.InvalidMessages++
+				})
+				sendError(pvClient.client, err)
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_PROTOCOL_ERROR,
 					fmt.Sprintf("Invalid TURN_ACK received. %v",
 						err.Error()))
 				return
@@ -113,45 +297,135 @@ func handlePlayerOrVisu(pvClient *PlayerOrVisuClient,
 			log.WithFields(log.Fields{
 				"playerID": pvClient.playerID,
 			}).Debug("Client received a TURN_ACK (from socket)")
+			globalWatchdog.progress()
 
 			// Check client state
 			if pvClient.client.state != CLIENT_THINKING {
-				KickLoggedPlayerOrVisu(pvClient, globalState,
+				pvClient.client.updateStats(func(stats *ClientProtocolStats) {
+					stats.InvalidMessages++
+				})
+				KickLoggedPlayerOrVisu(pvClient, globalState, KICK_PROTOCOL_ERROR,
 					"Received a TURN_ACK but the client state is not THINKING")
 				return
 			}
 
 			if pvClient.isPlayer {
+				turnSentAt := pvClient.turnSentAt
+				if pvClient.sendAllTurns {
+					turnSentAt = pvClient.unackedTurns[turnAckMsg.turnNumber]
+					for turnNumber := range pvClient.unackedTurns {
+						if turnNumber <= turnAckMsg.turnNumber {
+							delete(pvClient.unackedTurns, turnNumber)
+						}
+					}
+				}
+				globalStats.turnAckReceived(pvClient.playerID,
+					pvClient.client.nickname, time.Since(turnSentAt))
+
 				// Forward the player actions to the game logic
 				glClient.playerAction <- MessageDoTurnPlayerAction{
 					PlayerID:   pvClient.playerID,
+					Role:       pvClient.role(),
 					TurnNumber: turnAckMsg.turnNumber,
 					Actions:    turnAckMsg.actions,
 				}
 			}
 
 			// If a TURN is buffered, send it right now.
-			if len(turnBuffer) > 0 {
-				lastTurnNumberSent = turnBuffer[0].TurnNumber
-				err := sendTurn(pvClient.client, turnBuffer[0])
-				if err != nil {
-					KickLoggedPlayerOrVisu(pvClient, globalState,
-						fmt.Sprintf("Cannot send TURN. %v", err.Error()))
+			// Past turns of the running game come first.
+			if len(pvClient.pastTurns) > 0 {
+				if !sendKeptTurn(pvClient.pastTurns[0]) {
+					return
+				}
+				pvClient.pastTurns = pvClient.pastTurns[1:]
+			} else if len(turnBuffer) > 0 && pvClient.turnThrottleDelay() == 0 {
+				if !sendKeptTurn(turnBuffer[0]) {
 					return
 				}
 
 				// Empty turn buffer
 				turnBuffer = turnBuffer[:0]
+			} else if len(pvClient.unackedTurns) > 0 {
+				// Later turns have already been sent
 				pvClient.client.state = CLIENT_THINKING
 			} else {
 				pvClient.client.state = CLIENT_READY
 			}
+
+			// A buffered TURN that is too early is sent later
+			if len(turnBuffer) > 0 {
+				armThrottleTimer()
+			}
+		}
+	}
+}
+
+// Returns the latest of the turns already received by a client: The
+// previous ones are skipped.
+func latestTurn(newTurn chan MessageTurn, turn MessageTurn) MessageTurn {
+	for {
+		select {
+		case turn = <-newTurn:
+		default:
+			return turn
+		}
+	}
+}
+
+// Returns the role of the client, as told to the game logic and to
+// message handlers.
+func (pvClient *PlayerOrVisuClient) role() string {
+	if !pvClient.isPlayer {
+		return "visualization"
+	} else if pvClient.isSpecialPlayer {
+		return "special player"
+	}
+	return "player"
+}
+
+// Returns how long the client must wait before it can be sent another TURN,
+// so that it is not sent more than one every minTurnInterval.
+func (pvClient *PlayerOrVisuClient) turnThrottleDelay() time.Duration {
+	if pvClient.minTurnInterval == 0 || pvClient.turnSentAt.IsZero() {
+		return 0
+	}
+	delay := pvClient.minTurnInterval - time.Since(pvClient.turnSentAt)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Tells visualizations that skip frames and players that catch up
+// how many turns they have not received since the previous TURN sent to them.
+func (pvClient *PlayerOrVisuClient) annotateSkippedTurns(turn *MessageTurn,
+	lastTurnNumberSent int) {
+	if (pvClient.client.capabilities.frameSkipping || pvClient.isPlayer) && lastTurnNumberSent >= 0 {
+		turn.SkippedTurns = turn.TurnNumber - lastTurnNumberSent - 1
+	}
+}
+
+// Returns the turn number a TURN_ACK of a player that gets all turns must
+// have: Any turn sent but not acknowledged yet can be acknowledged,
+// the oldest one is expected otherwise.
+func (pvClient *PlayerOrVisuClient) expectedTurnAck(
+	content map[string]interface{}, lastTurnNumberSent int) int {
+	turnNumber, err := ReadInt(content, "turn_number")
+	if _, unacked := pvClient.unackedTurns[turnNumber]; err == nil && unacked {
+		return turnNumber
+	}
+
+	expected := lastTurnNumberSent
+	for unackedTurn := range pvClient.unackedTurns {
+		if unackedTurn < expected {
+			expected = unackedTurn
 		}
 	}
+	return expected
 }
 
 func KickLoggedPlayerOrVisu(pvClient *PlayerOrVisuClient,
-	gs *GlobalState, reason string) {
+	gs *GlobalState, code KickCode, reason string) {
 	// Remove the client from the global state
 	LockGlobalStateMutex(gs, "Kick player or visu", "player/visu")
 
@@ -160,6 +434,13 @@ func KickLoggedPlayerOrVisu(pvClient *PlayerOrVisuClient,
 		if pvClient.playerInfo != nil {
 			pvClient.playerInfo.IsConnected = false
 		}
+		globalStats.playerDisconnected(pvClient.playerID)
+		if gs.GameState == GAME_RUNNING && len(gs.GameLogic) > 0 &&
+			pvClient.playerID >= 0 {
+			gs.GameLogic[0].playerLeft(pvClient.playerID, code)
+			// The first turn no longer waits for it
+			gs.GameLogic[0].gameStartsAcks.ack(pvClient.playerID)
+		}
 
 		if pvClient.isSpecialPlayer {
 			// Locate the player in the array
@@ -212,6 +493,11 @@ func KickLoggedPlayerOrVisu(pvClient *PlayerOrVisuClient,
 			}
 		}
 
+		if visuIndex != -1 && gs.GameState == GAME_RUNNING &&
+			len(gs.GameLogic) > 0 {
+			gs.GameLogic[0].visuChanged(pvClient.client.nickname, false)
+		}
+
 		if visuIndex != -1 {
 			// Remove the visu by placing it at the end of the slice,
 			// then reducing the slice length
@@ -219,11 +505,41 @@ func KickLoggedPlayerOrVisu(pvClient *PlayerOrVisuClient,
 			gs.Visus = gs.Visus[:len(gs.Visus)-1]
 		}
 	}
+	broadcastLobby(gs)
 
 	UnlockGlobalStateMutex(gs, "Kick player or visu", "player/visu")
+	if !pvClient.isPlayer {
+		globalTurnHistory.leave(pvClient)
+	}
 
 	// Kick the client
-	Kick(pvClient.client, reason)
+	Kick(pvClient.client, code, reason)
+}
+
+func sendLobby(client *Client, msg MessageLobby) error {
+	content, err := json.Marshal(msg)
+	if err == nil {
+		log.WithFields(log.Fields{
+			"nickname":       client.nickname,
+			"remote address": client.Conn.RemoteAddr(),
+			"content":        string(content),
+		}).Debug("Sending LOBBY to client")
+		err = sendMessage(client, content)
+	}
+	return err
+}
+
+func sendCountdown(client *Client, msg MessageCountdown) error {
+	content, err := json.Marshal(msg)
+	if err == nil {
+		log.WithFields(log.Fields{
+			"nickname":       client.nickname,
+			"remote address": client.Conn.RemoteAddr(),
+			"content":        string(content),
+		}).Debug("Sending COUNTDOWN to client")
+		err = sendMessage(client, content)
+	}
+	return err
 }
 
 func sendGameStarts(client *Client, msg MessageGameStarts) error {
@@ -239,15 +555,22 @@ func sendGameStarts(client *Client, msg MessageGameStarts) error {
 	return err
 }
 
-func sendTurn(client *Client, msg MessageTurn) error {
-	content, err := json.Marshal(msg)
+// Sends a TURN to a client, through the workers that write TURN messages
+// if there are any.
+func sendTurn(client *Client, msg MessageTurn, writers *turnWritePool) error {
+	content, err := encodeTurn(msg)
 	if err == nil {
 		log.WithFields(log.Fields{
 			"nickname":       client.nickname,
 			"remote address": client.Conn.RemoteAddr(),
 			"content":        string(content),
 		}).Debug("Sending TURN to client")
-		err = sendMessage(client, content)
+		err = writers.write(client, content)
+	}
+
+	if err == nil {
+		globalStats.turnSent(msg.TurnNumber,
+			client.capabilities.frameSize(len(content)))
 	}
 	return err
 }
@@ -0,0 +1,77 @@
+package netorcai
+
+import (
+	"crypto/subtle"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The address the control endpoints are served on by default: Only local
+// operators can reach them.
+const DefaultControlAddress = "127.0.0.1"
+
+// Reads the secret token that authenticates control requests from a file.
+// Surrounding whitespace is ignored, but the token must not be empty.
+func LoadControlToken(filename string) (string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("No token in %v", filename)
+	}
+	return token, nil
+}
+
+// Only calls handler on requests that carry the token
+// (Authorization: Bearer <token>). As browsers do not send this header on
+// their own, web pages cannot forge control requests either.
+func requireToken(token string, handler http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		received := []byte(r.Header.Get("Authorization"))
+		if token == "" ||
+			subtle.ConstantTimeCompare(received, expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Missing or invalid token",
+				http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Serves the control HTTP endpoints, that act on the running game (admin
+// messages, game stop and game abort), on an address and a port.
+// Unlike the administration endpoints, which are public, every request must
+// carry the token.
+func RunControlServer(address string, port int, token string,
+	gs *GlobalState, onexit chan int) {
+	// Only served once netorcai listens incoming connections, so that
+	// "Listening incoming connections" is always logged first
+	<-gs.listeningChannel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/broadcast", requireToken(token, handleBroadcast(gs)))
+	mux.HandleFunc("/stop", requireToken(token, handleStop(gs)))
+	mux.HandleFunc("/abort", requireToken(token, handleAbort(gs)))
+
+	listenAddress := net.JoinHostPort(address, strconv.Itoa(port))
+	log.WithFields(log.Fields{
+		"listen address": listenAddress,
+	}).Info("Serving control endpoints")
+
+	err := http.ListenAndServe(listenAddress, mux)
+	log.WithFields(log.Fields{
+		"err":            err,
+		"listen address": listenAddress,
+	}).Error("Control server stopped")
+	onexit <- 1
+}
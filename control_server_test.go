@@ -0,0 +1,65 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadControlToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-control")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "token")
+	ioutil.WriteFile(filename, []byte("  s3cr3t\n"), 0600)
+	token, err := LoadControlToken(filename)
+	assert.NoError(t, err, "Cannot load token")
+	assert.Equal(t, "s3cr3t", token, "Unexpected token")
+
+	ioutil.WriteFile(filename, []byte(" \n"), 0600)
+	_, err = LoadControlToken(filename)
+	assert.Error(t, err, "Empty tokens should be rejected")
+
+	_, err = LoadControlToken(filepath.Join(dir, "missing"))
+	assert.Error(t, err, "Missing files should be rejected")
+}
+
+func TestRequireToken(t *testing.T) {
+	handler := requireToken("s3cr3t", func(w http.ResponseWriter,
+		r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for authorization, expectedCode := range map[string]int{
+		"":               http.StatusUnauthorized,
+		"s3cr3t":         http.StatusUnauthorized,
+		"Bearer wrong":   http.StatusUnauthorized,
+		"Bearer s3cr3t2": http.StatusUnauthorized,
+		"Bearer s3cr3t":  http.StatusNoContent,
+	} {
+		request := httptest.NewRequest("POST", "/abort", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		assert.Equal(t, expectedCode, recorder.Code,
+			"Unexpected status code with authorization '%v'", authorization)
+	}
+
+	// Without token, nothing is served
+	handler = requireToken("", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := httptest.NewRequest("POST", "/abort", nil)
+	request.Header.Set("Authorization", "Bearer ")
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code,
+		"Requests should be rejected without token")
+}
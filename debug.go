@@ -0,0 +1,224 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Debugging features that can be enabled from the command-line.
+type debugOptions struct {
+	dumpStatesDirectory string
+	logStateDiffs       bool
+	// Logs the cost of each codec on every game state (see encoding.go)
+	reportEncoding bool
+	// Signs the manifest of the dumped files (nil if it is not written)
+	signingKey *SigningKey
+	// The dumped files, listed by the manifest at the end of the game
+	manifest *replayManifest
+}
+
+type gameDump struct {
+	NbPlayers        int                    `json:"nb_players"`
+	NbSpecialPlayers int                    `json:"nb_special_players"`
+	NbTurnsMax       int                    `json:"nb_turns_max"`
+	ServerConfig     *ServerConfig          `json:"server_config,omitempty"`
+	Seed             *int64                 `json:"seed,omitempty"`
+	InitialGameState map[string]interface{} `json:"initial_game_state"`
+	// Random numbers reported by the game logic (nil if none)
+	InitialRandomDraws []interface{} `json:"initial_random_draws,omitempty"`
+}
+
+type turnDump struct {
+	TurnNumber    int                         `json:"turn_number"`
+	GameState     map[string]interface{}      `json:"game_state"`
+	PlayerActions []MessageDoTurnPlayerAction `json:"player_actions"`
+	RandomDraws   []interface{}               `json:"random_draws,omitempty"`
+	// The players that left right before the turn was computed
+	PlayersLeft []MessagePlayerLeft `json:"players_left,omitempty"`
+	// The number of turns sent in the DO_TURN, if changed during the game
+	NbTurnsMax int `json:"nb_turns_max,omitempty"`
+	// Whether the DO_TURN was flagged as the last one
+	LastTurn bool `json:"last_turn,omitempty"`
+	// Whether the game logic was not invoked, as no player acted
+	// (--skip-idle-turns)
+	Idle bool `json:"idle,omitempty"`
+	// The players that missed their action deadline
+	MissedDeadlines []int `json:"missed_deadlines,omitempty"`
+}
+
+// Called by the GL coroutine every time a new game state is received.
+func debugNewGameState(glClient *GameLogicClient, debug debugOptions,
+	turnNumber int, gameState map[string]interface{},
+	randomDraws []interface{}) {
+	dumpTurn(debug, turnNumber, gameState, glClient.lastPlayerActions,
+		glClient.lastDepartures, glClient.nbTurnsMax, glClient.lastTurn,
+		glClient.idleTurn, glClient.lastMissedDeadlines, randomDraws)
+	if debug.reportEncoding {
+		globalEncodingReport.reportTurn(turnNumber, gameState)
+	}
+
+	if debug.logStateDiffs && log.IsLevelEnabled(log.DebugLevel) {
+		added, removed, changed := diffGameStates(glClient.lastGameState,
+			gameState)
+		log.WithFields(log.Fields{
+			"turn number": turnNumber,
+			"added":       strings.Join(added, ","),
+			"removed":     strings.Join(removed, ","),
+			"changed":     strings.Join(changed, ","),
+		}).Debug("Game state diff")
+	}
+
+	glClient.lastGameState = gameState
+}
+
+// Writes the game parameters (including the seed handed to the game logic,
+// if any) and the initial game state into the game.json file of the dump
+// directory. Does nothing if no directory has been set.
+func dumpGame(debug debugOptions, nbPlayers, nbSpecialPlayers, nbTurnsMax int,
+	serverConfig ServerConfig, seed *int64,
+	initialGameState map[string]interface{},
+	initialRandomDraws []interface{}) {
+	if debug.dumpStatesDirectory == "" {
+		return
+	}
+
+	dump := gameDump{
+		NbPlayers:        nbPlayers,
+		NbSpecialPlayers: nbSpecialPlayers,
+		NbTurnsMax:       nbTurnsMax,
+		ServerConfig:     &serverConfig,
+		Seed:             seed,
+		InitialGameState: initialGameState,
+
+		InitialRandomDraws: initialRandomDraws,
+	}
+
+	filename := filepath.Join(debug.dumpStatesDirectory, "game.json")
+	content, err := json.MarshalIndent(dump, "", "  ")
+	if err == nil {
+		err = writeDumpFile(debug, filename, content)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":      err,
+			"filename": filename,
+		}).Warn("Cannot dump game parameters")
+	}
+}
+
+// Writes the game state of a turn (and the actions, departures and random
+// draws that led to it) into a turn_NNNN.json file of the dump directory.
+// Does nothing if no directory has been set.
+func dumpTurn(debug debugOptions, turnNumber int,
+	gameState map[string]interface{},
+	playerActions []MessageDoTurnPlayerAction,
+	playersLeft []MessagePlayerLeft, nbTurnsMax int, lastTurn, idle bool,
+	missedDeadlines []int, randomDraws []interface{}) {
+	if debug.dumpStatesDirectory == "" {
+		return
+	}
+
+	dump := turnDump{
+		TurnNumber:    turnNumber,
+		GameState:     gameState,
+		PlayerActions: playerActions,
+		RandomDraws:   randomDraws,
+		PlayersLeft:   playersLeft,
+		NbTurnsMax:    nbTurnsMax,
+		LastTurn:      lastTurn,
+		Idle:          idle,
+
+		MissedDeadlines: missedDeadlines,
+	}
+	if dump.PlayerActions == nil {
+		dump.PlayerActions = []MessageDoTurnPlayerAction{}
+	}
+
+	filename := filepath.Join(debug.dumpStatesDirectory,
+		fmt.Sprintf("turn_%04d.json", turnNumber))
+	content, err := json.MarshalIndent(dump, "", "  ")
+	if err == nil {
+		err = writeDumpFile(debug, filename, content)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":      err,
+			"filename": filename,
+		}).Warn("Cannot dump game state")
+	}
+}
+
+// Writes a file of the dump directory and records it into the manifest.
+func writeDumpFile(debug debugOptions, filename string, content []byte) error {
+	err := ioutil.WriteFile(filename, content, 0644)
+	if err == nil && debug.manifest != nil {
+		debug.manifest.add(filepath.Base(filename), content)
+	}
+	return err
+}
+
+// Writes the signed manifest of the dump directory, once the game has ended
+// and all the files have been dumped. Does nothing if no directory or no
+// signing key has been set.
+func dumpManifest(debug debugOptions) {
+	if debug.dumpStatesDirectory == "" || debug.signingKey == nil {
+		return
+	}
+
+	err := writeSignedManifest(debug.dumpStatesDirectory, debug.manifest,
+		debug.signingKey)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":       err,
+			"directory": debug.dumpStatesDirectory,
+		}).Warn("Cannot write the manifest of dumped files")
+	}
+}
+
+// Computes which keys have been added, removed or changed between two game
+// states. Nested objects are traversed and their keys are dot-separated.
+// Other values (including arrays) are compared as a whole.
+func diffGameStates(previous, current map[string]interface{}) (
+	added, removed, changed []string) {
+	added, removed, changed = []string{}, []string{}, []string{}
+	diffObjects(previous, current, "", &added, &removed, &changed)
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+func diffObjects(previous, current map[string]interface{}, prefix string,
+	added, removed, changed *[]string) {
+	for key, previousValue := range previous {
+		currentValue, exists := current[key]
+		if !exists {
+			*removed = append(*removed, prefix+key)
+			continue
+		}
+
+		previousObject, isPreviousObject := previousValue.(map[string]interface{})
+		currentObject, isCurrentObject := currentValue.(map[string]interface{})
+		if isPreviousObject && isCurrentObject {
+			diffObjects(previousObject, currentObject, prefix+key+".",
+				added, removed, changed)
+		} else if !reflect.DeepEqual(previousValue, currentValue) {
+			*changed = append(*changed, prefix+key)
+		}
+	}
+
+	for key := range current {
+		if _, exists := previous[key]; !exists {
+			*added = append(*added, prefix+key)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffGameStates(t *testing.T) {
+	var previous, current map[string]interface{}
+	json.Unmarshal([]byte(`{"a":1, "b":[1,2], "c":{"x":1, "y":2}, "d":"meh"}`), &previous)
+	json.Unmarshal([]byte(`{"a":1, "b":[1,3], "c":{"x":1, "z":2}, "e":0}`), &current)
+
+	added, removed, changed := diffGameStates(previous, current)
+	assert.Equal(t, []string{"c.z", "e"}, added, "Unexpected added keys")
+	assert.Equal(t, []string{"c.y", "d"}, removed, "Unexpected removed keys")
+	assert.Equal(t, []string{"b"}, changed, "Unexpected changed keys")
+
+	added, removed, changed = diffGameStates(current, current)
+	assert.Empty(t, added, "Keys added between identical states")
+	assert.Empty(t, removed, "Keys removed between identical states")
+	assert.Empty(t, changed, "Keys changed between identical states")
+}
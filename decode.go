@@ -0,0 +1,132 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Maximum nesting depth of objects and arrays in received messages.
+const decodeMaxDepth = 64
+
+// Limits enforced while decoding the content of a received message,
+// so that huge payloads are rejected as soon as a limit is exceeded,
+// instead of after being fully received and materialized.
+// The total size of messages is bounded by the frame readers.
+type decodeLimits struct {
+	// Maximum nesting depth of objects and arrays
+	maxDepth int
+	// Maximum number of elements of an array (e.g., the actions of a
+	// TURN_ACK). 0 means unlimited
+	maxArrayLength int
+}
+
+// A message that exceeds a decoding limit, as opposed to invalid JSON.
+type decodeLimitError struct {
+	message string
+}
+
+func (e *decodeLimitError) Error() string {
+	return e.message
+}
+
+func newDecodeLimitError(format string, a ...interface{}) *decodeLimitError {
+	return &decodeLimitError{fmt.Sprintf(format, a...)}
+}
+
+// Decodes the content of a message, which must be a JSON object, as it is
+// read from reader (see frameReader).
+// Values are decoded as by json.Unmarshal into an interface{}.
+func decodeMessage(reader io.Reader, limits decodeLimits) (
+	map[string]interface{}, error) {
+	decoder := json.NewDecoder(reader)
+	value, err := decodeValue(decoder, limits, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Like json.Unmarshal, reject any data after the value
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Invalid data after the top-level value")
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	object, isObject := value.(map[string]interface{})
+	if !isObject {
+		return nil, fmt.Errorf("Non-object message")
+	}
+	return object, nil
+}
+
+// Decodes the next value of decoder, at the given depth and path.
+func decodeValue(decoder *json.Decoder, limits decodeLimits, depth int,
+	path string) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, isDelim := token.(json.Delim)
+	if !isDelim {
+		// string, float64, bool or nil
+		return token, nil
+	}
+
+	if depth >= limits.maxDepth {
+		return nil, newDecodeLimitError(
+			"Message is nested too deeply: %v is deeper than %v levels",
+			pathOrRoot(path), limits.maxDepth)
+	}
+
+	switch delim {
+	case '{':
+		object := make(map[string]interface{})
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			key := keyToken.(string)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			object[key], err = decodeValue(decoder, limits, depth+1, keyPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = decoder.Token() // }
+		return object, err
+	case '[':
+		array := []interface{}{}
+		for decoder.More() {
+			if limits.maxArrayLength > 0 && len(array) >= limits.maxArrayLength {
+				return nil, newDecodeLimitError(
+					"Array is too long: %v has more than %v elements",
+					pathOrRoot(path), limits.maxArrayLength)
+			}
+
+			element, err := decodeValue(decoder, limits, depth+1,
+				fmt.Sprintf("%v[%v]", path, len(array)))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		_, err = decoder.Token() // ]
+		return array, err
+	}
+
+	return nil, fmt.Errorf("Unexpected delimiter '%v'", delim)
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "the message"
+	}
+	return path
+}
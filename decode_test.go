@@ -0,0 +1,70 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMessage(t *testing.T) {
+	limits := decodeLimits{maxDepth: decodeMaxDepth}
+	for _, content := range []string{
+		`{}`,
+		`null`,
+		`{"message_type":"TURN_ACK", "turn_number":4, "actions":[1, "a", null]}`,
+		`{"a":{"b":[{"c":true}, [], {}], "d":-1.5e3}, "e":"é"}`,
+	} {
+		var expected map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(content), &expected))
+
+		decoded, err := decodeMessage(strings.NewReader(content), limits)
+		assert.NoError(t, err, "Cannot decode %v", content)
+		assert.Equal(t, expected, decoded, "Unexpected decoding of %v", content)
+	}
+
+	for _, content := range []string{``, `{`, `[]`, `42`, `{}{}`, `{"a":NaN}`,
+		`{"a":1,}`} {
+		_, err := decodeMessage(strings.NewReader(content), limits)
+		assert.Error(t, err, "No error on %v", content)
+		_, exceedsLimit := err.(*decodeLimitError)
+		assert.False(t, exceedsLimit, "Limit error on %v", content)
+	}
+}
+
+func TestDecodeMessageLimits(t *testing.T) {
+	limits := decodeLimits{maxDepth: 3, maxArrayLength: 2}
+
+	_, err := decodeMessage(strings.NewReader(`{"a":{"b":[1, 2]}}`), limits)
+	assert.NoError(t, err, "Error within the limits")
+
+	_, err = decodeMessage(strings.NewReader(`{"a":{"b":[1, 2, 3]}}`), limits)
+	assert.EqualError(t, err,
+		"Array is too long: a.b has more than 2 elements")
+
+	_, err = decodeMessage(strings.NewReader(`{"a":{"b":[[1]]}}`), limits)
+	assert.EqualError(t, err,
+		"Message is nested too deeply: a.b[0] is deeper than 3 levels")
+
+	// Limits are checked before the rest of the message is parsed
+	content := `{"actions":[1, 2, 3` + strings.Repeat(`, 4`, 100000)
+	_, err = decodeMessage(strings.NewReader(content), limits)
+	_, exceedsLimit := err.(*decodeLimitError)
+	assert.True(t, exceedsLimit, "Not a limit error: %v", err)
+
+	// ... and before the rest of the message is received
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte(`{"actions":[1, 2, 3,`))
+		writer.CloseWithError(fmt.Errorf("Read after the limit"))
+	}()
+	_, err = decodeMessage(reader, limits)
+	assert.EqualError(t, err,
+		"Array is too long: actions has more than 2 elements")
+
+	limits.maxArrayLength = 0
+	_, err = decodeMessage(strings.NewReader(`{"a":[1, 2, 3]}`), limits)
+	assert.NoError(t, err, "Arrays should be unlimited")
+}
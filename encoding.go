@@ -0,0 +1,131 @@
+package netorcai
+
+import (
+	"bytes"
+	"compress/flate"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// The codecs whose cost is reported by --report-encoding, besides JSON.
+// They are applied to the JSON content, as in compressed v2 frames
+// (raw DEFLATE, whatever the compression level).
+var reportedCodecs = []struct {
+	name  string
+	level int
+}{
+	{"deflate", flate.DefaultCompression},
+	{"deflate-fast", flate.BestSpeed},
+	{"deflate-best", flate.BestCompression},
+}
+
+// Total cost of a codec over the turns of the game.
+type codecCost struct {
+	nbTurns      int
+	rawBytes     int
+	encodedBytes int
+	encodeTime   time.Duration
+}
+
+// The cost of serializing game states with each codec, so that operators
+// can decide which negotiation options are worth enabling for their game.
+type encodingReport struct {
+	mutex sync.Mutex
+	// Codec names, in report order
+	codecs []string
+	costs  map[string]*codecCost
+}
+
+var (
+	globalEncodingReport = &encodingReport{costs: make(map[string]*codecCost)}
+)
+
+// Compresses content as the payload of a compressed v2 frame.
+func deflate(content []byte, level int) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(content)
+	if err == nil {
+		err = writer.Close()
+	}
+	return compressed.Bytes(), err
+}
+
+// Serializes the game state of a turn with each codec, and logs the sizes
+// and encode times. The encode time of a codec excludes JSON serialization.
+func (r *encodingReport) reportTurn(turnNumber int,
+	gameState map[string]interface{}) {
+	start := time.Now()
+	content, err := jsonBackend.Marshal(gameState)
+	if err != nil {
+		return
+	}
+	r.record(turnNumber, "json", len(content), len(content), time.Since(start))
+
+	for _, codec := range reportedCodecs {
+		start = time.Now()
+		encoded, err := deflate(content, codec.level)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":   err,
+				"codec": codec.name,
+			}).Warn("Cannot encode game state")
+			continue
+		}
+		r.record(turnNumber, codec.name, len(content), len(encoded),
+			time.Since(start))
+	}
+}
+
+func (r *encodingReport) record(turnNumber int, codec string,
+	rawBytes, encodedBytes int, encodeTime time.Duration) {
+	log.WithFields(log.Fields{
+		"turn number":      turnNumber,
+		"codec":            codec,
+		"raw bytes":        rawBytes,
+		"encoded bytes":    encodedBytes,
+		"encode time (ms)": encodeTime.Seconds() * 1000,
+	}).Info("Turn encoding cost")
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cost, exists := r.costs[codec]
+	if !exists {
+		cost = &codecCost{}
+		r.costs[codec] = cost
+		r.codecs = append(r.codecs, codec)
+	}
+	cost.nbTurns++
+	cost.rawBytes += rawBytes
+	cost.encodedBytes += encodedBytes
+	cost.encodeTime += encodeTime
+}
+
+// Logs the cost of each codec over the turns of the game.
+func (r *encodingReport) logSummary() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, codec := range r.codecs {
+		cost := r.costs[codec]
+		ratio := 1.0
+		if cost.rawBytes > 0 {
+			ratio = float64(cost.encodedBytes) / float64(cost.rawBytes)
+		}
+
+		log.WithFields(log.Fields{
+			"codec":                 codec,
+			"turns":                 cost.nbTurns,
+			"total raw bytes":       cost.rawBytes,
+			"total encoded bytes":   cost.encodedBytes,
+			"ratio":                 ratio,
+			"mean encode time (ms)": cost.encodeTime.Seconds() * 1000 / float64(cost.nbTurns),
+		}).Info("Encoding report")
+	}
+}
@@ -0,0 +1,41 @@
+package netorcai
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDeflate(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"x":1},`), 100)
+	for _, codec := range reportedCodecs {
+		compressed, err := deflate(content, codec.level)
+		assert.NoError(t, err, "Cannot compress with %v", codec.name)
+		assert.True(t, len(compressed) < len(content),
+			"%v did not compress", codec.name)
+
+		inflated, err := readTestFrameV2(frameV2(compressed,
+			frameFlagCompressed))
+		assert.NoError(t, err, "Cannot decompress %v", codec.name)
+		assert.Equal(t, content, inflated, "%v content mismatch", codec.name)
+	}
+}
+
+func TestEncodingReport(t *testing.T) {
+	report := &encodingReport{costs: make(map[string]*codecCost)}
+	report.reportTurn(0, map[string]interface{}{"x": 1})
+	report.record(1, "json", 10, 10, time.Millisecond)
+
+	expectedCodecs := []string{"json"}
+	for _, codec := range reportedCodecs {
+		expectedCodecs = append(expectedCodecs, codec.name)
+	}
+	assert.Equal(t, expectedCodecs, report.codecs)
+
+	jsonCost := report.costs["json"]
+	assert.Equal(t, 2, jsonCost.nbTurns)
+	assert.Equal(t, len(`{"x":1}`)+10, jsonCost.rawBytes)
+	assert.Equal(t, jsonCost.rawBytes, jsonCost.encodedBytes)
+	assert.Equal(t, 1, report.costs["deflate"].nbTurns)
+}
@@ -0,0 +1,315 @@
+package netorcai
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Frame formats of the messages, negotiated at LOGIN.
+// LOGIN and LOGIN_ACK are always v1 frames.
+const (
+	// 32-bit little-endian size (content size + 1), content, then '\n'
+	framingV1 = 1
+	// Varint payload size, flags byte, then payload
+	framingV2 = 2
+)
+
+// Flags of v2 frames.
+const (
+	// The payload is compressed (raw DEFLATE)
+	frameFlagCompressed byte = 0x01
+	// The encoding of the payload (2 bits). Only JSON (0) is supported
+	frameFlagEncoding byte = 0x06
+	// The message continues in the next frame
+	frameFlagChunked byte = 0x08
+	// Reserved for future use, must be unset
+	frameFlagsReserved byte = 0xF0
+)
+
+// An invalid frame, as opposed to a frame that could not be read.
+type invalidFrameError struct {
+	message string
+}
+
+func (e *invalidFrameError) Error() string {
+	return e.message
+}
+
+func newInvalidFrameError(format string, a ...interface{}) *invalidFrameError {
+	return &invalidFrameError{fmt.Sprintf(format, a...)}
+}
+
+func newReadError(err error) error {
+	return fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+}
+
+// Returns the frame format asked by a LOGIN message (v1 unless valid v2).
+func loginFraming(data map[string]interface{}) int {
+	framing, err := ReadInt(data, "framing")
+	if err == nil && framing == framingV2 {
+		return framingV2
+	}
+	return framingV1
+}
+
+// Reads the content of a received message, from its frames.
+// Frames are read from the connection as the content is consumed, so that a
+// reader that stops early (e.g., on a decoding limit) does not receive the
+// rest of the message.
+type frameReader struct {
+	reader             *bufio.Reader
+	maximumAllowedSize uint32
+	// Payload of the current frame, and its decompressor if it is compressed
+	payload  payloadReader
+	inflater io.ReadCloser
+	// Whether the message continues in the next frame (v2)
+	chunked bool
+	// Size read from the v1 frame header, and size of the content read so far
+	headerSize  uint32
+	contentSize uint64
+	// Error that stopped the reads, if any
+	err error
+}
+
+// The payload of a frame, whose size is known from the frame header.
+type payloadReader struct {
+	reader    *bufio.Reader
+	remaining uint64
+	err       error
+}
+
+func (p *payloadReader) Read(buf []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if p.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if uint64(len(buf)) > p.remaining {
+		buf = buf[:p.remaining]
+	}
+	n, err := p.reader.Read(buf)
+	p.remaining -= uint64(n)
+	if err != nil && p.remaining > 0 {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		p.err = newReadError(err)
+		return n, p.err
+	}
+	return n, nil
+}
+
+// Reads the header of the first frame of a message framed as framing.
+// On error, the returned frameReader only gives the size of the message.
+func newFrameReader(reader *bufio.Reader, framing int,
+	maximumAllowedSize uint32, errorFormatOnTooBigMessage string) (
+	*frameReader, error) {
+	frame := &frameReader{
+		reader:             reader,
+		maximumAllowedSize: maximumAllowedSize,
+	}
+	if framing == framingV2 {
+		frame.err = frame.nextFrameV2()
+	} else {
+		frame.err = frame.frameV1(errorFormatOnTooBigMessage)
+	}
+	return frame, frame.err
+}
+
+// Returns the size of the message: the size in the header of a v1 frame, or
+// the size of the content read so far from v2 frames.
+func (frame *frameReader) size() uint32 {
+	if frame.headerSize > 0 {
+		return frame.headerSize
+	}
+	return uint32(frame.contentSize)
+}
+
+// Reads the header of a v1 frame.
+func (frame *frameReader) frameV1(errorFormatOnTooBigMessage string) error {
+	// Receive message content size
+	contentSizeBuf := make([]byte, 4)
+	_, err := io.ReadFull(frame.reader, contentSizeBuf)
+	if err != nil {
+		return newReadError(err)
+	}
+
+	// Read message content size
+	frame.headerSize = binary.LittleEndian.Uint32(contentSizeBuf)
+	if frame.headerSize > frame.maximumAllowedSize {
+		return newInvalidFrameError(errorFormatOnTooBigMessage,
+			frame.headerSize)
+	}
+
+	frame.payload = payloadReader{
+		reader:    frame.reader,
+		remaining: uint64(frame.headerSize),
+	}
+	return nil
+}
+
+// Reads the header of the next v2 frame of a message.
+func (frame *frameReader) nextFrameV2() error {
+	payloadSize, err := readVarintSize(frame.reader)
+	if err != nil {
+		return err
+	}
+	remainingSize := uint64(frame.maximumAllowedSize) - frame.contentSize
+	if payloadSize > remainingSize {
+		return newInvalidFrameError(
+			"Received message size is too big: %v exceeds %v bytes",
+			frame.contentSize+payloadSize, frame.maximumAllowedSize)
+	}
+
+	flags, err := frame.reader.ReadByte()
+	if err != nil {
+		return newReadError(err)
+	}
+	if flags&frameFlagsReserved != 0 {
+		return newInvalidFrameError("Reserved frame flags are set: %#02x",
+			flags)
+	}
+	if flags&frameFlagEncoding != 0 {
+		return newInvalidFrameError(
+			"Unsupported frame encoding %v (only JSON is supported)",
+			(flags&frameFlagEncoding)>>1)
+	}
+
+	frame.chunked = flags&frameFlagChunked != 0
+	frame.payload = payloadReader{
+		reader:    frame.reader,
+		remaining: payloadSize,
+	}
+	frame.inflater = nil
+	if flags&frameFlagCompressed != 0 {
+		frame.inflater = flate.NewReader(&frame.payload)
+	}
+	return nil
+}
+
+// Reads the content of the message. Returns io.EOF at its end.
+func (frame *frameReader) Read(buf []byte) (int, error) {
+	for frame.err == nil {
+		n, err := frame.readPayload(buf)
+		frame.contentSize += uint64(n)
+		if frame.contentSize > uint64(frame.maximumAllowedSize) {
+			// Only decompressed payloads can exceed the size of their frame
+			frame.err = newInvalidFrameError(
+				"Decompressed message size is too big: it exceeds %v bytes",
+				frame.maximumAllowedSize)
+			return 0, frame.err
+		}
+
+		if err == io.EOF && frame.chunked {
+			err = frame.nextFrameV2()
+			if err == nil && n == 0 {
+				continue
+			}
+		}
+		if err != io.EOF {
+			frame.err = err
+		}
+		return n, err
+	}
+	return 0, frame.err
+}
+
+// Reads the payload of the current frame, decompressed if needed.
+func (frame *frameReader) readPayload(buf []byte) (int, error) {
+	if frame.inflater == nil {
+		return frame.payload.Read(buf)
+	}
+
+	n, err := frame.inflater.Read(buf)
+	if frame.payload.err != nil {
+		return n, frame.payload.err
+	}
+	if err == io.EOF {
+		// Skip what may follow the compressed data in the payload
+		if _, err = io.Copy(ioutil.Discard, &frame.payload); err != nil {
+			return n, err
+		}
+		return n, io.EOF
+	} else if err != nil {
+		return n, newInvalidFrameError("Cannot decompress frame: %v", err)
+	}
+	return n, nil
+}
+
+// Reads a whole message framed as framing.
+// Returns its content and its size (see frameReader.size).
+func readFrame(reader *bufio.Reader, framing int, maximumAllowedSize uint32,
+	errorFormatOnTooBigMessage string) ([]byte, uint32, error) {
+	frame, err := newFrameReader(reader, framing, maximumAllowedSize,
+		errorFormatOnTooBigMessage)
+	if err != nil {
+		return nil, frame.size(), err
+	}
+
+	content, err := ioutil.ReadAll(frame)
+	if err != nil {
+		return nil, frame.size(), err
+	}
+	return content, frame.size(), nil
+}
+
+// Reads the varint size of a v2 frame.
+func readVarintSize(reader *bufio.Reader) (uint64, error) {
+	var size uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, newReadError(err)
+		}
+		if shift >= 35 {
+			return 0, newInvalidFrameError("Frame size varint is too long")
+		}
+
+		size |= uint64(b&0x7F) << shift
+		if b < 0x80 {
+			return size, nil
+		}
+	}
+}
+
+// Writes a message as a v1 frame. Returns the size written in its header.
+func writeFrameV1(writer *bufio.Writer, content []byte) (uint32, error) {
+	// Write content size
+	var contentSize uint32 = uint32(len(content)) + 1 // +1 for \n
+	contentSizeBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(contentSizeBuf, contentSize)
+	_, err := writer.Write(contentSizeBuf)
+	if err != nil {
+		return contentSize, err
+	}
+
+	// Write content
+	_, err = writer.Write(content)
+	if err != nil {
+		return contentSize, err
+	}
+
+	// Write terminating "\n" character
+	return contentSize, writer.WriteByte(0x0A)
+}
+
+// Writes a message as a v2 frame (uncompressed JSON, in a single frame).
+// Returns the size written in its header.
+func writeFrameV2(writer *bufio.Writer, content []byte) (uint32, error) {
+	header := make([]byte, binary.MaxVarintLen64+1)
+	headerSize := binary.PutUvarint(header, uint64(len(content)))
+	header[headerSize] = 0 // flags
+	_, err := writer.Write(header[:headerSize+1])
+	if err != nil {
+		return uint32(len(content)), err
+	}
+
+	_, err = writer.Write(content)
+	return uint32(len(content)), err
+}
@@ -0,0 +1,85 @@
+package netorcai
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func frameV2(payload []byte, flags byte) []byte {
+	var frame bytes.Buffer
+	writer := bufio.NewWriter(&frame)
+	writeFrameV2(writer, payload)
+	writer.Flush()
+
+	// Patch the flags byte, right after the varint size
+	content := frame.Bytes()
+	content[len(content)-len(payload)-1] = flags
+	return content
+}
+
+func readTestFrameV2(frames ...[]byte) ([]byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(bytes.Join(frames, nil)))
+	content, _, err := readFrame(reader, framingV2, 1000, "")
+	return content, err
+}
+
+func TestReadFrameV2(t *testing.T) {
+	content, err := readTestFrameV2(frameV2([]byte(`{"a":1}`), 0))
+	assert.NoError(t, err, "Cannot read frame")
+	assert.Equal(t, `{"a":1}`, string(content))
+
+	// Chunked message
+	content, err = readTestFrameV2(frameV2([]byte(`{"a"`), frameFlagChunked),
+		frameV2([]byte(`:1}`), 0))
+	assert.NoError(t, err, "Cannot read chunked frames")
+	assert.Equal(t, `{"a":1}`, string(content))
+
+	// Compressed payload
+	var compressed bytes.Buffer
+	writer, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	writer.Write(bytes.Repeat([]byte("a"), 500))
+	writer.Close()
+	content, err = readTestFrameV2(frameV2(compressed.Bytes(),
+		frameFlagCompressed))
+	assert.NoError(t, err, "Cannot read compressed frame")
+	assert.Equal(t, 500, len(content))
+
+	// Multi-byte varint size
+	content, err = readTestFrameV2(frameV2(bytes.Repeat([]byte("b"), 300), 0))
+	assert.NoError(t, err, "Cannot read frame")
+	assert.Equal(t, 300, len(content))
+}
+
+func TestReadInvalidFrameV2(t *testing.T) {
+	for name, frames := range map[string][][]byte{
+		"reserved flags": {frameV2([]byte(`{}`), 0x10)},
+		"encoding":       {frameV2([]byte(`{}`), 0x02)},
+		"too big":        {frameV2(bytes.Repeat([]byte("a"), 1001), 0)},
+		"chunks too big": {frameV2(bytes.Repeat([]byte("a"), 600), frameFlagChunked),
+			frameV2(bytes.Repeat([]byte("a"), 600), 0)},
+		"not compressed": {frameV2([]byte(`{}`), frameFlagCompressed)},
+		"varint":         {bytes.Repeat([]byte{0xFF}, 6)},
+	} {
+		_, err := readTestFrameV2(frames...)
+		_, invalid := err.(*invalidFrameError)
+		assert.True(t, invalid, "%v: Invalid frame not detected (%v)", name, err)
+	}
+
+	// Truncated frames are read errors
+	frame := frameV2([]byte(`{"a":1}`), 0)
+	_, err := readTestFrameV2(frame[:len(frame)-1])
+	_, invalid := err.(*invalidFrameError)
+	assert.Error(t, err, "Truncated frame read")
+	assert.False(t, invalid, "Truncated frame is invalid")
+}
+
+func TestLoginFraming(t *testing.T) {
+	assert.Equal(t, framingV1, loginFraming(map[string]interface{}{}))
+	assert.Equal(t, framingV1, loginFraming(map[string]interface{}{
+		"framing": 3.0}))
+	assert.Equal(t, framingV2, loginFraming(map[string]interface{}{
+		"framing": 2.0}))
+}
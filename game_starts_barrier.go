@@ -0,0 +1,81 @@
+package netorcai
+
+import (
+	"sort"
+	"sync"
+)
+
+// Waits for the GAME_STARTS_ACK of every player before the first DO_TURN,
+// so that players that take long to load (e.g., heavy assets) do not miss
+// the first turns. Players that leave no longer count.
+// A nil barrier waits for nobody.
+type gameStartsBarrier struct {
+	mutex sync.Mutex
+	// Players that have not acknowledged GAME_STARTS yet
+	pending map[int]bool
+	// Whether the pending players are known (see expect)
+	expected bool
+	// Whether the barrier no longer waits (all players acknowledged, or
+	// the timeout expired)
+	released bool
+	// Closed when all the expected players have acknowledged
+	done chan int
+}
+
+func newGameStartsBarrier() *gameStartsBarrier {
+	return &gameStartsBarrier{
+		pending: make(map[int]bool),
+		done:    make(chan int),
+	}
+}
+
+// Sets the players to wait for, once their identifiers are known.
+func (b *gameStartsBarrier) expect(playerIDs []int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, playerID := range playerIDs {
+		b.pending[playerID] = true
+	}
+	b.expected = true
+	b.releaseIfDone()
+}
+
+// Called when a player sends GAME_STARTS_ACK, or leaves.
+// Late and duplicate acknowledgements are ignored.
+func (b *gameStartsBarrier) ack(playerID int) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.pending, playerID)
+	b.releaseIfDone()
+}
+
+// Must be called with the barrier mutex held.
+func (b *gameStartsBarrier) releaseIfDone() {
+	if b.expected && !b.released && len(b.pending) == 0 {
+		b.released = true
+		close(b.done)
+	}
+}
+
+// Stops waiting (the timeout expired).
+// Returns the players that have not acknowledged GAME_STARTS, sorted.
+func (b *gameStartsBarrier) release() []int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	missing := []int{}
+	for playerID := range b.pending {
+		missing = append(missing, playerID)
+	}
+	sort.Ints(missing)
+
+	b.pending = make(map[int]bool)
+	b.released = true
+	return missing
+}
@@ -0,0 +1,52 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func isBarrierDone(barrier *gameStartsBarrier) bool {
+	select {
+	case <-barrier.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestGameStartsBarrierAcks(t *testing.T) {
+	barrier := newGameStartsBarrier()
+	barrier.ack(1)
+	assert.False(t, isBarrierDone(barrier), "Done before players are known")
+
+	barrier.expect([]int{0, 1, 2})
+	barrier.ack(0)
+	barrier.ack(0)
+	assert.False(t, isBarrierDone(barrier), "Done while players are missing")
+
+	barrier.ack(2)
+	barrier.ack(1)
+	assert.True(t, isBarrierDone(barrier), "Not done once all players acked")
+	barrier.ack(1)
+}
+
+func TestGameStartsBarrierNoPlayer(t *testing.T) {
+	barrier := newGameStartsBarrier()
+	barrier.expect([]int{})
+	assert.True(t, isBarrierDone(barrier), "Waits without player")
+
+	var noBarrier *gameStartsBarrier
+	noBarrier.ack(0)
+}
+
+func TestGameStartsBarrierRelease(t *testing.T) {
+	barrier := newGameStartsBarrier()
+	barrier.expect([]int{3, 0, 1})
+	barrier.ack(1)
+	assert.Equal(t, []int{0, 3}, barrier.release(), "Unexpected missing players")
+
+	// Late acks are ignored
+	barrier.ack(0)
+	barrier.ack(3)
+	assert.False(t, isBarrierDone(barrier), "Done after the timeout")
+}
@@ -0,0 +1,417 @@
+// Package gamelogic implements the game logic side of the netorcai
+// metaprotocol, so that games can be written in Go by only implementing
+// the Game interface.
+package gamelogic
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"net"
+)
+
+// The game state, as sent to the clients (all_clients field).
+type State map[string]interface{}
+
+// The ID of the player that wins the game, or NoWinner.
+type Winner int
+
+const NoWinner Winner = -1
+
+// The actions sent by one player during one turn.
+type PlayerActions struct {
+	PlayerID   int
+	Role       string
+	TurnNumber int
+	Actions    []interface{}
+}
+
+// Game is the interface implemented by game logics.
+// Init is called once when the game starts and returns the initial game state.
+// Turn is called for each turn with the actions of the players that
+// acknowledged the previous turn, and returns the new game state and the
+// current winner.
+// Panics in these methods make the game logic fail (Run returns an error).
+type Game interface {
+	Init(nbPlayers, nbSpecialPlayers, nbTurnsMax int) State
+	Turn(actions []PlayerActions) (State, Winner)
+}
+
+// SeededGame can be implemented by games that draw random numbers.
+// Seed is called before Init if netorcai hands a seed to the game logic
+// (netorcai --seed).
+type SeededGame interface {
+	Seed(seed int64)
+}
+
+// DrawReporter can be implemented by games that report the random numbers
+// they draw, so that netorcai records them for auditing.
+// RandomDraws is called after Init and after each Turn, and returns the
+// draws made since its previous call (nil if none).
+type DrawReporter interface {
+	RandomDraws() []interface{}
+}
+
+// DepartureHandler can be implemented by games that react to players leaving
+// the game (e.g., to redistribute their resources). If so, the game logic
+// asks netorcai for PLAYER_LEFT messages at LOGIN.
+// PlayerLeft is called right before the Turn that follows the departure,
+// with the last turn sent to the player and why it left (disconnect, kick
+// or timeout).
+type DepartureHandler interface {
+	PlayerLeft(playerID, turnNumber int, reason string)
+}
+
+// VisuWatcher can be implemented by games that adapt to their audience
+// (e.g., by pausing cinematics when no visualization is watching). If so,
+// the game logic asks netorcai for VISUS_CHANGED messages at LOGIN.
+// VisusChanged is called right before a Turn, with the number of
+// visualizations logged in and the nicknames of the ones that joined or left
+// since its previous call. The visualizations logged in when the game starts
+// are reported as having joined before the first Turn.
+type VisuWatcher interface {
+	VisusChanged(nbVisus int, joined, left []string)
+}
+
+// Connects to netorcai, logs in as a game logic and runs the game until
+// netorcai kicks the game logic (at the end of the game).
+// Returns the kick reason, or an error if the game could not be run.
+func Run(game Game, hostname string, port int, nickname string) (string, error) {
+	c := &client.Client{}
+	err := c.Connect(hostname, port)
+	if err != nil {
+		return "", err
+	}
+
+	return runClient(game, c, nickname)
+}
+
+// Same as Run, but uses an already established connection
+// (e.g. an in-process loopback connection to netorcai).
+func RunConn(game Game, conn net.Conn, nickname string) (string, error) {
+	c := &client.Client{}
+	c.ConnectConn(conn)
+	return runClient(game, c, nickname)
+}
+
+func runClient(game Game, c *client.Client, nickname string) (string, error) {
+	defer c.Disconnect()
+
+	login := map[string]interface{}{
+		"message_type":         "LOGIN",
+		"role":                 "game logic",
+		"nickname":             nickname,
+		"metaprotocol_version": netorcai.Version,
+	}
+	departureHandler, handlesDepartures := game.(DepartureHandler)
+	if handlesDepartures {
+		login["player_left"] = true
+	}
+	visuWatcher, watchesVisus := game.(VisuWatcher)
+	if watchesVisus {
+		login["visu_changes"] = true
+	}
+	err := c.SendJSON(login)
+	if err != nil {
+		return "", fmt.Errorf("Cannot send LOGIN. %v", err)
+	}
+
+	msg, err := readMessage(c, "LOGIN_ACK")
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		msg, err = readMessage(c, "DO_INIT", "DO_TURN", "PLAYER_LEFT",
+			"VISUS_CHANGED", "KICK")
+		if err != nil {
+			return "", err
+		}
+
+		switch msg["message_type"] {
+		case "PING":
+			err = c.SendJSON(map[string]interface{}{"message_type": "PONG"})
+		case "DO_INIT":
+			err = handleDoInit(c, game, msg)
+		case "DO_TURN":
+			err = handleDoTurn(c, game, msg)
+		case "PLAYER_LEFT":
+			if handlesDepartures {
+				err = handlePlayerLeft(departureHandler, msg)
+			}
+		case "VISUS_CHANGED":
+			if watchesVisus {
+				err = handleVisusChanged(visuWatcher, msg)
+			}
+		case "KICK":
+			return netorcai.ReadString(msg, "kick_reason")
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// Reads the next message and checks that its type is one of the expected
+// types. PING messages are always accepted, and KICK messages are turned
+// into errors if they are not expected (with the details of the ERROR
+// message that precedes them, if any).
+func readMessage(c *client.Client, expectedTypes ...string) (
+	map[string]interface{}, error) {
+	msg, err := c.ReadMessage()
+	if err != nil {
+		return msg, err
+	}
+
+	messageType, err := netorcai.ReadString(msg, "message_type")
+	if err != nil {
+		return msg, err
+	}
+
+	if messageType == "PING" {
+		return msg, nil
+	}
+
+	if messageType == "ERROR" {
+		errorMessage, _ := netorcai.ReadString(msg, "error_message")
+		fieldPath, _ := netorcai.ReadString(msg, "field_path")
+		msg, err = readMessage(c, expectedTypes...)
+		if err != nil {
+			return msg, fmt.Errorf("%v (invalid message: %v, field: '%v')",
+				err, errorMessage, fieldPath)
+		}
+		return msg, nil
+	}
+
+	for _, expectedType := range expectedTypes {
+		if messageType == expectedType {
+			return msg, nil
+		}
+	}
+
+	if messageType == "KICK" {
+		kickReason, _ := netorcai.ReadString(msg, "kick_reason")
+		return msg, fmt.Errorf("Kicked from netorcai. Reason: %v", kickReason)
+	}
+
+	return msg, fmt.Errorf("Unexpected message received: %v (expected %v)",
+		messageType, expectedTypes)
+}
+
+func handleDoInit(c *client.Client, game Game,
+	msg map[string]interface{}) error {
+	nbPlayers, err := netorcai.ReadInt(msg, "nb_players")
+	if err != nil {
+		return err
+	}
+
+	nbSpecialPlayers, err := netorcai.ReadInt(msg, "nb_special_players")
+	if err != nil {
+		return err
+	}
+
+	nbTurnsMax, err := netorcai.ReadInt(msg, "nb_turns_max")
+	if err != nil {
+		return err
+	}
+
+	var seed int
+	_, hasSeed := msg["seed"]
+	if hasSeed {
+		seed, err = netorcai.ReadInt(msg, "seed")
+		if err != nil {
+			return err
+		}
+	}
+
+	var initialState State
+	var draws []interface{}
+	err = protect(func() {
+		if seededGame, isSeeded := game.(SeededGame); isSeeded && hasSeed {
+			seededGame.Seed(int64(seed))
+		}
+		initialState = game.Init(nbPlayers, nbSpecialPlayers, nbTurnsMax)
+		draws = randomDraws(game)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.SendJSON(withRandomDraws(map[string]interface{}{
+		"message_type": "DO_INIT_ACK",
+		"initial_game_state": map[string]interface{}{
+			"all_clients": stateOrEmpty(initialState),
+		},
+	}, draws))
+}
+
+// Returns the random draws reported by the game (nil if none).
+func randomDraws(game Game) []interface{} {
+	if reporter, isReporter := game.(DrawReporter); isReporter {
+		return reporter.RandomDraws()
+	}
+	return nil
+}
+
+// Adds random draws (if any) to a message.
+func withRandomDraws(msg map[string]interface{},
+	draws []interface{}) map[string]interface{} {
+	if draws != nil {
+		msg["random_draws"] = draws
+	}
+	return msg
+}
+
+func handleDoTurn(c *client.Client, game Game,
+	msg map[string]interface{}) error {
+	actions, err := readPlayerActions(msg)
+	if err != nil {
+		return err
+	}
+
+	var state State
+	var winner Winner
+	var draws []interface{}
+	err = protect(func() {
+		state, winner = game.Turn(actions)
+		draws = randomDraws(game)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.SendJSON(withRandomDraws(map[string]interface{}{
+		"message_type":     "DO_TURN_ACK",
+		"winner_player_id": int(winner),
+		"game_state": map[string]interface{}{
+			"all_clients": stateOrEmpty(state),
+		},
+	}, draws))
+}
+
+func handlePlayerLeft(handler DepartureHandler,
+	msg map[string]interface{}) error {
+	playerID, err := netorcai.ReadInt(msg, "player_id")
+	if err != nil {
+		return err
+	}
+
+	turnNumber, err := netorcai.ReadInt(msg, "turn_number")
+	if err != nil {
+		return err
+	}
+
+	reason, err := netorcai.ReadString(msg, "reason")
+	if err != nil {
+		return err
+	}
+
+	return protect(func() {
+		handler.PlayerLeft(playerID, turnNumber, reason)
+	})
+}
+
+func handleVisusChanged(watcher VisuWatcher,
+	msg map[string]interface{}) error {
+	nbVisus, err := netorcai.ReadInt(msg, "nb_visus")
+	if err != nil {
+		return err
+	}
+
+	joined, err := readNicknames(msg, "joined")
+	if err != nil {
+		return err
+	}
+
+	left, err := readNicknames(msg, "left")
+	if err != nil {
+		return err
+	}
+
+	return protect(func() {
+		watcher.VisusChanged(nbVisus, joined, left)
+	})
+}
+
+func readNicknames(msg map[string]interface{}, field string) ([]string,
+	error) {
+	array, err := netorcai.ReadArray(msg, field)
+	if err != nil {
+		return nil, err
+	}
+
+	nicknames := make([]string, 0, len(array))
+	for index := range array {
+		nickname, err := netorcai.ReadElementString(array, index)
+		if err != nil {
+			return nil, netorcai.InField(field, err)
+		}
+		nicknames = append(nicknames, nickname)
+	}
+	return nicknames, nil
+}
+
+func readPlayerActions(msg map[string]interface{}) ([]PlayerActions, error) {
+	array, err := netorcai.ReadArray(msg, "player_actions")
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]PlayerActions, 0, len(array))
+	for index := range array {
+		object, err := netorcai.ReadElementObject(array, index)
+		if err != nil {
+			return nil, netorcai.InField("player_actions", err)
+		}
+
+		// Errors are reported with their path (e.g., player_actions[3].role)
+		elementPath := fmt.Sprintf("player_actions[%v]", index)
+
+		var playerActions PlayerActions
+		playerActions.PlayerID, err = netorcai.ReadInt(object, "player_id")
+		if err != nil {
+			return nil, netorcai.InField(elementPath, err)
+		}
+
+		playerActions.Role, err = netorcai.ReadString(object, "role")
+		if err != nil {
+			return nil, netorcai.InField(elementPath, err)
+		}
+
+		playerActions.TurnNumber, err = netorcai.ReadInt(object, "turn_number")
+		if err != nil {
+			return nil, netorcai.InField(elementPath, err)
+		}
+
+		playerActions.Actions, err = netorcai.ReadArray(object, "actions")
+		if err != nil {
+			return nil, netorcai.InField(elementPath, err)
+		}
+
+		actions = append(actions, playerActions)
+	}
+
+	return actions, nil
+}
+
+// Calls f, turning a panic of the game into an error
+// (so that the game logic fails instead of crashing the program).
+func protect(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Game panicked: %v", r)
+		}
+	}()
+
+	f()
+	return nil
+}
+
+// netorcai requires the game state to be an object, not null.
+func stateOrEmpty(state State) State {
+	if state == nil {
+		return State{}
+	}
+	return state
+}
@@ -0,0 +1,101 @@
+package netorcai
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+)
+
+type healthStatus struct {
+	Listening          bool   `json:"listening"`
+	GameState          string `json:"game_state"`
+	GameLogicConnected bool   `json:"game_logic_connected"`
+}
+
+func gameStateString(gameState int) string {
+	switch gameState {
+	case GAME_NOT_RUNNING:
+		return "not running"
+	case GAME_RUNNING:
+		return "running"
+	default:
+		return "finished"
+	}
+}
+
+func readHealthStatus(gs *GlobalState) healthStatus {
+	LockGlobalStateMutex(gs, "Health probe", "Admin server")
+	defer UnlockGlobalStateMutex(gs, "Health probe", "Admin server")
+
+	return healthStatus{
+		Listening:          gs.Listening,
+		GameState:          gameStateString(gs.GameState),
+		GameLogicConnected: len(gs.GameLogic) > 0,
+	}
+}
+
+func writeHealthStatus(w http.ResponseWriter, status healthStatus, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// Liveness probe: netorcai is alive as long as its global state can be read
+// and it still listens incoming connections.
+func handleHealthz(gs *GlobalState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := readHealthStatus(gs)
+		writeHealthStatus(w, status, status.Listening)
+	}
+}
+
+// Readiness probe: netorcai is ready once a game logic is connected,
+// until the game is finished.
+func handleReadyz(gs *GlobalState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := readHealthStatus(gs)
+		ready := status.Listening && status.GameLogicConnected &&
+			status.GameState != gameStateString(GAME_FINISHED)
+		writeHealthStatus(w, status, ready)
+	}
+}
+
+// Per-turn metrics, in the Prometheus text format.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	globalStats.writeMetrics(w)
+	globalTurnHistory.writeMetrics(w)
+}
+
+// Serves the administration HTTP endpoints (health probes, metrics,
+// the stream of turns and the web visualization) on a port.
+// The endpoints that act on the game are served by RunControlServer.
+func RunAdminServer(port int, gs *GlobalState, onexit chan int) {
+	// Only served once netorcai listens incoming connections, so that
+	// "Listening incoming connections" is always logged first
+	<-gs.listeningChannel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz(gs))
+	mux.HandleFunc("/readyz", handleReadyz(gs))
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/turns", handleTurnStream(globalTurnStream))
+	mux.HandleFunc("/", handleWebVisu)
+
+	listenAddress := ":" + strconv.Itoa(port)
+	log.WithFields(log.Fields{
+		"port": port,
+	}).Info("Serving administration endpoints")
+
+	err := http.ListenAndServe(listenAddress, mux)
+	log.WithFields(log.Fields{
+		"err":            err,
+		"listen address": listenAddress,
+	}).Error("Administration server stopped")
+	onexit <- 1
+}
@@ -0,0 +1,150 @@
+package netorcai
+
+import (
+	"bufio"
+	"github.com/mpoquet/go-prompt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	historyFilename = ".netorcai_history"
+	historyMaxSize  = 1000
+)
+
+var (
+	globalHistory       []string
+	globalHistorySearch struct {
+		pattern string
+		index   int
+		result  string
+	}
+)
+
+// Returns the path of the history file, in the home directory of the user
+// ($HOME if set).
+func historyFilepath() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, historyFilename), nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, historyFilename), nil
+}
+
+// Reads the prompt history of previous netorcai runs.
+// A missing history file is not an error. The file is trimmed to its last
+// historyMaxSize lines, so that it does not grow forever.
+func loadHistory() []string {
+	history := []string{}
+	filename, err := historyFilepath()
+	if err != nil {
+		return history
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithFields(log.Fields{
+				"err":      err,
+				"filename": filename,
+			}).Warn("Cannot read prompt history")
+		}
+		return history
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	file.Close()
+
+	if len(history) > historyMaxSize {
+		// The file only keeps the lines that are loaded
+		history = history[len(history)-historyMaxSize:]
+		writeHistory(filename, history)
+	}
+	return history
+}
+
+// Replaces the content of the history file by history.
+func writeHistory(filename string, history []string) {
+	file, err := os.OpenFile(filename, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = file.WriteString(strings.Join(history, "\n") + "\n")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":      err,
+			"filename": filename,
+		}).Warn("Cannot write prompt history")
+	}
+}
+
+// Records a prompt line in memory and at the end of the history file.
+func appendHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	globalHistory = append(globalHistory, line)
+	if len(globalHistory) > historyMaxSize {
+		globalHistory = globalHistory[len(globalHistory)-historyMaxSize:]
+	}
+
+	filename, err := historyFilepath()
+	if err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = file.WriteString(line + "\n")
+		file.Close()
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":      err,
+			"filename": filename,
+		}).Warn("Cannot write prompt history")
+	}
+}
+
+// Replaces the prompt buffer by the most recent history line that contains
+// the buffer text. Calling it again finds older matching lines.
+func reverseSearchHistory(buf *prompt.Buffer) {
+	search := &globalHistorySearch
+	text := buf.Text()
+
+	startIndex := len(globalHistory) - 1
+	if text != "" && text == search.result {
+		// Continue the previous search
+		startIndex = search.index - 1
+	} else {
+		search.pattern = text
+	}
+
+	for index := startIndex; index >= 0; index-- {
+		if strings.Contains(globalHistory[index], search.pattern) {
+			search.index = index
+			search.result = globalHistory[index]
+
+			buf.DeleteBeforeCursor(len([]rune(buf.Document().TextBeforeCursor())))
+			buf.InsertText(search.result, false, true)
+			return
+		}
+	}
+}
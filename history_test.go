@@ -0,0 +1,91 @@
+package netorcai
+
+import (
+	"fmt"
+	"github.com/mpoquet/go-prompt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Runs the history functions in a temporary home directory, with an empty
+// in-memory history. Returns the path of the history file and a function
+// that restores the environment.
+func setupHistoryHome(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "netorcai-history")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	globalHistory = []string{}
+	globalHistorySearch.pattern = ""
+	globalHistorySearch.index = 0
+	globalHistorySearch.result = ""
+
+	return filepath.Join(dir, historyFilename), func() {
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLoadHistoryTrim(t *testing.T) {
+	filename, restore := setupHistoryHome(t)
+	defer restore()
+
+	assert.Equal(t, []string{}, loadHistory(), "Missing history not empty")
+
+	lines := []string{}
+	for i := 0; i < historyMaxSize+5; i++ {
+		lines = append(lines, fmt.Sprintf("print %v", i))
+	}
+	err := ioutil.WriteFile(filename,
+		[]byte(strings.Join(lines, "\n\n")+"\n"), 0600)
+	assert.NoError(t, err, "Cannot write history")
+
+	history := loadHistory()
+	assert.Equal(t, lines[5:], history, "History not trimmed")
+	content, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err, "Cannot read history")
+	assert.Equal(t, strings.Join(lines[5:], "\n")+"\n", string(content),
+		"History file not trimmed")
+}
+
+func TestAppendHistory(t *testing.T) {
+	filename, restore := setupHistoryHome(t)
+	defer restore()
+
+	appendHistory("  start  ")
+	appendHistory(" ")
+	appendHistory("print")
+	assert.Equal(t, []string{"start", "print"}, globalHistory)
+
+	content, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err, "Cannot read history")
+	assert.Equal(t, "start\nprint\n", string(content))
+	assert.Equal(t, []string{"start", "print"}, loadHistory(),
+		"Appended lines not loaded back")
+}
+
+func TestReverseSearchHistory(t *testing.T) {
+	_, restore := setupHistoryHome(t)
+	defer restore()
+	globalHistory = []string{"start", "print", "quit", "restart"}
+
+	buf := prompt.NewBuffer()
+	buf.InsertText("start", false, true)
+	reverseSearchHistory(buf)
+	assert.Equal(t, "restart", buf.Text(), "Most recent match expected")
+
+	// Searching again finds older matches
+	reverseSearchHistory(buf)
+	assert.Equal(t, "start", buf.Text(), "Older match expected")
+	reverseSearchHistory(buf)
+	assert.Equal(t, "start", buf.Text(), "No older match expected")
+
+	buf.DeleteBeforeCursor(len(buf.Text()))
+	buf.InsertText("none", false, true)
+	reverseSearchHistory(buf)
+	assert.Equal(t, "none", buf.Text(), "No match expected")
+}
@@ -0,0 +1,74 @@
+package netorcai
+
+import (
+	"bytes"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sync"
+)
+
+type hookGameStarts struct {
+	Event            string               `json:"event"`
+	NbPlayers        int                  `json:"nb_players"`
+	NbSpecialPlayers int                  `json:"nb_special_players"`
+	NbTurnsMax       int                  `json:"nb_turns_max"`
+	PlayersInfo      []*PlayerInformation `json:"players_info"`
+}
+
+type hookGameEnds struct {
+	Event               string                 `json:"event"`
+	WinnerPlayerID      int                    `json:"winner_player_id"`
+	PlayersInfo         []*PlayerInformation   `json:"players_info"`
+	GameState           map[string]interface{} `json:"game_state"`
+	DumpStatesDirectory string                 `json:"dump_states_directory,omitempty"`
+	AbortReason         string                 `json:"abort_reason,omitempty"`
+}
+
+// Runs the --hook-command on game lifecycle events.
+type hookRunner struct {
+	command             string
+	dumpStatesDirectory string
+	// netorcai does not exit before the running hooks are finished
+	waitGroup *sync.WaitGroup
+}
+
+// Runs the hook command in the background, with the event as JSON on stdin.
+// Does nothing if no hook command has been set.
+func (h *hookRunner) run(eventName string, event interface{}) {
+	if h.command == "" {
+		return
+	}
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event": eventName,
+			"err":   err,
+		}).Error("Cannot serialize hook event")
+		return
+	}
+
+	h.waitGroup.Add(1)
+	go func() {
+		defer h.waitGroup.Done()
+
+		cmd := shellCommand(h.command)
+		cmd.Stdin = bytes.NewReader(append(content, '\n'))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		log.WithFields(log.Fields{
+			"event":   eventName,
+			"command": h.command,
+		}).Debug("Running hook command")
+		err := cmd.Run()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"event":   eventName,
+				"command": h.command,
+				"err":     err,
+			}).Warn("Hook command failed")
+		}
+	}()
+}
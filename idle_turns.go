@@ -0,0 +1,64 @@
+package netorcai
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Skips the DO_TURN of a turn in which no player acted: The game logic is
+// not invoked (--skip-idle-turns), and the GL coroutine then advances the
+// turn itself (see handleGLIdleTurn).
+// Turns are only skipped once the game logic has computed one, and not if
+// the game is being stopped or if the game logic must be notified of
+// departures, missed deadlines or visualizations.
+// Returns whether the turn has been skipped.
+func (client *GameLogicClient) skipIdleTurn(
+	playerActions []MessageDoTurnPlayerAction) bool {
+	client.idleTurn = false
+	if !client.skipIdleTurns || client.lastWinnerPlayerID == nil ||
+		client.lastTurn || len(client.stop) > 0 ||
+		len(client.missedDeadlines) > 0 {
+		return false
+	}
+	for _, action := range playerActions {
+		if len(action.Actions) > 0 {
+			return false
+		}
+	}
+
+	client.notificationsMutex.Lock()
+	notificationsPending := len(client.departures) > 0 ||
+		client.visusChanged != nil
+	client.notificationsMutex.Unlock()
+	if notificationsPending {
+		return false
+	}
+
+	client.idleTurn = true
+	client.lastPlayerActions = playerActions
+	client.lastDepartures = nil
+	client.lastMissedDeadlines = nil
+	log.Debug("No player acted. Game logic not invoked (idle turn)")
+	return true
+}
+
+// Advances a skipped turn in place of the game logic, as if it had answered
+// (see handleGLDoTurnAckReception): The game state and the winner of the
+// previous turn are kept, and so are the player deadlines.
+// Must be called by the GL coroutine, once the DO_TURN has been skipped.
+func handleGLIdleTurn(glClient *GameLogicClient,
+	turnNumber int) MessageDoTurnAck {
+	globalWatchdog.progress()
+
+	var milliseconds map[int]float64
+	if glClient.deadlines != nil {
+		milliseconds = glClient.deadlines.milliseconds
+	}
+	glClient.deadlines = newPlayerDeadlines(turnNumber, milliseconds,
+		time.Now())
+
+	return MessageDoTurnAck{
+		WinnerPlayerID: *glClient.lastWinnerPlayerID,
+		GameState:      glClient.lastGameState,
+	}
+}
@@ -0,0 +1,67 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Encodes and decodes JSON on the hot paths of netorcai (mainly the
+// serialization of the TURN messages broadcast to clients).
+// The standard library is always available. Faster backends are compiled in
+// with build tags (e.g., go build -tags jsoniter, see json_jsoniter.go).
+// Backends must produce the same JSON as encoding/json.
+type JSONBackend interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type stdJSONBackend struct{}
+
+func (stdJSONBackend) Name() string {
+	return "std"
+}
+
+func (stdJSONBackend) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdJSONBackend) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+var (
+	jsonBackends = map[string]JSONBackend{"std": stdJSONBackend{}}
+	// The backend in use. Only set at startup, by SetJSONBackend
+	jsonBackend JSONBackend = stdJSONBackend{}
+)
+
+// Makes a backend selectable by SetJSONBackend.
+// Called by the init function of the files of optional backends.
+func registerJSONBackend(backend JSONBackend) {
+	jsonBackends[backend.Name()] = backend
+}
+
+// Returns the names of the JSON backends compiled in, sorted.
+func JSONBackends() []string {
+	names := []string{}
+	for name := range jsonBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Selects the JSON backend used by netorcai. Must be called before the
+// server starts.
+func SetJSONBackend(name string) error {
+	backend, exists := jsonBackends[name]
+	if !exists {
+		return fmt.Errorf("Unknown JSON backend '%v'. Available ones: %v",
+			name, strings.Join(JSONBackends(), " "))
+	}
+	jsonBackend = backend
+	return nil
+}
@@ -0,0 +1,73 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Returns a TURN whose game state is about nbUnits*100 bytes once serialized.
+func benchmarkTurn(nbUnits int) MessageTurn {
+	units := make([]interface{}, nbUnits)
+	for i := range units {
+		units[i] = map[string]interface{}{
+			"id":      float64(i),
+			"x":       float64(i) * 1.5,
+			"y":       float64(-i),
+			"name":    fmt.Sprintf("unit<%v>", i),
+			"alive":   i%3 != 0,
+			"targets": []interface{}{float64(i + 1), float64(i + 2)},
+		}
+	}
+
+	return MessageTurn{
+		MessageType: "TURN",
+		TurnNumber:  42,
+		GameState:   map[string]interface{}{"units": units, "nb": float64(nbUnits)},
+		PlayersInfo: []*PlayerInformation{},
+	}
+}
+
+func TestJSONBackends(t *testing.T) {
+	assert.Contains(t, JSONBackends(), "std")
+	assert.Error(t, SetJSONBackend("meh"), "No error on unknown backend")
+	defer SetJSONBackend("std")
+
+	// All backends must serialize exactly as encoding/json
+	turn := benchmarkTurn(100)
+	expected, err := json.Marshal(turn)
+	assert.NoError(t, err)
+	for _, name := range JSONBackends() {
+		assert.NoError(t, SetJSONBackend(name), "Cannot select %v", name)
+		content, err := jsonBackend.Marshal(turn)
+		assert.NoError(t, err, "%v cannot serialize TURN", name)
+		assert.Equal(t, string(expected), string(content),
+			"%v serializes differently from encoding/json", name)
+
+		var decoded map[string]interface{}
+		assert.NoError(t, jsonBackend.Unmarshal(content, &decoded),
+			"%v cannot deserialize TURN", name)
+		assert.Equal(t, 42.0, decoded["turn_number"])
+	}
+}
+
+// Compares the JSON backends on the serialization of multi-MB TURN messages.
+// Run with go test -tags jsoniter -bench TurnMarshal to include jsoniter.
+func BenchmarkTurnMarshal(b *testing.B) {
+	defer SetJSONBackend("std")
+	for _, nbUnits := range []int{10000, 50000} {
+		turn := benchmarkTurn(nbUnits)
+		for _, name := range JSONBackends() {
+			SetJSONBackend(name)
+			b.Run(fmt.Sprintf("%v/%vunits", name, nbUnits), func(b *testing.B) {
+				content, _ := jsonBackend.Marshal(turn)
+				b.SetBytes(int64(len(content)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					jsonBackend.Marshal(turn)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build jsoniter
+// +build jsoniter
+
+package netorcai
+
+import (
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSON backend based on github.com/json-iterator/go, configured to produce
+// the same JSON as encoding/json (e.g., sorted map keys, escaped HTML).
+// Compiled in with go build -tags jsoniter, selected with --json-backend=jsoniter.
+type jsoniterJSONBackend struct {
+	api jsoniter.API
+}
+
+func init() {
+	registerJSONBackend(jsoniterJSONBackend{
+		api: jsoniter.ConfigCompatibleWithStandardLibrary,
+	})
+}
+
+func (jsoniterJSONBackend) Name() string {
+	return "jsoniter"
+}
+
+func (b jsoniterJSONBackend) Marshal(v interface{}) ([]byte, error) {
+	return b.api.Marshal(v)
+}
+
+func (b jsoniterJSONBackend) Unmarshal(data []byte, v interface{}) error {
+	return b.api.Unmarshal(data, v)
+}
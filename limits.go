@@ -0,0 +1,84 @@
+package netorcai
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os/exec"
+	"time"
+)
+
+const (
+	// Period of the resource usage checks of the commands run by netorcai
+	limitsCheckPeriod = 100 * time.Millisecond
+)
+
+// Limits on the resources used by each command run by netorcai
+// (game logic and players of tournament, lobby and scheduled games).
+// The usage of a command includes the processes it spawns.
+// Memory is the resident memory, in bytes. Zero values mean no limit.
+type ResourceLimits struct {
+	CPUTime time.Duration
+	Memory  int64
+}
+
+func (limits ResourceLimits) enabled() bool {
+	return limits.CPUTime > 0 || limits.Memory > 0
+}
+
+// Returns which limit the given usage exceeds ("" if none).
+func (limits ResourceLimits) violation(cpuTime time.Duration,
+	memory int64) string {
+	if limits.CPUTime > 0 && cpuTime > limits.CPUTime {
+		return fmt.Sprintf("CPU time limit (%v) exceeded", limits.CPUTime)
+	}
+	if limits.Memory > 0 && memory > limits.Memory {
+		return fmt.Sprintf("Memory limit (%v MiB) exceeded",
+			limits.Memory/(1024*1024))
+	}
+	return ""
+}
+
+// Checks that the resource limits can be enforced on this platform.
+func CheckResourceLimits(limits ResourceLimits) error {
+	if limits.enabled() && !resourceUsageSupported {
+		return fmt.Errorf("Resource limits are not supported on this platform")
+	}
+	return nil
+}
+
+// Periodically checks the resource usage of a command (started in its own
+// process group) until it exits or stop is closed. If the command exceeds
+// the limits, onViolation is called with the exceeded limit, then the
+// command is killed.
+func watchResourceLimits(name string, cmd *exec.Cmd, limits ResourceLimits,
+	stop chan int, onViolation func(exceeded string)) {
+	ticker := time.NewTicker(limitsCheckPeriod)
+	defer ticker.Stop()
+
+	pgid := cmd.Process.Pid
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		cpuTime, memory, nbProcesses := processGroupUsage(pgid)
+		if nbProcesses == 0 {
+			return
+		}
+
+		exceeded := limits.violation(cpuTime, memory)
+		if exceeded != "" {
+			log.WithFields(log.Fields{
+				"command":   name,
+				"violation": exceeded,
+				"cpu time":  cpuTime,
+				"memory":    memory,
+			}).Warn("Command exceeded its resource limits")
+			onViolation(exceeded)
+			killProcessGroup(pgid)
+			return
+		}
+	}
+}
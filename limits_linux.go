@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package netorcai
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	resourceUsageSupported = true
+
+	// Clock ticks per second of the times in /proc/<pid>/stat (USER_HZ)
+	clockTicksPerSecond = 100
+)
+
+// Returns the CPU time (including the one of the waited-for children)
+// and the resident memory used by the processes of a process group,
+// and the number of these processes.
+func processGroupUsage(pgid int) (time.Duration, int64, int) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return 0, 0, 0
+	}
+
+	var ticks, pages int64
+	nbProcesses := 0
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			// The process has exited in the meantime
+			continue
+		}
+
+		// The command name (2nd field) may contain spaces and parentheses:
+		// Fields are read after its closing parenthesis, from the 3rd one
+		stat := string(content)
+		fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+		if len(fields) < 22 || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+
+		nbProcesses++
+		for _, field := range fields[11:15] { // utime stime cutime cstime
+			value, _ := strconv.ParseInt(field, 10, 64)
+			ticks += value
+		}
+		rss, _ := strconv.ParseInt(fields[21], 10, 64)
+		pages += rss
+	}
+
+	cpuTime := time.Duration(ticks) * time.Second / clockTicksPerSecond
+	return cpuTime, pages * int64(os.Getpagesize()), nbProcesses
+}
+
+func killProcessGroup(pgid int) {
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
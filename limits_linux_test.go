@@ -0,0 +1,42 @@
+//go:build linux
+// +build linux
+
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWatchResourceLimits(t *testing.T) {
+	cmd, err := startShellCommand("while :; do :; done", nil, nil)
+	if !assert.NoError(t, err, "Cannot run command") {
+		return
+	}
+	defer stopShellCommand(cmd)
+
+	stop := make(chan int)
+	defer close(stop)
+	violations := make(chan string, 1)
+	go watchResourceLimits("busy", cmd,
+		ResourceLimits{CPUTime: 200 * time.Millisecond}, stop,
+		func(exceeded string) { violations <- exceeded })
+
+	select {
+	case exceeded := <-violations:
+		assert.Equal(t, "CPU time limit (200ms) exceeded", exceeded)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "The busy command was not killed")
+	}
+
+	// The command has been killed
+	exited := make(chan error)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err = <-exited:
+		assert.Error(t, err, "The command should have been killed")
+	case <-time.After(time.Second):
+		assert.Fail(t, "The command is still running")
+	}
+}
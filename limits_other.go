@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package netorcai
+
+import (
+	"time"
+)
+
+const resourceUsageSupported = false
+
+func processGroupUsage(pgid int) (time.Duration, int64, int) {
+	return 0, 0, 0
+}
+
+func killProcessGroup(pgid int) {
+}
@@ -0,0 +1,21 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestResourceLimitsViolation(t *testing.T) {
+	var noLimits ResourceLimits
+	assert.False(t, noLimits.enabled())
+	assert.Equal(t, "", noLimits.violation(time.Hour, 1<<40))
+
+	limits := ResourceLimits{CPUTime: time.Second, Memory: 64 * 1024 * 1024}
+	assert.True(t, limits.enabled())
+	assert.Equal(t, "", limits.violation(time.Second, 64*1024*1024))
+	assert.Equal(t, "CPU time limit (1s) exceeded",
+		limits.violation(2*time.Second, 0))
+	assert.Equal(t, "Memory limit (64 MiB) exceeded",
+		limits.violation(0, 65*1024*1024))
+}
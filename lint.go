@@ -0,0 +1,208 @@
+package netorcai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Nesting depth (of the game logic messages) from which a game state is
+// considered extremely deep.
+const lintMaxDepth = 32
+
+// A suspicious construct in a message sent by the game logic.
+// Such constructs are accepted by netorcai, but often crash visualizations.
+type lintWarning struct {
+	path    string
+	problem string
+}
+
+// An object or array being traversed by lintMessage.
+type lintContainer struct {
+	object    bool
+	expectKey bool
+	key       string
+	index     int
+	keys      map[string]bool
+}
+
+// Returns the path of the value being traversed (e.g., game_state.all_clients[3]).
+func lintPath(stack []*lintContainer) string {
+	path := ""
+	for _, container := range stack {
+		if !container.object {
+			path += fmt.Sprintf("[%v]", container.index)
+		} else if path == "" {
+			path = container.key
+		} else {
+			path += "." + container.key
+		}
+	}
+	return path
+}
+
+// Looks for suspicious constructs in the raw content of a message:
+// NaN or infinite numbers (which are not valid JSON), duplicate keys
+// (whose first values are silently dropped) and extremely deep nesting.
+func lintMessage(raw []byte) []lintWarning {
+	warnings := []lintWarning{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	stack := []*lintContainer{}
+	tooDeep := false
+	valueRead := func() {
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			top.expectKey = top.object
+			top.index++
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if syntaxErr, isSyntaxError := err.(*json.SyntaxError); isSyntaxError &&
+				isNonFiniteLiteral(raw, syntaxErr.Offset) {
+				warnings = append(warnings, lintWarning{lintPath(stack),
+					"NaN or infinite number (not valid JSON)"})
+			}
+			return warnings
+		}
+
+		// Object keys
+		if len(stack) > 0 && stack[len(stack)-1].expectKey {
+			top := stack[len(stack)-1]
+			if key, isKey := token.(string); isKey {
+				top.key = key
+				top.expectKey = false
+				if top.keys[key] {
+					warnings = append(warnings, lintWarning{lintPath(stack),
+						"Duplicate key (only its last value is kept)"})
+				}
+				top.keys[key] = true
+				continue
+			}
+		}
+
+		switch value := token.(type) {
+		case json.Delim:
+			if value == '}' || value == ']' {
+				stack = stack[:len(stack)-1]
+				valueRead()
+				continue
+			}
+
+			if len(stack) >= lintMaxDepth && !tooDeep {
+				tooDeep = true
+				warnings = append(warnings, lintWarning{lintPath(stack),
+					fmt.Sprintf("Extremely deep nesting (more than %v levels)",
+						lintMaxDepth)})
+			}
+			stack = append(stack, &lintContainer{
+				object:    value == '{',
+				expectKey: value == '{',
+				keys:      make(map[string]bool),
+			})
+		case json.Number:
+			number, _ := strconv.ParseFloat(value.String(), 64)
+			if math.IsInf(number, 0) {
+				warnings = append(warnings, lintWarning{lintPath(stack),
+					fmt.Sprintf("Number %v overflows to an infinite number",
+						value)})
+			}
+			valueRead()
+		default:
+			valueRead()
+		}
+	}
+}
+
+// Whether the JSON syntax error at offset is caused by a NaN or Infinity
+// literal (as written by many JSON libraries, e.g., Python's).
+func isNonFiniteLiteral(raw []byte, offset int64) bool {
+	if offset < 1 || offset > int64(len(raw)) {
+		return false
+	}
+	literal := raw[offset-1:]
+	return bytes.HasPrefix(literal, []byte("NaN")) ||
+		bytes.HasPrefix(literal, []byte("Infinity"))
+}
+
+// Records the JSON type of every value of a game state, by path.
+func gameStateTypes(value interface{}, path string, types map[string]string) {
+	types[path] = jsonTypeName(value)
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			gameStateTypes(field, fieldPath, types)
+		}
+	case []interface{}:
+		for index, element := range value {
+			gameStateTypes(element, fmt.Sprintf("%v[%v]", path, index), types)
+		}
+	}
+}
+
+// Returns the fields whose JSON type differs between two game states,
+// sorted by path. Fields that only exist in one of them are ignored.
+func lintTypeChanges(previous, current map[string]string) []lintWarning {
+	warnings := []lintWarning{}
+	for path, currentType := range current {
+		previousType, exists := previous[path]
+		if exists && previousType != currentType {
+			warnings = append(warnings, lintWarning{path,
+				fmt.Sprintf("Type changed from %v to %v", previousType,
+					currentType)})
+		}
+	}
+
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].path < warnings[j].path
+	})
+	return warnings
+}
+
+func logLintWarnings(turnNumber int, warnings []lintWarning) {
+	for _, warning := range warnings {
+		log.WithFields(log.Fields{
+			"turn number": turnNumber,
+			"path":        warning.path,
+			"problem":     warning.problem,
+		}).Warn("Suspicious game state")
+	}
+}
+
+// Called by the GL coroutine on every DO_INIT_ACK (turnNumber=-1) or
+// DO_TURN_ACK received, before it is read.
+func lintGameLogicMessage(glClient *GameLogicClient, turnNumber int,
+	msg ClientMessage) {
+	if glClient.lintGameState {
+		logLintWarnings(turnNumber, lintMessage(msg.raw))
+	}
+}
+
+// Called by the GL coroutine every time a new game state is received,
+// to warn about the fields whose type changed since the previous one.
+func lintGameStateTypes(glClient *GameLogicClient, turnNumber int,
+	gameState map[string]interface{}) {
+	if !glClient.lintGameState {
+		return
+	}
+
+	types := make(map[string]string)
+	gameStateTypes(gameState, "all_clients", types)
+	if glClient.lastStateTypes != nil {
+		logLintWarnings(turnNumber, lintTypeChanges(glClient.lastStateTypes,
+			types))
+	}
+	glClient.lastStateTypes = types
+}
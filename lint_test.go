@@ -0,0 +1,60 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLintMessage(t *testing.T) {
+	warnings := lintMessage([]byte(`{"game_state":{"all_clients":{"a":1}}}`))
+	assert.Empty(t, warnings, "Warnings on a sane message")
+
+	warnings = lintMessage([]byte(`{"game_state":{"all_clients":` +
+		`{"units":[{"x":1,"x":2}], "big":1e999}}}`))
+	assert.Equal(t, []lintWarning{
+		{"game_state.all_clients.units[0].x",
+			"Duplicate key (only its last value is kept)"},
+		{"game_state.all_clients.big",
+			"Number 1e999 overflows to an infinite number"},
+	}, warnings)
+
+	for _, literal := range []string{"NaN", "Infinity", "-Infinity"} {
+		warnings = lintMessage([]byte(`{"game_state":{"all_clients":` +
+			`{"units":[{"x":0}, {"x":` + literal + `}]}}}`))
+		assert.Equal(t, []lintWarning{{"game_state.all_clients.units[1].x",
+			"NaN or infinite number (not valid JSON)"}}, warnings,
+			"Unexpected warnings for %v", literal)
+	}
+
+	deep := strings.Repeat(`{"a":`, lintMaxDepth+5) + "1" +
+		strings.Repeat("}", lintMaxDepth+5)
+	warnings = lintMessage([]byte(deep))
+	if assert.Len(t, warnings, 1, "Deep nesting should be reported once") {
+		assert.Equal(t, strings.Repeat("a.", lintMaxDepth-1)+"a",
+			warnings[0].path)
+	}
+
+	assert.Empty(t, lintMessage(nil), "Warnings on an empty message")
+}
+
+func TestLintTypeChanges(t *testing.T) {
+	previous := make(map[string]string)
+	gameStateTypes(map[string]interface{}{
+		"units": []interface{}{map[string]interface{}{"x": 1.0}},
+		"score": 4.0,
+		"name":  "meh",
+	}, "all_clients", previous)
+
+	current := make(map[string]string)
+	gameStateTypes(map[string]interface{}{
+		"units": []interface{}{map[string]interface{}{"x": "1"}},
+		"score": nil,
+		"new":   true,
+	}, "all_clients", current)
+
+	assert.Equal(t, []lintWarning{
+		{"all_clients.score", "Type changed from number to null"},
+		{"all_clients.units[0].x", "Type changed from number to string"},
+	}, lintTypeChanges(previous, current))
+}
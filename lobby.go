@@ -0,0 +1,269 @@
+package netorcai
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// A player waiting in the lobby.
+// Its LOGIN message is forwarded to the game room it is moved into,
+// which then answers with LOGIN_ACK.
+type lobbyPlayer struct {
+	client *Client
+	login  []byte
+}
+
+// Why a player stopped waiting in the lobby.
+type lobbyWaitEnd struct {
+	player *lobbyPlayer
+	err    error
+}
+
+// Reads the LOGIN message of a client connected to the lobby, which must be
+// received within timeout.
+func readLobbyLogin(client *Client, timeout time.Duration) (*lobbyPlayer,
+	error) {
+	client.Conn.SetReadDeadline(time.Now().Add(timeout))
+	content, _, err := readFrame(client.reader, framingV1, 1023,
+		"Received message size of first message is too big: %v does not fit in 10 bits")
+	if err != nil {
+		return nil, err
+	}
+	client.Conn.SetReadDeadline(time.Time{})
+
+	var data map[string]interface{}
+	err = json.Unmarshal(content, &data)
+	if err != nil {
+		return nil, fmt.Errorf("Non-JSON message received")
+	}
+
+	loginMessage, err := readLoginMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	if loginMessage.role != "player" {
+		return nil, fmt.Errorf("LOGIN denied: The lobby only accepts players")
+	}
+	client.nickname = loginMessage.nickname
+
+	// Forwarded as received, in a v1 frame
+	contentSizeBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(contentSizeBuf, uint32(len(content)))
+	return &lobbyPlayer{
+		client: client,
+		login:  append(contentSizeBuf, content...),
+	}, nil
+}
+
+// The maximum number of clients connected to the lobby that have not sent
+// their LOGIN yet. Further connections wait for a free slot.
+const lobbyMaxPendingLogins = 64
+
+var errLobbyMessage = fmt.Errorf("Received a message in the lobby")
+
+// Waits until a waiting player sends something (which is not expected) or
+// leaves, without consuming what it sends. The wait is interrupted by
+// setting a read deadline on the player connection.
+func waitInLobby(player *lobbyPlayer, waitEnded chan lobbyWaitEnd) {
+	_, err := player.client.reader.Peek(1)
+	if err == nil {
+		err = errLobbyMessage
+	}
+	waitEnded <- lobbyWaitEnd{player, err}
+}
+
+func isTimeout(err error) bool {
+	netErr, isNetErr := err.(net.Error)
+	return isNetErr && netErr.Timeout()
+}
+
+// Runs a game room: Starts a netorcai process and moves the players into it,
+// by forwarding their connection to it.
+func runLobbyRoom(gameID string, players []*lobbyPlayer,
+	settings TournamentSettings, port int) {
+	timeout := time.After(settings.MatchTimeout)
+	game, err := startGameProcess(gameID, len(players), settings, port,
+		timeout)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"port": port,
+			"err":  err,
+		}).Error("Cannot start game room")
+		for _, player := range players {
+			Kick(player.client, KICK_ROOM_UNAVAILABLE, "Cannot start game room")
+			player.client.Conn.Close()
+		}
+		return
+	}
+	defer game.stop()
+
+	log.WithFields(log.Fields{
+		"port":       port,
+		"nb players": len(players),
+	}).Info("Game room started")
+	for _, player := range players {
+		roomConn, err := net.Dial("tcp", "localhost:"+strconv.Itoa(port))
+		if err == nil {
+			_, err = roomConn.Write(player.login)
+		}
+		if err != nil {
+			Kick(player.client, KICK_ROOM_UNAVAILABLE, "Cannot join game room")
+			player.client.Conn.Close()
+			continue
+		}
+
+		// Buffered data must be forwarded too: Copy from the client reader
+		go func(player *lobbyPlayer, roomConn net.Conn) {
+			io.Copy(roomConn, player.client.reader)
+			roomConn.Close()
+		}(player, roomConn)
+		go func(player *lobbyPlayer, roomConn net.Conn) {
+			io.Copy(player.client.Conn, roomConn)
+			player.client.Conn.Close()
+		}(player, roomConn)
+	}
+
+	winner, err := game.waitWinner(timeout)
+	log.WithFields(log.Fields{
+		"port":            port,
+		"winner nickname": winner,
+		"err":             err,
+	}).Info("Game room is over")
+}
+
+// Accepts players in a lobby on a port. As soon as nbPlayers players wait,
+// a game room (a netorcai process, as tournament games are) is started and
+// the players are moved into it. Up to settings.NbRooms rooms run at the
+// same time, room i listening on port settings.Port+i.
+// Only returns if the lobby cannot accept connections anymore.
+func RunLobby(port, nbPlayers int, settings TournamentSettings) error {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	log.WithFields(log.Fields{
+		"port":       port,
+		"nb players": nbPlayers,
+	}).Info("Lobby is open")
+
+	arrived := make(chan *lobbyPlayer)
+	acceptErr := make(chan error, 1)
+	// Bounds the number of connected clients that have not logged in yet,
+	// as --max-pending-logins does for game servers
+	pendingLogins := make(chan int, lobbyMaxPendingLogins)
+	go func() {
+		for {
+			pendingLogins <- 1
+			conn, err := listener.Accept()
+			if err != nil {
+				acceptErr <- err
+				return
+			}
+
+			go func() {
+				client := newClient(conn)
+				player, err := readLobbyLogin(client,
+					defaultPendingLoginTimeout)
+				<-pendingLogins
+				if err != nil {
+					if _, isFieldError := err.(*FieldError); isFieldError {
+						sendError(client, err)
+					}
+					Kick(client, KICK_INVALID_LOGIN, fmt.Sprintf("Invalid first message: %v",
+						err.Error()))
+					conn.Close()
+					return
+				}
+				arrived <- player
+			}()
+		}
+	}()
+
+	freeRooms := []int{}
+	for room := 0; room < settings.NbRooms; room++ {
+		freeRooms = append(freeRooms, room)
+	}
+	roomDone := make(chan int)
+	nbGames := 0
+
+	queue := []*lobbyPlayer{}
+	moving := make(map[*lobbyPlayer]bool)
+	ready := []*lobbyPlayer{}
+	waitEnded := make(chan lobbyWaitEnd)
+	for {
+		// Move the first players of the queue once there are enough of them
+		// and a room is free: Their wait is interrupted to check they are
+		// still there.
+		if len(moving) == 0 && len(queue) >= nbPlayers && len(freeRooms) > 0 {
+			for _, player := range queue[:nbPlayers] {
+				moving[player] = true
+				player.client.Conn.SetReadDeadline(time.Now())
+			}
+			queue = queue[nbPlayers:]
+		}
+
+		select {
+		case err := <-acceptErr:
+			return err
+		case room := <-roomDone:
+			freeRooms = append(freeRooms, room)
+		case player := <-arrived:
+			log.WithFields(log.Fields{
+				"nickname":       player.client.nickname,
+				"remote address": player.client.Conn.RemoteAddr(),
+			}).Info("Player joined the lobby")
+			queue = append(queue, player)
+			go waitInLobby(player, waitEnded)
+		case end := <-waitEnded:
+			if moving[end.player] && isTimeout(end.err) {
+				end.player.client.Conn.SetReadDeadline(time.Time{})
+				ready = append(ready, end.player)
+			} else {
+				log.WithFields(log.Fields{
+					"nickname":       end.player.client.nickname,
+					"remote address": end.player.client.Conn.RemoteAddr(),
+					"err":            end.err,
+				}).Warn("Player left the lobby")
+				if end.err == errLobbyMessage {
+					Kick(end.player.client, KICK_PROTOCOL_ERROR, errLobbyMessage.Error())
+				}
+				end.player.client.Conn.Close()
+
+				for index, player := range queue {
+					if player == end.player {
+						queue = append(queue[:index], queue[index+1:]...)
+						break
+					}
+				}
+			}
+			delete(moving, end.player)
+
+			if len(moving) == 0 && len(ready) > 0 {
+				if len(ready) == nbPlayers {
+					room := freeRooms[0]
+					freeRooms = freeRooms[1:]
+					nbGames++
+					go func(gameID string, players []*lobbyPlayer, room int) {
+						runLobbyRoom(gameID, players, settings, settings.Port+room)
+						roomDone <- room
+					}(fmt.Sprintf("game%v", nbGames), ready, room)
+				} else {
+					// Some players left: The others wait again, first
+					queue = append(ready, queue...)
+					for _, player := range ready {
+						go waitInLobby(player, waitEnded)
+					}
+				}
+				ready = []*lobbyPlayer{}
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package netorcai
+
+// Returns who is logged in and which seats are free.
+// Must be called with the global state mutex held.
+func currentLobby(gs *GlobalState) MessageLobby {
+	nicknames := func(clients []*PlayerOrVisuClient, anonymous bool) []string {
+		names := []string{}
+		for _, pvClient := range clients {
+			if anonymous {
+				names = append(names, "")
+			} else {
+				names = append(names, pvClient.client.nickname)
+			}
+		}
+		return names
+	}
+	missing := func(nbClients, nbClientsMax int) int {
+		if nbClients >= nbClientsMax {
+			return 0
+		}
+		return nbClientsMax - nbClients
+	}
+
+	return MessageLobby{
+		MessageType:        "LOBBY",
+		Players:            nicknames(gs.Players, gs.AnonymizePlayers),
+		SpecialPlayers:     nicknames(gs.SpecialPlayers, gs.AnonymizePlayers),
+		Visus:              nicknames(gs.Visus, false),
+		GameLogicConnected: len(gs.GameLogic) > 0,
+		NbMissingPlayers:   missing(len(gs.Players), gs.NbPlayersMax),
+		NbMissingSpecialPlayers: missing(len(gs.SpecialPlayers),
+			gs.NbSpecialPlayersMax),
+		NbMissingVisus: missing(len(gs.Visus), gs.NbVisusMax),
+	}
+}
+
+// Sends the lobby to the players and visualizations that asked for it,
+// if the game has not started. Called whenever the lobby changes.
+// Must be called with the global state mutex held.
+func broadcastLobby(gs *GlobalState) {
+	if gs.GameState != GAME_NOT_RUNNING {
+		return
+	}
+
+	msg := currentLobby(gs)
+	for _, clients := range [][]*PlayerOrVisuClient{gs.Players,
+		gs.SpecialPlayers, gs.Visus} {
+		for _, pvClient := range clients {
+			if !pvClient.client.capabilities.lobby {
+				continue
+			}
+
+			// Only the latest lobby is kept for clients that have not
+			// been sent the previous one yet
+			select {
+			case <-pvClient.lobby:
+			default:
+			}
+			select {
+			case pvClient.lobby <- msg:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newLobbyClient(nickname string, lobby bool) *PlayerOrVisuClient {
+	return &PlayerOrVisuClient{
+		client: &Client{
+			nickname:     nickname,
+			capabilities: clientCapabilities{lobby: lobby},
+		},
+		lobby: make(chan MessageLobby, 1),
+	}
+}
+
+func TestCurrentLobby(t *testing.T) {
+	gs := &GlobalState{
+		Players:        []*PlayerOrVisuClient{newLobbyClient("p1", false)},
+		SpecialPlayers: []*PlayerOrVisuClient{},
+		Visus:          []*PlayerOrVisuClient{newLobbyClient("v1", false)},
+		NbPlayersMax:   4,
+		NbVisusMax:     1,
+	}
+
+	lobby := currentLobby(gs)
+	assert.Equal(t, []string{"p1"}, lobby.Players)
+	assert.Equal(t, []string{}, lobby.SpecialPlayers)
+	assert.Equal(t, []string{"v1"}, lobby.Visus)
+	assert.False(t, lobby.GameLogicConnected)
+	assert.Equal(t, 3, lobby.NbMissingPlayers)
+	assert.Equal(t, 0, lobby.NbMissingSpecialPlayers)
+	assert.Equal(t, 0, lobby.NbMissingVisus)
+
+	gs.AnonymizePlayers = true
+	gs.NbPlayersMax = 1
+	lobby = currentLobby(gs)
+	assert.Equal(t, []string{""}, lobby.Players, "Nickname not anonymized")
+	assert.Equal(t, []string{"v1"}, lobby.Visus)
+	assert.Equal(t, 0, lobby.NbMissingPlayers)
+}
+
+func TestBroadcastLobbyKeepsLatest(t *testing.T) {
+	player := newLobbyClient("p1", true)
+	silent := newLobbyClient("p2", false)
+	gs := &GlobalState{
+		GameState:    GAME_NOT_RUNNING,
+		Players:      []*PlayerOrVisuClient{player, silent},
+		NbPlayersMax: 4,
+	}
+
+	broadcastLobby(gs)
+	gs.NbPlayersMax = 3
+	broadcastLobby(gs)
+	assert.Len(t, silent.lobby, 0, "LOBBY sent to a client that did not ask")
+	if assert.Len(t, player.lobby, 1, "LOBBY not sent") {
+		assert.Equal(t, 1, (<-player.lobby).NbMissingPlayers,
+			"Previous LOBBY not replaced")
+	}
+
+	gs.GameState = GAME_RUNNING
+	broadcastLobby(gs)
+	assert.Len(t, player.lobby, 0, "LOBBY sent while the game is running")
+}
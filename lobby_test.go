@@ -0,0 +1,65 @@
+package netorcai
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func sendRawMessage(conn net.Conn, content string) {
+	contentSizeBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(contentSizeBuf, uint32(len(content)))
+	conn.Write(append(contentSizeBuf, []byte(content)...))
+}
+
+func TestLobbyLogin(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	login := `{"message_type":"LOGIN","nickname":"bot","role":"player",` +
+		`"metaprotocol_version":"` + Version + `"}`
+	go sendRawMessage(clientConn, login)
+	player, err := readLobbyLogin(newClient(serverConn), time.Second)
+	assert.NoError(t, err, "Player LOGIN should be accepted")
+	assert.Equal(t, "bot", player.client.nickname)
+	assert.Equal(t, login, string(player.login[4:]),
+		"LOGIN should be kept to be forwarded")
+
+	// The lobby notices when a waiting player is moved or leaves
+	waitEnded := make(chan lobbyWaitEnd)
+	go waitInLobby(player, waitEnded)
+	serverConn.SetReadDeadline(time.Now())
+	end := <-waitEnded
+	assert.True(t, isTimeout(end.err), "Wait should be interrupted")
+
+	serverConn.SetReadDeadline(time.Time{})
+	go waitInLobby(player, waitEnded)
+	clientConn.Close()
+	end = <-waitEnded
+	assert.False(t, isTimeout(end.err), "Player should have left")
+}
+
+func TestLobbyLoginOnlyPlayers(t *testing.T) {
+	for _, role := range []string{"visualization", "game logic"} {
+		serverConn, clientConn := net.Pipe()
+		go sendRawMessage(clientConn, `{"message_type":"LOGIN",`+
+			`"nickname":"bot","role":"`+role+`",`+
+			`"metaprotocol_version":"`+Version+`"}`)
+
+		_, err := readLobbyLogin(newClient(serverConn), time.Second)
+		assert.Error(t, err, "Role %v should be denied", role)
+		clientConn.Close()
+	}
+}
+
+func TestLobbyLoginTimeout(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	// A client that never sends its LOGIN does not hold its slot forever
+	_, err := readLobbyLogin(newClient(serverConn), 50*time.Millisecond)
+	assert.Error(t, err, "Missing LOGIN should time out")
+	assert.Contains(t, err.Error(), "timeout", "Unexpected error")
+}
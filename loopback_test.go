@@ -0,0 +1,41 @@
+package netorcai
+
+import (
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoopbackClient(t *testing.T) {
+	gs := &GlobalState{
+		GameState:    GAME_NOT_RUNNING,
+		NbPlayersMax: 1,
+	}
+	gameLogicExit := make(chan int, 1)
+
+	var player client.Client
+	player.ConnectConn(ConnectLoopbackClient(gs, gameLogicExit))
+
+	err := player.SendLogin("player", "loopback", Version)
+	assert.NoError(t, err, "Cannot send LOGIN")
+
+	msg, err := player.ReadMessage()
+	assert.NoError(t, err, "Cannot read LOGIN_ACK")
+	messageType, err := ReadString(msg, "message_type")
+	assert.NoError(t, err, "Cannot read message_type")
+	assert.Equal(t, "LOGIN_ACK", messageType, "Unexpected message type")
+
+	LockGlobalStateMutex(gs, "Check players", "Test")
+	assert.Equal(t, 1, len(gs.Players), "Loopback player not logged in")
+	gs.Players[0].client.canTerminate <- kickOrder{KICK_SHUTDOWN, "Test is finished"}
+	UnlockGlobalStateMutex(gs, "Check players", "Test")
+
+	msg, err = player.ReadMessage()
+	assert.NoError(t, err, "Cannot read KICK")
+	messageType, err = ReadString(msg, "message_type")
+	assert.NoError(t, err, "Cannot read message_type")
+	assert.Equal(t, "KICK", messageType, "Unexpected message type")
+
+	player.Disconnect()
+	gs.WaitGroup.Wait()
+}
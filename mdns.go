@@ -0,0 +1,301 @@
+package netorcai
+
+import (
+	"encoding/binary"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Minimal DNS-SD over multicast DNS (RFC 6762, RFC 6763) responder,
+// so that netorcai servers can be discovered on a local network.
+const (
+	mdnsAddress          = "224.0.0.251:5353"
+	mdnsServiceName      = "_netorcai._tcp.local."
+	mdnsTTL              = 120
+	mdnsAnnounceInterval = 30 * time.Second
+
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+	dnsTypeANY = 255
+
+	dnsClassIN         = 1
+	dnsClassCacheFlush = 0x8000
+)
+
+type mdnsQuestion struct {
+	name  string
+	qtype uint16
+}
+
+// Appends a domain name (e.g. "host.local.") to a DNS message.
+func appendDNSName(msg []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0)
+}
+
+func appendDNSRecord(msg []byte, name string, rtype, class uint16,
+	rdata []byte) []byte {
+	header := make([]byte, 10)
+	binary.BigEndian.PutUint16(header[0:], rtype)
+	binary.BigEndian.PutUint16(header[2:], class)
+	binary.BigEndian.PutUint32(header[4:], mdnsTTL)
+	binary.BigEndian.PutUint16(header[8:], uint16(len(rdata)))
+
+	msg = appendDNSName(msg, name)
+	msg = append(msg, header...)
+	return append(msg, rdata...)
+}
+
+// Reads a (possibly compressed) domain name at offset in a DNS message.
+// Returns the name and the offset right after it.
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	labels := []string{}
+	end := -1
+	for nbJumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("Truncated name")
+		}
+
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end == -1 {
+				end = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, fmt.Errorf("Truncated name pointer")
+			}
+			nbJumps++
+			if nbJumps > 16 {
+				return "", 0, fmt.Errorf("Too many name pointers")
+			}
+			if end == -1 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, fmt.Errorf("Truncated label")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// Parses the questions of a DNS query. Responses are ignored.
+func parseMdnsQuestions(msg []byte) ([]mdnsQuestion, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("Truncated header")
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 != 0 {
+		return []mdnsQuestion{}, nil
+	}
+
+	nbQuestions := int(binary.BigEndian.Uint16(msg[4:]))
+	questions := []mdnsQuestion{}
+	offset := 12
+	for i := 0; i < nbQuestions; i++ {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, fmt.Errorf("Truncated question")
+		}
+
+		questions = append(questions, mdnsQuestion{
+			name:  strings.ToLower(name),
+			qtype: binary.BigEndian.Uint16(msg[next:]),
+		})
+		offset = next + 4
+	}
+	return questions, nil
+}
+
+// Builds the mDNS response that describes a netorcai instance.
+func buildMdnsResponse(instance, host string, port int, ips []net.IP,
+	txt []string) []byte {
+	instanceName := instance + "." + mdnsServiceName
+	hostName := host + ".local."
+
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[2:], 0x8400) // Authoritative response
+	binary.BigEndian.PutUint16(msg[6:], uint16(3+len(ips)))
+
+	msg = appendDNSRecord(msg, mdnsServiceName, dnsTypePTR, dnsClassIN,
+		appendDNSName(nil, instanceName))
+
+	srv := make([]byte, 6)
+	binary.BigEndian.PutUint16(srv[4:], uint16(port))
+	msg = appendDNSRecord(msg, instanceName, dnsTypeSRV,
+		dnsClassIN|dnsClassCacheFlush, appendDNSName(srv, hostName))
+
+	rdata := []byte{}
+	for _, entry := range txt {
+		if len(entry) > 255 {
+			entry = entry[:255]
+		}
+		rdata = append(rdata, byte(len(entry)))
+		rdata = append(rdata, entry...)
+	}
+	msg = appendDNSRecord(msg, instanceName, dnsTypeTXT,
+		dnsClassIN|dnsClassCacheFlush, rdata)
+
+	for _, ip := range ips {
+		msg = appendDNSRecord(msg, hostName, dnsTypeA,
+			dnsClassIN|dnsClassCacheFlush, ip.To4())
+	}
+	return msg
+}
+
+// Whether a query asks for the netorcai service or the given instance.
+func isMdnsQueryForInstance(questions []mdnsQuestion, instance string) bool {
+	instanceName := strings.ToLower(instance + "." + mdnsServiceName)
+	for _, question := range questions {
+		if question.qtype != dnsTypePTR && question.qtype != dnsTypeSRV &&
+			question.qtype != dnsTypeTXT && question.qtype != dnsTypeANY {
+			continue
+		}
+		if question.name == mdnsServiceName || question.name == instanceName {
+			return true
+		}
+	}
+	return false
+}
+
+func localIPv4Addresses() []net.IP {
+	ips := []net.IP{}
+	addresses, err := net.InterfaceAddrs()
+	if err != nil {
+		return ips
+	}
+
+	for _, address := range addresses {
+		if ipNet, ok := address.(*net.IPNet); ok {
+			if ip := ipNet.IP.To4(); ip != nil && !ip.IsLoopback() {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// Describes the current state of the instance as DNS-SD TXT entries.
+func mdnsTxtEntries(gs *GlobalState, gameName string) []string {
+	LockGlobalStateMutex(gs, "mDNS announce", "mDNS")
+	defer UnlockGlobalStateMutex(gs, "mDNS announce", "mDNS")
+
+	openSeats := 0
+	if gs.GameState == GAME_NOT_RUNNING {
+		openSeats = gs.NbPlayersMax - len(gs.Players)
+	}
+
+	return []string{
+		"game=" + gameName,
+		"version=" + Version,
+		fmt.Sprintf("state=%v", gameStateString(gs.GameState)),
+		fmt.Sprintf("seats=%v", openSeats),
+	}
+}
+
+// Advertises netorcai on the local network via mDNS, as a
+// _netorcai._tcp service named after the game.
+// The service is announced periodically and whenever it is queried.
+func RunMdnsAnnouncer(port int, gameName string, gs *GlobalState) {
+	groupAddress, err := net.ResolveUDPAddr("udp4", mdnsAddress)
+	if err == nil {
+		var conn *net.UDPConn
+		conn, err = net.ListenMulticastUDP("udp4", nil, groupAddress)
+		if err == nil {
+			defer conn.Close()
+			if err = enableMulticastLoopback(conn); err != nil {
+				log.WithFields(log.Fields{
+					"err": err,
+				}).Debug("Cannot enable mDNS multicast loopback")
+			}
+			serveMdns(conn, groupAddress, port, gameName, gs)
+			return
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"err":     err,
+		"address": mdnsAddress,
+	}).Warn("Cannot announce netorcai via mDNS")
+}
+
+func serveMdns(conn *net.UDPConn, groupAddress *net.UDPAddr, port int,
+	gameName string, gs *GlobalState) {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "netorcai"
+	}
+	host = strings.Split(host, ".")[0]
+
+	announce := func() {
+		response := buildMdnsResponse(gameName, host, port,
+			localIPv4Addresses(), mdnsTxtEntries(gs, gameName))
+		_, err := conn.WriteToUDP(response, groupAddress)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Debug("Cannot send mDNS announce")
+		}
+	}
+
+	queries := make(chan []mdnsQuestion)
+	go func() {
+		buf := make([]byte, 9000)
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				close(queries)
+				return
+			}
+
+			questions, err := parseMdnsQuestions(buf[:n])
+			if err == nil && len(questions) > 0 {
+				queries <- questions
+			}
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"service": gameName + "." + mdnsServiceName,
+		"port":    port,
+	}).Info("Announcing netorcai via mDNS")
+	announce()
+
+	ticker := time.NewTicker(mdnsAnnounceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			announce()
+		case questions, ok := <-queries:
+			if !ok {
+				return
+			}
+			if isMdnsQueryForInstance(questions, gameName) {
+				announce()
+			}
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package netorcai
+
+import (
+	"net"
+)
+
+func enableMulticastLoopback(conn *net.UDPConn) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestParseMdnsQuestions(t *testing.T) {
+	// Query with two questions, the second one using name compression
+	query := []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0}
+	query = appendDNSName(query, "_netorcai._tcp.local.")
+	query = append(query, 0, dnsTypePTR, 0, dnsClassIN)
+	query = append(query, 4, 'g', 'a', 'm', 'e', 0xC0, 12)
+	query = append(query, 0, dnsTypeSRV, 0, dnsClassIN)
+
+	questions, err := parseMdnsQuestions(query)
+	assert.NoError(t, err, "Cannot parse query")
+	assert.Equal(t, []mdnsQuestion{
+		{name: "_netorcai._tcp.local.", qtype: dnsTypePTR},
+		{name: "game._netorcai._tcp.local.", qtype: dnsTypeSRV},
+	}, questions, "Unexpected questions")
+	assert.True(t, isMdnsQueryForInstance(questions, "game"),
+		"Query not detected as targeting the instance")
+	assert.True(t, isMdnsQueryForInstance(questions[:1], "other"),
+		"Service query not detected as targeting any instance")
+
+	_, err = parseMdnsQuestions(query[:len(query)-6])
+	assert.Error(t, err, "No error on truncated query")
+
+	// Name pointer loop
+	loop := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xC0, 12, 0, 1, 0, 1}
+	_, err = parseMdnsQuestions(loop)
+	assert.Error(t, err, "No error on name pointer loop")
+}
+
+func TestBuildMdnsResponse(t *testing.T) {
+	response := buildMdnsResponse("game", "host", 4242,
+		[]net.IP{net.IPv4(192, 168, 0, 1)}, []string{"seats=4"})
+
+	// Responses are not considered as queries
+	questions, err := parseMdnsQuestions(response)
+	assert.NoError(t, err, "Cannot parse response header")
+	assert.Empty(t, questions, "Response parsed as a query")
+
+	// First answer is the PTR from the service to the instance
+	name, offset, err := readDNSName(response, 12)
+	assert.NoError(t, err, "Cannot read first answer name")
+	assert.Equal(t, mdnsServiceName, name, "Unexpected first answer name")
+	target, _, err := readDNSName(response, offset+10)
+	assert.NoError(t, err, "Cannot read PTR target")
+	assert.Equal(t, "game."+mdnsServiceName, target, "Unexpected PTR target")
+}
@@ -0,0 +1,28 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package netorcai
+
+import (
+	"net"
+	"syscall"
+)
+
+// net.ListenMulticastUDP disables multicast loopback, which prevents
+// clients running on the same host from discovering netorcai.
+func enableMulticastLoopback(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP,
+			syscall.IP_MULTICAST_LOOP, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
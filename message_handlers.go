@@ -0,0 +1,133 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// A message received from a player or visualization, as handed to the
+// MessageHandler of its type.
+type ReceivedMessage struct {
+	Content    map[string]interface{}
+	ReceivedAt time.Time
+	// The client that sent it. PlayerID is -1 for visualizations and before
+	// the game starts. Role is "player", "special player" or "visualization".
+	Nickname string
+	PlayerID int
+	Role     string
+
+	pvClient *PlayerOrVisuClient
+	// The game logic of the running game (nil before it starts)
+	glClient *GameLogicClient
+}
+
+// Sends a message (marshalled in JSON) to the client that sent the
+// received one.
+func (msg *ReceivedMessage) Reply(content interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return sendMessage(msg.pvClient.client, data)
+}
+
+// Handles the messages of a type received from players and visualizations.
+// TURN_ACK is not handled this way, as it drives the turns of the client.
+type MessageHandler struct {
+	// Checks the message (optional). If it fails, the error is sent to the
+	// client, which is kicked with the PROTOCOL_ERROR code.
+	Validate func(msg *ReceivedMessage) error
+	// Acts on a valid message. If it fails (e.g., it cannot reply),
+	// the client is kicked with the NETWORK_ERROR code.
+	Handle func(msg *ReceivedMessage) error
+}
+
+// Message types that cannot be given a handler
+var reservedMessageTypes = map[string]bool{
+	"LOGIN":    true,
+	"TURN_ACK": true,
+}
+
+var (
+	messageHandlersMutex sync.RWMutex
+	messageHandlers      = map[string]MessageHandler{
+		"PING":            {Validate: validateTimeSyncPing, Handle: handleTimeSyncPing},
+		"PONG":            {Handle: handlePongMessage},
+		"GAME_STARTS_ACK": {Validate: validateGameStartsAck, Handle: handleGameStartsAck},
+	}
+)
+
+// Registers the handler of a new type of messages received from players
+// and visualizations, e.g. for the experimental extensions of programs that
+// embed netorcai. It must be called before RunServer.
+// Fails if the type already has a handler, or if it is LOGIN or TURN_ACK.
+func RegisterMessageHandler(messageType string, handler MessageHandler) error {
+	if handler.Handle == nil {
+		return fmt.Errorf("The handler of %v has no Handle function",
+			messageType)
+	}
+	if reservedMessageTypes[messageType] {
+		return fmt.Errorf("%v messages cannot be given a handler", messageType)
+	}
+
+	messageHandlersMutex.Lock()
+	defer messageHandlersMutex.Unlock()
+	if _, exists := messageHandlers[messageType]; exists {
+		return fmt.Errorf("%v messages already have a handler", messageType)
+	}
+	messageHandlers[messageType] = handler
+	return nil
+}
+
+// Returns the handler of a received message, if its type has one.
+func lookupMessageHandler(content map[string]interface{}) (MessageHandler,
+	bool) {
+	messageType, err := ReadString(content, "message_type")
+	if err != nil {
+		return MessageHandler{}, false
+	}
+
+	messageHandlersMutex.RLock()
+	defer messageHandlersMutex.RUnlock()
+	handler, exists := messageHandlers[messageType]
+	return handler, exists
+}
+
+func validateTimeSyncPing(msg *ReceivedMessage) error {
+	_, err := readTimeSyncPingMessage(msg.Content)
+	return err
+}
+
+func handleTimeSyncPing(msg *ReceivedMessage) error {
+	clientTime, _ := readTimeSyncPingMessage(msg.Content)
+	err := sendTimeSyncPong(msg.pvClient.client, clientTime, msg.ReceivedAt)
+	if err != nil {
+		return fmt.Errorf("Cannot send PONG. %v", err.Error())
+	}
+	return nil
+}
+
+func handlePongMessage(msg *ReceivedMessage) error {
+	handlePong(msg.pvClient.client)
+	return nil
+}
+
+// Only players of a running game send GAME_STARTS_ACK.
+func validateGameStartsAck(msg *ReceivedMessage) error {
+	if !msg.pvClient.isPlayer || msg.glClient == nil {
+		return newFieldError("message_type", "TURN_ACK", "GAME_STARTS_ACK",
+			"Received 'GAME_STARTS_ACK' message type, while TURN_ACK was expected")
+	}
+	return nil
+}
+
+func handleGameStartsAck(msg *ReceivedMessage) error {
+	log.WithFields(log.Fields{
+		"playerID": msg.PlayerID,
+	}).Debug("Client received a GAME_STARTS_ACK (from socket)")
+	msg.glClient.gameStartsAcks.ack(msg.PlayerID)
+	return nil
+}
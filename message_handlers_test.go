@@ -0,0 +1,71 @@
+package netorcai
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegisterMessageHandlerErrors(t *testing.T) {
+	handle := func(msg *ReceivedMessage) error { return nil }
+
+	err := RegisterMessageHandler("PING", MessageHandler{Handle: handle})
+	assert.Error(t, err, "PING already has a handler")
+	err = RegisterMessageHandler("TURN_ACK", MessageHandler{Handle: handle})
+	assert.Error(t, err, "TURN_ACK cannot be given a handler")
+	err = RegisterMessageHandler("NO_HANDLE", MessageHandler{})
+	assert.Error(t, err, "A handler must have a Handle function")
+}
+
+func TestRegisterMessageHandler(t *testing.T) {
+	err := RegisterMessageHandler("TEST_ECHO", MessageHandler{
+		Validate: func(msg *ReceivedMessage) error {
+			_, err := ReadString(msg.Content, "text")
+			return err
+		},
+		Handle: func(msg *ReceivedMessage) error {
+			return msg.Reply(map[string]interface{}{
+				"message_type": "TEST_ECHO",
+				"text":         msg.Content["text"],
+				"from":         fmt.Sprintf("%v (%v)", msg.Nickname, msg.Role),
+			})
+		},
+	})
+	assert.NoError(t, err, "Cannot register handler")
+
+	gs := &GlobalState{
+		GameState:    GAME_NOT_RUNNING,
+		NbPlayersMax: 1,
+	}
+	var player client.Client
+	player.ConnectConn(ConnectLoopbackClient(gs, make(chan int, 1)))
+	err = player.SendLogin("player", "echoer", Version)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	_, err = player.ReadMessage()
+	assert.NoError(t, err, "Cannot read LOGIN_ACK")
+
+	err = player.SendString(`{"message_type":"TEST_ECHO", "text":"hello"}`)
+	assert.NoError(t, err, "Cannot send TEST_ECHO")
+	msg, err := player.ReadMessage()
+	assert.NoError(t, err, "Cannot read TEST_ECHO answer")
+	assert.Equal(t, "hello", msg["text"], "Unexpected echo")
+	assert.Equal(t, "echoer (player)", msg["from"], "Unexpected sender")
+
+	// Invalid messages get the client kicked
+	err = player.SendString(`{"message_type":"TEST_ECHO"}`)
+	assert.NoError(t, err, "Cannot send TEST_ECHO")
+	for {
+		msg, err = player.ReadMessage()
+		if !assert.NoError(t, err, "Cannot read KICK") ||
+			msg["message_type"] != "ERROR" {
+			break
+		}
+	}
+	assert.Equal(t, "KICK", msg["message_type"], "Unexpected message type")
+	assert.Equal(t, string(KICK_PROTOCOL_ERROR), msg["kick_code"],
+		"Unexpected kick code")
+
+	player.Disconnect()
+	gs.WaitGroup.Wait()
+}
@@ -1,6 +1,7 @@
 package netorcai
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -10,11 +11,20 @@ type MessageLogin struct {
 	nickname            string
 	role                string
 	metaprotocolVersion string
+	visuTier            string
+	apiKey              string
+	frameSkipping       bool
+	framing             int
+	playerLeft          bool
+	visuChanges         bool
+	lobby               bool
 }
 
 type MessageLoginAck struct {
 	MessageType         string `json:"message_type"`
 	MetaprotocolVersion string `json:"metaprotocol_version"`
+	// Only set if the client asked for a frame format
+	Framing int `json:"framing,omitempty"`
 }
 
 // Quite an immutable PlayerOrVisuClient generated at game start
@@ -23,6 +33,21 @@ type PlayerInformation struct {
 	Nickname      string `json:"nickname"`
 	RemoteAddress string `json:"remote_address"`
 	IsConnected   bool   `json:"is_connected"`
+	Account       string `json:"account,omitempty"`
+
+	// Whether the nickname and remote address must be hidden to clients
+	anonymous bool
+}
+
+func (info *PlayerInformation) MarshalJSON() ([]byte, error) {
+	type plainInformation PlayerInformation
+	sent := plainInformation(*info)
+	if info.anonymous {
+		sent.Nickname = fmt.Sprintf("Player %v", info.PlayerID)
+		sent.RemoteAddress = ""
+		sent.Account = ""
+	}
+	return json.Marshal(sent)
 }
 
 type MessageGameStarts struct {
@@ -35,12 +60,76 @@ type MessageGameStarts struct {
 	DelayTurns       float64                `json:"milliseconds_between_turns"`
 	InitialGameState map[string]interface{} `json:"initial_game_state"`
 	PlayersInfo      []*PlayerInformation   `json:"players_info"`
+	ServerConfig     ServerConfig           `json:"server_config"`
+}
+
+// The effective server parameters when a game starts, which may differ from
+// the command-line ones (they can be changed from the prompt).
+type ServerConfig struct {
+	NbPlayersMax         int     `json:"nb_players_max"`
+	NbSpecialPlayersMax  int     `json:"nb_special_players_max"`
+	NbVisusMax           int     `json:"nb_visus_max"`
+	NbTurnsMax           int     `json:"nb_turns_max"`
+	DelayFirstTurn       float64 `json:"milliseconds_before_first_turn"`
+	DelayTurns           float64 `json:"milliseconds_between_turns"`
+	DelayTurnsMin        float64 `json:"milliseconds_between_turns_min"`
+	AdaptiveDelay        bool    `json:"adaptive_delay"`
+	GameStartsAckTimeout float64 `json:"milliseconds_game_starts_ack_timeout"`
+	Fast                 bool    `json:"fast"`
+	PublicVisuDelay      int     `json:"public_visu_delay"`
+	EchoActionsToVisus   bool    `json:"echo_actions_to_visus"`
+	AnonymizePlayers     bool    `json:"anonymize_players"`
+}
+
+// Protocol statistics of a player during the game, from the point of view
+// of netorcai: MessagesSent are the messages sent to the player,
+// MessagesReceived the ones received from it.
+// Reconnects is the number of previous logins with the same nickname, as
+// players can only log in again before the game starts.
+type ClientProtocolStats struct {
+	MessagesSent     int `json:"messages_sent"`
+	MessagesReceived int `json:"messages_received"`
+	InvalidMessages  int `json:"invalid_messages"`
+	Reconnects       int `json:"reconnects"`
+}
+
+// Sent to the players and visualizations that asked for it at LOGIN,
+// whenever the logged in clients change before the game starts.
+// Player nicknames are empty with --anonymize-players.
+type MessageLobby struct {
+	MessageType             string   `json:"message_type"`
+	Players                 []string `json:"players"`
+	SpecialPlayers          []string `json:"special_players"`
+	Visus                   []string `json:"visus"`
+	GameLogicConnected      bool     `json:"game_logic_connected"`
+	NbMissingPlayers        int      `json:"nb_missing_players"`
+	NbMissingSpecialPlayers int      `json:"nb_missing_special_players"`
+	NbMissingVisus          int      `json:"nb_missing_visus"`
+}
+
+// An announcement of the operator (broadcast prompt command or /broadcast
+// administration endpoint), e.g., to display during live events.
+type MessageAdmin struct {
+	MessageType string `json:"message_type"`
+	Text        string `json:"text"`
+}
+
+// Sent to players and visualizations every second before GAME_STARTS
+// (--countdown), e.g., to display a start animation.
+type MessageCountdown struct {
+	MessageType string `json:"message_type"`
+	// 3, 2, 1...
+	SecondsBeforeGameStarts int `json:"seconds_before_game_starts"`
 }
 
 type MessageGameEnds struct {
 	MessageType    string                 `json:"message_type"`
 	WinnerPlayerID int                    `json:"winner_player_id"`
 	GameState      map[string]interface{} `json:"game_state"`
+	// Only sent to visualizations
+	ClientStats map[int]ClientProtocolStats `json:"client_stats,omitempty"`
+	// Only sent if the operator aborted the game (see AbortGame)
+	AbortReason string `json:"abort_reason,omitempty"`
 }
 
 type MessageTurn struct {
@@ -48,6 +137,24 @@ type MessageTurn struct {
 	TurnNumber  int                    `json:"turn_number"`
 	GameState   map[string]interface{} `json:"game_state"`
 	PlayersInfo []*PlayerInformation   `json:"players_info"`
+	DelayTurns  float64                `json:"milliseconds_between_turns,omitempty"`
+	Latencies   map[int]float64        `json:"latencies,omitempty"`
+	// Only sent to visualizations, with --echo-actions-to-visus
+	PlayerActions []MessageDoTurnPlayerAction `json:"player_actions,omitempty"`
+	// Only sent to the player the game logic addressed it to
+	PlayerMessage map[string]interface{} `json:"player_message,omitempty"`
+	// Only sent to visualizations that skip frames, and to players that
+	// catch up
+	SkippedTurns int `json:"skipped_turns,omitempty"`
+	// Only sent to visualizations, in milliseconds since the Unix epoch
+	Timestamp   int64 `json:"timestamp_ms,omitempty"`
+	NextTurnETA int64 `json:"next_turn_eta_ms,omitempty"`
+	// Only sent once nb-turns-max has been changed during the game
+	NbTurnsMax int `json:"nb_turns_max,omitempty"`
+	// Only sent to players, when the game logic set player deadlines
+	ActionDeadline *float64 `json:"action_deadline_ms,omitempty"`
+	// The serialization of GameState shared by the clients (nil if none)
+	encodedState *encodedGameState
 }
 
 type MessageTurnAck struct {
@@ -60,14 +167,19 @@ type MessageDoInit struct {
 	NbPlayers        int    `json:"nb_players"`
 	NbSpecialPlayers int    `json:"nb_special_players"`
 	NbTurnsMax       int    `json:"nb_turns_max"`
+	Seed             *int64 `json:"seed,omitempty"`
 }
 
 type MessageDoInitAck struct {
 	InitialGameState map[string]interface{}
+	RandomDraws      []interface{}
+	// The JSON Schema every game_state must match (nil if none)
+	GameStateSchema map[string]interface{}
 }
 
 type MessageDoTurnPlayerAction struct {
 	PlayerID   int           `json:"player_id"`
+	Role       string        `json:"role"`
 	TurnNumber int           `json:"turn_number"`
 	Actions    []interface{} `json:"actions"`
 }
@@ -75,16 +187,90 @@ type MessageDoTurnPlayerAction struct {
 type MessageDoTurn struct {
 	MessageType   string                      `json:"message_type"`
 	PlayerActions []MessageDoTurnPlayerAction `json:"player_actions"`
+	Latencies     map[int]float64             `json:"latencies,omitempty"`
+	// Only sent once nb-turns-max has been changed during the game
+	NbTurnsMax int `json:"nb_turns_max,omitempty"`
+	// Only sent when the game has been stopped (see StopGame)
+	LastTurn bool `json:"last_turn,omitempty"`
+	// The players that did not act before their deadline (see
+	// player_deadlines.go)
+	MissedDeadlines []int `json:"missed_deadlines,omitempty"`
+}
+
+// Tells the game logic that a player left the running game.
+// Sent right before the DO_TURN of the turn that follows the departure.
+type MessagePlayerLeft struct {
+	MessageType string `json:"message_type"`
+	PlayerID    int    `json:"player_id"`
+	// The last turn sent to clients when the player left (-1 if none)
+	TurnNumber int `json:"turn_number"`
+	// disconnect, kick or timeout
+	Reason string `json:"reason"`
+}
+
+// Tells the game logic which visualizations joined or left since the
+// previous notification. Sent right before a DO_TURN.
+type MessageVisusChanged struct {
+	MessageType string `json:"message_type"`
+	// The number of visualizations logged in
+	NbVisus int      `json:"nb_visus"`
+	Joined  []string `json:"joined"`
+	Left    []string `json:"left"`
 }
 
 type MessageDoTurnAck struct {
 	WinnerPlayerID int
 	GameState      map[string]interface{}
+	PlayerMessages map[int]map[string]interface{}
+	RandomDraws    []interface{}
+	// Nil if the game logic set no player deadline
+	PlayerDeadlines map[int]float64
 }
 
-type MessageKick struct {
+type MessagePing struct {
+	MessageType string `json:"message_type"`
+}
+
+// Answers the time synchronization PING of a client.
+// Times are in milliseconds since the Unix epoch.
+type MessagePong struct {
 	MessageType string `json:"message_type"`
-	KickReason  string `json:"kick_reason"`
+	ClientTime  int64  `json:"client_time"`
+	ReceiveTime int64  `json:"receive_time"`
+	SendTime    int64  `json:"send_time"`
+}
+
+type MessageKick struct {
+	MessageType string   `json:"message_type"`
+	KickReason  string   `json:"kick_reason"`
+	KickCode    KickCode `json:"kick_code"`
+}
+
+// Machine-readable counterpart of a kick reason
+type KickCode string
+
+const (
+	KICK_INVALID_LOGIN      KickCode = "INVALID_LOGIN"
+	KICK_LOGIN_DENIED_FULL  KickCode = "LOGIN_DENIED_FULL"
+	KICK_LOGIN_DENIED_AUTH  KickCode = "LOGIN_DENIED_AUTH"
+	KICK_GAME_STARTED       KickCode = "GAME_STARTED"
+	KICK_PROTOCOL_ERROR     KickCode = "PROTOCOL_ERROR"
+	KICK_NETWORK_ERROR      KickCode = "NETWORK_ERROR"
+	KICK_TIMEOUT            KickCode = "TIMEOUT"
+	KICK_GAME_STATE_TOO_BIG KickCode = "GAME_STATE_TOO_BIG"
+	KICK_GAME_FINISHED      KickCode = "GAME_FINISHED"
+	KICK_REPLACED           KickCode = "REPLACED"
+	KICK_SHUTDOWN           KickCode = "SHUTDOWN"
+	KICK_ROOM_UNAVAILABLE   KickCode = "ROOM_UNAVAILABLE"
+	KICK_ABORTED            KickCode = "ABORTED"
+)
+
+type MessageError struct {
+	MessageType   string      `json:"message_type"`
+	ErrorMessage  string      `json:"error_message"`
+	FieldPath     string      `json:"field_path,omitempty"`
+	Expected      string      `json:"expected,omitempty"`
+	ReceivedValue interface{} `json:"received_value,omitempty"`
 }
 
 func checkMessageType(data map[string]interface{}, expectedMessageType string) error {
@@ -94,13 +280,38 @@ func checkMessageType(data map[string]interface{}, expectedMessageType string) e
 	}
 
 	if messageType != expectedMessageType {
-		return fmt.Errorf("Received '%v' message type, "+
-			"while %v was expected", messageType, expectedMessageType)
+		return newFieldError("message_type", expectedMessageType, messageType,
+			"Received '%v' message type, while %v was expected",
+			messageType, expectedMessageType)
 	}
 
 	return nil
 }
 
+func isPongMessage(data map[string]interface{}) bool {
+	return checkMessageType(data, "PONG") == nil
+}
+
+// Reads the time (in milliseconds since the Unix epoch) at which a client
+// sent a time synchronization PING.
+func readTimeSyncPingMessage(data map[string]interface{}) (int64, error) {
+	err := checkMessageType(data, "PING")
+	if err != nil {
+		return 0, err
+	}
+
+	clientTime, err := ReadInt(data, "client_time")
+	if err != nil {
+		return 0, err
+	}
+	if clientTime < 0 {
+		return 0, newFieldError("client_time", "non-negative integer",
+			clientTime, "Invalid value (client_time=%v): expecting a "+
+				"non-negative integer", clientTime)
+	}
+	return int64(clientTime), nil
+}
+
 func readLoginMessage(data map[string]interface{}) (MessageLogin, error) {
 	var readMessage MessageLogin
 
@@ -119,7 +330,9 @@ func readLoginMessage(data map[string]interface{}) (MessageLogin, error) {
 	// Check nickname
 	r, _ := regexp.Compile(`\A\S{1,10}\z`)
 	if !r.MatchString(readMessage.nickname) {
-		return readMessage, fmt.Errorf("Invalid nickname")
+		return readMessage, newFieldError("nickname",
+			`string matching \A\S{1,10}\z`, readMessage.nickname,
+			"Invalid nickname")
 	}
 
 	// Read role
@@ -134,8 +347,9 @@ func readLoginMessage(data map[string]interface{}) (MessageLogin, error) {
 		"visualization",
 		"game logic":
 	default:
-		return readMessage, fmt.Errorf("Invalid role '%v'",
-			readMessage.role)
+		return readMessage, newFieldError("role",
+			"player, special player, visualization or game logic",
+			readMessage.role, "Invalid role '%v'", readMessage.role)
 	}
 
 	// Read metaprotocol version
@@ -148,7 +362,9 @@ func readLoginMessage(data map[string]interface{}) (MessageLogin, error) {
 	r, _ = regexp.Compile(`\A(?P<Major>\d+)\.(?P<Minor>\d+)\.(?P<Patch>\d+)\z`)
 	match := r.FindStringSubmatch(readMessage.metaprotocolVersion)
 	if match == nil {
-		return readMessage, fmt.Errorf("Invalid metaprotocol version: Not MAJOR.MINOR.PATCH")
+		return readMessage, newFieldError("metaprotocol_version",
+			"MAJOR.MINOR.PATCH", readMessage.metaprotocolVersion,
+			"Invalid metaprotocol version: Not MAJOR.MINOR.PATCH")
 	}
 
 	varMap := make(map[string]int)
@@ -159,11 +375,80 @@ func readLoginMessage(data map[string]interface{}) (MessageLogin, error) {
 	}
 
 	if varMap["Major"] != VersionMajor {
-		return readMessage, fmt.Errorf(
+		return readMessage, newFieldError("metaprotocol_version",
+			fmt.Sprintf("%v.x.y", VersionMajor), readMessage.metaprotocolVersion,
 			"Metaprotocol version mismatch. Major version must be identical but client asks for '%s' while netorcai uses '%s'.",
 			readMessage.metaprotocolVersion, Version)
 	}
 
+	// Read visualization tier (optional)
+	readMessage.visuTier = "public"
+	if _, exists := data["visu_tier"]; exists {
+		readMessage.visuTier, err = ReadString(data, "visu_tier")
+		if err != nil {
+			return readMessage, err
+		}
+
+		if readMessage.visuTier != "live" && readMessage.visuTier != "public" {
+			return readMessage, newFieldError("visu_tier", "live or public",
+				readMessage.visuTier, "Invalid visu_tier '%v'",
+				readMessage.visuTier)
+		}
+	}
+
+	// Read API key (optional)
+	if _, exists := data["api_key"]; exists {
+		readMessage.apiKey, err = ReadString(data, "api_key")
+		if err != nil {
+			return readMessage, err
+		}
+	}
+
+	// Read frame skipping (optional)
+	if _, exists := data["frame_skipping"]; exists {
+		readMessage.frameSkipping, err = ReadBool(data, "frame_skipping")
+		if err != nil {
+			return readMessage, err
+		}
+	}
+
+	// Read frame format (optional)
+	if _, exists := data["framing"]; exists {
+		readMessage.framing, err = ReadInt(data, "framing")
+		if err != nil {
+			return readMessage, err
+		}
+
+		if readMessage.framing != framingV1 && readMessage.framing != framingV2 {
+			return readMessage, newFieldError("framing", "1 or 2",
+				readMessage.framing, "Invalid framing %v", readMessage.framing)
+		}
+	}
+
+	// Read PLAYER_LEFT notifications (optional)
+	if _, exists := data["player_left"]; exists {
+		readMessage.playerLeft, err = ReadBool(data, "player_left")
+		if err != nil {
+			return readMessage, err
+		}
+	}
+
+	// Read VISUS_CHANGED notifications (optional)
+	if _, exists := data["visu_changes"]; exists {
+		readMessage.visuChanges, err = ReadBool(data, "visu_changes")
+		if err != nil {
+			return readMessage, err
+		}
+	}
+
+	// Read LOBBY notifications (optional)
+	if _, exists := data["lobby"]; exists {
+		readMessage.lobby, err = ReadBool(data, "lobby")
+		if err != nil {
+			return readMessage, err
+		}
+	}
+
 	return readMessage, nil
 }
 
@@ -185,8 +470,10 @@ func readTurnAckMessage(data map[string]interface{}, expectedTurnNumber int) (
 
 	// Check turn number
 	if readMessage.turnNumber != expectedTurnNumber {
-		return readMessage, fmt.Errorf("Invalid value (turn_number=%v): "+
-			"expecting %v", readMessage.turnNumber, expectedTurnNumber)
+		return readMessage, newFieldError("turn_number",
+			strconv.Itoa(expectedTurnNumber), readMessage.turnNumber,
+			"Invalid value (turn_number=%v): expecting %v",
+			readMessage.turnNumber, expectedTurnNumber)
 	}
 
 	// Read actions
@@ -216,10 +503,34 @@ func readDoInitAckMessage(data map[string]interface{}) (
 
 	// Read game state -> all clients
 	readMessage.InitialGameState, err = ReadObject(gameState, "all_clients")
+	if err != nil {
+		return readMessage, InField("initial_game_state", err)
+	}
+
+	// Read random draws (optional)
+	readMessage.RandomDraws, err = readRandomDraws(data)
 	if err != nil {
 		return readMessage, err
 	}
 
+	// Read game state schema (optional), which the initial game state
+	// must already match
+	if _, exists := data["game_state_schema"]; exists {
+		readMessage.GameStateSchema, err = ReadObject(data, "game_state_schema")
+		if err != nil {
+			return readMessage, err
+		}
+		err = checkStateSchema(readMessage.GameStateSchema, "game_state_schema")
+		if err != nil {
+			return readMessage, err
+		}
+		err = validateStateSchema(gameState, readMessage.GameStateSchema,
+			"initial_game_state")
+		if err != nil {
+			return readMessage, err
+		}
+	}
+
 	return readMessage, nil
 }
 
@@ -242,8 +553,10 @@ func readDoTurnAckMessage(data map[string]interface{}, nbPlayers int) (
 	// Check player id
 	if readMessage.WinnerPlayerID < -1 ||
 		readMessage.WinnerPlayerID >= nbPlayers {
-		return readMessage, fmt.Errorf("Invalid winner_player_id: "+
-			"Not in [-1, %v[", nbPlayers)
+		return readMessage, newFieldError("winner_player_id",
+			fmt.Sprintf("integer in [-1, %v[", nbPlayers),
+			readMessage.WinnerPlayerID,
+			"Invalid winner_player_id: Not in [-1, %v[", nbPlayers)
 	}
 
 	// Read game state
@@ -254,9 +567,109 @@ func readDoTurnAckMessage(data map[string]interface{}, nbPlayers int) (
 
 	// Read game state -> all clients
 	readMessage.GameState, err = ReadObject(gameState, "all_clients")
+	if err != nil {
+		return readMessage, InField("game_state", err)
+	}
+
+	// Read player messages (optional)
+	readMessage.PlayerMessages, err = readPlayerMessages(data, nbPlayers)
+	if err != nil {
+		return readMessage, err
+	}
+
+	// Read random draws (optional)
+	readMessage.RandomDraws, err = readRandomDraws(data)
+	if err != nil {
+		return readMessage, err
+	}
+
+	// Read player deadlines (optional)
+	readMessage.PlayerDeadlines, err = readPlayerDeadlines(data, nbPlayers)
 	if err != nil {
 		return readMessage, err
 	}
 
 	return readMessage, nil
 }
+
+// Reads the optional random_draws array of a DO_INIT_ACK or DO_TURN_ACK,
+// in which the game logic reports the random numbers it has drawn
+// (nil if absent).
+func readRandomDraws(data map[string]interface{}) ([]interface{}, error) {
+	if _, exists := data["random_draws"]; !exists {
+		return nil, nil
+	}
+	return ReadArray(data, "random_draws")
+}
+
+// Reads the optional player_messages object of a DO_TURN_ACK,
+// whose keys are player IDs and whose values are objects.
+func readPlayerMessages(data map[string]interface{}, nbPlayers int) (
+	map[int]map[string]interface{}, error) {
+	playerMessages := make(map[int]map[string]interface{})
+	if _, exists := data["player_messages"]; !exists {
+		return playerMessages, nil
+	}
+
+	messages, err := ReadObject(data, "player_messages")
+	if err != nil {
+		return playerMessages, err
+	}
+
+	for key := range messages {
+		playerID, err := strconv.Atoi(key)
+		if err != nil || playerID < 0 || playerID >= nbPlayers {
+			return playerMessages, newFieldError("player_messages",
+				fmt.Sprintf("object whose keys are player IDs in [0, %v[", nbPlayers),
+				key, "Invalid player_messages key '%v': "+
+					"Not a player ID in [0, %v[", key, nbPlayers)
+		}
+
+		playerMessages[playerID], err = ReadObject(messages, key)
+		if err != nil {
+			return playerMessages, InField("player_messages", err)
+		}
+	}
+
+	return playerMessages, nil
+}
+
+// Reads the optional player_deadlines object of a DO_TURN_ACK,
+// whose keys are player IDs and whose values are non-negative numbers of
+// milliseconds (nil if absent).
+func readPlayerDeadlines(data map[string]interface{}, nbPlayers int) (
+	map[int]float64, error) {
+	if _, exists := data["player_deadlines"]; !exists {
+		return nil, nil
+	}
+
+	deadlines, err := ReadObject(data, "player_deadlines")
+	if err != nil {
+		return nil, err
+	}
+
+	playerDeadlines := make(map[int]float64)
+	for key, value := range deadlines {
+		playerID, err := strconv.Atoi(key)
+		if err != nil || playerID < 0 || playerID >= nbPlayers {
+			return nil, newFieldError("player_deadlines",
+				fmt.Sprintf("object whose keys are player IDs in [0, %v[", nbPlayers),
+				key, "Invalid player_deadlines key '%v': "+
+					"Not a player ID in [0, %v[", key, nbPlayers)
+		}
+
+		ms, isNumber := value.(float64)
+		if !isNumber {
+			return nil, wrongType("player_deadlines."+key, "number", value)
+		}
+		if ms < 0 {
+			return nil, newFieldError("player_deadlines."+key,
+				"non-negative number", ms,
+				"Invalid player_deadlines value for player %v: "+
+					"Negative deadline", playerID)
+		}
+		playerDeadlines[playerID] = ms
+	}
+
+	return playerDeadlines, nil
+}
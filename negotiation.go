@@ -0,0 +1,60 @@
+package netorcai
+
+import (
+	"bufio"
+	"encoding/binary"
+)
+
+// How messages are sent to a client, as negotiated at LOGIN.
+// The paths that send messages to clients (LOGIN_ACK aside) only consult
+// these capabilities, so that a new wire feature (e.g., compressed frames,
+// game state diffs or another encoding) is negotiated here, once per
+// connection, instead of being checked everywhere messages are sent.
+type clientCapabilities struct {
+	// Frame format of the messages sent (0 if the client asked none,
+	// which means v1 frames)
+	framing int
+	// Whether the client only gets the latest TURN when it acknowledges
+	// (visualizations only)
+	frameSkipping bool
+	// Whether the client is sent PLAYER_LEFT messages (game logic only)
+	playerLeft bool
+	// Whether the client is sent VISUS_CHANGED messages (game logic only)
+	visuChanges bool
+	// Whether the client is sent LOBBY messages (players and
+	// visualizations only)
+	lobby bool
+}
+
+// Decides the capabilities of a client from its LOGIN message.
+func negotiateCapabilities(login MessageLogin) clientCapabilities {
+	return clientCapabilities{
+		framing:       login.framing,
+		frameSkipping: login.role == "visualization" && login.frameSkipping,
+		playerLeft:    login.role == "game logic" && login.playerLeft,
+		visuChanges:   login.role == "game logic" && login.visuChanges,
+		lobby:         login.role != "game logic" && login.lobby,
+	}
+}
+
+// Writes a message with the frame format of the client.
+// Returns the size written in the frame header.
+func (c clientCapabilities) writeFrame(writer *bufio.Writer,
+	content []byte) (uint32, error) {
+	if c.framing == framingV2 {
+		return writeFrameV2(writer, content)
+	}
+	return writeFrameV1(writer, content)
+}
+
+// Returns the number of bytes of the frame that writeFrame writes for a
+// message of contentSize bytes (header included).
+func (c clientCapabilities) frameSize(contentSize int) int {
+	if c.framing == framingV2 {
+		header := make([]byte, binary.MaxVarintLen64)
+		// Size, then flags
+		return binary.PutUvarint(header, uint64(contentSize)) + 1 + contentSize
+	}
+	// Size (4 bytes), then content and a trailing newline
+	return 4 + contentSize + 1
+}
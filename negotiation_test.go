@@ -0,0 +1,79 @@
+package netorcai
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiateCapabilities(t *testing.T) {
+	capabilities := negotiateCapabilities(MessageLogin{role: "player"})
+	assert.Equal(t, clientCapabilities{}, capabilities)
+
+	capabilities = negotiateCapabilities(MessageLogin{role: "player",
+		framing: framingV2, frameSkipping: true})
+	assert.Equal(t, framingV2, capabilities.framing)
+	assert.False(t, capabilities.frameSkipping, "Player skips frames")
+
+	capabilities = negotiateCapabilities(MessageLogin{role: "visualization",
+		frameSkipping: true})
+	assert.True(t, capabilities.frameSkipping, "Visualization skips no frame")
+}
+
+func TestCapabilitiesWriteFrame(t *testing.T) {
+	for _, framing := range []int{0, framingV1, framingV2} {
+		var frame bytes.Buffer
+		writer := bufio.NewWriter(&frame)
+		_, err := clientCapabilities{framing: framing}.writeFrame(writer,
+			[]byte(`{"a":1}`))
+		assert.NoError(t, err, "Cannot write frame (framing=%v)", framing)
+		writer.Flush()
+		assert.Equal(t, frame.Len(),
+			clientCapabilities{framing: framing}.frameSize(len(`{"a":1}`)),
+			"Unexpected frame size (framing=%v)", framing)
+
+		reader := bufio.NewReader(&frame)
+		var content []byte
+		if framing == framingV2 {
+			content, _, err = readFrame(reader, framingV2, 1000, "")
+		} else {
+			content, _, err = readFrame(reader, framingV1, 1000, "%v")
+			content = bytes.TrimSuffix(content, []byte("\n"))
+		}
+		assert.NoError(t, err, "Cannot read frame (framing=%v)", framing)
+		assert.Equal(t, `{"a":1}`, string(content), "framing=%v", framing)
+	}
+}
+
+func TestNegotiatePlayerLeft(t *testing.T) {
+	capabilities := negotiateCapabilities(MessageLogin{role: "game logic",
+		playerLeft: true})
+	assert.True(t, capabilities.playerLeft, "Game logic not sent PLAYER_LEFT")
+
+	capabilities = negotiateCapabilities(MessageLogin{role: "player",
+		playerLeft: true})
+	assert.False(t, capabilities.playerLeft, "Player sent PLAYER_LEFT")
+}
+
+func TestNegotiateVisuChanges(t *testing.T) {
+	capabilities := negotiateCapabilities(MessageLogin{role: "game logic",
+		visuChanges: true})
+	assert.True(t, capabilities.visuChanges, "Game logic not sent VISUS_CHANGED")
+
+	capabilities = negotiateCapabilities(MessageLogin{role: "visualization",
+		visuChanges: true})
+	assert.False(t, capabilities.visuChanges, "Visualization sent VISUS_CHANGED")
+}
+
+func TestNegotiateLobby(t *testing.T) {
+	for _, role := range []string{"player", "special player", "visualization"} {
+		capabilities := negotiateCapabilities(MessageLogin{role: role,
+			lobby: true})
+		assert.True(t, capabilities.lobby, "%v not sent LOBBY", role)
+	}
+
+	capabilities := negotiateCapabilities(MessageLogin{role: "game logic",
+		lobby: true})
+	assert.False(t, capabilities.lobby, "Game logic sent LOBBY")
+}
@@ -1,4 +1,4 @@
-package test
+package netorcaitest
 
 import (
 	"fmt"
@@ -23,24 +23,24 @@ type GLDoTurnAckFunc func(int, []interface{}) string
 func DefaultHelloClientCheckGameStarts(t *testing.T,
 	msg map[string]interface{}, nbPlayers, nbSpecialPlayers, nbTurnsGL int,
 	msBeforeFirstTurn, msBetweenTurns float64, isPlayer bool) int {
-	playerID := checkGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
+	playerID := CheckGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
 		msBeforeFirstTurn, msBetweenTurns, isPlayer)
 	return playerID
 }
 
 func DefaultHelloClientCheckTurn(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
-	return checkTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	return CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
 }
 
 func DefaultHelloClientCheckGameEnds(t *testing.T,
 	msg map[string]interface{}, clientName string) {
-	checkGameEnds(t, msg, clientName)
+	CheckGameEnds(t, msg, clientName)
 }
 
 func DefaultHelloGLCheckDoTurn(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int) []interface{} {
-	actions := checkDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
+	actions := CheckDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
 	return actions
 }
 
@@ -60,15 +60,15 @@ func DefaultHelloGlDoTurnAck(turn int, actions []interface{}) string {
 		"game_state":{"all_clients":{}}}`
 }
 
-func helloGameLogic(t *testing.T, glClient *client.Client,
+func HelloGameLogic(t *testing.T, glClient *client.Client,
 	nbPlayers, nbSpecialPlayers, nbTurnsNetorcai, nbTurns int,
 	checkDoTurnFunc GLCheckDoTurnFunc,
 	doInitAckFunc GLDoInitAckFunc, doTurnAckFunc GLDoTurnAckFunc,
 	kickReasonMatcher *regexp.Regexp) {
 	// Wait DO_INIT
-	msg, err := waitReadMessage(glClient, 1000)
+	msg, err := WaitReadMessage(glClient, 1000)
 	assert.NoError(t, err, "Could not read GLClient message (DO_INIT)")
-	checkDoInit(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsNetorcai)
+	CheckDoInit(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsNetorcai)
 
 	// Send DO_INIT_ACK
 	data := doInitAckFunc(nbPlayers, nbSpecialPlayers, nbTurnsNetorcai)
@@ -77,7 +77,7 @@ func helloGameLogic(t *testing.T, glClient *client.Client,
 
 	// Wait for DO_TURN
 	for turn := 0; turn < nbTurns; turn++ {
-		msg, err := waitReadMessage(glClient, 1000)
+		msg, err := WaitReadMessage(glClient, 1000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_TURN) "+
 			"%v/%v", turn, nbTurns)
 		actions := checkDoTurnFunc(t, msg, nbPlayers, nbSpecialPlayers, turn-1)
@@ -88,15 +88,15 @@ func helloGameLogic(t *testing.T, glClient *client.Client,
 		assert.NoError(t, err, "GLClient could not send DO_TURN_ACK")
 	}
 
-	msg, err = waitReadMessage(glClient, 1000)
+	msg, err = WaitReadKick(glClient, 1000)
 	assert.NoError(t, err, "Could not read GLClient message (KICK)")
-	checkKick(t, msg, "GameLogic", kickReasonMatcher)
+	CheckKick(t, msg, "GameLogic", kickReasonMatcher)
 
 	// Close socket
 	glClient.Disconnect()
 }
 
-func helloClient(t *testing.T, client *client.Client, clientName string,
+func HelloClient(t *testing.T, client *client.Client, clientName string,
 	nbPlayers, nbSpecialPlayers, nbTurnsGL, nbTurnsClient, turnsToSkip int,
 	msBeforeFirstTurn, msBetweenTurns float64,
 	isPlayer, allowTurnSkip, shouldTurnAckBeValid, shouldDoInitAckBeValid bool,
@@ -110,7 +110,7 @@ func helloClient(t *testing.T, client *client.Client, clientName string,
 
 	if shouldDoInitAckBeValid {
 		// Wait GAME_STARTS
-		msg, err := waitReadMessage(client, 1000)
+		msg, err := WaitReadMessage(client, 1000)
 		assert.NoError(t, err, "%v could not read message (GAME_STARTS)", clientName)
 		playerID := checkGameStartsFunc(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
 			msBeforeFirstTurn, msBetweenTurns, isPlayer)
@@ -118,7 +118,7 @@ func helloClient(t *testing.T, client *client.Client, clientName string,
 		if !allowTurnSkip {
 			for turn := 0; turn < nbTurnsClient-1; turn += 1 + turnsToSkip {
 				// Wait TURN
-				msg, err := waitReadMessage(client, 1000)
+				msg, err := WaitReadMessage(client, 1000)
 				assert.NoError(t, err, "%v could not read message (TURN) %v/%v",
 					clientName, turn, nbTurnsClient)
 				turnReceived := checkTurnFunc(t, msg, nbPlayers, nbSpecialPlayers, turn, isPlayer)
@@ -131,17 +131,17 @@ func helloClient(t *testing.T, client *client.Client, clientName string,
 
 			if shouldTurnAckBeValid {
 				// Wait GAME_ENDS
-				msg, err = waitReadMessage(client, 1000)
+				msg, err = WaitReadMessage(client, 1000)
 				assert.NoError(t, err, "%v could not read message (GAME_ENDS)", clientName)
 				checkGameEndsFunc(t, msg, clientName)
 			}
 		} else {
 		TurnLoop:
 			for turn := 0; turn < nbTurnsClient; turn += 1 {
-				msg, err := waitReadMessage(client, 1000)
+				msg, err := WaitReadMessage(client, 1000)
 				assert.NoError(t, err, "%v could not read message (TURN or GAME_ENDS) %v/%v"+
 					clientName, turn, nbTurnsClient)
-				turnReceived := checkTurnPotentialTurnsSkipped(t, msg, nbPlayers, nbSpecialPlayers, turn, isPlayer)
+				turnReceived := CheckTurnPotentialTurnsSkipped(t, msg, nbPlayers, nbSpecialPlayers, turn, isPlayer)
 
 				messageType, _ := netorcai.ReadString(msg, "message_type")
 
@@ -161,7 +161,7 @@ func helloClient(t *testing.T, client *client.Client, clientName string,
 	}
 
 	// Wait Kick
-	msg, err := waitReadMessage(client, 2000)
+	msg, err := WaitReadKick(client, 2000)
 	assert.NoError(t, err, "Could not read %v message (KICK)", clientName)
-	checkKick(t, msg, clientName, kickReasonMatcher)
+	CheckKick(t, msg, clientName, kickReasonMatcher)
 }
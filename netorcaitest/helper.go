@@ -1,4 +1,7 @@
-package test
+// Package netorcaitest exposes the helpers used by netorcai's integration
+// tests, so that game logic and client projects can test their programs
+// against a real netorcai process.
+package netorcaitest
 
 import (
 	"fmt"
@@ -12,7 +15,7 @@ import (
 	"time"
 )
 
-func readFloat(data map[string]interface{}, field string) (float64, error) {
+func ReadFloat(data map[string]interface{}, field string) (float64, error) {
 	value, exists := data[field]
 	if !exists {
 		return 0, fmt.Errorf("Field '%v' is missing", field)
@@ -26,7 +29,7 @@ func readFloat(data map[string]interface{}, field string) (float64, error) {
 	}
 }
 
-func readBool(data map[string]interface{}, field string) (bool, error) {
+func ReadBool(data map[string]interface{}, field string) (bool, error) {
 	value, exists := data[field]
 	if !exists {
 		return false, fmt.Errorf("Field '%v' is missing", field)
@@ -41,23 +44,23 @@ func readBool(data map[string]interface{}, field string) (bool, error) {
 }
 
 // Netorcai helpers
-func runNetorcaiWaitListening(t *testing.T,
+func RunNetorcaiWaitListening(t *testing.T,
 	arguments []string) *NetorcaiProcess {
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, arguments)
+	proc, err := RunNetorcaiCover(coverFile, arguments)
 	assert.NoError(t, err, "Cannot start netorcai")
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = WaitListening(proc.OutputControl, 1000)
 	if err != nil {
-		killallNetorcai()
+		KillallNetorcai()
 		assert.NoError(t, err, "Netorcai is not listening")
 	}
 
 	return proc
 }
 
-func waitCompletionTimeout(completion chan int, timeoutMS int) (
+func WaitCompletionTimeout(completion chan int, timeoutMS int) (
 	exitCode int, err error) {
 	select {
 	case exitCode := <-completion:
@@ -67,7 +70,7 @@ func waitCompletionTimeout(completion chan int, timeoutMS int) (
 	}
 }
 
-func waitOutputTimeout(re *regexp.Regexp, output chan string,
+func WaitOutputTimeout(re *regexp.Regexp, output chan string,
 	timeoutMS int, leaveOnNonMatch bool) (matchingLine string, err error) {
 	timeoutReached := make(chan int)
 	stopTimeout := make(chan int)
@@ -99,25 +102,25 @@ func waitOutputTimeout(re *regexp.Regexp, output chan string,
 	}
 }
 
-func waitListening(output chan string, timeoutMS int) (
+func WaitListening(output chan string, timeoutMS int) (
 	matchingLine string, err error) {
 	re := regexp.MustCompile("Listening incoming connections")
-	return waitOutputTimeout(re, output, timeoutMS, true)
+	return WaitOutputTimeout(re, output, timeoutMS, true)
 }
 
-func killallNetorcai() error {
+func KillallNetorcai() error {
 	cmd := exec.Command("killall")
 	cmd.Args = []string{"killall", "--quiet", "netorcai", "netorcai.cover"}
 	return cmd.Run()
 }
 
-func killallNetorcaiSIGKILL() error {
+func KillallNetorcaiSIGKILL() error {
 	cmd := exec.Command("killall")
 	cmd.Args = []string{"killall", "-KILL", "--quiet", "netorcai", "netorcai.cover"}
 	return cmd.Run()
 }
 
-func handleCoverage(t *testing.T, expRetCode int) (coverFilename string,
+func HandleCoverage(t *testing.T, expRetCode int) (coverFilename string,
 	expectedReturnCode int) {
 	_, exists := os.LookupEnv("DO_COVERAGE")
 	if exists {
@@ -138,7 +141,7 @@ func isTravis() bool {
 }
 
 // Client helpers
-func waitReadMessage(client *client.Client, timeoutMS int) (
+func WaitReadMessage(client *client.Client, timeoutMS int) (
 	map[string]interface{}, error) {
 
 	type readResult struct {
@@ -161,32 +164,38 @@ func waitReadMessage(client *client.Client, timeoutMS int) (
 	}
 }
 
-func connectClient(t *testing.T, role, nickname, metaprotocolVersion string, timeoutMS int) (
+func ConnectClient(t *testing.T, role, nickname, metaprotocolVersion string, timeoutMS int) (
 	*client.Client, error) {
 	client := &client.Client{}
 	err := client.Connect("localhost", 4242)
-	assert.NoError(t, err, "Cannot connect")
+	if !assert.NoError(t, err, "Cannot connect") {
+		return client, err
+	}
 
 	err = client.SendLogin(role, nickname, metaprotocolVersion)
-	assert.NoError(t, err, "Cannot send LOGIN")
+	if !assert.NoError(t, err, "Cannot send LOGIN") {
+		return client, err
+	}
 
-	msg, err := waitReadMessage(client, 1000)
-	assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
-	checkLoginAck(t, msg)
+	msg, err := WaitReadMessage(client, 1000)
+	if !assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)") {
+		return client, err
+	}
+	CheckLoginAck(t, msg)
 	return client, nil
 }
 
-func runNetorcaiAndClients(t *testing.T, arguments []string,
+func RunNetorcaiAndClients(t *testing.T, arguments []string,
 	timeoutMS int, nbPlayers, nbSpecialPlayers, nbVisus int) (
 	proc *NetorcaiProcess, clients, playerClients, specialPlayerClients, visuClients,
 	glClients []*client.Client) {
-	proc = runNetorcaiWaitListening(t, arguments)
+	proc = RunNetorcaiWaitListening(t, arguments)
 
 	// Players
 	for i := 0; i < nbPlayers; i++ {
-		player, err := connectClient(t, "player", "player", netorcai.Version, timeoutMS)
+		player, err := ConnectClient(t, "player", "player", netorcai.Version, timeoutMS)
 		if err != nil {
-			killallNetorcai()
+			KillallNetorcai()
 			assert.NoError(t, err, "Cannot connect client")
 		}
 		clients = append(clients, player)
@@ -195,9 +204,9 @@ func runNetorcaiAndClients(t *testing.T, arguments []string,
 
 	// Special players
 	for i := 0; i < nbSpecialPlayers; i++ {
-		splayer, err := connectClient(t, "special player", "splayer", netorcai.Version, timeoutMS)
+		splayer, err := ConnectClient(t, "special player", "splayer", netorcai.Version, timeoutMS)
 		if err != nil {
-			killallNetorcai()
+			KillallNetorcai()
 			assert.NoError(t, err, "Cannot connect client")
 		}
 		clients = append(clients, splayer)
@@ -206,9 +215,9 @@ func runNetorcaiAndClients(t *testing.T, arguments []string,
 
 	// Visus
 	for i := 0; i < nbVisus; i++ {
-		visu, err := connectClient(t, "visualization", "visu", netorcai.Version, timeoutMS)
+		visu, err := ConnectClient(t, "visualization", "visu", netorcai.Version, timeoutMS)
 		if err != nil {
-			killallNetorcai()
+			KillallNetorcai()
 			assert.NoError(t, err, "Cannot connect client")
 		}
 		clients = append(clients, visu)
@@ -217,9 +226,9 @@ func runNetorcaiAndClients(t *testing.T, arguments []string,
 
 	// Game Logic
 	for i := 0; i < 1; i++ {
-		gl, err := connectClient(t, "game logic", "game_logic", netorcai.Version, timeoutMS)
+		gl, err := ConnectClient(t, "game logic", "game_logic", netorcai.Version, timeoutMS)
 		if err != nil {
-			killallNetorcai()
+			KillallNetorcai()
 			assert.NoError(t, err, "Cannot connect client")
 		}
 		clients = append(clients, gl)
@@ -229,14 +238,14 @@ func runNetorcaiAndClients(t *testing.T, arguments []string,
 	return proc, clients, playerClients, specialPlayerClients, visuClients, glClients
 }
 
-func runNetorcaiAndAllClients(t *testing.T, arguments []string,
+func RunNetorcaiAndAllClients(t *testing.T, arguments []string,
 	timeoutMS int, nbSpecialPlayers int) (
 	proc *NetorcaiProcess, clients, playerClients, specialPlayerClients, visuClients,
 	glClients []*client.Client) {
-	return runNetorcaiAndClients(t, arguments, timeoutMS, 4, nbSpecialPlayers, 1)
+	return RunNetorcaiAndClients(t, arguments, timeoutMS, 4, nbSpecialPlayers, 1)
 }
 
-func checkAllKicked(t *testing.T, clients []*client.Client,
+func CheckAllKicked(t *testing.T, clients []*client.Client,
 	reasonMatcher *regexp.Regexp, timeoutMS int) {
 	timeoutReached := make(chan int)
 	stopTimeout := make(chan int)
@@ -255,12 +264,12 @@ func checkAllKicked(t *testing.T, clients []*client.Client,
 	for _, cli := range clients {
 		go func(c *client.Client) {
 			for {
-				msg, err := waitReadMessage(c, timeoutMS)
+				msg, err := WaitReadMessage(c, timeoutMS)
 				assert.NoError(t, err, "Cannot read client message (KICK)")
 
 				messageType, err := netorcai.ReadString(msg, "message_type")
 				if messageType == "KICK" {
-					checkKick(t, msg, "AnyClient", reasonMatcher)
+					CheckKick(t, msg, "AnyClient", reasonMatcher)
 					kickChan <- 0
 					return
 				}
@@ -280,7 +289,7 @@ func checkAllKicked(t *testing.T, clients []*client.Client,
 	close(kickChan)
 }
 
-func checkKick(t *testing.T, msg map[string]interface{}, clientName string,
+func CheckKick(t *testing.T, msg map[string]interface{}, clientName string,
 	reasonMatcher *regexp.Regexp) {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err,
@@ -290,9 +299,51 @@ func checkKick(t *testing.T, msg map[string]interface{}, clientName string,
 	kickReason, err := netorcai.ReadString(msg, "kick_reason")
 	assert.NoError(t, err, "%v cannot read 'kick_reason' in received client message (KICK)", clientName)
 	assert.Regexp(t, reasonMatcher, kickReason, "%v got kicked for unexpected reason", clientName)
+
+	_, err = netorcai.ReadString(msg, "kick_code")
+	assert.NoError(t, err, "%v cannot read 'kick_code' in received client message (KICK)", clientName)
 }
 
-func checkLoginAck(t *testing.T, msg map[string]interface{}) {
+func CheckKickCode(t *testing.T, msg map[string]interface{}, clientName string,
+	expectedCode netorcai.KickCode) {
+	kickCode, err := netorcai.ReadString(msg, "kick_code")
+	assert.NoError(t, err, "%v cannot read 'kick_code' in received client message (KICK)", clientName)
+	assert.Equal(t, string(expectedCode), kickCode, "%v got kicked with unexpected code", clientName)
+}
+
+func CheckError(t *testing.T, msg map[string]interface{}, clientName string,
+	expectedFieldPath string) {
+	messageType, err := netorcai.ReadString(msg, "message_type")
+	assert.NoError(t, err,
+		"%v cannot read 'message_type' field in received client message (ERROR)", clientName)
+	assert.Equal(t, "ERROR", messageType, "Unexpected message type")
+
+	_, err = netorcai.ReadString(msg, "error_message")
+	assert.NoError(t, err, "%v cannot read 'error_message' in received client message (ERROR)", clientName)
+
+	fieldPath, err := netorcai.ReadString(msg, "field_path")
+	assert.NoError(t, err, "%v cannot read 'field_path' in received client message (ERROR)", clientName)
+	assert.Equal(t, expectedFieldPath, fieldPath, "%v got an error on an unexpected field", clientName)
+}
+
+// Reads the next KICK message, skipping the ERROR messages that precede it
+// when the client is kicked because of an invalid message.
+func WaitReadKick(client *client.Client, timeoutMS int) (
+	map[string]interface{}, error) {
+	for {
+		msg, err := WaitReadMessage(client, timeoutMS)
+		if err != nil {
+			return msg, err
+		}
+
+		messageType, _ := netorcai.ReadString(msg, "message_type")
+		if messageType != "ERROR" {
+			return msg, nil
+		}
+	}
+}
+
+func CheckLoginAck(t *testing.T, msg map[string]interface{}) {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err, "Cannot read 'message_type' field in "+
 		"received client message (LOGIN_ACK)")
@@ -310,7 +361,7 @@ func checkLoginAck(t *testing.T, msg map[string]interface{}) {
 	}
 }
 
-func checkDoInit(t *testing.T, msg map[string]interface{},
+func CheckDoInit(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedNbTurnsMax int) {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err, "Cannot read 'message_type' field in "+
@@ -343,7 +394,7 @@ func checkDoInit(t *testing.T, msg map[string]interface{},
 	}
 }
 
-func checkDoTurn(t *testing.T, msg map[string]interface{},
+func CheckDoTurn(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int) []interface{} {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err, "Cannot read 'message_type' field in "+
@@ -372,6 +423,18 @@ func checkDoTurn(t *testing.T, msg map[string]interface{},
 				"message: Should be in [0,%v[",
 				playerID, playerIndex, expectedNbPlayers+expectedNbSpecialPlayers)
 
+			// Special players have the first player IDs
+			expectedRole := "player"
+			if playerID < expectedNbSpecialPlayers {
+				expectedRole = "special player"
+			}
+			role, err := netorcai.ReadString(obj, "role")
+			assert.NoError(t, err, "Invalid player_actions in DO_TURN "+
+				"message: Cannot read role in array element %v",
+				playerIndex)
+			assert.Equal(t, expectedRole, role,
+				"Unexpected role in DO_TURN player action %v", playerIndex)
+
 			turnNumber, err := netorcai.ReadInt(obj, "turn_number")
 			assert.NoError(t, err, "Invalid player_actions in DO_TURN "+
 				"message: Cannot read turn_number in array element %v",
@@ -400,7 +463,7 @@ func checkDoTurn(t *testing.T, msg map[string]interface{},
 	return []interface{}{}
 }
 
-func checkPlayersInfo(t *testing.T, msg map[string]interface{},
+func CheckPlayersInfo(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers int, isPlayer bool) {
 	playersInfo, err := netorcai.ReadArray(msg, "players_info")
 	assert.NoError(t, err, "Cannot read players_info in GAME_STARTS")
@@ -431,7 +494,7 @@ func checkPlayersInfo(t *testing.T, msg map[string]interface{},
 				"players_info[%v] of GAME_STARTS message (as a visu)",
 				playerIndex)
 
-			_, err = readBool(obj, "is_connected")
+			_, err = ReadBool(obj, "is_connected")
 			assert.NoError(t, err, "Cannot read nickname in "+
 				"players_info[%v] of GAME_STARTS message (as a visu)",
 				playerIndex)
@@ -446,7 +509,7 @@ func checkPlayersInfo(t *testing.T, msg map[string]interface{},
 	}
 }
 
-func checkGameStarts(t *testing.T, msg map[string]interface{},
+func CheckGameStarts(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedNbTurnsMax int,
 	expectedMsBeforeFirstTurn, expectedMsBetweenTurns float64,
 	isPlayer bool) (playerID int) {
@@ -485,7 +548,7 @@ func checkGameStarts(t *testing.T, msg map[string]interface{},
 				playerID)
 		}
 
-		msBeforeFirstTurn, err := readFloat(msg,
+		msBeforeFirstTurn, err := ReadFloat(msg,
 			"milliseconds_before_first_turn")
 		assert.NoError(t, err,
 			"Cannot read milliseconds_before_first_turn in GAME_STARTS")
@@ -493,7 +556,7 @@ func checkGameStarts(t *testing.T, msg map[string]interface{},
 			1e-3, "Unexpected value for milliseconds_before_first_turn "+
 				"in GAME_STARTS message")
 
-		msBetweenTurns, err := readFloat(msg,
+		msBetweenTurns, err := ReadFloat(msg,
 			"milliseconds_before_first_turn")
 		assert.NoError(t, err,
 			"Cannot read milliseconds_before_first_turn in GAME_STARTS")
@@ -501,7 +564,7 @@ func checkGameStarts(t *testing.T, msg map[string]interface{},
 			1e-3, "Unexpected value for milliseconds_before_first_turn "+
 				"in GAME_STARTS message")
 
-		checkPlayersInfo(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, isPlayer)
+		CheckPlayersInfo(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, isPlayer)
 		return playerID
 	case "KICK":
 		kickReason, err := netorcai.ReadString(msg, "kick_reason")
@@ -515,7 +578,7 @@ func checkGameStarts(t *testing.T, msg map[string]interface{},
 	return -2
 }
 
-func checkTurn(t *testing.T, msg map[string]interface{},
+func CheckTurn(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err, "Cannot read 'message_type' field in "+
@@ -531,7 +594,7 @@ func checkTurn(t *testing.T, msg map[string]interface{},
 		_, err = netorcai.ReadObject(msg, "game_state")
 		assert.NoError(t, err, "Cannot read game_state in TURN")
 
-		checkPlayersInfo(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, isPlayer)
+		CheckPlayersInfo(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, isPlayer)
 		return turnNumber
 	case "KICK":
 		kickReason, err := netorcai.ReadString(msg, "kick_reason")
@@ -545,7 +608,7 @@ func checkTurn(t *testing.T, msg map[string]interface{},
 	return expectedTurnNumber
 }
 
-func checkTurnPotentialTurnsSkipped(t *testing.T, msg map[string]interface{},
+func CheckTurnPotentialTurnsSkipped(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedMinimalTurnNumber int, isPlayer bool) int {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err, "Cannot read 'message_type' field in "+
@@ -562,7 +625,7 @@ func checkTurnPotentialTurnsSkipped(t *testing.T, msg map[string]interface{},
 		_, err = netorcai.ReadObject(msg, "game_state")
 		assert.NoError(t, err, "Cannot read game_state in TURN")
 
-		checkPlayersInfo(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, isPlayer)
+		CheckPlayersInfo(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, isPlayer)
 		return turnNumber
 	case "GAME_ENDS":
 		_, err := netorcai.ReadInt(msg, "winner_player_id")
@@ -582,7 +645,7 @@ func checkTurnPotentialTurnsSkipped(t *testing.T, msg map[string]interface{},
 	return expectedMinimalTurnNumber
 }
 
-func checkGameEnds(t *testing.T, msg map[string]interface{}, clientName string) {
+func CheckGameEnds(t *testing.T, msg map[string]interface{}, clientName string) {
 	messageType, err := netorcai.ReadString(msg, "message_type")
 	assert.NoError(t, err,
 		"%v cannot read 'message_type' field in received message (GAME_ENDS)", clientName)
@@ -604,9 +667,14 @@ func checkGameEnds(t *testing.T, msg map[string]interface{}, clientName string)
 	}
 }
 
-func killNetorcaiGently(proc *NetorcaiProcess, timeoutMS int) error {
-	killallNetorcai()
+func KillNetorcaiGently(proc *NetorcaiProcess, timeoutMS int) error {
+	KillallNetorcai()
 
-	_, err := waitCompletionTimeout(proc.completion, timeoutMS)
+	_, err := WaitCompletionTimeout(proc.Completion, timeoutMS)
 	return err
 }
+
+// Sends a signal (e.g. SIGHUP) to a netorcai process.
+func SignalNetorcai(proc *NetorcaiProcess, sig os.Signal) error {
+	return proc.cmd.Process.Signal(sig)
+}
@@ -0,0 +1,201 @@
+package netorcaitest
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"time"
+)
+
+// Fault that a mock injects instead of answering a message.
+type Fault int
+
+const (
+	// The mock answers normally.
+	NoFault Fault = iota
+	// The mock closes its connection instead of answering.
+	FaultDisconnect
+	// The mock does not answer, but keeps reading messages.
+	FaultNoAnswer
+	// The mock answers with a message of unknown type.
+	FaultInvalidMessage
+)
+
+// Returns the delay before answering a turn.
+type MockDelayFunc func(turn int) time.Duration
+
+// Returns the fault to inject at a turn.
+type MockFaultFunc func(turn int) Fault
+
+// MockGameLogic is a scriptable game logic.
+// Its answers are generated by DoInitAck and DoTurnAck (the hello game logic
+// ones when nil), and Delay and Fault are called before each answer.
+// The DO_INIT answer is turn -1, the DO_TURN answers are turns 0, 1...
+type MockGameLogic struct {
+	DoInitAck GLDoInitAckFunc
+	DoTurnAck GLDoTurnAckFunc
+	Delay     MockDelayFunc
+	Fault     MockFaultFunc
+
+	// All the messages received, to inspect once Run has returned.
+	Received []map[string]interface{}
+}
+
+// MockClient is a scriptable player or visualization.
+// Its TURN_ACK messages are generated by TurnAck (the hello client one when
+// nil), and Delay and Fault are called with the turn number before each answer.
+type MockClient struct {
+	TurnAck ClientTurnAckFunc
+	Delay   MockDelayFunc
+	Fault   MockFaultFunc
+
+	// All the messages received, to inspect once Run has returned.
+	Received []map[string]interface{}
+}
+
+// Runs the game logic on a logged in client until it is kicked.
+// Returns the kick reason (empty if the mock disconnected itself).
+func (m *MockGameLogic) Run(glClient *client.Client) (string, error) {
+	defer glClient.Disconnect()
+
+	turn := 0
+	for {
+		msg, messageType, err := readMockMessage(glClient, &m.Received)
+		if err != nil {
+			return "", err
+		}
+
+		var answer string
+		switch messageType {
+		case "KICK":
+			return netorcai.ReadString(msg, "kick_reason")
+		case "PING":
+			answer = `{"message_type":"PONG"}`
+		case "ERROR":
+			// Followed by a KICK
+		case "DO_INIT":
+			doInitAck := m.DoInitAck
+			if doInitAck == nil {
+				doInitAck = DefaultHelloGLDoInitAck
+			}
+			nbPlayers, _ := netorcai.ReadInt(msg, "nb_players")
+			nbSpecialPlayers, _ := netorcai.ReadInt(msg, "nb_special_players")
+			nbTurnsMax, _ := netorcai.ReadInt(msg, "nb_turns_max")
+			answer = doInitAck(nbPlayers, nbSpecialPlayers, nbTurnsMax)
+
+			if !injectFault(glClient, -1, m.Delay, m.Fault, &answer) {
+				return "", nil
+			}
+		case "DO_TURN":
+			doTurnAck := m.DoTurnAck
+			if doTurnAck == nil {
+				doTurnAck = DefaultHelloGlDoTurnAck
+			}
+			actions, _ := netorcai.ReadArray(msg, "player_actions")
+			answer = doTurnAck(turn, actions)
+
+			if !injectFault(glClient, turn, m.Delay, m.Fault, &answer) {
+				return "", nil
+			}
+			turn++
+		default:
+			return "", fmt.Errorf("Unexpected message received by the mock "+
+				"game logic: %v", messageType)
+		}
+
+		if answer != "" {
+			err = glClient.SendString(answer)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// Runs the player or visualization on a logged in client until it is kicked.
+// Returns the kick reason (empty if the mock disconnected itself).
+func (m *MockClient) Run(c *client.Client) (string, error) {
+	defer c.Disconnect()
+
+	playerID := -1
+	for {
+		msg, messageType, err := readMockMessage(c, &m.Received)
+		if err != nil {
+			return "", err
+		}
+
+		var answer string
+		switch messageType {
+		case "KICK":
+			return netorcai.ReadString(msg, "kick_reason")
+		case "PING":
+			answer = `{"message_type":"PONG"}`
+		case "ERROR":
+			// Followed by a KICK
+		case "GAME_STARTS":
+			playerID, _ = netorcai.ReadInt(msg, "player_id")
+		case "GAME_ENDS":
+		case "TURN":
+			turnAck := m.TurnAck
+			if turnAck == nil {
+				turnAck = DefaultHelloClientTurnAck
+			}
+			turn, err := netorcai.ReadInt(msg, "turn_number")
+			if err != nil {
+				return "", err
+			}
+			answer = turnAck(turn, playerID)
+
+			if !injectFault(c, turn, m.Delay, m.Fault, &answer) {
+				return "", nil
+			}
+		default:
+			return "", fmt.Errorf("Unexpected message received by the mock "+
+				"client: %v", messageType)
+		}
+
+		if answer != "" {
+			err = c.SendString(answer)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// Reads a message and appends it to the received ones.
+func readMockMessage(c *client.Client, received *[]map[string]interface{}) (
+	map[string]interface{}, string, error) {
+	msg, err := c.ReadMessage()
+	if err != nil {
+		return msg, "", err
+	}
+	*received = append(*received, msg)
+
+	messageType, err := netorcai.ReadString(msg, "message_type")
+	return msg, messageType, err
+}
+
+// Waits for the turn delay then applies the turn fault on the answer.
+// Returns false if the mock must stop (as it disconnected).
+func injectFault(c *client.Client, turn int, delay MockDelayFunc,
+	fault MockFaultFunc, answer *string) bool {
+	if delay != nil {
+		time.Sleep(delay(turn))
+	}
+
+	if fault == nil {
+		return true
+	}
+
+	switch fault(turn) {
+	case FaultDisconnect:
+		c.Disconnect()
+		return false
+	case FaultNoAnswer:
+		*answer = ""
+	case FaultInvalidMessage:
+		*answer = `{"message_type":"MOCK_FAULT"}`
+	}
+	return true
+}
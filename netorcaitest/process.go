@@ -1,4 +1,4 @@
-package test
+package netorcaitest
 
 import (
 	"bufio"
@@ -13,18 +13,18 @@ type NetorcaiProcess struct {
 	cmd           *exec.Cmd
 	stdinPipe     io.WriteCloser
 	stdoutPipe    io.ReadCloser
-	inputControl  chan string // user can send messages on this channel
-	outputControl chan string // user can receive messages on this channel
-	completion    chan int    // user can receive an exit code on this channel
-	printOutput   bool        // whether stdout lines should be printed
+	InputControl  chan string // user can send messages on this channel
+	OutputControl chan string // user can receive messages on this channel
+	Completion    chan int    // user can receive an exit code on this channel
+	PrintOutput   bool        // whether stdout lines should be printed
 }
 
-func runNetorcai(command string, arguments []string) (*NetorcaiProcess, error) {
+func RunNetorcai(command string, arguments []string) (*NetorcaiProcess, error) {
 	proc := &NetorcaiProcess{
-		inputControl:  make(chan string),
-		outputControl: make(chan string, 64),
-		completion:    make(chan int),
-		printOutput:   false,
+		InputControl:  make(chan string),
+		OutputControl: make(chan string, 64),
+		Completion:    make(chan int),
+		PrintOutput:   false,
 	}
 	proc.cmd = exec.Command(command)
 	proc.cmd.Args = append([]string{command}, arguments...)
@@ -45,14 +45,14 @@ func runNetorcai(command string, arguments []string) (*NetorcaiProcess, error) {
 		return proc, fmt.Errorf("Cannot start process. %v", err)
 	}
 
-	go lineReader(bufio.NewReader(proc.stdoutPipe), proc.outputControl,
-		&proc.printOutput)
-	go lineWriter(bufio.NewWriter(proc.stdinPipe), proc.inputControl)
-	go waitCompletion(proc.cmd, proc.completion)
+	go lineReader(bufio.NewReader(proc.stdoutPipe), proc.OutputControl,
+		&proc.PrintOutput)
+	go lineWriter(bufio.NewWriter(proc.stdinPipe), proc.InputControl)
+	go waitCompletion(proc.cmd, proc.Completion)
 	return proc, nil
 }
 
-func runNetorcaiCover(coverFile string, arguments []string) (
+func RunNetorcaiCover(coverFile string, arguments []string) (
 	*NetorcaiProcess, error) {
 	if coverFile != "" {
 		// Bypass arguments
@@ -65,9 +65,9 @@ func runNetorcaiCover(coverFile string, arguments []string) (
 		arguments = append([]string{"-test.coverprofile=" + coverFile},
 			arguments...)
 
-		return runNetorcai("netorcai.cover", arguments)
+		return RunNetorcai("netorcai.cover", arguments)
 	} else {
-		return runNetorcai("netorcai", arguments)
+		return RunNetorcai("netorcai", arguments)
 	}
 }
 
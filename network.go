@@ -2,59 +2,202 @@ package netorcai
 
 import (
 	"bufio"
-	"encoding/binary"
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 	"net"
 	"strconv"
+	"sync"
+	"time"
 )
 
+// Maximum wait for the LOGIN of the clients that hold a pending login slot,
+// unless GlobalState.PendingLoginTimeout is set
+const defaultPendingLoginTimeout = 10 * time.Second
+
 type Client struct {
 	Conn             net.Conn
 	nickname         string
+	account          string
 	state            int
 	reader           *bufio.Reader
 	writer           *bufio.Writer
 	incomingMessages chan ClientMessage
-	canTerminate     chan string
+	canTerminate     chan kickOrder
+	ping             chan chan *Client
+	pendingPong      chan *Client
+	pendingLogins    chan int
+	// Maximum wait for the LOGIN message (0: unbounded)
+	loginTimeout time.Duration
+	// How messages are sent to the client (set once LOGIN_ACK is sent)
+	capabilities clientCapabilities
+	// Maximum number of elements of the arrays in the messages of players
+	// and visualizations (0 means unlimited)
+	maxArrayLength int
+
+	statsMutex    sync.Mutex
+	protocolStats ClientProtocolStats
 }
 
 type ClientMessage struct {
 	content map[string]interface{}
 	err     error
+	errCode KickCode
+	// When the message was read from the socket
+	receivedAt time.Time
+	// The content as received (even if it is not valid JSON)
+	raw []byte
+}
+
+// Asks the goroutine handling a client to kick it
+type kickOrder struct {
+	code   KickCode
+	reason string
 }
 
-func RunServer(port int, globalState *GlobalState, onexit,
+// Returns the channel closed once RunServer listens incoming connections.
+func (gs *GlobalState) listeningChannel() chan int {
+	gs.Mutex.Lock()
+	defer gs.Mutex.Unlock()
+
+	if gs.listeningDone == nil {
+		gs.listeningDone = make(chan int)
+	}
+	return gs.listeningDone
+}
+
+// Runs the server until one of its acceptors fails (onexit is then notified)
+// or until ctx is cancelled. The game loop also stops waiting between turns
+// once ctx is cancelled.
+func RunServer(ctx context.Context, port int, globalState *GlobalState, onexit,
 	gameLogicExit chan int) {
 	defer globalState.WaitGroup.Done()
 	// Listen all incoming TCP connections on the specified port
 	listenAddress := ":" + strconv.Itoa(port)
+	nbAcceptors := globalState.NbAcceptors
+	if nbAcceptors < 1 {
+		nbAcceptors = 1
+	}
+
 	globalState.Mutex.Lock()
+	globalState.ctx = ctx
 	var err error
-	globalState.Listener, err = net.Listen("tcp", listenAddress)
+	if globalState.ReusePort {
+		// One listener per acceptor, the kernel balances connections
+		for i := 0; i < nbAcceptors && err == nil; i++ {
+			var listener net.Listener
+			listener, err = listenReusePort(listenAddress)
+			if err == nil {
+				globalState.Listeners = append(globalState.Listeners, listener)
+			}
+		}
+	} else {
+		// All acceptors share the same listener
+		var listener net.Listener
+		listener, err = net.Listen("tcp", listenAddress)
+		if err == nil {
+			globalState.Listeners = append(globalState.Listeners, listener)
+		}
+	}
+	globalState.Listening = err == nil
+	listeners := append([]net.Listener(nil), globalState.Listeners...)
 	globalState.Mutex.Unlock()
+	defer closeListeners(listeners)
+
 	if err != nil {
 		log.WithFields(log.Fields{
 			"err":            err,
 			"network":        "tcp",
 			"listen address": listenAddress,
+			"reuse port":     globalState.ReusePort,
 		}).Error("Cannot listen incoming connections")
 		onexit <- 1
 		return
 	}
 
 	log.WithFields(log.Fields{
-		"port": port,
+		"port":      port,
+		"acceptors": nbAcceptors,
 	}).Info("Listening incoming connections")
-	defer globalState.Listener.Close()
+	close(globalState.listeningChannel())
+	SystemdNotify("READY=1")
+
+	// Bounds the number of connected clients that have not logged in yet.
+	// Once reached, acceptors stop accepting connections until a slot is free.
+	var pendingLogins chan int
+	if globalState.MaxPendingLogins > 0 {
+		pendingLogins = make(chan int, globalState.MaxPendingLogins)
+	}
+
+	acceptorExit := make(chan int, nbAcceptors)
+	stopAcceptors := make(chan int)
+	for i := 0; i < nbAcceptors; i++ {
+		listener := listeners[i%len(listeners)]
+		go acceptConnections(listener, globalState, pendingLogins,
+			stopAcceptors, acceptorExit, gameLogicExit)
+	}
+
+	// Aborting one acceptor aborts the whole server
+	nbExitedAcceptors := 0
+	select {
+	case <-acceptorExit:
+		nbExitedAcceptors = 1
+	case <-ctx.Done():
+		log.Info("Server context cancelled. Closing listening socket")
+	}
+
+	globalState.Mutex.Lock()
+	globalState.Listening = false
+	globalState.Mutex.Unlock()
+	close(stopAcceptors)
+	closeListeners(listeners)
+	for i := nbExitedAcceptors; i < nbAcceptors; i++ {
+		<-acceptorExit
+	}
+
+	if nbExitedAcceptors > 0 {
+		onexit <- 1
+	}
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
+
+func acceptConnections(listener net.Listener, globalState *GlobalState,
+	pendingLogins, stop, onexit, gameLogicExit chan int) {
+	// Clients that never log in must not hold their slot forever
+	loginTimeout := globalState.PendingLoginTimeout
+	if loginTimeout <= 0 {
+		loginTimeout = defaultPendingLoginTimeout
+	}
 
 	for {
+		if pendingLogins != nil {
+			select {
+			case pendingLogins <- 1:
+			case <-stop:
+				onexit <- 1
+				return
+			}
+		}
+
 		// Wait for an incoming connection.
-		client := &Client{}
-		client.Conn, err = globalState.Listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-stop:
+				// The listener has been closed on purpose
+				onexit <- 1
+				return
+			default:
+			}
+
 			log.WithFields(log.Fields{
 				"err": err,
 			}).Warn("Could not accept incoming connection. Aborting server.")
@@ -62,11 +205,11 @@ func RunServer(port int, globalState *GlobalState, onexit,
 			return
 		} else {
 			// Handle connections in a new goroutine.
-			client.reader = bufio.NewReader(client.Conn)
-			client.writer = bufio.NewWriter(client.Conn)
-			client.state = CLIENT_UNLOGGED
-			client.incomingMessages = make(chan ClientMessage)
-			client.canTerminate = make(chan string, 1)
+			client := newClient(conn)
+			if pendingLogins != nil {
+				client.pendingLogins = pendingLogins
+				client.loginTimeout = loginTimeout
+			}
 
 			globalState.WaitGroup.Add(1)
 			go handleClient(client, globalState, gameLogicExit)
@@ -74,59 +217,189 @@ func RunServer(port int, globalState *GlobalState, onexit,
 	}
 }
 
-func readClientMessage(client *Client, maximumAllowedSize uint32, errorFormatOnTooBigMessage string) bool {
-	var msg ClientMessage
-	// Receive message content size
-	contentSizeBuf := make([]byte, 4)
-	_, err := io.ReadFull(client.reader, contentSizeBuf)
-	if err != nil {
-		msg.err = fmt.Errorf("Remote endpoint closed? Read error: %v", err)
-		client.incomingMessages <- msg
-		return false
+func newClient(conn net.Conn) *Client {
+	return &Client{
+		Conn:             conn,
+		reader:           bufio.NewReader(conn),
+		writer:           bufio.NewWriter(conn),
+		state:            CLIENT_UNLOGGED,
+		incomingMessages: make(chan ClientMessage),
+		canTerminate:     make(chan kickOrder, 1),
+		ping:             make(chan chan *Client, 1),
 	}
+}
 
-	// Read message content size
-	contentSize := binary.LittleEndian.Uint32(contentSizeBuf)
-	if contentSize > maximumAllowedSize {
-		msg.err = fmt.Errorf(errorFormatOnTooBigMessage, contentSize)
-		client.incomingMessages <- msg
-		return false
+// Connects an in-process client to netorcai, without any network socket.
+// The client uses the returned connection as if it were connected via TCP.
+// This allows to measure serialization and game loop costs independently
+// of the network stack, or to embed clients in the same process.
+func ConnectLoopbackClient(globalState *GlobalState,
+	gameLogicExit chan int) net.Conn {
+	serverConn, clientConn := net.Pipe()
+
+	globalState.WaitGroup.Add(1)
+	go handleClient(newClient(serverConn), globalState, gameLogicExit)
+	return clientConn
+}
+
+// Connects to a client (e.g. a game logic or a player bot) that listens on
+// address, instead of waiting for it to connect. Once connected, the client
+// logs in as usual: role is only used in logs.
+// Connection attempts are retried until the client listens, or until ctx is
+// cancelled.
+func ConnectRemoteClient(ctx context.Context, address, role string,
+	globalState *GlobalState, gameLogicExit chan int) {
+	dialer := net.Dialer{Timeout: 2 * time.Second}
+	for attempt := 0; ; attempt++ {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			log.WithFields(log.Fields{
+				"address": address,
+				"role":    role,
+			}).Info("Connected to remote client")
+
+			globalState.WaitGroup.Add(1)
+			go handleClient(newClient(conn), globalState, gameLogicExit)
+			return
+		}
+
+		if attempt == 0 {
+			log.WithFields(log.Fields{
+				"address": address,
+				"role":    role,
+				"err":     err,
+			}).Info("Cannot connect to remote client yet. Retrying")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
 	}
+}
 
-	// Receive message content
-	contentBuf := make([]byte, contentSize)
-	_, err = io.ReadFull(client.reader, contentBuf)
-	if err != nil {
-		msg.err = fmt.Errorf("Remote endpoint closed? Read error: %v", err)
+// Updates the protocol statistics of a client.
+func (client *Client) updateStats(update func(stats *ClientProtocolStats)) {
+	client.statsMutex.Lock()
+	update(&client.protocolStats)
+	client.statsMutex.Unlock()
+}
+
+// Returns the protocol statistics of a client.
+func (client *Client) stats() ClientProtocolStats {
+	client.statsMutex.Lock()
+	defer client.statsMutex.Unlock()
+	return client.protocolStats
+}
+
+// Frees the pending login slot of a client. Called once its first message
+// has been received (or could not be received).
+func releasePendingLogin(client *Client) {
+	if client.pendingLogins != nil {
+		<-client.pendingLogins
+		client.pendingLogins = nil
+	}
+}
+
+// Reads a message framed as framing, and decodes it while it is received.
+// Returns its content, and whether the next messages can be read.
+func readClientMessage(client *Client, framing int, limits decodeLimits,
+	maximumAllowedSize uint32, errorFormatOnTooBigMessage string) (
+	map[string]interface{}, bool) {
+	var msg ClientMessage
+	var content bytes.Buffer
+	var decodeErr error
+	frame, err := newFrameReader(client.reader, framing, maximumAllowedSize,
+		errorFormatOnTooBigMessage)
+	if err == nil {
+		msg.content, decodeErr = decodeMessage(io.TeeReader(frame, &content),
+			limits)
+		err = frame.err
+	}
+	contentSize := frame.size()
+
+	if _, invalid := err.(*invalidFrameError); invalid {
+		msg.err = err
+		msg.errCode = KICK_PROTOCOL_ERROR
+		client.updateStats(func(stats *ClientProtocolStats) {
+			stats.MessagesReceived++
+			stats.InvalidMessages++
+		})
+		traceMessage(client, "in", contentSize, nil, msg.err)
 		client.incomingMessages <- msg
-		return false
+		return nil, false
+	} else if err != nil {
+		msg.err = err
+		msg.errCode = KICK_NETWORK_ERROR
+		if contentSize > 0 {
+			traceMessage(client, "in", contentSize, nil, msg.err)
+		}
+		client.incomingMessages <- msg
+		return nil, false
 	}
+	msg.receivedAt = time.Now()
+	msg.raw = content.Bytes()
+	traceMessage(client, "in", contentSize, msg.raw, nil)
+	client.updateStats(func(stats *ClientProtocolStats) {
+		stats.MessagesReceived++
+	})
 
 	log.WithFields(log.Fields{
 		"remote address": client.Conn.RemoteAddr(),
 		"nickname":       client.nickname,
 		"content size":   contentSize,
-		"content":        string(contentBuf),
+		"content":        content.String(),
 	}).Debug("New message received")
-	// Read message content
-	err = json.Unmarshal(contentBuf, &msg.content)
-	if err != nil {
+	if decodeErr != nil {
 		log.WithFields(log.Fields{
-			"err":             err,
-			"message content": string(contentBuf),
-		}).Debug("Non-JSON message received")
+			"err":             decodeErr,
+			"message content": content.String(),
+		}).Debug("Invalid message received")
 		msg.err = fmt.Errorf("Non-JSON message received")
+		if _, exceedsLimit := decodeErr.(*decodeLimitError); exceedsLimit {
+			msg.err = decodeErr
+		}
+		msg.errCode = KICK_PROTOCOL_ERROR
+		client.updateStats(func(stats *ClientProtocolStats) {
+			stats.InvalidMessages++
+		})
+		// The rest of the message is skipped, not decoded, so that the
+		// client receives its KICK before the socket is closed
+		io.Copy(ioutil.Discard, frame)
 		client.incomingMessages <- msg
-		return false
+		return nil, false
 	}
 
 	client.incomingMessages <- msg
-	return true
+	return msg.content, true
 }
 
+// Reads the messages of a client. The first one (LOGIN) is a v1 frame,
+// it sets the frame format of the next ones. The arrays of the messages of
+// players and visualizations are limited to client.maxArrayLength elements.
 func readClientMessages(client *Client) {
-	if readClientMessage(client, 1023, "Received message size of first message is too big: %v does not fit in 10 bits") {
-		for readClientMessage(client, 16777215, "Received message size is too big: %v does not fit in 24 bits") {
+	limits := decodeLimits{maxDepth: decodeMaxDepth}
+	if client.loginTimeout > 0 {
+		// The LOGIN read fails once the deadline is exceeded,
+		// which releases the pending login slot of the client
+		client.Conn.SetReadDeadline(time.Now().Add(client.loginTimeout))
+	}
+	login, ok := readClientMessage(client, framingV1, limits, 1023,
+		"Received message size of first message is too big: %v does not fit in 10 bits")
+	if ok {
+		if client.loginTimeout > 0 {
+			client.Conn.SetReadDeadline(time.Time{})
+		}
+		framing := loginFraming(login)
+		if role, _ := ReadString(login, "role"); role != "game logic" {
+			limits.maxArrayLength = client.maxArrayLength
+		}
+		for {
+			_, ok = readClientMessage(client, framing, limits, 16777215,
+				"Received message size is too big: %v does not fit in 24 bits")
+			if !ok {
+				return
+			}
 		}
 	}
 }
@@ -138,28 +411,20 @@ func sendMessage(client *Client, content []byte) error {
 		return fmt.Errorf("content too big: size does not fit in 24 bits")
 	}
 
-	// Write content size on socket
-	var contentSizeUint32 uint32 = uint32(contentSize) + 1 // +1 for \n
-	contentSizeBuf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(contentSizeBuf, contentSizeUint32)
-	_, err := client.writer.Write(contentSizeBuf)
-	if err != nil {
-		return fmt.Errorf("Remote endpoint closed? Write error: %v", err)
-	}
-
-	// Write content on socket
-	_, err = client.writer.Write(content)
+	// Write frame on socket
+	frameContentSize, err := client.capabilities.writeFrame(client.writer,
+		content)
 	if err != nil {
 		return fmt.Errorf("Remote endpoint closed? Write error: %v", err)
 	}
 
-	// Write terminating "\n" character on socket
-	err = client.writer.WriteByte(0x0A)
-	if err != nil {
+	// Flush socket
+	if err = client.writer.Flush(); err != nil {
 		return fmt.Errorf("Remote endpoint closed? Write error: %v", err)
 	}
-
-	// Flush socket
-	client.writer.Flush()
+	traceMessage(client, "out", frameContentSize, content, nil)
+	client.updateStats(func(stats *ClientProtocolStats) {
+		stats.MessagesSent++
+	})
 	return nil
 }
@@ -0,0 +1,100 @@
+package netorcai
+
+import (
+	"context"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRunServerContextCancel(t *testing.T) {
+	gs := &GlobalState{
+		GameState:   GAME_NOT_RUNNING,
+		NbAcceptors: 2,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	onexit := make(chan int, 1)
+	gameLogicExit := make(chan int, 1)
+
+	// Port 0 lets the system choose a free port
+	gs.WaitGroup.Add(1)
+	go RunServer(ctx, 0, gs, onexit, gameLogicExit)
+
+	var address string
+	for address == "" {
+		gs.Mutex.Lock()
+		if gs.Listening {
+			address = gs.Listeners[0].Addr().String()
+		}
+		gs.Mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := net.Dial("tcp", address)
+	assert.NoError(t, err, "Cannot connect to server")
+	conn.Close()
+
+	cancel()
+	serverDone := make(chan int)
+	go func() {
+		gs.WaitGroup.Wait()
+		close(serverDone)
+	}()
+
+	select {
+	case <-serverDone:
+	case <-time.After(time.Second):
+		assert.FailNow(t, "Server did not stop after context cancellation")
+	}
+
+	assert.False(t, gs.Listening, "Server should not be listening anymore")
+	assert.Equal(t, 0, len(onexit), "Cancelling the context is not a server failure")
+	_, err = net.Dial("tcp", address)
+	assert.Error(t, err, "Server should not accept connections anymore")
+}
+
+func TestPendingLoginTimeout(t *testing.T) {
+	gs := &GlobalState{
+		GameState:           GAME_NOT_RUNNING,
+		NbPlayersMax:        1,
+		MaxPendingLogins:    2,
+		PendingLoginTimeout: 200 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	onexit := make(chan int, 1)
+	gameLogicExit := make(chan int, 1)
+
+	gs.WaitGroup.Add(1)
+	go RunServer(ctx, 0, gs, onexit, gameLogicExit)
+	<-gs.listeningChannel()
+	gs.Mutex.Lock()
+	address := gs.Listeners[0].Addr().String()
+	gs.Mutex.Unlock()
+
+	// Idle connections take all the pending login slots
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", address)
+		if assert.NoError(t, err, "Cannot connect to server") {
+			defer conn.Close()
+		}
+	}
+
+	conn, err := net.Dial("tcp", address)
+	assert.NoError(t, err, "Cannot connect to server")
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var player client.Client
+	player.ConnectConn(conn)
+	defer player.Disconnect()
+
+	err = player.SendLogin("player", "player", Version)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := player.ReadMessage()
+	if assert.NoError(t, err, "Idle connections should not prevent logins") {
+		messageType, err := ReadString(msg, "message_type")
+		assert.NoError(t, err, "Cannot read message_type")
+		assert.Equal(t, "LOGIN_ACK", messageType, "Unexpected message type")
+	}
+}
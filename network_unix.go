@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package netorcai
+
+import (
+	"net"
+)
+
+// Sends a shutdown on the socket before closing it.
+// Combined with a SO_LINGER<0 (default for go sockets),
+// this should avoid loss of data sent by netorcai on client sockets.
+func shutdownConnection(conn net.Conn) {
+	if tcpConn, isTCP := conn.(*net.TCPConn); isTCP {
+		tcpConn.CloseWrite()
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package netorcai
+
+import (
+	"net"
+)
+
+// Half-closing sockets on Windows may reset the connection before the
+// client has read pending data. Instead, closing the socket is made to
+// block until pending data is sent (or a timeout is reached).
+func shutdownConnection(conn net.Conn) {
+	if tcpConn, isTCP := conn.(*net.TCPConn); isTCP {
+		tcpConn.SetLinger(5)
+	}
+}
@@ -0,0 +1,234 @@
+package netorcai
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The action deadlines the game logic set for the players of a turn
+// (player_deadlines in DO_TURN_ACK), e.g. so that only the active player of
+// a sequential game acts. Players without a positive deadline cannot act.
+// The turn ends as soon as every player that can act has done so or has
+// missed its deadline.
+// A nil playerDeadlines sets no deadline: Every player can act.
+// It is shared by the GL coroutine and the timer of the turn.
+type playerDeadlines struct {
+	// Never modified once created
+	turnNumber int
+	// As set by the game logic, in milliseconds
+	milliseconds map[int]float64
+	// When the TURN_ACK of every player that can act is due
+	due map[int]time.Time
+
+	mutex sync.Mutex
+	// Players that can act and have not done so yet
+	pending map[int]bool
+	// Whether the DO_TURN of the turn has been sent
+	closed bool
+	// Signaled when a pending player acts or leaves
+	changed chan int
+}
+
+// Returns nil if the game logic set no deadline.
+func newPlayerDeadlines(turnNumber int, milliseconds map[int]float64,
+	sentAt time.Time) *playerDeadlines {
+	if milliseconds == nil {
+		return nil
+	}
+
+	d := &playerDeadlines{
+		turnNumber:   turnNumber,
+		milliseconds: milliseconds,
+		due:          make(map[int]time.Time),
+		pending:      make(map[int]bool),
+		changed:      make(chan int, 1),
+	}
+	for playerID, ms := range milliseconds {
+		if ms > 0 {
+			d.due[playerID] = sentAt.Add(
+				time.Duration(ms * float64(time.Millisecond)))
+			d.pending[playerID] = true
+		}
+	}
+	return d
+}
+
+// Returns the deadline of a player in milliseconds (0 if it cannot act),
+// or nil if no deadline is set.
+func (d *playerDeadlines) of(playerID int) *float64 {
+	if d == nil {
+		return nil
+	}
+
+	ms := d.milliseconds[playerID]
+	return &ms
+}
+
+// Returns whether a player can act this turn.
+func (d *playerDeadlines) canAct(playerID int) bool {
+	if d == nil {
+		return true
+	}
+
+	_, canAct := d.due[playerID]
+	return canAct
+}
+
+// Returns the longest deadline in milliseconds (0 if no player can act).
+func (d *playerDeadlines) longest() float64 {
+	longest := 0.0
+	for _, ms := range d.milliseconds {
+		if ms > longest {
+			longest = ms
+		}
+	}
+	return longest
+}
+
+// Called when the actions of a player are received.
+// Returns whether they should be forwarded to the game logic: Actions of
+// players that cannot act, of another turn, received too late or after
+// the player has already acted are dropped.
+func (d *playerDeadlines) accept(action MessageDoTurnPlayerAction,
+	receivedAt time.Time) bool {
+	if d == nil {
+		return true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	reason := ""
+	due, canAct := d.due[action.PlayerID]
+	switch {
+	case !canAct:
+		reason = "Player cannot act this turn"
+	case d.closed || action.TurnNumber != d.turnNumber ||
+		receivedAt.After(due):
+		reason = "Player missed its action deadline"
+	case !d.pending[action.PlayerID]:
+		reason = "Player has already acted this turn"
+	}
+	if reason != "" {
+		log.WithFields(log.Fields{
+			"player ID":   action.PlayerID,
+			"turn number": action.TurnNumber,
+		}).Warn(reason + ". Actions dropped")
+		return false
+	}
+
+	d.done(action.PlayerID)
+	return true
+}
+
+// Called when a player leaves: It is no longer waited for.
+func (d *playerDeadlines) left(playerID int) {
+	if d == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.done(playerID)
+}
+
+// Must be called with the mutex held.
+func (d *playerDeadlines) done(playerID int) {
+	delete(d.pending, playerID)
+	select {
+	case d.changed <- 1:
+	default:
+	}
+}
+
+// Returns the pending players whose deadline has expired, sorted.
+func (d *playerDeadlines) expired(now time.Time) []int {
+	if d == nil {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	expired := []int{}
+	for playerID := range d.pending {
+		if !now.Before(d.due[playerID]) {
+			expired = append(expired, playerID)
+		}
+	}
+	sort.Ints(expired)
+	return expired
+}
+
+// Returns a channel that fires when the next deadline of a pending player
+// expires (nil if none will, so that it blocks forever).
+func (d *playerDeadlines) nextExpiry() <-chan time.Time {
+	due, found := d.nextDue()
+	if !found {
+		return nil
+	}
+	return time.After(time.Until(due))
+}
+
+// Returns the earliest deadline of the pending players that can still act,
+// if any.
+func (d *playerDeadlines) nextDue() (time.Time, bool) {
+	var next time.Time
+	if d == nil {
+		return next, false
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	found := false
+	for playerID := range d.pending {
+		due := d.due[playerID]
+		if due.After(now) && (!found || due.Before(next)) {
+			next, found = due, true
+		}
+	}
+	return next, found
+}
+
+// Called when the DO_TURN of the turn is sent: Later actions are dropped.
+// Returns the players that missed their deadline, sorted.
+func (d *playerDeadlines) close() []int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.closed = true
+	missed := []int{}
+	for playerID := range d.pending {
+		missed = append(missed, playerID)
+	}
+	sort.Ints(missed)
+	return missed
+}
+
+// Waits until every player that can act has done so or has missed its
+// deadline (timer mode).
+// Returns false if netorcai is shut down during the wait.
+func waitPlayerDeadlines(glClient *GameLogicClient,
+	deadlines *playerDeadlines) bool {
+	for {
+		due, found := deadlines.nextDue()
+		if !found {
+			break
+		}
+		globalWatchdog.timerArmed(due)
+
+		select {
+		case <-time.After(time.Until(due)):
+		case <-deadlines.changed:
+		case <-glClient.ctx.Done():
+			return false
+		}
+	}
+	globalWatchdog.progress()
+	return true
+}
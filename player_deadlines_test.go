@@ -0,0 +1,117 @@
+package netorcai
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPlayerDeadlinesNil(t *testing.T) {
+	deadlines := newPlayerDeadlines(0, nil, time.Now())
+	assert.Nil(t, deadlines, "No deadline should be set")
+	assert.Nil(t, deadlines.of(0), "No deadline should be sent")
+	assert.True(t, deadlines.canAct(0), "Every player should act")
+	assert.True(t, deadlines.accept(MessageDoTurnPlayerAction{PlayerID: 0},
+		time.Now()), "Every action should be accepted")
+	assert.Nil(t, deadlines.nextExpiry(), "No deadline should expire")
+}
+
+func TestPlayerDeadlines(t *testing.T) {
+	sentAt := time.Now()
+	deadlines := newPlayerDeadlines(3, map[int]float64{0: 1000, 1: 0, 2: 10},
+		sentAt)
+	assert.Equal(t, 0.0, *deadlines.of(1), "Unexpected deadline")
+	assert.Equal(t, 0.0, *deadlines.of(3), "Unexpected deadline")
+	assert.Equal(t, 1000.0, *deadlines.of(0), "Unexpected deadline")
+	assert.Equal(t, 1000.0, deadlines.longest(), "Unexpected longest deadline")
+	assert.True(t, deadlines.canAct(0), "Player 0 should act")
+	assert.False(t, deadlines.canAct(1), "Player 1 should not act")
+	assert.False(t, deadlines.canAct(3), "Player 3 should not act")
+
+	action := func(playerID, turnNumber int) MessageDoTurnPlayerAction {
+		return MessageDoTurnPlayerAction{PlayerID: playerID,
+			TurnNumber: turnNumber}
+	}
+	assert.False(t, deadlines.accept(action(1, 3), sentAt),
+		"Player that cannot act")
+	assert.False(t, deadlines.accept(action(0, 2), sentAt), "Previous turn")
+	assert.False(t, deadlines.accept(action(2, 3),
+		sentAt.Add(20*time.Millisecond)), "Late action")
+	assert.Equal(t, []int{2}, deadlines.expired(sentAt.Add(20*time.Millisecond)),
+		"Unexpected expired players")
+
+	assert.True(t, deadlines.accept(action(0, 3), sentAt), "Action in time")
+	assert.False(t, deadlines.accept(action(0, 3), sentAt), "Second action")
+	select {
+	case <-deadlines.changed:
+	default:
+		assert.Fail(t, "Action not signaled")
+	}
+
+	<-deadlines.nextExpiry()
+	_, found := deadlines.nextDue()
+	assert.False(t, found, "No player should be waited for")
+	assert.Equal(t, []int{2}, deadlines.close(), "Unexpected missed deadlines")
+	assert.False(t, deadlines.accept(action(0, 3), sentAt),
+		"Action after DO_TURN")
+}
+
+func TestPlayerDeadlinesLeft(t *testing.T) {
+	deadlines := newPlayerDeadlines(0, map[int]float64{0: 10000}, time.Now())
+	_, found := deadlines.nextDue()
+	assert.True(t, found, "Player 0 should be waited for")
+
+	deadlines.left(0)
+	_, found = deadlines.nextDue()
+	assert.False(t, found, "Player 0 should no longer be waited for")
+	assert.Empty(t, deadlines.close(), "Players that left miss no deadline")
+}
+
+func TestPlayerDeadlinesTimer(t *testing.T) {
+	// As in timer mode: The timer waits while the GL coroutine receives
+	// actions, then DO_TURN is sent once the timer is done
+	deadlines := newPlayerDeadlines(0, map[int]float64{0: 10000, 1: 10000,
+		2: 20}, time.Now())
+	glClient := &GameLogicClient{ctx: context.Background()}
+	waited := make(chan bool)
+	go func() {
+		waited <- waitPlayerDeadlines(glClient, deadlines)
+	}()
+
+	assert.True(t, deadlines.accept(MessageDoTurnPlayerAction{PlayerID: 0},
+		time.Now()), "Action in time")
+	deadlines.left(1)
+	select {
+	case result := <-waited:
+		assert.True(t, result, "Wait should not be interrupted")
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "Players still waited for")
+	}
+	assert.Equal(t, []int{2}, deadlines.close(), "Unexpected missed deadlines")
+}
+
+func TestReadPlayerDeadlines(t *testing.T) {
+	deadlines, err := readPlayerDeadlines(map[string]interface{}{}, 2)
+	assert.NoError(t, err, "player_deadlines is optional")
+	assert.Nil(t, deadlines, "No deadline should be read")
+
+	deadlines, err = readPlayerDeadlines(map[string]interface{}{
+		"player_deadlines": map[string]interface{}{"0": 500.0, "1": 0.0},
+	}, 2)
+	assert.NoError(t, err, "Cannot read player_deadlines")
+	assert.Equal(t, map[int]float64{0: 500, 1: 0}, deadlines,
+		"Unexpected deadlines")
+
+	for _, invalid := range []map[string]interface{}{
+		{"2": 500.0},
+		{"x": 500.0},
+		{"0": "500"},
+		{"0": -1.0},
+	} {
+		_, err = readPlayerDeadlines(map[string]interface{}{
+			"player_deadlines": invalid,
+		}, 2)
+		assert.Error(t, err, "Invalid player_deadlines %v", invalid)
+	}
+}
@@ -2,8 +2,11 @@ package netorcai
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/mpoquet/go-prompt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
 	"os"
 	"regexp"
 	"strconv"
@@ -24,12 +27,56 @@ func stringInSlice(searchedValue string, slice []string) bool {
 	return false
 }
 
+// Executes a prompt line, which may contain several ';'-separated commands.
+// Commands are only executed if they are all valid.
 func executor(line string) {
-	line = strings.TrimSpace(line)
+	actions := []func(){}
+	nbInvalidCommands := 0
+	for _, command := range strings.Split(line, ";") {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+
+		action, err := parseCommand(command)
+		if err != nil {
+			fmt.Println(err.Error())
+			nbInvalidCommands++
+		} else {
+			actions = append(actions, action)
+		}
+	}
+
+	if nbInvalidCommands > 0 {
+		if len(actions) > 0 {
+			fmt.Println("No command executed")
+		}
+		return
+	}
+
+	for _, action := range actions {
+		action()
+	}
+}
+
+// Checks the syntax and values of a single prompt command.
+// Returns the function that executes the command if it is valid.
+func parseCommand(line string) (func(), error) {
 	rStart, _ := regexp.Compile(`\Astart\z`)
 	rQuit, _ := regexp.Compile(`\Aquit\z`)
+	rStop, _ := regexp.Compile(`\Astop\z`)
+	rAbort, _ := regexp.Compile(`\Aabort(\s+(?P<reason>.+))?\z`)
 	rPrint, _ := regexp.Compile(`\Aprint\s+(?P<variable>\S+)\z`)
 	rSet, _ := regexp.Compile(`\Aset\s+(?P<variable>\S+)(?P<sep>\s|=)(?P<value>\S+)\z`)
+	rBreak, _ := regexp.Compile(`\Abreak\s+(?P<turn>\S+)\z`)
+	rContinue, _ := regexp.Compile(`\Acontinue\z`)
+	rCheck, _ := regexp.Compile(`\Acheck\z`)
+	rStatus, _ := regexp.Compile(`\Astatus\z`)
+	rStats, _ := regexp.Compile(`\Astats\z`)
+	rSaveConfig, _ := regexp.Compile(`\Asave-config\s+(?P<file>\S+)\z`)
+	rLoadConfig, _ := regexp.Compile(`\Aload-config\s+(?P<file>\S+)\z`)
+	rExtendTurn, _ := regexp.Compile(`\Aextend-turn\s+(?P<ms>\S+)\z`)
+	rBroadcast, _ := regexp.Compile(`\Abroadcast\s+(?P<text>.+)\z`)
 
 	acceptedSetVariables := []string{
 		"nb-turns-max",
@@ -43,20 +90,75 @@ func executor(line string) {
 	acceptedPrintVariables := append(acceptedSetVariables, "all")
 
 	if rStart.MatchString(line) {
-		LockGlobalStateMutex(globalGS, "got start command", "Prompt")
-		if globalGS.GameState == GAME_NOT_RUNNING {
-			if len(globalGS.GameLogic) == 1 {
-				globalGS.GameState = GAME_RUNNING
-				globalGS.GameLogic[0].start <- 1
-			} else {
-				fmt.Printf("Cannot start: Game logic not connected\n")
+		return executeStart, nil
+	} else if rQuit.MatchString(line) {
+		return func() {
+			globalShellExit <- 0
+		}, nil
+	} else if rStop.MatchString(line) {
+		return func() {
+			if err := StopGame(globalGS); err != nil {
+				fmt.Printf("Cannot stop: %v\n", err.Error())
 			}
-		} else {
-			fmt.Printf("Game has already been started\n")
+		}, nil
+	} else if rAbort.MatchString(line) {
+		reason := rAbort.FindStringSubmatch(line)[2]
+		return func() {
+			if err := AbortGame(globalGS, reason); err != nil {
+				fmt.Printf("Cannot abort: %v\n", err.Error())
+			}
+		}, nil
+	} else if rBreak.MatchString(line) {
+		m := rBreak.FindStringSubmatch(line)
+		turn, err := strconv.ParseInt(m[1], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad TURN=%v. %v", m[1], err.Error())
+		} else if turn < 0 || turn > 65535 {
+			return nil, fmt.Errorf("Bad TURN=%v: Not in [0,65535]", turn)
 		}
-		UnlockGlobalStateMutex(globalGS, "got start command", "Prompt")
-	} else if rQuit.MatchString(line) {
-		globalShellExit <- 0
+
+		return func() {
+			executeBreak(int(turn))
+		}, nil
+	} else if rContinue.MatchString(line) {
+		return executeContinue, nil
+	} else if rExtendTurn.MatchString(line) {
+		m := rExtendTurn.FindStringSubmatch(line)
+		milliseconds, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad MS=%v. %v", m[1], err.Error())
+		} else if milliseconds <= 0 || milliseconds > 600000 {
+			return nil, fmt.Errorf("Bad MS=%v: Not in ]0,600000]", milliseconds)
+		}
+
+		return func() {
+			executeExtendTurn(milliseconds)
+		}, nil
+	} else if rCheck.MatchString(line) {
+		return executeCheck, nil
+	} else if rStats.MatchString(line) {
+		return globalStats.print, nil
+	} else if rStatus.MatchString(line) {
+		return func() {
+			DumpStatus(globalGS)
+		}, nil
+	} else if rSaveConfig.MatchString(line) {
+		filename := rSaveConfig.FindStringSubmatch(line)[1]
+		return func() {
+			executeSaveConfig(filename, acceptedSetVariables)
+		}, nil
+	} else if rLoadConfig.MatchString(line) {
+		filename := rLoadConfig.FindStringSubmatch(line)[1]
+		return parseLoadConfig(filename)
+	} else if rBroadcast.MatchString(line) {
+		text := rBroadcast.FindStringSubmatch(line)[1]
+		if err := checkAdminMessageText(text); err != nil {
+			return nil, fmt.Errorf("Bad TEXT. %v", err.Error())
+		}
+		return func() {
+			nbSent := BroadcastAdminMessage(globalGS, text)
+			fmt.Printf("ADMIN_MESSAGE sent to %v clients\n", nbSent)
+		}, nil
 	} else if rPrint.MatchString(line) {
 		m := rPrint.FindStringSubmatch(line)
 		names := rPrint.SubexpNames()
@@ -65,41 +167,15 @@ func executor(line string) {
 			matches[names[index]] = matchedString
 		}
 
-		if stringInSlice(matches["variable"], acceptedPrintVariables) {
-			switch matches["variable"] {
-			case "nb-turns-max":
-				fmt.Printf("%v=%v\n", "nb-turns-max", globalGS.NbTurnsMax)
-			case "nb-players-max":
-				fmt.Printf("%v=%v\n", "nb-players-max",
-					globalGS.NbPlayersMax)
-			case "nb-splayers-max":
-				fmt.Printf("%v=%v\n", "nb-splayers-max",
-					globalGS.NbSpecialPlayersMax)
-			case "nb-visus-max":
-				fmt.Printf("%v=%v\n", "nb-visus-max", globalGS.NbVisusMax)
-			case "delay-first-turn":
-				fmt.Printf("%v=%v\n", "delay-first-turn",
-					globalGS.MillisecondsBeforeFirstTurn)
-			case "delay-turns":
-				fmt.Printf("%v=%v\n", "delay-turns",
-					globalGS.MillisecondsBetweenTurns)
-			case "all":
-				fmt.Printf("%v=%v\n", "nb-turns-max", globalGS.NbTurnsMax)
-				fmt.Printf("%v=%v\n", "nb-players-max",
-					globalGS.NbPlayersMax)
-				fmt.Printf("%v=%v\n", "nb-splayers-max",
-					globalGS.NbSpecialPlayersMax)
-				fmt.Printf("%v=%v\n", "nb-visus-max", globalGS.NbVisusMax)
-				fmt.Printf("%v=%v\n", "delay-first-turn",
-					globalGS.MillisecondsBeforeFirstTurn)
-				fmt.Printf("%v=%v\n", "delay-turns",
-					globalGS.MillisecondsBetweenTurns)
-			}
-		} else {
-			fmt.Printf("Bad VARIABLE=%v. Accepted values: %v\n",
+		if !stringInSlice(matches["variable"], acceptedPrintVariables) {
+			return nil, fmt.Errorf("Bad VARIABLE=%v. Accepted values: %v",
 				matches["variable"],
 				strings.Join(acceptedPrintVariables, " "))
 		}
+
+		return func() {
+			executePrint(matches["variable"], acceptedSetVariables)
+		}, nil
 	} else if rSet.MatchString(line) {
 		m := rSet.FindStringSubmatch(line)
 		names := rSet.SubexpNames()
@@ -108,102 +184,335 @@ func executor(line string) {
 			matches[names[index]] = matchedString
 		}
 
-		if stringInSlice(matches["variable"], acceptedSetVariables) {
-			// Read value
-			intValue, errInt := strconv.ParseInt(matches["value"], 0, 64)
-			floatValue, errFloat := strconv.ParseFloat(matches["value"], 64)
-
-			switch matches["variable"] {
-			case "nb-turns-max":
-				if errInt != nil {
-					fmt.Printf("Bad VALUE=%v. %v\n",
-						matches["value"], errInt.Error())
-				} else {
-					if intValue >= 1 && intValue <= 65535 {
-						globalGS.NbTurnsMax = int(intValue)
-					} else {
-						fmt.Printf("Bad VALUE=%v: Not in [1,65535]\n",
-							intValue)
-					}
-				}
-			case "nb-players-max":
-				if errInt != nil {
-					fmt.Printf("Bad VALUE=%v. %v\n",
-						matches["value"], errInt.Error())
-				} else {
-					if intValue >= 1 && intValue <= 1024 {
-						globalGS.NbPlayersMax = int(intValue)
-					} else {
-						fmt.Printf("Bad VALUE=%v: Not in [1,1024]\n",
-							intValue)
-					}
-				}
-			case "nb-splayers-max":
-				if errInt != nil {
-					fmt.Printf("Bad VALUE=%v. %v\n",
-						matches["value"], errInt.Error())
-				} else {
-					if intValue >= 0 && intValue <= 1024 {
-						globalGS.NbSpecialPlayersMax = int(intValue)
-					} else {
-						fmt.Printf("Bad VALUE=%v: Not in [0,1024]\n",
-							intValue)
-					}
-				}
-			case "nb-visus-max":
-				if errInt != nil {
-					fmt.Printf("Bad VALUE=%v. %v\n",
-						matches["value"], errInt.Error())
-				} else {
-					if intValue >= 0 && intValue <= 1024 {
-						globalGS.NbVisusMax = int(intValue)
-					} else {
-						fmt.Printf("Bad VALUE=%v: Not in [0,1024]\n",
-							intValue)
-					}
-				}
-			case "delay-first-turn":
-				if errFloat != nil {
-					fmt.Printf("Bad VALUE=%v. %v\n",
-						matches["value"], errFloat.Error())
-				} else {
-					if floatValue >= 50 && floatValue <= 10000 {
-						globalGS.MillisecondsBeforeFirstTurn = floatValue
-					} else {
-						fmt.Printf("Bad VALUE=%v: Not in [50,10000]\n",
-							floatValue)
-					}
-				}
-			case "delay-turns":
-				if errFloat != nil {
-					fmt.Printf("Bad VALUE=%v. %v\n",
-						matches["value"], errFloat.Error())
-				} else {
-					if floatValue >= 50 && floatValue <= 10000 {
-						globalGS.MillisecondsBetweenTurns = floatValue
-					} else {
-						fmt.Printf("Bad VALUE=%v: Not in [50,10000]\n",
-							floatValue)
-					}
-				}
-			}
-		} else {
-			fmt.Printf("Bad VARIABLE=%v. Accepted values: %v\n",
+		if !stringInSlice(matches["variable"], acceptedSetVariables) {
+			return nil, fmt.Errorf("Bad VARIABLE=%v. Accepted values: %v",
 				matches["variable"],
 				strings.Join(acceptedSetVariables, " "))
 		}
+
+		return parseSet(matches["variable"], matches["value"])
 	} else {
 		if strings.HasPrefix(line, "start") {
-			fmt.Println("expected syntax: start")
+			return nil, fmt.Errorf("expected syntax: start")
 		} else if strings.HasPrefix(line, "quit") {
-			fmt.Println("expected syntax: quit")
+			return nil, fmt.Errorf("expected syntax: quit")
+		} else if strings.HasPrefix(line, "stop") {
+			return nil, fmt.Errorf("expected syntax: stop")
+		} else if strings.HasPrefix(line, "abort") {
+			return nil, fmt.Errorf("expected syntax: abort [REASON]")
 		} else if strings.HasPrefix(line, "print") {
-			fmt.Println("expected syntax: print VARIABLE")
+			return nil, fmt.Errorf("expected syntax: print VARIABLE")
 		} else if strings.HasPrefix(line, "set") {
-			fmt.Println("expected syntax: set VARIABLE=VALUE\n" +
+			return nil, fmt.Errorf("expected syntax: set VARIABLE=VALUE\n" +
 				"   (alt syntax): set VARIABLE VALUE")
+		} else if strings.HasPrefix(line, "break") {
+			return nil, fmt.Errorf("expected syntax: break TURN")
+		} else if strings.HasPrefix(line, "continue") {
+			return nil, fmt.Errorf("expected syntax: continue")
+		} else if strings.HasPrefix(line, "extend-turn") {
+			return nil, fmt.Errorf("expected syntax: extend-turn MS")
+		} else if strings.HasPrefix(line, "check") {
+			return nil, fmt.Errorf("expected syntax: check")
+		} else if strings.HasPrefix(line, "status") {
+			return nil, fmt.Errorf("expected syntax: status")
+		} else if strings.HasPrefix(line, "stats") {
+			return nil, fmt.Errorf("expected syntax: stats")
+		} else if strings.HasPrefix(line, "save-config") {
+			return nil, fmt.Errorf("expected syntax: save-config FILE")
+		} else if strings.HasPrefix(line, "load-config") {
+			return nil, fmt.Errorf("expected syntax: load-config FILE")
+		} else if strings.HasPrefix(line, "broadcast") {
+			return nil, fmt.Errorf("expected syntax: broadcast TEXT")
+		}
+		return nil, fmt.Errorf("Unknown command '%v'", line)
+	}
+}
+
+// Checks the value of a set command.
+func parseSet(variable, value string) (func(), error) {
+	intValue, errInt := strconv.ParseInt(value, 0, 64)
+	floatValue, errFloat := strconv.ParseFloat(value, 64)
+
+	switch variable {
+	case "nb-turns-max":
+		if errInt != nil {
+			return nil, fmt.Errorf("Bad VALUE=%v. %v", value, errInt.Error())
+		} else if intValue < 1 || intValue > 65535 {
+			return nil, fmt.Errorf("Bad VALUE=%v: Not in [1,65535]", intValue)
+		}
+		return func() {
+			setNbTurnsMax(int(intValue))
+		}, nil
+	case "nb-players-max":
+		if errInt != nil {
+			return nil, fmt.Errorf("Bad VALUE=%v. %v", value, errInt.Error())
+		} else if intValue < 1 || intValue > 1024 {
+			return nil, fmt.Errorf("Bad VALUE=%v: Not in [1,1024]", intValue)
+		}
+		return func() {
+			setNbClientsMax(&globalGS.NbPlayersMax, int(intValue))
+		}, nil
+	case "nb-splayers-max":
+		if errInt != nil {
+			return nil, fmt.Errorf("Bad VALUE=%v. %v", value, errInt.Error())
+		} else if intValue < 0 || intValue > 1024 {
+			return nil, fmt.Errorf("Bad VALUE=%v: Not in [0,1024]", intValue)
+		}
+		return func() {
+			setNbClientsMax(&globalGS.NbSpecialPlayersMax, int(intValue))
+		}, nil
+	case "nb-visus-max":
+		if errInt != nil {
+			return nil, fmt.Errorf("Bad VALUE=%v. %v", value, errInt.Error())
+		} else if intValue < 0 || intValue > 1024 {
+			return nil, fmt.Errorf("Bad VALUE=%v: Not in [0,1024]", intValue)
+		}
+		return func() {
+			setNbClientsMax(&globalGS.NbVisusMax, int(intValue))
+		}, nil
+	case "delay-first-turn":
+		if errFloat != nil {
+			return nil, fmt.Errorf("Bad VALUE=%v. %v", value, errFloat.Error())
+		} else if floatValue < 50 || floatValue > 10000 {
+			return nil, fmt.Errorf("Bad VALUE=%v: Not in [50,10000]", floatValue)
+		}
+		return func() {
+			setDelay(&globalGS.MillisecondsBeforeFirstTurn, variable,
+				floatValue)
+		}, nil
+	case "delay-turns":
+		if errFloat != nil {
+			return nil, fmt.Errorf("Bad VALUE=%v. %v", value, errFloat.Error())
+		} else if floatValue < 50 || floatValue > 10000 {
+			return nil, fmt.Errorf("Bad VALUE=%v: Not in [50,10000]", floatValue)
+		}
+		return func() {
+			setDelay(&globalGS.MillisecondsBetweenTurns, variable, floatValue)
+		}, nil
+	}
+	return nil, fmt.Errorf("Bad VARIABLE=%v", variable)
+}
+
+func executeStart() {
+	LockGlobalStateMutex(globalGS, "got start command", "Prompt")
+	if globalGS.GameState == GAME_NOT_RUNNING {
+		if len(globalGS.GameLogic) == 1 {
+			globalGS.GameState = GAME_RUNNING
+			globalGS.GameLogic[0].start <- 1
+		} else {
+			fmt.Printf("Cannot start: Game logic not connected\n")
+		}
+	} else {
+		fmt.Printf("Game has already been started\n")
+	}
+	UnlockGlobalStateMutex(globalGS, "got start command", "Prompt")
+}
+
+func executeBreak(turn int) {
+	LockGlobalStateMutex(globalGS, "got break command", "Prompt")
+	if globalGS.Breakpoints == nil {
+		globalGS.Breakpoints = make(map[int]bool)
+	}
+	globalGS.Breakpoints[turn] = true
+	UnlockGlobalStateMutex(globalGS, "got break command", "Prompt")
+	fmt.Printf("Breakpoint set at turn %v\n", turn)
+}
+
+// Pushes back the deadline of the current turn once, without changing the
+// delay between turns.
+func executeExtendTurn(milliseconds float64) {
+	LockGlobalStateMutex(globalGS, "got extend-turn command", "Prompt")
+	defer UnlockGlobalStateMutex(globalGS, "got extend-turn command", "Prompt")
+
+	if globalGS.GameState != GAME_RUNNING || len(globalGS.GameLogic) == 0 {
+		fmt.Printf("Cannot extend turn: Game is not running\n")
+		return
+	} else if globalGS.Fast {
+		fmt.Printf("Cannot extend turn: Turns have no deadline with --fast\n")
+		return
+	}
+
+	globalGS.turnExtension += milliseconds
+	select {
+	case globalGS.GameLogic[0].delayChanged <- 1:
+	default:
+	}
+	log.WithFields(log.Fields{
+		"duration (ms)": milliseconds,
+	}).Info("Current turn extended")
+}
+
+// Changes the number of turns. While the game runs, it cannot be set below
+// the turns already sent to clients, and the game logic takes the new value
+// into account after the current turn.
+func setNbTurnsMax(value int) {
+	LockGlobalStateMutex(globalGS, "got set nb turns max command", "Prompt")
+	defer UnlockGlobalStateMutex(globalGS, "got set nb turns max command", "Prompt")
+
+	if globalGS.GameState == GAME_RUNNING {
+		// The game ends at the earliest with the turn that follows the last
+		// TURN sent (in GAME_ENDS)
+		lastTurn := globalStats.lastTurn()
+		if value < lastTurn+2 {
+			fmt.Printf("Cannot set nb-turns-max=%v: Must be at least %v, as "+
+				"turn %v has already been sent\n", value, lastTurn+2, lastTurn)
+			return
+		}
+		log.WithFields(log.Fields{
+			"value": value,
+		}).Info("Number of turns changed during the game")
+	}
+	globalGS.NbTurnsMax = value
+}
+
+// Changes a maximum number of clients. Clients that wait for the game to
+// start are told the new number of free seats.
+func setNbClientsMax(nbClientsMax *int, value int) {
+	LockGlobalStateMutex(globalGS, "got set nb clients max command", "Prompt")
+	*nbClientsMax = value
+	broadcastLobby(globalGS)
+	UnlockGlobalStateMutex(globalGS, "got set nb clients max command", "Prompt")
+}
+
+// Changes a delay. If the game is running, the change is applied to the
+// current turn timer.
+func setDelay(delay *float64, variable string, value float64) {
+	LockGlobalStateMutex(globalGS, "got set delay command", "Prompt")
+	*delay = value
+	if globalGS.GameState == GAME_RUNNING && len(globalGS.GameLogic) > 0 {
+		log.WithFields(log.Fields{
+			"variable": variable,
+			"value":    value,
+		}).Info("Delay changed during the game")
+
+		select {
+		case globalGS.GameLogic[0].delayChanged <- 1:
+		default:
+		}
+	}
+	UnlockGlobalStateMutex(globalGS, "got set delay command", "Prompt")
+}
+
+func executeContinue() {
+	LockGlobalStateMutex(globalGS, "got continue command", "Prompt")
+	if globalGS.Paused {
+		globalGS.Paused = false
+		globalWatchdog.setPaused(false)
+		globalGS.GameLogic[0].resume <- 1
+		fmt.Printf("Game resumed\n")
+	} else {
+		fmt.Printf("Game is not paused\n")
+	}
+	UnlockGlobalStateMutex(globalGS, "got continue command", "Prompt")
+}
+
+func executeCheck() {
+	LockGlobalStateMutex(globalGS, "got check command", "Prompt")
+	gameState := globalGS.GameState
+	UnlockGlobalStateMutex(globalGS, "got check command", "Prompt")
+
+	if gameState != GAME_NOT_RUNNING {
+		fmt.Printf("Cannot check: Game has already been started\n")
+		return
+	}
+
+	PrintCheckResults(checkClients(globalGS))
+}
+
+func executePrint(variable string, allVariables []string) {
+	if variable == "all" {
+		for _, v := range allVariables {
+			executePrint(v, allVariables)
+		}
+		return
+	}
+
+	fmt.Printf("%v=%v\n", variable, variableValue(variable))
+}
+
+// Returns the current value of a variable that can be set from the prompt.
+func variableValue(variable string) interface{} {
+	switch variable {
+	case "nb-turns-max":
+		return globalGS.NbTurnsMax
+	case "nb-players-max":
+		return globalGS.NbPlayersMax
+	case "nb-splayers-max":
+		return globalGS.NbSpecialPlayersMax
+	case "nb-visus-max":
+		return globalGS.NbVisusMax
+	case "delay-first-turn":
+		return globalGS.MillisecondsBeforeFirstTurn
+	case "delay-turns":
+		return globalGS.MillisecondsBetweenTurns
+	}
+	return nil
+}
+
+// Writes the variables that can be set from the prompt into a file,
+// one VARIABLE=VALUE line per variable (as printed by 'print all').
+func executeSaveConfig(filename string, variables []string) {
+	var content strings.Builder
+	LockGlobalStateMutex(globalGS, "got save-config command", "Prompt")
+	for _, variable := range variables {
+		fmt.Fprintf(&content, "%v=%v\n", variable, variableValue(variable))
+	}
+	UnlockGlobalStateMutex(globalGS, "got save-config command", "Prompt")
+
+	err := ioutil.WriteFile(filename, []byte(content.String()), 0644)
+	if err != nil {
+		fmt.Printf("Cannot save configuration: %v\n", err.Error())
+		return
+	}
+	fmt.Printf("Configuration saved to %v\n", filename)
+}
+
+// Reads a file written by save-config. Each line is checked as a set command
+// would be: The configuration is only loaded if all lines are valid.
+// Empty lines and lines starting with '#' are ignored.
+func parseLoadConfig(filename string) (func(), error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load configuration: %v", err.Error())
+	}
+
+	actions := []func(){}
+	for index, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+
+		action, err := parseCommand("set " + line)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot load configuration: %v:%v: %v",
+				filename, index+1, err.Error())
+		}
+		actions = append(actions, action)
 	}
+
+	return func() {
+		for _, action := range actions {
+			action()
+		}
+		fmt.Printf("Configuration loaded from %v\n", filename)
+	}, nil
+}
+
+// Loads a file written by save-config, as load-config does.
+// Used by --config, when netorcai starts and on SIGHUP.
+func LoadConfig(gs *GlobalState, filename string) error {
+	// The prompt may not run yet
+	if globalGS != gs {
+		globalGS = gs
+	}
+
+	load, err := parseLoadConfig(filename)
+	if err != nil {
+		return err
+	}
+	load()
+	return nil
 }
 
 func completer(d prompt.Document) []prompt.Suggest {
@@ -211,6 +520,17 @@ func completer(d prompt.Document) []prompt.Suggest {
 		{Text: "start", Description: "Start the game"},
 		{Text: "print", Description: "Print value of variable"},
 		{Text: "set", Description: "Set value of variable"},
+		{Text: "break", Description: "Pause the game when a turn is reached"},
+		{Text: "continue", Description: "Resume a paused game"},
+		{Text: "extend-turn", Description: "Extend the current turn once (ms)"},
+		{Text: "check", Description: "Check that clients answer a PING"},
+		{Text: "status", Description: "Print netorcai status and goroutines"},
+		{Text: "stats", Description: "Print live turn metrics"},
+		{Text: "save-config", Description: "Save variables into a file"},
+		{Text: "load-config", Description: "Load variables from a file"},
+		{Text: "broadcast", Description: "Send a message to all clients"},
+		{Text: "stop", Description: "End the game after the current turn"},
+		{Text: "abort", Description: "Abort the game, with an optional reason"},
 		{Text: "quit", Description: "Quit netorcai"},
 	}
 
@@ -241,25 +561,40 @@ func completer(d prompt.Document) []prompt.Suggest {
 	}
 }
 
-func RunPrompt(gs *GlobalState, onexit chan int, interactive bool) {
+// Runs the prompt until the standard input is closed or until ctx is
+// cancelled. The interactive prompt is stopped by Cleanup instead.
+func RunPrompt(ctx context.Context, gs *GlobalState, onexit chan int,
+	interactive bool) {
 	globalGS = gs
 	globalShellExit = onexit
 
-	if interactive {
+	if interactive && interactivePromptSupported {
 		interactivePrompt(onexit)
+	} else if interactive {
+		linePrompt(ctx, onexit)
 	} else {
-		nonInteractivePrompt(onexit)
+		nonInteractivePrompt(ctx, onexit)
 	}
 
 }
 
 func interactivePrompt(onexit chan int) {
+	globalHistory = loadHistory()
+
 	LockGlobalStateMutex(globalGS, "Creating prompt", "Prompt")
 	globalGS.prompt = prompt.New(
-		executor,
+		func(line string) {
+			appendHistory(line)
+			executor(line)
+		},
 		completer,
 		prompt.OptionPrefix(">>> "),
 		prompt.OptionTitle(""),
+		prompt.OptionHistory(append([]string(nil), globalHistory...)),
+		prompt.OptionAddKeyBind(prompt.KeyBind{
+			Key: prompt.ControlR,
+			Fn:  reverseSearchHistory,
+		}),
 	)
 	UnlockGlobalStateMutex(globalGS, "Creating prompt", "Prompt")
 
@@ -267,11 +602,64 @@ func interactivePrompt(onexit chan int) {
 	onexit <- 1
 }
 
-func nonInteractivePrompt(onexit chan int) {
-	reader := bufio.NewReader(os.Stdin)
+type promptLine struct {
+	line string
+	err  error
+}
+
+// Reads the standard input lines in another goroutine,
+// so that prompts can stop waiting for a line when their context is cancelled.
+func readPromptLines() chan promptLine {
+	lines := make(chan promptLine)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			lines <- promptLine{line, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// Line-based prompt used on terminals where the interactive prompt is not
+// supported. It has a prefix and a history file, but no completion.
+func linePrompt(ctx context.Context, onexit chan int) {
+	globalHistory = loadHistory()
+	lines := readPromptLines()
+
+	for {
+		fmt.Print(">>> ")
+		select {
+		case <-ctx.Done():
+			return
+		case l := <-lines:
+			appendHistory(l.line)
+			executor(l.line)
+			if l.err != nil {
+				onexit <- 1
+				return
+			}
+		}
+	}
+}
+
+func nonInteractivePrompt(ctx context.Context, onexit chan int) {
+	lines := readPromptLines()
 
 	for {
-		line, _ := reader.ReadString('\n')
-		executor(line)
+		select {
+		case <-ctx.Done():
+			return
+		case l := <-lines:
+			executor(l.line)
+			if l.err != nil {
+				// Standard input has been closed (e.g. netorcai runs as a service).
+				// netorcai keeps running without prompt.
+				return
+			}
+		}
 	}
 }
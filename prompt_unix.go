@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package netorcai
+
+const (
+	// Whether the interactive prompt works correctly on this platform.
+	interactivePromptSupported = true
+)
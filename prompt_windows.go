@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package netorcai
+
+const (
+	// The interactive prompt does not handle most Windows terminals well.
+	// A line-based prompt is used instead.
+	interactivePromptSupported = false
+)
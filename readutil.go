@@ -3,17 +3,101 @@ package netorcai
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
+// Tells why a field of a received message is invalid.
+// Its message is the one logged and sent in KICK messages,
+// while its other fields are sent to the client in an ERROR message.
+type FieldError struct {
+	Path     string      // Path of the field in the message (e.g., game_state.all_clients[3].pos)
+	Expected string      // What the field should be (e.g., string)
+	Received interface{} // Received value, nil if the field is missing
+	missing  bool
+	message  string // Empty for type mismatches, whose message is built from the path
+}
+
+func (e *FieldError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+
+	if e.missing {
+		return fmt.Sprintf("%v: missing, expected %v", e.Path, e.Expected)
+	}
+	return fmt.Sprintf("%v: expected %v, got %v", e.Path, e.Expected,
+		jsonTypeName(e.Received))
+}
+
+func missingField(field, expected string) *FieldError {
+	return &FieldError{Path: field, Expected: expected, missing: true}
+}
+
+func wrongType(path, expected string, received interface{}) *FieldError {
+	return &FieldError{Path: path, Expected: expected, Received: received}
+}
+
+func newFieldError(path, expected string, received interface{},
+	format string, a ...interface{}) *FieldError {
+	return &FieldError{
+		Path:     path,
+		Expected: expected,
+		Received: received,
+		message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// Returns the JSON type of a value decoded by encoding/json.
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// Prepends the path of the parent object or array to the path of a
+// FieldError (e.g., game_state then all_clients[3].pos gives
+// game_state.all_clients[3].pos). Other errors are returned unchanged.
+func InField(parent string, err error) error {
+	fieldErr, isFieldError := err.(*FieldError)
+	if !isFieldError {
+		return err
+	}
+
+	path := parent + "." + fieldErr.Path
+	if strings.HasPrefix(fieldErr.Path, "[") {
+		path = parent + fieldErr.Path
+	}
+
+	return &FieldError{
+		Path:     path,
+		Expected: fieldErr.Expected,
+		Received: fieldErr.Received,
+		missing:  fieldErr.missing,
+		message:  fieldErr.message,
+	}
+}
+
 func ReadString(data map[string]interface{}, field string) (string, error) {
 	value, exists := data[field]
 	if !exists {
-		return "", fmt.Errorf("Field '%v' is missing", field)
+		return "", missingField(field, "string")
 	}
 
 	switch value.(type) {
 	default:
-		return "", fmt.Errorf("Non-string value for field '%v'", field)
+		return "", wrongType(field, "string", value)
 	case string:
 		return value.(string), nil
 	}
@@ -22,28 +106,42 @@ func ReadString(data map[string]interface{}, field string) (string, error) {
 func ReadInt(data map[string]interface{}, field string) (int, error) {
 	value, exists := data[field]
 	if !exists {
-		return 0, fmt.Errorf("Field '%v' is missing", field)
+		return 0, missingField(field, "integer")
 	}
 
 	switch value.(type) {
 	default:
-		return 0, fmt.Errorf("Non-integral value for field '%v'", field)
+		return 0, wrongType(field, "integer", value)
 	case float64:
 		return int(value.(float64)), nil
 	}
 }
 
+func ReadBool(data map[string]interface{}, field string) (bool, error) {
+	value, exists := data[field]
+	if !exists {
+		return false, missingField(field, "bool")
+	}
+
+	switch value.(type) {
+	default:
+		return false, wrongType(field, "bool", value)
+	case bool:
+		return value.(bool), nil
+	}
+}
+
 func ReadObject(data map[string]interface{}, field string) (map[string]interface{}, error) {
 	value, exists := data[field]
 	if !exists {
 		return make(map[string]interface{}),
-			fmt.Errorf("Field '%v' is missing", field)
+			missingField(field, "object")
 	}
 
 	switch value.(type) {
 	default:
 		return make(map[string]interface{}),
-			fmt.Errorf("Non-object value for field '%v'", field)
+			wrongType(field, "object", value)
 	case map[string]interface{}:
 		return value.(map[string]interface{}), nil
 	}
@@ -54,44 +152,80 @@ func ReadArray(data map[string]interface{}, field string) ([]interface{},
 	value, exists := data[field]
 	if !exists {
 		return make([]interface{}, 0),
-			fmt.Errorf("Field '%v' is missing", field)
+			missingField(field, "array")
 	}
 
 	switch value.(type) {
 	default:
 		return make([]interface{}, 0),
-			fmt.Errorf("Non-array value for field '%v'", field)
+			wrongType(field, "array", value)
 	case []interface{}:
 		return value.([]interface{}), nil
 	}
 }
 
+// Reads the object at an index of an array. The path of the error is the
+// index (e.g., [3]), to be prefixed with the path of the array by InField.
+func ReadElementObject(array []interface{}, index int) (map[string]interface{},
+	error) {
+	path := fmt.Sprintf("[%v]", index)
+	if index < 0 || index >= len(array) {
+		return make(map[string]interface{}), missingField(path, "object")
+	}
+
+	switch array[index].(type) {
+	default:
+		return make(map[string]interface{}),
+			wrongType(path, "object", array[index])
+	case map[string]interface{}:
+		return array[index].(map[string]interface{}), nil
+	}
+}
+
+// Reads the string at an index of an array (see ReadElementObject).
+func ReadElementString(array []interface{}, index int) (string, error) {
+	path := fmt.Sprintf("[%v]", index)
+	if index < 0 || index >= len(array) {
+		return "", missingField(path, "string")
+	}
+
+	switch array[index].(type) {
+	default:
+		return "", wrongType(path, "string", array[index])
+	case string:
+		return array[index].(string), nil
+	}
+}
+
 func ReadIntInString(data map[string]interface{}, field string, bitSize,
 	minValue, maxValue int) (int, error) {
 	value, exists := data[field]
 	if !exists {
-		return 0, fmt.Errorf("Field '%v' is missing", field)
+		return 0, missingField(field, "integer in string")
 	}
 
 	switch value.(type) {
 	default:
-		return 0, fmt.Errorf("Non-string value for field '%v'", field)
+		return 0, wrongType(field, "integer in string", value)
 	case string:
 		intValue, err := strconv.ParseInt(value.(string), 0, bitSize)
 		if err != nil {
-			return 0, fmt.Errorf("Field '%v' is invalid: "+
-				"Could not parse integer. Err: %v", field, err)
+			return 0, newFieldError(field, "integer in string", value,
+				"Field '%v' is invalid: "+
+					"Could not parse integer. Err: %v", field, err)
 		}
 
 		if intValue < int64(minValue) {
-			return int(intValue), fmt.Errorf("Field '%v' is invalid: "+
-				"Value is less than minValue=%v",
+			return int(intValue), newFieldError(field, "integer in string", value,
+				"Field '%v' is invalid: "+
+					"Value is less than minValue=%v",
 				field, minValue)
 		}
 
 		if intValue > int64(maxValue) {
-			return int(intValue), fmt.Errorf("Field '%v' is invalid: "+
-				"Value is greater than maxValue=%v",
+			return int(intValue), newFieldError(field, "integer in string", value,
+				"Field '%v' is invalid: "+
+					"Value is greater than maxValue=%v",
 				field, maxValue)
 		}
 
@@ -104,28 +238,31 @@ func ReadFloatInString(data map[string]interface{}, field string, bitSize int,
 	minValue, maxValue float64) (float64, error) {
 	value, exists := data[field]
 	if !exists {
-		return 0, fmt.Errorf("Field '%v' is missing", field)
+		return 0, missingField(field, "float in string")
 	}
 
 	switch value.(type) {
 	default:
-		return 0, fmt.Errorf("Non-string value for field '%v'", field)
+		return 0, wrongType(field, "float in string", value)
 	case string:
 		floatValue, err := strconv.ParseFloat(value.(string), bitSize)
 		if err != nil {
-			return 0, fmt.Errorf("Field '%v' is invalid: "+
-				"Could not parse float. Err: %v", field, err)
+			return 0, newFieldError(field, "float in string", value,
+				"Field '%v' is invalid: "+
+					"Could not parse float. Err: %v", field, err)
 		}
 
 		if floatValue < minValue {
-			return floatValue, fmt.Errorf("Field '%v' is invalid: "+
-				"Value is less than minValue=%v",
+			return floatValue, newFieldError(field, "float in string", value,
+				"Field '%v' is invalid: "+
+					"Value is less than minValue=%v",
 				field, minValue)
 		}
 
 		if floatValue > maxValue {
-			return floatValue, fmt.Errorf("Field '%v' is invalid: "+
-				"Value is greater than maxValue=%v",
+			return floatValue, newFieldError(field, "float in string", value,
+				"Field '%v' is invalid: "+
+					"Value is greater than maxValue=%v",
 				field, maxValue)
 		}
 
@@ -18,6 +18,22 @@ func TestReadIntInString(t *testing.T) {
 	assert.Error(t, err, "No error on non-string value")
 }
 
+func TestReadBool(t *testing.T) {
+	_, err := ReadBool(nil, "meh")
+	assert.Error(t, err, "No error on missing field")
+
+	str := `{"meh":42, "ok":true}`
+	var data map[string]interface{}
+	json.Unmarshal([]byte(str), &data)
+
+	_, err = ReadBool(data, "meh")
+	assert.Error(t, err, "No error on non-bool value")
+
+	value, err := ReadBool(data, "ok")
+	assert.NoError(t, err, "Error on bool value")
+	assert.True(t, value)
+}
+
 func TestReadFloatInString(t *testing.T) {
 	_, err := ReadFloatInString(nil, "meh", 64, 0, 10)
 	assert.Error(t, err, "No error on missing field")
@@ -29,3 +45,96 @@ func TestReadFloatInString(t *testing.T) {
 	_, err = ReadFloatInString(data, "meh", 64, 0, 10)
 	assert.Error(t, err, "No error on non-string value")
 }
+
+func TestFieldError(t *testing.T) {
+	str := `{"message_type":"DO_TURN_ACK", "winner_player_id":-1,
+		"game_state":{"all_clients":[]}}`
+	var data map[string]interface{}
+	json.Unmarshal([]byte(str), &data)
+
+	_, err := readDoTurnAckMessage(data, 2)
+	fieldErr, isFieldError := err.(*FieldError)
+	if assert.True(t, isFieldError, "Not a FieldError: %v", err) {
+		assert.Equal(t, "game_state.all_clients", fieldErr.Path)
+		assert.Equal(t, "object", fieldErr.Expected)
+		assert.Equal(t, []interface{}{}, fieldErr.Received)
+		assert.EqualError(t, err,
+			"game_state.all_clients: expected object, got array")
+	}
+
+	_, err = ReadString(data, "nickname")
+	fieldErr, isFieldError = err.(*FieldError)
+	if assert.True(t, isFieldError, "Not a FieldError: %v", err) {
+		assert.Equal(t, "nickname", fieldErr.Path)
+		assert.Nil(t, fieldErr.Received)
+	}
+}
+
+func TestFieldErrorPath(t *testing.T) {
+	str := `{"all_clients":[{}, {}, {}, {"pos":{"x":"1"}}, 4, null]}`
+	var data map[string]interface{}
+	json.Unmarshal([]byte(str), &data)
+
+	allClients, _ := ReadArray(data, "all_clients")
+	client, err := ReadElementObject(allClients, 3)
+	assert.NoError(t, err, "Cannot read object element")
+	pos, _ := ReadObject(client, "pos")
+	_, err = ReadInt(pos, "x")
+	err = InField("game_state", InField("all_clients", InField("[3]",
+		InField("pos", err))))
+	fieldErr, isFieldError := err.(*FieldError)
+	if assert.True(t, isFieldError, "Not a FieldError: %v", err) {
+		assert.Equal(t, "game_state.all_clients[3].pos.x", fieldErr.Path)
+		assert.EqualError(t, err,
+			"game_state.all_clients[3].pos.x: expected integer, got string")
+	}
+
+	_, err = ReadInt(pos, "y")
+	assert.EqualError(t, InField("pos", err), "pos.y: missing, expected integer")
+
+	_, err = ReadElementObject(allClients, 4)
+	assert.EqualError(t, InField("all_clients", err),
+		"all_clients[4]: expected object, got number")
+	_, err = ReadElementObject(allClients, 5)
+	assert.EqualError(t, err, "[5]: expected object, got null")
+	_, err = ReadElementObject(allClients, 6)
+	assert.EqualError(t, err, "[6]: missing, expected object")
+
+	nicknames := []interface{}{"visu", 1.0}
+	nickname, err := ReadElementString(nicknames, 0)
+	assert.NoError(t, err, "Cannot read string element")
+	assert.Equal(t, "visu", nickname)
+	_, err = ReadElementString(nicknames, 1)
+	assert.EqualError(t, InField("joined", err),
+		"joined[1]: expected string, got number")
+
+	// Errors with a specific message keep it
+	data["message_type"] = "TURN"
+	err = InField("nested", checkMessageType(data, "LOGIN"))
+	assert.EqualError(t, err,
+		"Received 'TURN' message type, while LOGIN was expected")
+	assert.Equal(t, "nested.message_type", err.(*FieldError).Path)
+}
+
+func TestReadRandomDraws(t *testing.T) {
+	str := `{"message_type":"DO_TURN_ACK", "winner_player_id":-1,
+		"game_state":{"all_clients":{}}, "random_draws":[4, 2]}`
+	var data map[string]interface{}
+	json.Unmarshal([]byte(str), &data)
+
+	msg, err := readDoTurnAckMessage(data, 2)
+	assert.NoError(t, err, "Cannot read DO_TURN_ACK")
+	assert.Equal(t, []interface{}{4.0, 2.0}, msg.RandomDraws)
+
+	delete(data, "random_draws")
+	msg, err = readDoTurnAckMessage(data, 2)
+	assert.NoError(t, err, "random_draws should be optional")
+	assert.Nil(t, msg.RandomDraws)
+
+	data["random_draws"] = 42.0
+	_, err = readDoTurnAckMessage(data, 2)
+	fieldErr, isFieldError := err.(*FieldError)
+	if assert.True(t, isFieldError, "Not a FieldError: %v", err) {
+		assert.Equal(t, "random_draws", fieldErr.Path)
+	}
+}
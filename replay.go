@@ -0,0 +1,237 @@
+package netorcai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	replayTimeout = 3 * time.Second
+)
+
+// Checksum of a game state, computed on its canonical JSON serialization
+// (object keys are sorted by encoding/json).
+func stateChecksum(state map[string]interface{}) string {
+	content, _ := json.Marshal(state)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func readJSONFile(filename string, value interface{}) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	err = jsonBackend.Unmarshal(content, value)
+	if err != nil {
+		return fmt.Errorf("Cannot parse %v: %v", filename, err.Error())
+	}
+	return nil
+}
+
+// Reads a game recorded with --dump-states. Turns are sorted by number.
+func readGameRecord(directory string) (gameDump, []turnDump, error) {
+	var game gameDump
+	err := readJSONFile(filepath.Join(directory, "game.json"), &game)
+	if err != nil {
+		return game, nil, err
+	}
+
+	filenames, _ := filepath.Glob(filepath.Join(directory, "turn_*.json"))
+	turns := []turnDump{}
+	for _, filename := range filenames {
+		var turn turnDump
+		err = readJSONFile(filename, &turn)
+		if err != nil {
+			return game, nil, err
+		}
+		turns = append(turns, turn)
+	}
+
+	sort.Slice(turns, func(i, j int) bool {
+		return turns[i].TurnNumber < turns[j].TurnNumber
+	})
+	return game, turns, nil
+}
+
+// Reads the game states of a game recorded with --dump-states:
+// The initial game state, followed by the game state of each turn.
+func ReadRecordedGameStates(directory string) ([]map[string]interface{},
+	error) {
+	game, turns, err := readGameRecord(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []map[string]interface{}{game.InitialGameState}
+	for _, turn := range turns {
+		states = append(states, turn.GameState)
+	}
+	return states, nil
+}
+
+func waitReplayMessage(client *Client, expected string) (
+	map[string]interface{}, error) {
+	select {
+	case msg := <-client.incomingMessages:
+		if msg.err != nil {
+			return nil, fmt.Errorf("Cannot read %v. %v", expected,
+				msg.err.Error())
+		}
+		return msg.content, nil
+	case <-time.After(replayTimeout):
+		return nil, fmt.Errorf("Did not receive %v after %v seconds",
+			expected, replayTimeout.Seconds())
+	}
+}
+
+func printChecksumComparison(what string, recorded,
+	replayed map[string]interface{}) bool {
+	recordedChecksum := stateChecksum(recorded)
+	replayedChecksum := stateChecksum(replayed)
+	if recordedChecksum == replayedChecksum {
+		fmt.Printf("[OK]   %v: %.16v\n", what, recordedChecksum)
+		return true
+	}
+
+	fmt.Printf("[FAIL] %v: recorded %.16v, replayed %.16v\n", what,
+		recordedChecksum, replayedChecksum)
+	return false
+}
+
+// Compares the random draws recorded for a game state with the replayed
+// ones. Nothing is printed if no draws have been recorded.
+func printRandomDrawsComparison(what string, recorded,
+	replayed []interface{}) bool {
+	if recorded == nil {
+		return true
+	}
+	if reflect.DeepEqual(recorded, replayed) {
+		fmt.Printf("[OK]   %v: %v random draws\n", what, len(recorded))
+		return true
+	}
+
+	fmt.Printf("[FAIL] %v: random draws differ\n", what)
+	return false
+}
+
+// Replays a game recorded with --dump-states through a fresh game logic,
+// started with glCommand (and handed the recorded seed, if any), and checks
+// that the game states it computes (and the random draws it reports) match
+// the recorded ones. Returns the number of mismatches.
+func VerifyReplay(directory string, port int, glCommand string) (int, error) {
+	game, turns, err := readGameRecord(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	cmd, err := startShellCommand(glCommand, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot run game logic command: %v", err.Error())
+	}
+	defer stopShellCommand(cmd)
+
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(replayTimeout))
+	conn, err := listener.Accept()
+	if err != nil {
+		return 0, fmt.Errorf("Game logic did not connect: %v", err.Error())
+	}
+	defer conn.Close()
+
+	client := newClient(conn)
+	go readClientMessages(client)
+	glClient := &GameLogicClient{client: client}
+
+	content, err := waitReplayMessage(client, "LOGIN")
+	if err != nil {
+		return 0, err
+	}
+	login, err := readLoginMessage(content)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid LOGIN. %v", err.Error())
+	}
+	if login.role != "game logic" {
+		return 0, fmt.Errorf("Invalid LOGIN: role is '%v' instead of "+
+			"'game logic'", login.role)
+	}
+	client.nickname = login.nickname
+	if err = sendLoginACK(client, negotiateCapabilities(login)); err != nil {
+		return 0, err
+	}
+
+	if err = sendDoInit(glClient, game.NbPlayers, game.NbSpecialPlayers,
+		game.NbTurnsMax, game.Seed); err != nil {
+		return 0, err
+	}
+	content, err = waitReplayMessage(client, "DO_INIT_ACK")
+	if err != nil {
+		return 0, err
+	}
+	doInitAck, err := readDoInitAckMessage(content)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid DO_INIT_ACK. %v", err.Error())
+	}
+
+	nbMismatches := 0
+	if !printChecksumComparison("initial state", game.InitialGameState,
+		doInitAck.InitialGameState) {
+		nbMismatches++
+	}
+	if !printRandomDrawsComparison("initial state", game.InitialRandomDraws,
+		doInitAck.RandomDraws) {
+		nbMismatches++
+	}
+
+	for _, turn := range turns {
+		// The game logic was not invoked on idle turns
+		if turn.Idle {
+			continue
+		}
+
+		// Recorded departures are sent again before the DO_TURN
+		glClient.departures = turn.PlayersLeft
+		glClient.missedDeadlines = turn.MissedDeadlines
+		glClient.nbTurnsMax = turn.NbTurnsMax
+		glClient.lastTurn = turn.LastTurn
+		if err = sendDoTurn(glClient, turn.PlayerActions); err != nil {
+			return nbMismatches, err
+		}
+		content, err = waitReplayMessage(client, "DO_TURN_ACK")
+		if err != nil {
+			return nbMismatches, err
+		}
+		doTurnAck, err := readDoTurnAckMessage(content,
+			game.NbPlayers+game.NbSpecialPlayers)
+		if err != nil {
+			return nbMismatches, fmt.Errorf("Invalid DO_TURN_ACK. %v",
+				err.Error())
+		}
+
+		if !printChecksumComparison(fmt.Sprintf("turn %v", turn.TurnNumber),
+			turn.GameState, doTurnAck.GameState) {
+			nbMismatches++
+		}
+		if !printRandomDrawsComparison(fmt.Sprintf("turn %v", turn.TurnNumber),
+			turn.RandomDraws, doTurnAck.RandomDraws) {
+			nbMismatches++
+		}
+	}
+
+	Kick(client, KICK_GAME_FINISHED, "Replay is finished")
+	return nbMismatches, nil
+}
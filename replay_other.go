@@ -0,0 +1,30 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package netorcai
+
+import (
+	"os"
+	"os/exec"
+)
+
+func startShellCommand(command string, env []string,
+	output *os.File) (*exec.Cmd, error) {
+	cmd := shellCommand(command)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if output == nil {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
+	return cmd, cmd.Start()
+}
+
+func stopShellCommand(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+	cmd.Wait()
+}
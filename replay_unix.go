@@ -0,0 +1,36 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package netorcai
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Runs a shell command in its own process group,
+// so that the processes it spawns can be stopped with it.
+// env (if any) is added to the environment of the command, and its output
+// goes to output (standard output and error if nil).
+func startShellCommand(command string, env []string,
+	output *os.File) (*exec.Cmd, error) {
+	cmd := shellCommand(command)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if output == nil {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd, cmd.Start()
+}
+
+func stopShellCommand(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	cmd.Wait()
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package netorcai
+
+import (
+	"fmt"
+	"net"
+)
+
+func listenReusePort(address string) (net.Listener, error) {
+	return nil, fmt.Errorf("SO_REUSEPORT is not supported on this platform")
+}
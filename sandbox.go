@@ -0,0 +1,29 @@
+package netorcai
+
+import (
+	"fmt"
+	"os"
+)
+
+// netorcai runs itself as the sandbox helper of each sandboxed player
+// command. The command line to run is given in this environment variable,
+// and the connection to the game as file descriptor 3.
+const (
+	sandboxCommandVariable = "NETORCAI_SANDBOX_COMMAND"
+	sandboxConnectionFd    = 3
+)
+
+// Returns whether netorcai has been run as the sandbox helper of a player
+// command (in which case RunSandboxHelper should be called instead of
+// parsing the command-line arguments).
+func IsSandboxHelper() bool {
+	return os.Getenv(sandboxCommandVariable) != ""
+}
+
+// Checks that player commands can be sandboxed on this platform.
+func CheckSandbox(sandbox bool) error {
+	if sandbox && !sandboxSupported {
+		return fmt.Errorf("Sandboxing is not supported on this platform")
+	}
+	return nil
+}
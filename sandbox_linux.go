@@ -0,0 +1,382 @@
+//go:build linux
+// +build linux
+
+package netorcai
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sandboxSupported = true
+
+	// Size of the (only) writable directory of sandboxed commands: /tmp
+	sandboxTmpSize = "64m"
+)
+
+// Mount flags that cannot be changed in a user namespace, and must
+// therefore be kept when remounting
+const lockedMountFlags = unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC |
+	unix.MS_NOATIME | unix.MS_NODIRATIME | unix.MS_RELATIME
+
+// System calls that sandboxed commands cannot use: They give access to other
+// processes or to the kernel, or could undo the sandbox
+var sandboxDeniedSyscalls = []uint32{
+	unix.SYS_ACCT, unix.SYS_ADD_KEY, unix.SYS_BPF, unix.SYS_CHROOT,
+	unix.SYS_DELETE_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_FSCONFIG,
+	unix.SYS_FSMOUNT, unix.SYS_FSOPEN, unix.SYS_FSPICK, unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_LOAD, unix.SYS_KEYCTL, unix.SYS_MOUNT,
+	unix.SYS_MOUNT_SETATTR, unix.SYS_MOVE_MOUNT, unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_OPEN_BY_HANDLE_AT, unix.SYS_OPEN_TREE, unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT, unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV, unix.SYS_PTRACE, unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY, unix.SYS_SETNS, unix.SYS_SWAPOFF, unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2, unix.SYS_UNSHARE, unix.SYS_USERFAULTFD,
+}
+
+// Architectures of the seccomp profile (the system call numbers are the
+// ones of the architecture netorcai is built for)
+var seccompAuditArchs = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+// Runs a player command in a sandbox, with a connection to the game that
+// listens on port. The sandbox helper (netorcai itself, executable) runs
+// in its own user, mount, PID and network namespaces, sets the sandbox up
+// then runs the command.
+func startSandboxedCommand(executable, command string, port int,
+	env []string, output *os.File) (*exec.Cmd, error) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", port))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to the game: %v", err.Error())
+	}
+	defer conn.Close()
+	connFile, err := conn.(*net.TCPConn).File()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to the game: %v", err.Error())
+	}
+	defer connFile.Close()
+
+	cmd := exec.Command(executable)
+	cmd.Env = append(append(os.Environ(), env...),
+		sandboxCommandVariable+"="+command)
+	if output == nil {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
+	cmd.ExtraFiles = []*os.File{connFile}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS |
+			syscall.CLONE_NEWPID | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	return cmd, cmd.Start()
+}
+
+// Sets the sandbox of a player command up, runs the command in it and
+// returns its exit code. In the sandbox:
+//   - The filesystem is read-only, except for a private /tmp.
+//   - Only processes of the sandbox can be seen (and signaled).
+//   - The only network connection is the one to the game, which the command
+//     gets as usual by connecting to localhost on port NETORCAI_PORT.
+//   - A seccomp profile denies the system calls that could undo the sandbox.
+func RunSandboxHelper() int {
+	command := os.Getenv(sandboxCommandVariable)
+	os.Unsetenv(sandboxCommandVariable)
+
+	connFile := os.NewFile(sandboxConnectionFd, "game connection")
+	conn, err := net.FileConn(connFile)
+	connFile.Close()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot get the game connection of the sandbox")
+		return 1
+	}
+	defer conn.Close()
+
+	listener, err := setupSandbox()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot set the sandbox up")
+		return 1
+	}
+	go proxySandboxConnection(listener, conn)
+
+	cmd := shellCommand(command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot run sandboxed command")
+		return 1
+	}
+	status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		// Killed by a signal
+		return 1
+	}
+	return status.ExitStatus()
+}
+
+// Sets the sandbox up, and returns the listener the command can connect to.
+func setupSandbox() (net.Listener, error) {
+	err := setupSandboxFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bringLoopbackUp()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot bring loopback interface up: %v",
+			err.Error())
+	}
+	port, err := strconv.Atoi(os.Getenv("NETORCAI_PORT"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid NETORCAI_PORT")
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		return nil, err
+	}
+
+	err = installSeccompProfile()
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("Cannot install seccomp profile: %v",
+			err.Error())
+	}
+	return listener, nil
+}
+
+// Remounts the whole filesystem read-only, with a private /tmp and a /proc
+// that only shows the processes of the sandbox.
+func setupSandboxFilesystem() error {
+	err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, "")
+	if err != nil {
+		return fmt.Errorf("Cannot make mounts private: %v", err.Error())
+	}
+
+	mountPoints, err := readMountPoints()
+	if err != nil {
+		return err
+	}
+	for _, mountPoint := range mountPoints {
+		var stat unix.Statfs_t
+		err = unix.Statfs(mountPoint, &stat)
+		if err == nil {
+			err = unix.Mount("", mountPoint, "", uintptr(stat.Flags)&
+				lockedMountFlags|unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY,
+				"")
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot remount %v read-only: %v", mountPoint,
+				err.Error())
+		}
+	}
+
+	err = unix.Mount("proc", "/proc", "proc",
+		unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+	if err != nil {
+		return fmt.Errorf("Cannot mount /proc: %v", err.Error())
+	}
+	err = unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV,
+		"mode=1777,size="+sandboxTmpSize)
+	if err != nil {
+		return fmt.Errorf("Cannot mount /tmp: %v", err.Error())
+	}
+	return nil
+}
+
+// Returns the mount points of the mount namespace.
+func readMountPoints() ([]string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read mount points: %v", err.Error())
+	}
+	defer file.Close()
+
+	// Spaces (and a few other characters) are octal-escaped
+	unescaper := strings.NewReplacer(`\040`, " ", `\011`, "\t",
+		`\012`, "\n", `\134`, `\`)
+	mountPoints := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("Invalid mount information: %v",
+				scanner.Text())
+		}
+		mountPoints = append(mountPoints, unescaper.Replace(fields[4]))
+	}
+	return mountPoints, scanner.Err()
+}
+
+// Brings the loopback interface of the network namespace up.
+func bringLoopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	err = unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq)
+	if err != nil {
+		return err
+	}
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq)
+}
+
+// Installs the seccomp profile of the sandbox on all the threads of the
+// process (and therefore on the command it runs).
+func installSeccompProfile() error {
+	filter, err := seccompFilter()
+	if err != nil {
+		return err
+	}
+
+	err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	program := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP,
+		unix.SECCOMP_SET_MODE_FILTER, unix.SECCOMP_FILTER_FLAG_TSYNC,
+		uintptr(unsafe.Pointer(&program)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Returns the BPF program of the seccomp profile.
+// Besides the denied system calls, new namespaces cannot be created and
+// only IP (and netlink) sockets can be created.
+func seccompFilter() ([]unix.SockFilter, error) {
+	auditArch, supported := seccompAuditArchs[runtime.GOARCH]
+	if !supported {
+		return nil, fmt.Errorf("No seccomp profile for %v", runtime.GOARCH)
+	}
+
+	const (
+		// Offsets in struct seccomp_data (the low 32 bits of the first
+		// argument, on little-endian architectures)
+		nrOffset   = 0
+		archOffset = 4
+		arg0Offset = 16
+
+		// Bit of x32 system call numbers on amd64
+		x32SyscallBit = 0x40000000
+
+		namespaceFlags = unix.CLONE_NEWCGROUP | unix.CLONE_NEWIPC |
+			unix.CLONE_NEWNET | unix.CLONE_NEWNS | unix.CLONE_NEWPID |
+			unix.CLONE_NEWUSER | unix.CLONE_NEWUTS
+	)
+	statement := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	load := func(offset uint32) unix.SockFilter {
+		return statement(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offset)
+	}
+	ret := func(action uint32) unix.SockFilter {
+		return statement(unix.BPF_RET|unix.BPF_K, action)
+	}
+	deny := func(errno syscall.Errno) unix.SockFilter {
+		return ret(unix.SECCOMP_RET_ERRNO | uint32(errno))
+	}
+	allow := ret(unix.SECCOMP_RET_ALLOW)
+	const jeq = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+
+	filter := []unix.SockFilter{
+		load(archOffset),
+		jump(jeq, auditArch, 1, 0),
+		ret(unix.SECCOMP_RET_KILL_PROCESS),
+		load(nrOffset),
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, 0, 1),
+		deny(unix.ENOSYS),
+	}
+	for _, nr := range sandboxDeniedSyscalls {
+		filter = append(filter, jump(jeq, nr, 0, 1), deny(unix.EPERM))
+	}
+	filter = append(filter,
+		// clone3 arguments cannot be inspected: Make the C library fall
+		// back to clone
+		jump(jeq, unix.SYS_CLONE3, 0, 1),
+		deny(unix.ENOSYS),
+
+		jump(jeq, unix.SYS_CLONE, 0, 4),
+		load(arg0Offset),
+		jump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, namespaceFlags, 0, 1),
+		deny(unix.EPERM),
+		allow,
+
+		jump(jeq, unix.SYS_SOCKET, 0, 6),
+		load(arg0Offset),
+		jump(jeq, unix.AF_INET, 3, 0),
+		jump(jeq, unix.AF_INET6, 2, 0),
+		jump(jeq, unix.AF_NETLINK, 1, 0),
+		deny(unix.EAFNOSUPPORT),
+		allow,
+
+		allow,
+	)
+	return filter, nil
+}
+
+// Forwards the first connection accepted on listener to the game
+// connection.
+func proxySandboxConnection(listener net.Listener, conn net.Conn) {
+	client, err := listener.Accept()
+	listener.Close()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	done := make(chan int, 2)
+	go func() {
+		io.Copy(conn, client)
+		done <- 1
+	}()
+	go func() {
+		io.Copy(client, conn)
+		done <- 1
+	}()
+	<-done
+	conn.Close()
+}
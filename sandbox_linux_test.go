@@ -0,0 +1,87 @@
+//go:build linux
+// +build linux
+
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The test executable is the sandbox helper of sandboxed test commands
+func TestMain(m *testing.M) {
+	if IsSandboxHelper() {
+		os.Exit(RunSandboxHelper())
+	}
+	os.Exit(m.Run())
+}
+
+func TestReadMountPoints(t *testing.T) {
+	mountPoints, err := readMountPoints()
+	assert.NoError(t, err, "Cannot read mount points")
+	assert.Contains(t, mountPoints, "/")
+}
+
+func TestSeccompFilter(t *testing.T) {
+	filter, err := seccompFilter()
+	if _, supported := seccompAuditArchs[runtime.GOARCH]; !supported {
+		assert.Error(t, err, "Unsupported architectures have no profile")
+		return
+	}
+	assert.NoError(t, err, "Cannot build seccomp profile")
+
+	// Every jump stays in the program, which ends with a return
+	for i, instruction := range filter {
+		if instruction.Code&0x07 == unix.BPF_JMP {
+			assert.True(t, i+1+int(instruction.Jt) < len(filter) &&
+				i+1+int(instruction.Jf) < len(filter),
+				"Jump %v goes out of the program", i)
+		}
+	}
+	assert.Equal(t, uint16(unix.BPF_RET|unix.BPF_K), filter[len(filter)-1].Code)
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ALLOW), filter[len(filter)-1].K)
+}
+
+func TestSandboxedCommand(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if !assert.NoError(t, err, "Cannot listen") {
+		return
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// The command cannot write outside /tmp, and cannot mount anything
+	cmd, err := startSandboxedCommand(os.Args[0],
+		"touch /tmp/file || exit 1; touch /file && exit 2; "+
+			"mount -t tmpfs tmpfs /mnt && exit 3; exit 4",
+		port, []string{"NETORCAI_PORT=" + strconv.Itoa(port)}, nil)
+	if err != nil {
+		t.Skipf("Cannot create namespaces: %v", err.Error())
+	}
+	exited := make(chan error)
+	go func() { exited <- cmd.Wait() }()
+
+	// The game is connected to before running the command
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(time.Second))
+	conn, err := listener.Accept()
+	if assert.NoError(t, err, "The game was not connected to") {
+		conn.Close()
+	}
+
+	select {
+	case <-exited:
+		status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+		assert.Equal(t, 4, status.ExitStatus(),
+			"The sandbox should deny writes and mounts")
+	case <-time.After(5 * time.Second):
+		stopShellCommand(cmd)
+		assert.Fail(t, "The sandboxed command did not exit")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package netorcai
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+const sandboxSupported = false
+
+func startSandboxedCommand(executable, command string, port int,
+	env []string, output *os.File) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("Sandboxing is not supported on this platform")
+}
+
+func RunSandboxHelper() int {
+	fmt.Fprintln(os.Stderr, "Sandboxing is not supported on this platform")
+	return 1
+}
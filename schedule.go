@@ -0,0 +1,426 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status of a scheduled match
+const (
+	MATCH_QUEUED   = "queued"
+	MATCH_RUNNING  = "running"
+	MATCH_FINISHED = "finished"
+	MATCH_FAILED   = "failed"
+)
+
+// A match enqueued through the scheduling API.
+// Arguments are given to the netorcai process that runs the game
+// (e.g. --nb-turns-max=10), and must be among scheduledMatchOptions.
+// The match does not start before StartTime.
+// Players without command are registered bots.
+// If GLChecksum is set, the game logic executable (GLExecutable, or the
+// first word of GLCommand) must have this SHA-256 checksum, or the match
+// fails.
+type ScheduledMatch struct {
+	ID           int                `json:"id"`
+	Players      []TournamentPlayer `json:"players"`
+	GLCommand    string             `json:"gl_command"`
+	GLExecutable string             `json:"gl_executable,omitempty"`
+	GLChecksum   string             `json:"gl_sha256,omitempty"`
+	Arguments    []string           `json:"arguments"`
+	StartTime    time.Time          `json:"start_time"`
+	Status       string             `json:"status"`
+	Winner       string             `json:"winner"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// The netorcai options that scheduled matches may set, as --name or
+// --name=value. Options that run commands or code (e.g. --hook-command or
+// --lua-gl), or that read or write files, are not given to API clients.
+var scheduledMatchOptions = map[string]bool{
+	"--nb-turns-max":            true,
+	"--delay-first-turn":        true,
+	"--delay-turns":             true,
+	"--adaptive-delay":          true,
+	"--delay-turns-min":         true,
+	"--game-starts-ack-timeout": true,
+	"--countdown":               true,
+	"--fast":                    true,
+	"--action-order":            true,
+	"--skip-idle-turns":         true,
+	"--stale-players":           true,
+	"--seed":                    true,
+	"--max-state-bytes":         true,
+	"--state-size-policy":       true,
+	"--max-array-length":        true,
+	"--watchdog":                true,
+	"--watchdog-action":         true,
+}
+
+// Checks that the netorcai arguments of a scheduled match only set
+// scheduledMatchOptions.
+func checkMatchArguments(arguments []string) error {
+	for _, argument := range arguments {
+		name := strings.SplitN(argument, "=", 2)[0]
+		if !scheduledMatchOptions[name] {
+			return fmt.Errorf("Argument %v is not allowed in scheduled "+
+				"matches", argument)
+		}
+	}
+	return nil
+}
+
+// A player bot registered through the scheduling API.
+// netorcai connects to it at Address (host:port) when it plays a match.
+type RegisteredBot struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// The body of a bot registration. With player accounts, the API key must
+// be the one of the account named as the bot.
+type botRegistration struct {
+	RegisteredBot
+	APIKey string `json:"api_key"`
+}
+
+var errBotRegistered = fmt.Errorf("The bot is registered by someone else")
+
+// Runs the enqueued matches one after another.
+type matchScheduler struct {
+	mutex    sync.Mutex
+	matches  []*ScheduledMatch
+	bots     map[string]string
+	accounts *Accounts
+	enqueued chan int
+	settings TournamentSettings
+	runMatch func(match ScheduledMatch) (string, error)
+}
+
+func newMatchScheduler(settings TournamentSettings) *matchScheduler {
+	scheduler := &matchScheduler{
+		matches:  []*ScheduledMatch{},
+		bots:     make(map[string]string),
+		enqueued: make(chan int, 1),
+		settings: settings,
+	}
+	scheduler.runMatch = func(match ScheduledMatch) (string, error) {
+		matchSettings := scheduler.settings
+		matchSettings.GLCommand = match.GLCommand
+		matchSettings.GLExecutable = match.GLExecutable
+		matchSettings.GLChecksum = match.GLChecksum
+		matchSettings.GameArguments = match.Arguments
+		winner, _, err := runTournamentGame(fmt.Sprintf("match%v", match.ID),
+			match.Players, matchSettings, matchSettings.Port)
+		return winner, err
+	}
+	return scheduler
+}
+
+// Registers a bot, or changes the address of a registered bot.
+// Only the owner of a bot can change its address: Without player accounts,
+// bots cannot be registered again. Otherwise, apiKey must be the one of the
+// account named as the bot.
+func (s *matchScheduler) register(bot RegisteredBot, apiKey string) error {
+	err := checkTournamentPlayers([]TournamentPlayer{
+		{Name: bot.Name, Address: bot.Address}}, 1)
+	if err != nil {
+		return err
+	}
+	_, _, err = net.SplitHostPort(bot.Address)
+	if err != nil {
+		return fmt.Errorf("Invalid address of bot %v: %v", bot.Name,
+			err.Error())
+	}
+
+	owner := false
+	if s.accounts != nil {
+		name, exists := s.accounts.lookup(apiKey)
+		if !exists || name != bot.Name {
+			return fmt.Errorf("Invalid API key for bot %v", bot.Name)
+		}
+		owner = true
+	}
+
+	s.mutex.Lock()
+	if _, registered := s.bots[bot.Name]; registered && !owner {
+		s.mutex.Unlock()
+		return errBotRegistered
+	}
+	s.bots[bot.Name] = bot.Address
+	s.mutex.Unlock()
+
+	log.WithFields(log.Fields{
+		"name":    bot.Name,
+		"address": bot.Address,
+	}).Info("Bot registered")
+	return nil
+}
+
+// Returns the registered bots, sorted by name.
+func (s *matchScheduler) registeredBots() []RegisteredBot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bots := []RegisteredBot{}
+	for name, address := range s.bots {
+		bots = append(bots, RegisteredBot{name, address})
+	}
+	sort.Slice(bots, func(i, j int) bool { return bots[i].Name < bots[j].Name })
+	return bots
+}
+
+func (s *matchScheduler) enqueue(match ScheduledMatch) (ScheduledMatch, error) {
+	// Players without command nor address are registered bots
+	match.Players = append([]TournamentPlayer(nil), match.Players...)
+	s.mutex.Lock()
+	for index, player := range match.Players {
+		if strings.TrimSpace(player.Command) == "" && player.Address == "" {
+			match.Players[index].Address = s.bots[player.Name]
+		}
+	}
+	s.mutex.Unlock()
+	for _, player := range match.Players {
+		if strings.TrimSpace(player.Command) == "" && player.Address == "" {
+			return match, fmt.Errorf("Player %v has no command, and is not "+
+				"a registered bot", player.Name)
+		}
+	}
+
+	err := checkTournamentPlayers(match.Players, 1)
+	if err != nil {
+		return match, err
+	}
+	if strings.TrimSpace(match.GLCommand) == "" {
+		return match, fmt.Errorf("Field 'gl_command' is missing")
+	}
+	err = checkMatchArguments(match.Arguments)
+	if err != nil {
+		return match, err
+	}
+	if match.GLChecksum != "" {
+		err = CheckSHA256(match.GLChecksum)
+		if err != nil {
+			return match, err
+		}
+	}
+	if match.StartTime.IsZero() {
+		match.StartTime = time.Now()
+	}
+
+	s.mutex.Lock()
+	match.ID = len(s.matches)
+	match.Status = MATCH_QUEUED
+	match.Winner = ""
+	match.Error = ""
+	s.matches = append(s.matches, &match)
+	s.mutex.Unlock()
+
+	log.WithFields(log.Fields{
+		"match id":   match.ID,
+		"start time": match.StartTime,
+	}).Info("Match enqueued")
+
+	// Wake the scheduler up, unless it has already been woken up
+	select {
+	case s.enqueued <- match.ID:
+	default:
+	}
+	return match, nil
+}
+
+// Returns a copy of the scheduled matches.
+func (s *matchScheduler) list() []ScheduledMatch {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	matches := []ScheduledMatch{}
+	for _, match := range s.matches {
+		matches = append(matches, *match)
+	}
+	return matches
+}
+
+// Returns the queued match that should start first (nil if there is none).
+func (s *matchScheduler) nextMatch() *ScheduledMatch {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	queued := []*ScheduledMatch{}
+	for _, match := range s.matches {
+		if match.Status == MATCH_QUEUED {
+			queued = append(queued, match)
+		}
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(queued, func(i, j int) bool {
+		return queued[i].StartTime.Before(queued[j].StartTime)
+	})
+	return queued[0]
+}
+
+// Runs queued matches forever, one after another.
+func (s *matchScheduler) run() {
+	for {
+		match := s.nextMatch()
+		if match == nil {
+			<-s.enqueued
+			continue
+		}
+
+		// Matches enqueued while waiting may have to start sooner
+		wait := time.Until(match.StartTime)
+		if wait > 0 {
+			select {
+			case <-s.enqueued:
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		s.mutex.Lock()
+		match.Status = MATCH_RUNNING
+		toRun := *match
+		s.mutex.Unlock()
+
+		log.WithFields(log.Fields{
+			"match id": toRun.ID,
+		}).Info("Running match")
+		winner, err := s.runMatch(toRun)
+
+		s.mutex.Lock()
+		match.Winner = winner
+		if err != nil {
+			match.Status = MATCH_FAILED
+			match.Error = err.Error()
+		} else {
+			match.Status = MATCH_FINISHED
+		}
+		s.mutex.Unlock()
+
+		log.WithFields(log.Fields{
+			"match id": toRun.ID,
+			"winner":   winner,
+			"err":      err,
+		}).Info("Match is over")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, map[string]string{"error": err.Error()})
+}
+
+// /matches: Lists the matches (GET) or enqueues a match (POST).
+func handleMatches(s *matchScheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.list())
+		case http.MethodPost:
+			var match ScheduledMatch
+			err := json.NewDecoder(r.Body).Decode(&match)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest,
+					fmt.Errorf("Invalid match: %v", err.Error()))
+				return
+			}
+
+			match, err = s.enqueue(match)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest,
+					fmt.Errorf("Invalid match: %v", err.Error()))
+				return
+			}
+			writeJSON(w, http.StatusCreated, match)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// /bots: Lists the registered bots (GET) or registers a bot (POST).
+func handleBots(s *matchScheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.registeredBots())
+		case http.MethodPost:
+			var registration botRegistration
+			err := json.NewDecoder(r.Body).Decode(&registration)
+			if err == nil {
+				err = s.register(registration.RegisteredBot,
+					registration.APIKey)
+			}
+			if err == errBotRegistered {
+				writeJSONError(w, http.StatusConflict, err)
+				return
+			} else if err != nil {
+				writeJSONError(w, http.StatusBadRequest,
+					fmt.Errorf("Invalid bot: %v", err.Error()))
+				return
+			}
+			writeJSON(w, http.StatusCreated, registration.RegisteredBot)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// /matches/<id>: Reports the status of a match.
+func handleMatch(s *matchScheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/matches/"))
+		matches := s.list()
+		if err != nil || id < 0 || id >= len(matches) {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("No such match"))
+			return
+		}
+		writeJSON(w, http.StatusOK, matches[id])
+	}
+}
+
+// Returns the handler of the match scheduling API. As matches run commands,
+// every request must carry the token (see requireToken).
+func schedulerHandler(s *matchScheduler, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/matches", requireToken(token, handleMatches(s)))
+	mux.HandleFunc("/matches/", requireToken(token, handleMatch(s)))
+	mux.HandleFunc("/bots", requireToken(token, handleBots(s)))
+	return mux
+}
+
+// Serves the match scheduling API on an address and a port, and runs the
+// enqueued matches one after another. Each match is run by a netorcai
+// process, as tournament games are. Bots are registered by the owners of
+// their player account (nil: bots cannot be registered again).
+// Only returns if the API cannot be served.
+func RunScheduler(address string, adminPort int, token string,
+	accounts *Accounts, settings TournamentSettings) error {
+	scheduler := newMatchScheduler(settings)
+	scheduler.accounts = accounts
+	go scheduler.run()
+
+	listenAddress := net.JoinHostPort(address, strconv.Itoa(adminPort))
+	log.WithFields(log.Fields{
+		"listen address": listenAddress,
+	}).Info("Serving match scheduling API")
+	return http.ListenAndServe(listenAddress,
+		schedulerHandler(scheduler, token))
+}
@@ -0,0 +1,255 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postMatch(t *testing.T, server *httptest.Server, body string) (
+	*http.Response, ScheduledMatch) {
+	response, err := http.Post(server.URL+"/matches", "application/json",
+		strings.NewReader(body))
+	assert.NoError(t, err, "Cannot POST match")
+	defer response.Body.Close()
+
+	var match ScheduledMatch
+	json.NewDecoder(response.Body).Decode(&match)
+	return response, match
+}
+
+func getMatch(t *testing.T, server *httptest.Server, id string) (
+	*http.Response, ScheduledMatch) {
+	response, err := http.Get(server.URL + "/matches/" + id)
+	assert.NoError(t, err, "Cannot GET match")
+	defer response.Body.Close()
+
+	var match ScheduledMatch
+	json.NewDecoder(response.Body).Decode(&match)
+	return response, match
+}
+
+func TestSchedulerRunsMatchesByStartTime(t *testing.T) {
+	scheduler := newMatchScheduler(TournamentSettings{})
+	ran := make(chan int, 2)
+	scheduler.runMatch = func(match ScheduledMatch) (string, error) {
+		ran <- match.ID
+		return match.Players[0].Name, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/matches", handleMatches(scheduler))
+	mux.HandleFunc("/matches/", handleMatch(scheduler))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The second match starts first
+	later := time.Now().Add(300 * time.Millisecond).Format(time.RFC3339Nano)
+	sooner := time.Now().Add(100 * time.Millisecond).Format(time.RFC3339Nano)
+	response, match := postMatch(t, server, `{"players":[
+		{"name":"alice","command":"./bot"},{"name":"bob","command":"./bot"}],
+		"gl_command":"./gl","start_time":"`+later+`"}`)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, 0, match.ID)
+	assert.Equal(t, MATCH_QUEUED, match.Status)
+
+	response, match = postMatch(t, server, `{"players":[
+		{"name":"carol","command":"./bot"}],
+		"gl_command":"./gl","arguments":["--nb-turns-max=3"],
+		"start_time":"`+sooner+`"}`)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, 1, match.ID)
+
+	go scheduler.run()
+	for _, expectedID := range []int{1, 0} {
+		select {
+		case id := <-ran:
+			assert.Equal(t, expectedID, id, "Unexpected match order")
+		case <-time.After(time.Second):
+			assert.FailNow(t, "Match did not run")
+		}
+	}
+
+	// Status is updated once the match has run
+	for {
+		response, match = getMatch(t, server, "0")
+		if match.Status != MATCH_RUNNING {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, MATCH_FINISHED, match.Status)
+	assert.Equal(t, "alice", match.Winner)
+
+	response, _ = getMatch(t, server, "2")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestSchedulerRegisteredBots(t *testing.T) {
+	scheduler := newMatchScheduler(TournamentSettings{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/matches", handleMatches(scheduler))
+	mux.HandleFunc("/bots", handleBots(scheduler))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	invalidBodies := []string{
+		`not json`,
+		`{"name":"alice"}`,
+		`{"name":"alice","address":"no-port"}`,
+		`{"name":"alice bob","address":"localhost:5000"}`,
+	}
+	for _, body := range invalidBodies {
+		response, err := http.Post(server.URL+"/bots", "application/json",
+			strings.NewReader(body))
+		if assert.NoError(t, err, "Cannot POST bot") {
+			response.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode,
+				"Invalid bot accepted: %v", body)
+		}
+	}
+
+	response, err := http.Post(server.URL+"/bots", "application/json",
+		strings.NewReader(`{"name":"alice","address":"bots.example:5000"}`))
+	if assert.NoError(t, err, "Cannot POST bot") {
+		response.Body.Close()
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+	}
+	assert.Equal(t, []RegisteredBot{{"alice", "bots.example:5000"}},
+		scheduler.registeredBots())
+
+	// Without accounts, nobody can take the seat of a registered bot over
+	response, err = http.Post(server.URL+"/bots", "application/json",
+		strings.NewReader(`{"name":"alice","address":"evil.example:5000"}`))
+	if assert.NoError(t, err, "Cannot POST bot") {
+		response.Body.Close()
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+	}
+	assert.Equal(t, []RegisteredBot{{"alice", "bots.example:5000"}},
+		scheduler.registeredBots())
+
+	// Registered bots are dialed, the other players run their command
+	response, match := postMatch(t, server, `{"players":[
+		{"name":"alice"},{"name":"bob","command":"./bot"}],
+		"gl_command":"./gl"}`)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, []TournamentPlayer{
+		{Name: "alice", Address: "bots.example:5000"},
+		{Name: "bob", Command: "./bot"},
+	}, match.Players)
+
+	response, _ = postMatch(t, server, `{"players":[{"name":"carol"}],
+		"gl_command":"./gl"}`)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode,
+		"Unregistered bots without command should be rejected")
+}
+
+func TestSchedulerInvalidMatches(t *testing.T) {
+	scheduler := newMatchScheduler(TournamentSettings{})
+	server := httptest.NewServer(handleMatches(scheduler))
+	defer server.Close()
+
+	invalidBodies := []string{
+		`not json`,
+		`{"players":[],"gl_command":"./gl"}`,
+		`{"players":[{"name":"alice","command":"./bot"}]}`,
+		`{"players":[{"name":"alice bob","command":"./bot"}],"gl_command":"./gl"}`,
+		`{"players":[{"name":"alice","command":""}],"gl_command":"./gl"}`,
+		`{"players":[{"name":"alice","command":"./bot"}],"gl_command":"./gl","gl_sha256":"abc"}`,
+		`{"players":[{"name":"alice","command":"./bot"}],"gl_command":"./gl","arguments":["--hook-command=rm -rf /"]}`,
+		`{"players":[{"name":"alice","command":"./bot"}],"gl_command":"./gl","arguments":["--hook-c=./evil"]}`,
+		`{"players":[{"name":"alice","command":"./bot"}],"gl_command":"./gl","arguments":["--nb-turns-max","10"]}`,
+		`{"players":[{"name":"alice","command":"./bot"}],"gl_command":"./gl","arguments":["--dump-states=/etc"]}`,
+	}
+	for _, body := range invalidBodies {
+		response, _ := postMatch(t, server, body)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode,
+			"Invalid match accepted: %v", body)
+	}
+	assert.Equal(t, 0, len(scheduler.list()), "No match should be enqueued")
+}
+
+func TestSchedulerRequiresToken(t *testing.T) {
+	scheduler := newMatchScheduler(TournamentSettings{})
+	server := httptest.NewServer(schedulerHandler(scheduler, "secret"))
+	defer server.Close()
+
+	body := `{"players":[{"name":"alice","command":"./bot"}],"gl_command":"./gl"}`
+	for _, path := range []string{"/matches", "/bots"} {
+		for _, authorization := range []string{"", "Bearer wrong"} {
+			request, _ := http.NewRequest(http.MethodPost, server.URL+path,
+				strings.NewReader(body))
+			if authorization != "" {
+				request.Header.Set("Authorization", authorization)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if assert.NoError(t, err, "Cannot POST %v", path) {
+				response.Body.Close()
+				assert.Equal(t, http.StatusUnauthorized, response.StatusCode,
+					"%v accepted with authorization %q", path, authorization)
+			}
+		}
+	}
+	assert.Equal(t, 0, len(scheduler.list()), "No match should be enqueued")
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/matches",
+		strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := http.DefaultClient.Do(request)
+	if assert.NoError(t, err, "Cannot POST match") {
+		response.Body.Close()
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+	}
+}
+
+func postBot(t *testing.T, server *httptest.Server, body string) int {
+	response, err := http.Post(server.URL+"/bots", "application/json",
+		strings.NewReader(body))
+	if !assert.NoError(t, err, "Cannot POST bot") {
+		return 0
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func TestSchedulerBotAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-scheduler-accounts")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	accounts, err := LoadAccounts(filepath.Join(dir, "accounts.json"))
+	assert.NoError(t, err, "Cannot load accounts")
+	aliceKey, err := accounts.Add("alice")
+	assert.NoError(t, err, "Cannot add account")
+	bobKey, err := accounts.Add("bob")
+	assert.NoError(t, err, "Cannot add account")
+
+	scheduler := newMatchScheduler(TournamentSettings{})
+	scheduler.accounts = accounts
+	server := httptest.NewServer(handleBots(scheduler))
+	defer server.Close()
+
+	assert.Equal(t, http.StatusBadRequest, postBot(t, server,
+		`{"name":"alice","address":"bots.example:5000"}`),
+		"Bot registered without API key")
+	assert.Equal(t, http.StatusBadRequest, postBot(t, server,
+		`{"name":"alice","address":"bots.example:5000","api_key":"`+bobKey+`"}`),
+		"Bot registered with the API key of another account")
+	assert.Equal(t, http.StatusCreated, postBot(t, server,
+		`{"name":"alice","address":"bots.example:5000","api_key":"`+aliceKey+`"}`))
+
+	// Only the owner can change the address of the bot
+	assert.Equal(t, http.StatusBadRequest, postBot(t, server,
+		`{"name":"alice","address":"evil.example:5000","api_key":"`+bobKey+`"}`))
+	assert.Equal(t, http.StatusCreated, postBot(t, server,
+		`{"name":"alice","address":"bots.example:6000","api_key":"`+aliceKey+`"}`))
+	assert.Equal(t, []RegisteredBot{{"alice", "bots.example:6000"}},
+		scheduler.registeredBots())
+}
@@ -0,0 +1,259 @@
+package netorcai
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The messages netorcai reads field by field (see the read*Message
+// functions) have no struct that matches their JSON content.
+// The following structs only describe them, for their JSON Schema.
+// Besides json, the tags pattern, enum and minimum constrain the values.
+
+type loginSchema struct {
+	MessageType         string `json:"message_type"`
+	Nickname            string `json:"nickname" pattern:"^\\S{1,10}$"`
+	Role                string `json:"role" enum:"player,special player,visualization,game logic"`
+	MetaprotocolVersion string `json:"metaprotocol_version" pattern:"^\\d+\\.\\d+\\.\\d+$"`
+	VisuTier            string `json:"visu_tier,omitempty" enum:"live,public"`
+	APIKey              string `json:"api_key,omitempty"`
+	FrameSkipping       bool   `json:"frame_skipping,omitempty"`
+	Framing             int    `json:"framing,omitempty" enum:"1,2"`
+	PlayerLeft          bool   `json:"player_left,omitempty"`
+	VisuChanges         bool   `json:"visu_changes,omitempty"`
+	Lobby               bool   `json:"lobby,omitempty"`
+}
+
+type turnAckSchema struct {
+	MessageType string        `json:"message_type"`
+	TurnNumber  int           `json:"turn_number" minimum:"0"`
+	Actions     []interface{} `json:"actions"`
+}
+
+// GAME_STARTS_ACK has no field
+type gameStartsAckSchema struct {
+	MessageType string `json:"message_type"`
+}
+
+type gameStateSchema struct {
+	AllClients map[string]interface{} `json:"all_clients"`
+}
+
+type doInitAckSchema struct {
+	MessageType      string                 `json:"message_type"`
+	InitialGameState gameStateSchema        `json:"initial_game_state"`
+	RandomDraws      []interface{}          `json:"random_draws,omitempty"`
+	GameStateSchema  map[string]interface{} `json:"game_state_schema,omitempty"`
+}
+
+type doTurnAckSchema struct {
+	MessageType    string                         `json:"message_type"`
+	WinnerPlayerID int                            `json:"winner_player_id" minimum:"-1"`
+	GameState      gameStateSchema                `json:"game_state"`
+	PlayerMessages map[int]map[string]interface{} `json:"player_messages,omitempty"`
+	RandomDraws    []interface{}                  `json:"random_draws,omitempty"`
+	// Values are milliseconds
+	PlayerDeadlines map[int]float64 `json:"player_deadlines,omitempty"`
+}
+
+// PING and PONG have no field, but for time synchronization
+type pingSchema struct {
+	MessageType string `json:"message_type"`
+	ClientTime  int64  `json:"client_time,omitempty" minimum:"0"`
+}
+
+type pongSchema struct {
+	MessageType string `json:"message_type"`
+	ClientTime  int64  `json:"client_time,omitempty" minimum:"0"`
+	ReceiveTime int64  `json:"receive_time,omitempty" minimum:"0"`
+	SendTime    int64  `json:"send_time,omitempty" minimum:"0"`
+}
+
+// The struct that describes each message type
+var messageSchemaStructs = map[string]interface{}{
+	"LOGIN":           loginSchema{},
+	"LOGIN_ACK":       MessageLoginAck{},
+	"KICK":            MessageKick{},
+	"ERROR":           MessageError{},
+	"LOBBY":           MessageLobby{},
+	"ADMIN_MESSAGE":   MessageAdmin{},
+	"COUNTDOWN":       MessageCountdown{},
+	"GAME_STARTS":     MessageGameStarts{},
+	"GAME_STARTS_ACK": gameStartsAckSchema{},
+	"GAME_ENDS":       MessageGameEnds{},
+	"TURN":            MessageTurn{},
+	"TURN_ACK":        turnAckSchema{},
+	"PING":            pingSchema{},
+	"PONG":            pongSchema{},
+	"DO_INIT":         MessageDoInit{},
+	"DO_INIT_ACK":     doInitAckSchema{},
+	"DO_TURN":         MessageDoTurn{},
+	"DO_TURN_ACK":     doTurnAckSchema{},
+	"PLAYER_LEFT":     MessagePlayerLeft{},
+	"VISUS_CHANGED":   MessageVisusChanged{},
+}
+
+// The values of the string types that are enumerations
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(KICK_INVALID_LOGIN): {
+		string(KICK_INVALID_LOGIN), string(KICK_LOGIN_DENIED_FULL),
+		string(KICK_LOGIN_DENIED_AUTH), string(KICK_GAME_STARTED),
+		string(KICK_PROTOCOL_ERROR), string(KICK_NETWORK_ERROR),
+		string(KICK_TIMEOUT), string(KICK_GAME_STATE_TOO_BIG),
+		string(KICK_GAME_FINISHED), string(KICK_REPLACED),
+		string(KICK_SHUTDOWN), string(KICK_ROOM_UNAVAILABLE),
+		string(KICK_ABORTED),
+	},
+}
+
+// Returns the message types, sorted.
+func MessageTypes() []string {
+	messageTypes := []string{}
+	for messageType := range messageSchemaStructs {
+		messageTypes = append(messageTypes, messageType)
+	}
+	sort.Strings(messageTypes)
+	return messageTypes
+}
+
+// Returns the JSON Schema (draft-07) of a message type.
+func MessageSchema(messageType string) (map[string]interface{}, error) {
+	message, exists := messageSchemaStructs[messageType]
+	if !exists {
+		return nil, fmt.Errorf("Unknown message type '%v'. Known ones: %v",
+			messageType, strings.Join(MessageTypes(), " "))
+	}
+
+	schema := typeSchema(reflect.TypeOf(message))
+	schema["title"] = messageType
+	schema["properties"].(map[string]interface{})["message_type"] =
+		map[string]interface{}{"type": "string", "const": messageType}
+	return schema, nil
+}
+
+// Returns the JSON Schema (draft-07) of all the messages of the
+// metaprotocol: Each message type is in definitions.
+func MetaprotocolSchema() map[string]interface{} {
+	definitions := make(map[string]interface{})
+	messages := []interface{}{}
+	for _, messageType := range MessageTypes() {
+		definitions[messageType], _ = MessageSchema(messageType)
+		messages = append(messages, map[string]interface{}{
+			"$ref": "#/definitions/" + messageType,
+		})
+	}
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       fmt.Sprintf("netorcai metaprotocol %v", Version),
+		"definitions": definitions,
+		"oneOf":       messages,
+	}
+}
+
+// Returns the JSON Schema of the values of a Go type, as serialized by
+// encoding/json.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if values, isEnum := schemaEnums[t]; isEnum {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = typeSchema(t.Elem())
+		}
+		if t.Key().Kind() != reflect.String {
+			// Integer keys are serialized as strings
+			schema["propertyNames"] = map[string]interface{}{
+				"pattern": "^-?[0-9]+$",
+			}
+		}
+		return schema
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	// Any value (interface{})
+	return map[string]interface{}{}
+}
+
+// Returns the JSON Schema of a struct: An object whose properties are
+// its exported fields. The fields that are not omitted if empty are required.
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if name == "" {
+			name = field.Name
+		}
+		omitEmpty := false
+		for _, option := range options[1:] {
+			omitEmpty = omitEmpty || option == "omitempty"
+		}
+
+		schema := typeSchema(field.Type)
+		if pattern, exists := field.Tag.Lookup("pattern"); exists {
+			schema["pattern"] = pattern
+		}
+		if enum, exists := field.Tag.Lookup("enum"); exists {
+			schema["enum"] = enumValues(field.Type, enum)
+		}
+		if minimum, exists := field.Tag.Lookup("minimum"); exists {
+			schema["minimum"], _ = strconv.Atoi(minimum)
+		}
+
+		properties[name] = schema
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// Returns the values of an enum tag (comma-separated), typed as the field.
+func enumValues(t reflect.Type, enum string) []interface{} {
+	values := []interface{}{}
+	for _, value := range strings.Split(enum, ",") {
+		if t.Kind() == reflect.String {
+			values = append(values, value)
+		} else {
+			intValue, _ := strconv.Atoi(value)
+			values = append(values, intValue)
+		}
+	}
+	return values
+}
@@ -0,0 +1,73 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Checks that the properties of a message schema are the keys of the
+// message once serialized.
+func checkSchemaProperties(t *testing.T, messageType string,
+	message interface{}) {
+	schema, err := MessageSchema(messageType)
+	if !assert.NoError(t, err, "No schema for %v", messageType) {
+		return
+	}
+	properties := schema["properties"].(map[string]interface{})
+
+	content, _ := json.Marshal(message)
+	var serialized map[string]interface{}
+	json.Unmarshal(content, &serialized)
+	for key := range serialized {
+		assert.Contains(t, properties, key, "%v: '%v' not in schema",
+			messageType, key)
+	}
+	for _, key := range schema["required"].([]string) {
+		assert.Contains(t, serialized, key, "%v: required '%v' not sent",
+			messageType, key)
+	}
+}
+
+func TestMessageSchema(t *testing.T) {
+	seed := int64(1)
+	checkSchemaProperties(t, "DO_INIT", MessageDoInit{MessageType: "DO_INIT",
+		Seed: &seed})
+	checkSchemaProperties(t, "TURN", MessageTurn{MessageType: "TURN",
+		Latencies: map[int]float64{0: 1}, SkippedTurns: 1, Timestamp: 1,
+		PlayerActions: []MessageDoTurnPlayerAction{{}}})
+	checkSchemaProperties(t, "KICK", MessageKick{MessageType: "KICK"})
+
+	schema, err := MessageSchema("TURN")
+	assert.NoError(t, err, "No schema for TURN")
+	properties := schema["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string", "const": "TURN"},
+		properties["message_type"], "Unexpected message_type schema")
+	latencies := properties["latencies"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "number"},
+		latencies["additionalProperties"], "Unexpected latencies schema")
+
+	schema, err = MessageSchema("KICK")
+	assert.NoError(t, err, "No schema for KICK")
+	kickCode := schema["properties"].(map[string]interface{})["kick_code"]
+	assert.Contains(t, kickCode.(map[string]interface{})["enum"],
+		string(KICK_GAME_FINISHED), "Kick codes not enumerated")
+
+	_, err = MessageSchema("UNKNOWN")
+	assert.Error(t, err, "Unknown message type accepted")
+}
+
+func TestMetaprotocolSchema(t *testing.T) {
+	schema := MetaprotocolSchema()
+	definitions := schema["definitions"].(map[string]interface{})
+	for _, messageType := range []string{"LOGIN", "LOGIN_ACK", "GAME_STARTS",
+		"TURN", "TURN_ACK", "DO_INIT", "DO_INIT_ACK", "DO_TURN", "DO_TURN_ACK",
+		"KICK"} {
+		assert.Contains(t, definitions, messageType, "No schema for %v",
+			messageType)
+	}
+	assert.Equal(t, len(definitions), len(schema["oneOf"].([]interface{})))
+
+	_, err := json.Marshal(schema)
+	assert.NoError(t, err, "Cannot serialize schema")
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package netorcai
+
+import (
+	"os/exec"
+)
+
+// Returns a command that runs a command line in the system shell.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,303 @@
+package netorcai
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Algorithms of the keys that sign files
+const (
+	SignatureEd25519 = "ed25519"
+	SignatureHMAC    = "hmac-sha256"
+)
+
+// The signature of a file is written next to it, in a file with this suffix
+const signatureSuffix = ".sig"
+
+// The file of a replay directory that lists (and signs) the others
+const manifestFilename = "manifest.json"
+
+// A key that signs the files written by netorcai (game replays and
+// tournament results), so that their readers can check that they have not
+// been tampered with.
+// Ed25519 signatures can be verified by anyone who has the public key of
+// the server, while HMAC (SHA-256) signatures can only be verified by those
+// who know its secret. A key that only has the public part of an Ed25519
+// key can only verify signatures.
+type SigningKey struct {
+	algorithm  string
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	secret     []byte
+}
+
+// The JSON content of key files (keys are hex-encoded)
+type signingKeyFile struct {
+	Algorithm  string `json:"algorithm"`
+	PrivateKey string `json:"private_key,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
+	Secret     string `json:"secret,omitempty"`
+}
+
+// The JSON content of signature files
+type fileSignature struct {
+	Algorithm string `json:"algorithm"`
+	Signature string `json:"signature"`
+}
+
+// The files of a replay directory, in the order they have been written.
+// Replays are signed through their manifest rather than file by file, so
+// that files cannot be removed or swapped with those of another game
+// without breaking the signature.
+type replayManifest struct {
+	GameID string         `json:"game_id"`
+	Files  []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// Generates a new random key.
+func NewSigningKey(algorithm string) (*SigningKey, error) {
+	key := &SigningKey{algorithm: algorithm}
+	var err error
+	switch algorithm {
+	case SignatureEd25519:
+		key.publicKey, key.privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case SignatureHMAC:
+		key.secret = make([]byte, 32)
+		_, err = rand.Read(key.secret)
+	default:
+		return nil, fmt.Errorf("Unknown signature algorithm '%v'", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot generate key: %v", err)
+	}
+	return key, nil
+}
+
+// Loads a key file.
+func LoadSigningKey(filename string) (*SigningKey, error) {
+	var content signingKeyFile
+	err := readJSONFile(filename, &content)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{algorithm: content.Algorithm}
+	switch content.Algorithm {
+	case SignatureEd25519:
+		publicKey, err := hex.DecodeString(content.PublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Invalid public key in %v", filename)
+		}
+		key.publicKey = publicKey
+		if content.PrivateKey != "" {
+			privateKey, err := hex.DecodeString(content.PrivateKey)
+			if err != nil || len(privateKey) != ed25519.PrivateKeySize {
+				return nil, fmt.Errorf("Invalid private key in %v", filename)
+			}
+			key.privateKey = privateKey
+		}
+	case SignatureHMAC:
+		secret, err := hex.DecodeString(content.Secret)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("Invalid secret in %v", filename)
+		}
+		key.secret = secret
+	default:
+		return nil, fmt.Errorf("Unknown signature algorithm '%v' in %v",
+			content.Algorithm, filename)
+	}
+	return key, nil
+}
+
+// Writes the key into a file that only its owner can read.
+func (key *SigningKey) Save(filename string) error {
+	return key.save(filename, signingKeyFile{
+		Algorithm:  key.algorithm,
+		PrivateKey: hex.EncodeToString(key.privateKey),
+		PublicKey:  hex.EncodeToString(key.publicKey),
+		Secret:     hex.EncodeToString(key.secret),
+	}, 0600)
+}
+
+// Writes the public part of an Ed25519 key into a file, which can be given
+// to anyone who wants to verify signatures.
+func (key *SigningKey) SavePublic(filename string) error {
+	if key.algorithm != SignatureEd25519 {
+		return fmt.Errorf("%v keys have no public part", key.algorithm)
+	}
+	return key.save(filename, signingKeyFile{
+		Algorithm: key.algorithm,
+		PublicKey: hex.EncodeToString(key.publicKey),
+	}, 0644)
+}
+
+func (key *SigningKey) save(filename string, content signingKeyFile,
+	perm os.FileMode) error {
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(filename, data, perm)
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot save key: %v", err)
+	}
+	return nil
+}
+
+// Returns whether the key can sign files (and not only verify them).
+func (key *SigningKey) CanSign() bool {
+	return key.privateKey != nil || key.secret != nil
+}
+
+func (key *SigningKey) sign(content []byte) []byte {
+	if key.algorithm == SignatureEd25519 {
+		return ed25519.Sign(key.privateKey, content)
+	}
+	mac := hmac.New(sha256.New, key.secret)
+	mac.Write(content)
+	return mac.Sum(nil)
+}
+
+func (key *SigningKey) verify(content []byte, signature fileSignature) error {
+	if signature.Algorithm != key.algorithm {
+		return fmt.Errorf("Signed with %v instead of %v", signature.Algorithm,
+			key.algorithm)
+	}
+	signatureBytes, err := hex.DecodeString(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("Invalid signature")
+	}
+
+	var valid bool
+	if key.algorithm == SignatureEd25519 {
+		valid = ed25519.Verify(key.publicKey, content, signatureBytes)
+	} else {
+		valid = hmac.Equal(key.sign(content), signatureBytes)
+	}
+	if !valid {
+		return fmt.Errorf("Signature does not match")
+	}
+	return nil
+}
+
+// Writes a file and, if key is not nil, its signature
+// (in the file of the same name suffixed by .sig).
+func WriteSignedFile(filename string, content []byte, perm os.FileMode,
+	key *SigningKey) error {
+	err := ioutil.WriteFile(filename, content, perm)
+	if err != nil || key == nil {
+		return err
+	}
+
+	signature, _ := json.Marshal(fileSignature{
+		Algorithm: key.algorithm,
+		Signature: hex.EncodeToString(key.sign(content)),
+	})
+	return ioutil.WriteFile(filename+signatureSuffix, signature, perm)
+}
+
+// Checks that a file matches its signature.
+func VerifyFileSignature(filename string, key *SigningKey) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var signature fileSignature
+	err = readJSONFile(filename+signatureSuffix, &signature)
+	if err != nil {
+		return err
+	}
+	return key.verify(content, signature)
+}
+
+// Creates an empty manifest, for a game with a new random identifier.
+func newReplayManifest() *replayManifest {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &replayManifest{
+		GameID: hex.EncodeToString(id),
+		Files:  []manifestFile{},
+	}
+}
+
+// Records a file of the replay. A file written again replaces its entry.
+func (manifest *replayManifest) add(name string, content []byte) {
+	sum := sha256.Sum256(content)
+	file := manifestFile{Name: name, SHA256: hex.EncodeToString(sum[:])}
+	for i := range manifest.Files {
+		if manifest.Files[i].Name == name {
+			manifest.Files[i] = file
+			return
+		}
+	}
+	manifest.Files = append(manifest.Files, file)
+}
+
+// Writes the manifest of a replay directory and its signature.
+func writeSignedManifest(directory string, manifest *replayManifest,
+	key *SigningKey) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteSignedFile(filepath.Join(directory, manifestFilename),
+		content, 0644, key)
+}
+
+// Checks that a replay directory matches its signed manifest: The manifest
+// must match its signature, and the directory must contain exactly the
+// files it lists, unmodified.
+func VerifyDirectorySignature(directory string, key *SigningKey) error {
+	manifestPath := filepath.Join(directory, manifestFilename)
+	err := VerifyFileSignature(manifestPath, key)
+	if err != nil {
+		return err
+	}
+
+	var manifest replayManifest
+	err = readJSONFile(manifestPath, &manifest)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool)
+	for _, file := range manifest.Files {
+		content, err := ioutil.ReadFile(filepath.Join(directory, file.Name))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return fmt.Errorf("%v does not match the manifest", file.Name)
+		}
+		listed[file.Name] = true
+	}
+
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == manifestFilename || name == manifestFilename+signatureSuffix {
+			continue
+		}
+		if !listed[name] {
+			return fmt.Errorf("%v is not listed in the manifest", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignedFiles(t *testing.T) {
+	directory, err := ioutil.TempDir("", "netorcai-signature")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(directory)
+
+	for _, algorithm := range []string{SignatureEd25519, SignatureHMAC} {
+		key, err := NewSigningKey(algorithm)
+		if !assert.NoError(t, err, "Cannot create %v key", algorithm) {
+			continue
+		}
+		keyFilename := filepath.Join(directory, algorithm+".json")
+		assert.NoError(t, key.Save(keyFilename), "Cannot save key")
+		key, err = LoadSigningKey(keyFilename)
+		assert.NoError(t, err, "Cannot load key")
+		assert.True(t, key.CanSign(), "A saved key should sign")
+
+		filename := filepath.Join(directory, algorithm+"-results.json")
+		err = WriteSignedFile(filename, []byte(`{"winner":"a"}`), 0644, key)
+		assert.NoError(t, err, "Cannot write signed file")
+		assert.NoError(t, VerifyFileSignature(filename, key),
+			"%v signature should match", algorithm)
+
+		ioutil.WriteFile(filename, []byte(`{"winner":"b"}`), 0644)
+		assert.EqualError(t, VerifyFileSignature(filename, key),
+			"Signature does not match")
+	}
+}
+
+func TestPublicSigningKey(t *testing.T) {
+	directory, err := ioutil.TempDir("", "netorcai-signature")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(directory)
+
+	key, err := NewSigningKey(SignatureEd25519)
+	assert.NoError(t, err, "Cannot create key")
+	publicFilename := filepath.Join(directory, "public.json")
+	assert.NoError(t, key.SavePublic(publicFilename), "Cannot save public key")
+
+	filename := filepath.Join(directory, "report.json")
+	err = WriteSignedFile(filename, []byte(`{}`), 0644, key)
+	assert.NoError(t, err, "Cannot write signed file")
+
+	// Third parties can verify signatures, but not sign
+	publicKey, err := LoadSigningKey(publicFilename)
+	assert.NoError(t, err, "Cannot load public key")
+	assert.False(t, publicKey.CanSign(), "A public key should not sign")
+	assert.NoError(t, VerifyFileSignature(filename, publicKey),
+		"Signature should match")
+
+	hmacKey, err := NewSigningKey(SignatureHMAC)
+	assert.NoError(t, err, "Cannot create key")
+	assert.Error(t, hmacKey.SavePublic(publicFilename),
+		"HMAC keys have no public part")
+	assert.EqualError(t, VerifyFileSignature(filename, hmacKey),
+		"Signed with ed25519 instead of hmac-sha256")
+
+	_, err = NewSigningKey("rot13")
+	assert.Error(t, err, "Unknown algorithms should be rejected")
+}
+
+func TestSignedReplayManifest(t *testing.T) {
+	directory, err := ioutil.TempDir("", "netorcai-signature")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(directory)
+
+	key, err := NewSigningKey(SignatureEd25519)
+	assert.NoError(t, err, "Cannot create key")
+
+	manifest := newReplayManifest()
+	for _, name := range []string{"game.json", "turn_0000.json", "turn_0001.json"} {
+		content := []byte(`{"file":"` + name + `"}`)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(directory, name),
+			content, 0644), "Cannot write replay file")
+		manifest.add(name, content)
+	}
+	assert.NoError(t, writeSignedManifest(directory, manifest, key),
+		"Cannot write manifest")
+	assert.NoError(t, VerifyDirectorySignature(directory, key),
+		"The directory should match its manifest")
+
+	var written replayManifest
+	assert.NoError(t, readJSONFile(filepath.Join(directory, manifestFilename),
+		&written), "Cannot read manifest")
+	assert.Equal(t, manifest.GameID, written.GameID)
+	assert.Equal(t, "turn_0001.json", written.Files[2].Name,
+		"Files should be listed in the order they were written")
+
+	// Modified, added and removed files are all detected
+	turnFilename := filepath.Join(directory, "turn_0001.json")
+	ioutil.WriteFile(turnFilename, []byte(`{"file":"other"}`), 0644)
+	assert.EqualError(t, VerifyDirectorySignature(directory, key),
+		"turn_0001.json does not match the manifest")
+
+	ioutil.WriteFile(turnFilename, []byte(`{"file":"turn_0001.json"}`), 0644)
+	extraFilename := filepath.Join(directory, "turn_0002.json")
+	ioutil.WriteFile(extraFilename, []byte(`{}`), 0644)
+	assert.EqualError(t, VerifyDirectorySignature(directory, key),
+		"turn_0002.json is not listed in the manifest")
+
+	os.Remove(extraFilename)
+	assert.NoError(t, VerifyDirectorySignature(directory, key),
+		"The directory should match its manifest again")
+	os.Remove(turnFilename)
+	assert.Error(t, VerifyDirectorySignature(directory, key),
+		"Removed files should be detected")
+
+	// The manifest itself cannot be rewritten without the key
+	otherKey, err := NewSigningKey(SignatureEd25519)
+	assert.NoError(t, err, "Cannot create key")
+	assert.NoError(t, writeSignedManifest(directory, newReplayManifest(),
+		otherKey), "Cannot write manifest")
+	assert.EqualError(t, VerifyDirectorySignature(directory, key),
+		"Signature does not match")
+}
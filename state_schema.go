@@ -0,0 +1,275 @@
+package netorcai
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The JSON Schema keywords game logics can use in game_state_schema,
+// and whether they are checked (annotations are accepted but ignored).
+var stateSchemaKeywords = map[string]bool{
+	"$schema":              false,
+	"$id":                  false,
+	"title":                false,
+	"description":          false,
+	"default":              false,
+	"examples":             false,
+	"type":                 true,
+	"enum":                 true,
+	"const":                true,
+	"properties":           true,
+	"required":             true,
+	"additionalProperties": true,
+	"items":                true,
+	"minItems":             true,
+	"maxItems":             true,
+	"minLength":            true,
+	"maxLength":            true,
+	"minimum":              true,
+	"maximum":              true,
+}
+
+var stateSchemaTypes = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"number":  true,
+	"integer": true,
+	"string":  true,
+	"array":   true,
+	"object":  true,
+}
+
+// Returns the keys of an object, sorted so that errors are reproducible.
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the types allowed by the type keyword of a schema
+// (a type name or an array of type names).
+func schemaTypes(value interface{}) ([]string, bool) {
+	names := []interface{}{value}
+	if array, isArray := value.([]interface{}); isArray {
+		names = array
+	}
+
+	types := []string{}
+	for _, name := range names {
+		typeName, isString := name.(string)
+		if !isString || !stateSchemaTypes[typeName] {
+			return nil, false
+		}
+		types = append(types, typeName)
+	}
+	return types, len(types) > 0
+}
+
+// Checks that a game state schema only uses the supported keywords,
+// with valid values. Errors are located in the schema
+// (e.g., game_state_schema.properties.all_clients.type).
+func checkStateSchema(schema map[string]interface{}, path string) error {
+	for _, keyword := range sortedKeys(schema) {
+		value := schema[keyword]
+		keywordPath := path + "." + keyword
+		if _, supported := stateSchemaKeywords[keyword]; !supported {
+			return newFieldError(keywordPath, "supported JSON Schema keyword",
+				value, "%v: Unsupported JSON Schema keyword", keywordPath)
+		}
+
+		switch keyword {
+		case "type":
+			if _, valid := schemaTypes(value); !valid {
+				return wrongType(keywordPath, "JSON type name or array of them",
+					value)
+			}
+		case "enum":
+			if _, isArray := value.([]interface{}); !isArray {
+				return wrongType(keywordPath, "array", value)
+			}
+		case "required":
+			names, isArray := value.([]interface{})
+			if !isArray {
+				return wrongType(keywordPath, "array of strings", value)
+			}
+			for index, name := range names {
+				if _, isString := name.(string); !isString {
+					return wrongType(fmt.Sprintf("%v[%v]", keywordPath, index),
+						"string", name)
+				}
+			}
+		case "properties":
+			properties, isObject := value.(map[string]interface{})
+			if !isObject {
+				return wrongType(keywordPath, "object", value)
+			}
+			for _, name := range sortedKeys(properties) {
+				propertyPath := keywordPath + "." + name
+				property, isObject := properties[name].(map[string]interface{})
+				if !isObject {
+					return wrongType(propertyPath, "object", properties[name])
+				}
+				if err := checkStateSchema(property, propertyPath); err != nil {
+					return err
+				}
+			}
+		case "additionalProperties", "items":
+			if _, isBool := value.(bool); isBool && keyword == "additionalProperties" {
+				continue
+			}
+			subSchema, isObject := value.(map[string]interface{})
+			if !isObject {
+				return wrongType(keywordPath, "object", value)
+			}
+			if err := checkStateSchema(subSchema, keywordPath); err != nil {
+				return err
+			}
+		case "minItems", "maxItems", "minLength", "maxLength", "minimum",
+			"maximum":
+			if _, isNumber := value.(float64); !isNumber {
+				return wrongType(keywordPath, "number", value)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns whether a decoded JSON value has one of the given schema types.
+func hasSchemaType(value interface{}, types []string) bool {
+	valueType := jsonTypeName(value)
+	for _, schemaType := range types {
+		if schemaType == valueType {
+			return true
+		}
+		if number, isNumber := value.(float64); isNumber &&
+			schemaType == "integer" && number == float64(int64(number)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks a value against a schema accepted by checkStateSchema.
+// Errors are located in the value (e.g., game_state.all_clients.units[3].x).
+func validateStateSchema(value interface{}, schema map[string]interface{},
+	path string) error {
+	if schemaType, exists := schema["type"]; exists {
+		types, _ := schemaTypes(schemaType)
+		if !hasSchemaType(value, types) {
+			return wrongType(path, strings.Join(types, " or "), value)
+		}
+	}
+
+	if enum, exists := schema["enum"]; exists {
+		found := false
+		for _, allowed := range enum.([]interface{}) {
+			found = found || reflect.DeepEqual(allowed, value)
+		}
+		if !found {
+			return newFieldError(path, "value of the schema enum", value,
+				"%v: Not one of the values of the schema enum", path)
+		}
+	}
+
+	if constant, exists := schema["const"]; exists &&
+		!reflect.DeepEqual(constant, value) {
+		return newFieldError(path, "schema const", value,
+			"%v: Not equal to the schema const", path)
+	}
+
+	bound := func(keyword string) (float64, bool) {
+		limit, exists := schema[keyword]
+		if !exists {
+			return 0, false
+		}
+		return limit.(float64), true
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if minimum, exists := bound("minimum"); exists && value < minimum {
+			return newFieldError(path, fmt.Sprintf("number >= %v", minimum),
+				value, "%v: %v is lower than the minimum %v", path, value,
+				minimum)
+		}
+		if maximum, exists := bound("maximum"); exists && value > maximum {
+			return newFieldError(path, fmt.Sprintf("number <= %v", maximum),
+				value, "%v: %v is greater than the maximum %v", path, value,
+				maximum)
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if minLength, exists := bound("minLength"); exists && length < minLength {
+			return newFieldError(path,
+				fmt.Sprintf("string of at least %v characters", minLength),
+				value, "%v: Shorter than %v characters", path, minLength)
+		}
+		if maxLength, exists := bound("maxLength"); exists && length > maxLength {
+			return newFieldError(path,
+				fmt.Sprintf("string of at most %v characters", maxLength),
+				value, "%v: Longer than %v characters", path, maxLength)
+		}
+	case []interface{}:
+		length := float64(len(value))
+		if minItems, exists := bound("minItems"); exists && length < minItems {
+			return newFieldError(path,
+				fmt.Sprintf("array of at least %v items", minItems), value,
+				"%v: Fewer than %v items (%v)", path, minItems, len(value))
+		}
+		if maxItems, exists := bound("maxItems"); exists && length > maxItems {
+			return newFieldError(path,
+				fmt.Sprintf("array of at most %v items", maxItems), value,
+				"%v: More than %v items (%v)", path, maxItems, len(value))
+		}
+		if items, exists := schema["items"]; exists {
+			for index, item := range value {
+				err := validateStateSchema(item, items.(map[string]interface{}),
+					fmt.Sprintf("%v[%v]", path, index))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		if required, exists := schema["required"]; exists {
+			for _, name := range required.([]interface{}) {
+				if _, exists := value[name.(string)]; !exists {
+					return missingField(path+"."+name.(string), "value")
+				}
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range sortedKeys(value) {
+			fieldPath := path + "." + name
+			if property, declared := properties[name]; declared {
+				err := validateStateSchema(value[name],
+					property.(map[string]interface{}), fieldPath)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return newFieldError(fieldPath, "no such field", value[name],
+						"%v: Not a property of the schema", fieldPath)
+				}
+			case map[string]interface{}:
+				err := validateStateSchema(value[name], additional, fieldPath)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func decodeObject(t *testing.T, content string) map[string]interface{} {
+	var object map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(content), &object),
+		"Invalid JSON object")
+	return object
+}
+
+const testStateSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["all_clients"],
+  "properties": {
+    "all_clients": {
+      "type": "object",
+      "required": ["units"],
+      "additionalProperties": false,
+      "properties": {
+        "phase": {"enum": ["init", "play"]},
+        "units": {
+          "type": "array",
+          "maxItems": 2,
+          "items": {
+            "type": "object",
+            "properties": {
+              "x": {"type": "integer", "minimum": 0},
+              "name": {"type": ["string", "null"], "minLength": 1}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestCheckStateSchema(t *testing.T) {
+	err := checkStateSchema(decodeObject(t, testStateSchema),
+		"game_state_schema")
+	assert.NoError(t, err, "Valid schema rejected")
+
+	invalid := map[string]string{
+		`{"$ref": "#/definitions/unit"}`: "game_state_schema.$ref: " +
+			"Unsupported JSON Schema keyword",
+		`{"type": "float"}`: "game_state_schema.type: " +
+			"expected JSON type name or array of them, got string",
+		`{"properties": {"x": {"minimum": "0"}}}`: "game_state_schema." +
+			"properties.x.minimum: expected number, got string",
+		`{"required": ["a", 1]}`: "game_state_schema.required[1]: " +
+			"expected string, got number",
+		`{"items": true}`: "game_state_schema.items: " +
+			"expected object, got boolean",
+	}
+	for schema, expected := range invalid {
+		err = checkStateSchema(decodeObject(t, schema), "game_state_schema")
+		if assert.Error(t, err, "Invalid schema accepted: %v", schema) {
+			assert.Equal(t, expected, err.Error())
+		}
+	}
+}
+
+func TestValidateStateSchema(t *testing.T) {
+	schema := decodeObject(t, testStateSchema)
+	state := decodeObject(t, `{"all_clients": {"phase": "play",
+		"units": [{"x": 1, "name": "a"}, {"x": 0, "name": null}]}}`)
+	assert.NoError(t, validateStateSchema(state, schema, "game_state"),
+		"Valid game state rejected")
+
+	invalid := map[string]string{
+		`{}`: "game_state.all_clients: missing, expected value",
+		`{"all_clients": {"units": [{"x": 1.5}]}}`: "game_state.all_clients." +
+			"units[0].x: expected integer, got number",
+		`{"all_clients": {"units": [{"x": -1}]}}`: "game_state.all_clients." +
+			"units[0].x: -1 is lower than the minimum 0",
+		`{"all_clients": {"units": [{"name": ""}]}}`: "game_state." +
+			"all_clients.units[0].name: Shorter than 1 characters",
+		`{"all_clients": {"units": [{}, {}, {}]}}`: "game_state." +
+			"all_clients.units: More than 2 items (3)",
+		`{"all_clients": {"units": [], "phase": "end"}}`: "game_state." +
+			"all_clients.phase: Not one of the values of the schema enum",
+		`{"all_clients": {"units": [], "score": 3}}`: "game_state." +
+			"all_clients.score: Not a property of the schema",
+	}
+	for content, expected := range invalid {
+		err := validateStateSchema(decodeObject(t, content), schema,
+			"game_state")
+		if assert.Error(t, err, "Invalid game state accepted: %v", content) {
+			assert.Equal(t, expected, err.Error())
+		}
+	}
+}
+
+func TestReadDoInitAckGameStateSchema(t *testing.T) {
+	msg, err := readDoInitAckMessage(decodeObject(t, `{
+	  "message_type": "DO_INIT_ACK",
+	  "initial_game_state": {"all_clients": {"units": []}},
+	  "game_state_schema": `+testStateSchema+`}`))
+	assert.NoError(t, err, "Valid DO_INIT_ACK rejected")
+	assert.NotNil(t, msg.GameStateSchema, "Schema not read")
+
+	_, err = readDoInitAckMessage(decodeObject(t, `{
+	  "message_type": "DO_INIT_ACK",
+	  "initial_game_state": {"all_clients": {"units": [{"x": "1"}]}},
+	  "game_state_schema": `+testStateSchema+`}`))
+	if assert.Error(t, err, "Initial game state should be checked") {
+		assert.Equal(t, "initial_game_state.all_clients.units[0].x: "+
+			"expected integer, got string", err.Error())
+	}
+}
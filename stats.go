@@ -0,0 +1,501 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	statsWindowSize     = 100
+	statsTurnRateWindow = time.Minute
+	statsLatencyNbTurns = 5
+	// Minimum duration between two warnings about a slow game logic
+	slowGLWarningInterval = 10 * time.Second
+)
+
+// Cost of a turn, as seen by netorcai.
+type turnRecord struct {
+	TurnNumber     int `json:"turn_number"`
+	StateBytes     int `json:"state_bytes"`
+	NbRecipients   int `json:"nb_recipients"`
+	BroadcastBytes int `json:"broadcast_bytes"`
+
+	stateReceivedAt time.Time
+	lastSentAt      time.Time
+	glComputeTime   time.Duration
+}
+
+type playerLatencies struct {
+	nickname     string
+	latencies    []time.Duration
+	disconnected bool
+	nbAcks       int
+	totalLatency time.Duration
+}
+
+// Rolling metrics about the running game, displayed by the stats command.
+type gameStats struct {
+	mutex sync.Mutex
+
+	turnTimes       []time.Time
+	doTurnSentAt    time.Time
+	glComputeTimes  []time.Duration
+	allComputeTimes []time.Duration
+	turnAcks        map[int]*playerLatencies
+	lastTurnNumber  int
+	turns           map[int]*turnRecord
+
+	// Turns whose GL compute time exceeded the delay between turns
+	nbSlowGLTurns     int
+	lastSlowGLWarning time.Time
+}
+
+var (
+	globalStats = newGameStats()
+)
+
+func newGameStats() *gameStats {
+	return &gameStats{
+		turnAcks:       make(map[int]*playerLatencies),
+		lastTurnNumber: -1,
+		turns:          make(map[int]*turnRecord),
+	}
+}
+
+func appendDuration(durations []time.Duration,
+	d time.Duration) []time.Duration {
+	durations = append(durations, d)
+	if len(durations) > statsWindowSize {
+		durations = durations[len(durations)-statsWindowSize:]
+	}
+	return durations
+}
+
+// Called when a DO_TURN has been sent to the game logic.
+func (s *gameStats) doTurnSent() {
+	s.mutex.Lock()
+	s.doTurnSentAt = time.Now()
+	s.mutex.Unlock()
+}
+
+// Called when a DO_TURN_ACK has been received from the game logic.
+func (s *gameStats) doTurnAckReceived() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if !s.doTurnSentAt.IsZero() {
+		s.glComputeTimes = appendDuration(s.glComputeTimes,
+			now.Sub(s.doTurnSentAt))
+		s.allComputeTimes = append(s.allComputeTimes, now.Sub(s.doTurnSentAt))
+	}
+
+	s.turnTimes = append(s.turnTimes, now)
+	for len(s.turnTimes) > 0 && now.Sub(s.turnTimes[0]) > statsTurnRateWindow {
+		s.turnTimes = s.turnTimes[1:]
+	}
+}
+
+// Checks whether the game logic, rather than the players or the network,
+// is the bottleneck of the game: Its compute time of the last turn is
+// added to the delay between turns, and exceeds it.
+// Slow turns are counted, and a warning is logged (at most once per
+// slowGLWarningInterval) that suggests a longer delay.
+func (s *gameStats) checkGLComputeTime(turnNumber int, msBetweenTurns float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if msBetweenTurns <= 0 || len(s.glComputeTimes) == 0 {
+		return
+	}
+	computeTime := s.glComputeTimes[len(s.glComputeTimes)-1]
+	msComputeTime := computeTime.Seconds() * 1000
+	if msComputeTime <= msBetweenTurns {
+		return
+	}
+
+	s.nbSlowGLTurns++
+	now := time.Now()
+	if now.Sub(s.lastSlowGLWarning) < slowGLWarningInterval {
+		return
+	}
+	s.lastSlowGLWarning = now
+
+	log.WithFields(log.Fields{
+		"turn number":                turnNumber,
+		"gl compute time (ms)":       msComputeTime,
+		"delay turns (ms)":           msBetweenTurns,
+		"slow turns":                 s.nbSlowGLTurns,
+		"suggested delay turns (ms)": math.Ceil(msComputeTime * 1.25),
+	}).Warn("Game logic is the bottleneck (slower than the delay " +
+		"between turns). Consider increasing --delay-turns")
+}
+
+func (s *gameStats) turn(turnNumber int) *turnRecord {
+	record, exists := s.turns[turnNumber]
+	if !exists {
+		record = &turnRecord{TurnNumber: turnNumber}
+		s.turns[turnNumber] = record
+	}
+	return record
+}
+
+// Called when the game state of a turn has been received.
+func (s *gameStats) stateReceived(turnNumber, stateBytes int) {
+	s.mutex.Lock()
+	record := s.turn(turnNumber)
+	record.StateBytes = stateBytes
+	record.stateReceivedAt = time.Now()
+	if !s.doTurnSentAt.IsZero() {
+		record.glComputeTime = record.stateReceivedAt.Sub(s.doTurnSentAt)
+	}
+	s.mutex.Unlock()
+}
+
+// Called when a TURN has been sent to a player or visualization.
+func (s *gameStats) turnSent(turnNumber, nbBytes int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record := s.turn(turnNumber)
+	record.NbRecipients++
+	record.BroadcastBytes += nbBytes
+	record.lastSentAt = time.Now()
+	if turnNumber > s.lastTurnNumber {
+		s.lastTurnNumber = turnNumber
+	}
+}
+
+// Returns the number of the last turn sent to clients (-1 if none).
+func (s *gameStats) lastTurn() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastTurnNumber
+}
+
+// Returns the records of all turns, sorted by turn number.
+func (s *gameStats) turnRecords() []turnRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	records := []turnRecord{}
+	for _, record := range s.turns {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].TurnNumber < records[j].TurnNumber
+	})
+	return records
+}
+
+// Called when a TURN_ACK has been received from a player.
+func (s *gameStats) turnAckReceived(playerID int, nickname string,
+	latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	player, exists := s.turnAcks[playerID]
+	if !exists {
+		player = &playerLatencies{nickname: nickname}
+		s.turnAcks[playerID] = player
+	}
+	player.latencies = appendDuration(player.latencies, latency)
+	player.nbAcks++
+	player.totalLatency += latency
+}
+
+// Called when a player has been kicked.
+func (s *gameStats) playerDisconnected(playerID int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if player, exists := s.turnAcks[playerID]; exists {
+		player.disconnected = true
+	}
+}
+
+// Returns the mean TURN_ACK latency (over its last turns) of the slowest
+// connected player whose mean latency does not exceed maxLatency.
+// Slower players are considered unhealthy and ignored.
+// Returns false if no latency is known.
+func (s *gameStats) slowestHealthyLatency(nbTurns int,
+	maxLatency time.Duration) (time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	slowest := time.Duration(0)
+	found := false
+	for _, player := range s.turnAcks {
+		if player.disconnected {
+			continue
+		}
+
+		latencies := player.latencies
+		if len(latencies) > nbTurns {
+			latencies = latencies[len(latencies)-nbTurns:]
+		}
+
+		total := time.Duration(0)
+		for _, latency := range latencies {
+			total += latency
+		}
+		mean := total / time.Duration(len(latencies))
+
+		if mean <= maxLatency && mean >= slowest {
+			slowest = mean
+			found = true
+		}
+	}
+	return slowest, found
+}
+
+// Returns the mean TURN_ACK latency (over its last turns, in milliseconds)
+// of each connected player, indexed by player ID.
+func (s *gameStats) recentLatencies(nbTurns int) map[int]float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	latencies := make(map[int]float64)
+	for playerID, player := range s.turnAcks {
+		if player.disconnected {
+			continue
+		}
+
+		recent := player.latencies
+		if len(recent) > nbTurns {
+			recent = recent[len(recent)-nbTurns:]
+		}
+		latencies[playerID] = meanMilliseconds(recent)
+	}
+	return latencies
+}
+
+func meanMilliseconds(durations []time.Duration) float64 {
+	total := time.Duration(0)
+	for _, d := range durations {
+		total += d
+	}
+	return total.Seconds() * 1000 / float64(len(durations))
+}
+
+// Nearest-rank percentile of sorted durations, in milliseconds.
+func percentileMilliseconds(sorted []time.Duration, percentile int) float64 {
+	rank := (percentile*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Seconds() * 1000
+}
+
+func (s *gameStats) print() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.glComputeTimes) == 0 {
+		fmt.Println("No turn has been computed yet")
+		return
+	}
+
+	window := statsTurnRateWindow
+	if sinceFirst := time.Since(s.turnTimes[0]); sinceFirst < window {
+		window = sinceFirst
+	}
+	if window > 0 {
+		fmt.Printf("turns/minute: %.1f\n",
+			float64(len(s.turnTimes))/window.Minutes())
+	}
+
+	sorted := append([]time.Duration(nil), s.glComputeTimes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	fmt.Printf("GL compute time (last %v turns): mean=%.3f ms "+
+		"p50=%.3f ms p90=%.3f ms p99=%.3f ms\n", len(sorted),
+		meanMilliseconds(sorted), percentileMilliseconds(sorted, 50),
+		percentileMilliseconds(sorted, 90), percentileMilliseconds(sorted, 99))
+	if s.nbSlowGLTurns > 0 {
+		fmt.Printf("turns slower than the delay because of the GL: %v\n",
+			s.nbSlowGLTurns)
+	}
+
+	playerIDs := []int{}
+	for playerID := range s.turnAcks {
+		playerIDs = append(playerIDs, playerID)
+	}
+	sort.Ints(playerIDs)
+	for _, playerID := range playerIDs {
+		player := s.turnAcks[playerID]
+		fmt.Printf("  player %v (%v): mean TURN_ACK latency=%.3f ms\n",
+			playerID, player.nickname, meanMilliseconds(player.latencies))
+	}
+
+	if s.lastTurnNumber >= 0 {
+		record := s.turns[s.lastTurnNumber]
+		fmt.Printf("last turn (%v): state=%v bytes, recipients=%v, "+
+			"bytes broadcast=%v\n", record.TurnNumber, record.StateBytes,
+			record.NbRecipients, record.BroadcastBytes)
+	}
+}
+
+// Writes per-turn metrics in the Prometheus text exposition format.
+func (s *gameStats) writeMetrics(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	totalBroadcastBytes := 0
+	for _, record := range s.turns {
+		totalBroadcastBytes += record.BroadcastBytes
+	}
+
+	last := turnRecord{TurnNumber: s.lastTurnNumber}
+	if record, exists := s.turns[s.lastTurnNumber]; exists {
+		last = *record
+	}
+
+	metrics := []struct {
+		name, kind, help string
+		value            int
+	}{
+		{"netorcai_turns_total", "counter",
+			"Number of turns computed by the game logic.", len(s.turns)},
+		{"netorcai_broadcast_bytes_total", "counter",
+			"Number of bytes sent in TURN messages.", totalBroadcastBytes},
+		{"netorcai_last_turn_number", "gauge",
+			"Number of the last turn sent to clients.", last.TurnNumber},
+		{"netorcai_last_turn_state_bytes", "gauge",
+			"Serialized game state size of the last turn.", last.StateBytes},
+		{"netorcai_last_turn_recipients", "gauge",
+			"Number of clients that received the last turn.", last.NbRecipients},
+		{"netorcai_last_turn_broadcast_bytes", "gauge",
+			"Number of bytes sent in TURN messages for the last turn.",
+			last.BroadcastBytes},
+		{"netorcai_slow_gl_turns_total", "counter",
+			"Number of turns whose GL compute time exceeded the delay between turns.",
+			s.nbSlowGLTurns},
+	}
+
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n%v %v\n", metric.name,
+			metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+}
+
+// Returns the p50, p95 and p99 (in milliseconds) of the GL compute times
+// and of the broadcast completion times (from the reception of a game state
+// to the last TURN sent) of all the turns of the game.
+func (s *gameStats) turnDurationPercentiles() log.Fields {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	broadcastTimes := []time.Duration{}
+	for _, record := range s.turns {
+		if !record.stateReceivedAt.IsZero() && !record.lastSentAt.IsZero() {
+			broadcastTimes = append(broadcastTimes,
+				record.lastSentAt.Sub(record.stateReceivedAt))
+		}
+	}
+
+	fields := log.Fields{}
+	for name, durations := range map[string][]time.Duration{
+		"gl compute":         s.allComputeTimes,
+		"broadcast complete": broadcastTimes,
+	} {
+		if len(durations) == 0 {
+			continue
+		}
+
+		sorted := append([]time.Duration(nil), durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for _, percentile := range []int{50, 95, 99} {
+			fields[fmt.Sprintf("%v p%v (ms)", name, percentile)] =
+				percentileMilliseconds(sorted, percentile)
+		}
+	}
+	return fields
+}
+
+// Prints the cost of each turn of the game: The time taken by the game
+// logic to compute it, the size of its game state, and the bytes and time
+// (from the reception of the game state to the last TURN sent) taken to
+// broadcast it.
+func PrintTurnCosts() {
+	fmt.Println("turn  gl compute (ms)  state bytes  recipients  " +
+		"broadcast bytes  broadcast (ms)")
+	for _, record := range globalStats.turnRecords() {
+		broadcastTime := time.Duration(0)
+		if !record.stateReceivedAt.IsZero() && !record.lastSentAt.IsZero() {
+			broadcastTime = record.lastSentAt.Sub(record.stateReceivedAt)
+		}
+		fmt.Printf("%4v  %15.3f  %11v  %10v  %15v  %14.3f\n",
+			record.TurnNumber, record.glComputeTime.Seconds()*1000,
+			record.StateBytes, record.NbRecipients, record.BroadcastBytes,
+			broadcastTime.Seconds()*1000)
+	}
+}
+
+// Summarizes the cost of the game turns at the end of the game.
+// Per-turn records are written in the dump directory, if any.
+// The manifest of the dump directory is written last, as the game is over.
+func reportGame(debug debugOptions) {
+	defer dumpManifest(debug)
+
+	records := globalStats.turnRecords()
+	if len(records) == 0 {
+		return
+	}
+
+	maxStateBytes, totalStateBytes, totalBroadcastBytes := 0, 0, 0
+	for _, record := range records {
+		if record.StateBytes > maxStateBytes {
+			maxStateBytes = record.StateBytes
+		}
+		totalStateBytes += record.StateBytes
+		totalBroadcastBytes += record.BroadcastBytes
+	}
+
+	log.WithFields(log.Fields{
+		"turns":                 len(records),
+		"max state bytes":       maxStateBytes,
+		"mean state bytes":      totalStateBytes / len(records),
+		"total broadcast bytes": totalBroadcastBytes,
+	}).Info("Game report")
+	log.WithFields(globalStats.turnDurationPercentiles()).Info("Turn durations")
+
+	globalStats.mutex.Lock()
+	for _, player := range globalStats.turnAcks {
+		meanLatency := player.totalLatency.Seconds() * 1000 /
+			float64(player.nbAcks)
+		log.WithFields(log.Fields{
+			"nickname":                   player.nickname,
+			"turn acks":                  player.nbAcks,
+			"mean turn ack latency (ms)": meanLatency,
+		}).Info("Player report")
+	}
+	globalStats.mutex.Unlock()
+
+	if debug.reportEncoding {
+		globalEncodingReport.logSummary()
+	}
+
+	if debug.dumpStatesDirectory == "" {
+		return
+	}
+
+	filename := filepath.Join(debug.dumpStatesDirectory, "report.json")
+	content, err := json.MarshalIndent(records, "", "  ")
+	if err == nil {
+		err = writeDumpFile(debug, filename, content)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":      err,
+			"filename": filename,
+		}).Warn("Cannot write game report")
+	}
+}
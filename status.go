@@ -0,0 +1,34 @@
+package netorcai
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Prints the current state of netorcai (game, clients, variables) and the
+// stack of all goroutines. Meant to debug stuck instances.
+func DumpStatus(gs *GlobalState) {
+	LockGlobalStateMutex(gs, "Dump status", "Status")
+	fmt.Printf("game state: %v\n", gameStateString(gs.GameState))
+	fmt.Printf("paused: %v\n", gs.Paused)
+	fmt.Printf("game logic: %v/1\n", len(gs.GameLogic))
+	fmt.Printf("players: %v/%v\n", len(gs.Players), gs.NbPlayersMax)
+	fmt.Printf("special players: %v/%v\n", len(gs.SpecialPlayers),
+		gs.NbSpecialPlayersMax)
+	fmt.Printf("visualizations: %v/%v\n", len(gs.Visus), gs.NbVisusMax)
+	fmt.Printf("nb-turns-max=%v\n", gs.NbTurnsMax)
+	fmt.Printf("delay-first-turn=%v\n", gs.MillisecondsBeforeFirstTurn)
+	fmt.Printf("delay-turns=%v\n", gs.MillisecondsBetweenTurns)
+	UnlockGlobalStateMutex(gs, "Dump status", "Status")
+
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	fmt.Printf("goroutines:\n%s\n", buf)
+}
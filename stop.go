@@ -0,0 +1,51 @@
+package netorcai
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Stops the running game cleanly: The next DO_TURN is the last one (flagged
+// with last_turn), and clients receive GAME_ENDS once the game logic has
+// computed it.
+// Returns an error if the game is not running or is already being stopped.
+func StopGame(gs *GlobalState) error {
+	LockGlobalStateMutex(gs, "Stop game", "Admin")
+	defer UnlockGlobalStateMutex(gs, "Stop game", "Admin")
+
+	if gs.GameState != GAME_RUNNING || len(gs.GameLogic) == 0 {
+		return fmt.Errorf("Game is not running")
+	}
+
+	select {
+	case gs.GameLogic[0].stop <- 1:
+	default:
+		return fmt.Errorf("Game is already being stopped")
+	}
+
+	log.WithFields(log.Fields{
+		"paused": gs.Paused,
+	}).Warn("Game stop requested. The next turn is the last one")
+	return nil
+}
+
+// Stops the running game on POST requests (see StopGame).
+func handleStop(gs *GlobalState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := StopGame(gs); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"stopping": true})
+	}
+}
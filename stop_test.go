@@ -0,0 +1,20 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStopGame(t *testing.T) {
+	glClient := &GameLogicClient{stop: make(chan int, 1)}
+	gs := &GlobalState{
+		GameState: GAME_NOT_RUNNING,
+		GameLogic: []*GameLogicClient{glClient},
+	}
+	assert.Error(t, StopGame(gs), "Game stopped before it starts")
+
+	gs.GameState = GAME_RUNNING
+	assert.NoError(t, StopGame(gs))
+	assert.Len(t, glClient.stop, 1, "Game logic not told to stop")
+	assert.Error(t, StopGame(gs), "Game stopped twice")
+}
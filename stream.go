@@ -0,0 +1,229 @@
+package netorcai
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of messages buffered for a stream subscriber.
+	// Slower subscribers are dropped.
+	turnStreamBufferSize = 64
+)
+
+// A message streamed over HTTP, already serialized.
+type streamedMessage struct {
+	messageType string
+	content     []byte
+}
+
+func serializeStreamedMessage(messageType string,
+	message interface{}) (streamedMessage, bool) {
+	content, err := jsonBackend.Marshal(message)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot serialize streamed message")
+		return streamedMessage{}, false
+	}
+	return streamedMessage{messageType, content}, true
+}
+
+// Fans out the messages that public visualizations receive (TURN and
+// GAME_ENDS) to HTTP spectators.
+// Messages are only serialized if there are subscribers.
+type turnStream struct {
+	mutex       sync.Mutex
+	subscribers map[chan streamedMessage]bool
+	lastType    string
+	last        interface{}
+	ended       bool
+	handlers    sync.WaitGroup
+}
+
+var (
+	globalTurnStream = newTurnStream()
+)
+
+func newTurnStream() *turnStream {
+	return &turnStream{
+		subscribers: make(map[chan streamedMessage]bool),
+	}
+}
+
+// Sends a message to all subscribers. The GAME_ENDS message ends the
+// stream.
+func (s *turnStream) publish(messageType string, message interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ended {
+		return
+	}
+	s.lastType, s.last = messageType, message
+	if serialized, ok := s.serializeIfSubscribers(); ok {
+		for subscriber := range s.subscribers {
+			select {
+			case subscriber <- serialized:
+			default:
+				log.Warn("Dropping slow turn stream subscriber")
+				delete(s.subscribers, subscriber)
+				close(subscriber)
+			}
+		}
+	}
+
+	if messageType == "GAME_ENDS" {
+		s.end()
+	}
+}
+
+// Sends a message to the current subscribers only: Unlike published
+// messages, it is not sent to later subscribers.
+func (s *turnStream) announce(messageType string, message interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ended || len(s.subscribers) == 0 {
+		return
+	}
+	serialized, ok := serializeStreamedMessage(messageType, message)
+	if !ok {
+		return
+	}
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- serialized:
+		default:
+			log.Warn("Dropping slow turn stream subscriber")
+			delete(s.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// Serializes the last message, unless nobody subscribed to the stream.
+// Must be called with the mutex held.
+func (s *turnStream) serializeIfSubscribers() (streamedMessage, bool) {
+	if len(s.subscribers) == 0 {
+		return streamedMessage{}, false
+	}
+	return serializeStreamedMessage(s.lastType, s.last)
+}
+
+// Closes the stream of all subscribers. Must be called with the mutex held.
+func (s *turnStream) end() {
+	s.ended = true
+	for subscriber := range s.subscribers {
+		delete(s.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// Returns a channel that receives the last published message (if any),
+// then the next ones. The channel is closed at the end of the stream.
+// Returns whether the stream is live: Live subscribers are waited for when
+// the stream is closed, until they call handlers.Done.
+func (s *turnStream) subscribe() (chan streamedMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	subscriber := make(chan streamedMessage, turnStreamBufferSize)
+	if s.last != nil {
+		serialized, ok := serializeStreamedMessage(s.lastType, s.last)
+		if ok {
+			subscriber <- serialized
+		}
+	}
+	if s.ended {
+		close(subscriber)
+		return subscriber, false
+	}
+	s.subscribers[subscriber] = true
+	s.handlers.Add(1)
+	return subscriber, true
+}
+
+func (s *turnStream) unsubscribe(subscriber chan streamedMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.subscribers[subscriber] {
+		delete(s.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// Ends the stream, and gives subscribers some time to receive the messages
+// they have not received yet.
+func (s *turnStream) close(timeout time.Duration) {
+	s.mutex.Lock()
+	s.end()
+	s.mutex.Unlock()
+
+	done := make(chan int)
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// Streams TURN and GAME_ENDS messages, as public visualizations receive
+// them, and the ADMIN_MESSAGE broadcast meanwhile. Messages are sent as Server-Sent Events if the client accepts them
+// (text/event-stream), or as newline-delimited JSON otherwise.
+func handleTurnStream(s *turnStream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, canFlush := w.(http.Flusher)
+		if !canFlush {
+			http.Error(w, "Streaming is not supported",
+				http.StatusInternalServerError)
+			return
+		}
+
+		subscriber, live := s.subscribe()
+		if live {
+			defer s.handlers.Done()
+		}
+		defer s.unsubscribe(subscriber)
+
+		sse := r.Header.Get("Accept") == "text/event-stream"
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case message, ok := <-subscriber:
+				if !ok {
+					return
+				}
+
+				var err error
+				if sse {
+					_, err = fmt.Fprintf(w, "event: %v\ndata: %s\n\n",
+						message.messageType, message.content)
+				} else {
+					_, err = fmt.Fprintf(w, "%s\n", message.content)
+				}
+				if err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package netorcai
+
+import (
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Sends a state (e.g. "READY=1") to systemd, as sd_notify(3) does.
+// Does nothing if netorcai has not been started by systemd with Type=notify.
+func SystemdNotify(state string) error {
+	socketName := os.Getenv("NOTIFY_SOCKET")
+	if socketName == "" {
+		return nil
+	}
+
+	// Abstract namespace sockets are prefixed by '@'
+	if socketName[0] == '@' {
+		socketName = "\x00" + socketName[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil,
+		&net.UnixAddr{Name: socketName, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Periodically sends keepalives to the systemd watchdog, as long as the
+// global state mutex can be acquired (a deadlock stops the keepalives).
+// Returns directly if the systemd watchdog is not enabled.
+func RunSystemdWatchdog(gs *GlobalState) {
+	watchdogPID := os.Getenv("WATCHDOG_PID")
+	if watchdogPID != "" && watchdogPID != strconv.Itoa(os.Getpid()) {
+		return
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+
+	// systemd recommends sending keepalives twice per watchdog period
+	interval := time.Duration(usec) * time.Microsecond / 2
+	log.WithFields(log.Fields{
+		"interval (ms)": interval.Seconds() * 1000,
+	}).Debug("Sending keepalives to systemd watchdog")
+
+	for range time.Tick(interval) {
+		LockGlobalStateMutex(gs, "Watchdog keepalive", "Watchdog")
+		UnlockGlobalStateMutex(gs, "Watchdog keepalive", "Watchdog")
+		SystemdNotify("WATCHDOG=1")
+	}
+}
@@ -0,0 +1,81 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Listens on a datagram socket named socketName, given to SystemdNotify as
+// NOTIFY_SOCKET. Returns the socket and a function that restores the
+// environment.
+func listenNotifySocket(t *testing.T, socketName string) (*net.UnixConn,
+	func()) {
+	name := socketName
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: name, Net: "unixgram"})
+	assert.NoError(t, err, "Cannot listen on notify socket")
+
+	notifySocket := os.Getenv("NOTIFY_SOCKET")
+	os.Setenv("NOTIFY_SOCKET", socketName)
+	return conn, func() {
+		os.Setenv("NOTIFY_SOCKET", notifySocket)
+		conn.Close()
+	}
+}
+
+func checkSystemdNotify(t *testing.T, conn *net.UnixConn) {
+	for _, state := range []string{"READY=1", "WATCHDOG=1", "STOPPING=1"} {
+		assert.NoError(t, SystemdNotify(state), "Cannot notify %v", state)
+
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		assert.NoError(t, err, "Notification %v not received", state)
+		assert.Equal(t, state, string(buf[:n]), "Unexpected notification")
+	}
+}
+
+func TestSystemdNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-systemd")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+
+	conn, restore := listenNotifySocket(t, filepath.Join(dir, "notify"))
+	defer restore()
+	checkSystemdNotify(t, conn)
+}
+
+func TestSystemdNotifyAbstractSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Abstract namespace sockets are Linux only")
+	}
+
+	conn, restore := listenNotifySocket(t, "@netorcai-test-notify")
+	defer restore()
+	checkSystemdNotify(t, conn)
+}
+
+func TestSystemdNotifyUnset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-systemd")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+
+	conn, restore := listenNotifySocket(t, filepath.Join(dir, "notify"))
+	defer restore()
+	os.Unsetenv("NOTIFY_SOCKET")
+
+	assert.NoError(t, SystemdNotify("READY=1"),
+		"Notifying without systemd should do nothing")
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 64))
+	assert.Error(t, err, "No notification should be sent")
+}
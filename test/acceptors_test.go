@@ -0,0 +1,47 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func subtestSeveralAcceptors(t *testing.T, arguments []string) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, append([]string{
+		"--nb-players-max=16"}, arguments...))
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	players := make(chan *client.Client, 16)
+	errors := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		go func() {
+			player, err := netorcaitest.ConnectClient(t, "player", "player",
+				netorcai.Version, 2000)
+			players <- player
+			errors <- err
+		}()
+	}
+
+	for i := 0; i < 16; i++ {
+		<-players
+		assert.NoError(t, <-errors, "Cannot connect client")
+	}
+
+	err := netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestSeveralAcceptors(t *testing.T) {
+	subtestSeveralAcceptors(t, []string{"--acceptors=4"})
+}
+
+func TestSeveralAcceptorsReusePort(t *testing.T) {
+	subtestSeveralAcceptors(t, []string{"--acceptors=4", "--reuse-port"})
+}
+
+func TestMaxPendingLogins(t *testing.T) {
+	subtestSeveralAcceptors(t, []string{"--acceptors=4",
+		"--max-pending-logins=2"})
+}
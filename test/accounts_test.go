@@ -0,0 +1,152 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func loginWithAPIKey(t *testing.T, nickname, apiKey string) map[string]interface{} {
+	_, msg := connectWithAPIKey(t, nickname, apiKey)
+	return msg
+}
+
+// Logs a player in and returns its connection with the LOGIN answer.
+func connectWithAPIKey(t *testing.T, nickname, apiKey string) (*client.Client,
+	map[string]interface{}) {
+	return connectWithRole(t, nickname, "player", apiKey)
+}
+
+func connectWithRole(t *testing.T, nickname, role, apiKey string) (
+	*client.Client, map[string]interface{}) {
+	player := &client.Client{}
+	err := player.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+
+	login := map[string]interface{}{
+		"message_type":         "LOGIN",
+		"nickname":             nickname,
+		"role":                 role,
+		"metaprotocol_version": netorcai.Version,
+	}
+	if apiKey != "" {
+		login["api_key"] = apiKey
+	}
+	err = player.SendJSON(login)
+	assert.NoError(t, err, "Cannot send LOGIN")
+
+	msg, err := netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read LOGIN answer")
+	return player, msg
+}
+
+func TestAccountsLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-accounts")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "accounts.json")
+
+	accounts, err := netorcai.LoadAccounts(filename)
+	assert.NoError(t, err, "Cannot load accounts")
+	apiKey, err := accounts.Add("alice")
+	assert.NoError(t, err, "Cannot add account")
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--accounts=" + filename})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	msg := loginWithAPIKey(t, "anonymous", "")
+	netorcaitest.CheckKick(t, msg, "Player", regexp.MustCompile(`API key is required`))
+	netorcaitest.CheckKickCode(t, msg, "Player", netorcai.KICK_LOGIN_DENIED_AUTH)
+
+	msg = loginWithAPIKey(t, "intruder", "not a key")
+	netorcaitest.CheckKick(t, msg, "Player", regexp.MustCompile(`Unknown API key`))
+	netorcaitest.CheckKickCode(t, msg, "Player", netorcai.KICK_LOGIN_DENIED_AUTH)
+
+	msg = loginWithAPIKey(t, "alice", apiKey)
+	netorcaitest.CheckLoginAck(t, msg)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`account=alice`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Account should be logged")
+
+	// An account cannot be used twice at the same time
+	msg = loginWithAPIKey(t, "alice2", apiKey)
+	netorcaitest.CheckKick(t, msg, "Player",
+		regexp.MustCompile(`account already logged in`))
+}
+
+func TestAccountsDuplicateLoginReplace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-accounts")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "accounts.json")
+
+	accounts, err := netorcai.LoadAccounts(filename)
+	assert.NoError(t, err, "Cannot load accounts")
+	apiKey, err := accounts.Add("alice")
+	assert.NoError(t, err, "Cannot add account")
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--accounts=" + filename, "--duplicate-login=replace"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	first, msg := connectWithAPIKey(t, "alice", apiKey)
+	netorcaitest.CheckLoginAck(t, msg)
+
+	// The new login takes the seat of the previous one
+	msg = loginWithAPIKey(t, "alice2", apiKey)
+	netorcaitest.CheckLoginAck(t, msg)
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Account is already logged in`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Duplicate login should be logged")
+
+	msg, err = netorcaitest.WaitReadMessage(first, 1000)
+	assert.NoError(t, err, "Cannot read KICK")
+	netorcaitest.CheckKick(t, msg, "Player",
+		regexp.MustCompile(`Replaced by a new login`))
+	netorcaitest.CheckKickCode(t, msg, "Player", netorcai.KICK_REPLACED)
+}
+
+func TestAccountsDuplicateLoginReplaceDenied(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-accounts")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "accounts.json")
+
+	accounts, err := netorcai.LoadAccounts(filename)
+	assert.NoError(t, err, "Cannot load accounts")
+	apiKey, err := accounts.Add("alice")
+	assert.NoError(t, err, "Cannot add account")
+	otherAPIKey, err := accounts.Add("bob")
+	assert.NoError(t, err, "Cannot add account")
+
+	netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--accounts=" + filename, "--duplicate-login=replace",
+		"--nb-players-max=1", "--nb-splayers-max=0"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	first, msg := connectWithAPIKey(t, "alice", apiKey)
+	netorcaitest.CheckLoginAck(t, msg)
+
+	// A denied login does not replace the previous one
+	_, msg = connectWithRole(t, "alice2", "special player", apiKey)
+	netorcaitest.CheckKick(t, msg, "Player",
+		regexp.MustCompile(`Maximum number of special players reached`))
+	msg = loginWithAPIKey(t, "bob", otherAPIKey)
+	netorcaitest.CheckKick(t, msg, "Player",
+		regexp.MustCompile(`Maximum number of players reached`))
+
+	// The seat of the previous login is taken even if all seats are taken
+	msg = loginWithAPIKey(t, "alice3", apiKey)
+	netorcaitest.CheckLoginAck(t, msg)
+	msg, err = netorcaitest.WaitReadMessage(first, 1000)
+	assert.NoError(t, err, "Cannot read KICK")
+	netorcaitest.CheckKickCode(t, msg, "Player", netorcai.KICK_REPLACED)
+}
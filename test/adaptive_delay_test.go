@@ -0,0 +1,41 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestAdaptiveDelay(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=800", "--nb-turns-max=8",
+			"--delay-turns=800", "--adaptive-delay"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 1, 0, 8, 8, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// Run an active player, which answers right away
+	go netorcaitest.HelloClient(t, players[0], "Player0", 1, 0, 8, 8, 0, 800, 800, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// Disconnect other clients
+	for _, client := range append(players[1:], visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	// Only the first delay should be 800 ms, as no latency is known yet.
+	// The game would last more than 6 seconds without adaptive delay.
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 3000, false)
+	assert.NoError(t, err, "Game did not finish quickly")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
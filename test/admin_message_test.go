@@ -0,0 +1,91 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func checkAdminMessage(t *testing.T, c *client.Client, expectedText string) {
+	msg, err := netorcaitest.WaitReadMessage(c, 1000)
+	assert.NoError(t, err, "Cannot read ADMIN_MESSAGE")
+	messageType, _ := netorcai.ReadString(msg, "message_type")
+	assert.Equal(t, "ADMIN_MESSAGE", messageType, "Unexpected message")
+	text, err := netorcai.ReadString(msg, "text")
+	assert.NoError(t, err, "Cannot read text in ADMIN_MESSAGE")
+	assert.Equal(t, expectedText, text, "Unexpected text in ADMIN_MESSAGE")
+}
+
+func TestAdminMessagePrompt(t *testing.T) {
+	proc, clients, _, _, _, _ := netorcaitest.RunNetorcaiAndClients(
+		t, []string{}, 1000, 1, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "broadcast Match restarting in 2 minutes"
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`ADMIN_MESSAGE sent to 2 clients`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Admin message not broadcast")
+
+	// The player and the visualization
+	for _, c := range clients[:2] {
+		checkAdminMessage(t, c, "Match restarting in 2 minutes")
+	}
+}
+
+func TestAdminMessageEndpoint(t *testing.T) {
+	tokenFile := writeControlToken(t)
+	defer os.RemoveAll(filepath.Dir(tokenFile))
+
+	_, clients, _, _, _, _ := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--admin-port=4243", "--control-port=4244",
+			"--control-token-file=" + tokenFile}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	resp := postWithToken(t, "http://localhost:4244/broadcast", "", "Hacked")
+	if resp != nil {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode,
+			"Unexpected /broadcast status code without token")
+	}
+	resp = postWithToken(t, "http://localhost:4243/broadcast", controlToken,
+		"Hacked")
+	if resp != nil {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode,
+			"/broadcast should not be served on the admin port")
+	}
+
+	resp = requestWithToken(t, "POST", "http://localhost:4244/broadcast",
+		controlToken, "Welcome!")
+	if resp != nil {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode,
+			"Unexpected /broadcast status code")
+		var result map[string]int
+		err := json.NewDecoder(resp.Body).Decode(&result)
+		assert.NoError(t, err, "Cannot decode /broadcast result")
+		assert.Equal(t, 1, result["nb_clients"], "Unexpected nb_clients")
+	}
+	checkAdminMessage(t, clients[0], "Welcome!")
+
+	resp = requestWithToken(t, "GET", "http://localhost:4244/broadcast",
+		controlToken, "")
+	if resp != nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode,
+			"Unexpected /broadcast status code on GET")
+	}
+
+	resp = postWithToken(t, "http://localhost:4244/broadcast", controlToken,
+		"  ")
+	if resp != nil {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode,
+			"Unexpected /broadcast status code without text")
+	}
+}
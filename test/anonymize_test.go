@@ -0,0 +1,57 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func checkAnonymousPlayersInfo(t *testing.T, msg map[string]interface{}) {
+	playersInfo, err := netorcai.ReadArray(msg, "players_info")
+	assert.NoError(t, err, "Cannot read 'players_info'")
+	for _, info := range playersInfo {
+		obj := info.(map[string]interface{})
+		playerID, err := netorcai.ReadInt(obj, "player_id")
+		assert.NoError(t, err, "Cannot read 'player_id'")
+		nickname, err := netorcai.ReadString(obj, "nickname")
+		assert.NoError(t, err, "Cannot read 'nickname'")
+		assert.Equal(t, fmt.Sprintf("Player %v", playerID), nickname,
+			"Nickname is not anonymized")
+		remoteAddress, err := netorcai.ReadString(obj, "remote_address")
+		assert.NoError(t, err, "Cannot read 'remote_address'")
+		assert.Equal(t, "", remoteAddress, "Remote address is not hidden")
+	}
+}
+
+func checkGameStartsAnonymous(t *testing.T, msg map[string]interface{},
+	nbPlayers, nbSpecialPlayers, nbTurnsGL int,
+	msBeforeFirstTurn, msBetweenTurns float64, isPlayer bool) int {
+	playerID := netorcaitest.CheckGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
+		msBeforeFirstTurn, msBetweenTurns, isPlayer)
+	checkAnonymousPlayersInfo(t, msg)
+	return playerID
+}
+
+func checkTurnAnonymous(t *testing.T, msg map[string]interface{},
+	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
+	turnNumber := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	checkAnonymousPlayersInfo(t, msg)
+	return turnNumber
+}
+
+func TestAnonymizePlayers(t *testing.T) {
+	subtestHelloGlActiveClients(t, []string{"--anonymize-players"}, 2, 0, 1,
+		3, 3, 3, 3,
+		0, 0,
+		false, false,
+		checkGameStartsAnonymous, checkTurnAnonymous, checkTurnAnonymous,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`))
+}
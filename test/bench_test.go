@@ -0,0 +1,37 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestBench(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{"bench",
+		"--nb-players=2", "--nb-turns-max=3"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl, err := netorcaitest.ConnectClient(t, "game logic", "gl",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect game logic")
+	go netorcaitest.HelloGameLogic(t, gl, 2, 0, 3, 3,
+		netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck,
+		netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// One line per turn, each TURN being sent to the 2 loopback players
+	for turn := 0; turn < 2; turn++ {
+		_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(fmt.Sprintf(
+			`\A\s+%v\s+[0-9.]+\s+[0-9]+\s+2\s+[0-9]+\s+[0-9.]+\z`, turn)),
+			proc.OutputControl, 2000, false)
+		assert.NoError(t, err, "Cannot read the cost of turn %v", turn)
+	}
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai bench did not complete")
+	assert.Equal(t, 0, retCode, "Unexpected netorcai bench return code")
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"regexp"
+	"testing"
+)
+
+func TestBroadcastWorkers(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=500", "--nb-turns-max=3",
+			"--delay-turns=500", "--broadcast-workers=1"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 4, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// All clients must receive all turns despite the single worker
+	for playerID, player := range players {
+		go netorcaitest.HelloClient(t, player, fmt.Sprintf("Player%v", playerID),
+			4, 0, 3, 3, 0, 500, 500, true, false, true, true,
+			netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+			netorcaitest.DefaultHelloClientCheckGameEnds,
+			netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+	}
+	for visuID, visu := range visus {
+		go netorcaitest.HelloClient(t, visu, fmt.Sprintf("Visu%v", visuID),
+			4, 0, 3, 3, 0, 500, 500, false, false, true, true,
+			netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+			netorcaitest.DefaultHelloClientCheckGameEnds,
+			netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+	}
+
+	proc.InputControl <- "start"
+
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestPromptCheck(t *testing.T) {
+	proc, _, players, _, _, _ := netorcaitest.RunNetorcaiAndClients(
+		t, []string{}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "check"
+
+	// The player answers the PING, while the game logic does not
+	msg, err := netorcaitest.WaitReadMessage(players[0], 1000)
+	assert.NoError(t, err, "Cannot read client message (PING)")
+	messageType, err := netorcai.ReadString(msg, "message_type")
+	assert.NoError(t, err, "Cannot read message_type")
+	assert.Equal(t, "PING", messageType, "Unexpected message type")
+
+	err = players[0].SendString(`{"message_type":"PONG"}`)
+	assert.NoError(t, err, "Cannot send PONG")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`PING answered by 1/2 clients`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Cannot read check summary")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestAutostartCheck(t *testing.T) {
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--nb-players-max=1", "--nb-visus-max=0",
+			"--autostart", "--autostart-check"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(players, gl...) {
+		msg, err := netorcaitest.WaitReadMessage(client, 1000)
+		assert.NoError(t, err, "Cannot read client message (PING)")
+		messageType, err := netorcai.ReadString(msg, "message_type")
+		assert.NoError(t, err, "Cannot read message_type")
+		assert.Equal(t, "PING", messageType, "Unexpected message type")
+
+		err = client.SendString(`{"message_type":"PONG"}`)
+		assert.NoError(t, err, "Cannot send PONG")
+	}
+
+	msg, err := netorcaitest.WaitReadMessage(gl[0], 1000)
+	assert.NoError(t, err, "Cannot read GL message (DO_INIT)")
+	netorcaitest.CheckDoInit(t, msg, 1, 0, 100)
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func subtestCheckClient(t *testing.T, answer bool, expectedOutput *regexp.Regexp,
+	expectedReturnCode int) {
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, expectedReturnCode)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile,
+		[]string{"check-client", "--timeout=5"})
+	assert.NoError(t, err, "Cannot start netorcai check-client")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Waiting for a client`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "netorcai check-client is not waiting")
+
+	player, err := netorcaitest.ConnectClient(t, "player", "bot",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect player")
+
+	msg, err := netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read client message (PING)")
+	messageType, err := netorcai.ReadString(msg, "message_type")
+	assert.NoError(t, err, "Cannot read message_type")
+	assert.Equal(t, "PING", messageType, "Unexpected message type")
+
+	if answer {
+		err = player.SendString(`{"message_type":"PONG"}`)
+		assert.NoError(t, err, "Cannot send PONG")
+	}
+
+	_, err = netorcaitest.WaitReadKick(player, 2000)
+	assert.NoError(t, err, "Cannot read KICK")
+
+	_, err = netorcaitest.WaitOutputTimeout(expectedOutput, proc.OutputControl,
+		2000, false)
+	assert.NoError(t, err, "Unexpected check-client output")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai check-client did not complete")
+	assert.Equal(t, expRetCode, retCode,
+		"Unexpected netorcai check-client return code")
+}
+
+func TestCheckClientAnswers(t *testing.T) {
+	subtestCheckClient(t, true,
+		regexp.MustCompile(`PING answered by 1/1 clients`), 0)
+}
+
+func TestCheckClientSilent(t *testing.T) {
+	subtestCheckClient(t, false, regexp.MustCompile(`player bot .*: no answer`), 1)
+}
@@ -1,158 +1,295 @@
 package test
 
 import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 )
 
 func TestMain(m *testing.M) {
-	killallNetorcaiSIGKILL()
+	netorcaitest.KillallNetorcaiSIGKILL()
 	retCode := m.Run()
-	killallNetorcaiSIGKILL()
+	netorcaitest.KillallNetorcaiSIGKILL()
 	os.Exit(retCode)
 }
 
 func TestCLINoArgs(t *testing.T) {
 	args := []string{}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgHelp(t *testing.T) {
 	args := []string{"--help"}
-	coverFile, expRetCode := handleCoverage(t, 0)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgHelpShort(t *testing.T) {
 	args := []string{"-h"}
-	coverFile, expRetCode := handleCoverage(t, 0)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgVersion(t *testing.T) {
 	args := []string{"--version"}
-	coverFile, expRetCode := handleCoverage(t, 0)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitOutputTimeout(regexp.MustCompile(`\Av\d+\.\d+\.\d+\S*\z`),
-		proc.outputControl, 1000, false)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Av\d+\.\d+\.\d+\S*\z`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read version")
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIVersionJSON(t *testing.T) {
+	args := []string{"version", "--json"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`"metaprotocol_version": "`+netorcai.Version+`"`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read metaprotocol version")
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`"capabilities": \[`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read capabilities")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLISchema(t *testing.T) {
+	args := []string{"schema", "TURN_ACK"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`"const": "TURN_ACK"`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read message type")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLISchemaUnknownMessageType(t *testing.T) {
+	args := []string{"schema", "NOPE"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Unknown message type 'NOPE'`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Unknown message type not reported")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIServeCommand(t *testing.T) {
+	args := []string{"serve", "--nb-turns-max=3"}
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
+	assert.NoError(t, err, "Netorcai is not listening")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestCLIUnknownCommand(t *testing.T) {
+	args := []string{"unknown-command"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Unknown command 'unknown-command'`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Unknown command not reported")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLICommandHelp(t *testing.T) {
+	args := []string{"tournament", "--help"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`--players=<file>  `),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Tournament options not printed")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLICompactLogs(t *testing.T) {
+	args := []string{"--compact-logs", "--color=never"}
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`\A\d\d:\d\d:\d\d\.\d{3} INFO Listening incoming connections .*port=4242`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Netorcai is not listening (compact logs)")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestCLIBadColor(t *testing.T) {
+	args := []string{"--color=sometimes"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgVerbose(t *testing.T) {
 	args := []string{"--verbose"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgQuiet(t *testing.T) {
 	args := []string{"--quiet"}
-	coverFile, expRetCode := handleCoverage(t, 0)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "quit"
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	proc.InputControl <- "quit"
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDebug(t *testing.T) {
 	args := []string{"--debug"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgJsonLogs(t *testing.T) {
 	args := []string{"--json-logs"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIInvalidVerbosityCombination(t *testing.T) {
 	args := []string{"--debug", "--verbose"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIUnknownArg(t *testing.T) {
 	args := []string{"--this-option-should-not-exist"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
@@ -162,70 +299,70 @@ func TestCLIUnknownArg(t *testing.T) {
  ********************/
 func TestCLIArgNbPlayersMaxNotInteger(t *testing.T) {
 	args := []string{"--nb-players-max=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbPlayersMaxTooSmall(t *testing.T) {
 	args := []string{"--nb-players-max=-1"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbPlayersMaxTooBig(t *testing.T) {
 	args := []string{"--nb-players-max=1025"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbPlayersMaxSmall(t *testing.T) {
 	args := []string{"--nb-players-max=0"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgNbPlayersMaxBig(t *testing.T) {
 	args := []string{"--nb-players-max=1024"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -234,70 +371,70 @@ func TestCLIArgNbPlayersMaxBig(t *testing.T) {
  ********************/
 func TestCLIArgNbSpecialPlayersMaxNotInteger(t *testing.T) {
 	args := []string{"--nb-splayers-max=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbSpecialPlayersMaxTooSmall(t *testing.T) {
 	args := []string{"--nb-splayers-max=-1"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbSpecialPlayersMaxTooBig(t *testing.T) {
 	args := []string{"--nb-splayers-max=1025"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbSpecialPlayersMaxSmall(t *testing.T) {
 	args := []string{"--nb-splayers-max=0"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgNbSpecialPlayersMaxBig(t *testing.T) {
 	args := []string{"--nb-splayers-max=1024"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -306,70 +443,70 @@ func TestCLIArgNbSpecialPlayersMaxBig(t *testing.T) {
  **********/
 func TestCLIArgPortNotInteger(t *testing.T) {
 	args := []string{"--port=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgPortTooSmall(t *testing.T) {
 	args := []string{"--port=0"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgPortTooBig(t *testing.T) {
 	args := []string{"--port=65536"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgPortSmall(t *testing.T) {
 	args := []string{"--port=1025"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgPortBig(t *testing.T) {
 	args := []string{"--port=65535"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -378,70 +515,70 @@ func TestCLIArgPortBig(t *testing.T) {
  ******************/
 func TestCLIArgNbTurnsMaxNotInteger(t *testing.T) {
 	args := []string{"--nb-turns-max=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbTurnsMaxTooSmall(t *testing.T) {
 	args := []string{"--nb-turns-max=0"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbTurnsMaxTooBig(t *testing.T) {
 	args := []string{"--nb-turns-max=65536"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbTurnsMaxSmall(t *testing.T) {
 	args := []string{"--nb-turns-max=1"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgNbTurnsMaxBig(t *testing.T) {
 	args := []string{"--nb-turns-max=65535"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -450,70 +587,70 @@ func TestCLIArgNbTurnsMaxBig(t *testing.T) {
  ******************/
 func TestCLIArgNbVisusMaxNotInteger(t *testing.T) {
 	args := []string{"--nb-visus-max=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbVisusMaxTooSmall(t *testing.T) {
 	args := []string{"--nb-visus-max=-1"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbVisusMaxTooBig(t *testing.T) {
 	args := []string{"--nb-visus-max=1025"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgNbVisusMaxSmall(t *testing.T) {
 	args := []string{"--nb-visus-max=0"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgNbVisusMaxBig(t *testing.T) {
 	args := []string{"--nb-visus-max=1024"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -522,70 +659,70 @@ func TestCLIArgNbVisusMaxBig(t *testing.T) {
  **********************/
 func TestCLIArgDelayFirstTurnNotFloat(t *testing.T) {
 	args := []string{"--delay-first-turn=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDelayFirstTurnTooSmall(t *testing.T) {
 	args := []string{"--delay-first-turn=49.999"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDelayFirstTurnTooBig(t *testing.T) {
 	args := []string{"--delay-first-turn=10000.001"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDelayFirstTurnSmall(t *testing.T) {
 	args := []string{"--delay-first-turn=50"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgDelayFirstTurnBig(t *testing.T) {
 	args := []string{"--delay-first-turn=10000"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -594,69 +731,247 @@ func TestCLIArgDelayFirstTurnBig(t *testing.T) {
  *****************/
 func TestCLIArgDelayTurnsNotFloat(t *testing.T) {
 	args := []string{"--delay-turns=meh"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDelayTurnsTooSmall(t *testing.T) {
 	args := []string{"--delay-turns=49.999"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDelayTurnsTooBig(t *testing.T) {
 	args := []string{"--delay-turns=10000.001"}
-	coverFile, expRetCode := handleCoverage(t, 1)
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestCLIArgDelayTurnsSmall(t *testing.T) {
 	args := []string{"--delay-turns=50"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestCLIArgDelayTurnsBig(t *testing.T) {
 	args := []string{"--delay-turns=10000"}
-	coverFile, _ := handleCoverage(t, 0)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
 
-	proc, err := runNetorcaiCover(coverFile, args)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
 	assert.NoError(t, err, "Netorcai is not listening")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestCLIDoctor(t *testing.T) {
+	args := []string{"doctor"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\A\[OK\] TCP port 4242 is available`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read port diagnostic")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIDoctorPortInUse(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	doctor, err := netorcaitest.RunNetorcaiCover(coverFile, []string{"doctor"})
+	assert.NoError(t, err, "Cannot start netorcai doctor")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\A\[FAIL\] TCP port 4242 is not available`),
+		doctor.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read port diagnostic")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(doctor.Completion, 1000)
+	assert.NoError(t, err, "netorcai doctor did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai doctor return code")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
+
+func TestCLIArgLuaGLNonexistentFile(t *testing.T) {
+	args := []string{"--lua-gl=/nonexistent/netorcai-game.lua"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Cannot run Lua game logic`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read Lua error")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIArgWasmGLNonexistentFile(t *testing.T) {
+	args := []string{"--wasm-gl=/nonexistent/netorcai-game.wasm"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Cannot run WASM game logic`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read WASM error")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIArgLuaAndWasmGL(t *testing.T) {
+	args := []string{"--lua-gl=game.lua", "--wasm-gl=game.wasm"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIArgUnknownJSONBackend(t *testing.T) {
+	args := []string{"--json-backend=meh"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`Unknown JSON backend 'meh'. Available ones: .*std`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read JSON backend error")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIArgBadActionOrder(t *testing.T) {
+	args := []string{"--action-order=alphabetical"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`Bad --action-order=alphabetical. Accepted values: arrival player-id shuffled`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read action order error")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLIArgControlPortWithoutToken(t *testing.T) {
+	args := []string{"--control-port=4244"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`--control-port requires --control-token-file`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read control token error")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
+
+func TestCLICheckConfig(t *testing.T) {
+	dumpDir := filepath.Join(os.TempDir(), "netorcai-check-config-dump")
+	os.RemoveAll(dumpDir)
+	args := []string{"--check-config", "--nb-players-max=2",
+		"--dump-states=" + dumpDir}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 0)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`"nb_players_max": 2,`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read effective configuration")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+	_, err = os.Stat(dumpDir)
+	assert.True(t, os.IsNotExist(err), "--dump-states directory created")
+}
+
+func TestCLICheckConfigConflict(t *testing.T) {
+	args := []string{"--check-config", "--fast", "--adaptive-delay"}
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, 1)
+
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, args)
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`--adaptive-delay has no effect with --fast`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Conflict not reported")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func checkGameEndsClientStats(t *testing.T, msg map[string]interface{},
+	clientName string) {
+	netorcaitest.CheckGameEnds(t, msg, clientName)
+	if !strings.HasPrefix(clientName, "Visu") {
+		_, exists := msg["client_stats"]
+		assert.False(t, exists, "%v: unexpected 'client_stats'", clientName)
+		return
+	}
+
+	clientStats, err := netorcai.ReadObject(msg, "client_stats")
+	if !assert.NoError(t, err, "%v cannot read 'client_stats'", clientName) {
+		return
+	}
+	assert.Equal(t, 1, len(clientStats), "Unexpected 'client_stats' length")
+	stats, isObject := clientStats["0"].(map[string]interface{})
+	if !assert.True(t, isObject, "No stats for player 0") {
+		return
+	}
+
+	// The last game state is sent in GAME_ENDS: LOGIN_ACK, GAME_STARTS and
+	// 2 TURN sent; LOGIN and 2 TURN_ACK received
+	for field, expected := range map[string]int{
+		"messages_sent":     4,
+		"messages_received": 3,
+		"invalid_messages":  0,
+		"reconnects":        0,
+	} {
+		value, err := netorcai.ReadInt(stats, field)
+		assert.NoError(t, err, "Cannot read '%v'", field)
+		assert.Equal(t, expected, value, "Unexpected '%v' value", field)
+	}
+}
+
+func TestGameEndsClientStats(t *testing.T) {
+	subtestHelloGlActiveClients(t, []string{}, 1, 0, 1,
+		3, 3, 3, 3,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts,
+		netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckTurn,
+		checkGameEndsClientStats, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`))
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestConnectGL(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--connect-gl=localhost:4244", "--nb-turns-max=2",
+		"--delay-first-turn=50", "--delay-turns=50", "--nb-visus-max=0"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// The game logic does not listen yet: netorcai retries
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Cannot connect to remote client yet`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "netorcai should retry to connect")
+
+	listener, err := net.Listen("tcp", "localhost:4244")
+	if !assert.NoError(t, err, "Cannot listen") {
+		return
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	var gl *client.Client
+	select {
+	case conn := <-accepted:
+		gl = &client.Client{}
+		gl.ConnectConn(conn)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "netorcai did not connect to the game logic")
+	}
+
+	err = gl.SendLogin("game logic", "gl", netorcai.Version)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := netorcaitest.WaitReadMessage(gl, 1000)
+	assert.NoError(t, err, "Cannot read LOGIN_ACK")
+	netorcaitest.CheckLoginAck(t, msg)
+
+	player, err := netorcaitest.ConnectClient(t, "player", "player",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect player")
+
+	go netorcaitest.HelloGameLogic(t, gl, 1, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	go netorcaitest.HelloClient(t, player, "Player", 1, 0, 2, 2, 0, 50, 50,
+		true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestConnectPlayer(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:4244")
+	if !assert.NoError(t, err, "Cannot listen") {
+		return
+	}
+	defer listener.Close()
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--connect-player=localhost:4244"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	conn, err := listener.Accept()
+	if !assert.NoError(t, err, "netorcai did not connect to the player") {
+		return
+	}
+	player := &client.Client{}
+	player.ConnectConn(conn)
+
+	err = player.SendLogin("player", "remote", netorcai.Version)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read LOGIN_ACK")
+	netorcaitest.CheckLoginAck(t, msg)
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`New player accepted.*remote`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "The remote player should be accepted")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
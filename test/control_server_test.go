@@ -0,0 +1,159 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const controlToken = "s3cr3t-t0ken"
+
+// Writes the control token into a temporary file, and returns its name.
+func writeControlToken(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "netorcai-control")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	filename := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(filename, []byte(controlToken+"\n"), 0600)
+	assert.NoError(t, err, "Cannot write token file")
+	return filename
+}
+
+// Sends a request with a token (none if empty), retrying until the server
+// answers. The body of the response must be closed by the caller.
+func requestWithToken(t *testing.T, method, url, token,
+	body string) *http.Response {
+	var resp *http.Response
+	var err error
+	for i := 0; i < 10; i++ {
+		request, _ := http.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Content-Type", "text/plain")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err = http.DefaultClient.Do(request)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !assert.NoError(t, err, "Cannot query %v", url) {
+		return nil
+	}
+	return resp
+}
+
+// Sends a POST request with a token (none if empty), retrying until the
+// server answers.
+func postWithToken(t *testing.T, url, token, body string) *http.Response {
+	resp := requestWithToken(t, "POST", url, token, body)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp
+}
+
+func TestControlStop(t *testing.T) {
+	tokenFile := writeControlToken(t)
+	defer os.RemoveAll(filepath.Dir(tokenFile))
+
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=10",
+			"--delay-turns=300", "--admin-port=4243", "--control-port=4244",
+			"--control-token-file=" + tokenFile}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// The game cannot be stopped before it starts
+	resp := postWithToken(t, "http://localhost:4244/stop", controlToken, "")
+	if resp != nil {
+		assert.Equal(t, http.StatusConflict, resp.StatusCode,
+			"Unexpected /stop status code before the game starts")
+	}
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+	proc.InputControl <- "start"
+	assert.Equal(t, "GAME_STARTS", readMessageType(t, players[0]))
+	assert.Equal(t, "TURN", readMessageType(t, players[0]))
+
+	resp = postWithToken(t, "http://localhost:4244/stop", "", "")
+	if resp != nil {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode,
+			"Unexpected /stop status code without token")
+	}
+	resp = postWithToken(t, "http://localhost:4243/stop", controlToken, "")
+	if resp != nil {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode,
+			"/stop should not be served on the admin port")
+	}
+
+	// The game ends cleanly with the next turn
+	resp = postWithToken(t, "http://localhost:4244/stop", controlToken, "")
+	if resp != nil {
+		assert.Equal(t, http.StatusOK, resp.StatusCode,
+			"Unexpected /stop status code")
+	}
+	err := players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(0, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	assert.Equal(t, "GAME_ENDS", readMessageType(t, players[0]))
+	assert.Equal(t, "KICK", readMessageType(t, players[0]))
+}
+
+func TestControlAbort(t *testing.T) {
+	tokenFile := writeControlToken(t)
+	defer os.RemoveAll(filepath.Dir(tokenFile))
+
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=10",
+			"--delay-turns=300", "--admin-port=4243", "--control-port=4244",
+			"--control-token-file=" + tokenFile}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+	proc.InputControl <- "start"
+	assert.Equal(t, "GAME_STARTS", readMessageType(t, players[0]))
+
+	// Only requests with the token are served, on the control port only
+	for _, token := range []string{"", "wrong"} {
+		resp := postWithToken(t, "http://localhost:4244/abort", token, "Hacked")
+		if resp != nil {
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode,
+				"Unexpected /abort status code with token '%v'", token)
+		}
+	}
+	resp := postWithToken(t, "http://localhost:4243/abort", controlToken,
+		"Hacked")
+	if resp != nil {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode,
+			"/abort should not be served on the admin port")
+	}
+
+	resp = postWithToken(t, "http://localhost:4244/abort", controlToken,
+		"Power outage")
+	if resp != nil {
+		assert.Equal(t, http.StatusOK, resp.StatusCode,
+			"Unexpected /abort status code")
+	}
+	for {
+		msg, err := netorcaitest.WaitReadMessage(players[0], 1000)
+		if !assert.NoError(t, err, "Cannot read GAME_ENDS") {
+			return
+		}
+		if messageType, _ := netorcai.ReadString(msg, "message_type"); messageType == "GAME_ENDS" {
+			reason, _ := netorcai.ReadString(msg, "abort_reason")
+			assert.Equal(t, "Power outage", reason, "Unexpected abort_reason")
+			break
+		}
+	}
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 2000)
+	assert.NoError(t, err, "netorcai did not exit")
+	assert.Equal(t, 1, retCode, "Unexpected netorcai return code")
+}
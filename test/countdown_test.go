@@ -0,0 +1,43 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCountdown(t *testing.T) {
+	proc, clients, _, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=1",
+			"--countdown=2"}, 1000, 1, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+
+	proc.InputControl <- "start"
+	startedAt := time.Now()
+
+	// The player and the visualization
+	for _, client := range clients[:2] {
+		for _, expectedSeconds := range []int{2, 1} {
+			msg, err := netorcaitest.WaitReadMessage(client, 1500)
+			assert.NoError(t, err, "Cannot read COUNTDOWN")
+			messageType, _ := netorcai.ReadString(msg, "message_type")
+			assert.Equal(t, "COUNTDOWN", messageType, "Unexpected message")
+			seconds, err := netorcai.ReadInt(msg, "seconds_before_game_starts")
+			assert.NoError(t, err, "Cannot read seconds_before_game_starts")
+			assert.Equal(t, expectedSeconds, seconds,
+				"Unexpected seconds_before_game_starts")
+		}
+
+		msg, err := netorcaitest.WaitReadMessage(client, 1500)
+		assert.NoError(t, err, "Cannot read GAME_STARTS")
+		messageType, _ := netorcai.ReadString(msg, "message_type")
+		assert.Equal(t, "GAME_STARTS", messageType, "Unexpected message")
+	}
+	assert.True(t, time.Since(startedAt) >= 1900*time.Millisecond,
+		"GAME_STARTS sent before the end of the countdown")
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestDumpStatesGLOnly(t *testing.T) {
+	dumpDir, err := ioutil.TempDir("", "netorcai-dump-states")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dumpDir)
+
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
+			"--delay-turns=500", "--dump-states=" + dumpDir}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// Disconnect all players and visus
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	// Run a game client
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// Start the game
+	proc.InputControl <- "start"
+
+	// Wait for game end
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Turn durations.*gl compute p99`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Turn duration percentiles not logged")
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	for _, filename := range []string{"game.json", "turn_0000.json", "turn_0001.json"} {
+		_, err = os.Stat(filepath.Join(dumpDir, filename))
+		assert.NoError(t, err, "Game state not dumped in %v", filename)
+	}
+
+	_, err = os.Stat(filepath.Join(dumpDir, "report.json"))
+	assert.NoError(t, err, "Game report not written")
+}
@@ -0,0 +1,44 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func checkTurnEchoedActions(t *testing.T, msg map[string]interface{},
+	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
+	turnNumber := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	if isPlayer || expectedTurnNumber < 1 {
+		_, exists := msg["player_actions"]
+		assert.False(t, exists, "Unexpected 'player_actions' in TURN %v", expectedTurnNumber)
+	} else {
+		// The player answered the previous TURN
+		actions, err := netorcai.ReadArray(msg, "player_actions")
+		assert.NoError(t, err, "Cannot read 'player_actions'")
+		assert.Equal(t, 1, len(actions), "Unexpected 'player_actions' length")
+		if len(actions) == 1 {
+			obj := actions[0].(map[string]interface{})
+			turn, err := netorcai.ReadInt(obj, "turn_number")
+			assert.NoError(t, err, "Cannot read 'turn_number'")
+			assert.Equal(t, expectedTurnNumber-1, turn, "Unexpected 'turn_number' value")
+		}
+	}
+	return turnNumber
+}
+
+func TestEchoActionsToVisus(t *testing.T) {
+	subtestHelloGlActiveClients(t, []string{"--echo-actions-to-visus"}, 1, 0, 1,
+		3, 3, 3, 3,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnEchoedActions, checkTurnEchoedActions,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`))
+}
@@ -0,0 +1,43 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestReportEncoding(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=50", "--report-encoding"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+	for _, codec := range []string{"json", "deflate"} {
+		_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+			`Turn encoding cost.*codec=`+codec+` .*encoded bytes=\d+`),
+			proc.OutputControl, 1000, false)
+		assert.NoError(t, err, "No %v encoding cost", codec)
+	}
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`Encoding report.*codec=deflate .*ratio=`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "No encoding report")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
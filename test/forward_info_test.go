@@ -3,6 +3,7 @@ package test
 import (
 	"fmt"
 	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
@@ -86,7 +87,7 @@ func subCheckFlattenedObject(t *testing.T, object map[string]interface{}) {
 	assert.NoError(t, err, "Cannot read 'integer' field in obj")
 	assert.Equal(t, 42, i, "Unexpected value for 'integer' field in obj")
 
-	f, err := readFloat(object, "float")
+	f, err := netorcaitest.ReadFloat(object, "float")
 	assert.NoError(t, err, "Cannot read 'float' field in obj")
 	assert.Equal(t, 0.5, f, "Unexpected value for 'float' field in obj")
 
@@ -98,7 +99,7 @@ func subCheckFlattenedObject(t *testing.T, object map[string]interface{}) {
 	assert.NoError(t, err, "Cannot read 'array' field in obj")
 	assert.Equal(t, 0, len(a), "Unexpected length for 'array' field in obj")
 
-	b, err := readBool(object, "bool")
+	b, err := netorcaitest.ReadBool(object, "bool")
 	assert.NoError(t, err, "Cannot read 'bool' field in obj")
 	assert.Equal(t, true, b, "Unexpected value for 'bool' field in obj")
 }
@@ -106,7 +107,7 @@ func subCheckFlattenedObject(t *testing.T, object map[string]interface{}) {
 func checkGameStartsFlattened(t *testing.T,
 	msg map[string]interface{}, nbPlayers, nbSpecialPlayers, nbTurnsGL int,
 	msBeforeFirstTurn, msBetweenTurns float64, isPlayer bool) int {
-	playerID := checkGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
+	playerID := netorcaitest.CheckGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
 		msBeforeFirstTurn, msBetweenTurns, isPlayer)
 
 	initialGS, err := netorcai.ReadObject(msg, "initial_game_state")
@@ -119,7 +120,7 @@ func checkGameStartsFlattened(t *testing.T,
 func checkGameStartsNastyNested(t *testing.T,
 	msg map[string]interface{}, nbPlayers, nbSpecialPlayers, nbTurnsGL int,
 	msBeforeFirstTurn, msBetweenTurns float64, isPlayer bool) int {
-	playerID := checkGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
+	playerID := netorcaitest.CheckGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
 		msBeforeFirstTurn, msBetweenTurns, isPlayer)
 
 	initialGS, err := netorcai.ReadObject(msg, "initial_game_state")
@@ -157,10 +158,10 @@ func TestForwardInitialGameStateFlattened(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		checkGameStartsFlattened, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckFlattened, DefaultHelloGlDoTurnAck,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		checkGameStartsFlattened, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckFlattened, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -171,10 +172,10 @@ func TestForwardInitialGameStateNastyNested(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		checkGameStartsNastyNested, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckNastyNested, DefaultHelloGlDoTurnAck,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		checkGameStartsNastyNested, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckNastyNested, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -253,7 +254,7 @@ func doTurnAckNastyNested(turn int, actions []interface{}) string {
 
 func checkTurnFlattened(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
-	turn := checkTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	turn := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
 
 	gs, err := netorcai.ReadObject(msg, "game_state")
 	assert.NoError(t, err, "Cannot read 'game_state' in msg")
@@ -264,7 +265,7 @@ func checkTurnFlattened(t *testing.T, msg map[string]interface{},
 
 func checkTurnNastyNested(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
-	turn := checkTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	turn := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
 
 	gs, err := netorcai.ReadObject(msg, "game_state")
 	assert.NoError(t, err, "Cannot read 'game_state' in msg")
@@ -301,10 +302,10 @@ func TestForwardGameStateFlattened(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, checkTurnFlattened, checkTurnFlattened,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckFlattened,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnFlattened, checkTurnFlattened,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckFlattened,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -315,10 +316,10 @@ func TestForwardGameStateNastyNested(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, checkTurnNastyNested, checkTurnNastyNested,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckNastyNested,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnNastyNested, checkTurnNastyNested,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckNastyNested,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -403,7 +404,7 @@ func turnAckNastyNested(turn, playerID int) string {
 
 func checkDoTurnFlattened(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int) []interface{} {
-	pActions := checkDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
+	pActions := netorcaitest.CheckDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
 
 	if expectedTurnNumber >= 0 {
 		assert.Equal(t, expectedNbPlayers, len(pActions),
@@ -443,7 +444,7 @@ func checkDoTurnFlattened(t *testing.T, msg map[string]interface{},
 
 func checkDoTurnNastyNested(t *testing.T, msg map[string]interface{},
 	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int) []interface{} {
-	pActions := checkDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
+	pActions := netorcaitest.CheckDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
 
 	if expectedTurnNumber >= 0 {
 		assert.Equal(t, expectedNbPlayers, len(pActions),
@@ -512,10 +513,10 @@ func TestForwardActionsFlattened(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, checkDoTurnFlattened,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckFlattened, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, checkDoTurnFlattened,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckFlattened, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -526,10 +527,10 @@ func TestForwardActionsNastyNested(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, checkDoTurnNastyNested,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckNastyNested, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, checkDoTurnNastyNested,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNastyNested, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
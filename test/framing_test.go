@@ -0,0 +1,109 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Logs a client in, asking for the v2 frame format.
+func connectClientFramingV2(t *testing.T, role string) *client.Client {
+	c := &client.Client{}
+	err := c.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	err = c.SendString(`{"message_type":"LOGIN", "role":"` + role + `", ` +
+		`"nickname":"v2", "metaprotocol_version":"` + netorcai.Version + `", ` +
+		`"framing":2}`)
+	assert.NoError(t, err, "Cannot send LOGIN")
+
+	// LOGIN_ACK is a v1 frame
+	msg, err := netorcaitest.WaitReadMessage(c, 1000)
+	assert.NoError(t, err, "Cannot read LOGIN_ACK")
+	netorcaitest.CheckLoginAck(t, msg)
+	framing, err := netorcai.ReadInt(msg, "framing")
+	assert.NoError(t, err, "Cannot read 'framing'")
+	assert.Equal(t, 2, framing, "Unexpected 'framing'")
+
+	c.SetFraming(2)
+	return c
+}
+
+func TestFramingV2Game(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--delay-turns=50",
+			"--nb-turns-max=4", "--nb-players-max=2"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// v1 and v2 players play the same game
+	v2Player := connectClientFramingV2(t, "player")
+	mocks := []*netorcaitest.MockClient{{}, {}}
+	var results []chan mockResult
+	for index, c := range []*client.Client{playerClients[0], v2Player} {
+		mock, c := mocks[index], c
+		results = append(results, runMock(func() (string, error) {
+			return mock.Run(c)
+		}))
+	}
+	gl := &netorcaitest.MockGameLogic{}
+	results = append(results, runMock(func() (string, error) {
+		return gl.Run(glClients[0])
+	}))
+
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 3000, false)
+	assert.NoError(t, err, "Game did not finish")
+	for _, result := range results {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.Equal(t, len(mocks[0].Received), len(mocks[1].Received),
+		"v1 and v2 players received different messages")
+}
+
+func TestFramingV2ChunkedPing(t *testing.T) {
+	proc, _, _, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{}, 1000, 0, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	visu := connectClientFramingV2(t, "visualization")
+	err := visu.SendFrame([]byte(`{"message_type":`), client.FrameFlagChunked)
+	assert.NoError(t, err, "Cannot send frame")
+	err = visu.SendFrame([]byte(`"PING", "client_time":0}`), 0)
+	assert.NoError(t, err, "Cannot send frame")
+
+	msg, err := netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read PONG")
+	messageType, _ := netorcai.ReadString(msg, "message_type")
+	assert.Equal(t, "PONG", messageType, "Unexpected message")
+
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
+}
+
+func TestFramingV2ReservedFlags(t *testing.T) {
+	proc, _, _, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{}, 1000, 0, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player := connectClientFramingV2(t, "player")
+	err := player.SendFrame([]byte(`{"message_type":"PONG"}`), 0x80)
+	assert.NoError(t, err, "Cannot send frame")
+	netorcaitest.CheckAllKicked(t, []*client.Client{player},
+		regexp.MustCompile(`Reserved frame flags are set`), 1000)
+
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Reads GAME_STARTS and checks the GAME_STARTS_ACK timeout it tells.
+func readGameStartsAckTimeout(t *testing.T, player *client.Client,
+	expectedTimeout int) {
+	msg, err := netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read GAME_STARTS")
+	config, err := netorcai.ReadObject(msg, "server_config")
+	if assert.NoError(t, err, "Cannot read server_config in GAME_STARTS") {
+		timeout, err := netorcai.ReadInt(config,
+			"milliseconds_game_starts_ack_timeout")
+		assert.NoError(t, err, "Cannot read GAME_STARTS_ACK timeout")
+		assert.Equal(t, expectedTimeout, timeout,
+			"Unexpected GAME_STARTS_ACK timeout")
+	}
+}
+
+func TestGameStartsAckWaited(t *testing.T) {
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=50", "--game-starts-ack-timeout=3000"},
+		1000, 2, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+
+	proc.InputControl <- "start"
+	for _, player := range players {
+		readGameStartsAckTimeout(t, player, 3000)
+	}
+	gameStartsReceivedAt := time.Now()
+
+	// The first turn waits for the slow player
+	err := players[0].SendString(`{"message_type":"GAME_STARTS_ACK"}`)
+	assert.NoError(t, err, "Cannot send GAME_STARTS_ACK")
+	time.Sleep(500 * time.Millisecond)
+	err = players[1].SendString(`{"message_type":"GAME_STARTS_ACK"}`)
+	assert.NoError(t, err, "Cannot send GAME_STARTS_ACK")
+
+	for _, player := range players {
+		msg, err := netorcaitest.WaitReadMessage(player, 1000)
+		assert.NoError(t, err, "Cannot read TURN")
+		turnNumber, err := netorcai.ReadInt(msg, "turn_number")
+		assert.NoError(t, err, "Cannot read turn_number in TURN")
+		assert.Equal(t, 0, turnNumber, "Unexpected turn_number")
+	}
+	assert.True(t, time.Since(gameStartsReceivedAt) >= 450*time.Millisecond,
+		"First turn did not wait for GAME_STARTS_ACK")
+}
+
+func TestGameStartsAckTimeout(t *testing.T) {
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=50", "--fast", "--game-starts-ack-timeout=300"},
+		1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+
+	// The player never acknowledges GAME_STARTS: It is only reported
+	proc.InputControl <- "start"
+	readGameStartsAckTimeout(t, players[0], 300)
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`did not send GAME_STARTS_ACK in time`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Missing GAME_STARTS_ACK not reported")
+
+	msg, err := netorcaitest.WaitReadMessage(players[0], 1000)
+	assert.NoError(t, err, "Cannot read TURN")
+	turnNumber, err := netorcai.ReadInt(msg, "turn_number")
+	assert.NoError(t, err, "Cannot read turn_number in TURN")
+	assert.Equal(t, 0, turnNumber, "Unexpected turn_number")
+
+	// A late GAME_STARTS_ACK is ignored
+	err = players[0].SendString(`{"message_type":"GAME_STARTS_ACK"}`)
+	assert.NoError(t, err, "Cannot send GAME_STARTS_ACK")
+	err = players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(0, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Game did not finish")
+}
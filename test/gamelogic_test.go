@@ -0,0 +1,324 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/gamelogic"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Counts the turns and makes player 0 win at the last turn.
+type counterGame struct {
+	turn       int
+	nbTurnsMax int
+}
+
+func (g *counterGame) Init(nbPlayers, nbSpecialPlayers, nbTurnsMax int) gamelogic.State {
+	g.nbTurnsMax = nbTurnsMax
+	return gamelogic.State{"counter": g.turn}
+}
+
+func (g *counterGame) Turn(actions []gamelogic.PlayerActions) (gamelogic.State, gamelogic.Winner) {
+	g.turn++
+	if g.turn == g.nbTurnsMax {
+		return gamelogic.State{"counter": g.turn}, 0
+	}
+	return gamelogic.State{"counter": g.turn}, gamelogic.NoWinner
+}
+
+// A counter game that draws one (fake) random number per call.
+type seededCounterGame struct {
+	counterGame
+	seed int64
+}
+
+func (g *seededCounterGame) Seed(seed int64) {
+	g.seed = seed
+}
+
+func (g *seededCounterGame) RandomDraws() []interface{} {
+	return []interface{}{g.seed + int64(g.turn)}
+}
+
+// A counter game that takes longer than the delay between turns.
+type slowCounterGame struct {
+	counterGame
+}
+
+func (g *slowCounterGame) Turn(actions []gamelogic.PlayerActions) (gamelogic.State, gamelogic.Winner) {
+	time.Sleep(100 * time.Millisecond)
+	return g.counterGame.Turn(actions)
+}
+
+func checkTurnCounter(t *testing.T, msg map[string]interface{},
+	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
+	turnNumber := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	gameState, err := netorcai.ReadObject(msg, "game_state")
+	assert.NoError(t, err, "Cannot read 'game_state'")
+	counter, err := netorcai.ReadInt(gameState, "counter")
+	assert.NoError(t, err, "Cannot read 'counter' in game_state")
+	assert.Equal(t, expectedTurnNumber+1, counter, "Unexpected counter")
+	return turnNumber
+}
+
+func TestGameLogicSDK(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--delay-first-turn=500", "--nb-turns-max=3",
+		"--nb-players-max=1", "--nb-splayers-max=0", "--nb-visus-max=0",
+		"--delay-turns=500", "--debug", "--json-logs", "--autostart"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player, err := netorcaitest.ConnectClient(t, "player", "player", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect client")
+
+	glResult := make(chan error)
+	go func() {
+		kickReason, err := gamelogic.Run(&counterGame{}, "localhost", 4242, "counter")
+		if err == nil && kickReason != "Game is finished" {
+			err = fmt.Errorf("Unexpected kick reason: %v", kickReason)
+		}
+		glResult <- err
+	}()
+
+	go netorcaitest.HelloClient(t, player, "Player0",
+		1, 0, 3, 3, 0, 500, 500, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnCounter,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game is finished`), proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	assert.NoError(t, <-glResult, "Game logic failed")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestGameLogicSDKSeed(t *testing.T) {
+	dumpDir, err := ioutil.TempDir("", "netorcai-seed")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dumpDir)
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--delay-first-turn=50", "--nb-turns-max=2",
+		"--nb-players-max=1", "--nb-splayers-max=0", "--nb-visus-max=0",
+		"--delay-turns=50", "--json-logs", "--autostart", "--seed=42",
+		"--dump-states=" + dumpDir})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player, err := netorcaitest.ConnectClient(t, "player", "player", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect client")
+
+	go gamelogic.Run(&seededCounterGame{}, "localhost", 4242, "counter")
+	go netorcaitest.HelloClient(t, player, "Player0",
+		1, 0, 2, 2, 0, 50, 50, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnCounter,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Seed handed to game logic.*"seed":42`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Seed not logged")
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Random draws reported by game logic.*"random draws":"\[43\]"`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Random draws not logged")
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game is finished`), proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	// The seed and the draws are recorded
+	var game, turn map[string]interface{}
+	content, err := ioutil.ReadFile(filepath.Join(dumpDir, "game.json"))
+	assert.NoError(t, err, "Cannot read game.json")
+	json.Unmarshal(content, &game)
+	assert.Equal(t, 42.0, game["seed"])
+	assert.Equal(t, []interface{}{42.0}, game["initial_random_draws"])
+
+	content, err = ioutil.ReadFile(filepath.Join(dumpDir, "turn_0001.json"))
+	assert.NoError(t, err, "Cannot read turn_0001.json")
+	json.Unmarshal(content, &turn)
+	assert.Equal(t, []interface{}{44.0}, turn["random_draws"])
+}
+
+func TestSlowGameLogicWarning(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--delay-first-turn=50", "--nb-turns-max=3",
+		"--nb-players-max=1", "--nb-splayers-max=0", "--nb-visus-max=0",
+		"--delay-turns=50", "--json-logs", "--autostart"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player, err := netorcaitest.ConnectClient(t, "player", "player", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect client")
+
+	go gamelogic.Run(&slowCounterGame{}, "localhost", 4242, "counter")
+	go netorcaitest.HelloClient(t, player, "Player0",
+		1, 0, 3, 3, 0, 50, 50, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnCounter,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// The warning is only logged once, with a suggested delay
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game logic is the bottleneck.*"slow turns":1,"suggested delay turns \(ms\)":1[0-9]{2},`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Slow game logic not reported")
+	line, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game logic is the bottleneck|Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	assert.Contains(t, line, "Game is finished", "Slow game logic reported twice")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+// A counter game that records the departures of players.
+type departureCounterGame struct {
+	counterGame
+	departures []string
+}
+
+func (g *departureCounterGame) PlayerLeft(playerID, turnNumber int, reason string) {
+	g.departures = append(g.departures,
+		fmt.Sprintf("player=%v turn=%v reason=%v", playerID, turnNumber, reason))
+}
+
+func TestGameLogicSDKPlayerLeft(t *testing.T) {
+	dumpDir, err := ioutil.TempDir("", "netorcai-left")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dumpDir)
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--delay-first-turn=200", "--nb-turns-max=4",
+		"--nb-players-max=1", "--nb-splayers-max=0", "--nb-visus-max=0",
+		"--delay-turns=200", "--json-logs", "--autostart",
+		"--dump-states=" + dumpDir})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player, err := netorcaitest.ConnectClient(t, "player", "player", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect client")
+
+	game := &departureCounterGame{}
+	glResult := make(chan error)
+	go func() {
+		_, err := gamelogic.Run(game, "localhost", 4242, "counter")
+		glResult <- err
+	}()
+
+	// The player leaves once it has received the first TURN
+	msg, err := netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read GAME_STARTS")
+	netorcaitest.CheckGameStarts(t, msg, 1, 0, 4, 200, 200, true)
+	msg, err = netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read TURN")
+	checkTurnCounter(t, msg, 1, 0, 0, true)
+	player.Disconnect()
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game is finished`), proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	assert.NoError(t, <-glResult, "Game logic failed")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.Equal(t, []string{"player=0 turn=0 reason=disconnect"},
+		game.departures)
+
+	// The departure is recorded with the turn it precedes
+	nbRecorded := 0
+	filenames, _ := filepath.Glob(filepath.Join(dumpDir, "turn_*.json"))
+	for _, filename := range filenames {
+		var turn map[string]interface{}
+		content, err := ioutil.ReadFile(filename)
+		assert.NoError(t, err, "Cannot read %v", filename)
+		json.Unmarshal(content, &turn)
+		if playersLeft, exists := turn["players_left"]; exists {
+			nbRecorded++
+			assert.Equal(t, []interface{}{map[string]interface{}{
+				"message_type": "PLAYER_LEFT", "player_id": 0.0,
+				"turn_number": 0.0, "reason": "disconnect"}}, playersLeft)
+		}
+	}
+	assert.Equal(t, 1, nbRecorded, "Departure not recorded once")
+}
+
+// A counter game that records the changes of its visualizations.
+type visuCounterGame struct {
+	counterGame
+	nbVisus      int
+	joined, left []string
+	firstChange  string
+}
+
+func (g *visuCounterGame) VisusChanged(nbVisus int, joined, left []string) {
+	if g.firstChange == "" {
+		g.firstChange = fmt.Sprintf("nb=%v joined=%v left=%v", nbVisus,
+			joined, left)
+	}
+	g.nbVisus = nbVisus
+	g.joined = append(g.joined, joined...)
+	g.left = append(g.left, left...)
+}
+
+func TestGameLogicSDKVisusChanged(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--delay-first-turn=200", "--nb-turns-max=5",
+		"--nb-players-max=1", "--nb-splayers-max=0", "--nb-visus-max=2",
+		"--delay-turns=200", "--json-logs"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player, err := netorcaitest.ConnectClient(t, "player", "player", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect client")
+	visu1, err := netorcaitest.ConnectClient(t, "visualization", "visu1", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect visu1")
+
+	game := &visuCounterGame{}
+	glResult := make(chan error)
+	go func() {
+		_, err := gamelogic.Run(game, "localhost", 4242, "counter")
+		glResult <- err
+	}()
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game logic accepted`), proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Game logic not accepted")
+	proc.InputControl <- "start"
+	go netorcaitest.HelloClient(t, player, "Player0",
+		1, 0, 5, 5, 0, 200, 200, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnCounter,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// visu2 joins and visu1 leaves once the first TURN is received
+	msg, err := netorcaitest.WaitReadMessage(visu1, 1000)
+	assert.NoError(t, err, "Cannot read GAME_STARTS")
+	netorcaitest.CheckGameStarts(t, msg, 1, 0, 5, 200, 200, false)
+	msg, err = netorcaitest.WaitReadMessage(visu1, 1000)
+	assert.NoError(t, err, "Cannot read TURN")
+	checkTurnCounter(t, msg, 1, 0, 0, false)
+
+	visu2, err := netorcaitest.ConnectClient(t, "visualization", "visu2", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect visu2")
+	defer visu2.Disconnect()
+	visu1.Disconnect()
+
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Game is finished`), proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	assert.NoError(t, <-glResult, "Game logic failed")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	assert.Equal(t, "nb=1 joined=[visu1] left=[]", game.firstChange)
+	assert.Equal(t, 1, game.nbVisus, "Unexpected final number of visus")
+	assert.Equal(t, []string{"visu1", "visu2"}, game.joined)
+	assert.Equal(t, []string{"visu1"}, game.left)
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+// Runs a whole game with the given game logic, checking that the player and
+// the visu connected before the game logic swap are still there.
+func subtestHotSwapGame(t *testing.T, proc *netorcaitest.NetorcaiProcess,
+	player, visu, gl *client.Client) {
+	go netorcaitest.HelloGameLogic(t, gl, 1, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	go netorcaitest.HelloClient(t, player, "Player", 1, 0, 2, 2, 0, 500, 500, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	go netorcaitest.HelloClient(t, visu, "Visu", 1, 0, 2, 2, 0, 500, 500, false, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	_, err = netorcaitest.WaitCompletionTimeout(proc.Completion, 2000)
+	assert.NoError(t, err, "netorcai did not complete")
+}
+
+func TestGLHotSwapReplace(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
+			"--delay-turns=500", "--gl-hot-swap"}, 1000, 1, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// A new game logic replaces the current one
+	newGL, err := netorcaitest.ConnectClient(t, "game logic", "new_gl",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect new game logic")
+
+	msg, err := netorcaitest.WaitReadMessage(gl[0], 1000)
+	assert.NoError(t, err, "Cannot read previous game logic message (KICK)")
+	netorcaitest.CheckKick(t, msg, "GameLogic",
+		regexp.MustCompile(`Replaced by a new game logic`))
+	netorcaitest.CheckKickCode(t, msg, "GameLogic", netorcai.KICK_REPLACED)
+	gl[0].Disconnect()
+
+	subtestHotSwapGame(t, proc, players[0], visus[0], newGL)
+}
+
+func TestGLHotSwapLeave(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
+			"--delay-turns=500", "--gl-hot-swap"}, 1000, 1, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// netorcai should not abort when the game logic leaves
+	gl[0].Disconnect()
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Waiting for a new one`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "netorcai did not wait for a new game logic")
+
+	newGL, err := netorcaitest.ConnectClient(t, "game logic", "new_gl",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect new game logic")
+
+	subtestHotSwapGame(t, proc, players[0], visus[0], newGL)
+}
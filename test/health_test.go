@@ -0,0 +1,118 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func getHealthStatus(t *testing.T, url string) (int, map[string]interface{}) {
+	var resp *http.Response
+	var err error
+	for i := 0; i < 10; i++ {
+		resp, err = http.Get(url)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !assert.NoError(t, err, "Cannot query health endpoint") {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	var status map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	assert.NoError(t, err, "Cannot decode health status")
+	return resp.StatusCode, status
+}
+
+func TestHealthProbes(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{"--admin-port=4243"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	code, status := getHealthStatus(t, "http://localhost:4243/healthz")
+	assert.Equal(t, http.StatusOK, code, "Unexpected /healthz status code")
+	assert.Equal(t, true, status["listening"], "Unexpected listening")
+
+	code, status = getHealthStatus(t, "http://localhost:4243/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code,
+		"Unexpected /readyz status code without game logic")
+	assert.Equal(t, false, status["game_logic_connected"],
+		"Unexpected game_logic_connected")
+	assert.Equal(t, "not running", status["game_state"], "Unexpected game_state")
+
+	_, err := netorcaitest.ConnectClient(t, "game logic", "game_logic", netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect game logic")
+
+	code, status = getHealthStatus(t, "http://localhost:4243/readyz")
+	assert.Equal(t, http.StatusOK, code,
+		"Unexpected /readyz status code with game logic")
+	assert.Equal(t, true, status["game_logic_connected"],
+		"Unexpected game_logic_connected")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestWebVisu(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{"--admin-port=4243"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < 10; i++ {
+		resp, err = http.Get("http://localhost:4243/")
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if assert.NoError(t, err, "Cannot query web visualization") {
+		content, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err, "Cannot read web visualization")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(content), `new EventSource("/turns")`,
+			"The web visualization should follow the stream of turns")
+	}
+
+	resp, err = http.Get("http://localhost:4243/unknown")
+	if assert.NoError(t, err, "Cannot query unknown page") {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestMetrics(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{"--admin-port=4243"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < 10; i++ {
+		resp, err = http.Get("http://localhost:4243/metrics")
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if assert.NoError(t, err, "Cannot query metrics endpoint") {
+		content, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err, "Cannot read metrics")
+		assert.Contains(t, string(content), "netorcai_turns_total 0",
+			"Unexpected metrics")
+	}
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
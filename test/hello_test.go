@@ -3,202 +3,204 @@ package test
 import (
 	"fmt"
 	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
+	"strings"
 	"testing"
 )
 
 func TestHelloGLOnly(t *testing.T) {
-	proc, _, players, _, visus, gl := runNetorcaiAndAllClients(
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
 			"--delay-turns=500", "--debug"}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Disconnect all players
 	for _, player := range players {
 		player.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Disconnect all visus
 	for _, visu := range visus {
 		visu.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], 0, 0, 2, 2, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Start the game
-	proc.inputControl <- "start"
+	proc.InputControl <- "start"
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestHelloGLIdleClients(t *testing.T) {
-	proc, _, _, _, _, gl := runNetorcaiAndAllClients(
+	proc, _, _, _, _, gl := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
 			"--delay-turns=500", "--debug"}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], 4, 0, 2, 2, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], 4, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Start the game
-	proc.inputControl <- "start"
+	proc.InputControl <- "start"
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestHelloGLIdleClientsSpecial(t *testing.T) {
-	proc, _, _, _, _, gl := runNetorcaiAndAllClients(
+	proc, _, _, _, _, gl := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
 			"--delay-turns=500", "--debug", "--nb-splayers-max=1"}, 1000, 1)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], 4, 1, 2, 2, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], 4, 1, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Start the game
-	proc.inputControl <- "start"
+	proc.InputControl <- "start"
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestHelloGLActiveVisu(t *testing.T) {
-	proc, _, players, _, visus, gl := runNetorcaiAndAllClients(
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--delay-first-turn=500", "--nb-turns-max=3",
 			"--delay-turns=500", "--debug", "--json-logs"}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], 0, 0, 3, 3, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Disconnect players
 	for _, player := range players {
 		player.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Run visu clients
 	for visuID, visu := range visus {
-		go helloClient(t, visu, fmt.Sprintf("Visu%v", visuID),
+		go netorcaitest.HelloClient(t, visu, fmt.Sprintf("Visu%v", visuID),
 			0, 0, 3, 3, 0, 500, 500, false, false, true, true,
-			DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn,
-			DefaultHelloClientCheckGameEnds,
-			DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+			netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+			netorcaitest.DefaultHelloClientCheckGameEnds,
+			netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
 	}
 
 	// Start the game
-	proc.inputControl <- "start"
+	proc.InputControl <- "start"
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestHelloGLActivePlayer(t *testing.T) {
-	proc, _, players, _, visus, gl := runNetorcaiAndAllClients(
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--delay-first-turn=500", "--nb-turns-max=3",
 			"--delay-turns=500", "--debug", "--json-logs"}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], 1, 0, 3, 3, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], 1, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Run an active player
-	go helloClient(t, players[0], "Player0", 1, 0, 3, 3, 0, 500, 500, true, false, true, true,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds,
-		DefaultHelloClientTurnAck,
+	go netorcaitest.HelloClient(t, players[0], "Player0", 1, 0, 3, 3, 0, 500, 500, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Disconnect other players
 	for _, player := range players[1:] {
 		player.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Disconnect visus
 	for _, visu := range visus {
 		visu.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Start the game
-	proc.inputControl <- "start"
+	proc.InputControl <- "start"
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestHelloGLActiveSpecialPlayer(t *testing.T) {
-	proc, _, players, specialPlayers, visus, gl := runNetorcaiAndAllClients(
+	proc, _, players, specialPlayers, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--delay-first-turn=500", "--nb-turns-max=3",
 			"--delay-turns=500", "--debug", "--json-logs",
 			"--nb-splayers-max=1"}, 1000, 1)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], 0, 1, 3, 3, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 1, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Run an active special special
-	go helloClient(t, specialPlayers[0], "SpecialPlayer0", 0, 1, 3, 3, 0, 500, 500, true, false, true, true,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds,
-		DefaultHelloClientTurnAck,
+	go netorcaitest.HelloClient(t, specialPlayers[0], "SpecialPlayer0", 0, 1, 3, 3, 0, 500, 500, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Disconnect classical players
 	for _, player := range players {
 		player.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Disconnect visus
 	for _, visu := range visus {
 		visu.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	// Start the game
-	proc.inputControl <- "start"
+	proc.InputControl <- "start"
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func subtestHelloGlActiveClients(t *testing.T,
@@ -207,15 +209,15 @@ func subtestHelloGlActiveClients(t *testing.T,
 	nbTurnsNetorcai, nbTurnsGL, nbTurnsPlayer, nbTurnsVisu int,
 	nbTurnsToSkipPlayer, nbTurnsToSkipVisu int,
 	allowTurnSkipPlayer, allowTurnSkipVisu bool,
-	checkGameStartsFunc ClientGameStartsCheckFunc,
-	playerCheckTurnFunc, visuCheckTurnFunc ClientTurnCheckFunc,
-	checkGameEndsFunc ClientGameEndsCheckFunc,
-	checkDoTurnFunc GLCheckDoTurnFunc,
-	doInitAckFunc GLDoInitAckFunc, doTurnAckFunc GLDoTurnAckFunc,
-	playerTurnAckFunc, visuTurnAckFunc ClientTurnAckFunc,
+	checkGameStartsFunc netorcaitest.ClientGameStartsCheckFunc,
+	playerCheckTurnFunc, visuCheckTurnFunc netorcaitest.ClientTurnCheckFunc,
+	checkGameEndsFunc netorcaitest.ClientGameEndsCheckFunc,
+	checkDoTurnFunc netorcaitest.GLCheckDoTurnFunc,
+	doInitAckFunc netorcaitest.GLDoInitAckFunc, doTurnAckFunc netorcaitest.GLDoTurnAckFunc,
+	playerTurnAckFunc, visuTurnAckFunc netorcaitest.ClientTurnAckFunc,
 	glKickReasonMatcher, playerKickReasonMatcher,
 	visuKickReasonMatcher *regexp.Regexp) {
-	proc, _, players, specialPlayers, visus, gl := runNetorcaiAndClients(
+	proc, _, players, specialPlayers, visus, gl := netorcaitest.RunNetorcaiAndClients(
 		t, append([]string{"--delay-first-turn=500",
 			fmt.Sprintf("--nb-turns-max=%v", nbTurnsNetorcai),
 			fmt.Sprintf("--nb-players-max=%v", nbPlayers),
@@ -224,16 +226,16 @@ func subtestHelloGlActiveClients(t *testing.T,
 			"--delay-turns=500", "--debug", "--json-logs", "--autostart"},
 			netorcaiAdditionalArgs...),
 		1000, nbPlayers, nbSpecialPlayers, nbVisus)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], nbPlayers, nbSpecialPlayers, nbTurnsNetorcai, nbTurnsGL,
+	go netorcaitest.HelloGameLogic(t, gl[0], nbPlayers, nbSpecialPlayers, nbTurnsNetorcai, nbTurnsGL,
 		checkDoTurnFunc, doInitAckFunc, doTurnAckFunc,
 		glKickReasonMatcher)
 
 	// Run player clients
 	for playerID, player := range players {
-		go helloClient(t, player, fmt.Sprintf("Player%v", playerID),
+		go netorcaitest.HelloClient(t, player, fmt.Sprintf("Player%v", playerID),
 			nbPlayers, nbSpecialPlayers, nbTurnsNetorcai, nbTurnsPlayer,
 			nbTurnsToSkipPlayer, 500, 500, true, allowTurnSkipPlayer,
 			nbTurnsPlayer == nbTurnsNetorcai, nbTurnsGL > 0,
@@ -243,7 +245,7 @@ func subtestHelloGlActiveClients(t *testing.T,
 
 	// Run special player clients
 	for splayerID, splayer := range specialPlayers {
-		go helloClient(t, splayer, fmt.Sprintf("SpecialPlayer%v", splayerID),
+		go netorcaitest.HelloClient(t, splayer, fmt.Sprintf("SpecialPlayer%v", splayerID),
 			nbPlayers, nbSpecialPlayers, nbTurnsNetorcai, nbTurnsPlayer,
 			nbTurnsToSkipPlayer, 500, 500, true, allowTurnSkipPlayer,
 			nbTurnsPlayer == nbTurnsNetorcai, nbTurnsGL > 0,
@@ -253,7 +255,7 @@ func subtestHelloGlActiveClients(t *testing.T,
 
 	// Run visu clients
 	for visuID, visu := range visus {
-		go helloClient(t, visu, fmt.Sprintf("Visu%v", visuID),
+		go netorcaitest.HelloClient(t, visu, fmt.Sprintf("Visu%v", visuID),
 			nbPlayers, nbSpecialPlayers, nbTurnsNetorcai, nbTurnsVisu,
 			nbTurnsToSkipVisu, 500, 500, false, allowTurnSkipVisu,
 			nbTurnsVisu == nbTurnsNetorcai, nbTurnsGL > 0,
@@ -262,9 +264,9 @@ func subtestHelloGlActiveClients(t *testing.T,
 	}
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestHelloGLActiveClients(t *testing.T) {
@@ -272,10 +274,10 @@ func TestHelloGLActiveClients(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -286,10 +288,10 @@ func TestHelloGLActiveClientsFast(t *testing.T) {
 		1000, 1000, 1000, 1000,
 		0, 0,
 		false, true,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -300,10 +302,10 @@ func TestHelloGLActiveClientsSpecial(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -314,10 +316,10 @@ func TestHelloGLActiveClientsSpecialFast(t *testing.T) {
 		1000, 1000, 1000, 1000,
 		0, 0,
 		false, true,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -350,12 +352,12 @@ func TestInvalidDoInitAckNoMsgType(t *testing.T) {
 		3, 0, 1, 1,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckNoMsgType, DefaultHelloGlDoTurnAck,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckNoMsgType, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Invalid DO_INIT_ACK message. `+
-			`Field 'message_type' is missing`),
+			`message_type: missing, expected string`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -365,12 +367,12 @@ func TestInvalidDoInitAckNoInitialGameState(t *testing.T) {
 		3, 0, 1, 1,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckNoInitialGameState, DefaultHelloGlDoTurnAck,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckNoInitialGameState, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Invalid DO_INIT_ACK message. `+
-			`Field 'initial_game_state' is missing`),
+			`initial_game_state: missing, expected object`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -380,10 +382,10 @@ func TestInvalidDoInitAckBadMsgType(t *testing.T) {
 		3, 0, 1, 1,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckBadMsgType, DefaultHelloGlDoTurnAck,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckBadMsgType, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`DO_INIT_ACK was expected`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
@@ -394,11 +396,11 @@ func TestInvalidDoInitAckBadInitialGameStateNotObject(t *testing.T) {
 		3, 0, 1, 1,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckBadInitialGameStateNotObject, DefaultHelloGlDoTurnAck,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
-		regexp.MustCompile(`Non-object value for field 'initial_game_state'`),
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckBadInitialGameStateNotObject, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`initial_game_state: expected object, got number`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -408,11 +410,11 @@ func TestInvalidDoInitAckBadInitialGameStateNoAllClients(t *testing.T) {
 		3, 0, 1, 1,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		doInitAckBadInitialGameStateNoAllClients, DefaultHelloGlDoTurnAck,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
-		regexp.MustCompile(`Field 'all_clients' is missing`),
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckBadInitialGameStateNoAllClients, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`initial_game_state\.all_clients: missing, expected object`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -450,11 +452,11 @@ func TestInvalidDoTurnAckNoMsgType(t *testing.T) {
 		3, 1, 0, 0,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckNoMsgType,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
-		regexp.MustCompile(`Field 'message_type' is missing`),
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckNoMsgType,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`message_type: missing, expected string`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -464,10 +466,10 @@ func TestInvalidDoTurnAckBadMsgType(t *testing.T) {
 		3, 1, 0, 0,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckBadMsgType,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckBadMsgType,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`DO_TURN_ACK was expected`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
@@ -478,11 +480,11 @@ func TestInvalidDoTurnAckNoWinner(t *testing.T) {
 		3, 1, 0, 0,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckNoWinner,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
-		regexp.MustCompile(`Field 'winner_player_id' is missing`),
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckNoWinner,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`winner_player_id: missing, expected integer`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -492,11 +494,11 @@ func TestInvalidDoTurnAckNoGameState(t *testing.T) {
 		3, 1, 0, 0,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckNoGameState,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
-		regexp.MustCompile(`Field 'game_state' is missing`),
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckNoGameState,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`game_state: missing, expected object`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -506,11 +508,11 @@ func TestInvalidDoTurnAckNoAllClients(t *testing.T) {
 		3, 1, 0, 0,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckNoAllClients,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
-		regexp.MustCompile(`Field 'all_clients' is missing`),
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckNoAllClients,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`game_state\.all_clients: missing, expected object`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
@@ -520,15 +522,53 @@ func TestInvalidDoTurnAckBadWinner(t *testing.T) {
 		3, 1, 0, 0,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckBadWinner,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckBadWinner,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Invalid winner_player_id: Not in \[-1, 1\[`),
 		regexp.MustCompile(`netorcai abort`),
 		regexp.MustCompile(`netorcai abort`))
 }
 
+func doTurnAckBadPlayerMessagesKey(turn int, actions []interface{}) string {
+	return `{"message_type":"DO_TURN_ACK", "winner_player_id":-1,` +
+		`"game_state":{"all_clients":{}}, "player_messages":{"1":{}}}`
+}
+
+func doTurnAckBadPlayerMessagesValue(turn int, actions []interface{}) string {
+	return `{"message_type":"DO_TURN_ACK", "winner_player_id":-1,` +
+		`"game_state":{"all_clients":{}}, "player_messages":{"0":42}}`
+}
+
+func TestInvalidDoTurnAckBadPlayerMessagesKey(t *testing.T) {
+	subtestHelloGlActiveClients(t, nil, 1, 0, 1,
+		3, 1, 0, 0,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckBadPlayerMessagesKey,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Invalid player_messages key '1': Not a player ID in \[0, 1\[`),
+		regexp.MustCompile(`netorcai abort`),
+		regexp.MustCompile(`netorcai abort`))
+}
+
+func TestInvalidDoTurnAckBadPlayerMessagesValue(t *testing.T) {
+	subtestHelloGlActiveClients(t, nil, 1, 0, 1,
+		3, 1, 0, 0,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckBadPlayerMessagesValue,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`player_messages\.0: expected object, got number`),
+		regexp.MustCompile(`netorcai abort`),
+		regexp.MustCompile(`netorcai abort`))
+}
+
 // Invalid TURN_ACK
 func turnAckNoMsgType(turn, playerID int) string {
 	return fmt.Sprintf(`{"turn_number": %v, "actions": []}`, turn)
@@ -568,12 +608,12 @@ func TestInvalidTurnAckNoMsgType(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckNoMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
-		regexp.MustCompile(`Field 'message_type' is missing`),
+		regexp.MustCompile(`message_type: missing, expected string`),
 		regexp.MustCompile(`Game is finished`))
 }
 
@@ -582,12 +622,12 @@ func TestInvalidTurnAckNoTurnNumber(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckNoTurnNumber, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoTurnNumber, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
-		regexp.MustCompile(`Field 'turn_number' is missing`),
+		regexp.MustCompile(`turn_number: missing, expected integer`),
 		regexp.MustCompile(`Game is finished`))
 }
 
@@ -596,12 +636,12 @@ func TestInvalidTurnAckNoActions(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckNoActions, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoActions, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
-		regexp.MustCompile(`Field 'actions' is missing`),
+		regexp.MustCompile(`actions: missing, expected array`),
 		regexp.MustCompile(`Game is finished`))
 }
 
@@ -610,10 +650,10 @@ func TestInvalidTurnAckBadMsgType(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckBadMsgType, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckBadMsgType, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`TURN_ACK was expected`),
 		regexp.MustCompile(`Game is finished`))
@@ -624,10 +664,10 @@ func TestInvalidTurnAckBadTurnNumberValue(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckBadTurnNumberValue, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckBadTurnNumberValue, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Invalid value \(turn_number=1\)`),
 		regexp.MustCompile(`Game is finished`))
@@ -638,12 +678,54 @@ func TestInvalidTurnAckBadTurnNumberNotInt(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckBadTurnNumberNotInt, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckBadTurnNumberNotInt, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`turn_number: expected integer, got string`),
+		regexp.MustCompile(`Game is finished`))
+}
+
+// The array limit is exceeded long before the end of the message, which
+// the client must still be able to send entirely.
+func turnAckTooManyActions(turn, playerID int) string {
+	return fmt.Sprintf(`{"message_type": "TURN_ACK",
+		"turn_number": %v, "actions": [1%v]}`, turn,
+		strings.Repeat(", 1", 100000))
+}
+
+func turnAckTooDeep(turn, playerID int) string {
+	return fmt.Sprintf(`{"message_type": "TURN_ACK",
+		"turn_number": %v, "actions": [%v1%v]}`, turn,
+		strings.Repeat("[", 100), strings.Repeat("]", 100))
+}
+
+func TestInvalidTurnAckTooManyActions(t *testing.T) {
+	subtestHelloGlActiveClients(t, []string{"--max-array-length=3"}, 1, 0, 1,
+		3, 3, 2, 3,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckTooManyActions, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Array is too long: actions has more than 3 elements`),
+		regexp.MustCompile(`Game is finished`))
+}
+
+func TestInvalidTurnAckTooDeep(t *testing.T) {
+	subtestHelloGlActiveClients(t, nil, 1, 0, 1,
+		3, 3, 2, 3,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckTooDeep, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
-		regexp.MustCompile(`Non-integral value for field 'turn_number'`),
+		regexp.MustCompile(`Message is nested too deeply: actions(\[0\])+ is deeper than 64 levels`),
 		regexp.MustCompile(`Game is finished`))
 }
 
@@ -652,12 +734,12 @@ func TestInvalidTurnAckBadActions(t *testing.T) {
 		3, 3, 2, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		DefaultHelloClientCheckGameEnds, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckBadActions, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckBadActions, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
-		regexp.MustCompile(`Non-array value for field 'actions'`),
+		regexp.MustCompile(`actions: expected array, got object`),
 		regexp.MustCompile(`Game is finished`))
 }
 
@@ -669,7 +751,7 @@ func doTurnAckWinner(turn int, actions []interface{}) string {
 }
 
 func checkGameEndsWinner(t *testing.T, msg map[string]interface{}, clientName string) {
-	checkGameEnds(t, msg, clientName)
+	netorcaitest.CheckGameEnds(t, msg, clientName)
 
 	winner, err := netorcai.ReadInt(msg, "winner_player_id")
 	assert.NoError(t, err, "Cannot read 'winner_player_id'")
@@ -681,10 +763,10 @@ func TestHelloWinner(t *testing.T) {
 		3, 3, 3, 3,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, DefaultHelloClientCheckTurn, DefaultHelloClientCheckTurn,
-		checkGameEndsWinner, DefaultHelloGLCheckDoTurn,
-		DefaultHelloGLDoInitAck, doTurnAckWinner,
-		DefaultHelloClientTurnAck, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		checkGameEndsWinner, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckWinner,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
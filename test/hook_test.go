@@ -0,0 +1,75 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestHookCommand(t *testing.T) {
+	hookDir, err := ioutil.TempDir("", "netorcai-hook")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(hookDir)
+	eventsFile := filepath.Join(hookDir, "events.jsonl")
+
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=500", "--nb-turns-max=2",
+			"--delay-turns=500", "--hook-command=cat >> " + eventsFile}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// Disconnect all players and visus
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	// Run a game client
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// Start the game
+	proc.InputControl <- "start"
+
+	// Wait for game end. netorcai waits for the hooks before exiting.
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	_, err = netorcaitest.WaitCompletionTimeout(proc.Completion, 2000)
+	assert.NoError(t, err, "netorcai did not complete")
+
+	file, err := os.Open(eventsFile)
+	assert.NoError(t, err, "Hook command did not run")
+	defer file.Close()
+
+	var events []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		assert.NoError(t, err, "Hook event is not JSON")
+
+		eventName, err := netorcai.ReadString(event, "event")
+		assert.NoError(t, err, "Cannot read 'event'")
+		events = append(events, eventName)
+
+		_, err = netorcai.ReadArray(event, "players_info")
+		assert.NoError(t, err, "Cannot read 'players_info'")
+
+		if eventName == "game_ends" {
+			winner, err := netorcai.ReadInt(event, "winner_player_id")
+			assert.NoError(t, err, "Cannot read 'winner_player_id'")
+			assert.Equal(t, -1, winner, "Unexpected winner")
+		}
+	}
+	assert.ElementsMatch(t, []string{"game_starts", "game_ends"}, events,
+		"Unexpected hook events")
+}
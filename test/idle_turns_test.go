@@ -0,0 +1,82 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSkipIdleTurns(t *testing.T) {
+	subtestSkipIdleTurns(t, []string{"--fast"})
+}
+
+func TestSkipIdleTurnsTimers(t *testing.T) {
+	subtestSkipIdleTurns(t, []string{"--delay-turns=200"})
+}
+
+func subtestSkipIdleTurns(t *testing.T, args []string) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, append([]string{"--delay-first-turn=50", "--nb-turns-max=6",
+			"--autostart", "--nb-players-max=1", "--nb-splayers-max=0",
+			"--nb-visus-max=0", "--skip-idle-turns"}, args...), 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// The player only acts at turn 2
+	gl := &netorcaitest.MockGameLogic{}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+	player := &netorcaitest.MockClient{
+		TurnAck: func(turn, playerID int) string {
+			if turn == 2 {
+				return fmt.Sprintf(`{"message_type": "TURN_ACK",
+					"turn_number": %v, "actions": ["move"]}`, turn)
+			}
+			return netorcaitest.DefaultHelloClientTurnAck(turn, playerID)
+		},
+	}
+	playerResult := runMock(func() (string, error) {
+		return player.Run(playerClients[0])
+	})
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 3000, false)
+	assert.NoError(t, err, "Game did not finish")
+	for _, result := range []chan mockResult{glResult, playerResult} {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	// The player receives every turn...
+	nbTurns := 0
+	for _, msg := range player.Received {
+		if messageType, _ := netorcai.ReadString(msg, "message_type"); messageType == "TURN" {
+			nbTurns++
+		}
+	}
+	assert.Equal(t, 5, nbTurns, "Unexpected number of TURN")
+
+	// ... but the game logic only computes the first one and the one
+	// that follows the action
+	nbDoTurns := 0
+	for _, msg := range gl.Received {
+		if messageType, _ := netorcai.ReadString(msg, "message_type"); messageType == "DO_TURN" {
+			nbDoTurns++
+			actions, _ := netorcai.ReadArray(msg, "player_actions")
+			if nbDoTurns == 2 && assert.Len(t, actions, 1, "Missing action") {
+				assert.Equal(t, []interface{}{"move"},
+					actions[0].(map[string]interface{})["actions"],
+					"Unexpected action")
+			}
+		}
+	}
+	assert.Equal(t, 2, nbDoTurns, "Idle turns should not invoke the game logic")
+}
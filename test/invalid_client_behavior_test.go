@@ -2,192 +2,193 @@ package test
 
 import (
 	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
 )
 
 func TestInvalidGlMessageBeforeStart(t *testing.T) {
-	proc, _, playerClients, _, visuClients, glClients := runNetorcaiAndAllClients(
+	proc, _, playerClients, _, visuClients, glClients := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	glClients[0].SendString(`{}`)
-	checkAllKicked(t, glClients, regexp.MustCompile(
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(
 		`Received a game logic message but the game has not started`), 1000)
 
-	_, err := waitOutputTimeout(regexp.MustCompile(`Game logic failed`),
-		proc.outputControl, 1000, false)
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game logic failed`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err,
 		"Cannot read `Game logic failed` in netorcai output")
 
-	_, expRetCode := handleCoverage(t, 1)
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 
-	checkAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`),
+	netorcaitest.CheckAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`),
 		1000)
-	checkAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestInvalidPlayerMessageBeforeStart(t *testing.T) {
-	proc, _, playerClients, _, _, glClients := runNetorcaiAndClients(t,
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(t,
 		[]string{}, 1000, 1, 0, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	playerClients[0].SendString(`{"message_type": "TURN_ACK", ` +
 		`"turn_number": -1, "actions":[]}`)
-	checkAllKicked(t, playerClients, regexp.MustCompile(`Received a TURN_ACK `+
+	netorcaitest.CheckAllKicked(t, playerClients, regexp.MustCompile(`Received a TURN_ACK `+
 		`but the client state is not THINKING`), 1000)
 
-	proc.inputControl <- `quit`
-	waitCompletionTimeout(proc.completion, 1000)
-	checkAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestInvalidVisuMessageBeforeStart(t *testing.T) {
-	proc, _, _, _, visuClients, glClients := runNetorcaiAndClients(t,
+	proc, _, _, _, visuClients, glClients := netorcaitest.RunNetorcaiAndClients(t,
 		[]string{}, 1000, 0, 0, 1)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	visuClients[0].SendString(`{"message_type": "TURN_ACK", ` +
 		`"turn_number": -1, "actions":[]}`)
-	checkAllKicked(t, visuClients, regexp.MustCompile(`Received a TURN_ACK `+
+	netorcaitest.CheckAllKicked(t, visuClients, regexp.MustCompile(`Received a TURN_ACK `+
 		`but the client state is not THINKING`), 1000)
 
-	proc.inputControl <- `quit`
-	waitCompletionTimeout(proc.completion, 1000)
-	checkAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestInvalidGlNoDoInitAck(t *testing.T) {
-	proc, _, playerClients, _, visuClients, glClients := runNetorcaiAndAllClients(
+	proc, _, playerClients, _, visuClients, glClients := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	go func(glClient *client.Client) {
-		msg, err := waitReadMessage(glClient, 1000)
+		msg, err := netorcaitest.WaitReadMessage(glClient, 1000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_INIT)")
-		checkDoInit(t, msg, 4, 0, 100)
+		netorcaitest.CheckDoInit(t, msg, 4, 0, 100)
 
 		// Do not send DO_INIT_ACK on purpose
-		msg, err = waitReadMessage(glClient, 4000)
-		checkKick(t, msg, "GameLogic", regexp.MustCompile(`Did not receive DO_INIT_ACK after 3 seconds`))
+		msg, err = netorcaitest.WaitReadMessage(glClient, 4000)
+		netorcaitest.CheckKick(t, msg, "GameLogic", regexp.MustCompile(`Did not receive DO_INIT_ACK after 3 seconds`))
 	}(glClients[0])
 
-	proc.inputControl <- `start`
-	_, err := waitOutputTimeout(regexp.MustCompile(`Game logic failed`),
-		proc.outputControl, 4000, false)
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game logic failed`),
+		proc.OutputControl, 4000, false)
 	assert.NoError(t, err,
 		"Cannot read `Game logic failed` in netorcai output")
 
-	_, expRetCode := handleCoverage(t, 1)
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 
-	checkAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`),
+	netorcaitest.CheckAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`),
 		1000)
-	checkAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestInvalidGlNoDoInitAckSocketClosed(t *testing.T) {
-	proc, _, playerClients, _, visuClients, glClients := runNetorcaiAndAllClients(
+	proc, _, playerClients, _, visuClients, glClients := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	go func(glClient *client.Client) {
-		msg, err := waitReadMessage(glClient, 1000)
+		msg, err := netorcaitest.WaitReadMessage(glClient, 1000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_INIT)")
-		checkDoInit(t, msg, 4, 0, 100)
+		netorcaitest.CheckDoInit(t, msg, 4, 0, 100)
 
 		glClient.Disconnect()
 	}(glClients[0])
 
-	proc.inputControl <- `start`
-	_, err := waitOutputTimeout(regexp.MustCompile(`Game logic failed`),
-		proc.outputControl, 4000, false)
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game logic failed`),
+		proc.OutputControl, 4000, false)
 	assert.NoError(t, err,
 		"Cannot read `Game logic failed` in netorcai output")
 
-	_, expRetCode := handleCoverage(t, 1)
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 
-	checkAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`), 1000)
-	checkAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestInvalidGlNoDoTurnAckSocketClosed(t *testing.T) {
-	proc, _, playerClients, _, visuClients, glClients := runNetorcaiAndAllClients(
+	proc, _, playerClients, _, visuClients, glClients := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	go func(glClient *client.Client) {
-		msg, err := waitReadMessage(glClient, 1000)
+		msg, err := netorcaitest.WaitReadMessage(glClient, 1000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_INIT)")
-		checkDoInit(t, msg, 4, 0, 100)
+		netorcaitest.CheckDoInit(t, msg, 4, 0, 100)
 
-		doInitAck := DefaultHelloGLDoInitAck(4, 0, 100)
+		doInitAck := netorcaitest.DefaultHelloGLDoInitAck(4, 0, 100)
 		err = glClient.SendString(doInitAck)
 		assert.NoError(t, err, "GLClient could not send DO_INIT_ACK")
 
-		msg, err = waitReadMessage(glClient, 2000)
+		msg, err = netorcaitest.WaitReadMessage(glClient, 2000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_TURN)")
-		checkDoTurn(t, msg, 4, 0, 100)
+		netorcaitest.CheckDoTurn(t, msg, 4, 0, 100)
 
 		glClient.Disconnect()
 	}(glClients[0])
 
-	proc.inputControl <- `start`
-	_, err := waitOutputTimeout(regexp.MustCompile(`Game logic failed`),
-		proc.outputControl, 4000, false)
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game logic failed`),
+		proc.OutputControl, 4000, false)
 	assert.NoError(t, err,
 		"Cannot read `Game logic failed` in netorcai output")
 
-	_, expRetCode := handleCoverage(t, 1)
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 
-	checkAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`), 1000)
-	checkAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestInvalidGlNoDoTurnAckSocketClosedFast(t *testing.T) {
-	proc, _, playerClients, _, visuClients, glClients := runNetorcaiAndAllClients(
+	proc, _, playerClients, _, visuClients, glClients := netorcaitest.RunNetorcaiAndAllClients(
 		t, []string{"--fast"}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	go func(glClient *client.Client) {
-		msg, err := waitReadMessage(glClient, 1000)
+		msg, err := netorcaitest.WaitReadMessage(glClient, 1000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_INIT)")
-		checkDoInit(t, msg, 4, 0, 100)
+		netorcaitest.CheckDoInit(t, msg, 4, 0, 100)
 
-		doInitAck := DefaultHelloGLDoInitAck(4, 0, 100)
+		doInitAck := netorcaitest.DefaultHelloGLDoInitAck(4, 0, 100)
 		err = glClient.SendString(doInitAck)
 		assert.NoError(t, err, "GLClient could not send DO_INIT_ACK")
 
-		msg, err = waitReadMessage(glClient, 2000)
+		msg, err = netorcaitest.WaitReadMessage(glClient, 2000)
 		assert.NoError(t, err, "Could not read GLClient message (DO_TURN)")
-		checkDoTurn(t, msg, 4, 0, 100)
+		netorcaitest.CheckDoTurn(t, msg, 4, 0, 100)
 
 		glClient.Disconnect()
 	}(glClients[0])
 
-	proc.inputControl <- `start`
-	_, err := waitOutputTimeout(regexp.MustCompile(`Game logic failed`),
-		proc.outputControl, 4000, false)
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game logic failed`),
+		proc.OutputControl, 4000, false)
 	assert.NoError(t, err,
 		"Cannot read `Game logic failed` in netorcai output")
 
-	_, expRetCode := handleCoverage(t, 1)
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 
-	checkAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`), 1000)
-	checkAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, playerClients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, visuClients, regexp.MustCompile(`netorcai abort`), 1000)
 }
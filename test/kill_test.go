@@ -3,6 +3,7 @@ package test
 import (
 	"fmt"
 	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
@@ -10,30 +11,30 @@ import (
 )
 
 func TestKickallOnAbortKillSigterm(t *testing.T) {
-	proc, clients, _, _, _, _ := runNetorcaiAndAllClients(t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	proc, clients, _, _, _, _ := netorcaitest.RunNetorcaiAndAllClients(t, []string{}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	killallNetorcai()
+	netorcaitest.KillallNetorcai()
 
-	checkAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
-	_, expRetCode := handleCoverage(t, 1)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
 func TestKickallOnAbortKillSigtermSpecial(t *testing.T) {
-	proc, clients, _, _, _, _ := runNetorcaiAndAllClients(t, []string{"--nb-splayers-max=1"}, 1000, 1)
-	defer killallNetorcaiSIGKILL()
+	proc, clients, _, _, _, _ := netorcaitest.RunNetorcaiAndAllClients(t, []string{"--nb-splayers-max=1"}, 1000, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	killallNetorcai()
+	netorcaitest.KillallNetorcai()
 
-	checkAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
 
-	retCode, err := waitCompletionTimeout(proc.completion, 1000)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "netorcai did not complete")
-	_, expRetCode := handleCoverage(t, 1)
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
 	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
 }
 
@@ -48,20 +49,20 @@ func subtestKillDuringGame(t *testing.T, netorcaiArgs []string,
 	killTrigger KillTrigger,
 	nbTurns int,
 	msBeforeFirstTurn, msBetweenTurns float64) {
-	proc, _, players, _, visus, gls := runNetorcaiAndAllClients(t,
+	proc, _, players, _, visus, gls := netorcaitest.RunNetorcaiAndAllClients(t,
 		append([]string{
 			fmt.Sprintf("--delay-first-turn=%v", msBeforeFirstTurn),
 			fmt.Sprintf("--delay-turns=%v", msBetweenTurns),
 			fmt.Sprintf("--nb-turns-max=%v", nbTurns)},
 			netorcaiArgs...),
 		1000, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Disconnect visus
 	for _, visu := range visus {
 		visu.Disconnect()
-		waitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
-			proc.outputControl, 1000, false)
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
 	}
 
 	clientFinished := make(chan int, 5)
@@ -69,35 +70,35 @@ func subtestKillDuringGame(t *testing.T, netorcaiArgs []string,
 	for _, glClient := range gls {
 		go func(gl *client.Client, onexit chan int) {
 			// Read DO_INIT
-			msg, err := waitReadMessage(gl, 1000)
+			msg, err := netorcaitest.WaitReadMessage(gl, 1000)
 			assert.NoError(t, err, "GL could not read message (DO_INIT)")
-			checkDoInit(t, msg, 4, 0, nbTurns)
+			netorcaitest.CheckDoInit(t, msg, 4, 0, nbTurns)
 
 			// Answer DO_INIT_ACK
-			doInitAck := DefaultHelloGLDoInitAck(4, 0, nbTurns)
+			doInitAck := netorcaitest.DefaultHelloGLDoInitAck(4, 0, nbTurns)
 			err = gl.SendString(doInitAck)
 			assert.NoError(t, err, "GL could not send DO_INIT_ACK")
 
 			// Read DO_TURN
-			msg, err = waitReadMessage(gl, 1000)
+			msg, err = netorcaitest.WaitReadMessage(gl, 1000)
 			assert.NoError(t, err, "GL could not read message (DO_TURN)")
-			checkDoTurn(t, msg, 4, 0, nbTurns)
+			netorcaitest.CheckDoTurn(t, msg, 4, 0, nbTurns)
 
 			if killTrigger == OnDoTurnReception {
 				// Kill netorcai gently
-				err = killNetorcaiGently(proc, 1000)
+				err = netorcaitest.KillNetorcaiGently(proc, 1000)
 				assert.NoError(t, err, "Netorcai could not be killed gently")
 			} else {
 				// Answer DO_TURN_ACK
-				doTurnAck := DefaultHelloGlDoTurnAck(0, nil)
+				doTurnAck := netorcaitest.DefaultHelloGlDoTurnAck(0, nil)
 				err = gl.SendString(doTurnAck)
 				assert.NoError(t, err, "GL could not send DO_TURN_ACK")
 			}
 
 			// Read KICK
-			msg, err = waitReadMessage(gl, 1000)
+			msg, err = netorcaitest.WaitReadMessage(gl, 1000)
 			assert.NoError(t, err, "GL could not read message (KICK)")
-			checkKick(t, msg, "GL", regexp.MustCompile(`netorcai abort`))
+			netorcaitest.CheckKick(t, msg, "GL", regexp.MustCompile(`netorcai abort`))
 			onexit <- 1
 		}(glClient, clientFinished)
 	}
@@ -106,20 +107,20 @@ func subtestKillDuringGame(t *testing.T, netorcaiArgs []string,
 	for playerID, playerClient := range players {
 		go func(clientName string, player *client.Client, onexit chan int) {
 			// Read GAME_STARTS
-			msg, err := waitReadMessage(player, 1000)
+			msg, err := netorcaitest.WaitReadMessage(player, 1000)
 			assert.NoError(t, err, "%v could not read message (GAME_STARTS)", clientName)
-			checkGameStarts(t, msg, 4, 0, nbTurns, msBeforeFirstTurn, msBetweenTurns, true)
+			netorcaitest.CheckGameStarts(t, msg, 4, 0, nbTurns, msBeforeFirstTurn, msBetweenTurns, true)
 
 			if killTrigger == OnTurnReceptionPlayer0 {
 				// Read TURN
-				msg, err = waitReadMessage(player, 1000)
+				msg, err = netorcaitest.WaitReadMessage(player, 1000)
 				assert.NoError(t, err, "%v could not read message (TURN)", clientName)
-				turnReceived := checkTurn(t, msg, 4, 0, 0, true)
+				turnReceived := netorcaitest.CheckTurn(t, msg, 4, 0, 0, true)
 
 				switch clientName {
 				case "Player0":
 					// Kill netorcai gently
-					err = killNetorcaiGently(proc, 1000)
+					err = netorcaitest.KillNetorcaiGently(proc, 1000)
 					assert.NoError(t, err, "Netorcai could not be killed gently")
 				case "Player1":
 					// Disconnect.
@@ -130,7 +131,7 @@ func subtestKillDuringGame(t *testing.T, netorcaiArgs []string,
 				case "Player2":
 					// Answer TURN_ACK
 					time.Sleep(time.Duration(500) * time.Millisecond)
-					turnAck := DefaultHelloClientTurnAck(turnReceived, 2)
+					turnAck := netorcaitest.DefaultHelloClientTurnAck(turnReceived, 2)
 					err = player.SendString(turnAck)
 					assert.NoError(t, err, "%v could not send TURN_ACK", clientName)
 				case "Player3":
@@ -139,15 +140,15 @@ func subtestKillDuringGame(t *testing.T, netorcaiArgs []string,
 			}
 
 			// Read KICK
-			msg, err = waitReadMessage(player, 1000)
+			msg, err = netorcaitest.WaitReadMessage(player, 1000)
 			assert.NoError(t, err, "%v could not read message (KICK)", clientName)
-			checkKick(t, msg, clientName, regexp.MustCompile(`netorcai abort`))
+			netorcaitest.CheckKick(t, msg, clientName, regexp.MustCompile(`netorcai abort`))
 			onexit <- 1
 		}(fmt.Sprintf("Player%v", playerID), playerClient, clientFinished)
 	}
 
-	proc.inputControl <- `start`
-	waitOutputTimeout(regexp.MustCompile(`Game started`), proc.outputControl, 1000, true)
+	proc.InputControl <- `start`
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game started`), proc.OutputControl, 1000, true)
 
 	// Wait until completion of all clients or timeout
 	timeoutReached := make(chan int)
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func checkLatencies(t *testing.T, msg map[string]interface{}) {
+	latencies, err := netorcai.ReadObject(msg, "latencies")
+	assert.NoError(t, err, "Cannot read 'latencies'")
+	latency, ok := latencies["0"].(float64)
+	assert.True(t, ok, "Cannot read latency of player 0")
+	assert.True(t, latency >= 0, "Unexpected latency %v", latency)
+}
+
+func checkDoTurnLatencies(t *testing.T, msg map[string]interface{},
+	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int) []interface{} {
+	actions := netorcaitest.CheckDoTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber)
+	// The player answered the previous TURN
+	if expectedTurnNumber >= 0 {
+		checkLatencies(t, msg)
+	}
+	return actions
+}
+
+func checkTurnLatencies(t *testing.T, msg map[string]interface{},
+	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
+	turnNumber := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	if isPlayer {
+		_, exists := msg["latencies"]
+		assert.False(t, exists, "Players should not receive latencies")
+	} else if expectedTurnNumber >= 1 {
+		checkLatencies(t, msg)
+	}
+	return turnNumber
+}
+
+func TestLatencies(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=500", "--nb-turns-max=4",
+			"--delay-turns=500"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 1, 0, 4, 4, checkDoTurnLatencies,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	go netorcaitest.HelloClient(t, players[0], "Player0", 1, 0, 4, 4, 0, 500, 500, true, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnLatencies,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+	go netorcaitest.HelloClient(t, visus[0], "Visu0", 1, 0, 4, 4, 0, 500, 500, false, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnLatencies,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+
+	// Disconnect other clients
+	for _, client := range players[1:] {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	proc.InputControl <- "start"
+
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
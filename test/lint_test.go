@@ -0,0 +1,87 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func lintDoTurnAck(turn int, actions []interface{}) string {
+	if turn%2 == 0 {
+		return fmt.Sprintf(`{"message_type":"DO_TURN_ACK", "winner_player_id":-1,
+			"game_state":{"all_clients":{"turn":%v}}}`, turn)
+	}
+	return fmt.Sprintf(`{"message_type":"DO_TURN_ACK", "winner_player_id":-1,
+		"game_state":{"all_clients":{"turn":%v, "turn":"%v"}}}`, turn, turn)
+}
+
+func lintDoInitAck(nbPlayers, nbSpecialPlayers, nbTurns int) string {
+	return `{"message_type":"DO_INIT_ACK",
+		"initial_game_state":{"all_clients":{"turn":-1}}}`
+}
+
+func TestLintGameState(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=50", "--lint-game-state"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		lintDoInitAck, lintDoTurnAck, regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`Suspicious game state.*game_state\.all_clients\.turn.*Duplicate key`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "No warning about the duplicate key")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`Suspicious game state.*all_clients\.turn.*Type changed from number to string`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "No warning about the type change")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestLintGameStateNaN(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--lint-game-state"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	proc.InputControl <- "start"
+
+	msg, err := netorcaitest.WaitReadMessage(gl[0], 1000)
+	assert.NoError(t, err, "Cannot read GL message (DO_INIT)")
+	netorcaitest.CheckDoInit(t, msg, 0, 0, 100)
+
+	err = gl[0].SendString(`{"message_type":"DO_INIT_ACK",
+		"initial_game_state":{"all_clients":{"x":NaN}}}`)
+	assert.NoError(t, err, "Cannot send DO_INIT_ACK")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(
+		`Suspicious game state.*initial_game_state\.all_clients\.x.*NaN`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "No warning about NaN")
+
+	msg, err = netorcaitest.WaitReadMessage(gl[0], 1000)
+	assert.NoError(t, err, "Cannot read GL message (KICK)")
+	netorcaitest.CheckKick(t, msg, "GL", regexp.MustCompile(`Non-JSON`))
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Reads a LOBBY and checks the clients logged in it tells.
+func checkLobby(t *testing.T, c *client.Client, expectedPlayers,
+	expectedVisus []string, expectedGameLogic bool, expectedMissingPlayers int) {
+	msg, err := netorcaitest.WaitReadMessage(c, 1000)
+	assert.NoError(t, err, "Cannot read LOBBY")
+	messageType, _ := netorcai.ReadString(msg, "message_type")
+	assert.Equal(t, "LOBBY", messageType, "Unexpected message")
+
+	for field, expected := range map[string][]string{
+		"players": expectedPlayers, "visus": expectedVisus} {
+		nicknames, err := netorcai.ReadArray(msg, field)
+		assert.NoError(t, err, "Cannot read %v in LOBBY", field)
+		assert.Len(t, nicknames, len(expected), "Unexpected %v in LOBBY", field)
+		for index, nickname := range expected {
+			read, err := netorcai.ReadElementString(nicknames, index)
+			assert.NoError(t, err, "Cannot read %v in LOBBY", field)
+			assert.Equal(t, nickname, read, "Unexpected %v in LOBBY", field)
+		}
+	}
+
+	glConnected, err := netorcai.ReadBool(msg, "game_logic_connected")
+	assert.NoError(t, err, "Cannot read game_logic_connected in LOBBY")
+	assert.Equal(t, expectedGameLogic, glConnected,
+		"Unexpected game_logic_connected in LOBBY")
+	missingPlayers, err := netorcai.ReadInt(msg, "nb_missing_players")
+	assert.NoError(t, err, "Cannot read nb_missing_players in LOBBY")
+	assert.Equal(t, expectedMissingPlayers, missingPlayers,
+		"Unexpected nb_missing_players in LOBBY")
+}
+
+func TestLobbyBroadcasts(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--nb-players-max=2", "--nb-visus-max=1"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	player := &client.Client{}
+	err := player.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	err = player.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"waiting", "metaprotocol_version": "` + netorcai.Version + `", "lobby": true}`)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := netorcaitest.WaitReadMessage(player, 1000)
+	assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
+	netorcaitest.CheckLoginAck(t, msg)
+	checkLobby(t, player, []string{"waiting"}, []string{}, false, 1)
+
+	// Clients that log in or leave change the lobby
+	visu, err := netorcaitest.ConnectClient(t, "visualization", "visu",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect visualization")
+	checkLobby(t, player, []string{"waiting"}, []string{"visu"}, false, 1)
+
+	_, err = netorcaitest.ConnectClient(t, "game logic", "gl",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect game logic")
+	checkLobby(t, player, []string{"waiting"}, []string{"visu"}, true, 1)
+
+	visu.Disconnect()
+	checkLobby(t, player, []string{"waiting"}, []string{}, true, 1)
+
+	proc.InputControl <- "set nb-players-max=1"
+	checkLobby(t, player, []string{"waiting"}, []string{}, true, 0)
+}
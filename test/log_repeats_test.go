@@ -0,0 +1,48 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestLogRepeatsMax(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--log-repeats-max=2"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// Each invalid client is kicked, which logs the same warning
+	for i := 0; i < 5; i++ {
+		c := &client.Client{}
+		err := c.Connect("localhost", 4242)
+		assert.NoError(t, err, "Cannot connect")
+		err = c.SendString(`{}`)
+		assert.NoError(t, err, "Cannot send invalid LOGIN")
+		_, err = netorcaitest.WaitReadMessage(c, 1000)
+		assert.NoError(t, err, "Cannot read KICK")
+		c.Disconnect()
+	}
+
+	// The dropped warnings are summarized when netorcai stops
+	netorcaitest.KillallNetorcai()
+	nbKicks := 0
+	kickOrRepeats := regexp.MustCompile(`Kicking client|Message repeated`)
+	for {
+		line, err := netorcaitest.WaitOutputTimeout(kickOrRepeats,
+			proc.OutputControl, 2000, false)
+		if !assert.NoError(t, err, "Repeated message not summarized") {
+			break
+		}
+		if regexp.MustCompile(`Message repeated`).MatchString(line) {
+			assert.Regexp(t, `dropped=3.*message="Kicking client".*times=5`,
+				line, "Unexpected summary")
+			break
+		}
+		nbKicks++
+	}
+	assert.Equal(t, 2, nbKicks, "Repeated warnings should be dropped")
+
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
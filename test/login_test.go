@@ -3,14 +3,15 @@ package test
 import (
 	"github.com/netorcai/netorcai"
 	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
 )
 
 func TestLoginNotJson(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -20,17 +21,18 @@ func TestLoginNotJson(t *testing.T) {
 	err = client.SendString(`definitely not JSON`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Non-JSON"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Non-JSON"))
+	netorcaitest.CheckKickCode(t, msg, "InvalidClient", netorcai.KICK_PROTOCOL_ERROR)
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginNoMessageType(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -40,17 +42,22 @@ func TestLoginNoMessageType(t *testing.T) {
 	err = client.SendString(`{"nickname":"bot", "role":"player"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "message_type")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Field 'message_type' is missing"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("message_type: missing, expected string"))
+	netorcaitest.CheckKickCode(t, msg, "InvalidClient", netorcai.KICK_INVALID_LOGIN)
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginNoRole(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -60,17 +67,21 @@ func TestLoginNoRole(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "nickname":"bot"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "role")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Field 'role' is missing"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("role: missing, expected string"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginNoNickname(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -80,17 +91,21 @@ func TestLoginNoNickname(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "nickname")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Field 'nickname' is missing"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("nickname: missing, expected string"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginRoleNotString(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -100,17 +115,23 @@ func TestLoginRoleNotString(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":1, "nickname":"bot"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "role")
+	assert.Equal(t, "string", msg["expected"], "Unexpected expected type in ERROR")
+	assert.Equal(t, 1.0, msg["received_value"], "Unexpected received value in ERROR")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Non-string value for field 'role'"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("role: expected string, got number"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadRole(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -120,17 +141,21 @@ func TestLoginBadRole(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"¿Qué?", "nickname":"bot"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "role")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid role"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid role"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadNicknameShort(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -140,17 +165,21 @@ func TestLoginBadNicknameShort(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":""}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "nickname")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid nickname"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid nickname"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadNicknameLong(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -160,17 +189,21 @@ func TestLoginBadNicknameLong(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"1234567890a"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "nickname")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid nickname"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid nickname"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadNicknameBadCharacters(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -180,17 +213,21 @@ func TestLoginBadNicknameBadCharacters(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"hi world"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "nickname")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid nickname"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid nickname"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginNoMetaprotocolVersion(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -200,17 +237,21 @@ func TestLoginNoMetaprotocolVersion(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"valid"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "metaprotocol_version")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Field 'metaprotocol_version' is missing"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("metaprotocol_version: missing, expected string"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadMetaprotocolVersionNotString(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -220,17 +261,21 @@ func TestLoginBadMetaprotocolVersionNotString(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"valid", "metaprotocol_version": false}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "metaprotocol_version")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Non-string value for field 'metaprotocol_version'"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("metaprotocol_version: expected string, got boolean"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadMetaprotocolVersionNotSemver(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -240,17 +285,21 @@ func TestLoginBadMetaprotocolVersionNotSemver(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"valid", "metaprotocol_version": "42"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "metaprotocol_version")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid metaprotocol version: Not MAJOR.MINOR.PATCH"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid metaprotocol version: Not MAJOR.MINOR.PATCH"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginBadMetaprotocolVersionDifferentMajor(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -260,11 +309,39 @@ func TestLoginBadMetaprotocolVersionDifferentMajor(t *testing.T) {
 	err = client.SendString(`{"message_type":"LOGIN", "role":"player", "nickname":"valid", "metaprotocol_version": "0.1.0"}`)
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "metaprotocol_version")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (KICK)")
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Metaprotocol version mismatch. Major version must be identical"))
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestLoginBadVisuTier(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	var client client.Client
+	err := client.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	defer client.Disconnect()
+
+	err = client.SendString(`{"message_type":"LOGIN", "role":"visualization", "nickname":"valid", "metaprotocol_version": "` + netorcai.Version + `", "visu_tier": "backstage"}`)
+	assert.NoError(t, err, "Cannot send message")
+
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (ERROR)")
+	netorcaitest.CheckError(t, msg, "InvalidClient", "visu_tier")
+
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient", regexp.MustCompile("Metaprotocol version mismatch. Major version must be identical"))
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Invalid visu_tier 'backstage'"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -273,38 +350,38 @@ func TestLoginBadMetaprotocolVersionDifferentMajor(t *testing.T) {
  ************/
 
 func TestLoginPlayerAscii(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	player, err := connectClient(t, "player", "player", netorcai.Version, 1000)
+	player, err := netorcaitest.ConnectClient(t, "player", "player", netorcai.Version, 1000)
 	assert.NoError(t, err, "Cannot connect client")
 	player.Disconnect()
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginPlayerArabic(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	player, err := connectClient(t, "player", "لاعب", netorcai.Version, 1000)
+	player, err := netorcaitest.ConnectClient(t, "player", "لاعب", netorcai.Version, 1000)
 	assert.NoError(t, err, "Cannot connect client")
 	player.Disconnect()
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestLoginPlayerJapanese(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	player, err := connectClient(t, "player", "プレーヤー", netorcai.Version, 1000)
+	player, err := netorcaitest.ConnectClient(t, "player", "プレーヤー", netorcai.Version, 1000)
 	assert.NoError(t, err, "Cannot connect client")
 	player.Disconnect()
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -314,8 +391,8 @@ func TestLoginPlayerJapanese(t *testing.T) {
 
 func subtestLoginMaxNbClientSequential(t *testing.T, loginRole string,
 	nbConnections, expectedNbLogged int, kickReasonMatcher *regexp.Regexp) {
-	proc := runNetorcaiWaitListening(t, []string{"--nb-splayers-max=2"})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{"--nb-splayers-max=2"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Do many player connections sequentially
 	var clients []*client.Client
@@ -331,14 +408,15 @@ func subtestLoginMaxNbClientSequential(t *testing.T, loginRole string,
 		err = client.SendLogin(loginRole, "клиент", netorcai.Version)
 		assert.NoError(t, err, "Cannot send LOGIN")
 
-		msg, err := waitReadMessage(client, 1000)
+		msg, err := netorcaitest.WaitReadMessage(client, 1000)
 		assert.NoError(t, err, "Cannot read client message (LOGIN_ACK|KICK)")
 
 		if i < expectedNbLogged {
-			checkLoginAck(t, msg)
+			netorcaitest.CheckLoginAck(t, msg)
 			clients = append(clients, client)
 		} else {
-			checkKick(t, msg, loginRole, kickReasonMatcher)
+			netorcaitest.CheckKick(t, msg, loginRole, kickReasonMatcher)
+			netorcaitest.CheckKickCode(t, msg, loginRole, netorcai.KICK_LOGIN_DENIED_FULL)
 			err = client.Disconnect()
 			assert.NoError(t, err, "Kicked client could not disconnect")
 		}
@@ -354,8 +432,8 @@ func subtestLoginMaxNbClientSequential(t *testing.T, loginRole string,
 		assert.NoError(t, err, "Logged client could not disconnect")
 
 		// Check netorcai awareness of the disconnection
-		_, err = waitOutputTimeout(
-			regexp.MustCompile(`Remote endpoint closed`), proc.outputControl,
+		_, err = netorcaitest.WaitOutputTimeout(
+			regexp.MustCompile(`Remote endpoint closed`), proc.OutputControl,
 			500, false)
 		assert.NoError(t, err,
 			"Could not read disconnection discovery in netorcai output")
@@ -364,12 +442,12 @@ func subtestLoginMaxNbClientSequential(t *testing.T, loginRole string,
 	if loginRole != "game logic" {
 		// Connect the expected number of clients
 		for i := 0; i < expectedNbLogged; i++ {
-			_, err := connectClient(t, loginRole, "клиент", netorcai.Version, 1000)
+			_, err := netorcaitest.ConnectClient(t, loginRole, "клиент", netorcai.Version, 1000)
 			assert.NoError(t, err, "Cannot connect client")
 		}
 	}
 
-	err := killNetorcaiGently(proc, 1000)
+	err := netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "First instance could not be killed gently")
 }
 
@@ -395,15 +473,15 @@ func TestLoginMaxNbGameLogicSequential(t *testing.T) {
 
 func subtestLoginGameAlreadyStarted(t *testing.T, loginRole string,
 	shouldConnect bool) {
-	proc, _, _, _, _, _ := runNetorcaiAndClients(t,
+	proc, _, _, _, _, _ := netorcaitest.RunNetorcaiAndClients(t,
 		[]string{}, 1000, 0, 0, 0)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	waitOutputTimeout(regexp.MustCompile(`Game logic accepted`),
-		proc.outputControl, 1000, false)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game logic accepted`),
+		proc.OutputControl, 1000, false)
 
-	proc.inputControl <- `start`
-	waitOutputTimeout(regexp.MustCompile(`Game started`), proc.outputControl,
+	proc.InputControl <- `start`
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game started`), proc.OutputControl,
 		1000, true)
 
 	client := &client.Client{}
@@ -414,18 +492,19 @@ func subtestLoginGameAlreadyStarted(t *testing.T, loginRole string,
 	assert.NoError(t, err, "Cannot send LOGIN")
 
 	if shouldConnect {
-		msg, err := waitReadMessage(client, 1000)
+		msg, err := netorcaitest.WaitReadMessage(client, 1000)
 		assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
-		checkLoginAck(t, msg)
+		netorcaitest.CheckLoginAck(t, msg)
 	} else {
-		msg, err := waitReadMessage(client, 1000)
+		msg, err := netorcaitest.WaitReadMessage(client, 1000)
 		assert.NoError(t, err, "Cannot read client message (KICK)")
-		checkKick(t, msg, loginRole,
+		netorcaitest.CheckKick(t, msg, loginRole,
 			regexp.MustCompile(`LOGIN denied: Game has been started`))
+		netorcaitest.CheckKickCode(t, msg, loginRole, netorcai.KICK_GAME_STARTED)
 	}
 
-	proc.inputControl <- `quit`
-	waitCompletionTimeout(proc.completion, 1000)
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestLoginPlayerGameAlreadyStarted(t *testing.T) {
@@ -3,6 +3,7 @@ package test
 import (
 	"github.com/netorcai/netorcai"
 	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"regexp"
@@ -19,8 +20,8 @@ func RandomString(size int) string {
 }
 
 func TestFirstMessageTooBig(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -30,18 +31,18 @@ func TestFirstMessageTooBig(t *testing.T) {
 	err = client.SendBytes([]byte(RandomString(1024-1)), false) // -1 for final '\n'
 	assert.NoError(t, err, "Cannot send message")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient",
+	netorcaitest.CheckKick(t, msg, "InvalidClient",
 		regexp.MustCompile("Received message size of first message is too big"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestSecondMessageTooBig(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	var client client.Client
 	err := client.Connect("localhost", 4242)
@@ -51,17 +52,17 @@ func TestSecondMessageTooBig(t *testing.T) {
 	err = client.SendLogin("player", "player", netorcai.Version)
 	assert.NoError(t, err, "Cannot send LOGIN")
 
-	msg, err := waitReadMessage(&client, 1000)
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
-	checkLoginAck(t, msg)
+	netorcaitest.CheckLoginAck(t, msg)
 
 	err = client.SendBytes([]byte(RandomString(16777216-1)), false) // -1 for final '\n'
 
-	msg, err = waitReadMessage(&client, 1000)
+	msg, err = netorcaitest.WaitReadMessage(&client, 1000)
 	assert.NoError(t, err, "Cannot read client message (KICK)")
-	checkKick(t, msg, "InvalidClient",
+	netorcaitest.CheckKick(t, msg, "InvalidClient",
 		regexp.MustCompile("Received message size is too big"))
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
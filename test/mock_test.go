@@ -0,0 +1,114 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type mockResult struct {
+	kickReason string
+	err        error
+}
+
+func runMock(run func() (string, error)) chan mockResult {
+	result := make(chan mockResult, 1)
+	go func() {
+		kickReason, err := run()
+		result <- mockResult{kickReason, err}
+	}()
+	return result
+}
+
+func TestMockGameLogicDisconnect(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--delay-turns=50"}, 1000, 2, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &netorcaitest.MockGameLogic{
+		Fault: func(turn int) netorcaitest.Fault {
+			if turn == 1 {
+				return netorcaitest.FaultDisconnect
+			}
+			return netorcaitest.NoFault
+		},
+	}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+
+	var playerResults []chan mockResult
+	for _, playerClient := range playerClients {
+		playerClient := playerClient
+		player := &netorcaitest.MockClient{}
+		playerResults = append(playerResults, runMock(
+			func() (string, error) { return player.Run(playerClient) }))
+	}
+
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Cannot read DO_TURN_ACK`),
+		proc.OutputControl, 4000, false)
+	assert.NoError(t, err, "Cannot read `Cannot read DO_TURN_ACK` in netorcai output")
+
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+
+	r := <-glResult
+	assert.NoError(t, r.err, "Mock game logic failed")
+	assert.Equal(t, "", r.kickReason, "Mock game logic should have disconnected")
+	assert.Len(t, gl.Received, 3, "Mock game logic should have received DO_INIT and 2 DO_TURN")
+
+	for _, playerResult := range playerResults {
+		select {
+		case r := <-playerResult:
+			assert.NoError(t, r.err, "Mock player failed")
+			assert.Regexp(t, `netorcai abort`, r.kickReason, "Unexpected player kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock player was not kicked")
+		}
+	}
+}
+
+func TestMockClientNoAnswer(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--delay-turns=50",
+			"--nb-turns-max=5", "--autostart", "--nb-players-max=2",
+			"--nb-splayers-max=0", "--nb-visus-max=0"}, 1000, 2, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &netorcaitest.MockGameLogic{}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+
+	// The first player stops answering after the first turn.
+	silent := &netorcaitest.MockClient{
+		Fault: func(turn int) netorcaitest.Fault {
+			if turn > 0 {
+				return netorcaitest.FaultNoAnswer
+			}
+			return netorcaitest.NoFault
+		},
+	}
+	silentResult := runMock(func() (string, error) { return silent.Run(playerClients[0]) })
+
+	player := &netorcaitest.MockClient{}
+	playerResult := runMock(func() (string, error) { return player.Run(playerClients[1]) })
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 4000, false)
+	assert.NoError(t, err, "Cannot read `Game is finished` in netorcai output")
+
+	for _, result := range []chan mockResult{glResult, silentResult, playerResult} {
+		r := <-result
+		assert.NoError(t, r.err, "Mock failed")
+		assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+	}
+
+	// netorcai stops sending turns to the silent player,
+	// which should have received TURN 0 and TURN 1 only
+	// (the other player receives TURN 0 to 3, the last game state is in GAME_ENDS).
+	assert.Len(t, silent.Received, 2+3, "Unexpected messages (GAME_STARTS, TURN*, GAME_ENDS, KICK)")
+	assert.Len(t, player.Received, 4+3, "Unexpected messages (GAME_STARTS, TURN*, GAME_ENDS, KICK)")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
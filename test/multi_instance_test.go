@@ -1,6 +1,7 @@
 package test
 
 import (
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
@@ -9,13 +10,13 @@ import (
 
 func TestTwoInstancesSamePort(t *testing.T) {
 	args := []string{"--port=5151"}
-	coverFile, expectedExitCode2 := handleCoverage(t, 1)
+	coverFile, expectedExitCode2 := netorcaitest.HandleCoverage(t, 1)
 
-	proc1, err := runNetorcaiCover("", args) // never covered
+	proc1, err := netorcaitest.RunNetorcaiCover("", args) // never covered
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc1.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc1.OutputControl, 1000)
 	assert.NoError(t, err, "First instance is not listening")
 
 	// Since f10adda, the second instance is also listening on the CI.
@@ -24,44 +25,44 @@ func TestTwoInstancesSamePort(t *testing.T) {
 	// This delay is therefore here as a workaround.
 	time.Sleep(time.Duration(100) * time.Millisecond)
 
-	proc2, err := runNetorcaiCover(coverFile, args)
+	proc2, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
 
-	_, err = waitOutputTimeout(
+	_, err = netorcaitest.WaitOutputTimeout(
 		regexp.MustCompile(`Cannot listen incoming connections`),
-		proc2.outputControl, 1000, false)
+		proc2.OutputControl, 1000, false)
 	assert.NoError(t, err, "Second instance is listening")
 
-	exitCode, err := waitCompletionTimeout(proc2.completion, 1000)
+	exitCode, err := netorcaitest.WaitCompletionTimeout(proc2.Completion, 1000)
 	assert.NoError(t, err, "Second instance has not completed")
 	assert.Equal(t, expectedExitCode2, exitCode,
 		"Second instance bad exit code")
 
-	err = killNetorcaiGently(proc1, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc1, 1000)
 	assert.NoError(t, err, "First instance could not be killed gently")
 }
 
 func TestTwoInstancesDifferentPort(t *testing.T) {
 	args := []string{"--port=5151"}
-	coverFile, _ := handleCoverage(t, 1)
+	coverFile, _ := netorcaitest.HandleCoverage(t, 1)
 
-	proc1, err := runNetorcaiCover("", args) // never covered
+	proc1, err := netorcaitest.RunNetorcaiCover("", args) // never covered
 	assert.NoError(t, err, "Cannot start netorcai")
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	_, err = waitListening(proc1.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc1.OutputControl, 1000)
 	assert.NoError(t, err, "First instance is not listening")
 
 	args = []string{"--port=5252"}
-	proc2, err := runNetorcaiCover(coverFile, args)
+	proc2, err := netorcaitest.RunNetorcaiCover(coverFile, args)
 	assert.NoError(t, err, "Cannot start netorcai")
 
-	_, err = waitListening(proc2.outputControl, 1000)
+	_, err = netorcaitest.WaitListening(proc2.OutputControl, 1000)
 	assert.NoError(t, err, "Second instance is not listening")
 
-	err = killNetorcaiGently(proc1, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc1, 1000)
 	assert.NoError(t, err, "First instance could not be killed gently")
 
-	err = killNetorcaiGently(proc2, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc2, 1000)
 	assert.NoError(t, err, "Second instance could not be killed gently")
 }
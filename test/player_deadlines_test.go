@@ -0,0 +1,122 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// A sequential game: Only player turn%2 acts, for up to 1 s.
+func doTurnAckSequential(turn int, actions []interface{}) string {
+	return fmt.Sprintf(`{"message_type":"DO_TURN_ACK",
+		"winner_player_id":-1,
+		"game_state":{"all_clients":{}},
+		"player_deadlines":{"%v":1000, "%v":0}}`, turn%2, (turn+1)%2)
+}
+
+func TestPlayerDeadlines(t *testing.T) {
+	// The turns end as soon as the active player acts, not after the delay
+	subtestPlayerDeadlines(t, []string{"--delay-turns=10000"})
+}
+
+func TestPlayerDeadlinesFast(t *testing.T) {
+	// Players that cannot act are not waited for
+	subtestPlayerDeadlines(t, []string{"--fast"})
+}
+
+func subtestPlayerDeadlines(t *testing.T, args []string) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, append([]string{"--delay-first-turn=50", "--nb-turns-max=5",
+			"--autostart", "--nb-players-max=2", "--nb-splayers-max=0",
+			"--nb-visus-max=0"}, args...), 1000, 2, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &netorcaitest.MockGameLogic{DoTurnAck: doTurnAckSequential}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+	players := []*netorcaitest.MockClient{}
+	results := []chan mockResult{glResult}
+	for _, playerClient := range playerClients {
+		player := &netorcaitest.MockClient{
+			TurnAck: func(turn, playerID int) string {
+				return fmt.Sprintf(`{"message_type": "TURN_ACK",
+					"turn_number": %v, "actions": ["%v"]}`, turn, playerID)
+			},
+			// Player 1 misses its deadline at turn 1
+			Delay: func(turn int) time.Duration {
+				if turn == 1 {
+					return 1500 * time.Millisecond
+				}
+				return 0
+			},
+		}
+		players = append(players, player)
+		playerClient := playerClient
+		results = append(results, runMock(func() (string, error) {
+			return player.Run(playerClient)
+		}))
+	}
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 8000, false)
+	assert.NoError(t, err, "Game did not finish")
+	for _, result := range results {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+		case <-time.After(3 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	// Players are told their deadline
+	for _, player := range players {
+		playerID := -1
+		for _, msg := range player.Received {
+			switch messageType, _ := netorcai.ReadString(msg, "message_type"); messageType {
+			case "GAME_STARTS":
+				playerID, _ = netorcai.ReadInt(msg, "player_id")
+			case "TURN":
+				turn, _ := netorcai.ReadInt(msg, "turn_number")
+				expected := 0.0
+				if turn%2 == playerID {
+					expected = 1000.0
+				}
+				assert.Equal(t, expected, msg["action_deadline_ms"],
+					"Unexpected deadline of player %v at turn %v", playerID, turn)
+			}
+		}
+	}
+
+	// Only the actions of the active player are forwarded, unless it is late
+	doTurns := []map[string]interface{}{}
+	for _, msg := range gl.Received {
+		if messageType, _ := netorcai.ReadString(msg, "message_type"); messageType == "DO_TURN" {
+			doTurns = append(doTurns, msg)
+		}
+	}
+	if !assert.Len(t, doTurns, 5, "Unexpected number of DO_TURN") {
+		return
+	}
+	for turn, doTurn := range doTurns[1:] {
+		actions, _ := netorcai.ReadArray(doTurn, "player_actions")
+		if turn == 1 {
+			assert.Empty(t, actions, "Late actions should be dropped")
+			assert.Equal(t, []interface{}{1.0}, doTurn["missed_deadlines"],
+				"Player 1 should have missed its deadline")
+			continue
+		}
+		if assert.Len(t, actions, 1, "Unexpected actions at turn %v", turn) {
+			action := actions[0].(map[string]interface{})
+			assert.Equal(t, float64(turn%2), action["player_id"],
+				"Unexpected acting player at turn %v", turn)
+		}
+		_, exists := doTurn["missed_deadlines"]
+		assert.False(t, exists, "No deadline missed at turn %v", turn)
+	}
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"fmt"
 	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"testing"
@@ -21,22 +22,22 @@ func disconnectingClient(t *testing.T, client *client.Client, clientName string,
 	msBeforeFirstTurn, msBetweenTurns float64,
 	clientDisconnectionTurnFunc ClientDisconnectionTurnFunc) {
 
-	msg, err := waitReadMessage(client, 2000)
+	msg, err := netorcaitest.WaitReadMessage(client, 2000)
 	assert.NoError(t, err, "%v could not read message (GAME_STARTS)", clientName)
-	playerID := checkGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
+	playerID := netorcaitest.CheckGameStarts(t, msg, nbPlayers, nbSpecialPlayers, nbTurnsGL,
 		msBeforeFirstTurn, msBetweenTurns, isPlayer)
 	disconnectionTurn := clientDisconnectionTurnFunc(playerID)
 
 	for turn := 0; turn < disconnectionTurn; turn += 1 {
 		// Wait TURN
-		msg, err := waitReadMessage(client, 2000)
+		msg, err := netorcaitest.WaitReadMessage(client, 2000)
 		assert.NoError(t, err, "%v could not read message (TURN) %v/%v",
 			clientName, turn, disconnectionTurn)
-		turnReceived := checkTurn(t, msg, nbPlayers, nbSpecialPlayers, turn, isPlayer)
+		turnReceived := netorcaitest.CheckTurn(t, msg, nbPlayers, nbSpecialPlayers, turn, isPlayer)
 
 		// Send TURN_ACK
 		if turn != disconnectionTurn-1 {
-			data := DefaultHelloClientTurnAck(turnReceived, playerID)
+			data := netorcaitest.DefaultHelloClientTurnAck(turnReceived, playerID)
 			err = client.SendString(data)
 			assert.NoError(t, err, "%s cannot send TURN_ACK", clientName)
 		}
@@ -51,7 +52,7 @@ func subtestDisconnectingClients(t *testing.T,
 	nbTurns int,
 	playerDiscoTurnFunc, splayerDiscoTurnFunc, visuDiscoTurnFunc ClientDisconnectionTurnFunc) {
 
-	proc, _, players, specialPlayers, visus, gl := runNetorcaiAndClients(
+	proc, _, players, specialPlayers, visus, gl := netorcaitest.RunNetorcaiAndClients(
 		t, append([]string{"--delay-first-turn=500",
 			fmt.Sprintf("--nb-turns-max=%v", nbTurns),
 			fmt.Sprintf("--nb-players-max=%v", nbPlayers),
@@ -60,11 +61,11 @@ func subtestDisconnectingClients(t *testing.T,
 			"--delay-turns=500", "--debug", "--autostart"},
 			netorcaiAdditionalArgs...),
 		1000, nbPlayers, nbSpecialPlayers, nbVisus)
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Run a game client
-	go helloGameLogic(t, gl[0], nbPlayers, nbSpecialPlayers, nbTurns, nbTurns,
-		DefaultHelloGLCheckDoTurn, DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
+	go netorcaitest.HelloGameLogic(t, gl[0], nbPlayers, nbSpecialPlayers, nbTurns, nbTurns,
+		netorcaitest.DefaultHelloGLCheckDoTurn, netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
 		regexp.MustCompile(`Game is finished`))
 
 	// Run player clients
@@ -86,9 +87,9 @@ func subtestDisconnectingClients(t *testing.T,
 	}
 
 	// Wait for game end
-	waitOutputTimeout(regexp.MustCompile(`Game is finished`),
-		proc.outputControl, 5000, false)
-	waitCompletionTimeout(proc.completion, 1000)
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 }
 
 func TestPlayerDisconnectionDuringGame(t *testing.T) {
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func doTurnAckPlayerMessages(turn int, actions []interface{}) string {
+	return fmt.Sprintf(`{"message_type":"DO_TURN_ACK",
+		"winner_player_id":-1,
+		"game_state":{"all_clients":{}},
+		"player_messages":{"0":{"turn":%v}}}`, turn)
+}
+
+func checkTurnPlayerMessage(t *testing.T, msg map[string]interface{},
+	expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber int, isPlayer bool) int {
+	turnNumber := netorcaitest.CheckTurn(t, msg, expectedNbPlayers, expectedNbSpecialPlayers, expectedTurnNumber, isPlayer)
+	if isPlayer {
+		playerMessage, err := netorcai.ReadObject(msg, "player_message")
+		assert.NoError(t, err, "Cannot read 'player_message'")
+		turn, err := netorcai.ReadInt(playerMessage, "turn")
+		assert.NoError(t, err, "Cannot read 'turn' in player_message")
+		assert.Equal(t, expectedTurnNumber, turn, "Unexpected player_message")
+	} else {
+		_, exists := msg["player_message"]
+		assert.False(t, exists, "Visualizations should not receive player messages")
+	}
+	return turnNumber
+}
+
+func TestPlayerMessages(t *testing.T) {
+	subtestHelloGlActiveClients(t, nil, 1, 0, 1,
+		3, 3, 3, 3,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnPlayerMessage, checkTurnPlayerMessage,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckPlayerMessages,
+		netorcaitest.DefaultHelloClientTurnAck, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`),
+		regexp.MustCompile(`Game is finished`))
+}
@@ -3,11 +3,19 @@ package test
 import (
 	"bufio"
 	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func promptReadValue(promptLine, variableName string) (string, error) {
@@ -23,104 +31,104 @@ func promptReadValue(promptLine, variableName string) (string, error) {
 }
 
 func TestPromptStartNoClient(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "start"
-	_, err := waitOutputTimeout(regexp.MustCompile(`Cannot start`),
-		proc.outputControl, 1000, true)
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Cannot start`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read line")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptDoubleStart(t *testing.T) {
-	proc, _, _, _, _, _ := runNetorcaiAndAllClients(t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	proc, _, _, _, _, _ := netorcaitest.RunNetorcaiAndAllClients(t, []string{}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "start"
-	proc.inputControl <- "start"
-	_, err := waitOutputTimeout(
+	proc.InputControl <- "start"
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(
 		regexp.MustCompile(`Game has already been started`),
-		proc.outputControl, 1000, false)
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read line")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptDoubleStartSpecial(t *testing.T) {
-	proc, _, _, _, _, _ := runNetorcaiAndAllClients(t, []string{"--nb-splayers-max=1"}, 1000, 1)
-	defer killallNetorcaiSIGKILL()
+	proc, _, _, _, _, _ := netorcaitest.RunNetorcaiAndAllClients(t, []string{"--nb-splayers-max=1"}, 1000, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "start"
-	proc.inputControl <- "start"
-	_, err := waitOutputTimeout(
+	proc.InputControl <- "start"
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(
 		regexp.MustCompile(`Game has already been started`),
-		proc.outputControl, 1000, false)
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read line")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptQuitNoClient(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "quit"
-	_, err := waitOutputTimeout(regexp.MustCompile(`Shell exit`),
-		proc.outputControl, 1000, true)
+	proc.InputControl <- "quit"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Shell exit`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read line")
 
-	exitCode, err := waitCompletionTimeout(proc.completion, 1000)
+	exitCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
 	assert.NoError(t, err, "Cannot wait netorcai completion")
 	assert.Equal(t, 0, exitCode, "Invalid netorcai exit code")
 }
 
 func TestPromptQuitAllClient(t *testing.T) {
-	proc, clients, _, _, _, _ := runNetorcaiAndAllClients(t, []string{}, 1000, 0)
-	defer killallNetorcaiSIGKILL()
+	proc, clients, _, _, _, _ := netorcaitest.RunNetorcaiAndAllClients(t, []string{}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "quit"
-	_, err := waitOutputTimeout(regexp.MustCompile(`Shell exit`),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "quit"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Shell exit`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read line")
 
-	checkAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func TestPromptQuitAllClientSpecial(t *testing.T) {
-	proc, clients, _, _, _, _ := runNetorcaiAndAllClients(t, []string{"--nb-splayers-max=1"}, 1000, 1)
-	defer killallNetorcaiSIGKILL()
+	proc, clients, _, _, _, _ := netorcaitest.RunNetorcaiAndAllClients(t, []string{"--nb-splayers-max=1"}, 1000, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "quit"
-	_, err := waitOutputTimeout(regexp.MustCompile(`Shell exit`),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "quit"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Shell exit`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read line")
 
-	checkAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
+	netorcaitest.CheckAllKicked(t, clients, regexp.MustCompile(`netorcai abort`), 1000)
 }
 
 func subtestPromptVariablePrintSet(t *testing.T, variableName,
 	invalidTypeValue, initialValue,
 	tooSmallValue, okValue, tooBigValue string) {
-	proc := runNetorcaiWaitListening(t, []string{})
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
 	currentValue := initialValue
-	defer killallNetorcaiSIGKILL()
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
 	// Set invalid value (bad type)
-	proc.inputControl <- "set " + variableName + "=" + invalidTypeValue
-	line, err := waitOutputTimeout(regexp.MustCompile(`Bad VALUE`),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "set " + variableName + "=" + invalidTypeValue
+	line, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Bad VALUE`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err,
 		"Cannot read prompt 'Bad VALUE' output (invalid type value)")
 
 	// Initial value must still be there
-	proc.inputControl <- "print " + variableName
-	line, err = waitOutputTimeout(regexp.MustCompile(variableName+"="),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "print " + variableName
+	line, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(variableName+"="),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read prompt 'print' output (initial value)")
 	value, err := promptReadValue(line, variableName)
 	assert.NoError(t, err,
@@ -130,10 +138,10 @@ func subtestPromptVariablePrintSet(t *testing.T, variableName,
 
 	// Set a valid value, then check that the printed value is the expected one
 	currentValue = okValue
-	proc.inputControl <- "set " + variableName + "=" + okValue
-	proc.inputControl <- "print " + variableName
-	line, err = waitOutputTimeout(regexp.MustCompile(variableName+"="),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "set " + variableName + "=" + okValue
+	proc.InputControl <- "print " + variableName
+	line, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(variableName+"="),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read prompt 'print' output (ok value)")
 	value, err = promptReadValue(line, variableName)
 	assert.NoError(t, err,
@@ -142,23 +150,23 @@ func subtestPromptVariablePrintSet(t *testing.T, variableName,
 		"Unexpected value from prompt print output (ok value)")
 
 	// Set invalid value (too small)
-	proc.inputControl <- "set " + variableName + "=" + tooSmallValue
-	line, err = waitOutputTimeout(regexp.MustCompile(`Bad VALUE`),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "set " + variableName + "=" + tooSmallValue
+	line, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Bad VALUE`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err,
 		"Cannot read prompt 'Bad VALUE' output (too small value)")
 
 	// Set invalid value (too big)
-	proc.inputControl <- "set " + variableName + "=" + tooBigValue
-	line, err = waitOutputTimeout(regexp.MustCompile(`Bad VALUE`),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "set " + variableName + "=" + tooBigValue
+	line, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Bad VALUE`),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err,
 		"Cannot read prompt 'Bad VALUE' output (too big value)")
 
 	// Previous value must still be there
-	proc.inputControl <- "print " + variableName
-	line, err = waitOutputTimeout(regexp.MustCompile(variableName+"="),
-		proc.outputControl, 1000, false)
+	proc.InputControl <- "print " + variableName
+	line, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(variableName+"="),
+		proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read prompt 'print' output (at end)")
 	value, err = promptReadValue(line, variableName)
 	assert.NoError(t, err,
@@ -166,7 +174,7 @@ func subtestPromptVariablePrintSet(t *testing.T, variableName,
 	assert.Equal(t, currentValue, value,
 		"Unexpected value from prompt print output (at end)")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -218,120 +226,120 @@ func TestPromptDelayTurns(t *testing.T) {
 }
 
 func TestPromptPrintAll(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "print all"
+	proc.InputControl <- "print all"
 
-	_, err := waitOutputTimeout(regexp.MustCompile(`nb-turns-max=100`),
-		proc.outputControl, 1000, true)
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-turns-max=100`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read print nb-turns-max")
 
-	_, err = waitOutputTimeout(regexp.MustCompile(`nb-players-max=4`),
-		proc.outputControl, 1000, true)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-players-max=4`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read print nb-players-max")
 
-	_, err = waitOutputTimeout(regexp.MustCompile(`nb-splayers-max=0`),
-		proc.outputControl, 1000, true)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-splayers-max=0`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read print nb-splayers-max")
 
-	_, err = waitOutputTimeout(regexp.MustCompile(`nb-visus-max=1`),
-		proc.outputControl, 1000, true)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-visus-max=1`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read print nb-visus-max")
 
-	_, err = waitOutputTimeout(regexp.MustCompile(`delay-first-turn=1000`),
-		proc.outputControl, 1000, true)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`delay-first-turn=1000`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read print delay-first-turn")
 
-	_, err = waitOutputTimeout(regexp.MustCompile(`delay-turns=1000`),
-		proc.outputControl, 1000, true)
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`delay-turns=1000`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read print delay-turns")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptPrintBadVariable(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "print unknown-var"
-	_, err := waitOutputTimeout(regexp.MustCompile(`Bad VARIABLE=unknown-var`),
-		proc.outputControl, 1000, true)
+	proc.InputControl <- "print unknown-var"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Bad VARIABLE=unknown-var`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read Bad VARIABLE")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptSetBadVariable(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 
-	proc.inputControl <- "set unknown-var=3"
-	_, err := waitOutputTimeout(regexp.MustCompile(`Bad VARIABLE=unknown-var`),
-		proc.outputControl, 1000, true)
+	proc.InputControl <- "set unknown-var=3"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Bad VARIABLE=unknown-var`),
+		proc.OutputControl, 1000, true)
 	assert.NoError(t, err, "Cannot read Bad VARIABLE")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptInvalidSyntaxPrint(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 	re := regexp.MustCompile(`expected syntax: print VARIABLE`)
 
-	proc.inputControl <- "print"
-	_, err := waitOutputTimeout(re, proc.outputControl, 1000, false)
+	proc.InputControl <- "print"
+	_, err := netorcaitest.WaitOutputTimeout(re, proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read 'expected syntax [...]' after print")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptInvalidSyntaxQuit(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 	re := regexp.MustCompile(`expected syntax: quit`)
 
-	proc.inputControl <- "quit meh"
-	_, err := waitOutputTimeout(re, proc.outputControl, 1000, false)
+	proc.InputControl <- "quit meh"
+	_, err := netorcaitest.WaitOutputTimeout(re, proc.OutputControl, 1000, false)
 	assert.NoError(t, err,
 		"Cannot read 'expected syntax [...]' after quit meh")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptInvalidSyntaxStart(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 	re := regexp.MustCompile(`expected syntax: start`)
 
-	proc.inputControl <- "start meh"
-	_, err := waitOutputTimeout(re, proc.outputControl, 1000, false)
+	proc.InputControl <- "start meh"
+	_, err := netorcaitest.WaitOutputTimeout(re, proc.OutputControl, 1000, false)
 	assert.NoError(t, err,
 		"Cannot read 'expected syntax [...]' after start meh")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
 func TestPromptInvalidSyntaxSet(t *testing.T) {
-	proc := runNetorcaiWaitListening(t, []string{})
-	defer killallNetorcaiSIGKILL()
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
 	re := regexp.MustCompile(`expected syntax: set VARIABLE=VALUE`)
 
-	proc.inputControl <- "set"
-	_, err := waitOutputTimeout(re, proc.outputControl, 1000, false)
+	proc.InputControl <- "set"
+	_, err := netorcaitest.WaitOutputTimeout(re, proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read 'expected syntax [...]' after set")
 
-	proc.inputControl <- "set nb-turns-max"
-	_, err = waitOutputTimeout(re, proc.outputControl, 1000, false)
+	proc.InputControl <- "set nb-turns-max"
+	_, err = netorcaitest.WaitOutputTimeout(re, proc.OutputControl, 1000, false)
 	assert.NoError(t, err, "Cannot read 'expected syntax [...]' after set VAR")
 
-	err = killNetorcaiGently(proc, 1000)
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
 	assert.NoError(t, err, "Netorcai could not be killed gently")
 }
 
@@ -384,3 +392,453 @@ func TestControlProcessInputCatHelloWorld(t *testing.T) {
 	err = cmd.Wait()
 	assert.NoError(t, err, "Could not wait cat's termination")
 }
+
+func TestPromptBreak(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "break meh"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Bad TURN=meh`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read 'Bad TURN' after break meh")
+
+	proc.InputControl <- "break 3"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Breakpoint set at turn 3`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read 'Breakpoint set' after break 3")
+
+	proc.InputControl <- "continue"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is not paused`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read 'Game is not paused' after continue")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestPromptInvalidSyntaxBreak(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+	re := regexp.MustCompile(`expected syntax: break TURN`)
+
+	proc.InputControl <- "break"
+	_, err := netorcaitest.WaitOutputTimeout(re, proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read 'expected syntax [...]' after break")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestPromptMultipleCommands(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// All commands are valid: they should all be executed
+	proc.InputControl <- "set nb-turns-max=42; set delay-turns=500; print nb-turns-max"
+	line, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-turns-max=`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read prompt 'print' output")
+	value, err := promptReadValue(line, "nb-turns-max")
+	assert.NoError(t, err, "Cannot extract value from prompt print output")
+	assert.Equal(t, "42", value, "Unexpected value after multiple commands")
+
+	// One command is invalid: none should be executed
+	proc.InputControl <- "set nb-turns-max=10; set delay-turns=1"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`No command executed`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read 'No command executed'")
+
+	proc.InputControl <- "print nb-turns-max"
+	line, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-turns-max=`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read prompt 'print' output")
+	value, err = promptReadValue(line, "nb-turns-max")
+	assert.NoError(t, err, "Cannot extract value from prompt print output")
+	assert.Equal(t, "42", value, "Value changed while a command was invalid")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestPromptStatus(t *testing.T) {
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "status"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Agame state: not running\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read status game state")
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Agoroutines:\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read status goroutines")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+func TestPromptStats(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=3",
+			"--delay-turns=50"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "stats"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`No turn has been computed yet`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read stats output before game start")
+
+	// Disconnect all players and visus
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "break 1; start"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Breakpoint reached`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Breakpoint not reached")
+
+	proc.InputControl <- "stats"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\AGL compute time \(last 2 turns\)`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot read GL compute time in stats output")
+
+	proc.InputControl <- "continue"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestPromptSetDelayDuringGame(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=3",
+			"--delay-turns=10000"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// Disconnect all players and visus
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// Change the delay while netorcai waits for the second turn.
+	// The game would last 20 seconds without the delay change.
+	proc.InputControl <- "start"
+	time.Sleep(500 * time.Millisecond)
+	proc.InputControl <- "set delay-turns=50"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Delay changed during the game`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Delay change not logged")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestPromptServerConfigInGameStarts(t *testing.T) {
+	proc, _, _, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=500"}, 1000, 0, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// The configuration tuned from the prompt is the one sent
+	proc.InputControl <- "set delay-turns=50"
+	proc.InputControl <- "set nb-turns-max=3"
+	proc.InputControl <- "print nb-turns-max"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`nb-turns-max=3`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Cannot set nb-turns-max")
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	proc.InputControl <- "start"
+
+	msg, err := netorcaitest.WaitReadMessage(visus[0], 1000)
+	assert.NoError(t, err, "Cannot read client message (GAME_STARTS)")
+	config, err := netorcai.ReadObject(msg, "server_config")
+	if assert.NoError(t, err, "Cannot read server_config in GAME_STARTS") {
+		nbTurnsMax, err := netorcai.ReadInt(config, "nb_turns_max")
+		assert.NoError(t, err, "Cannot read nb_turns_max in server_config")
+		assert.Equal(t, 3, nbTurnsMax, "Unexpected nb_turns_max in server_config")
+
+		delayTurns, err := netorcai.ReadInt(config, "milliseconds_between_turns")
+		assert.NoError(t, err, "Cannot read milliseconds_between_turns in server_config")
+		assert.Equal(t, 50, delayTurns,
+			"Unexpected milliseconds_between_turns in server_config")
+
+		fast, err := netorcai.ReadBool(config, "fast")
+		assert.NoError(t, err, "Cannot read fast in server_config")
+		assert.False(t, fast, "Unexpected fast in server_config")
+	}
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestPromptSaveLoadConfig(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "netorcai-config")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(configDir)
+	configFile := filepath.Join(configDir, "netorcai.cfg")
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "set delay-turns=123"
+	proc.InputControl <- "set nb-turns-max=42"
+	proc.InputControl <- "save-config " + configFile
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Configuration saved`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Configuration not saved")
+
+	proc.InputControl <- "set delay-turns=456"
+	proc.InputControl <- "set nb-turns-max=7"
+	proc.InputControl <- "load-config " + configFile
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Configuration loaded`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Configuration not loaded")
+
+	proc.InputControl <- "print delay-turns"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Adelay-turns=123\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Unexpected delay-turns after load-config")
+	proc.InputControl <- "print nb-turns-max"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Anb-turns-max=42\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Unexpected nb-turns-max after load-config")
+
+	// Invalid configurations are not loaded at all
+	err = ioutil.WriteFile(configFile, []byte("nb-turns-max=3\ndelay-turns=1\n"), 0644)
+	assert.NoError(t, err, "Cannot write configuration file")
+	proc.InputControl <- "load-config " + configFile
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Cannot load configuration.*:2: Bad VALUE=1`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Invalid configuration not reported")
+	proc.InputControl <- "print nb-turns-max"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Anb-turns-max=42\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Invalid configuration partially loaded")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
+
+// Reads the next message of a player and returns its type.
+func readMessageType(t *testing.T, c *client.Client) string {
+	msg, err := netorcaitest.WaitReadMessage(c, 2000)
+	assert.NoError(t, err, "Cannot read client message")
+	messageType, _ := netorcai.ReadString(msg, "message_type")
+	return messageType
+}
+
+func TestPromptExtendTurn(t *testing.T) {
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=3",
+			"--delay-turns=300"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "extend-turn 700"
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Cannot extend turn: Game is not running`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Turn extended before the game starts")
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+	proc.InputControl <- "start"
+	assert.Equal(t, "GAME_STARTS", readMessageType(t, players[0]))
+	assert.Equal(t, "TURN", readMessageType(t, players[0]))
+	firstTurnAt := time.Now()
+
+	// Only the current turn is extended
+	proc.InputControl <- "extend-turn 700"
+	err = players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(0, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	assert.Equal(t, "TURN", readMessageType(t, players[0]))
+	secondTurnAt := time.Now()
+	assert.True(t, secondTurnAt.Sub(firstTurnAt) >= 900*time.Millisecond,
+		"Turn not extended")
+
+	err = players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(1, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	readMessageType(t, players[0])
+	assert.True(t, time.Since(secondTurnAt) < 650*time.Millisecond,
+		"Next turn extended too")
+}
+
+func TestPromptSetNbTurnsMaxDuringGame(t *testing.T) {
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=300"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+	proc.InputControl <- "start"
+	assert.Equal(t, "GAME_STARTS", readMessageType(t, players[0]))
+	assert.Equal(t, "TURN", readMessageType(t, players[0]))
+
+	// The game is extended from the next turn on
+	proc.InputControl <- "set nb-turns-max=4"
+	err := players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(0, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	msg, err := netorcaitest.WaitReadMessage(players[0], 2000)
+	assert.NoError(t, err, "Cannot read TURN")
+	turnNumber, _ := netorcai.ReadInt(msg, "turn_number")
+	assert.Equal(t, 1, turnNumber, "Unexpected turn_number in TURN")
+	nbTurnsMax, err := netorcai.ReadInt(msg, "nb_turns_max")
+	assert.NoError(t, err, "Cannot read nb_turns_max in TURN")
+	assert.Equal(t, 4, nbTurnsMax, "Unexpected nb_turns_max in TURN")
+
+	// It cannot end before the turns already played
+	proc.InputControl <- "set nb-turns-max=2"
+	_, err = netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Cannot set nb-turns-max=2: Must be at least 3`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Number of turns decreased below the current turn")
+
+	err = players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(1, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	assert.Equal(t, "TURN", readMessageType(t, players[0]))
+	err = players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(2, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	assert.Equal(t, "GAME_ENDS", readMessageType(t, players[0]))
+}
+
+func TestPromptStop(t *testing.T) {
+	proc, _, players, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=10",
+			"--delay-turns=300"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "stop"
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Cannot stop: Game is not running`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Game stopped before it starts")
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+	proc.InputControl <- "start"
+	assert.Equal(t, "GAME_STARTS", readMessageType(t, players[0]))
+	assert.Equal(t, "TURN", readMessageType(t, players[0]))
+
+	// The game ends cleanly with the next turn
+	proc.InputControl <- "stop"
+	err = players[0].SendString(netorcaitest.DefaultHelloClientTurnAck(0, 0))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+	assert.Equal(t, "GAME_ENDS", readMessageType(t, players[0]))
+	assert.Equal(t, "KICK", readMessageType(t, players[0]))
+}
+
+func TestPromptAbort(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=10",
+			"--delay-turns=300"}, 1000, 1, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	proc.InputControl <- "abort"
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Cannot abort: Game is not running`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Game aborted before it starts")
+
+	glMock := &netorcaitest.MockGameLogic{}
+	runMock(func() (string, error) { return glMock.Run(gl[0]) })
+	proc.InputControl <- "start"
+	for _, c := range []*client.Client{players[0], visus[0]} {
+		assert.Equal(t, "GAME_STARTS", readMessageType(t, c))
+		assert.Equal(t, "TURN", readMessageType(t, c))
+	}
+
+	// Clients are told why the game ends right away
+	proc.InputControl <- "abort Power outage"
+	for _, c := range []*client.Client{players[0], visus[0]} {
+		msg, err := netorcaitest.WaitReadMessage(c, 1000)
+		assert.NoError(t, err, "Cannot read GAME_ENDS")
+		reason, err := netorcai.ReadString(msg, "abort_reason")
+		assert.NoError(t, err, "Cannot read abort_reason in GAME_ENDS")
+		assert.Equal(t, "Power outage", reason, "Unexpected abort_reason")
+		winner, _ := netorcai.ReadInt(msg, "winner_player_id")
+		assert.Equal(t, -1, winner, "Aborted game has a winner")
+
+		msg, err = netorcaitest.WaitReadMessage(c, 1000)
+		assert.NoError(t, err, "Cannot read KICK")
+		netorcaitest.CheckKick(t, msg, "client", regexp.MustCompile(
+			`\AGame aborted by the operator: Power outage\z`))
+		netorcaitest.CheckKickCode(t, msg, "client", netorcai.KICK_ABORTED)
+	}
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 2000)
+	assert.NoError(t, err, "netorcai did not exit")
+	assert.Equal(t, 1, retCode, "Unexpected netorcai return code")
+}
+
+func TestConfigReloadOnSIGHUP(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "netorcai-config")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(configDir)
+	configFile := filepath.Join(configDir, "netorcai.cfg")
+	err = ioutil.WriteFile(configFile, []byte("nb-turns-max=42\n"), 0644)
+	assert.NoError(t, err, "Cannot write configuration file")
+
+	coverFile, _ := netorcaitest.HandleCoverage(t, 0)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile,
+		[]string{"--config=" + configFile})
+	assert.NoError(t, err, "Cannot start netorcai")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\AConfiguration loaded`),
+		proc.OutputControl, 1000, true)
+	assert.NoError(t, err, "Configuration not loaded on startup")
+	_, err = netorcaitest.WaitListening(proc.OutputControl, 1000)
+	assert.NoError(t, err, "Netorcai is not listening")
+
+	proc.InputControl <- "print nb-turns-max"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Anb-turns-max=42\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Unexpected nb-turns-max on startup")
+
+	err = ioutil.WriteFile(configFile, []byte("nb-turns-max=7\n"), 0644)
+	assert.NoError(t, err, "Cannot write configuration file")
+	err = netorcaitest.SignalNetorcai(proc, syscall.SIGHUP)
+	assert.NoError(t, err, "Cannot send SIGHUP")
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Configuration loaded`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Configuration not reloaded on SIGHUP")
+	proc.InputControl <- "print nb-turns-max"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Anb-turns-max=7\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Unexpected nb-turns-max after SIGHUP")
+
+	// Invalid configurations are reported and not loaded
+	err = ioutil.WriteFile(configFile, []byte("nb-turns-max=-1\n"), 0644)
+	assert.NoError(t, err, "Cannot write configuration file")
+	err = netorcaitest.SignalNetorcai(proc, syscall.SIGHUP)
+	assert.NoError(t, err, "Cannot send SIGHUP")
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Cannot reload configuration`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Invalid configuration not reported")
+	proc.InputControl <- "print nb-turns-max"
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`\Anb-turns-max=7\z`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "Invalid configuration loaded on SIGHUP")
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+}
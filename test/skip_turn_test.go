@@ -3,6 +3,7 @@ package test
 import (
 	"fmt"
 	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"sort"
@@ -50,10 +51,10 @@ func TestSkipOneTurnOverTwo(t *testing.T) {
 		7, 7, 7, 7,
 		1, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, checkTurnSkipOneTurnOverTwo, checkTurnSkipOneTurnOverTwo,
-		DefaultHelloClientCheckGameEnds, checkDoTurnSkipOneTurnOverTwo,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckSkipOneTurnOverTwo, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnSkipOneTurnOverTwo, checkTurnSkipOneTurnOverTwo,
+		netorcaitest.DefaultHelloClientCheckGameEnds, checkDoTurnSkipOneTurnOverTwo,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckSkipOneTurnOverTwo, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -101,10 +102,10 @@ func TestSkipFirstTurn(t *testing.T) {
 		4, 4, 4, 4,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, checkTurnSkipFirstTurn, checkTurnSkipFirstTurn,
-		DefaultHelloClientCheckGameEnds, checkDoTurnSkipFirstTurn,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckSkipFirstTurn, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnSkipFirstTurn, checkTurnSkipFirstTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, checkDoTurnSkipFirstTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckSkipFirstTurn, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
@@ -243,10 +244,10 @@ func TestSkipOneTurnMultiClient(t *testing.T) {
 		7, 7, 7, 7,
 		0, 0,
 		false, false,
-		DefaultHelloClientCheckGameStarts, checkTurnSkipOneTurnMultiClient, checkTurnSkipOneTurnMultiClient,
-		DefaultHelloClientCheckGameEnds, checkDoTurnSkipOneTurnMultiClient,
-		DefaultHelloGLDoInitAck, DefaultHelloGlDoTurnAck,
-		turnAckSkipOneTurnMultiClient, DefaultHelloClientTurnAck,
+		netorcaitest.DefaultHelloClientCheckGameStarts, checkTurnSkipOneTurnMultiClient, checkTurnSkipOneTurnMultiClient,
+		netorcaitest.DefaultHelloClientCheckGameEnds, checkDoTurnSkipOneTurnMultiClient,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckSkipOneTurnMultiClient, netorcaitest.DefaultHelloClientTurnAck,
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`),
 		regexp.MustCompile(`Game is finished`))
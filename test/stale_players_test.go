@@ -0,0 +1,90 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Runs a game whose only player is slower than the game at the first turn,
+// and returns the TURN messages received by the player.
+func runStalePlayerGame(t *testing.T, policy string) []map[string]interface{} {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--delay-turns=50",
+			"--nb-turns-max=8", "--autostart", "--nb-players-max=1",
+			"--nb-splayers-max=0", "--nb-visus-max=0",
+			"--stale-players=" + policy}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &netorcaitest.MockGameLogic{}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+	player := &netorcaitest.MockClient{
+		Delay: func(turn int) time.Duration {
+			if turn == 0 {
+				return 140 * time.Millisecond
+			}
+			return 0
+		},
+	}
+	playerResult := runMock(func() (string, error) {
+		return player.Run(playerClients[0])
+	})
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 3000, false)
+	assert.NoError(t, err, "Game did not finish")
+	for _, result := range []chan mockResult{glResult, playerResult} {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	turns := []map[string]interface{}{}
+	for _, msg := range player.Received {
+		if messageType, _ := netorcai.ReadString(msg, "message_type"); messageType == "TURN" {
+			turns = append(turns, msg)
+		}
+	}
+	return turns
+}
+
+func TestStalePlayersCatchUp(t *testing.T) {
+	turns := runStalePlayerGame(t, "catch-up")
+
+	// Skipped turns are announced in the catch-up TURN
+	nbSkipped := 0
+	previousTurnNumber := -1
+	for _, turn := range turns {
+		turnNumber, _ := netorcai.ReadInt(turn, "turn_number")
+		skippedTurns, err := netorcai.ReadInt(turn, "skipped_turns")
+		if err != nil {
+			skippedTurns = 0
+		}
+		assert.Equal(t, turnNumber-previousTurnNumber-1, skippedTurns,
+			"Unexpected 'skipped_turns' value in TURN %v", turnNumber)
+		nbSkipped += skippedTurns
+		previousTurnNumber = turnNumber
+	}
+	assert.True(t, nbSkipped > 0, "The slow player should have skipped turns")
+}
+
+func TestStalePlayersSendAll(t *testing.T) {
+	turns := runStalePlayerGame(t, "send-all")
+
+	// Every turn is received, in order
+	for index, turn := range turns {
+		turnNumber, _ := netorcai.ReadInt(turn, "turn_number")
+		assert.Equal(t, index, turnNumber, "Unexpected TURN")
+		_, exists := turn["skipped_turns"]
+		assert.False(t, exists, "Unexpected 'skipped_turns' in TURN %v", turnNumber)
+	}
+	assert.Equal(t, 7, len(turns), "The slow player should receive all turns")
+}
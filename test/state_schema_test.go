@@ -0,0 +1,67 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/netorcaitest"
+	"regexp"
+	"testing"
+)
+
+// The game state of every turn must have an integral turn
+const doInitAckWithSchema = `{
+  "message_type": "DO_INIT_ACK",
+  "initial_game_state": {"all_clients": {"turn": -1}},
+  "game_state_schema": {
+    "type": "object",
+    "properties": {
+      "all_clients": {
+        "type": "object",
+        "required": ["turn"],
+        "properties": {"turn": {"type": "integer"}}
+      }
+    }
+  }
+}`
+
+func doInitAckSchema(nbPlayers, nbSpecialPlayers, nbTurns int) string {
+	return doInitAckWithSchema
+}
+
+func doInitAckUnsupportedSchema(nbPlayers, nbSpecialPlayers, nbTurns int) string {
+	return `{"message_type":"DO_INIT_ACK",
+		"initial_game_state":{"all_clients":{}},
+		"game_state_schema":{"$ref":"#/definitions/state"}}`
+}
+
+func doTurnAckBreaksSchema(turn int, actions []interface{}) string {
+	return `{"message_type":"DO_TURN_ACK", "winner_player_id":-1,` +
+		`"game_state":{"all_clients":{"turn":"0"}}}`
+}
+
+func TestGameStateSchemaViolation(t *testing.T) {
+	subtestHelloGlActiveClients(t, nil, 1, 0, 1,
+		3, 1, 0, 0,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckSchema, doTurnAckBreaksSchema,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`Game state of turn 0 does not match game_state_schema\. `+
+			`game_state\.all_clients\.turn: expected integer, got string`),
+		regexp.MustCompile(`netorcai abort`),
+		regexp.MustCompile(`netorcai abort`))
+}
+
+func TestGameStateSchemaUnsupported(t *testing.T) {
+	subtestHelloGlActiveClients(t, nil, 1, 0, 1,
+		3, 0, 1, 1,
+		0, 0,
+		false, false,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds, netorcaitest.DefaultHelloGLCheckDoTurn,
+		doInitAckUnsupportedSchema, netorcaitest.DefaultHelloGlDoTurnAck,
+		turnAckNoMsgType, netorcaitest.DefaultHelloClientTurnAck,
+		regexp.MustCompile(`game_state_schema\.\$ref: Unsupported JSON Schema keyword`),
+		regexp.MustCompile(`netorcai abort`),
+		regexp.MustCompile(`netorcai abort`))
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestMaxStateBytesWarn(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=2",
+			"--delay-turns=50", "--max-state-bytes=1"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 2, 2, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game state is bigger than --max-state-bytes`),
+		proc.OutputControl, 1000, false)
+	assert.NoError(t, err, "No warning about the game state size")
+
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestMaxStateBytesAbort(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--max-state-bytes=1", "--state-size-policy=abort"},
+		1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	proc.InputControl <- "start"
+
+	msg, err := netorcaitest.WaitReadMessage(gl[0], 1000)
+	assert.NoError(t, err, "Cannot read GL message (DO_INIT)")
+	netorcaitest.CheckDoInit(t, msg, 0, 0, 100)
+
+	err = gl[0].SendString(netorcaitest.DefaultHelloGLDoInitAck(0, 0, 100))
+	assert.NoError(t, err, "Cannot send DO_INIT_ACK")
+
+	msg, err = netorcaitest.WaitReadMessage(gl[0], 1000)
+	assert.NoError(t, err, "Cannot read GL message (KICK)")
+	netorcaitest.CheckKick(t, msg, "GL", regexp.MustCompile(`Game state too big`))
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, 1, retCode, "Unexpected netorcai return code")
+}
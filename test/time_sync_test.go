@@ -0,0 +1,69 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func unixMilliseconds(t time.Time) int {
+	return int(t.UnixNano() / int64(time.Millisecond))
+}
+
+func TestTimeSyncPing(t *testing.T) {
+	proc, _, playerClients, _, visuClients, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{}, 1000, 1, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	for _, client := range append(playerClients, visuClients...) {
+		sentAt := unixMilliseconds(time.Now())
+		err := client.SendString(fmt.Sprintf(
+			`{"message_type":"PING", "client_time":%v}`, sentAt))
+		assert.NoError(t, err, "Cannot send PING")
+
+		msg, err := netorcaitest.WaitReadMessage(client, 1000)
+		receivedAt := unixMilliseconds(time.Now())
+		if !assert.NoError(t, err, "Cannot read PONG") {
+			continue
+		}
+		messageType, _ := netorcai.ReadString(msg, "message_type")
+		assert.Equal(t, "PONG", messageType, "Unexpected message")
+
+		// All clocks are the same: The four times are ordered
+		clientTime, err := netorcai.ReadInt(msg, "client_time")
+		assert.NoError(t, err, "Cannot read 'client_time'")
+		assert.Equal(t, sentAt, clientTime, "Unexpected 'client_time'")
+		receiveTime, err := netorcai.ReadInt(msg, "receive_time")
+		assert.NoError(t, err, "Cannot read 'receive_time'")
+		sendTime, err := netorcai.ReadInt(msg, "send_time")
+		assert.NoError(t, err, "Cannot read 'send_time'")
+		assert.True(t, sentAt <= receiveTime && receiveTime <= sendTime &&
+			sendTime <= receivedAt, "Unordered times: %v %v %v %v", sentAt,
+			receiveTime, sendTime, receivedAt)
+	}
+
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
+}
+
+func TestInvalidTimeSyncPing(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(t,
+		[]string{}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	playerClients[0].SendString(`{"message_type":"PING", "client_time":-1}`)
+	msg, err := netorcaitest.WaitReadMessage(playerClients[0], 1000)
+	assert.NoError(t, err, "Cannot read ERROR")
+	netorcaitest.CheckError(t, msg, "Player", "client_time")
+	netorcaitest.CheckAllKicked(t, playerClients,
+		regexp.MustCompile(`Invalid PING received`), 1000)
+
+	proc.InputControl <- `quit`
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	netorcaitest.CheckAllKicked(t, glClients, regexp.MustCompile(`netorcai abort`), 1000)
+}
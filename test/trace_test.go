@@ -0,0 +1,86 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestTraceMessages(t *testing.T) {
+	traceDir, err := ioutil.TempDir("", "netorcai-trace")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(traceDir)
+	traceFile := filepath.Join(traceDir, "trace.json")
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--trace-messages=" + traceFile, "--trace-payload-max=4"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	var client client.Client
+	err = client.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	defer client.Disconnect()
+
+	err = client.SendString(`definitely not JSON`)
+	assert.NoError(t, err, "Cannot send message")
+
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read client message (KICK)")
+	netorcaitest.CheckKick(t, msg, "InvalidClient", regexp.MustCompile("Non-JSON"))
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+
+	content, err := ioutil.ReadFile(traceFile)
+	assert.NoError(t, err, "Cannot read trace file")
+	assert.Regexp(t, `"direction":"in".*"content_size":20,"payload":"defi","truncated":true`,
+		string(content), "Received message not traced")
+	assert.Regexp(t, `"direction":"out".*"payload":"{\\"me"`,
+		string(content), "Sent message not traced")
+}
+
+func TestTraceMessagesRedactAPIKey(t *testing.T) {
+	traceDir, err := ioutil.TempDir("", "netorcai-trace")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(traceDir)
+	traceFile := filepath.Join(traceDir, "trace.json")
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{
+		"--trace-messages=" + traceFile})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	var client client.Client
+	err = client.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	defer client.Disconnect()
+
+	apiKey := "0123456789abcdef-secret-api-key"
+	err = client.SendJSON(map[string]interface{}{
+		"message_type":         "LOGIN",
+		"role":                 "player",
+		"nickname":             "alice",
+		"metaprotocol_version": netorcai.Version,
+		"api_key":              apiKey,
+	})
+	assert.NoError(t, err, "Cannot send LOGIN")
+
+	msg, err := netorcaitest.WaitReadMessage(&client, 1000)
+	assert.NoError(t, err, "Cannot read LOGIN_ACK")
+	netorcaitest.CheckLoginAck(t, msg)
+
+	err = netorcaitest.KillNetorcaiGently(proc, 1000)
+	assert.NoError(t, err, "Netorcai could not be killed gently")
+
+	content, err := ioutil.ReadFile(traceFile)
+	assert.NoError(t, err, "Cannot read trace file")
+	assert.Regexp(t, `"direction":"in".*api_key.*redacted.*LOGIN`,
+		string(content), "LOGIN not traced")
+	assert.NotContains(t, string(content), apiKey,
+		"The API key should not be traced")
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTurnHistoryLateVisu(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--delay-turns=50",
+			"--nb-turns-max=20", "--turn-history=3"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &netorcaitest.MockGameLogic{}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+	player := &netorcaitest.MockClient{}
+	playerResult := runMock(func() (string, error) {
+		return player.Run(playerClients[0])
+	})
+
+	// A visualization logs in while the game is running
+	proc.InputControl <- `start`
+	time.Sleep(400 * time.Millisecond)
+	visuClient, _ := netorcaitest.ConnectClient(t, "visualization", "late",
+		netorcai.Version, 1000)
+	visu := &netorcaitest.MockClient{}
+	visuResult := runMock(func() (string, error) { return visu.Run(visuClient) })
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 3000, false)
+	assert.NoError(t, err, "Game did not finish")
+	for _, result := range []chan mockResult{glResult, playerResult, visuResult} {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+
+	// GAME_STARTS, then the kept past turns and the next ones, then GAME_ENDS
+	if !assert.True(t, len(visu.Received) > 2, "Too few messages received") {
+		return
+	}
+	messageType, _ := netorcai.ReadString(visu.Received[0], "message_type")
+	assert.Equal(t, "GAME_STARTS", messageType, "Unexpected first message")
+	messageType, _ = netorcai.ReadString(visu.Received[len(visu.Received)-2],
+		"message_type")
+	assert.Equal(t, "GAME_ENDS", messageType, "Unexpected last message")
+
+	turns := visu.Received[1 : len(visu.Received)-2]
+	firstTurnNumber, _ := netorcai.ReadInt(turns[0], "turn_number")
+	assert.True(t, firstTurnNumber > 0, "The past turns should be trimmed")
+	for index, turn := range turns {
+		turnNumber, _ := netorcai.ReadInt(turn, "turn_number")
+		assert.Equal(t, firstTurnNumber+index, turnNumber, "Unexpected TURN")
+	}
+	assert.Equal(t, 18, firstTurnNumber+len(turns)-1, "Unexpected last TURN")
+}
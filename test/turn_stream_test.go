@@ -0,0 +1,109 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTurnStream(t *testing.T, accept string) *http.Response {
+	request, err := http.NewRequest("GET", "http://localhost:4243/turns", nil)
+	assert.NoError(t, err, "Cannot create turn stream request")
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+
+	var resp *http.Response
+	for i := 0; i < 10; i++ {
+		resp, err = http.DefaultClient.Do(request)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NoError(t, err, "Cannot open turn stream")
+	return resp
+}
+
+// Reads the lines of a stream until it is closed.
+func readStreamLines(resp *http.Response, lines chan []string) {
+	defer resp.Body.Close()
+	read := []string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		read = append(read, scanner.Text())
+	}
+	lines <- read
+}
+
+func TestTurnStream(t *testing.T) {
+	proc, _, players, _, visus, gl := netorcaitest.RunNetorcaiAndAllClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=3",
+			"--delay-turns=50", "--admin-port=4243"}, 1000, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// Disconnect all players and visus
+	for _, client := range append(players, visus...) {
+		client.Disconnect()
+		netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Remote endpoint closed`),
+			proc.OutputControl, 1000, false)
+	}
+
+	ndjsonResp := openTurnStream(t, "")
+	assert.Equal(t, "application/x-ndjson", ndjsonResp.Header.Get("Content-Type"))
+	ndjsonLines := make(chan []string)
+	go readStreamLines(ndjsonResp, ndjsonLines)
+
+	sseResp := openTurnStream(t, "text/event-stream")
+	assert.Equal(t, "text/event-stream", sseResp.Header.Get("Content-Type"))
+	sseLines := make(chan []string)
+	go readStreamLines(sseResp, sseLines)
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	proc.InputControl <- "start"
+
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+
+	select {
+	case lines := <-ndjsonLines:
+		messageTypes := []string{}
+		for _, line := range lines {
+			var message map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(line), &message),
+				"Invalid streamed message: %v", line)
+			messageTypes = append(messageTypes, message["message_type"].(string))
+		}
+		assert.Equal(t, []string{"TURN", "TURN", "GAME_ENDS"}, messageTypes,
+			"Unexpected streamed messages")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "NDJSON stream did not end")
+	}
+
+	select {
+	case lines := <-sseLines:
+		events := []string{}
+		for _, line := range lines {
+			if strings.HasPrefix(line, "event: ") {
+				events = append(events, strings.TrimPrefix(line, "event: "))
+			} else if line != "" {
+				assert.True(t, strings.HasPrefix(line, "data: {"),
+					"Unexpected event line: %v", line)
+			}
+		}
+		assert.Equal(t, []string{"TURN", "TURN", "GAME_ENDS"}, events,
+			"Unexpected streamed events")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "SSE stream did not end")
+	}
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func doTurnAckTurnState(turn int, actions []interface{}) string {
+	return fmt.Sprintf(`{"message_type":"DO_TURN_ACK",
+		"winner_player_id":-1,
+		"game_state":{"all_clients":{"turn":%v}}}`, turn)
+}
+
+func doTurnAckOtherTurnState(turn int, actions []interface{}) string {
+	return fmt.Sprintf(`{"message_type":"DO_TURN_ACK",
+		"winner_player_id":-1,
+		"game_state":{"all_clients":{"turn":%v}}}`, turn*2)
+}
+
+// Records a 3-turn game (whose states contain the turn number) in dumpDir.
+func recordGame(t *testing.T, dumpDir string) {
+	proc, _, _, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=100", "--nb-turns-max=3",
+			"--delay-turns=100", "--dump-states=" + dumpDir}, 1000, 0, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckTurnState,
+		regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+	_, err := netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Game did not finish")
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func subtestVerifyReplay(t *testing.T, doTurnAckFunc netorcaitest.GLDoTurnAckFunc,
+	expectedOutput *regexp.Regexp, expectedReturnCode int) {
+	dumpDir, err := ioutil.TempDir("", "netorcai-verify-replay")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dumpDir)
+
+	recordGame(t, dumpDir)
+
+	// The game logic is run by the test, the command only keeps netorcai busy
+	coverFile, expRetCode := netorcaitest.HandleCoverage(t, expectedReturnCode)
+	proc, err := netorcaitest.RunNetorcaiCover(coverFile, []string{"validate-replay", dumpDir,
+		"--gl-command=sleep 10"})
+	assert.NoError(t, err, "Cannot start netorcai verify-replay")
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &client.Client{}
+	for try := 0; try < 50; try++ {
+		if err = gl.Connect("localhost", 4242); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.NoError(t, err, "Cannot connect")
+
+	err = gl.SendLogin("game logic", "gl", netorcai.Version)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := netorcaitest.WaitReadMessage(gl, 1000)
+	assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
+	netorcaitest.CheckLoginAck(t, msg)
+
+	go netorcaitest.HelloGameLogic(t, gl, 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, doTurnAckFunc,
+		regexp.MustCompile(`Replay is finished`))
+
+	_, err = netorcaitest.WaitOutputTimeout(expectedOutput, proc.OutputControl, 5000, false)
+	assert.NoError(t, err, "Unexpected verify-replay output")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai verify-replay did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai verify-replay return code")
+}
+
+func TestVerifyReplayDeterministic(t *testing.T) {
+	subtestVerifyReplay(t, doTurnAckTurnState,
+		regexp.MustCompile(`\A\[OK\]   turn 2: `), 0)
+}
+
+func TestVerifyReplayNondeterministic(t *testing.T) {
+	subtestVerifyReplay(t, doTurnAckOtherTurnState,
+		regexp.MustCompile(`\A\[FAIL\] turn 1: recorded [0-9a-f]{16}, replayed [0-9a-f]{16}`), 1)
+}
+
+func TestReplayToVisu(t *testing.T) {
+	dumpDir, err := ioutil.TempDir("", "netorcai-replay")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dumpDir)
+
+	recordGame(t, dumpDir)
+
+	proc := netorcaitest.RunNetorcaiWaitListening(t, []string{"replay",
+		dumpDir, "--delay-first-turn=50", "--delay-turns=50"})
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	visu, err := netorcaitest.ConnectClient(t, "visualization", "visu",
+		netorcai.Version, 1000)
+	assert.NoError(t, err, "Cannot connect visualization")
+
+	msg, err := netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read GAME_STARTS")
+	netorcaitest.CheckGameStarts(t, msg, 0, 0, 3, 50, 50, false)
+
+	// The recorded game states are sent in order
+	for turn := 0; turn < 2; turn++ {
+		msg, err = netorcaitest.WaitReadMessage(visu, 1000)
+		assert.NoError(t, err, "Cannot read TURN")
+		netorcaitest.CheckTurn(t, msg, 0, 0, turn, false)
+		gameState, err := netorcai.ReadObject(msg, "game_state")
+		assert.NoError(t, err, "Cannot read game_state")
+		recordedTurn, err := netorcai.ReadInt(gameState, "turn")
+		assert.NoError(t, err, "Cannot read recorded turn")
+		assert.Equal(t, turn, recordedTurn, "Unexpected replayed game state")
+
+		err = visu.SendString(netorcaitest.DefaultHelloClientTurnAck(turn, -1))
+		assert.NoError(t, err, "Cannot send TURN_ACK")
+	}
+
+	msg, err = netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read GAME_ENDS")
+	netorcaitest.CheckGameEnds(t, msg, "visu")
+
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai replay did not complete")
+	assert.Equal(t, 0, retCode, "Unexpected netorcai replay return code")
+}
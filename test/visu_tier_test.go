@@ -0,0 +1,178 @@
+package test
+
+import (
+	"github.com/netorcai/netorcai"
+	"github.com/netorcai/netorcai/client/go"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPublicVisuDelay(t *testing.T) {
+	proc, _, _, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=100", "--nb-turns-max=5",
+			"--delay-turns=100", "--nb-visus-max=2",
+			"--public-visu-delay=2"}, 1000, 0, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// Connect a live visualization
+	liveVisu := &client.Client{}
+	err := liveVisu.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	err = liveVisu.SendString(`{"message_type":"LOGIN", "role":"visualization", "nickname":"live", "metaprotocol_version": "` + netorcai.Version + `", "visu_tier": "live"}`)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := netorcaitest.WaitReadMessage(liveVisu, 1000)
+	assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
+	netorcaitest.CheckLoginAck(t, msg)
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 5, 5, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+
+	// The live visualization receives all turns (0 to 3)
+	go netorcaitest.HelloClient(t, liveVisu, "LiveVisu", 0, 0, 5, 5, 0, 100, 100,
+		false, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+
+	// The public visualization lags 2 turns behind: It only receives
+	// turns 0 and 1 before the game ends
+	go netorcaitest.HelloClient(t, visus[0], "PublicVisu", 0, 0, 5, 3, 0, 100, 100,
+		false, false, true, true,
+		netorcaitest.DefaultHelloClientCheckGameStarts, netorcaitest.DefaultHelloClientCheckTurn,
+		netorcaitest.DefaultHelloClientCheckGameEnds,
+		netorcaitest.DefaultHelloClientTurnAck, regexp.MustCompile(`Game is finished`))
+
+	proc.InputControl <- "start"
+
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestVisuFrameSkipping(t *testing.T) {
+	proc, _, _, _, _, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=10",
+			"--delay-turns=50"}, 1000, 0, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	visu := &client.Client{}
+	err := visu.Connect("localhost", 4242)
+	assert.NoError(t, err, "Cannot connect")
+	err = visu.SendString(`{"message_type":"LOGIN", "role":"visualization", "nickname":"slow", "metaprotocol_version": "` + netorcai.Version + `", "frame_skipping": true}`)
+	assert.NoError(t, err, "Cannot send LOGIN")
+	msg, err := netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read client message (LOGIN_ACK)")
+	netorcaitest.CheckLoginAck(t, msg)
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 10, 10, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	proc.InputControl <- "start"
+
+	msg, err = netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read client message (GAME_STARTS)")
+	msg, err = netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read client message (TURN)")
+	turn, err := netorcai.ReadInt(msg, "turn_number")
+	assert.NoError(t, err, "Cannot read turn_number")
+	assert.Equal(t, 0, turn, "Unexpected first turn")
+
+	// The visualization is slower than the game: Turns are skipped
+	time.Sleep(300 * time.Millisecond)
+	err = visu.SendString(netorcaitest.DefaultHelloClientTurnAck(turn, -1))
+	assert.NoError(t, err, "Cannot send TURN_ACK")
+
+	msg, err = netorcaitest.WaitReadMessage(visu, 1000)
+	assert.NoError(t, err, "Cannot read client message (TURN)")
+	nextTurn, err := netorcai.ReadInt(msg, "turn_number")
+	assert.NoError(t, err, "Cannot read turn_number")
+	assert.True(t, nextTurn > 1, "Turns should have been skipped")
+	skipped, err := netorcai.ReadInt(msg, "skipped_turns")
+	assert.NoError(t, err, "Cannot read skipped_turns")
+	assert.Equal(t, nextTurn-turn-1, skipped, "Unexpected skipped_turns")
+
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestVisuTurnTimes(t *testing.T) {
+	proc, _, _, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=3",
+			"--delay-turns=100"}, 1000, 0, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 3, 3, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	proc.InputControl <- "start"
+
+	_, err := netorcaitest.WaitReadMessage(visus[0], 1000)
+	assert.NoError(t, err, "Cannot read client message (GAME_STARTS)")
+	msg, err := netorcaitest.WaitReadMessage(visus[0], 1000)
+	assert.NoError(t, err, "Cannot read client message (TURN)")
+
+	timestamp, err := netorcai.ReadInt(msg, "timestamp_ms")
+	assert.NoError(t, err, "Cannot read timestamp_ms")
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	assert.InDelta(t, now, timestamp, 1000, "Unexpected timestamp_ms")
+	eta, err := netorcai.ReadInt(msg, "next_turn_eta_ms")
+	assert.NoError(t, err, "Cannot read next_turn_eta_ms")
+	assert.Equal(t, 100, eta-timestamp, "Unexpected next_turn_eta_ms")
+
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
+
+func TestVisuMaxTurnRate(t *testing.T) {
+	proc, _, _, _, visus, gl := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--nb-turns-max=20",
+			"--delay-turns=50", "--max-turn-rate-visus=4"}, 1000, 0, 0, 1)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	go netorcaitest.HelloGameLogic(t, gl[0], 0, 0, 20, 20, netorcaitest.DefaultHelloGLCheckDoTurn,
+		netorcaitest.DefaultHelloGLDoInitAck, netorcaitest.DefaultHelloGlDoTurnAck,
+		regexp.MustCompile(`Game is finished`))
+	proc.InputControl <- "start"
+
+	_, err := netorcaitest.WaitReadMessage(visus[0], 1000)
+	assert.NoError(t, err, "Cannot read client message (GAME_STARTS)")
+
+	// The visualization acknowledges turns right away, but receives at most
+	// one every 250 ms: The turns in between are coalesced
+	turns := []int{}
+	var lastReceivedAt time.Time
+	for {
+		msg, err := netorcaitest.WaitReadMessage(visus[0], 2000)
+		if !assert.NoError(t, err, "Cannot read client message") {
+			break
+		}
+		if msg["message_type"] != "TURN" {
+			assert.Equal(t, "GAME_ENDS", msg["message_type"], "Unexpected message")
+			break
+		}
+		if !lastReceivedAt.IsZero() {
+			assert.True(t, time.Since(lastReceivedAt) > 200*time.Millisecond,
+				"TURN received too early")
+		}
+		lastReceivedAt = time.Now()
+
+		turn, err := netorcai.ReadInt(msg, "turn_number")
+		assert.NoError(t, err, "Cannot read turn_number")
+		turns = append(turns, turn)
+		err = visus[0].SendString(netorcaitest.DefaultHelloClientTurnAck(turn, -1))
+		assert.NoError(t, err, "Cannot send TURN_ACK")
+	}
+	assert.True(t, len(turns) > 1, "Several turns should have been received")
+	assert.True(t, len(turns) < 10, "Turns should have been coalesced")
+	assert.Equal(t, 0, turns[0], "Unexpected first turn")
+
+	netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 5000, false)
+	netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+}
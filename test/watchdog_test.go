@@ -0,0 +1,107 @@
+package test
+
+import (
+	"fmt"
+	"github.com/netorcai/netorcai/netorcaitest"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const (
+	watchdogTimeoutMS = 300
+	// A hang is detected at most timeout + timeout/4 (the check period)
+	// after the last progress. The margin absorbs the scheduling of loaded
+	// test machines.
+	watchdogDetectionMS = watchdogTimeoutMS + watchdogTimeoutMS/4 + 2000
+)
+
+func TestWatchdogHungGameLogic(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--delay-first-turn=50", "--delay-turns=50",
+			fmt.Sprintf("--watchdog=%v", watchdogTimeoutMS)}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	// The game logic stops answering at the second turn
+	gl := &netorcaitest.MockGameLogic{
+		Fault: func(turn int) netorcaitest.Fault {
+			if turn == 1 {
+				return netorcaitest.FaultNoAnswer
+			}
+			return netorcaitest.NoFault
+		},
+	}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+	player := &netorcaitest.MockClient{}
+	playerResult := runMock(func() (string, error) {
+		return player.Run(playerClients[0])
+	})
+
+	proc.InputControl <- `start`
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`No protocol progress: game is hung`),
+		proc.OutputControl, watchdogDetectionMS, false)
+	assert.NoError(t, err, "Hung game not detected")
+
+	_, expRetCode := netorcaitest.HandleCoverage(t, 1)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+
+	for _, result := range []chan mockResult{glResult, playerResult} {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Regexp(t, `netorcai abort`, r.kickReason, "Unexpected kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+}
+
+func TestWatchdogAdvance(t *testing.T) {
+	proc, _, playerClients, _, _, glClients := netorcaitest.RunNetorcaiAndClients(
+		t, []string{"--fast", "--nb-turns-max=3", "--autostart",
+			"--nb-players-max=1", "--nb-splayers-max=0", "--nb-visus-max=0",
+			fmt.Sprintf("--watchdog=%v", watchdogTimeoutMS),
+			"--watchdog-action=advance"}, 1000, 1, 0, 0)
+	defer netorcaitest.KillallNetorcaiSIGKILL()
+
+	gl := &netorcaitest.MockGameLogic{}
+	glResult := runMock(func() (string, error) { return gl.Run(glClients[0]) })
+
+	// The player never answers: Each turn is forced to advance
+	player := &netorcaitest.MockClient{
+		Fault: func(turn int) netorcaitest.Fault {
+			return netorcaitest.FaultNoAnswer
+		},
+	}
+	playerResult := runMock(func() (string, error) {
+		return player.Run(playerClients[0])
+	})
+
+	_, err := netorcaitest.WaitOutputTimeout(
+		regexp.MustCompile(`Forcing the game to advance`),
+		proc.OutputControl, watchdogDetectionMS, false)
+	assert.NoError(t, err, "Game not forced to advance")
+	// The two remaining turns are forced to advance as well
+	_, err = netorcaitest.WaitOutputTimeout(regexp.MustCompile(`Game is finished`),
+		proc.OutputControl, 2*watchdogDetectionMS, false)
+	assert.NoError(t, err, "Game did not finish")
+
+	_, expRetCode := netorcaitest.HandleCoverage(t, 0)
+	retCode, err := netorcaitest.WaitCompletionTimeout(proc.Completion, 1000)
+	assert.NoError(t, err, "netorcai did not complete")
+	assert.Equal(t, expRetCode, retCode, "Unexpected netorcai return code")
+
+	for _, result := range []chan mockResult{glResult, playerResult} {
+		select {
+		case r := <-result:
+			assert.NoError(t, r.err, "Mock failed")
+			assert.Equal(t, "Game is finished", r.kickReason, "Unexpected kick reason")
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "Mock was not kicked")
+		}
+	}
+}
@@ -0,0 +1,1030 @@
+package netorcai
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A tournament player: a bot run by a shell command.
+// The command gets the port of its game (NETORCAI_PORT) and the nickname it
+// must log in with (NETORCAI_NICKNAME) in its environment.
+// A bot that listens on Address (host:port) instead has no command: The
+// netorcai process of its game connects to it (as --connect-player does),
+// and the bot logs in with its name.
+type TournamentPlayer struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	Address string `json:"address,omitempty"`
+}
+
+// What happened in a tournament game, as logged by its netorcai process.
+// Latencies are the mean TURN_ACK latencies (in milliseconds) of the
+// players. Players that lost their connection during the game crashed,
+// the other players kicked during the game are in Kicked.
+// CPUTimes are the CPU times (in milliseconds) used by the commands of the
+// players during the game, and TurnCPUTimes their CPU times during each
+// turn (from a TURN to the next one). They are only measured on Linux.
+// ProtocolStats are the protocol statistics of the players (see GAME_ENDS).
+// AbortReason is the reason of the operator if they aborted the game.
+type GameSummary struct {
+	NbTurns       int                            `json:"nb_turns"`
+	Latencies     map[string]float64             `json:"latencies_ms,omitempty"`
+	Crashed       []string                       `json:"crashed,omitempty"`
+	Kicked        []string                       `json:"kicked,omitempty"`
+	CPUTimes      map[string]float64             `json:"cpu_times_ms,omitempty"`
+	TurnCPUTimes  map[string][]float64           `json:"turn_cpu_times_ms,omitempty"`
+	ProtocolStats map[string]ClientProtocolStats `json:"protocol_stats,omitempty"`
+	AbortReason   string                         `json:"abort_reason,omitempty"`
+}
+
+// The result of a tournament match. Winner is empty if there is no winner.
+type TournamentMatch struct {
+	ID      string   `json:"id"`
+	Round   int      `json:"round"`
+	Players []string `json:"players"`
+	Winner  string   `json:"winner"`
+	Error   string   `json:"error,omitempty"`
+	GameSummary
+}
+
+// How the games of a tournament are run.
+// Each game is a netorcai process (Executable) that runs on its own port:
+// Games run in parallel in NbRooms rooms, room i using port Port+i.
+// NbRounds is the number of Swiss rounds (0 means enough rounds to
+// separate a single winner).
+// If MatchLogsDirectory is set, the logs of each game (and the output of
+// its commands) are written in it, in a file named by match ID.
+// The commands of a game (game logic and players) are killed if they exceed
+// Limits: Such players are considered kicked.
+// If Sandbox is set, player commands are run in a sandbox (see
+// RunSandboxHelper).
+// If GLChecksum is set, the game logic executable (GLExecutable, or the
+// first word of GLCommand) must have this SHA-256 checksum (hex), which is
+// checked before each game (see CheckGLExecutable).
+type TournamentSettings struct {
+	Executable         string
+	GameArguments      []string
+	GLCommand          string
+	GLExecutable       string
+	GLChecksum         string
+	Port               int
+	NbRooms            int
+	NbRounds           int
+	MatchTimeout       time.Duration
+	MatchLogsDirectory string
+	Limits             ResourceLimits
+	Sandbox            bool
+}
+
+// Runs a match in a room. Returns the name of the winner ("" if none)
+// and the summary of the game.
+type matchRunner func(players []TournamentPlayer, room int,
+	matchID string) (string, GameSummary, error)
+
+// Checks that players have valid and unique names (their nicknames),
+// and a command or an address.
+func checkTournamentPlayers(players []TournamentPlayer, nbPlayersMin int) error {
+	names := make(map[string]bool)
+	for _, player := range players {
+		if !regexp.MustCompile(`\A\S{1,10}\z`).MatchString(player.Name) {
+			return fmt.Errorf("Invalid player name '%v' (it is used as "+
+				"nickname: 1 to 10 non-space characters)", player.Name)
+		}
+		if strings.TrimSpace(player.Command) == "" && player.Address == "" {
+			return fmt.Errorf("Player %v has no command", player.Name)
+		}
+		if names[player.Name] {
+			return fmt.Errorf("Player %v is defined twice", player.Name)
+		}
+		names[player.Name] = true
+	}
+
+	if len(players) < nbPlayersMin {
+		return fmt.Errorf("At least %v players are needed (%v defined)",
+			nbPlayersMin, len(players))
+	}
+	return nil
+}
+
+// Reads a tournament players file. Each non-empty line (except comments,
+// starting with #) contains a player name followed by its command.
+func ReadTournamentPlayers(filename string) ([]TournamentPlayer, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	players := []TournamentPlayer{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name := strings.Fields(line)[0]
+		players = append(players, TournamentPlayer{
+			Name:    name,
+			Command: strings.TrimSpace(line[len(name):]),
+		})
+	}
+
+	err = checkTournamentPlayers(players, 2)
+	if err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+// Runs a whole tournament, printing its progression.
+// Returns the results of all its matches.
+func RunTournament(bracket string, players []TournamentPlayer,
+	settings TournamentSettings) ([]TournamentMatch, error) {
+	runMatch := func(matchPlayers []TournamentPlayer, room int,
+		matchID string) (string, GameSummary, error) {
+		return runTournamentGame(matchID, matchPlayers, settings,
+			settings.Port+room)
+	}
+
+	switch bracket {
+	case "single-elim":
+		matches, _ := runSingleElimination(players, settings.NbRooms, runMatch)
+		return matches, nil
+	case "swiss":
+		matches, _ := runSwiss(players, settings.NbRounds, settings.NbRooms,
+			runMatch)
+		return matches, nil
+	default:
+		return nil, fmt.Errorf("Bad bracket=%v. Accepted values: single-elim swiss",
+			bracket)
+	}
+}
+
+// Aggregate statistics of a player over the matches of a tournament.
+// MeanLatency is the mean of its TURN_ACK latencies (in milliseconds) over
+// the matches it played turns in. MeanNbTurns is the mean length (in turns)
+// of its games. CPUTime is the CPU time (in milliseconds) used by its command
+// over all its matches, and MaxTurnCPUTime the maximum CPU time it used
+// during a turn.
+type TournamentPlayerReport struct {
+	Name           string  `json:"name"`
+	NbMatches      int     `json:"nb_matches"`
+	NbWins         int     `json:"nb_wins"`
+	WinRate        float64 `json:"win_rate"`
+	MeanLatency    float64 `json:"mean_latency_ms"`
+	NbCrashes      int     `json:"nb_crashes"`
+	NbKicks        int     `json:"nb_kicks"`
+	MeanNbTurns    float64 `json:"mean_nb_turns"`
+	CPUTime        float64 `json:"cpu_time_ms"`
+	MaxTurnCPUTime float64 `json:"max_turn_cpu_time_ms"`
+}
+
+// Aggregate statistics of a tournament, per player.
+type TournamentReport struct {
+	NbMatches   int                      `json:"nb_matches"`
+	MeanNbTurns float64                  `json:"mean_nb_turns"`
+	Players     []TournamentPlayerReport `json:"players"`
+}
+
+// Aggregates the results of the matches of a tournament.
+// Players are reported in the given order.
+func ReportTournament(players []TournamentPlayer,
+	matches []TournamentMatch) TournamentReport {
+	type playerTotals struct {
+		nbTurns      int
+		totalLatency float64
+		nbLatencies  int
+	}
+
+	report := TournamentReport{
+		NbMatches: len(matches),
+		Players:   []TournamentPlayerReport{},
+	}
+	reports := make(map[string]*TournamentPlayerReport)
+	totals := make(map[string]*playerTotals)
+	for _, player := range players {
+		report.Players = append(report.Players,
+			TournamentPlayerReport{Name: player.Name})
+		totals[player.Name] = &playerTotals{}
+	}
+	for index := range report.Players {
+		reports[report.Players[index].Name] = &report.Players[index]
+	}
+
+	totalNbTurns := 0
+	for _, match := range matches {
+		totalNbTurns += match.NbTurns
+		for _, name := range match.Players {
+			player, exists := reports[name]
+			if !exists {
+				continue
+			}
+
+			player.NbMatches++
+			if match.Winner == name {
+				player.NbWins++
+			}
+			totals[name].nbTurns += match.NbTurns
+			if latency, known := match.Latencies[name]; known {
+				totals[name].totalLatency += latency
+				totals[name].nbLatencies++
+			}
+			player.CPUTime += match.CPUTimes[name]
+			for _, turnCPUTime := range match.TurnCPUTimes[name] {
+				player.MaxTurnCPUTime = math.Max(player.MaxTurnCPUTime,
+					turnCPUTime)
+			}
+		}
+		for _, name := range match.Crashed {
+			if player, exists := reports[name]; exists {
+				player.NbCrashes++
+			}
+		}
+		for _, name := range match.Kicked {
+			if player, exists := reports[name]; exists {
+				player.NbKicks++
+			}
+		}
+	}
+
+	if len(matches) > 0 {
+		report.MeanNbTurns = float64(totalNbTurns) / float64(len(matches))
+	}
+	for index := range report.Players {
+		player := &report.Players[index]
+		total := totals[player.Name]
+		if player.NbMatches > 0 {
+			player.WinRate = float64(player.NbWins) / float64(player.NbMatches)
+			player.MeanNbTurns = float64(total.nbTurns) /
+				float64(player.NbMatches)
+		}
+		if total.nbLatencies > 0 {
+			player.MeanLatency = total.totalLatency /
+				float64(total.nbLatencies)
+		}
+	}
+	return report
+}
+
+// Prints the report of a tournament.
+func (r TournamentReport) Print() {
+	fmt.Printf("Report (%v matches, %.1f turns per game)\n", r.NbMatches,
+		r.MeanNbTurns)
+	for _, player := range r.Players {
+		fmt.Printf("  %v: win rate=%.1f%% (%v/%v), mean latency=%.3f ms, "+
+			"crashes=%v, kicks=%v, turns per game=%.1f, "+
+			"CPU time=%.0f ms (%.0f ms per turn max)\n", player.Name,
+			player.WinRate*100, player.NbWins, player.NbMatches,
+			player.MeanLatency, player.NbCrashes, player.NbKicks,
+			player.MeanNbTurns, player.CPUTime, player.MaxTurnCPUTime)
+	}
+}
+
+// Runs the matches of a round, using at most nbRooms rooms at the same time.
+func runRound(round int, pairings [][]TournamentPlayer, nbRooms int,
+	runMatch matchRunner) []TournamentMatch {
+	rooms := make(chan int, nbRooms)
+	for room := 0; room < nbRooms; room++ {
+		rooms <- room
+	}
+
+	matches := make([]TournamentMatch, len(pairings))
+	var waitGroup sync.WaitGroup
+	for index, pairing := range pairings {
+		matches[index] = TournamentMatch{
+			ID:    fmt.Sprintf("round%v-match%v", round, index+1),
+			Round: round,
+		}
+		for _, player := range pairing {
+			matches[index].Players = append(matches[index].Players, player.Name)
+		}
+
+		room := <-rooms
+		waitGroup.Add(1)
+		go func(match *TournamentMatch, pairing []TournamentPlayer, room int) {
+			defer waitGroup.Done()
+			winner, summary, err := runMatch(pairing, room, match.ID)
+			rooms <- room
+
+			match.Winner = winner
+			match.GameSummary = summary
+			if err != nil {
+				match.Error = err.Error()
+			}
+		}(&matches[index], pairing, room)
+	}
+
+	waitGroup.Wait()
+	return matches
+}
+
+func printMatch(match TournamentMatch) {
+	result := match.Winner
+	if match.Error != "" {
+		result = fmt.Sprintf("failed (%v)", match.Error)
+	} else if result == "" {
+		result = "no winner"
+	}
+	fmt.Printf("  %v: %v\n", strings.Join(match.Players, " vs "), result)
+}
+
+// Runs a single elimination bracket. Players are sorted by seed.
+// Top seeds get a bye in the first round if the number of players is not a
+// power of two. The higher seed advances when a match has no winner.
+// Returns the results of all matches and the tournament winner.
+func runSingleElimination(players []TournamentPlayer, nbRooms int,
+	runMatch matchRunner) ([]TournamentMatch, string) {
+	matches := []TournamentMatch{}
+	remaining := players
+	for round := 1; len(remaining) > 1; round++ {
+		bracketSize := 1
+		for bracketSize < len(remaining) {
+			bracketSize *= 2
+		}
+		nbByes := bracketSize - len(remaining)
+
+		var pairings [][]TournamentPlayer
+		for i := nbByes; i < len(remaining); i += 2 {
+			pairings = append(pairings, remaining[i:i+2])
+		}
+		roundMatches := runRound(round, pairings, nbRooms, runMatch)
+
+		fmt.Printf("Round %v\n", round)
+		qualified := append([]TournamentPlayer(nil), remaining[:nbByes]...)
+		for _, player := range qualified {
+			fmt.Printf("  %v: bye\n", player.Name)
+		}
+		for index, match := range roundMatches {
+			printMatch(match)
+			winner := pairings[index][0]
+			if match.Winner == pairings[index][1].Name {
+				winner = pairings[index][1]
+			} else if match.Winner == "" {
+				fmt.Printf("  %v advances as the higher seed\n", winner.Name)
+			}
+			qualified = append(qualified, winner)
+		}
+
+		matches = append(matches, roundMatches...)
+		remaining = qualified
+	}
+
+	fmt.Printf("Tournament winner: %v\n", remaining[0].Name)
+	return matches, remaining[0].Name
+}
+
+// The standing of a player in a Swiss tournament.
+type swissStanding struct {
+	player    TournamentPlayer
+	seed      int
+	score     float64
+	opponents map[string]bool
+	hadBye    bool
+}
+
+// Sorts standings by decreasing score, then by seed.
+func sortStandings(standings []*swissStanding) {
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].score != standings[j].score {
+			return standings[i].score > standings[j].score
+		}
+		return standings[i].seed < standings[j].seed
+	})
+}
+
+// Pairs players of close scores that have not played each other yet.
+// standings must be sorted. Each player is paired with the closest player
+// it can be paired with, so that all the other players can be paired too.
+// Returns false if no pairing avoids rematches.
+func swissPairingsWithoutRematch(standings []*swissStanding) (
+	[][]*swissStanding, bool) {
+	if len(standings) == 0 {
+		return [][]*swissStanding{}, true
+	}
+
+	first := standings[0]
+	for j := 1; j < len(standings); j++ {
+		if first.opponents[standings[j].player.Name] {
+			continue
+		}
+
+		others := append([]*swissStanding(nil), standings[1:j]...)
+		others = append(others, standings[j+1:]...)
+		pairings, ok := swissPairingsWithoutRematch(others)
+		if ok {
+			return append([][]*swissStanding{{first, standings[j]}},
+				pairings...), true
+		}
+	}
+	return nil, false
+}
+
+// Pairs players of close scores, avoiding rematches if possible.
+// standings must be sorted.
+func swissPairings(standings []*swissStanding) [][]*swissStanding {
+	pairings, ok := swissPairingsWithoutRematch(standings)
+	if ok {
+		return pairings
+	}
+
+	// All players have met: Pair them in standings order
+	pairings = [][]*swissStanding{}
+	for i := 0; i+1 < len(standings); i += 2 {
+		pairings = append(pairings, standings[i:i+2])
+	}
+	return pairings
+}
+
+// Runs a Swiss tournament: Each round pairs players with close accumulated
+// scores (win: 1 point, no winner: 0.5 point, bye: 1 point).
+// When the number of players is odd, the lowest ranked player that did not
+// have a bye yet gets one. Ties in the standings are broken by seed.
+// Returns the results of all matches and the final standings.
+func runSwiss(players []TournamentPlayer, nbRounds, nbRooms int,
+	runMatch matchRunner) ([]TournamentMatch, []*swissStanding) {
+	if nbRounds == 0 {
+		for 1<<uint(nbRounds) < len(players) {
+			nbRounds++
+		}
+	}
+
+	standings := []*swissStanding{}
+	for seed, player := range players {
+		standings = append(standings, &swissStanding{
+			player:    player,
+			seed:      seed,
+			opponents: make(map[string]bool),
+		})
+	}
+
+	matches := []TournamentMatch{}
+	for round := 1; round <= nbRounds; round++ {
+		sortStandings(standings)
+		paired := standings
+		var bye *swissStanding
+		if len(standings)%2 == 1 {
+			for i := len(standings) - 1; i >= 0; i-- {
+				if !standings[i].hadBye {
+					bye = standings[i]
+					break
+				}
+			}
+			if bye == nil {
+				bye = standings[len(standings)-1]
+			}
+
+			paired = []*swissStanding{}
+			for _, standing := range standings {
+				if standing != bye {
+					paired = append(paired, standing)
+				}
+			}
+		}
+
+		pairings := swissPairings(paired)
+		playerPairings := [][]TournamentPlayer{}
+		for _, pairing := range pairings {
+			playerPairings = append(playerPairings,
+				[]TournamentPlayer{pairing[0].player, pairing[1].player})
+		}
+		roundMatches := runRound(round, playerPairings, nbRooms, runMatch)
+
+		fmt.Printf("Round %v\n", round)
+		for index, match := range roundMatches {
+			printMatch(match)
+			for _, standing := range pairings[index] {
+				for _, opponent := range pairings[index] {
+					if opponent != standing {
+						standing.opponents[opponent.player.Name] = true
+					}
+				}
+
+				if match.Winner == standing.player.Name {
+					standing.score++
+				} else if match.Winner == "" {
+					standing.score += 0.5
+				}
+			}
+		}
+		if bye != nil {
+			fmt.Printf("  %v: bye\n", bye.player.Name)
+			bye.hadBye = true
+			bye.score++
+		}
+
+		matches = append(matches, roundMatches...)
+	}
+
+	sortStandings(standings)
+	fmt.Println("Standings")
+	for rank, standing := range standings {
+		fmt.Printf("  %v. %v (%v points)\n", rank+1, standing.player.Name,
+			standing.score)
+	}
+	fmt.Printf("Tournament winner: %v\n", standings[0].player.Name)
+	return matches, standings
+}
+
+// Reads the JSON logs of a netorcai game, and forwards the ones that
+// matter to the tournament (the game is listening, the game is finished or
+// aborted).
+// The other logs of the game are summarized into summary, which can be read
+// once events is closed. Latencies and crashes are indexed by nickname.
+// onTurn (if any) is called whenever a turn starts, and once the last turn
+// is over (when the game is finished).
+func readGameEvents(reader *bufio.Reader, events chan map[string]interface{},
+	summary *GameSummary, onTurn func()) {
+	defer close(events)
+	finished := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		var entry map[string]interface{}
+		if json.Unmarshal([]byte(line), &entry) != nil {
+			continue
+		}
+		message, _ := ReadString(entry, "msg")
+		nickname, _ := ReadString(entry, "nickname")
+		switch {
+		case message == "Listening incoming connections":
+			events <- entry
+		case strings.HasPrefix(message, "Game is finished"),
+			message == "Game aborted by the operator":
+			finished = true
+			summary.AbortReason, _ = ReadString(entry, "abort reason")
+			if onTurn != nil {
+				onTurn()
+			}
+			events <- entry
+		case message == "Sending TURN to players" && onTurn != nil:
+			onTurn()
+		case message == "Game report":
+			summary.NbTurns, _ = ReadInt(entry, "turns")
+		case message == "Player report":
+			latency, isNumber := entry["mean turn ack latency (ms)"].(float64)
+			if isNumber {
+				if summary.Latencies == nil {
+					summary.Latencies = make(map[string]float64)
+				}
+				summary.Latencies[nickname] = latency
+			}
+		case message == "Player protocol report":
+			var stats ClientProtocolStats
+			stats.MessagesSent, _ = ReadInt(entry, "messages sent")
+			stats.MessagesReceived, _ = ReadInt(entry, "messages received")
+			stats.InvalidMessages, _ = ReadInt(entry, "invalid messages")
+			stats.Reconnects, _ = ReadInt(entry, "reconnects")
+			if summary.ProtocolStats == nil {
+				summary.ProtocolStats = make(map[string]ClientProtocolStats)
+			}
+			summary.ProtocolStats[nickname] = stats
+		case message == "Kicking client" && !finished:
+			// Clients are kicked once the game is over: Only kicks during
+			// the game matter
+			reason, _ := ReadString(entry, "reason")
+			if strings.Contains(reason, "Remote endpoint closed?") {
+				summary.Crashed = append(summary.Crashed, nickname)
+			} else {
+				summary.Kicked = append(summary.Kicked, nickname)
+			}
+		}
+	}
+}
+
+// Name of the game logic command in resource limit violations
+// (it cannot be a player name, as it contains a space)
+const gameLogicCommandName = "game logic"
+
+// A netorcai process that runs a game, with its game logic (and players)
+// commands.
+type gameProcess struct {
+	cmd      *exec.Cmd
+	events   chan map[string]interface{}
+	summary  GameSummary
+	port     int
+	commands []*exec.Cmd
+	logFile  *os.File
+
+	// Player commands are run in a sandbox by the executable
+	sandbox    bool
+	executable string
+
+	limits         ResourceLimits
+	stopWatching   chan int
+	usageMtx       sync.Mutex
+	violations     map[string]string // Exceeded limit of each command
+	playerCommands map[string]*exec.Cmd
+	cpuTimes       map[string]time.Duration // Last measured CPU times
+	turnCPUTimes   map[string][]float64
+	nbMeasures     int
+}
+
+// Runs a netorcai process that listens on port and automatically starts
+// its game once nbPlayers players are logged in, and runs its game logic.
+func startGameProcess(matchID string, nbPlayers int,
+	settings TournamentSettings, port int,
+	timeout <-chan time.Time) (*gameProcess, error) {
+	if settings.GLChecksum != "" {
+		err := CheckGLExecutable(settings.GLCommand, settings.GLExecutable,
+			settings.GLChecksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	arguments := append([]string{
+		fmt.Sprintf("--port=%v", port),
+		fmt.Sprintf("--nb-players-max=%v", nbPlayers),
+		"--nb-splayers-max=0",
+		"--nb-visus-max=0",
+		"--autostart",
+		"--simple-prompt",
+		"--json-logs",
+		"--verbose",
+		"--log-turns",
+	}, settings.GameArguments...)
+	game := &gameProcess{
+		cmd: exec.Command(settings.Executable, arguments...),
+		// Each event is only logged once, so reading events never blocks
+		events: make(chan map[string]interface{}, 2),
+		port:   port,
+
+		sandbox:    settings.Sandbox,
+		executable: settings.Executable,
+
+		limits:         settings.Limits,
+		stopWatching:   make(chan int),
+		violations:     make(map[string]string),
+		playerCommands: make(map[string]*exec.Cmd),
+		cpuTimes:       make(map[string]time.Duration),
+		turnCPUTimes:   make(map[string][]float64),
+	}
+	var logs io.Writer = ioutil.Discard
+	logFilename := ""
+	game.cmd.Stderr = os.Stderr
+	if settings.MatchLogsDirectory != "" {
+		logFilename = filepath.Join(settings.MatchLogsDirectory, matchID+".log")
+		logFile, err := os.Create(logFilename)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create match log file: %v",
+				err.Error())
+		}
+		game.logFile = logFile
+		game.cmd.Stderr = logFile
+		logs = logFile
+	}
+
+	stdout, err := game.cmd.StdoutPipe()
+	if err == nil {
+		err = game.cmd.Start()
+	}
+	if err != nil {
+		if game.logFile != nil {
+			game.logFile.Close()
+		}
+		return nil, fmt.Errorf("Cannot run netorcai: %v", err.Error())
+	}
+	log.WithFields(log.Fields{
+		"match id": matchID,
+		"port":     port,
+		"log file": logFilename,
+	}).Info("Running game")
+	go readGameEvents(bufio.NewReader(io.TeeReader(stdout, logs)), game.events,
+		&game.summary, game.measureCPUTimes)
+
+	select {
+	case _, ok := <-game.events:
+		if !ok {
+			game.stop()
+			return nil, fmt.Errorf("netorcai exited before listening")
+		}
+	case <-timeout:
+		game.stop()
+		return nil, fmt.Errorf("netorcai did not listen in time")
+	}
+
+	err = game.startCommand(gameLogicCommandName, settings.GLCommand)
+	if err != nil {
+		game.stop()
+		return nil, fmt.Errorf("Cannot run game logic command: %v", err.Error())
+	}
+	return game, nil
+}
+
+// Runs a command that gets the port of the game (NETORCAI_PORT) in its
+// environment. The command is stopped with the game, or as soon as it
+// exceeds the resource limits of the game.
+// name identifies the command (a player name, or gameLogicCommandName).
+// Player commands are sandboxed if the game is.
+func (g *gameProcess) startCommand(name, command string, env ...string) error {
+	env = append([]string{fmt.Sprintf("NETORCAI_PORT=%v", g.port)}, env...)
+	var cmd *exec.Cmd
+	var err error
+	if g.sandbox && name != gameLogicCommandName {
+		cmd, err = startSandboxedCommand(g.executable, command, g.port, env,
+			g.logFile)
+	} else {
+		cmd, err = startShellCommand(command, env, g.logFile)
+	}
+	if err != nil {
+		return err
+	}
+	g.commands = append(g.commands, cmd)
+	if name != gameLogicCommandName {
+		g.usageMtx.Lock()
+		g.playerCommands[name] = cmd
+		g.usageMtx.Unlock()
+	}
+
+	if g.limits.enabled() && resourceUsageSupported {
+		go watchResourceLimits(name, cmd, g.limits, g.stopWatching,
+			func(exceeded string) {
+				g.usageMtx.Lock()
+				g.violations[name] = exceeded
+				g.usageMtx.Unlock()
+			})
+	}
+	return nil
+}
+
+// Checks that a SHA-256 checksum is written in hexadecimal.
+func CheckSHA256(checksum string) error {
+	if !regexp.MustCompile(`\A[0-9a-fA-F]{64}\z`).MatchString(checksum) {
+		return fmt.Errorf("Invalid SHA-256 checksum '%v' (64 hexadecimal "+
+			"digits expected)", checksum)
+	}
+	return nil
+}
+
+// The interpreters that game logic commands may run (e.g. python3 gl.py).
+// Versions are ignored (python3.8 is python).
+var glInterpreters = map[string]bool{
+	"bash": true, "dotnet": true, "env": true, "java": true, "julia": true,
+	"lua": true, "luajit": true, "mono": true, "node": true, "nodejs": true,
+	"perl": true, "php": true, "pypy": true, "python": true, "Rscript": true,
+	"ruby": true, "sh": true, "zsh": true,
+}
+
+// Checks that the executable of a game logic command has the expected
+// SHA-256 checksum, so that a stale or tampered game logic build is never
+// run. The executable is the first word of the command (looked up in PATH
+// if needed), unless given (e.g. the script run by an interpreter).
+// As the checksum of an interpreter does not check the game logic, the
+// executable must be given if the command runs an interpreter.
+func CheckGLExecutable(command, executable, checksum string) error {
+	if executable == "" {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return fmt.Errorf("Empty game logic command")
+		}
+		name := strings.TrimRight(filepath.Base(fields[0]), "0123456789.")
+		if glInterpreters[name] {
+			return fmt.Errorf("The game logic command runs the %v "+
+				"interpreter, whose checksum would not check the game "+
+				"logic: The game logic executable must be given",
+				fields[0])
+		}
+		executable = fields[0]
+	}
+	path, err := exec.LookPath(executable)
+	if err != nil {
+		return fmt.Errorf("Cannot find game logic executable: %v",
+			err.Error())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read game logic executable: %v",
+			err.Error())
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return fmt.Errorf("Cannot read game logic executable: %v",
+			err.Error())
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, checksum) {
+		return fmt.Errorf("Game logic executable %v has SHA-256 %v "+
+			"instead of %v", path, actual, strings.ToLower(checksum))
+	}
+	return nil
+}
+
+// Measures the CPU time used by each player command since the previous
+// measure. Called when a turn starts and when the game is finished,
+// so that each measure (but the first) is the CPU time of a turn.
+func (g *gameProcess) measureCPUTimes() {
+	if !resourceUsageSupported {
+		return
+	}
+
+	g.usageMtx.Lock()
+	defer g.usageMtx.Unlock()
+	for name, cmd := range g.playerCommands {
+		cpuTime, _, _ := processGroupUsage(cmd.Process.Pid)
+		if cpuTime < g.cpuTimes[name] {
+			// Some processes have exited
+			cpuTime = g.cpuTimes[name]
+		}
+		if g.nbMeasures > 0 {
+			g.turnCPUTimes[name] = append(g.turnCPUTimes[name],
+				float64(cpuTime-g.cpuTimes[name])/float64(time.Millisecond))
+		}
+		g.cpuTimes[name] = cpuTime
+	}
+	g.nbMeasures++
+}
+
+// Returns the CPU time used by a player command during the game, and during
+// each turn. Once the command has been waited for, the CPU time of its
+// processes that have exited is known too.
+func (g *gameProcess) playerCPUTimes(name string) (time.Duration, []float64) {
+	g.usageMtx.Lock()
+	defer g.usageMtx.Unlock()
+	cpuTime := g.cpuTimes[name]
+	if cmd := g.playerCommands[name]; cmd != nil && cmd.ProcessState != nil {
+		waited := cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		if waited > cpuTime {
+			cpuTime = waited
+		}
+	}
+	return cpuTime, g.turnCPUTimes[name]
+}
+
+// Returns the exceeded limit of a command ("" if none).
+func (g *gameProcess) violation(name string) string {
+	g.usageMtx.Lock()
+	defer g.usageMtx.Unlock()
+	return g.violations[name]
+}
+
+// Waits for the end of the game. Returns the nickname of the winner
+// ("" if there is no winner).
+func (g *gameProcess) waitWinner(timeout <-chan time.Time) (string, error) {
+	select {
+	case entry, ok := <-g.events:
+		if !ok {
+			return "", fmt.Errorf("Game did not finish")
+		}
+		if reason, isAborted := entry["abort reason"]; isAborted {
+			return "", fmt.Errorf("Game aborted by the operator: %v", reason)
+		}
+		if _, hasWinner := entry["winner nickname"]; !hasWinner {
+			return "", nil
+		}
+		return ReadString(entry, "winner nickname")
+	case <-timeout:
+		return "", fmt.Errorf("Game did not finish in time")
+	}
+}
+
+// Stops netorcai and the commands of the game.
+// netorcai is given some time to exit by itself (e.g. after the end of the
+// game), so that its last logs are kept.
+func (g *gameProcess) stop() {
+	// Read the remaining logs before Wait closes the netorcai output
+	grace := time.After(time.Second)
+	for events := g.events; events != nil; {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+		case <-grace:
+			g.cmd.Process.Kill()
+		}
+	}
+	g.cmd.Wait()
+	close(g.stopWatching)
+	for _, cmd := range g.commands {
+		stopShellCommand(cmd)
+	}
+
+	if g.logFile != nil {
+		g.logFile.Close()
+	}
+}
+
+// Runs a tournament game in a netorcai process that listens on port,
+// with its game logic and players. Returns the name of the winner and the
+// summary of the game (restricted to the players).
+func runTournamentGame(matchID string, players []TournamentPlayer,
+	settings TournamentSettings, port int) (string, GameSummary, error) {
+	settings.GameArguments = append([]string(nil), settings.GameArguments...)
+	for _, player := range players {
+		if player.Address != "" {
+			settings.GameArguments = append(settings.GameArguments,
+				"--connect-player="+player.Address)
+		}
+	}
+
+	timeout := time.After(settings.MatchTimeout)
+	game, err := startGameProcess(matchID, len(players), settings, port,
+		timeout)
+	if err != nil {
+		return "", GameSummary{}, err
+	}
+
+	for _, player := range players {
+		if player.Address != "" {
+			continue
+		}
+		err = game.startCommand(player.Name, player.Command,
+			"NETORCAI_NICKNAME="+player.Name)
+		if err != nil {
+			game.stop()
+			return "", GameSummary{}, fmt.Errorf(
+				"Cannot run command of player %v: %v", player.Name, err.Error())
+		}
+	}
+
+	winner, err := game.waitWinner(timeout)
+	game.stop()
+
+	// The game logic (or any other client) may use a player nickname
+	isPlayer := make(map[string]bool)
+	for _, player := range players {
+		isPlayer[player.Name] = true
+	}
+	summary := GameSummary{
+		NbTurns:     game.summary.NbTurns,
+		AbortReason: game.summary.AbortReason,
+	}
+	for nickname, latency := range game.summary.Latencies {
+		if isPlayer[nickname] {
+			if summary.Latencies == nil {
+				summary.Latencies = make(map[string]float64)
+			}
+			summary.Latencies[nickname] = latency
+		}
+	}
+	for nickname, stats := range game.summary.ProtocolStats {
+		if isPlayer[nickname] {
+			if summary.ProtocolStats == nil {
+				summary.ProtocolStats = make(map[string]ClientProtocolStats)
+			}
+			summary.ProtocolStats[nickname] = stats
+		}
+	}
+	if resourceUsageSupported {
+		summary.CPUTimes = make(map[string]float64)
+		summary.TurnCPUTimes = make(map[string][]float64)
+		for _, player := range players {
+			if player.Address == "" {
+				cpuTime, turnCPUTimes := game.playerCPUTimes(player.Name)
+				summary.CPUTimes[player.Name] =
+					float64(cpuTime) / float64(time.Millisecond)
+				summary.TurnCPUTimes[player.Name] = turnCPUTimes
+			}
+		}
+	}
+
+	// Players killed because of their resource usage are kicked,
+	// whether netorcai noticed it or not
+	kicked := make(map[string]bool)
+	for _, player := range players {
+		if game.violation(player.Name) != "" {
+			kicked[player.Name] = true
+			summary.Kicked = append(summary.Kicked, player.Name)
+		}
+	}
+	for _, nickname := range game.summary.Crashed {
+		if isPlayer[nickname] && !kicked[nickname] {
+			summary.Crashed = append(summary.Crashed, nickname)
+		}
+	}
+	for _, nickname := range game.summary.Kicked {
+		if isPlayer[nickname] && !kicked[nickname] {
+			summary.Kicked = append(summary.Kicked, nickname)
+		}
+	}
+
+	if violation := game.violation(gameLogicCommandName); violation != "" {
+		return "", summary, fmt.Errorf("Game logic killed: %v", violation)
+	}
+
+	if err != nil || winner == "" || isPlayer[winner] {
+		return winner, summary, err
+	}
+	return "", summary, fmt.Errorf(
+		"Winner nickname %v is not a player of the match", winner)
+}
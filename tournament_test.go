@@ -0,0 +1,309 @@
+package netorcai
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tournamentPlayers(nbPlayers int) []TournamentPlayer {
+	players := []TournamentPlayer{}
+	for i := 0; i < nbPlayers; i++ {
+		players = append(players, TournamentPlayer{
+			Name:    fmt.Sprintf("bot%v", i),
+			Command: "true",
+		})
+	}
+	return players
+}
+
+// The player with the highest index always wins
+func lastPlayerWins(players []TournamentPlayer, room int,
+	matchID string) (string, GameSummary, error) {
+	return players[len(players)-1].Name, GameSummary{}, nil
+}
+
+func TestReadTournamentPlayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-tournament")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "players")
+
+	err = ioutil.WriteFile(filename, []byte("# Players\n"+
+		"alice ./bot --level=3\n\n  bob   python3 bot.py\n"), 0644)
+	assert.NoError(t, err, "Cannot write players file")
+	players, err := ReadTournamentPlayers(filename)
+	assert.NoError(t, err, "Cannot read players file")
+	assert.Equal(t, []TournamentPlayer{
+		{Name: "alice", Command: "./bot --level=3"},
+		{Name: "bob", Command: "python3 bot.py"},
+	}, players)
+
+	invalidContents := []string{
+		"alice ./bot\n",
+		"alice ./bot\nbob\n",
+		"alice ./bot\nalice ./bot\n",
+		"alice ./bot\nbob_the_bot ./bot\n",
+	}
+	for _, content := range invalidContents {
+		err = ioutil.WriteFile(filename, []byte(content), 0644)
+		assert.NoError(t, err, "Cannot write players file")
+		_, err = ReadTournamentPlayers(filename)
+		assert.Error(t, err, "Invalid players file accepted: %q", content)
+	}
+}
+
+func TestCheckGLExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netorcai-tournament")
+	assert.NoError(t, err, "Cannot create temporary directory")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "gl")
+	err = ioutil.WriteFile(filename, []byte("#!/bin/sh\n"), 0755)
+	assert.NoError(t, err, "Cannot write game logic")
+
+	hash := sha256.Sum256([]byte("#!/bin/sh\n"))
+	checksum := hex.EncodeToString(hash[:])
+	assert.NoError(t, CheckSHA256(checksum))
+	assert.NoError(t, CheckGLExecutable(filename+" --turns=3", "",
+		strings.ToUpper(checksum)))
+	assert.NoError(t, CheckGLExecutable("sh "+filename, filename, checksum))
+	assert.Error(t, CheckGLExecutable("sh "+filename, "", checksum),
+		"An interpreter should not be checked as game logic")
+	assert.Error(t, CheckGLExecutable("/usr/bin/python3.8 gl.py", "",
+		checksum), "An interpreter should not be checked as game logic")
+
+	err = ioutil.WriteFile(filename, []byte("#!/bin/sh\nexit 1\n"), 0755)
+	assert.NoError(t, err, "Cannot write game logic")
+	assert.Error(t, CheckGLExecutable(filename, "", checksum),
+		"A modified game logic should be rejected")
+	assert.Error(t, CheckGLExecutable(filepath.Join(dir, "none"), "",
+		checksum),
+		"A missing game logic should be rejected")
+	assert.Error(t, CheckSHA256("9ed9"), "Short checksum accepted")
+}
+
+func TestSingleEliminationByes(t *testing.T) {
+	matches, winner := runSingleElimination(tournamentPlayers(5), 1,
+		lastPlayerWins)
+
+	assert.Equal(t, "bot4", winner, "Unexpected tournament winner")
+	assert.Equal(t, []TournamentMatch{
+		{ID: "round1-match1", Round: 1, Players: []string{"bot3", "bot4"},
+			Winner: "bot4"},
+		{ID: "round2-match1", Round: 2, Players: []string{"bot0", "bot1"},
+			Winner: "bot1"},
+		{ID: "round2-match2", Round: 2, Players: []string{"bot2", "bot4"},
+			Winner: "bot4"},
+		{ID: "round3-match1", Round: 3, Players: []string{"bot1", "bot4"},
+			Winner: "bot4"},
+	}, matches)
+}
+
+func TestSingleEliminationNoWinner(t *testing.T) {
+	noWinner := func(players []TournamentPlayer, room int,
+		matchID string) (string, GameSummary, error) {
+		if players[0].Name == "bot2" {
+			return "", GameSummary{}, fmt.Errorf("Game did not finish")
+		}
+		return "", GameSummary{}, nil
+	}
+
+	matches, winner := runSingleElimination(tournamentPlayers(4), 1, noWinner)
+	assert.Equal(t, "bot0", winner, "The higher seed should advance")
+	assert.Equal(t, 3, len(matches), "Unexpected number of matches")
+	assert.Equal(t, "Game did not finish", matches[1].Error)
+	assert.Equal(t, []string{"bot0", "bot2"}, matches[2].Players)
+}
+
+func TestTournamentRooms(t *testing.T) {
+	var mutex sync.Mutex
+	usedRooms := make(map[int]bool)
+	roomsInUse := make(map[int]bool)
+	runMatch := func(players []TournamentPlayer, room int,
+		matchID string) (string, GameSummary, error) {
+		mutex.Lock()
+		assert.False(t, roomsInUse[room], "Room %v used twice at once", room)
+		roomsInUse[room] = true
+		usedRooms[room] = true
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		mutex.Lock()
+		delete(roomsInUse, room)
+		mutex.Unlock()
+		return players[0].Name, GameSummary{}, nil
+	}
+
+	matches, winner := runSingleElimination(tournamentPlayers(8), 2, runMatch)
+	assert.Equal(t, "bot0", winner, "Unexpected tournament winner")
+	assert.Equal(t, 7, len(matches), "Unexpected number of matches")
+	for room := range usedRooms {
+		assert.True(t, room == 0 || room == 1, "Unexpected room %v", room)
+	}
+}
+
+func TestSwissPairingsAvoidRematches(t *testing.T) {
+	players := tournamentPlayers(4)
+	var pairs []string
+	runMatch := func(players []TournamentPlayer, room int,
+		matchID string) (string, GameSummary, error) {
+		pairs = append(pairs, players[0].Name+"-"+players[1].Name)
+		return players[0].Name, GameSummary{}, nil
+	}
+
+	matches, standings := runSwiss(players, 3, 1, runMatch)
+	assert.Equal(t, 6, len(matches), "Unexpected number of matches")
+	assert.ElementsMatch(t, []string{"bot0-bot1", "bot2-bot3", "bot0-bot2",
+		"bot1-bot3", "bot0-bot3", "bot1-bot2"}, pairs,
+		"Each player should meet all the others once")
+
+	assert.Equal(t, "bot0", standings[0].player.Name, "Unexpected winner")
+	assert.Equal(t, 3.0, standings[0].score, "Unexpected winner score")
+}
+
+func TestSwissByes(t *testing.T) {
+	matches, standings := runSwiss(tournamentPlayers(5), 0, 2, lastPlayerWins)
+
+	// 3 rounds of 2 matches, each round with a different bye
+	assert.Equal(t, 6, len(matches), "Unexpected number of matches")
+	nbByes := 0
+	totalScore := 0.0
+	for _, standing := range standings {
+		if standing.hadBye {
+			nbByes++
+		}
+		totalScore += standing.score
+	}
+	assert.Equal(t, 3, nbByes, "Byes should go to different players")
+	assert.Equal(t, 9.0, totalScore, "Each match and bye gives 1 point")
+}
+
+func TestSwissNoWinner(t *testing.T) {
+	noWinner := func(players []TournamentPlayer, room int,
+		matchID string) (string, GameSummary, error) {
+		return "", GameSummary{}, nil
+	}
+
+	_, standings := runSwiss(tournamentPlayers(2), 1, 1, noWinner)
+	for _, standing := range standings {
+		assert.Equal(t, 0.5, standing.score, "A game without winner is a draw")
+	}
+	assert.Equal(t, "bot0", standings[0].player.Name, "Ties are broken by seed")
+}
+
+func TestSwissPairingsBacktrack(t *testing.T) {
+	standings := []*swissStanding{}
+	for _, player := range tournamentPlayers(4) {
+		standings = append(standings, &swissStanding{
+			player:    player,
+			opponents: make(map[string]bool),
+		})
+	}
+
+	// bot0-bot2 would force the bot1-bot3 rematch
+	standings[1].opponents["bot3"] = true
+	standings[3].opponents["bot1"] = true
+	standings[0].opponents["bot1"] = true
+	standings[1].opponents["bot0"] = true
+
+	pairings := swissPairings(standings)
+	assert.Equal(t, 2, len(pairings), "Unexpected number of pairings")
+	assert.Equal(t, []*swissStanding{standings[0], standings[3]}, pairings[0])
+	assert.Equal(t, []*swissStanding{standings[1], standings[2]}, pairings[1])
+}
+
+func TestReadGameEvents(t *testing.T) {
+	logs := `{"msg":"Listening incoming connections","port":4242}
+{"msg":"Kicking client","nickname":"bot2","reason":"LOGIN denied: Game has been started"}
+{"msg":"Kicking client","nickname":"bot1","reason":"Cannot read TURN_ACK. Remote endpoint closed? Read error: EOF"}
+not json
+{"msg":"Sending TURN to players","turn number":0}
+{"msg":"Sending TURN to players","turn number":1}
+{"msg":"Game report","turns":12}
+{"msg":"Player report","nickname":"bot0","mean turn ack latency (ms)":1.5}
+{"msg":"Player protocol report","nickname":"bot0","messages sent":14,"messages received":13,"invalid messages":0,"reconnects":1}
+{"msg":"Game is finished","winner nickname":"bot0"}
+{"msg":"Kicking client","nickname":"bot0","reason":"netorcai abort"}
+`
+	events := make(chan map[string]interface{}, 2)
+	var summary GameSummary
+	nbTurnBoundaries := 0
+	readGameEvents(bufio.NewReader(strings.NewReader(logs)), events, &summary,
+		func() { nbTurnBoundaries++ })
+	assert.Equal(t, 3, nbTurnBoundaries, "Turn starts and game end should be notified")
+
+	nbEvents := 0
+	for range events {
+		nbEvents++
+	}
+	assert.Equal(t, 2, nbEvents, "Unexpected number of forwarded events")
+	assert.Equal(t, GameSummary{
+		NbTurns:   12,
+		Latencies: map[string]float64{"bot0": 1.5},
+		Crashed:   []string{"bot1"},
+		Kicked:    []string{"bot2"},
+		ProtocolStats: map[string]ClientProtocolStats{
+			"bot0": {MessagesSent: 14, MessagesReceived: 13, Reconnects: 1},
+		},
+	}, summary, "Kicks after the end of the game should be ignored")
+}
+
+func TestReadGameEventsAborted(t *testing.T) {
+	logs := `{"msg":"Listening incoming connections","port":4242}
+{"msg":"Game report","turns":3}
+{"msg":"Game aborted by the operator","abort reason":"Power outage"}
+{"msg":"Kicking client","nickname":"bot0","reason":"Game aborted by the operator: Power outage"}
+`
+	events := make(chan map[string]interface{}, 2)
+	var summary GameSummary
+	readGameEvents(bufio.NewReader(strings.NewReader(logs)), events, &summary,
+		nil)
+	<-events
+
+	game := &gameProcess{events: events}
+	winner, err := game.waitWinner(nil)
+	assert.Equal(t, "", winner)
+	assert.EqualError(t, err, "Game aborted by the operator: Power outage")
+	assert.Equal(t, GameSummary{NbTurns: 3, AbortReason: "Power outage"},
+		summary, "Kicks after the abort should be ignored")
+}
+
+func TestReportTournament(t *testing.T) {
+	matches := []TournamentMatch{
+		{Players: []string{"bot0", "bot1"}, Winner: "bot0",
+			GameSummary: GameSummary{NbTurns: 10,
+				Latencies: map[string]float64{"bot0": 1, "bot1": 4},
+				Kicked:    []string{"bot1"},
+				CPUTimes:  map[string]float64{"bot0": 30, "bot1": 5},
+				TurnCPUTimes: map[string][]float64{
+					"bot0": {2, 20, 8}, "bot1": {1, 1, 3}}}},
+		{Players: []string{"bot0", "bot2"}, Winner: "",
+			GameSummary: GameSummary{NbTurns: 20,
+				Latencies: map[string]float64{"bot0": 3},
+				Crashed:   []string{"bot2"},
+				CPUTimes:  map[string]float64{"bot0": 12},
+				TurnCPUTimes: map[string][]float64{
+					"bot0": {12}}}},
+	}
+
+	report := ReportTournament(tournamentPlayers(4), matches)
+	assert.Equal(t, 2, report.NbMatches)
+	assert.Equal(t, 15.0, report.MeanNbTurns)
+	assert.Equal(t, []TournamentPlayerReport{
+		{Name: "bot0", NbMatches: 2, NbWins: 1, WinRate: 0.5, MeanLatency: 2,
+			MeanNbTurns: 15, CPUTime: 42, MaxTurnCPUTime: 20},
+		{Name: "bot1", NbMatches: 1, MeanLatency: 4, NbKicks: 1,
+			MeanNbTurns: 10, CPUTime: 5, MaxTurnCPUTime: 3},
+		{Name: "bot2", NbMatches: 1, NbCrashes: 1, MeanNbTurns: 20},
+		{Name: "bot3"},
+	}, report.Players)
+}
@@ -0,0 +1,129 @@
+package netorcai
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+type messageTracer struct {
+	mutex          sync.Mutex
+	file           *os.File // nil once closed
+	maxPayloadSize int
+}
+
+type tracedMessage struct {
+	Timestamp     string `json:"timestamp"`
+	Direction     string `json:"direction"`
+	RemoteAddress string `json:"remote_address"`
+	Nickname      string `json:"nickname"`
+	ContentSize   uint32 `json:"content_size"`
+	Payload       string `json:"payload,omitempty"`
+	Truncated     bool   `json:"truncated,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+var (
+	globalTracerMutex sync.Mutex
+	globalTracer      *messageTracer
+)
+
+// Starts recording every protocol message into filename (one JSON object
+// per line). Payloads are truncated to maxPayloadSize bytes, and are not
+// written at all if maxPayloadSize is 0.
+func StartMessageTracing(filename string, maxPayloadSize int) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	globalTracerMutex.Lock()
+	globalTracer = &messageTracer{
+		file:           file,
+		maxPayloadSize: maxPayloadSize,
+	}
+	globalTracerMutex.Unlock()
+	return nil
+}
+
+func StopMessageTracing() {
+	globalTracerMutex.Lock()
+	tracer := globalTracer
+	globalTracer = nil
+	globalTracerMutex.Unlock()
+
+	if tracer != nil {
+		// Messages being traced concurrently are dropped
+		tracer.mutex.Lock()
+		tracer.file.Close()
+		tracer.file = nil
+		tracer.mutex.Unlock()
+	}
+}
+
+func traceMessage(client *Client, direction string, contentSize uint32,
+	content []byte, traceErr error) {
+	globalTracerMutex.Lock()
+	tracer := globalTracer
+	globalTracerMutex.Unlock()
+	if tracer == nil {
+		return
+	}
+
+	msg := tracedMessage{
+		Timestamp:     time.Now().Format(time.RFC3339Nano),
+		Direction:     direction,
+		RemoteAddress: client.Conn.RemoteAddr().String(),
+		Nickname:      client.nickname,
+		ContentSize:   contentSize,
+	}
+
+	if direction == "in" {
+		content = redactAPIKey(content)
+	}
+	if len(content) > tracer.maxPayloadSize {
+		content = content[:tracer.maxPayloadSize]
+		msg.Truncated = true
+	}
+	msg.Payload = string(content)
+
+	if traceErr != nil {
+		msg.Error = traceErr.Error()
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	tracer.mutex.Lock()
+	if tracer.file != nil {
+		tracer.file.Write(append(line, '\n'))
+	}
+	tracer.mutex.Unlock()
+}
+
+// Hides the api_key of a LOGIN message, so that API keys are not written
+// in the trace file. Other messages are returned as is.
+func redactAPIKey(content []byte) []byte {
+	if !bytes.Contains(content, []byte(`"api_key"`)) {
+		return content
+	}
+
+	var msg map[string]interface{}
+	if json.Unmarshal(content, &msg) != nil {
+		// The key cannot be located: Nothing of the message is traced
+		return nil
+	}
+	if _, exists := msg["api_key"]; !exists {
+		return content
+	}
+	msg["api_key"] = "<redacted>"
+	redacted, err := json.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
@@ -0,0 +1,173 @@
+package netorcai
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Keeps the last turns of the running game in memory, so that visualizations
+// that log in while the game is running can be sent its past turns without
+// involving the game logic.
+type turnHistory struct {
+	mutex sync.Mutex
+	// Maximum number of turns kept (0 disables the history)
+	capacity int
+	// Whether visualizations can join the running game
+	accepting bool
+	// The GAME_STARTS sent to visualizations (nil until the game starts)
+	gameStarts  *MessageGameStarts
+	publicDelay int
+	// The last turns, as sent to live visualizations (oldest first)
+	turns []MessageTurn
+	// The visualizations that logged in while the game was running
+	lateVisus []*PlayerOrVisuClient
+}
+
+var (
+	globalTurnHistory = &turnHistory{}
+)
+
+// Starts the history of a new game. Must be called with the global state
+// mutex held, while the visualizations already logged in are copied.
+func (h *turnHistory) reset(capacity, publicDelay int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.capacity = capacity
+	h.accepting = capacity > 0
+	h.gameStarts = nil
+	h.publicDelay = publicDelay
+	h.turns = nil
+	h.lateVisus = nil
+}
+
+// Whether a visualization that logs in now should join the running game.
+// Must be called with the global state mutex held.
+func (h *turnHistory) acceptsVisus() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.accepting
+}
+
+// Makes a visualization join the running game. If the game has already
+// started, the GAME_STARTS and the past turns to send are returned.
+// Otherwise, GAME_STARTS will be sent by the game logic goroutine.
+// The next turns are sent to the newTurn channel of the visualization.
+func (h *turnHistory) join(visu *PlayerOrVisuClient) (*MessageGameStarts,
+	[]MessageTurn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lateVisus = append(h.lateVisus, visu)
+	if h.gameStarts == nil {
+		return nil, nil
+	}
+
+	gameStarts := *h.gameStarts
+	return &gameStarts, append([]MessageTurn(nil), h.pastTurns(visu)...)
+}
+
+// Returns the past turns a visualization can see.
+// Must be called with the mutex held.
+func (h *turnHistory) pastTurns(visu *PlayerOrVisuClient) []MessageTurn {
+	if visu.isLiveVisu {
+		return h.turns
+	}
+
+	// Public visualizations do not see the last publicDelay turns yet
+	nbPublicTurns := len(h.turns) - h.publicDelay
+	if nbPublicTurns <= 0 {
+		return nil
+	}
+	return h.turns[:nbPublicTurns]
+}
+
+// Removes a visualization from the running game.
+func (h *turnHistory) leave(visu *PlayerOrVisuClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for index, lateVisu := range h.lateVisus {
+		if lateVisu == visu {
+			h.lateVisus = append(h.lateVisus[:index], h.lateVisus[index+1:]...)
+			return
+		}
+	}
+}
+
+// Records the GAME_STARTS sent to visualizations.
+// Returns the visualizations that joined the game before it started,
+// that must be sent GAME_STARTS.
+func (h *turnHistory) started(gameStarts MessageGameStarts) []*PlayerOrVisuClient {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.accepting {
+		return nil
+	}
+	h.gameStarts = &gameStarts
+	return append([]*PlayerOrVisuClient(nil), h.lateVisus...)
+}
+
+// Records a new turn, and forwards it to the visualizations that joined the
+// game while it was running. publicTurn is nil if public visualizations
+// receive no turn yet.
+func (h *turnHistory) record(turn MessageTurn, publicTurn *MessageTurn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.accepting {
+		return
+	}
+	h.turns = append(h.turns, turn)
+	if len(h.turns) > h.capacity {
+		h.turns = h.turns[len(h.turns)-h.capacity:]
+	}
+
+	for _, visu := range h.lateVisus {
+		if visu.isLiveVisu {
+			visu.newTurn <- turn
+		} else if publicTurn != nil {
+			visu.newTurn <- *publicTurn
+		}
+	}
+}
+
+// Ends the history of the game. Returns the visualizations that joined the
+// game while it was running, that must be sent GAME_ENDS.
+func (h *turnHistory) end() []*PlayerOrVisuClient {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	lateVisus := h.lateVisus
+	h.accepting = false
+	h.gameStarts = nil
+	h.turns = nil
+	h.lateVisus = nil
+	return lateVisus
+}
+
+// Writes the occupancy of the history, in the Prometheus text format.
+func (h *turnHistory) writeMetrics(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	metrics := []struct {
+		name, help string
+		value      int
+	}{
+		{"netorcai_turn_history_turns",
+			"Number of turns kept in the turn history.", len(h.turns)},
+		{"netorcai_turn_history_capacity",
+			"Maximum number of turns kept in the turn history.", h.capacity},
+		{"netorcai_turn_history_late_visus",
+			"Number of visualizations that joined the running game.",
+			len(h.lateVisus)},
+	}
+
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v gauge\n%v %v\n", metric.name,
+			metric.help, metric.name, metric.name, metric.value)
+	}
+}
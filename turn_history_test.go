@@ -0,0 +1,91 @@
+package netorcai
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newHistoryVisu(isLiveVisu bool) *PlayerOrVisuClient {
+	return &PlayerOrVisuClient{
+		isLiveVisu: isLiveVisu,
+		newTurn:    make(chan MessageTurn, 100),
+	}
+}
+
+func turnNumbers(turns []MessageTurn) []int {
+	numbers := []int{}
+	for _, turn := range turns {
+		numbers = append(numbers, turn.TurnNumber)
+	}
+	return numbers
+}
+
+func receivedTurnNumbers(visu *PlayerOrVisuClient) []int {
+	turns := []MessageTurn{}
+	for {
+		select {
+		case turn := <-visu.newTurn:
+			turns = append(turns, turn)
+		default:
+			return turnNumbers(turns)
+		}
+	}
+}
+
+func TestTurnHistory(t *testing.T) {
+	history := &turnHistory{}
+	history.reset(3, 1)
+	assert.True(t, history.acceptsVisus(), "The history should accept visus")
+
+	// Visus that join before the game starts get GAME_STARTS later
+	early := newHistoryVisu(true)
+	gameStarts, pastTurns := history.join(early)
+	assert.Nil(t, gameStarts, "Unexpected GAME_STARTS")
+	assert.Empty(t, pastTurns, "Unexpected past turns")
+	lateVisus := history.started(MessageGameStarts{MessageType: "GAME_STARTS"})
+	assert.Equal(t, []*PlayerOrVisuClient{early}, lateVisus)
+
+	for turnNumber := 0; turnNumber < 5; turnNumber++ {
+		var publicTurn *MessageTurn
+		if turnNumber >= 1 {
+			publicTurn = &MessageTurn{TurnNumber: turnNumber - 1}
+		}
+		history.record(MessageTurn{TurnNumber: turnNumber}, publicTurn)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, receivedTurnNumbers(early))
+
+	// Only the last turns are kept
+	live := newHistoryVisu(true)
+	gameStarts, pastTurns = history.join(live)
+	assert.NotNil(t, gameStarts, "No GAME_STARTS")
+	assert.Equal(t, []int{2, 3, 4}, turnNumbers(pastTurns))
+
+	// Public visus do not see the last turns yet
+	public := newHistoryVisu(false)
+	gameStarts, pastTurns = history.join(public)
+	assert.NotNil(t, gameStarts, "No GAME_STARTS")
+	assert.Equal(t, []int{2, 3}, turnNumbers(pastTurns))
+
+	history.record(MessageTurn{TurnNumber: 5}, &MessageTurn{TurnNumber: 4})
+	assert.Equal(t, []int{5}, receivedTurnNumbers(live))
+	assert.Equal(t, []int{4}, receivedTurnNumbers(public))
+
+	var metrics bytes.Buffer
+	history.writeMetrics(&metrics)
+	assert.Contains(t, metrics.String(), "netorcai_turn_history_turns 3\n")
+	assert.Contains(t, metrics.String(), "netorcai_turn_history_late_visus 3\n")
+
+	history.leave(early)
+	assert.Equal(t, 2, len(history.end()), "Unexpected late visus")
+	assert.False(t, history.acceptsVisus(), "The ended history accepts visus")
+}
+
+func TestTurnHistoryDisabled(t *testing.T) {
+	history := &turnHistory{}
+	history.reset(0, 0)
+	assert.False(t, history.acceptsVisus(), "The history should be disabled")
+	assert.Nil(t, history.started(MessageGameStarts{}), "Unexpected late visus")
+	history.record(MessageTurn{}, nil)
+	assert.Empty(t, history.turns, "Turns kept by a disabled history")
+}
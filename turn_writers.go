@@ -0,0 +1,122 @@
+package netorcai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// How long writing a TURN on a client socket may take. Clients that do not
+// read their TURN for longer are considered disconnected.
+const turnWriteTimeout = 10 * time.Second
+
+// The game state of a turn, serialized once for all the TURN messages
+// that contain it.
+type encodedGameState struct {
+	once    sync.Once
+	content []byte
+	err     error
+}
+
+func (e *encodedGameState) encode(gameState map[string]interface{}) (
+	[]byte, error) {
+	e.once.Do(func() {
+		e.content, e.err = jsonBackend.Marshal(gameState)
+	})
+	return e.content, e.err
+}
+
+// Serializes a TURN. Its game state is only serialized by the first client
+// that receives it, if it is shared (see handleGlForwardTurnToClients).
+func encodeTurn(msg MessageTurn) ([]byte, error) {
+	if msg.encodedState == nil {
+		return jsonBackend.Marshal(msg)
+	}
+
+	gameState, err := msg.encodedState.encode(msg.GameState)
+	if err != nil {
+		return nil, err
+	}
+	type turnFields MessageTurn
+	return jsonBackend.Marshal(struct {
+		*turnFields
+		GameState json.RawMessage `json:"game_state"`
+	}{(*turnFields)(&msg), gameState})
+}
+
+// A TURN to write on a client socket, and where to report the result
+type turnWrite struct {
+	client  *Client
+	content []byte
+	done    chan error
+}
+
+// A fixed number of workers, which write the TURN messages queued by the
+// client goroutines (--broadcast-workers): It bounds the number of
+// concurrent TURN writes.
+// A nil turnWritePool lets every client goroutine write its TURN itself.
+type turnWritePool struct {
+	queue chan turnWrite
+	ctx   context.Context
+}
+
+// Starts the workers, which stop with ctx.
+// Returns nil if nbWorkers is 0 (unbounded).
+func newTurnWritePool(ctx context.Context, nbWorkers int) *turnWritePool {
+	if nbWorkers <= 0 {
+		return nil
+	}
+
+	p := &turnWritePool{
+		queue: make(chan turnWrite),
+		ctx:   ctx,
+	}
+	for worker := 0; worker < nbWorkers; worker++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *turnWritePool) work() {
+	for {
+		select {
+		case write := <-p.queue:
+			write.done <- writeTurn(write.client, write.content)
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// Writes a TURN on the socket of a client, and waits until it is written.
+func (p *turnWritePool) write(client *Client, content []byte) error {
+	if p == nil {
+		return writeTurn(client, content)
+	}
+
+	write := turnWrite{
+		client:  client,
+		content: content,
+		done:    make(chan error, 1),
+	}
+	select {
+	case p.queue <- write:
+	case <-p.ctx.Done():
+		return fmt.Errorf("netorcai is shutting down")
+	}
+	return <-write.done
+}
+
+// Writes a TURN within turnWriteTimeout.
+// On failure, the deadline is kept so that the KICK that follows cannot
+// block either.
+func writeTurn(client *Client, content []byte) error {
+	client.Conn.SetWriteDeadline(time.Now().Add(turnWriteTimeout))
+	err := sendMessage(client, content)
+	if err == nil {
+		client.Conn.SetWriteDeadline(time.Time{})
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package netorcai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestEncodeTurn(t *testing.T) {
+	turn := MessageTurn{
+		MessageType: "TURN",
+		TurnNumber:  3,
+		GameState:   map[string]interface{}{"units": []interface{}{1.0}},
+		PlayersInfo: []*PlayerInformation{},
+	}
+	expected, err := jsonBackend.Marshal(turn)
+	assert.NoError(t, err, "Cannot serialize TURN")
+
+	turn.encodedState = &encodedGameState{}
+	content, err := encodeTurn(turn)
+	assert.NoError(t, err, "Cannot serialize TURN")
+	assert.JSONEq(t, string(expected), string(content), "Unexpected TURN")
+
+	// The game state is only serialized by the first TURN that shares it
+	other := turn
+	other.GameState = map[string]interface{}{"units": []interface{}{}}
+	other.TurnNumber = 4
+	content, err = encodeTurn(other)
+	assert.NoError(t, err, "Cannot serialize TURN")
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content, &decoded), "Invalid TURN")
+	assert.Equal(t, 4.0, decoded["turn_number"], "Unexpected turn_number")
+	assert.Equal(t, turn.GameState, decoded["game_state"],
+		"Game state serialized again")
+}
+
+func TestTurnWritePool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTurnWritePool(ctx, 1)
+	assert.Nil(t, newTurnWritePool(ctx, 0), "Unbounded writes need no pool")
+
+	// Every queued TURN is written, by the single worker
+	const nbClients = 3
+	done := make(chan error, nbClients)
+	readers := []*bufio.Reader{}
+	for i := 0; i < nbClients; i++ {
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		readers = append(readers, bufio.NewReader(clientConn))
+		client := newClient(serverConn)
+		go func() {
+			done <- pool.write(client, []byte(`{"message_type":"TURN"}`))
+		}()
+	}
+	received := make(chan string, nbClients)
+	for _, reader := range readers {
+		reader := reader
+		go func() {
+			content, _, _ := readFrame(reader, framingV1, 1000, "%v")
+			received <- string(content)
+		}()
+	}
+	for i := 0; i < nbClients; i++ {
+		assert.NoError(t, <-done, "Cannot write TURN")
+		assert.Equal(t, "{\"message_type\":\"TURN\"}\n", <-received)
+	}
+}
@@ -8,3 +8,16 @@ var VersionMajor = 2
 var VersionMinor = 0
 var VersionPatch = 0
 var Version = fmt.Sprintf("%d.%d.%d", VersionMajor, VersionMinor, VersionPatch)
+
+// The optional metaprotocol features this netorcai implements, so that
+// clients can check whether they can rely on them.
+var Capabilities = []string{
+	"visu-tier",
+	"frame-skipping",
+	"turn-times",
+	"server-config",
+	"player-messages",
+	"accounts",
+	"connect-gl",
+	"connect-player",
+}
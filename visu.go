@@ -0,0 +1,108 @@
+package netorcai
+
+import (
+	"net/http"
+)
+
+// A minimal web visualization, for games that do not have their own.
+// It follows the stream of turns (/turns) and pretty-prints the game states
+// received since the page has been opened, and the last announcement of the
+// operator (ADMIN_MESSAGE).
+const webVisuPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>netorcai</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+#status { color: #666; }
+#announcement { background: #fff3c4; font-weight: bold; padding: 0.5em; }
+#state { background: #f4f4f4; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>netorcai</h1>
+<p id="announcement" hidden></p>
+<p id="status">Waiting for the first turn...</p>
+<p>
+<button id="first">|&lt;</button>
+<button id="previous">&lt;</button>
+<span id="position">0/0</span>
+<button id="next">&gt;</button>
+<button id="last">&gt;|</button>
+<label><input id="follow" type="checkbox" checked> Follow the game</label>
+</p>
+<pre id="state"></pre>
+<script>
+var messages = [];
+var index = -1;
+
+function show(newIndex) {
+  if (messages.length == 0) {
+    return;
+  }
+  index = Math.max(0, Math.min(newIndex, messages.length - 1));
+  var message = messages[index];
+  var title = message.message_type == "TURN" ?
+    "Turn " + message.turn_number : "Game is finished";
+  if (message.message_type == "GAME_ENDS") {
+    title += message.winner_player_id == -1 ? " (no winner)" :
+      " (winner: player " + message.winner_player_id + ")";
+  }
+  document.getElementById("status").textContent = title;
+  document.getElementById("position").textContent =
+    (index + 1) + "/" + messages.length;
+  document.getElementById("state").textContent =
+    JSON.stringify(message, null, 2);
+}
+
+function followed() {
+  return document.getElementById("follow").checked;
+}
+
+document.getElementById("first").onclick = function() { show(0); };
+document.getElementById("previous").onclick = function() { show(index - 1); };
+document.getElementById("next").onclick = function() { show(index + 1); };
+document.getElementById("last").onclick = function() {
+  show(messages.length - 1);
+};
+
+var stream = new EventSource("/turns");
+function received(event) {
+  messages.push(JSON.parse(event.data));
+  if (followed() || index == -1) {
+    show(messages.length - 1);
+  } else {
+    show(index);
+  }
+}
+stream.addEventListener("TURN", received);
+stream.addEventListener("ADMIN_MESSAGE", function(event) {
+  var announcement = document.getElementById("announcement");
+  announcement.textContent = JSON.parse(event.data).text;
+  announcement.hidden = false;
+});
+stream.addEventListener("GAME_ENDS", function(event) {
+  received(event);
+  stream.close();
+});
+stream.onerror = function() {
+  if (messages.length == 0) {
+    document.getElementById("status").textContent =
+      "Cannot follow the game (is it finished?)";
+  }
+};
+</script>
+</body>
+</html>
+`
+
+// Serves the web visualization page.
+func handleWebVisu(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(webVisuPage))
+}
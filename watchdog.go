@@ -0,0 +1,146 @@
+package netorcai
+
+import (
+	"bytes"
+	log "github.com/sirupsen/logrus"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// Detects running games that make no protocol progress: no DO_TURN_ACK
+// from the game logic, no TURN_ACK from players and no turn timer fired.
+// Turn timers and breakpoints are expected waits, not hangs.
+type gameWatchdog struct {
+	mutex        sync.Mutex
+	lastProgress time.Time
+	// No progress is expected before this time (a turn timer is armed)
+	waitingUntil time.Time
+	paused       bool
+	// Whether the game waits for the TURN_ACK of players (--fast),
+	// rather than for the game logic
+	waitingPlayers bool
+	// Returns the current time (time.Now if nil, replaced by tests)
+	now func() time.Time
+}
+
+var (
+	globalWatchdog = &gameWatchdog{}
+)
+
+func (w *gameWatchdog) clock() time.Time {
+	if w.now == nil {
+		return time.Now()
+	}
+	return w.now()
+}
+
+// Called whenever the game makes progress.
+func (w *gameWatchdog) progress() {
+	w.mutex.Lock()
+	w.lastProgress = w.clock()
+	w.mutex.Unlock()
+}
+
+// Called when a turn timer is armed, that should fire at deadline.
+func (w *gameWatchdog) timerArmed(deadline time.Time) {
+	w.mutex.Lock()
+	w.waitingUntil = deadline
+	w.mutex.Unlock()
+}
+
+// Called when the game is paused on a breakpoint, or resumed.
+func (w *gameWatchdog) setPaused(paused bool) {
+	w.mutex.Lock()
+	w.paused = paused
+	w.lastProgress = w.clock()
+	w.mutex.Unlock()
+}
+
+func (w *gameWatchdog) setWaitingPlayers(waiting bool) {
+	w.mutex.Lock()
+	w.waitingPlayers = waiting
+	w.mutex.Unlock()
+}
+
+// Returns for how long the game has made no unexpected progress, and what
+// it waits for.
+func (w *gameWatchdog) stalledFor() (time.Duration, string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	waitingFor := "game logic"
+	if w.waitingPlayers {
+		waitingFor = "players"
+	}
+	if w.paused {
+		return 0, waitingFor
+	}
+
+	since := w.lastProgress
+	if w.waitingUntil.After(since) {
+		since = w.waitingUntil
+	}
+	return w.clock().Sub(since), waitingFor
+}
+
+// Returns the stacks of all goroutines.
+func goroutineStacks() string {
+	var stacks bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&stacks, 2)
+	return stacks.String()
+}
+
+// Checks the progress of the game every timeout/4 until stop is closed.
+// Once the game makes no progress for timeout, the stacks of all goroutines
+// are logged, and netorcai aborts (onexit is notified).
+// If advance is set and the game waits for the TURN_ACK of players, the game
+// is forced to advance without them instead.
+func runGameWatchdog(glClient *GameLogicClient, timeout time.Duration,
+	advance bool, onexit chan int, stop chan int) {
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	globalWatchdog.watch(glClient, timeout, advance, ticker.C, onexit, stop)
+}
+
+// Same as runGameWatchdog, but checks the progress of the game whenever
+// ticks fires.
+func (w *gameWatchdog) watch(glClient *GameLogicClient, timeout time.Duration,
+	advance bool, ticks <-chan time.Time, onexit chan int, stop chan int) {
+	w.progress()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticks:
+		}
+
+		stalled, waitingFor := w.stalledFor()
+		if stalled < timeout {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"stalled for (ms)": stalled.Seconds() * 1000,
+			"waiting for":      waitingFor,
+			"goroutines":       goroutineStacks(),
+		}).Error("No protocol progress: game is hung")
+
+		if advance && waitingFor == "players" {
+			log.Warn("Forcing the game to advance without the missing TURN_ACK")
+			w.progress()
+			select {
+			case glClient.forceAdvance <- 1:
+			default:
+			}
+			continue
+		}
+
+		log.Warn("Aborting hung game")
+		select {
+		case onexit <- 1:
+		case <-stop:
+		}
+		return
+	}
+}
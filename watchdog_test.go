@@ -0,0 +1,111 @@
+package netorcai
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A clock that only moves forward when told to
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	c.now = c.now.Add(d)
+	c.mutex.Unlock()
+}
+
+// Starts a watchdog (timeout: 1 s) driven by the returned tick channel.
+func startTestWatchdog(advance bool) (*gameWatchdog, *fakeClock,
+	*GameLogicClient, chan time.Time, chan int, chan int) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	w := &gameWatchdog{now: clock.Now}
+	glClient := &GameLogicClient{forceAdvance: make(chan int, 1)}
+	ticks := make(chan time.Time)
+	onexit := make(chan int, 1)
+	stop := make(chan int)
+	go w.watch(glClient, time.Second, advance, ticks, onexit, stop)
+	// Once received, the watchdog has recorded its start as progress
+	ticks <- time.Time{}
+	return w, clock, glClient, ticks, onexit, stop
+}
+
+// Sends a tick to the watchdog. The send only completes once the previous
+// tick has been handled, so two ticks make sure that the first one did not
+// abort the game.
+func tickWatchdog(t *testing.T, ticks chan time.Time, onexit chan int) {
+	for i := 0; i < 2; i++ {
+		select {
+		case ticks <- time.Time{}:
+		case <-onexit:
+			assert.FailNow(t, "The game should not be aborted")
+		}
+	}
+}
+
+func TestWatchdogHang(t *testing.T) {
+	_, clock, _, ticks, onexit, stop := startTestWatchdog(false)
+	defer close(stop)
+
+	clock.advance(900 * time.Millisecond)
+	tickWatchdog(t, ticks, onexit)
+
+	clock.advance(100 * time.Millisecond)
+	ticks <- time.Time{}
+	select {
+	case code := <-onexit:
+		assert.Equal(t, 1, code, "Unexpected exit code")
+	case <-time.After(time.Second):
+		assert.Fail(t, "The hung game should be aborted")
+	}
+}
+
+func TestWatchdogProgress(t *testing.T) {
+	w, clock, _, ticks, onexit, stop := startTestWatchdog(false)
+	defer close(stop)
+
+	clock.advance(900 * time.Millisecond)
+	w.progress()
+	clock.advance(900 * time.Millisecond)
+	tickWatchdog(t, ticks, onexit)
+
+	// Armed turn timers are expected waits
+	w.timerArmed(clock.Now().Add(5 * time.Second))
+	clock.advance(5500 * time.Millisecond)
+	tickWatchdog(t, ticks, onexit)
+
+	// So are breakpoints
+	w.setPaused(true)
+	clock.advance(time.Minute)
+	tickWatchdog(t, ticks, onexit)
+}
+
+func TestWatchdogForceAdvance(t *testing.T) {
+	w, clock, glClient, ticks, onexit, stop := startTestWatchdog(true)
+	defer close(stop)
+
+	w.setWaitingPlayers(true)
+	clock.advance(2 * time.Second)
+	tickWatchdog(t, ticks, onexit)
+	select {
+	case <-glClient.forceAdvance:
+	default:
+		assert.Fail(t, "The game should be forced to advance")
+	}
+
+	// Forcing the game to advance is progress
+	clock.advance(900 * time.Millisecond)
+	tickWatchdog(t, ticks, onexit)
+	assert.Equal(t, 0, len(glClient.forceAdvance),
+		"The game should not be forced to advance twice")
+}